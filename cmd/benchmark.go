@@ -44,6 +44,7 @@ type rawBenchmarkCmdArgs struct {
 	sizePerFile    string
 	fileCount      uint
 	deleteTestData bool
+	keep           bool
 	numOfFolders   uint
 
 	// options from flags
@@ -166,7 +167,7 @@ func (raw rawBenchmarkCmdArgs) cook() (CookedCopyCmdArgs, error) {
 		glcm.Info(fmt.Sprintf("Benchmarking uploads to %s.", cooked.Destination.Value))
 	}
 
-	if !downloadMode && raw.deleteTestData {
+	if !downloadMode && raw.deleteTestData && !raw.keep {
 		// set up automatic cleanup
 		cooked.followupJobArgs, err = raw.createCleanupJobArgs(cooked.Destination, raw.logVerbosity)
 		if err != nil {
@@ -339,6 +340,7 @@ func init() {
 	benchCmd.PersistentFlags().UintVar(&raw.fileCount, common.FileCountParam, common.FileCountDefault, "number of auto-generated data files to use")
 	benchCmd.PersistentFlags().UintVar(&raw.numOfFolders, "number-of-folders", 0, "If larger than 0, create folders to divide up the data.")
 	benchCmd.PersistentFlags().BoolVar(&raw.deleteTestData, "delete-test-data", true, "if true, the benchmark data will be deleted at the end of the benchmark run.  Set it to false if you want to keep the data at the destination - e.g. to use it for manual tests outside benchmark mode")
+	benchCmd.PersistentFlags().BoolVar(&raw.keep, "keep", false, "keep the benchmark data at the destination. Short for --delete-test-data=false")
 
 	benchCmd.PersistentFlags().Float64Var(&raw.blockSizeMB, "block-size-mb", 0, "use this block size (specified in MiB). Default is automatically calculated based on file size. Decimal fractions are allowed - e.g. 0.25. Identical to the same-named parameter in the copy command")
 	benchCmd.PersistentFlags().StringVar(&raw.blobType, "blob-type", "Detect", "defines the type of blob at the destination. Used to allow benchmarking different blob types. Identical to the same-named parameter in the copy command")
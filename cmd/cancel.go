@@ -28,8 +28,8 @@ import (
 	"github.com/spf13/cobra"
 )
 
-// TODO should this command be removed? Previously AzCopy was supposed to have an independent backend (out of proc)
-// TODO but that's not the plan anymore
+// The cancel command runs in a different process from the job it cancels. It marks the job as cancelling in the job's
+// plan files, and the process that is running the job notices that and stops its in-flight transfers.
 type rawCancelCmdArgs struct {
 	jobID string
 }
@@ -68,7 +68,8 @@ func init() {
 		Use:        "cancel",
 		SuggestFor: []string{"cancl", "ancl", "cacl"},
 		Short:      "Stops an ongoing job with the given Job ID",
-		Long:       "Stops an ongoing job with the given Job ID",
+		Long: "Stops an ongoing job with the given Job ID. Run this from a separate terminal while the job is running. " +
+			"In-flight transfers are abandoned, and the job's status becomes Cancelled. A cancelled job can later be resumed with 'azcopy jobs resume'.",
 		Args: func(cmd *cobra.Command, args []string) error {
 			// the cancel command requires a JobId argument
 			// it then cancels all parts of the specified job
@@ -88,14 +89,13 @@ func init() {
 
 			err = cooked.process()
 			if err != nil {
-				glcm.Error("failed to perform copy command due to error " + err.Error())
+				glcm.Error("failed to perform cancel command due to error " + err.Error())
 			}
 
-			glcm.Exit(nil, common.EExitCode.Success())
+			glcm.Exit(func(format common.OutputFormat) string {
+				return "Job " + cooked.jobID.String() + " is being cancelled"
+			}, common.EExitCode.Success())
 		},
-		// hide features not relevant to BFS
-		// TODO remove after preview release.
-		Hidden: true,
 	}
 	rootCmd.AddCommand(cancelCmd)
 }
@@ -22,6 +22,7 @@ package cmd
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -30,6 +31,7 @@ import (
 	"math"
 	"net/url"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
@@ -80,6 +82,10 @@ type rawCopyCmdArgs struct {
 	excludeFileAttributes string
 	includeBefore         string
 	includeAfter          string
+	minSize               string
+	maxSize               string
+	newerThan             string
+	olderThan             string
 	legacyInclude         string // used only for warnings
 	legacyExclude         string // used only for warnings
 	listOfVersionIDs      string
@@ -89,13 +95,24 @@ type rawCopyCmdArgs struct {
 	recursive         bool
 	followSymlinks    bool
 	autoDecompress    bool
+	compress          bool
 	// forceWrite flag is used to define the User behavior
 	// to overwrite the existing blobs or not.
 	forceWrite      string
 	forceIfReadOnly bool
 
+	// retry options from flags. Zero means use the default
+	maxTries      int32
+	tryTimeout    time.Duration
+	retryDelay    time.Duration
+	maxRetryDelay time.Duration
+
+	// fail any transfer that runs longer than this. Zero means no limit
+	transferTimeout time.Duration
+
 	// options from flags
 	blockSizeMB              float64
+	putBlobSizeMB            float64
 	metadata                 string
 	contentType              string
 	contentEncoding          string
@@ -103,12 +120,24 @@ type rawCopyCmdArgs struct {
 	contentLanguage          string
 	cacheControl             string
 	noGuessMimeType          bool
+	contentTypeMap           string
 	preserveLastModifiedTime bool
 	putMd5                   bool
+	ifNoneMatch              bool
+	validateBlocks           bool
+	snapshotBeforeOverwrite  bool
+	serverSideCopy           bool
+	serverSideCopyProperties string
 	md5ValidationOption      string
 	CheckLength              bool
 	deleteSnapshotsOption    string
 	dryrun                   bool
+	manifestPath             string
+	failedManifestPath       string
+	writeChecksums           string
+	asTar                    bool
+	skipContainerCheck       bool
+	sasTokenFile             string
 
 	blobTags string
 	// defines the type of the blob at the destination in case of upload / account to account copy
@@ -125,6 +154,11 @@ type rawCopyCmdArgs struct {
 	preserveOwner          bool // works in conjunction with preserveSmbPermissions
 	// Default true; false indicates that the destination is the target directory, rather than something we'd put a directory under (e.g. a container)
 	asSubdir bool
+	// directory prefix to remove from, and virtual directory prefix to add to, the path of each file at the destination
+	stripPrefix string
+	destPrefix  string
+	// how the names of blobs are normalized from the names of the source files
+	normalizeBlobNames string
 	// Opt-in flag to persist additional SMB properties to Azure Files. Named ...info instead of ...properties
 	// because the latter was similar enough to preserveSMBPermissions to induce user error
 	preserveSMBInfo bool
@@ -166,6 +200,9 @@ type rawCopyCmdArgs struct {
 	// Key is present in AzureKeyVault and Azure KeyVault is linked with storage account.
 	// Provided key name will be fetched from Azure Key Vault and will be used to encrypt the data
 	cpkScopeInfo string
+	// Key (and optionally its hash) to use instead of the ones in the environment variables. Implies cpkInfo
+	cpkEncryptionKey       string
+	cpkEncryptionKeySHA256 string
 
 	// Optional flag that permanently deletes soft-deleted snapshots/versions
 	permanentDeleteOption string
@@ -192,18 +229,31 @@ func (raw *rawCopyCmdArgs) parsePatterns(pattern string) (cookedPatterns []strin
 // 0.25 = 256 KiB
 // 0.015625 = 16 KiB
 func blockSizeInBytes(rawBlockSizeInMiB float64) (int64, error) {
-	if rawBlockSizeInMiB < 0 {
-		return 0, errors.New("negative block size not allowed")
+	return mibToBytes(rawBlockSizeInMiB, "block size", common.MaxBlockBlobBlockSize)
+}
+
+// putBlobSizeInBytes converts the threshold, up to which files are uploaded in a single Put Blob, from MiB to bytes,
+// under the same rules as blockSizeInBytes
+func putBlobSizeInBytes(rawPutBlobSizeInMiB float64) (int64, error) {
+	return mibToBytes(rawPutBlobSizeInMiB, "put blob size", common.MaxPutBlobSize)
+}
+
+func mibToBytes(rawSizeInMiB float64, what string, max int64) (int64, error) {
+	if rawSizeInMiB < 0 {
+		return 0, fmt.Errorf("negative %s not allowed", what)
 	}
-	rawSizeInBytes := rawBlockSizeInMiB * 1024 * 1024 // internally we use bytes, but users' convenience the command line uses MiB
+	rawSizeInBytes := rawSizeInMiB * 1024 * 1024 // internally we use bytes, but users' convenience the command line uses MiB
 	if rawSizeInBytes > math.MaxInt64 {
-		return 0, errors.New("block size too big for int64")
+		return 0, fmt.Errorf("%s too big for int64", what)
+	}
+	if rawSizeInBytes > float64(max) {
+		return 0, fmt.Errorf("%s of %v MiB is larger than the maximum the service allows, which is %d MiB", what, rawSizeInMiB, max/(1024*1024))
 	}
 	const epsilon = 0.001 // arbitrarily using a tolerance of 1000th of a byte
 	_, frac := math.Modf(rawSizeInBytes)
 	isWholeNumber := frac < epsilon || frac > 1.0-epsilon // frac is very close to 0 or 1, so rawSizeInBytes is (very close to) an integer
 	if !isWholeNumber {
-		return 0, fmt.Errorf("while fractional numbers of MiB are allowed as the block size, the fraction must result to a whole number of bytes. %.12f MiB resolves to %.3f bytes", rawBlockSizeInMiB, rawSizeInBytes)
+		return 0, fmt.Errorf("while fractional numbers of MiB are allowed as the %s, the fraction must result to a whole number of bytes. %.12f MiB resolves to %.3f bytes", what, rawSizeInMiB, rawSizeInBytes)
 	}
 	return int64(math.Round(rawSizeInBytes)), nil
 }
@@ -238,9 +288,20 @@ func (raw rawCopyCmdArgs) stripTrailingWildcardOnRemoteSource(location common.Lo
 		stripTopDir = true
 	}
 
+	// Blob names may contain wildcards anywhere. Like a local wildcard, the matches land directly in the destination.
+	if location == common.ELocation.Blob() && blobURLHasWildcard(*resourceURL) {
+		if _, err = parseBlobWildcard(escapedBlobName(*resourceURL)); err != nil {
+			err = fmt.Errorf("invalid wildcard in the blob name; %s", err)
+			return
+		}
+		stripTopDir = true
+		result = resourceURL.String()
+		return
+	}
+
 	// Ensure there aren't any extra *s floating around.
 	if strings.Contains(resourceURL.RawPath, "*") {
-		err = errors.New("cannot use wildcards in the path section of the URL except in trailing \"/*\". If you wish to use * in your URL, manually encode it to %2A")
+		err = errors.New("cannot use wildcards in the path section of the URL except in trailing \"/*\" (or anywhere in the blob name, for Blob sources). If you wish to use * in your URL, manually encode it to %2A")
 		return
 	}
 
@@ -256,7 +317,7 @@ func (raw rawCopyCmdArgs) cook() (CookedCopyCmdArgs, error) {
 
 	err := cooked.LogVerbosity.Parse(raw.logVerbosity)
 	if err != nil {
-		return cooked, err
+		return cooked, fmt.Errorf("invalid log-level %q. The choices are DEBUG, INFO, WARNING, ERROR and NONE", raw.logVerbosity)
 	}
 
 	// set up the front end scanning logger
@@ -322,6 +383,9 @@ func (raw rawCopyCmdArgs) cook() (CookedCopyCmdArgs, error) {
 	if err != nil {
 		return cooked, err
 	}
+	if err = applyOutOfBandSAS(&cooked.Destination, fromTo.To(), raw.sasTokenFile); err != nil {
+		return cooked, err
+	}
 
 	cooked.FromTo = fromTo
 	cooked.Recursive = raw.recursive
@@ -336,6 +400,12 @@ func (raw rawCopyCmdArgs) cook() (CookedCopyCmdArgs, error) {
 	if err != nil {
 		return cooked, err
 	}
+	// nobody is there to answer the prompts, so do the safe thing and overwrite nothing
+	// JSON output is exempt, since that's how Storage Explorer answers the prompts through a pipe
+	if cooked.ForceWrite == common.EOverwriteOption.Prompt() && azcopyOutputFormat != common.EOutputFormat.Json() && !stdinIsTerminal() {
+		glcm.Info("--overwrite=prompt needs a terminal to ask for confirmations, so existing files at the destination will be skipped, as with --overwrite=false.")
+		cooked.ForceWrite = common.EOverwriteOption.False()
+	}
 	allowAutoDecompress := fromTo == common.EFromTo.BlobLocal() || fromTo == common.EFromTo.FileLocal()
 	if raw.autoDecompress && !allowAutoDecompress {
 		return cooked, errors.New("automatic decompression is only supported for downloads from Blob and Azure Files") // as at Sept 2019, our ADLS Gen 2 Swagger does not include content-encoding for directory (path) listings so we can't support it there
@@ -353,6 +423,15 @@ func (raw rawCopyCmdArgs) cook() (CookedCopyCmdArgs, error) {
 	if err != nil {
 		return cooked, err
 	}
+	cooked.putBlobSize, err = putBlobSizeInBytes(raw.putBlobSizeMB)
+	if err != nil {
+		return cooked, err
+	}
+	// the whole file is read into memory to send it in one request, so it has to fit in a single chunk
+	if maxChunkSize := ste.MaxSingleChunkSize(); cooked.putBlobSize > maxChunkSize {
+		return cooked, fmt.Errorf("put-blob-size-mb of %v MiB is larger than the largest file AzCopy can hold in memory to send in one request, which is %d MiB. "+
+			"The limit is 4000 MiB, or three quarters of %s if that's less", raw.putBlobSizeMB, maxChunkSize/(1024*1024), common.EEnvironmentVariable.BufferGB().Name)
+	}
 
 	// parse the given blob type.
 	err = cooked.blobType.Parse(raw.blobType)
@@ -375,6 +454,33 @@ func (raw rawCopyCmdArgs) cook() (CookedCopyCmdArgs, error) {
 		return cooked, err
 	}
 
+	// the archive tier only exists for block blobs, so asking for it on page or append blobs would silently do nothing
+	if cooked.blockBlobTier == common.EBlockBlobTier.Archive() &&
+		(cooked.blobType == common.EBlobType.PageBlob() || cooked.blobType == common.EBlobType.AppendBlob()) {
+		return cooked, fmt.Errorf("block-blob-tier %s cannot be used when blob-type is %s", cooked.blockBlobTier, cooked.blobType)
+	}
+
+	if raw.compress {
+		if cooked.FromTo != common.EFromTo.LocalBlob() {
+			return cooked, errors.New("compression is only supported for uploads to Blob Storage")
+		}
+		if cooked.blobType == common.EBlobType.PageBlob() || cooked.blobType == common.EBlobType.AppendBlob() {
+			return cooked, fmt.Errorf("compression is only supported for block blobs, not for blob-type %s", cooked.blobType)
+		}
+		if raw.contentEncoding != "" {
+			return cooked, errors.New("content-encoding cannot be set when compressing, since it will always be gzip")
+		}
+	}
+	cooked.compress = raw.compress
+
+	if cooked.retryOptions, err = raw.cookRetryOptions(); err != nil {
+		return cooked, err
+	}
+	if raw.transferTimeout < 0 {
+		return cooked, errors.New("transfer-timeout cannot be negative")
+	}
+	cooked.transferTimeout = raw.transferTimeout
+
 	// Everything uses the new implementation of list-of-files now.
 	// This handles both list-of-files and include-path as a list enumerator.
 	// This saves us time because we know *exactly* what we're looking for right off the bat.
@@ -452,6 +558,11 @@ func (raw rawCopyCmdArgs) cook() (CookedCopyCmdArgs, error) {
 					headerLineNum++
 				}
 
+				// lines starting with # are comments, so that a list can say where it came from (e.g. which failed run it repeats)
+				if strings.HasPrefix(v, listOfFilesCommentPrefix) {
+					continue
+				}
+
 				addToChannel(v, "list-of-files")
 			}
 		}
@@ -473,6 +584,7 @@ func (raw rawCopyCmdArgs) cook() (CookedCopyCmdArgs, error) {
 	if raw.listOfFilesToCopy != "" || raw.includePath != "" {
 		cooked.ListOfFilesChannel = listChan
 	}
+	cooked.listOfFilesLocation = raw.listOfFilesToCopy
 
 	if raw.includeBefore != "" {
 		// must set chooseEarliest = false, so that if there's an ambiguous local date, the latest will be returned
@@ -494,6 +606,45 @@ func (raw rawCopyCmdArgs) cook() (CookedCopyCmdArgs, error) {
 		cooked.IncludeAfter = &parsedIncludeAfter
 	}
 
+	// newer-than and older-than narrow the same window as include-after and include-before, so when both
+	// are given, a file must be in both windows
+	if raw.newerThan != "" {
+		newerThan, err := parseAge(raw.newerThan, time.Now(), true)
+		if err != nil {
+			return cooked, fmt.Errorf("invalid newer-than: %w", err)
+		}
+		if cooked.IncludeAfter == nil || newerThan.After(*cooked.IncludeAfter) {
+			cooked.IncludeAfter = &newerThan
+		}
+	}
+	if raw.olderThan != "" {
+		olderThan, err := parseAge(raw.olderThan, time.Now(), false)
+		if err != nil {
+			return cooked, fmt.Errorf("invalid older-than: %w", err)
+		}
+		if cooked.IncludeBefore == nil || olderThan.Before(*cooked.IncludeBefore) {
+			cooked.IncludeBefore = &olderThan
+		}
+	}
+
+	if raw.minSize != "" {
+		minSize, err := parseFilterSize(raw.minSize, "min-size")
+		if err != nil {
+			return cooked, err
+		}
+		cooked.MinSize = &minSize
+	}
+	if raw.maxSize != "" {
+		maxSize, err := parseFilterSize(raw.maxSize, "max-size")
+		if err != nil {
+			return cooked, err
+		}
+		cooked.MaxSize = &maxSize
+	}
+	if cooked.MinSize != nil && cooked.MaxSize != nil && *cooked.MinSize > *cooked.MaxSize {
+		return cooked, errors.New("min-size cannot be larger than max-size")
+	}
+
 	versionsChan := make(chan string)
 	var filePtr *os.File
 	// Get file path from user which would contain list of all versionIDs
@@ -533,6 +684,12 @@ func (raw rawCopyCmdArgs) cook() (CookedCopyCmdArgs, error) {
 		cooked.ListOfVersionIDs = versionsChan
 	}
 
+	if _, err = common.ParseMetadata(raw.metadata); err != nil {
+		return cooked, err
+	}
+	if len(raw.metadata) > ste.MetadataMaxBytes {
+		return cooked, fmt.Errorf("metadata is too long, it may be at most %d characters", ste.MetadataMaxBytes)
+	}
 	cooked.metadata = raw.metadata
 	cooked.contentType = raw.contentType
 	cooked.contentEncoding = raw.contentEncoding
@@ -540,12 +697,22 @@ func (raw rawCopyCmdArgs) cook() (CookedCopyCmdArgs, error) {
 	cooked.contentDisposition = raw.contentDisposition
 	cooked.cacheControl = raw.cacheControl
 	cooked.noGuessMimeType = raw.noGuessMimeType
+	if _, err = common.ParseContentTypeMap(raw.contentTypeMap); err != nil {
+		return cooked, err
+	}
+	if len(raw.contentTypeMap) > ste.ContentTypeMapMaxBytes {
+		return cooked, fmt.Errorf("content-type-map is too long, it may be at most %d characters", ste.ContentTypeMapMaxBytes)
+	}
+	cooked.contentTypeMap = raw.contentTypeMap
 	cooked.preserveLastModifiedTime = raw.preserveLastModifiedTime
 	cooked.disableAutoDecoding = raw.disableAutoDecoding
 
 	if cooked.FromTo.To() != common.ELocation.Blob() && raw.blobTags != "" {
 		return cooked, errors.New("blob tags can only be set when transferring to blob storage")
 	}
+	if err = validateBlobTagsFormat(raw.blobTags); err != nil {
+		return cooked, err
+	}
 	blobTags := common.ToCommonBlobTagsMap(raw.blobTags)
 	err = validateBlobTagsKeyValue(blobTags)
 	if err != nil {
@@ -568,18 +735,19 @@ func (raw rawCopyCmdArgs) cook() (CookedCopyCmdArgs, error) {
 
 	// Setting CPK-N
 	cpkOptions := common.CpkOptions{}
+	// Setting CPK-V
+	// The key (EncryptionKey and EncryptionKeySHA256) comes from the command line, or else from environment variables.
+	if cpkOptions.CpkInfo, err = cookCpkByValue(raw.cpkInfo, raw.cpkEncryptionKey, raw.cpkEncryptionKeySHA256); err != nil {
+		return cooked, err
+	}
 	// Setting CPK-N
 	if raw.cpkScopeInfo != "" {
-		if raw.cpkInfo {
+		if cpkOptions.CpkInfo {
 			return cooked, errors.New("cannot use both cpk-by-name and cpk-by-value at the same time")
 		}
 		cpkOptions.CpkScopeInfo = raw.cpkScopeInfo
 	}
 
-	// Setting CPK-V
-	// Get the key (EncryptionKey and EncryptionKeySHA256) value from environment variables when required.
-	cpkOptions.CpkInfo = raw.cpkInfo
-
 	if cpkOptions.CpkScopeInfo != "" || cpkOptions.CpkInfo {
 		// We only support transfer from source encrypted by user key when user wishes to download.
 		// Due to service limitation, S2S transfer is not supported for source encrypted by user key.
@@ -608,12 +776,27 @@ func (raw rawCopyCmdArgs) cook() (CookedCopyCmdArgs, error) {
 	if err != nil {
 		return cooked, err
 	}
+	// for remove, the destination is Unknown (i.e. the trash)
+	if cooked.deleteSnapshotsOption != common.EDeleteSnapshotsOption.None() && fromTo.To() != common.ELocation.Blob() && fromTo.To() != common.ELocation.Unknown() {
+		return cooked, errors.New("--delete-snapshots only applies when the destination is Blob storage")
+	}
 
 	if cooked.contentType != "" {
 		cooked.noGuessMimeType = true // As specified in the help text, noGuessMimeType is inferred here.
 	}
 
 	cooked.putMd5 = raw.putMd5
+	cooked.ifNoneMatch = raw.ifNoneMatch
+	cooked.validateBlocks = raw.validateBlocks
+	cooked.snapshotBeforeOverwrite = raw.snapshotBeforeOverwrite
+	cooked.serverSideCopy = raw.serverSideCopy
+	switch strings.ToLower(raw.serverSideCopyProperties) {
+	case "", "preserve":
+	case "replace":
+		cooked.replaceProperties = true
+	default:
+		return cooked, fmt.Errorf("invalid server-side-copy-properties %q. The choices are preserve and replace", raw.serverSideCopyProperties)
+	}
 	err = cooked.md5ValidationOption.Parse(raw.md5ValidationOption)
 	if err != nil {
 		return cooked, err
@@ -631,7 +814,8 @@ func (raw rawCopyCmdArgs) cook() (CookedCopyCmdArgs, error) {
 		glcm.SetOutputFormat(common.EOutputFormat.None())
 	}
 
-	cooked.preserveSMBInfo = areBothLocationsSMBAware(cooked.FromTo)
+	isUserPersistingPermissions := raw.preservePermissions || raw.preserveSMBPermissions
+	cooked.preserveSMBInfo = areBothLocationsSMBAware(cooked.FromTo) || areSMBPropertiesMappedFromPOSIX(cooked.FromTo, isUserPersistingPermissions)
 	// If user has explicitly specified not to copy SMB Information, set cooked.preserveSMBInfo to false
 	if !raw.preserveSMBInfo {
 		cooked.preserveSMBInfo = false
@@ -641,7 +825,6 @@ func (raw rawCopyCmdArgs) cook() (CookedCopyCmdArgs, error) {
 		return cooked, err
 	}
 
-	isUserPersistingPermissions := raw.preservePermissions || raw.preserveSMBPermissions
 	if cooked.preserveSMBInfo && !isUserPersistingPermissions {
 		glcm.Info("Please note: the preserve-permissions flag is set to false, thus AzCopy will not copy SMB ACLs between the source and destination. To learn more: https://aka.ms/AzCopyandAzureFiles.")
 	}
@@ -660,6 +843,20 @@ func (raw rawCopyCmdArgs) cook() (CookedCopyCmdArgs, error) {
 	// --as-subdir is OK on all sources and destinations, but additional verification has to be done down the line. (e.g. https://account.blob.core.windows.net is not a valid root)
 	cooked.asSubdir = raw.asSubdir
 
+	if cooked.stripPrefix, err = cookPathPrefix("strip-prefix", raw.stripPrefix); err != nil {
+		return cooked, err
+	}
+	if cooked.destPrefix, err = cookPathPrefix("dest-prefix", raw.destPrefix); err != nil {
+		return cooked, err
+	}
+
+	if err = cooked.blobNameNormalization.Parse(raw.normalizeBlobNames); err != nil {
+		return cooked, fmt.Errorf("invalid --normalize-blob-names value %q. Possible values are 'none', 'nfc' and 'lowercase'", raw.normalizeBlobNames)
+	}
+	if cooked.blobNameNormalization != common.EBlobNameNormalization.None() && cooked.FromTo.To() != common.ELocation.Blob() {
+		return cooked, errors.New("--normalize-blob-names only applies when the destination is Blob storage")
+	}
+
 	cooked.IncludeDirectoryStubs = raw.includeDirectoryStubs || (cooked.isHNStoHNS && cooked.preservePermissions.IsTruthy())
 
 	if err = crossValidateSymlinksAndPermissions(cooked.FollowSymlinks, cooked.preservePermissions.IsTruthy()); err != nil {
@@ -678,7 +875,7 @@ func (raw rawCopyCmdArgs) cook() (CookedCopyCmdArgs, error) {
 	}
 
 	// check for the flag value relative to fromTo location type
-	// Example1: for Local to Blob, preserve-last-modified-time flag should not be set to true
+	// Example1: for Local to ADLS Gen 2, blob-type and blob-tier flags should not be provided with values.
 	// Example2: for Blob to Local, follow-symlinks, blob-tier flags should not be provided with values.
 	switch cooked.FromTo {
 	case common.EFromTo.LocalBlobFS():
@@ -708,9 +905,6 @@ func (raw rawCopyCmdArgs) cook() (CookedCopyCmdArgs, error) {
 			return cooked, fmt.Errorf("s2s-detect-source-changed is not supported while uploading")
 		}
 	case common.EFromTo.LocalBlob():
-		if cooked.preserveLastModifiedTime {
-			return cooked, fmt.Errorf("preserve-last-modified-time is not supported while uploading to Blob Storage")
-		}
 		if cooked.s2sPreserveProperties {
 			return cooked, fmt.Errorf("s2s-preserve-properties is not supported while uploading to Blob Storage")
 		}
@@ -759,6 +953,9 @@ func (raw rawCopyCmdArgs) cook() (CookedCopyCmdArgs, error) {
 		if cooked.noGuessMimeType {
 			return cooked, fmt.Errorf("no-guess-mime-type is not supported while downloading")
 		}
+		if cooked.contentTypeMap != "" {
+			return cooked, fmt.Errorf("content-type-map is not supported while downloading")
+		}
 		if len(cooked.contentType) > 0 || len(cooked.contentEncoding) > 0 || len(cooked.contentLanguage) > 0 || len(cooked.contentDisposition) > 0 || len(cooked.cacheControl) > 0 || len(cooked.metadata) > 0 {
 			return cooked, fmt.Errorf("content-type, content-encoding, content-language, content-disposition, cache-control, or metadata is not supported while downloading")
 		}
@@ -796,11 +993,17 @@ func (raw rawCopyCmdArgs) cook() (CookedCopyCmdArgs, error) {
 			cooked.FromTo.To() != common.ELocation.Blob() {
 			return cooked, fmt.Errorf("blob-tier is not supported for the scenario (%s)", cooked.FromTo.String())
 		}
-		if cooked.noGuessMimeType {
+		if cooked.noGuessMimeType && !cooked.replaceProperties {
 			return cooked, fmt.Errorf("no-guess-mime-type is not supported while copying from service to service")
 		}
-		if len(cooked.contentType) > 0 || len(cooked.contentEncoding) > 0 || len(cooked.contentLanguage) > 0 || len(cooked.contentDisposition) > 0 || len(cooked.cacheControl) > 0 || len(cooked.metadata) > 0 {
-			return cooked, fmt.Errorf("content-type, content-encoding, content-language, content-disposition, cache-control, or metadata is not supported while copying from service to service")
+		if cooked.contentTypeMap != "" {
+			return cooked, fmt.Errorf("content-type-map is not supported while copying from service to service")
+		}
+		// the headers and metadata can only be given when a server-side copy is replacing the source's
+		if (len(cooked.contentType) > 0 || len(cooked.contentEncoding) > 0 || len(cooked.contentLanguage) > 0 || len(cooked.contentDisposition) > 0 || len(cooked.cacheControl) > 0 || len(cooked.metadata) > 0) &&
+			!cooked.replaceProperties {
+			return cooked, fmt.Errorf("content-type, content-encoding, content-language, content-disposition, cache-control, or metadata is not supported while copying from service to service, " +
+				"except with --server-side-copy-properties=replace")
 		}
 	}
 	if err = validatePutMd5(cooked.putMd5, cooked.FromTo); err != nil {
@@ -809,6 +1012,45 @@ func (raw rawCopyCmdArgs) cook() (CookedCopyCmdArgs, error) {
 	if err = validateMd5Option(cooked.md5ValidationOption, cooked.FromTo); err != nil {
 		return cooked, err
 	}
+	if cooked.ifNoneMatch {
+		if cooked.FromTo.To() != common.ELocation.Blob() {
+			return cooked, fmt.Errorf("if-none-match is only supported when the destination is Blob storage")
+		}
+		if cooked.blobType == common.EBlobType.PageBlob() || cooked.blobType == common.EBlobType.AppendBlob() {
+			return cooked, fmt.Errorf("if-none-match only applies to block blobs, not to blob-type %s", cooked.blobType)
+		}
+	}
+	if cooked.validateBlocks && cooked.FromTo != common.EFromTo.LocalBlob() {
+		return cooked, fmt.Errorf("validate-blocks is only supported when uploading to Blob storage")
+	}
+	if cooked.putBlobSize != 0 {
+		if cooked.FromTo != common.EFromTo.LocalBlob() {
+			return cooked, fmt.Errorf("put-blob-size-mb is only supported when uploading to Blob storage")
+		}
+		if cooked.blobType == common.EBlobType.PageBlob() || cooked.blobType == common.EBlobType.AppendBlob() {
+			return cooked, fmt.Errorf("put-blob-size-mb only applies to block blobs")
+		}
+	}
+	if cooked.snapshotBeforeOverwrite {
+		if cooked.FromTo.To() != common.ELocation.Blob() {
+			return cooked, fmt.Errorf("snapshot-before-overwrite is only supported when the destination is Blob storage")
+		}
+		if cooked.ifNoneMatch {
+			return cooked, fmt.Errorf("snapshot-before-overwrite cannot be combined with if-none-match, which never overwrites blobs")
+		}
+		if cooked.deleteSnapshotsOption != common.EDeleteSnapshotsOption.None() {
+			return cooked, fmt.Errorf("snapshot-before-overwrite cannot be combined with delete-snapshots=%s, which would delete the snapshot it takes", raw.deleteSnapshotsOption)
+		}
+	}
+	if err = validateServerSideCopy(cooked.serverSideCopy, cooked.FromTo, cooked.ForceWrite, cooked.snapshotBeforeOverwrite); err != nil {
+		return cooked, err
+	}
+	if cooked.replaceProperties {
+		if !cooked.serverSideCopy {
+			return cooked, fmt.Errorf("server-side-copy-properties=replace needs --server-side-copy")
+		}
+		cooked.noGuessMimeType = true // headers that weren't given are kept from the source, rather than guessed
+	}
 
 	// Because of some of our defaults, these must live down here and can't be properly checked.
 	// TODO: Remove the above checks where they can't be done.
@@ -852,9 +1094,58 @@ func (raw rawCopyCmdArgs) cook() (CookedCopyCmdArgs, error) {
 
 	cooked.dryrunMode = raw.dryrun
 
+	cooked.manifestPath = raw.manifestPath
+	if cooked.manifestPath != "" && cooked.dryrunMode {
+		return cooked, errors.New("manifest cannot be used with dry-run, since a dry run does not start a job")
+	}
+	cooked.skipContainerCheck = raw.skipContainerCheck
+	cooked.failedManifestPath = raw.failedManifestPath
+	if cooked.failedManifestPath != "" && cooked.dryrunMode {
+		return cooked, errors.New("failed-manifest cannot be used with dry-run, since a dry run does not start a job")
+	}
+
+	cooked.asTar = raw.asTar
+	if err = validateAsTar(raw, cooked); err != nil {
+		return cooked, err
+	}
+	if cooked.checksumFile, err = cookChecksumFile(raw.writeChecksums, cooked); err != nil {
+		return cooked, err
+	}
+
 	return cooked, nil
 }
 
+// cookChecksumFile checks that --write-checksums can be used with the rest of the command, and gives the absolute path
+// of the file, since that's what the STE records. The file is created, if it doesn't exist, so that a path that can't be
+// written to is reported now rather than after all the downloads
+func cookChecksumFile(path string, cooked CookedCopyCmdArgs) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	if !cooked.FromTo.IsDownload() {
+		return "", errors.New("write-checksums is only supported for downloads")
+	}
+	if cooked.autoDecompress {
+		return "", errors.New("write-checksums cannot be used with decompress, since the hashes would be of the data before it was decompressed")
+	}
+	if cooked.dryrunMode {
+		return "", errors.New("write-checksums cannot be used with dry-run, since a dry run does not start a job")
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if len(path) > ste.ChecksumFileMaxBytes {
+		return "", fmt.Errorf("the path of the write-checksums file is too long, it may be at most %d characters", ste.ChecksumFileMaxBytes)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, common.DEFAULT_FILE_PERM)
+	if err != nil {
+		return "", fmt.Errorf("cannot write the checksum file: %w", err)
+	}
+	return path, f.Close()
+}
+
 var excludeWarningOncer = &sync.Once{}
 var includeWarningOncer = &sync.Once{}
 
@@ -906,6 +1197,12 @@ func areBothLocationsSMBAware(fromTo common.FromTo) bool {
 	}
 }
 
+// areSMBPropertiesMappedFromPOSIX tells whether the SMB properties of an upload to Azure Files will be mapped from
+// POSIX permissions, which is done for non-Windows sources when the user asks for permissions to be preserved
+func areSMBPropertiesMappedFromPOSIX(fromTo common.FromTo, isUserPersistingPermissions bool) bool {
+	return runtime.GOOS != "windows" && fromTo == common.EFromTo.LocalFile() && isUserPersistingPermissions
+}
+
 func validatePreserveSMBPropertyOption(toPreserve bool, fromTo common.FromTo, overwrite *common.OverwriteOption, flagName string) error {
 	if toPreserve && !(fromTo == common.EFromTo.LocalFile() ||
 		fromTo == common.EFromTo.FileLocal() ||
@@ -914,8 +1211,9 @@ func validatePreserveSMBPropertyOption(toPreserve bool, fromTo common.FromTo, ov
 		return fmt.Errorf("%s is set but the job is not between %s-aware resources", flagName, common.IffString(flagName == PreservePermissionsFlag, "permission", "SMB"))
 	}
 
-	if toPreserve && (fromTo.IsUpload() || fromTo.IsDownload()) && runtime.GOOS != "windows" {
-		return fmt.Errorf("%s is set but persistence for up/downloads is a Windows-only feature", flagName)
+	// on other platforms, uploads map the POSIX permissions to SMB ones, but downloads can't map them back
+	if toPreserve && fromTo.IsDownload() && runtime.GOOS != "windows" {
+		return fmt.Errorf("%s is set but persistence for downloads is a Windows-only feature", flagName)
 	}
 
 	return nil
@@ -964,6 +1262,25 @@ func validatePutMd5(putMd5 bool, fromTo common.FromTo) error {
 	return nil
 }
 
+// validateServerSideCopy checks that --server-side-copy is only used where the service can make the copy by itself.
+// Copy Blob has no way to ask the user, or to compare timestamps, before it overwrites a blob, so only the
+// unconditional overwrite options are allowed.
+func validateServerSideCopy(serverSideCopy bool, fromTo common.FromTo, overwrite common.OverwriteOption, snapshotBeforeOverwrite bool) error {
+	if !serverSideCopy {
+		return nil
+	}
+	if fromTo != common.EFromTo.BlobBlob() {
+		return fmt.Errorf("server-side-copy is only supported when copying from Blob storage to Blob storage")
+	}
+	if overwrite != common.EOverwriteOption.True() && overwrite != common.EOverwriteOption.False() {
+		return fmt.Errorf("server-side-copy only supports --overwrite=true or --overwrite=false")
+	}
+	if snapshotBeforeOverwrite {
+		return fmt.Errorf("server-side-copy cannot be combined with snapshot-before-overwrite")
+	}
+	return nil
+}
+
 func validateMd5Option(option common.HashValidationOption, fromTo common.FromTo) error {
 	hasMd5Validation := option != common.DefaultHashValidationOption
 	if hasMd5Validation && !fromTo.IsDownload() {
@@ -987,6 +1304,37 @@ func isValidBlobTagsKeyValue(keyVal string) bool {
 	return true
 }
 
+// cookPathPrefix normalizes the value of --strip-prefix or --dest-prefix to a path with "/" separators and no leading or
+// trailing separator, so that it matches (and makes) blob names the same way on every OS
+func cookPathPrefix(flagName string, raw string) (string, error) {
+	prefix := strings.Trim(strings.ReplaceAll(raw, `\`, common.AZCOPY_PATH_SEPARATOR_STRING), common.AZCOPY_PATH_SEPARATOR_STRING)
+	for _, segment := range strings.Split(prefix, common.AZCOPY_PATH_SEPARATOR_STRING) {
+		if prefix != "" && (segment == "" || segment == "." || segment == "..") {
+			return "", fmt.Errorf("--%s '%s' is not valid: each directory in the path must have a name, other than '.' or '..'", flagName, raw)
+		}
+	}
+	return prefix, nil
+}
+
+// validateBlobTagsFormat checks that the tags, as given to --blob-tags, are &-separated key=value pairs, with no key given twice
+func validateBlobTagsFormat(blobTags string) error {
+	if blobTags == "" {
+		return nil
+	}
+	seen := make(map[string]bool)
+	for _, keyAndValue := range strings.Split(blobTags, "&") {
+		if !strings.Contains(keyAndValue, "=") {
+			return fmt.Errorf("blob tag %q must be given as key=value", keyAndValue)
+		}
+		key := strings.SplitN(keyAndValue, "=", 2)[0]
+		if seen[key] {
+			return fmt.Errorf("blob tag key %q is given more than once", key)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
 // ValidateBlobTagsKeyValue
 // The tag set may contain at most 10 tags. Tag keys and values are case sensitive.
 // Tag keys must be between 1 and 128 characters, and tag values must be between 0 and 256 characters.
@@ -1019,6 +1367,34 @@ func validateBlobTagsKeyValue(bt common.BlobTags) error {
 	return nil
 }
 
+// cookRetryOptions validates the retry flags, as they will be combined with the defaults for any that aren't given
+func (raw rawCopyCmdArgs) cookRetryOptions() (common.RetryOptions, error) {
+	o := common.RetryOptions{
+		MaxTries:      raw.maxTries,
+		TryTimeout:    raw.tryTimeout,
+		RetryDelay:    raw.retryDelay,
+		MaxRetryDelay: raw.maxRetryDelay,
+	}
+	if o.MaxTries < 0 {
+		return o, errors.New("max-tries must be at least 1")
+	}
+	if o.TryTimeout < 0 || o.RetryDelay < 0 || o.MaxRetryDelay < 0 {
+		return o, errors.New("try-timeout, retry-delay and max-retry-delay cannot be negative")
+	}
+
+	retryDelay, maxRetryDelay := ste.UploadRetryDelay, ste.UploadMaxRetryDelay
+	if o.RetryDelay != 0 {
+		retryDelay = o.RetryDelay
+	}
+	if o.MaxRetryDelay != 0 {
+		maxRetryDelay = o.MaxRetryDelay
+	}
+	if maxRetryDelay < retryDelay {
+		return o, fmt.Errorf("max-retry-delay (%v) cannot be less than retry-delay (%v)", maxRetryDelay, retryDelay)
+	}
+	return o, nil
+}
+
 // represents the processed copy command input from the user
 type CookedCopyCmdArgs struct {
 	// from arguments
@@ -1037,6 +1413,8 @@ type CookedCopyCmdArgs struct {
 	ExcludeFileAttributes []string
 	IncludeBefore         *time.Time
 	IncludeAfter          *time.Time
+	MinSize               *int64
+	MaxSize               *int64
 
 	// include/exclude filters with regular expression (also for sync)
 	includeRegex []string
@@ -1052,9 +1430,17 @@ type CookedCopyCmdArgs struct {
 	ForceWrite         common.OverwriteOption // says whether we should try to overwrite
 	ForceIfReadOnly    bool                   // says whether we should _force_ any overwrites (triggered by forceWrite) to work on Azure Files objects that are set to read-only
 	autoDecompress     bool
+	compress           bool
+	retryOptions       common.RetryOptions
+	transferTimeout    time.Duration
+
+	// the file given with --list-of-files, if it was given (ListOfFilesChannel is also used for include-path)
+	listOfFilesLocation string
 
 	// options from flags
 	blockSize int64
+	// files up to this size are uploaded with a single Put Blob. Zero means up to the block size
+	putBlobSize int64
 	// list of blobTypes to exclude while enumerating the transfer
 	excludeBlobType []azblob.BlobType
 	blobType        common.BlobType
@@ -1070,9 +1456,15 @@ type CookedCopyCmdArgs struct {
 	contentDisposition       string
 	cacheControl             string
 	noGuessMimeType          bool
+	contentTypeMap           string
 	preserveLastModifiedTime bool
 	deleteSnapshotsOption    common.DeleteSnapshotsOption
 	putMd5                   bool
+	ifNoneMatch              bool
+	validateBlocks           bool
+	snapshotBeforeOverwrite  bool
+	serverSideCopy           bool
+	replaceProperties        bool
 	md5ValidationOption      common.HashValidationOption
 	CheckLength              bool
 	LogVerbosity             common.LogLevel
@@ -1110,6 +1502,15 @@ type CookedCopyCmdArgs struct {
 	// Whether to rename/share the root
 	asSubdir bool
 
+	// stripPrefix is removed from the start of each source-relative path, and destPrefix added in front of it, at the destination.
+	// Both are cooked by cookPathPrefix
+	stripPrefix string
+	destPrefix  string
+
+	// blobNameNormalization is applied to the path of each file, relative to the source, to name its blob.
+	// Two files whose names normalize to the same blob name fail the enumeration, rather than overwrite each other.
+	blobNameNormalization common.BlobNameNormalization
+
 	// whether user wants to preserve full properties during service to service copy, the default value is true.
 	// For S3 and Azure File non-single file source, as list operation doesn't return full properties of objects/files,
 	// to preserve full properties AzCopy needs to send one additional request per object/file.
@@ -1144,6 +1545,26 @@ type CookedCopyCmdArgs struct {
 
 	// specify if dry run mode on
 	dryrunMode bool
+	// tallies of what a dry run would have transferred, reported once enumeration completes
+	dryrunFileCount  uint32
+	dryrunTotalBytes uint64
+
+	// if set, a JSON list of the job's transfers is written to this path when the job ends
+	manifestPath string
+
+	// if set, the sources of the job's failed transfers are written to this path, in the --list-of-files format, when the job ends.
+	// The summaries only carry the failures that are new since the last one, so they are gathered in failedTransfers as the job runs
+	failedManifestPath string
+	failedTransfers    []common.TransferDetail
+
+	// if set, a line in the format of md5sum is appended to this file for each downloaded file
+	checksumFile string
+
+	// if set, the source directory is uploaded as a single tar archive, to the destination blob
+	asTar bool
+
+	// if set, the upfront check that the destination container exists, and can be written to, is not done
+	skipContainerCheck bool
 
 	CpkOptions common.CpkOptions
 
@@ -1162,6 +1583,27 @@ func (cca *CookedCopyCmdArgs) isRedirection() bool {
 	}
 }
 
+// dryrunSummary reports how much a dry run would have transferred. Whether each file would then be skipped
+// because of the overwrite policy is only known once the destination is checked, so that is reported as a caveat.
+func (cca *CookedCopyCmdArgs) dryrunSummary(format common.OutputFormat) string {
+	if format == common.EOutputFormat.Json() {
+		jsonOutput, err := json.Marshal(struct {
+			FileCount  uint32 `json:",string"`
+			TotalBytes uint64 `json:",string"`
+			Overwrite  string
+		}{cca.dryrunFileCount, cca.dryrunTotalBytes, cca.ForceWrite.String()})
+		common.PanicIfErr(err)
+		return string(jsonOutput)
+	}
+
+	summary := fmt.Sprintf("DRYRUN: %d file(s) totalling %s (%d bytes) would be copied",
+		cca.dryrunFileCount, byteSizeToString(int64(cca.dryrunTotalBytes)), cca.dryrunTotalBytes)
+	if cca.ForceWrite != common.EOverwriteOption.True() {
+		summary += fmt.Sprintf(". Files that already exist at the destination may be skipped, since --overwrite=%s", strings.ToLower(cca.ForceWrite.String()))
+	}
+	return summary
+}
+
 func (cca *CookedCopyCmdArgs) process() error {
 
 	err := common.SetBackupMode(cca.backupMode, cca.FromTo)
@@ -1169,6 +1611,13 @@ func (cca *CookedCopyCmdArgs) process() error {
 		return err
 	}
 
+	if cca.asTar {
+		if err := cca.processTarUpload(); err != nil {
+			return err
+		}
+		glcm.Exit(nil, common.EExitCode.Success())
+	}
+
 	if cca.isRedirection() {
 		err := cca.processRedirectionCopy()
 
@@ -1185,7 +1634,7 @@ func (cca *CookedCopyCmdArgs) process() error {
 // TODO discuss with Jeff what features should be supported by redirection, such as metadata, content-type, etc.
 func (cca *CookedCopyCmdArgs) processRedirectionCopy() error {
 	if cca.FromTo == common.EFromTo.PipeBlob() {
-		return cca.processRedirectionUpload(cca.Destination, cca.blockSize)
+		return cca.processRedirectionUpload(cca.Destination, cca.blockSize, os.Stdin)
 	} else if cca.FromTo == common.EFromTo.BlobPipe() {
 		return cca.processRedirectionDownload(cca.Source)
 	}
@@ -1247,7 +1696,7 @@ func (cca *CookedCopyCmdArgs) processRedirectionDownload(blobResource common.Res
 	return nil
 }
 
-func (cca *CookedCopyCmdArgs) processRedirectionUpload(blobResource common.ResourceString, blockSize int64) error {
+func (cca *CookedCopyCmdArgs) processRedirectionUpload(blobResource common.ResourceString, blockSize int64, src io.Reader) error {
 	ctx := context.WithValue(context.TODO(), ste.ServiceAPIVersionOverride, ste.DefaultServiceApiVersion)
 
 	// if no block size is set, then use default value
@@ -1274,36 +1723,37 @@ func (cca *CookedCopyCmdArgs) processRedirectionUpload(blobResource common.Resou
 		return fmt.Errorf("fatal: cannot parse destination blob URL due to error: %s", err.Error())
 	}
 
-	// step 2: leverage high-level call in Blob SDK to upload stdin in parallel
+	// step 2: stage the stream in blocks, in parallel. Unlike the high-level call in the Blob SDK, this grows the block size
+	// as the upload goes on, so that a long stream doesn't run out of blocks
 	blockBlobUrl := azblob.NewBlockBlobURL(*u, p)
-	metadataString := cca.metadata
-	metadataMap := common.Metadata{}
-	if len(metadataString) > 0 {
-		for _, keyAndValue := range strings.Split(metadataString, ";") { // key/value pairs are separated by ';'
-			kv := strings.Split(keyAndValue, "=") // key/value are separated by '='
-			metadataMap[kv[0]] = kv[1]
-		}
+	metadataMap, err := common.ParseMetadata(cca.metadata)
+	if err != nil {
+		return err
 	}
 	blobTags := cca.blobTags
 	bbAccessTier := azblob.DefaultAccessTier
 	if cca.blockBlobTier != common.EBlockBlobTier.None() {
 		bbAccessTier = azblob.AccessTierType(cca.blockBlobTier.String())
 	}
-	_, err = azblob.UploadStreamToBlockBlob(ctx, os.Stdin, blockBlobUrl, azblob.UploadStreamToBlockBlobOptions{
-		BufferSize:  int(blockSize),
-		MaxBuffers:  pipingUploadParallelism,
-		Metadata:    metadataMap.ToAzBlobMetadata(),
-		BlobTagsMap: blobTags.ToAzBlobTagsMap(),
-		BlobHTTPHeaders: azblob.BlobHTTPHeaders{
+	cpk := common.GetClientProvidedKey(cca.CpkOptions)
+	blockIDs, err := stageStreamInBlocks(ctx, src, blockSize, pipingUploadParallelism, ste.ChunkMemoryLimiter(), func(ctx context.Context, blockID string, block []byte) error {
+		_, err := blockBlobUrl.StageBlock(ctx, blockID, bytes.NewReader(block), azblob.LeaseAccessConditions{}, nil, cpk)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// step 3: commit the staged blocks
+	_, err = blockBlobUrl.CommitBlockList(ctx, blockIDs,
+		azblob.BlobHTTPHeaders{
 			ContentType:        cca.contentType,
 			ContentLanguage:    cca.contentLanguage,
 			ContentEncoding:    cca.contentEncoding,
 			ContentDisposition: cca.contentDisposition,
 			CacheControl:       cca.cacheControl,
 		},
-		BlobAccessTier:           bbAccessTier,
-		ClientProvidedKeyOptions: common.GetClientProvidedKey(cca.CpkOptions),
-	})
+		metadataMap.ToAzBlobMetadata(), azblob.BlobAccessConditions{}, bbAccessTier, blobTags.ToAzBlobTagsMap(), cpk)
 
 	return err
 }
@@ -1317,6 +1767,9 @@ func (cca *CookedCopyCmdArgs) processCopyJobPartOrders() (err error) {
 		ste.JobsAdmin.SetConcurrencySettingsToAuto()
 	}
 
+	// check the SAS tokens against the local clock before anything is sent with them
+	warnIfSASIsNotValidNow(cca.Source, cca.Destination)
+
 	// Note: credential info here is only used by remove at the moment.
 	// TODO: Get the entirety of remove into the new copyEnumeratorInit script so we can remove this
 	//       and stop having two places in copy that we get credential info
@@ -1354,12 +1807,16 @@ func (cca *CookedCopyCmdArgs) processCopyJobPartOrders() (err error) {
 		ForceWrite:      cca.ForceWrite,
 		ForceIfReadOnly: cca.ForceIfReadOnly,
 		AutoDecompress:  cca.autoDecompress,
+		Compress:        cca.compress,
+		RetryOptions:    cca.retryOptions,
+		TransferTimeout: cca.transferTimeout,
 		Priority:        common.EJobPriority.Normal(),
 		LogLevel:        cca.LogVerbosity,
 		ExcludeBlobType: cca.excludeBlobType,
 		BlobAttributes: common.BlobTransferAttributes{
 			BlobType:                 cca.blobType,
 			BlockSizeInBytes:         cca.blockSize,
+			PutBlobSizeInBytes:       cca.putBlobSize,
 			ContentType:              cca.contentType,
 			ContentEncoding:          cca.contentEncoding,
 			ContentLanguage:          cca.contentLanguage,
@@ -1369,9 +1826,17 @@ func (cca *CookedCopyCmdArgs) processCopyJobPartOrders() (err error) {
 			PageBlobTier:             cca.pageBlobTier,
 			Metadata:                 cca.metadata,
 			NoGuessMimeType:          cca.noGuessMimeType,
+			ContentTypeMap:           cca.contentTypeMap,
 			PreserveLastModifiedTime: cca.preserveLastModifiedTime,
+			LastModifiedInMetadata:   cca.preserveLastModifiedTime,
 			PutMd5:                   cca.putMd5,
+			IfNoneMatch:              cca.ifNoneMatch,
+			ValidateBlocks:           cca.validateBlocks,
+			SnapshotBeforeOverwrite:  cca.snapshotBeforeOverwrite,
+			ServerSideCopy:           cca.serverSideCopy,
+			ReplaceProperties:        cca.replaceProperties,
 			MD5ValidationOption:      cca.md5ValidationOption,
+			ChecksumFile:             cca.checksumFile,
 			DeleteSnapshotsOption:    cca.deleteSnapshotsOption,
 			// Setting tags when tags explicitly provided by the user through blob-tags flag
 			BlobTagsString: cca.blobTags.ToString(),
@@ -1513,6 +1978,22 @@ func (cca *CookedCopyCmdArgs) Cancel(lcm common.LifecycleMgr) {
 	}
 }
 
+// Pause stops the job so that it can be resumed later, e.g. when azcopy is asked to terminate.
+// A job whose enumeration is incomplete can't be resumed, so it is cancelled instead.
+func (cca *CookedCopyCmdArgs) Pause(lcm common.LifecycleMgr) {
+	if !cca.isEnumerationComplete {
+		lcm.Info("The source enumeration is not complete, so the job cannot be resumed later. Cancelling it instead.")
+		if err := (cookedCancelCmdArgs{jobID: cca.jobID}).process(); err != nil {
+			lcm.Error("error occurred while cancelling the job " + cca.jobID.String() + ": " + err.Error())
+		}
+		return
+	}
+
+	if err := pauseJob(cca.jobID); err != nil {
+		lcm.Error("error occurred while pausing the job " + cca.jobID.String() + ": " + err.Error())
+	}
+}
+
 func (cca *CookedCopyCmdArgs) hasFollowup() bool {
 	return cca.followupJobArgs != nil
 }
@@ -1540,6 +2021,21 @@ func (cca *CookedCopyCmdArgs) getSuccessExitCode() common.ExitCode {
 	}
 }
 
+// jobExitCode maps the final summary of a job to the exit code that tells scripts how it went.
+// successCode is used when nothing failed
+func jobExitCode(summary common.ListJobSummaryResponse, successCode common.ExitCode) common.ExitCode {
+	switch {
+	case summary.JobStatus == common.EJobStatus.Cancelled() || summary.JobStatus == common.EJobStatus.Cancelling():
+		return common.EExitCode.Cancelled()
+	case summary.TransfersFailed > 0 && summary.TransfersCompleted == 0:
+		return common.EExitCode.AllFailed()
+	case summary.TransfersFailed > 0:
+		return common.EExitCode.Error()
+	default:
+		return successCode
+	}
+}
+
 func (cca *CookedCopyCmdArgs) ReportProgressOrExit(lcm common.LifecycleMgr) (totalKnownCount uint32) {
 	// fetch a job status
 	var summary common.ListJobSummaryResponse
@@ -1550,16 +2046,29 @@ func (cca *CookedCopyCmdArgs) ReportProgressOrExit(lcm common.LifecycleMgr) (tot
 	summary.IsCleanupJob = cca.isCleanupJob // only FE knows this, so we can only set it here
 	cleanupStatusString := fmt.Sprintf("Cleanup %v/%v", summary.TransfersCompleted, summary.TotalTransfers)
 
-	jobDone := summary.JobStatus.IsJobDone()
+	jobDone := isJobDoneOrPaused(summary.JobStatus)
 	totalKnownCount = summary.TotalTransfers
+	if cca.failedManifestPath != "" {
+		cca.failedTransfers = append(cca.failedTransfers, summary.FailedTransfers...)
+	}
 
 	// if json is not desired, and job is done, then we generate a special end message to conclude the job
 	duration := time.Now().Sub(cca.jobStartTime) // report the total run time of the job
 
 	if jobDone {
-		exitCode := cca.getSuccessExitCode()
-		if summary.TransfersFailed > 0 {
-			exitCode = common.EExitCode.Error()
+		exitCode := jobExitCode(summary, cca.getSuccessExitCode())
+
+		if cca.manifestPath != "" {
+			if err := writeCopyManifest(cca.jobID, summary.JobStatus, cca.manifestPath); err != nil {
+				lcm.Info(fmt.Sprintf("Failed to write the manifest to %s: %s", cca.manifestPath, err))
+				exitCode = common.EExitCode.Error()
+			}
+		}
+		if cca.failedManifestPath != "" {
+			if err := writeFailedManifest(cca.jobID, cca.Source, cca.FromTo.From(), cca.failedTransfers, cca.failedManifestPath); err != nil {
+				lcm.Info(fmt.Sprintf("Failed to write the failed-transfer manifest to %s: %s", cca.failedManifestPath, err))
+				exitCode = common.EExitCode.Error()
+			}
 		}
 
 		builder := func(format common.OutputFormat) string {
@@ -1568,7 +2077,7 @@ func (cca *CookedCopyCmdArgs) ReportProgressOrExit(lcm common.LifecycleMgr) (tot
 				common.PanicIfErr(err)
 				return string(jsonOutput)
 			} else {
-				screenStats, logStats := formatExtraStats(cca.FromTo, summary.AverageIOPS, summary.AverageE2EMilliseconds, summary.NetworkErrorPercentage, summary.ServerBusyPercentage)
+				screenStats, logStats := formatExtraStats(cca.FromTo, summary, duration)
 
 				output := fmt.Sprintf(
 					`
@@ -1595,7 +2104,7 @@ Final Job Status: %v%s%s
 					summary.TotalBytesTransferred,
 					summary.JobStatus,
 					screenStats,
-					formatPerfAdvice(summary.PerformanceAdvice))
+					formatPerfAdvice(summary.PerformanceAdvice)) + pausedJobHint(summary)
 
 				// abbreviated output for cleanup jobs
 				if cca.isCleanupJob {
@@ -1702,16 +2211,25 @@ func formatPerfAdvice(advice []common.PerformanceAdvice) string {
 
 // format extra stats to include in the log.  If benchmarking, also output them on screen (but not to screen in normal
 // usage because too cluttered)
-func formatExtraStats(fromTo common.FromTo, avgIOPS int, avgE2EMilliseconds int, networkErrorPercent float32, serverBusyPercent float32) (screenStats, logStats string) {
+func formatExtraStats(fromTo common.FromTo, summary common.ListJobSummaryResponse, duration time.Duration) (screenStats, logStats string) {
+	// the average over the whole job, unlike the 2-second throughput shown in the progress
+	throughput := 0.0
+	if duration > 0 {
+		throughput = float64(summary.TotalBytesTransferred) * 8 / base10Mega / duration.Seconds()
+	}
+
 	logStats = fmt.Sprintf(
 		`
 
 Diagnostic stats:
+Throughput (Mb/s): %v
 IOPS: %v
 End-to-end ms per request: %v
+Retries: %v
 Network Errors: %.2f%%
 Server Busy: %.2f%%`,
-		avgIOPS, avgE2EMilliseconds, networkErrorPercent, serverBusyPercent)
+		ste.ToFixed(throughput, 4), summary.AverageIOPS, summary.AverageE2EMilliseconds, summary.RetryCount,
+		summary.NetworkErrorPercentage, summary.ServerBusyPercentage)
 
 	if fromTo.From() == common.ELocation.Benchmark() {
 		screenStats = logStats
@@ -1809,7 +2327,7 @@ func init() {
 		Run: func(cmd *cobra.Command, args []string) {
 			cooked, err := raw.cook()
 			if err != nil {
-				glcm.Error("failed to parse user input due to error: " + err.Error())
+				glcm.ExitWithError("failed to parse user input due to error: "+err.Error(), common.EExitCode.InvalidUsage())
 			}
 
 			glcm.Info("Scanning...")
@@ -1821,7 +2339,9 @@ func init() {
 			}
 
 			if cooked.dryrunMode {
-				glcm.Exit(nil, common.EExitCode.Success())
+				glcm.Exit(func(format common.OutputFormat) string {
+					return cooked.dryrunSummary(format)
+				}, common.EExitCode.Success())
 			}
 
 			glcm.SurrenderControl()
@@ -1830,52 +2350,122 @@ func init() {
 	rootCmd.AddCommand(cpCmd)
 
 	// filters change which files get transferred
-	cpCmd.PersistentFlags().BoolVar(&raw.followSymlinks, "follow-symlinks", false, "Follow symbolic links when uploading from local file system.")
+	cpCmd.PersistentFlags().BoolVar(&raw.followSymlinks, "follow-symlinks", false, "Follow symbolic links when uploading from local file system. "+
+		"Linked files and folders are uploaded as if they were located at the link, even if the link points outside of the source directory. "+
+		"Folders that are linked more than once (including links that form a loop) are only uploaded once. When false, symbolic links are skipped.")
 	cpCmd.PersistentFlags().StringVar(&raw.includeBefore, common.IncludeBeforeFlagName, "", "Include only those files modified before or on the given date/time. The value should be in ISO8601 format. If no timezone is specified, the value is assumed to be in the local timezone of the machine running AzCopy. E.g. '2020-08-19T15:04:00Z' for a UTC time, or '2020-08-19' for midnight (00:00) in the local timezone. As of AzCopy 10.7, this flag applies only to files, not folders, so folder properties won't be copied when using this flag with --preserve-smb-info or --preserve-smb-permissions.")
 	cpCmd.PersistentFlags().StringVar(&raw.includeAfter, common.IncludeAfterFlagName, "", "Include only those files modified on or after the given date/time. The value should be in ISO8601 format. If no timezone is specified, the value is assumed to be in the local timezone of the machine running AzCopy. E.g. '2020-08-19T15:04:00Z' for a UTC time, or '2020-08-19' for midnight (00:00) in the local timezone. As of AzCopy 10.5, this flag applies only to files, not folders, so folder properties won't be copied when using this flag with --preserve-smb-info or --preserve-smb-permissions.")
+	cpCmd.PersistentFlags().StringVar(&raw.minSize, "min-size", "", "Include only those files of at least this size. The value is a number of bytes, or a number followed by K, KB, M, MB, G or GB (in units of 1024). E.g. '100MB'.")
+	cpCmd.PersistentFlags().StringVar(&raw.maxSize, "max-size", "", "Include only those files of at most this size, given in the same way as min-size.")
+	cpCmd.PersistentFlags().StringVar(&raw.newerThan, "newer-than", "", "Include only those files modified within the given age, such as '36h' or '7d', or on or after the given date, in the same format as include-after. "+
+		"When used with include-after, files must satisfy both.")
+	cpCmd.PersistentFlags().StringVar(&raw.olderThan, "older-than", "", "Include only those files modified at least the given age ago, such as '36h' or '7d', or on or before the given date, in the same format as include-before. "+
+		"When used with include-before, files must satisfy both.")
 	cpCmd.PersistentFlags().StringVar(&raw.include, "include-pattern", "", "Include only these files when copying. "+
-		"This option supports wildcard characters (*). Separate files by using a ';'.")
+		"This option supports wildcard characters (*). Separate files by using a ';'. Patterns are case-insensitive on Windows.")
 	cpCmd.PersistentFlags().StringVar(&raw.includePath, "include-path", "", "Include only these paths when copying. "+
 		"This option does not support wildcard characters (*). Checks relative path prefix (For example: myFolder;myFolder/subDirName/file.pdf).")
 	cpCmd.PersistentFlags().StringVar(&raw.excludePath, "exclude-path", "", "Exclude these paths when copying. "+ // Currently, only exclude-path is supported alongside account traversal.
 		"This option does not support wildcard characters (*). Checks relative path prefix(For example: myFolder;myFolder/subDirName/file.pdf). When used in combination with account traversal, paths do not include the container name.")
 	cpCmd.PersistentFlags().StringVar(&raw.includeRegex, "include-regex", "", "Include only the relative path of the files that align with regular expressions. Separate regular expressions with ';'.")
 	cpCmd.PersistentFlags().StringVar(&raw.excludeRegex, "exclude-regex", "", "Exclude all the relative path of the files that align with regular expressions. Separate regular expressions with ';'.")
-	// This flag is implemented only for Storage Explorer.
-	cpCmd.PersistentFlags().StringVar(&raw.listOfFilesToCopy, "list-of-files", "", "Defines the location of text file which has the list of only files to be copied.")
-	cpCmd.PersistentFlags().StringVar(&raw.exclude, "exclude-pattern", "", "Exclude these files when copying. This option supports wildcard characters (*)")
-	cpCmd.PersistentFlags().StringVar(&raw.forceWrite, "overwrite", "true", "Overwrite the conflicting files and blobs at the destination if this flag is set to true. (default 'true') Possible values include 'true', 'false', 'prompt', and 'ifSourceNewer'. For destinations that support folders, conflicting folder-level properties will be overwritten this flag is 'true' or if a positive response is provided to the prompt.")
-	cpCmd.PersistentFlags().BoolVar(&raw.autoDecompress, "decompress", false, "Automatically decompress files when downloading, if their content-encoding indicates that they are compressed. The supported content-encoding values are 'gzip' and 'deflate'. File extensions of '.gz'/'.gzip' or '.zz' aren't necessary, but will be removed if present.")
-	cpCmd.PersistentFlags().BoolVar(&raw.recursive, "recursive", false, "Look into sub-directories recursively when uploading from local file system.")
-	cpCmd.PersistentFlags().StringVar(&raw.fromTo, "from-to", "", "Optionally specifies the source destination combination. For Example: LocalBlob, BlobLocal, LocalBlobFS. Piping: BlobPipe, PipeBlob")
+	cpCmd.PersistentFlags().StringVar(&raw.listOfFilesToCopy, "list-of-files", "", "Defines the location of a text file which lists the only files and directories to be copied, one per line, "+
+		"relative to the source. The paths should NOT be URL-encoded. Lines starting with '#' are comments. "+
+		"When copying from local, a listed path that can't be found is reported as a failed transfer.")
+	cpCmd.PersistentFlags().StringVar(&raw.exclude, "exclude-pattern", "", "Exclude these files when copying. This option supports wildcard characters (*). Patterns are case-insensitive on Windows. "+
+		"If a file matches both an include and an exclude pattern, it is excluded.")
+	cpCmd.PersistentFlags().StringVar(&raw.forceWrite, "overwrite", "true", "Overwrite the conflicting files and blobs at the destination if this flag is set to true. (default 'true') Possible values include 'true', 'false', 'prompt', and 'ifSourceNewer'. For destinations that support folders, conflicting folder-level properties will be overwritten this flag is 'true' or if a positive response is provided to the prompt. At the prompt, 'q' cancels the job. When azcopy is not run from a terminal, 'prompt' behaves like 'false'.")
+	cpCmd.PersistentFlags().BoolVar(&raw.compress, "compress", false, "Compress files with gzip as they are uploaded to block blobs, and set their content-encoding to 'gzip'. "+
+		"Each file is compressed and sent as a single stream, so this disables the parallel, memory-mapped upload of individual large files.")
+	cpCmd.PersistentFlags().Int32Var(&raw.maxTries, "max-tries", 0, fmt.Sprintf("Maximum number of times each request is tried before the transfer fails (default %d).", ste.UploadMaxTries))
+	cpCmd.PersistentFlags().DurationVar(&raw.tryTimeout, "try-timeout", 0, "Maximum time allowed for any single try of a request, e.g. '30m'. Raise this on very slow links, where "+
+		"uploading a single block can legitimately take longer than the default (default 15m, or the value of "+common.EEnvironmentVariable.RequestTryTimeout().Name+").")
+	cpCmd.PersistentFlags().DurationVar(&raw.retryDelay, "retry-delay", 0, fmt.Sprintf("Delay before the first retry of a failed request. Later retries back off exponentially (default %v).", ste.UploadRetryDelay))
+	cpCmd.PersistentFlags().DurationVar(&raw.maxRetryDelay, "max-retry-delay", 0, fmt.Sprintf("Longest delay between retries of a failed request (default %v).", ste.UploadMaxRetryDelay))
+	cpCmd.PersistentFlags().DurationVar(&raw.transferTimeout, "transfer-timeout", 0, "Fail any file that is still being transferred this long after its transfer started, e.g. '2h'. "+
+		"This stops a stalled transfer from holding up the job forever, even when its requests are being retried. By default there is no limit.")
+	cpCmd.PersistentFlags().BoolVar(&raw.autoDecompress, "decompress", false, "Automatically decompress files when downloading, if their content-encoding indicates that they are compressed. The supported content-encoding values are 'gzip' and 'deflate'. File extensions of '.gz'/'.gzip' or '.zz' aren't necessary, but will be removed if present. "+
+		"The compressed data is still downloaded in ranges, in parallel, and is decompressed as it's written to disk in order, so MD5 checks apply to the compressed data. "+
+		"Since the decompressed size isn't known in advance, --check-length doesn't apply to decompressed files. Without this flag, the compressed bytes are saved as they are.")
+	cpCmd.PersistentFlags().BoolVar(&raw.recursive, "recursive", false, "Look into sub-directories recursively when uploading from local file system. "+
+		"Without it, a directory source is rejected, unless it ends with a wildcard (/*), in which case only the files directly inside the directory are copied.")
+	cpCmd.PersistentFlags().StringVar(&raw.fromTo, "from-to", "", "Optionally specifies the source destination combination. For Example: LocalBlob, BlobLocal, LocalBlobFS. Piping: BlobPipe, PipeBlob. "+
+		"This overrides the combination inferred from the arguments, e.g. for URLs with custom domains, but is rejected if it plainly contradicts them, such as naming a URL as Local.")
 	cpCmd.PersistentFlags().StringVar(&raw.excludeBlobType, "exclude-blob-type", "", "Optionally specifies the type of blob (BlockBlob/ PageBlob/ AppendBlob) to exclude when copying blobs from the container "+
 		"or the account. Use of this flag is not applicable for copying data from non azure-service to service. More than one blob should be separated by ';'. ")
 	// options change how the transfers are performed
-	cpCmd.PersistentFlags().Float64Var(&raw.blockSizeMB, "block-size-mb", 0, "Use this block size (specified in MiB) when uploading to Azure Storage, and downloading from Azure Storage. The default value is automatically calculated based on file size. Decimal fractions are allowed (For example: 0.25).")
-	cpCmd.PersistentFlags().StringVar(&raw.logVerbosity, "log-level", "INFO", "Define the log verbosity for the log file, available levels: INFO(all requests/responses), WARNING(slow responses), ERROR(only failed requests), and NONE(no output logs). (default 'INFO').")
+	cpCmd.PersistentFlags().Float64Var(&raw.blockSizeMB, "block-size-mb", 0, "Use this block size (specified in MiB) when uploading to Azure Storage, and downloading from Azure Storage. The default value is automatically calculated based on file size. Decimal fractions are allowed (For example: 0.25). "+
+		"Block blobs can use blocks of up to 4000 MiB, which cuts the number of requests for very large files on fast links, but each block in flight is held in memory (see AZCOPY_BUFFER_GB).")
+	cpCmd.PersistentFlags().Float64Var(&raw.putBlobSizeMB, "put-blob-size-mb", 0, "When uploading block blobs, send files up to this size (specified in MiB) in a single request, rather than in blocks. "+
+		"The default is the block size. Each such file is held in memory whole, so the limit is 4000 MiB, or three quarters of AZCOPY_BUFFER_GB if that's less. Decimal fractions are allowed (For example: 0.25).")
+	cpCmd.PersistentFlags().StringVar(&raw.logVerbosity, "log-level", "INFO", "Define the log verbosity for the log file, available levels: DEBUG(INFO plus details of each chunk), INFO(all requests/responses), WARNING(slow responses), ERROR(only failed requests), and NONE(no output logs). (default 'INFO').")
 	cpCmd.PersistentFlags().StringVar(&raw.blobType, "blob-type", "Detect", "Defines the type of blob at the destination. This is used for uploading blobs and when copying between accounts (default 'Detect'). Valid values include 'Detect', 'BlockBlob', 'PageBlob', and 'AppendBlob'. "+
 		"When copying between accounts, a value of 'Detect' causes AzCopy to use the type of source blob to determine the type of the destination blob. When uploading a file, 'Detect' determines if the file is a VHD or a VHDX file based on the file extension. If the file is either a VHD or VHDX file, AzCopy treats the file as a page blob.")
+	cpCmd.PersistentFlags().StringVar(&raw.deleteSnapshotsOption, "delete-snapshots", "", "By default, a destination blob that has snapshots cannot be overwritten when that requires replacing the blob, e.g. to change its type. "+
+		"Specify 'include' to delete such a blob and all its snapshots before overwriting it; alternatively specify 'only' to delete only the snapshots. "+
+		"A blob uploaded in blocks is never deleted, since that would delete its blocks too, so only its snapshots are.")
 	cpCmd.PersistentFlags().StringVar(&raw.blockBlobTier, "block-blob-tier", "None", "upload block blob to Azure Storage using this blob tier.")
 	cpCmd.PersistentFlags().StringVar(&raw.pageBlobTier, "page-blob-tier", "None", "Upload page blob to Azure Storage using this blob tier. (default 'None').")
-	cpCmd.PersistentFlags().StringVar(&raw.metadata, "metadata", "", "Upload to Azure Storage with these key-value pairs as metadata.")
+	cpCmd.PersistentFlags().StringVar(&raw.metadata, "metadata", "", "Upload to Azure Storage with these key-value pairs as metadata, separated by semicolons (e.g. \"env=prod;team=data\"). Keys must be valid C# identifiers.")
 	cpCmd.PersistentFlags().StringVar(&raw.contentType, "content-type", "", "Specifies the content type of the file. Implies no-guess-mime-type. Returned on download.")
 	cpCmd.PersistentFlags().StringVar(&raw.contentEncoding, "content-encoding", "", "Set the content-encoding header. Returned on download.")
 	cpCmd.PersistentFlags().StringVar(&raw.contentDisposition, "content-disposition", "", "Set the content-disposition header. Returned on download.")
 	cpCmd.PersistentFlags().StringVar(&raw.contentLanguage, "content-language", "", "Set the content-language header. Returned on download.")
 	cpCmd.PersistentFlags().StringVar(&raw.cacheControl, "cache-control", "", "Set the cache-control header. Returned on download.")
 	cpCmd.PersistentFlags().BoolVar(&raw.noGuessMimeType, "no-guess-mime-type", false, "Prevents AzCopy from detecting the content-type based on the extension or content of the file.")
-	cpCmd.PersistentFlags().BoolVar(&raw.preserveLastModifiedTime, "preserve-last-modified-time", false, "Only available when destination is file system.")
+	cpCmd.PersistentFlags().StringVar(&raw.contentTypeMap, "content-type-map", "", "Sets the content type for files with particular extensions, e.g. '.md=text/markdown,.wasm=application/wasm'. "+
+		"Takes precedence over content-type and over detection. If an extension is listed more than once, the last entry is used.")
+	cpCmd.PersistentFlags().BoolVar(&raw.preserveLastModifiedTime, "preserve-last-modified-time", false, "Only available when destination is file system, or when uploading to Blob Storage. "+
+		"Uploads record each file's modification time in the blob metadata key '"+common.LastModifiedTimeMetadataKey+"', and downloads restore it from there, "+
+		"falling back to the blob's own last modified time for blobs without it.")
 	cpCmd.PersistentFlags().BoolVar(&raw.preserveSMBPermissions, "preserve-smb-permissions", false, "False by default. Preserves SMB ACLs between aware resources (Windows and Azure Files). For downloads, you will also need the --backup flag to restore permissions where the new Owner will not be the user running AzCopy. This flag applies to both files and folders, unless a file-only filter is specified (e.g. include-pattern).")
 	cpCmd.PersistentFlags().BoolVar(&raw.asSubdir, "as-subdir", true, "True by default. Places folder sources as subdirectories under the destination.")
+	cpCmd.PersistentFlags().StringVar(&raw.stripPrefix, "strip-prefix", "", "Remove this directory path from the start of each file's path, relative to the source, when naming it at the destination. "+
+		"For example, with --strip-prefix=2023/logs the file 2023/logs/app.txt is copied as app.txt. Files whose paths don't start with it are copied as usual. Either / or \\ may separate the directories.")
+	cpCmd.PersistentFlags().StringVar(&raw.destPrefix, "dest-prefix", "", "Place everything that is copied under this (virtual) directory path at the destination. "+
+		"For example, with --dest-prefix=backups/2024 a blob that would be named photos/a.jpg is named backups/2024/photos/a.jpg instead. "+
+		"Applied after --strip-prefix. Has no effect when the destination names a single blob or file.")
+	cpCmd.PersistentFlags().StringVar(&raw.normalizeBlobNames, "normalize-blob-names", "none", "Normalize the names of the blobs copied to. "+
+		"'nfc' uses Unicode normalization form C, so that names written with combining characters and names written with precomposed ones are the same. "+
+		"'lowercase' does that too, and lowercases the names. If two files would get the same blob name, the copy fails when the second one is found. "+
+		"Possible values include 'none' (the default), 'nfc' and 'lowercase'.")
 	cpCmd.PersistentFlags().BoolVar(&raw.preserveOwner, common.PreserveOwnerFlagName, common.PreserveOwnerDefault, "Only has an effect in downloads, and only when --preserve-smb-permissions is used. If true (the default), the file Owner and Group are preserved in downloads. If set to false, --preserve-smb-permissions will still preserve ACLs but Owner and Group will be based on the user running AzCopy")
 	cpCmd.PersistentFlags().BoolVar(&raw.preserveSMBInfo, "preserve-smb-info", true, "For SMB-aware locations, flag will be set to true by default. Preserves SMB property info (last write time, creation time, attribute bits) between SMB-aware resources (Windows and Azure Files). Only the attribute bits supported by Azure Files will be transferred; any others will be ignored. This flag applies to both files and folders, unless a file-only filter is specified (e.g. include-pattern). The info transferred for folders is the same as that for files, except for Last Write Time which is never preserved for folders.")
 	cpCmd.PersistentFlags().BoolVar(&raw.forceIfReadOnly, "force-if-read-only", false, "When overwriting an existing file on Windows or Azure Files, force the overwrite to work even if the existing file has its read-only attribute set")
 	cpCmd.PersistentFlags().BoolVar(&raw.backupMode, common.BackupModeFlagName, false, "Activates Windows' SeBackupPrivilege for uploads, or SeRestorePrivilege for downloads, to allow AzCopy to see read all files, regardless of their file system permissions, and to restore all permissions. Requires that the account running AzCopy already has these permissions (e.g. has Administrator rights or is a member of the 'Backup Operators' group). All this flag does is activate privileges that the account already has")
-	cpCmd.PersistentFlags().BoolVar(&raw.putMd5, "put-md5", false, "Create an MD5 hash of each file, and save the hash as the Content-MD5 property of the destination blob or file. (By default the hash is NOT created.) Only available when uploading.")
+	cpCmd.PersistentFlags().BoolVar(&raw.ifNoneMatch, "if-none-match", false, "Only write block blobs that do not exist yet. The service checks this at the moment the blob is written, "+
+		"so unlike --overwrite=false, it is safe when another process may be creating the same blobs. Blobs that already exist are skipped. (default false)")
+	cpCmd.PersistentFlags().BoolVar(&raw.validateBlocks, "validate-blocks", false, "When uploading block blobs, send the MD5 hash of each block along with it, so that the service rejects a block that was corrupted on the way, "+
+		"and AzCopy sends it again straight away. This costs CPU time to hash every block, whether or not --put-md5 is also used. "+
+		"Blobs small enough to be sent in a single request are not split into blocks, so are not covered. (default false)")
+	cpCmd.PersistentFlags().BoolVar(&raw.snapshotBeforeOverwrite, "snapshot-before-overwrite", false, "Before overwriting a blob that already exists, take a snapshot of it, so that its previous content can be recovered. "+
+		"A transfer whose destination cannot be snapshotted fails rather than overwriting the blob. (default false)")
+	cpCmd.PersistentFlags().BoolVar(&raw.serverSideCopy, "server-side-copy", false, "When copying from Blob storage to Blob storage, have the service copy each blob with Copy Blob, so that no data passes through AzCopy. "+
+		"Blobs up to 256 MiB are copied synchronously; larger ones, and blobs in the same account whose source URL has no SAS, are copied asynchronously and AzCopy waits for the service to finish. "+
+		"Each blob keeps its type, properties and metadata. A source in another account needs a SAS. (default false)")
+	cpCmd.PersistentFlags().StringVar(&raw.serverSideCopyProperties, "server-side-copy-properties", "preserve", "With --server-side-copy, whether each blob's headers and metadata are copied from the source ('preserve'), "+
+		"or replaced ('replace'). When replacing, the metadata given by --metadata replaces the source's, and each of --content-type, --content-encoding, --content-language, "+
+		"--content-disposition and --cache-control that is given replaces that header. Headers that aren't given keep the source's values. (default 'preserve')")
+	cpCmd.PersistentFlags().StringVar(&raw.manifestPath, "manifest", "", "Write a JSON file to this path when the job ends, listing every transfer with its source, destination, size, "+
+		"status and, where AzCopy computed one, MD5 hash. The manifest is also written when the job is cancelled, and then lists the transfers as they stood at that point")
+	cpCmd.PersistentFlags().StringVar(&raw.failedManifestPath, "failed-manifest", "", "Write the files that failed to transfer to this path when the job ends, in the format of --list-of-files, "+
+		"so that just those files can be copied again by re-running the same command with --list-of-files=<path>. The file is written, with no files listed, even when nothing failed.")
+	cpCmd.PersistentFlags().StringVar(&raw.writeChecksums, "write-checksums", "", "Only available when downloading. Append a line with the MD5 hash and relative path of each downloaded file to this file, "+
+		"in the format of md5sum, so that the download can be verified later by running 'md5sum -c <path>' in the destination folder. The hashes are computed locally, as the files are written.")
+	cpCmd.PersistentFlags().BoolVar(&raw.asTar, "as-tar", false, "Upload the source directory, and everything under it, as a single tar archive to the destination blob. "+
+		"The archive is staged in blocks as it is written, so it is never written to disk. It keeps each entry's mode and modification time, and archives symbolic links as links. "+
+		"Filters cannot be used, and the content type defaults to "+tarContentType+". (default false)")
+	cpCmd.PersistentFlags().BoolVar(&raw.skipContainerCheck, "skip-container-check", false, "Skip the check, made before any files are transferred, that the destination container (or share) exists "+
+		"and that its credential can write to it. Without this flag, a job whose every transfer would fail with 404 or 403 fails straight away instead. (default false)")
+	cpCmd.PersistentFlags().StringVar(&raw.sasTokenFile, "sas-token-file", "", "Path of a file holding the SAS token for the destination, so that it doesn't have to be put in the destination URL, "+
+		"where it would end up in the shell history. The AZCOPY_SAS_TOKEN environment variable can be used instead, with AZCOPY_SAS_TOKEN_ACCOUNT set to the name of the account it is for.")
+	cpCmd.PersistentFlags().BoolVar(&raw.putMd5, "put-md5", false, "Create an MD5 hash of each file, and save the hash as the Content-MD5 property of the destination blob or file. (By default the hash is NOT created.) "+
+		"Block blobs also get the hash, base64-encoded, in an 'md5' metadata entry, which takes precedence over any 'md5' key given with --metadata. Only available when uploading.")
 	cpCmd.PersistentFlags().StringVar(&raw.md5ValidationOption, "check-md5", common.DefaultHashValidationOption.String(), "Specifies how strictly MD5 hashes should be validated when downloading. Only available when downloading. Available options: NoCheck, LogOnly, FailIfDifferent, FailIfDifferentOrMissing. (default 'FailIfDifferent')")
 	cpCmd.PersistentFlags().StringVar(&raw.includeFileAttributes, "include-attributes", "", "(Windows only) Include files whose attributes match the attribute list. For example: A;S;R")
 	cpCmd.PersistentFlags().StringVar(&raw.excludeFileAttributes, "exclude-attributes", "", "(Windows only) Exclude files whose attributes match the attribute list. For example: A;S;R")
-	cpCmd.PersistentFlags().BoolVar(&raw.CheckLength, "check-length", true, "Check the length of a file on the destination after the transfer. If there is a mismatch between source and destination, the transfer is marked as failed.")
+	cpCmd.PersistentFlags().BoolVar(&raw.CheckLength, "check-length", true, "Check the length of a file on the destination after the transfer. If there is a mismatch between source and destination, the transfer is marked as failed. "+
+		"When uploading block blobs with --put-md5, the MD5 hash stored at the destination is checked too.")
 	cpCmd.PersistentFlags().BoolVar(&raw.s2sPreserveProperties, "s2s-preserve-properties", true, "Preserve full properties during service to service copy. "+
 		"For AWS S3 and Azure File non-single file source, the list operation doesn't return full properties of objects and files. To preserve full properties, AzCopy needs to send one additional request per object or file.")
 	cpCmd.PersistentFlags().BoolVar(&raw.s2sPreserveAccessTier, "s2s-preserve-access-tier", true, "Preserve access tier during service to service copy. "+
@@ -1884,7 +2474,8 @@ func init() {
 	cpCmd.PersistentFlags().BoolVar(&raw.s2sSourceChangeValidation, "s2s-detect-source-changed", false, "Detect if the source file/blob changes while it is being read. (This parameter only applies to service to service copies, because the corresponding check is permanently enabled for uploads and downloads.)")
 	cpCmd.PersistentFlags().StringVar(&raw.s2sInvalidMetadataHandleOption, "s2s-handle-invalid-metadata", common.DefaultInvalidMetadataHandleOption.String(), "Specifies how invalid metadata keys are handled. Available options: ExcludeIfInvalid, FailIfInvalid, RenameIfInvalid. (default 'ExcludeIfInvalid').")
 	cpCmd.PersistentFlags().StringVar(&raw.listOfVersionIDs, "list-of-versions", "", "Specifies a file where each version id is listed on a separate line. Ensure that the source must point to a single blob and all the version ids specified in the file using this flag must belong to the source blob only. AzCopy will download the specified versions in the destination folder provided.")
-	cpCmd.PersistentFlags().StringVar(&raw.blobTags, "blob-tags", "", "Set tags on blobs to categorize data in your storage account")
+	cpCmd.PersistentFlags().StringVar(&raw.blobTags, "blob-tags", "", "Set tags on blobs to categorize data in your storage account, so that blobs can be found by tag on the service side. "+
+		"Give them as URL-encoded key=value pairs separated by '&', for example 'key1=val1&key2=val2'. A blob can have at most 10 tags.")
 	cpCmd.PersistentFlags().BoolVar(&raw.s2sPreserveBlobTags, "s2s-preserve-blob-tags", false, "Preserve index tags during service to service transfer from one blob storage to another")
 	cpCmd.PersistentFlags().BoolVar(&raw.includeDirectoryStubs, "include-directory-stub", false, "False by default to ignore directory stubs. Directory stubs are blobs with metadata 'hdi_isfolder:true'. Setting value to true will preserve directory stubs during transfers.")
 	cpCmd.PersistentFlags().BoolVar(&raw.disableAutoDecoding, "disable-auto-decoding", false, "False by default to enable automatic decoding of illegal chars on Windows. Can be set to true to disable automatic decoding.")
@@ -1905,10 +2496,14 @@ func init() {
 	// Customer-provided keys can be stored in Azure Key Vault or in another key store linked to storage account.
 	cpCmd.PersistentFlags().StringVar(&raw.cpkScopeInfo, "cpk-by-name", "", "Client provided key by name let clients making requests against Azure Blob storage an option to provide an encryption key on a per-request basis. Provided key name will be fetched from Azure Key Vault and will be used to encrypt the data")
 	cpCmd.PersistentFlags().BoolVar(&raw.cpkInfo, "cpk-by-value", false, "Client provided key by name let clients making requests against Azure Blob storage an option to provide an encryption key on a per-request basis. Provided key and its hash will be fetched from environment variables")
+	cpCmd.PersistentFlags().StringVar(&raw.cpkEncryptionKey, "cpk-encryption-key", "", "Base64 encoded AES-256 key to encrypt or decrypt the data with, on every request to Azure Blob storage. "+
+		"Implies --cpk-by-value, and is used instead of the key in the environment variable "+common.EEnvironmentVariable.CPKEncryptionKey().Name+". "+
+		"Note that command lines can be seen by other users of the machine, which the environment variable avoids. "+
+		"Can't be used with --ste-url, since the key isn't sent to the transfer engine.")
+	cpCmd.PersistentFlags().StringVar(&raw.cpkEncryptionKeySHA256, "cpk-encryption-key-sha256", "", "Base64 encoded SHA-256 hash of the key given with --cpk-encryption-key. "+
+		"It's computed from the key if not given; if given, it must match the key.")
 
 	// permanently hidden
-	// Hide the list-of-files flag since it is implemented only for Storage Explorer.
-	cpCmd.PersistentFlags().MarkHidden("list-of-files")
 	cpCmd.PersistentFlags().MarkHidden("s2s-get-properties-in-backend")
 
 	// temp, to assist users with change in param names, by providing a clearer message when these obsolete ones are accidentally used
@@ -1923,5 +2518,5 @@ func init() {
 
 	// Deprecate the old persist-smb-permissions flag
 	cpCmd.PersistentFlags().MarkHidden("preserve-smb-permissions")
-	cpCmd.PersistentFlags().BoolVar(&raw.preservePermissions, PreservePermissionsFlag, false, "False by default. Preserves ACLs between aware resources (Windows and Azure Files, or ADLS Gen 2 to ADLS Gen 2). For Hierarchical Namespace accounts, you will need a container SAS or OAuth token with Modify Ownership and Modify Permissions permissions. For downloads, you will also need the --backup flag to restore permissions where the new Owner will not be the user running AzCopy. This flag applies to both files and folders, unless a file-only filter is specified (e.g. include-pattern).")
+	cpCmd.PersistentFlags().BoolVar(&raw.preservePermissions, PreservePermissionsFlag, false, "False by default. Preserves ACLs between aware resources (Windows and Azure Files, or ADLS Gen 2 to ADLS Gen 2). Uploads to Azure Files from other platforms map the POSIX permissions of each file and folder to the closest SMB permissions and attributes, and log a warning when the mapping is not exact. For Hierarchical Namespace accounts, you will need a container SAS or OAuth token with Modify Ownership and Modify Permissions permissions. For downloads, you will also need the --backup flag to restore permissions where the new Owner will not be the user running AzCopy. This flag applies to both files and folders, unless a file-only filter is specified (e.g. include-pattern).")
 }
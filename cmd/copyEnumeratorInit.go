@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -41,9 +42,9 @@ func (cca *CookedCopyCmdArgs) initEnumerator(jobPartOrder common.CopyJobPartOrde
 
 	if srcCredInfo, isPublic, err = GetCredentialInfoForLocation(ctx, cca.FromTo.From(), cca.Source.Value, cca.Source.SAS, true, cca.CpkOptions); err != nil {
 		return nil, err
-		// If S2S and source takes OAuthToken as its cred type (OR) source takes anonymous as its cred type, but it's not public and there's no SAS
+		// If S2S and source takes OAuthToken (or SharedKey) as its cred type (OR) source takes anonymous as its cred type, but it's not public and there's no SAS
 	} else if cca.FromTo.From().IsRemote() && cca.FromTo.To().IsRemote() &&
-		(srcCredInfo.CredentialType == common.ECredentialType.OAuthToken() ||
+		(srcCredInfo.CredentialType == common.ECredentialType.OAuthToken() || srcCredInfo.CredentialType == common.ECredentialType.SharedKey() ||
 			(srcCredInfo.CredentialType == common.ECredentialType.Anonymous() && !isPublic && cca.Source.SAS == "")) {
 		// TODO: Generate a SAS token if it's blob -> *
 		return nil, errors.New("a SAS token (or S3 access key) is required as a part of the source in S2S transfers, unless the source is a public resource")
@@ -76,13 +77,16 @@ func (cca *CookedCopyCmdArgs) initEnumerator(jobPartOrder common.CopyJobPartOrde
 		return nil, err
 	}
 
+	// A local file named in --list-of-files is expected to be there, so if it isn't, that's a failure, not just something to skip.
+	// (Remote ones are left alone, since a placeholder for a missing remote object would download as an empty file.)
+	if lt, ok := traverser.(*listTraverser); ok && cca.listOfFilesLocation != "" && cca.FromTo.From() == common.ELocation.Local() {
+		lt.reportMissingChildren = true
+	}
+
 	// Ensure we're only copying a directory under valid conditions
 	isSourceDir := traverser.IsDirectory(true)
-	if isSourceDir &&
-		!cca.Recursive && // Copies the folder & everything under it
-		!cca.StripTopDir { // Copies only everything under it
-		// todo: dir only transfer, also todo: support syncing the root folder's acls on sync.
-		return nil, errors.New("cannot use directory as source without --recursive or a trailing wildcard (/*)")
+	if err = validateDirectorySource(isSourceDir, cca.Recursive, cca.StripTopDir); err != nil {
+		return nil, err
 	}
 
 	// Check if the destination is a directory so we can correctly decide where our files land
@@ -217,6 +221,14 @@ func (cca *CookedCopyCmdArgs) initEnumerator(jobPartOrder common.CopyJobPartOrde
 				}
 			}
 		}
+
+		// fail the whole job now, rather than have every transfer fail on its own, if we can't possibly write to the destination
+		// (a dry run never touches the destination, so there's nothing to check)
+		if dstContainerName != "" && !cca.skipContainerCheck && !cca.dryrunMode {
+			if err = cca.checkDstContainer(ctx, dstContainerName); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	filters := cca.InitModularFilters()
@@ -231,6 +243,9 @@ func (cca *CookedCopyCmdArgs) initEnumerator(jobPartOrder common.CopyJobPartOrde
 		ste.JobsAdmin.LogToJobLog(message, pipeline.LogInfo)
 	}
 
+	// the source of each blob name, to detect files whose names normalize to the same blob name
+	normalizedBlobNames := make(map[string]string)
+
 	processor := func(object StoredObject) error {
 		// Start by resolving the name and creating the container
 		if object.ContainerName != "" {
@@ -263,7 +278,12 @@ func (cca *CookedCopyCmdArgs) initEnumerator(jobPartOrder common.CopyJobPartOrde
 		}
 
 		srcRelPath := cca.MakeEscapedRelativePath(true, isDestDir, cca.asSubdir, object)
-		dstRelPath := cca.MakeEscapedRelativePath(false, isDestDir, cca.asSubdir, object)
+		dstRelPath := cca.destinationRelativePath(isDestDir, object)
+		if cca.blobNameNormalization != common.EBlobNameNormalization.None() && object.entityType == common.EEntityType.File() {
+			if err := checkBlobNameCollision(normalizedBlobNames, srcRelPath, dstRelPath); err != nil {
+				return err
+			}
+		}
 
 		transfer, shouldSendToSte := object.ToNewCopyTransfer(
 			cca.autoDecompress && cca.FromTo.IsDownload(),
@@ -276,6 +296,10 @@ func (cca *CookedCopyCmdArgs) initEnumerator(jobPartOrder common.CopyJobPartOrde
 		}
 
 		if cca.dryrunMode && shouldSendToSte {
+			if object.entityType == common.EEntityType.File() {
+				cca.dryrunFileCount++
+				cca.dryrunTotalBytes += uint64(object.size)
+			}
 			glcm.Dryrun(func(format common.OutputFormat) string {
 				if format == common.EOutputFormat.Json() {
 					jsonOutput, err := json.Marshal(transfer)
@@ -327,6 +351,17 @@ func (cca *CookedCopyCmdArgs) initEnumerator(jobPartOrder common.CopyJobPartOrde
 	return NewCopyEnumerator(traverser, filters, processor, finalizer), nil
 }
 
+// validateDirectorySource stops a directory from being walked by accident. A directory source needs either
+// --recursive, which copies the folder & everything under it, or a trailing wildcard (/*), which copies
+// only the files directly inside it.
+func validateDirectorySource(isSourceDir bool, recursive bool, stripTopDir bool) error {
+	if isSourceDir && !recursive && !stripTopDir {
+		// todo: dir only transfer, also todo: support syncing the root folder's acls on sync.
+		return errors.New("cannot use directory as source without --recursive or a trailing wildcard (/*)")
+	}
+	return nil
+}
+
 // This is condensed down into an individual function as we don't end up re-using the destination traverser at all.
 // This is just for the directory check.
 func (cca *CookedCopyCmdArgs) isDestDirectory(dst common.ResourceString, ctx *context.Context) bool {
@@ -364,6 +399,14 @@ func (cca *CookedCopyCmdArgs) InitModularFilters() []ObjectFilter {
 		filters = append(filters, &IncludeAfterDateFilter{Threshold: *cca.IncludeAfter})
 	}
 
+	if cca.MinSize != nil {
+		filters = append(filters, &minSizeFilter{size: *cca.MinSize})
+	}
+
+	if cca.MaxSize != nil {
+		filters = append(filters, &maxSizeFilter{size: *cca.MaxSize})
+	}
+
 	if len(cca.IncludePatterns) != 0 {
 		filters = append(filters, &IncludeFilter{patterns: cca.IncludePatterns}) // TODO should this call buildIncludeFilters?
 	}
@@ -523,6 +566,75 @@ func (cca *CookedCopyCmdArgs) createDstContainer(containerName string, dstWithSA
 	return
 }
 
+// checkDstContainer makes sure that the destination container (or share) exists, and that our credential can write to it.
+// Only definite answers fail the check. If the service can't be reached, or the credential is one that can write
+// but isn't allowed to read the container's properties, we carry on and let the transfers find out for themselves.
+func (cca *CookedCopyCmdArgs) checkDstContainer(parentCtx context.Context, containerName string) error {
+	location := cca.FromTo.To()
+	if location != common.ELocation.Blob() && location != common.ELocation.File() {
+		return nil
+	}
+
+	// a SAS that doesn't grant write (or create) can't upload anything, whatever state the container is in
+	sas, err := url.ParseQuery(cca.Destination.SAS)
+	if err != nil {
+		return fmt.Errorf("cannot parse the SAS of the destination: %w", err)
+	}
+	hasSAS := cca.Destination.SAS != ""
+	permissions := sas.Get("sp")
+	if hasSAS && permissions != "" && !strings.ContainsAny(permissions, "wc") {
+		return fmt.Errorf("the SAS of the destination only grants the permissions '%s', so nothing can be written to %s. "+
+			"It needs write (w) permission. Use --skip-container-check to skip this check", permissions, containerName)
+	}
+
+	ctx, cancel := context.WithTimeout(parentCtx, time.Minute)
+	defer cancel()
+	dstCredInfo, _, err := GetCredentialInfoForLocation(ctx, location, cca.Destination.Value, cca.Destination.SAS, false, cca.CpkOptions)
+	if err != nil {
+		return err
+	}
+	dstPipeline, err := InitPipeline(ctx, location, dstCredInfo, cca.LogVerbosity.ToPipelineLogLevel())
+	if err != nil {
+		return err
+	}
+	accountRoot, err := GetAccountRoot(cca.Destination, location)
+	if err != nil {
+		return err
+	}
+	accountURL, err := url.Parse(accountRoot)
+	if err != nil {
+		return err
+	}
+
+	if location == common.ELocation.Blob() {
+		_, err = azblob.NewServiceURL(*accountURL, dstPipeline).NewContainerURL(containerName).GetProperties(ctx, azblob.LeaseAccessConditions{})
+	} else {
+		_, err = azfile.NewServiceURL(*accountURL, dstPipeline).NewShareURL(containerName).GetProperties(ctx)
+	}
+	if err == nil {
+		return nil
+	}
+	resp, ok := err.(pipeline.Response)
+	if !ok || resp.Response() == nil {
+		return nil // no answer from the service
+	}
+
+	switch resp.Response().StatusCode {
+	case http.StatusNotFound:
+		return fmt.Errorf("the destination container %s does not exist. Create it first, or check the destination URL. "+
+			"Use --skip-container-check to skip this check", containerName)
+	case http.StatusForbidden, http.StatusUnauthorized:
+		// Only an account SAS (or OAuth/shared key) that can read is sure to be allowed to get the container's properties.
+		// Service SASes, and write-only SASes, may be refused even though they can write.
+		if hasSAS && (!strings.Contains(sas.Get("srt"), "c") || !strings.Contains(permissions, "r")) {
+			return nil
+		}
+		return fmt.Errorf("not authorized to access the destination container %s (%s). Check the credential or SAS of the destination. "+
+			"Use --skip-container-check to skip this check", containerName, resp.Response().Status)
+	}
+	return nil
+}
+
 // Because some invalid characters weren't being properly encoded by url.PathEscape, we're going to instead manually encode them.
 var encodedInvalidCharacters = map[rune]string{
 	'<':  "%3C",
@@ -588,6 +700,51 @@ func pathEncodeRules(path string, fromTo common.FromTo, disableAutoDecoding bool
 	return path
 }
 
+// destinationRelativePath is MakeEscapedRelativePath for the destination, with --strip-prefix and --dest-prefix applied
+func (cca *CookedCopyCmdArgs) destinationRelativePath(dstIsDir bool, object StoredObject) string {
+	if cca.stripPrefix != "" {
+		object.relativePath = stripPathPrefix(object.relativePath, cca.stripPrefix)
+	}
+	object.relativePath = cca.blobNameNormalization.Normalize(object.relativePath)
+	object.name = cca.blobNameNormalization.Normalize(object.name)
+	relativePath := cca.MakeEscapedRelativePath(false, dstIsDir, cca.asSubdir, object)
+	if cca.destPrefix == "" || relativePath == "" {
+		return relativePath // no prefix wanted, or the destination is exactly where the object goes
+	}
+
+	prefix := pathEncodeRules(common.AZCOPY_PATH_SEPARATOR_STRING+cca.destPrefix, cca.FromTo, cca.disableAutoDecoding, false)
+	if object.DstContainerName == "" {
+		return prefix + relativePath
+	}
+	// the path starts with the name of the container, so the prefix goes just inside that
+	containerEnd := strings.Index(relativePath[1:], common.AZCOPY_PATH_SEPARATOR_STRING) + 1
+	if containerEnd == 0 {
+		return relativePath + prefix
+	}
+	return relativePath[:containerEnd] + prefix + relativePath[containerEnd:]
+}
+
+// checkBlobNameCollision records that the file at srcRelPath is copied to dstRelPath, and fails if another file already is.
+// Folders aren't checked, since folders whose names normalize to the same name just merge.
+func checkBlobNameCollision(seen map[string]string, srcRelPath, dstRelPath string) error {
+	if other, ok := seen[dstRelPath]; ok && other != srcRelPath {
+		return fmt.Errorf("cannot copy both %s and %s, because both would be named %s at the destination after --normalize-blob-names is applied",
+			strings.TrimPrefix(other, "/"), strings.TrimPrefix(srcRelPath, "/"), strings.TrimPrefix(dstRelPath, "/"))
+	}
+	seen[dstRelPath] = srcRelPath
+	return nil
+}
+
+// stripPathPrefix removes prefix, a directory path, from the start of relativePath.
+// Paths that aren't inside that directory are left as they are
+func stripPathPrefix(relativePath string, prefix string) string {
+	normalized := strings.ReplaceAll(relativePath, common.OS_PATH_SEPARATOR, common.AZCOPY_PATH_SEPARATOR_STRING)
+	if strings.HasPrefix(normalized, prefix+common.AZCOPY_PATH_SEPARATOR_STRING) {
+		return normalized[len(prefix)+1:]
+	}
+	return relativePath
+}
+
 func (cca *CookedCopyCmdArgs) MakeEscapedRelativePath(source bool, dstIsDir bool, asSubdir bool, object StoredObject) (relativePath string) {
 	// write straight to /dev/null, do not determine a indirect path
 	if !source && cca.Destination.Value == common.Dev_Null {
@@ -657,6 +814,7 @@ func (cca *CookedCopyCmdArgs) MakeEscapedRelativePath(source bool, dstIsDir bool
 				panic("unexpected inescapable rootDir name")
 			}
 		}
+		rootDir = cca.blobNameNormalization.Normalize(rootDir)
 		
 		relativePath = "/" + rootDir + relativePath
 	}
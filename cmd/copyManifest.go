@@ -0,0 +1,109 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// copyManifest is the content of the file written by --manifest. It lists every transfer of the job,
+// in whatever state the transfer was in when the job ended, so that a cancelled job still has a useful manifest
+type copyManifest struct {
+	JobID     common.JobID
+	JobStatus common.JobStatus
+	Transfers []common.TransferDetail
+}
+
+// writeCopyManifest fetches all transfers of the job from the STE and writes them as JSON to manifestPath
+func writeCopyManifest(jobID common.JobID, jobStatus common.JobStatus, manifestPath string) error {
+	var resp common.ListJobTransfersResponse
+	Rpc(common.ERpcCmd.ListJobTransfers(),
+		common.ListJobTransfersRequest{JobID: jobID, OfStatus: common.ETransferStatus.All()},
+		&resp)
+	if resp.ErrorMsg != "" {
+		return fmt.Errorf("cannot list the transfers of job %s: %s", jobID, resp.ErrorMsg)
+	}
+
+	manifest := copyManifest{JobID: jobID, JobStatus: jobStatus, Transfers: resp.Details}
+	if manifest.Transfers == nil {
+		manifest.Transfers = []common.TransferDetail{}
+	}
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(manifestPath, content, 0644)
+}
+
+// writeFailedManifest writes the sources of the given failed transfers to manifestPath, one per line, relative to the job's source,
+// so that the file can be given straight back to --list-of-files. Folder property transfers are left out, since listing
+// a folder would copy everything in it again.
+func writeFailedManifest(jobID common.JobID, source common.ResourceString, location common.Location, failed []common.TransferDetail, manifestPath string) error {
+	lines := []string{
+		listOfFilesCommentPrefix + " Failed transfers of job " + jobID.String() + ", relative to " + common.URLStringExtension(source.Value).RedactSecretQueryParamForLogging(),
+	}
+
+	listed := make(map[string]bool)
+	for _, transfer := range failed {
+		if transfer.IsFolderProperties {
+			continue
+		}
+		relativePath, ok := pathRelativeToSource(transfer.Src, source.Value, location)
+		if !ok || listed[relativePath] {
+			continue
+		}
+		listed[relativePath] = true
+		lines = append(lines, relativePath)
+	}
+
+	return ioutil.WriteFile(manifestPath, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+// pathRelativeToSource returns the path, as --list-of-files expects it (not URL-encoded, with forward slashes), of src under source
+func pathRelativeToSource(src string, source string, location common.Location) (string, bool) {
+	var srcPath, sourcePath string
+	if location.IsLocal() {
+		srcPath, sourcePath = filepath.ToSlash(src), filepath.ToSlash(source)
+	} else {
+		srcURL, err := url.Parse(src)
+		if err != nil {
+			return "", false
+		}
+		sourceURL, err := url.Parse(source)
+		if err != nil {
+			return "", false
+		}
+		srcPath, sourcePath = srcURL.Path, sourceURL.Path
+	}
+
+	sourcePath = strings.TrimSuffix(strings.TrimSuffix(sourcePath, "*"), "/") + "/"
+	if !strings.HasPrefix(srcPath, sourcePath) || srcPath == sourcePath {
+		return "", false
+	}
+	return strings.TrimPrefix(srcPath, sourcePath), true
+}
@@ -0,0 +1,134 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+type copyManifestSuite struct{}
+
+var _ = chk.Suite(&copyManifestSuite{})
+
+func (s *copyManifestSuite) TestManifestListsEveryTransfer(c *chk.C) {
+	jobID := common.NewJobID()
+	hash := md5.Sum([]byte("hello world"))
+	transfers := []common.TransferDetail{
+		{Src: "/data/a.txt", Dst: "https://acct.blob.core.windows.net/c/a.txt", TransferStatus: common.ETransferStatus.Success(), TransferSize: 11, ContentMD5: hash[:]},
+		{Src: "/data/b.txt", Dst: "https://acct.blob.core.windows.net/c/b.txt", TransferStatus: common.ETransferStatus.Failed(), TransferSize: 5},
+		{Src: "/data/c.txt", Dst: "https://acct.blob.core.windows.net/c/c.txt", TransferStatus: common.ETransferStatus.Started(), TransferSize: 7},
+	}
+
+	originalRpc := Rpc
+	defer func() { Rpc = originalRpc }()
+	Rpc = func(cmd common.RpcCmd, request interface{}, response interface{}) {
+		c.Assert(cmd, chk.Equals, common.ERpcCmd.ListJobTransfers())
+		req := request.(common.ListJobTransfersRequest)
+		c.Assert(req.JobID, chk.Equals, jobID)
+		c.Assert(req.OfStatus, chk.Equals, common.ETransferStatus.All())
+		*(response.(*common.ListJobTransfersResponse)) = common.ListJobTransfersResponse{JobID: jobID, Details: transfers}
+	}
+
+	// a cancelled job still gets a manifest, listing the transfers in the state they were left in
+	manifestPath := filepath.Join(c.MkDir(), "manifest.json")
+	c.Assert(writeCopyManifest(jobID, common.EJobStatus.Cancelled(), manifestPath), chk.IsNil)
+
+	content, err := ioutil.ReadFile(manifestPath)
+	c.Assert(err, chk.IsNil)
+	var manifest copyManifest
+	c.Assert(json.Unmarshal(content, &manifest), chk.IsNil)
+	c.Assert(manifest.JobID, chk.Equals, jobID)
+	c.Assert(manifest.JobStatus, chk.Equals, common.EJobStatus.Cancelled())
+	c.Assert(manifest.Transfers, chk.DeepEquals, transfers)
+}
+
+func (s *copyManifestSuite) TestManifestReportsListingErrors(c *chk.C) {
+	originalRpc := Rpc
+	defer func() { Rpc = originalRpc }()
+	Rpc = func(cmd common.RpcCmd, request interface{}, response interface{}) {
+		*(response.(*common.ListJobTransfersResponse)) = common.ListJobTransfersResponse{ErrorMsg: "no job with JobId exists"}
+	}
+
+	manifestPath := filepath.Join(c.MkDir(), "manifest.json")
+	c.Assert(writeCopyManifest(common.NewJobID(), common.EJobStatus.Completed(), manifestPath), chk.NotNil)
+	_, err := ioutil.ReadFile(manifestPath)
+	c.Assert(err, chk.NotNil)
+}
+
+func (s *copyManifestSuite) TestManifestCannotBeUsedWithDryrun(c *chk.C) {
+	raw := getDefaultCopyRawInput(c.MkDir(), "https://acct.blob.core.windows.net/container")
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+	raw.manifestPath = filepath.Join(c.MkDir(), "manifest.json")
+
+	_, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+
+	raw.dryrun = true
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+}
+
+func (s *copyManifestSuite) TestFailedManifestListsEachFailureOnce(c *chk.C) {
+	jobID := common.NewJobID()
+	failed := []common.TransferDetail{
+		{Src: "/data/sub/a.txt", Dst: "https://acct.blob.core.windows.net/c/sub/a.txt", TransferStatus: common.ETransferStatus.Failed()},
+		{Src: "/data/b.txt", Dst: "https://acct.blob.core.windows.net/c/b.txt", TransferStatus: common.ETransferStatus.Failed()},
+		{Src: "/data/sub", Dst: "https://acct.blob.core.windows.net/c/sub", TransferStatus: common.ETransferStatus.Failed(), IsFolderProperties: true},
+		{Src: "/data/b.txt", Dst: "https://acct.blob.core.windows.net/c/b.txt", TransferStatus: common.ETransferStatus.Failed()}, // e.g. reported again after a resume
+	}
+
+	manifestPath := filepath.Join(c.MkDir(), "failed.txt")
+	c.Assert(writeFailedManifest(jobID, common.ResourceString{Value: "/data"}, common.ELocation.Local(), failed, manifestPath), chk.IsNil)
+
+	content, err := ioutil.ReadFile(manifestPath)
+	c.Assert(err, chk.IsNil)
+	c.Assert(string(content), chk.Equals, "# Failed transfers of job "+jobID.String()+", relative to /data\nsub/a.txt\nb.txt\n")
+
+	// and it can be given straight back to --list-of-files
+	raw := getDefaultCopyRawInput("/data", "https://acct.blob.core.windows.net/c")
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+	raw.listOfFilesToCopy = manifestPath
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	listed := make([]string, 0)
+	for v := range cooked.ListOfFilesChannel {
+		listed = append(listed, v)
+	}
+	c.Assert(listed, chk.DeepEquals, []string{"sub/a.txt", "b.txt"})
+}
+
+func (s *copyManifestSuite) TestFailedManifestPathsOfRemoteSources(c *chk.C) {
+	source := "https://acct.blob.core.windows.net/container/dir?sv=2019-12-12&sig=secret"
+
+	relativePath, ok := pathRelativeToSource("https://acct.blob.core.windows.net/container/dir/sub/file%20one.txt?sv=2019-12-12&sig=REDACTED", source, common.ELocation.Blob())
+	c.Assert(ok, chk.Equals, true)
+	c.Assert(relativePath, chk.Equals, "sub/file one.txt") // list-of-files paths are not URL-encoded
+
+	_, ok = pathRelativeToSource("https://acct.blob.core.windows.net/container/other/file.txt", source, common.ELocation.Blob())
+	c.Assert(ok, chk.Equals, false)
+}
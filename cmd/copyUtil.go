@@ -22,6 +22,7 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
@@ -204,6 +205,34 @@ func startsWith(s string, t string) bool {
 	return len(s) >= len(t) && strings.EqualFold(s[0:len(t)], t)
 }
 
+// cookCpkByValue works out whether data is encrypted with a client provided key by value (CPK-V), from --cpk-by-value
+// and --cpk-encryption-key. A key given on the command line implies CPK-V, and becomes the key of every request of the job.
+// Either way, the key is checked before anything is transferred.
+// The key given on the command line is only known to this process, so it can't be used with a transfer engine elsewhere.
+func cookCpkByValue(cpkByValue bool, encryptionKey string, encryptionKeySHA256 string) (bool, error) {
+	if encryptionKey != "" {
+		if configuredSteURL(cmdLineSteURL) != "" {
+			return false, fmt.Errorf("--cpk-encryption-key cannot be used with --ste-url or %s, because the key is not sent to the transfer engine. "+
+				"Instead, set %s where the transfer engine runs, and use --cpk-by-value",
+				common.EEnvironmentVariable.SteURL().Name, common.EEnvironmentVariable.CPKEncryptionKey().Name)
+		}
+		return true, common.SetCpkEncryptionKey(encryptionKey, encryptionKeySHA256)
+	}
+	if encryptionKeySHA256 != "" {
+		return false, errors.New("--cpk-encryption-key-sha256 can only be used with --cpk-encryption-key")
+	}
+
+	if cpkByValue {
+		if key := glcm.GetEnvironmentVariable(common.EEnvironmentVariable.CPKEncryptionKey()); key != "" {
+			keySHA256 := glcm.GetEnvironmentVariable(common.EEnvironmentVariable.CPKEncryptionKeySHA256())
+			if _, err := common.ValidateCpkEncryptionKey(key, keySHA256); err != nil {
+				return false, fmt.Errorf("%s: %w", common.EEnvironmentVariable.CPKEncryptionKey().Name, err)
+			}
+		}
+	}
+	return cpkByValue, nil
+}
+
 /////////////////////////////////////////////////////////////////////////////////////////////////
 type s3URLPartsExtension struct {
 	common.S3URLParts
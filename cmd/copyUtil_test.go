@@ -21,8 +21,17 @@
 package cmd
 
 import (
-	chk "gopkg.in/check.v1"
+	"fmt"
 	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-azcopy/v10/ste"
+	chk "gopkg.in/check.v1"
 )
 
 type copyUtilTestSuite struct{}
@@ -79,3 +88,436 @@ func (s *copyUtilTestSuite) TestIPIsContainerOrBlob(c *chk.C) {
 	c.Assert(isContainerURL, chk.Equals, true) // URL endpoints do not contain the account in the path, making the container the first entry.
 	// The behaviour isn't too different from here.
 }
+
+func (s *copyUtilTestSuite) TestDryrunUploadSummarizesWithoutNetworkCalls(c *chk.C) {
+	srcDirName := scenarioHelper{}.generateLocalDirectory(c)
+	defer os.RemoveAll(srcDirName)
+	fileList := []string{"file1.txt", "sub/file2.txt", "sub/deeper/file3.txt"}
+	scenarioHelper{}.generateLocalFilesFromList(c, srcDirName, fileList)
+
+	mockedRPC := interceptor{}
+	Rpc = mockedRPC.intercept
+	mockedLcm := mockedLifecycleManager{dryrunLog: make(chan string, 50)}
+	mockedLcm.SetOutputFormat(common.EOutputFormat.Text())
+	glcm = &mockedLcm
+
+	// nothing listens on this port, so any attempt to reach the destination would fail the copy
+	raw := getDefaultCopyRawInput(srcDirName, "https://127.0.0.1:1/container?sv=2019-12-12&sig=fake")
+	raw.dryrun = true
+	raw.recursive = true
+	raw.forceWrite = common.EOverwriteOption.False().String()
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.process(), chk.IsNil)
+
+	c.Assert(len(mockedRPC.transfers), chk.Equals, 0)
+	c.Assert(len(mockedLcm.GatherAllLogs(mockedLcm.dryrunLog)), chk.Equals, len(fileList))
+	c.Assert(cooked.dryrunFileCount, chk.Equals, uint32(len(fileList)))
+	c.Assert(cooked.dryrunTotalBytes, chk.Equals, uint64(len(fileList)*defaultFileSize))
+
+	summary := cooked.dryrunSummary(common.EOutputFormat.Text())
+	c.Assert(strings.HasPrefix(summary, "DRYRUN: 3 file(s)"), chk.Equals, true)
+	c.Assert(strings.Contains(summary, "--overwrite=false"), chk.Equals, true)
+}
+
+func (s *copyUtilTestSuite) TestOverwritePromptWithoutTerminalSkipsExistingFiles(c *chk.C) {
+	defer func(isTerminal func() bool) { stdinIsTerminal = isTerminal }(stdinIsTerminal)
+	defer func(format common.OutputFormat) { azcopyOutputFormat = format }(azcopyOutputFormat)
+	mockedLcm := mockedLifecycleManager{infoLog: make(chan string, 50)}
+	glcm = &mockedLcm
+
+	raw := getDefaultCopyRawInput("/tmp/source", "https://account.blob.core.windows.net/container")
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+	raw.forceWrite = common.EOverwriteOption.Prompt().String()
+	azcopyOutputFormat = common.EOutputFormat.Text()
+
+	// from a terminal, the user is asked
+	stdinIsTerminal = func() bool { return true }
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.ForceWrite, chk.Equals, common.EOverwriteOption.Prompt())
+
+	// otherwise, there's nobody to ask, so nothing is overwritten
+	stdinIsTerminal = func() bool { return false }
+	cooked, err = raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.ForceWrite, chk.Equals, common.EOverwriteOption.False())
+	c.Assert(<-mockedLcm.infoLog, StringContains, "--overwrite=false")
+
+	// but Storage Explorer answers through a pipe, in JSON
+	azcopyOutputFormat = common.EOutputFormat.Json()
+	cooked, err = raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.ForceWrite, chk.Equals, common.EOverwriteOption.Prompt())
+}
+
+func (s *copyUtilTestSuite) TestDeleteSnapshotsOnlyForBlobDestinations(c *chk.C) {
+	raw := getDefaultCopyRawInput("/tmp/source", "https://account.blob.core.windows.net/container")
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+	raw.deleteSnapshotsOption = "include"
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.deleteSnapshotsOption, chk.Equals, common.EDeleteSnapshotsOption.Include())
+
+	raw.deleteSnapshotsOption = "only"
+	cooked, err = raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.deleteSnapshotsOption, chk.Equals, common.EDeleteSnapshotsOption.Only())
+
+	raw = getDefaultCopyRawInput("/tmp/source", "https://account.file.core.windows.net/share")
+	raw.fromTo = common.EFromTo.LocalFile().String()
+	raw.deleteSnapshotsOption = "include"
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "--delete-snapshots only applies when the destination is Blob storage")
+}
+
+func (s *copyUtilTestSuite) TestDeleteSnapshotsRejectedWithSnapshotBeforeOverwrite(c *chk.C) {
+	raw := getDefaultCopyRawInput("/tmp/source", "https://account.blob.core.windows.net/container")
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+	raw.snapshotBeforeOverwrite = true
+	_, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+
+	for _, option := range []string{"include", "only"} {
+		raw.deleteSnapshotsOption = option
+		_, err = raw.cook()
+		c.Assert(err, chk.NotNil)
+		c.Assert(err.Error(), StringContains, "snapshot-before-overwrite cannot be combined with delete-snapshots="+option)
+	}
+}
+
+func (s *copyUtilTestSuite) TestArchiveTierRejectedForNonBlockBlobs(c *chk.C) {
+	raw := getDefaultCopyRawInput("/tmp/source", "https://account.blob.core.windows.net/container")
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+	raw.blockBlobTier = common.EBlockBlobTier.Archive().String()
+
+	for _, blobType := range []common.BlobType{common.EBlobType.PageBlob(), common.EBlobType.AppendBlob()} {
+		raw.blobType = blobType.String()
+		_, err := raw.cook()
+		c.Assert(err, chk.NotNil)
+		c.Assert(err.Error(), StringContains, "cannot be used when blob-type is")
+	}
+
+	for _, blobType := range []common.BlobType{common.EBlobType.BlockBlob(), common.EBlobType.Detect()} {
+		raw.blobType = blobType.String()
+		_, err := raw.cook()
+		c.Assert(err, chk.IsNil)
+	}
+}
+
+func (s *copyUtilTestSuite) TestRetryFlagsValidation(c *chk.C) {
+	raw := getDefaultCopyRawInput("/tmp/source", "https://account.blob.core.windows.net/container")
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+
+	// nothing given means the STE uses its defaults
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.retryOptions, chk.Equals, common.RetryOptions{})
+
+	raw.maxTries = 40
+	raw.tryTimeout = time.Hour
+	raw.retryDelay = 5 * time.Second
+	raw.maxRetryDelay = 5 * time.Minute
+	cooked, err = raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.retryOptions, chk.Equals, common.RetryOptions{MaxTries: 40, TryTimeout: time.Hour, RetryDelay: 5 * time.Second, MaxRetryDelay: 5 * time.Minute})
+
+	bad := raw
+	bad.maxTries = -1
+	_, err = bad.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "max-tries must be at least 1")
+
+	bad = raw
+	bad.tryTimeout = -time.Second
+	_, err = bad.cook()
+	c.Assert(err, chk.NotNil)
+
+	bad = raw
+	bad.maxRetryDelay = time.Second
+	_, err = bad.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "cannot be less than retry-delay")
+
+	// the check is made against the default when only one of the delays is given
+	bad = getDefaultCopyRawInput("/tmp/source", "https://account.blob.core.windows.net/container")
+	bad.fromTo = common.EFromTo.LocalBlob().String()
+	bad.retryDelay = ste.UploadMaxRetryDelay + time.Second
+	_, err = bad.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "cannot be less than retry-delay")
+}
+
+func (s *copyUtilTestSuite) TestIfNoneMatchNeedsBlobDestination(c *chk.C) {
+	raw := getDefaultCopyRawInput("/tmp/source", "https://account.blob.core.windows.net/container")
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+	raw.ifNoneMatch = true
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.ifNoneMatch, chk.Equals, true)
+
+	raw = getDefaultCopyRawInput("https://account.blob.core.windows.net/container/blob", "/tmp/destination")
+	raw.fromTo = common.EFromTo.BlobLocal().String()
+	raw.ifNoneMatch = true
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "if-none-match is only supported when the destination is Blob storage")
+}
+
+func (s *copyUtilTestSuite) TestIfNoneMatchNeedsBlockBlobs(c *chk.C) {
+	for _, blobType := range []common.BlobType{common.EBlobType.PageBlob(), common.EBlobType.AppendBlob()} {
+		raw := getDefaultCopyRawInput("/tmp/source", "https://account.blob.core.windows.net/container")
+		raw.fromTo = common.EFromTo.LocalBlob().String()
+		raw.blobType = blobType.String()
+		raw.ifNoneMatch = true
+		_, err := raw.cook()
+		c.Assert(err, chk.NotNil)
+		c.Assert(err.Error(), StringContains, "if-none-match only applies to block blobs, not to blob-type "+blobType.String())
+	}
+}
+
+func (s *copyUtilTestSuite) TestValidateBlocksNeedsBlobUpload(c *chk.C) {
+	raw := getDefaultCopyRawInput("/tmp/source", "https://account.blob.core.windows.net/container")
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+	raw.validateBlocks = true
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.validateBlocks, chk.Equals, true)
+
+	// copies between accounts have no data passing through AzCopy to hash
+	raw = getDefaultCopyRawInput("https://source.blob.core.windows.net/container", "https://account.blob.core.windows.net/container")
+	raw.fromTo = common.EFromTo.BlobBlob().String()
+	raw.validateBlocks = true
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "validate-blocks is only supported when uploading to Blob storage")
+}
+
+func (s *copyUtilTestSuite) TestPutBlobSizeNeedsBlockBlobUpload(c *chk.C) {
+	raw := getDefaultCopyRawInput("/tmp/source", "https://account.blob.core.windows.net/container")
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+	raw.blockSizeMB = 16
+	raw.putBlobSizeMB = 256
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.putBlobSize, chk.Equals, int64(256*1024*1024))
+
+	raw.putBlobSizeMB = 5001
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "larger than the maximum the service allows")
+
+	// the service would take it, but the whole file has to be held in memory as one chunk
+	raw.putBlobSizeMB = 4001
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "larger than the largest file AzCopy can hold in memory")
+
+	bufferEnv := common.EEnvironmentVariable.BufferGB().Name
+	os.Setenv(bufferEnv, "1")
+	defer os.Unsetenv(bufferEnv)
+	raw.putBlobSizeMB = 1000
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "which is 768 MiB")
+	raw.putBlobSizeMB = 768
+	_, err = raw.cook()
+	c.Assert(err, chk.IsNil)
+
+	raw.putBlobSizeMB = 256
+	raw.blobType = common.EBlobType.PageBlob().String()
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "put-blob-size-mb only applies to block blobs")
+
+	raw = getDefaultCopyRawInput("https://source.blob.core.windows.net/container", "https://account.blob.core.windows.net/container")
+	raw.fromTo = common.EFromTo.BlobBlob().String()
+	raw.putBlobSizeMB = 256
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "put-blob-size-mb is only supported when uploading to Blob storage")
+}
+
+func (s *copyUtilTestSuite) TestServerSideCopyValidation(c *chk.C) {
+	raw := getDefaultCopyRawInput("https://source.blob.core.windows.net/container", "https://account.blob.core.windows.net/container")
+	raw.fromTo = common.EFromTo.BlobBlob().String()
+	raw.serverSideCopy = true
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.serverSideCopy, chk.Equals, true)
+
+	raw.forceWrite = common.EOverwriteOption.IfSourceNewer().String()
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "server-side-copy only supports --overwrite=true or --overwrite=false")
+
+	raw = getDefaultCopyRawInput("/tmp/source", "https://account.blob.core.windows.net/container")
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+	raw.serverSideCopy = true
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "server-side-copy is only supported when copying from Blob storage to Blob storage")
+}
+
+func (s *copyUtilTestSuite) TestServerSideCopyPropertiesValidation(c *chk.C) {
+	raw := getDefaultCopyRawInput("https://source.blob.core.windows.net/container", "https://account.blob.core.windows.net/container")
+	raw.fromTo = common.EFromTo.BlobBlob().String()
+	raw.serverSideCopy = true
+	raw.contentType = "application/json"
+	raw.metadata = "team=data"
+	_, err := raw.cook()
+	c.Assert(err, chk.NotNil) // preserving is the default, so the headers and metadata can't be given
+
+	raw.serverSideCopyProperties = "replace"
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.replaceProperties, chk.Equals, true)
+	c.Assert(cooked.noGuessMimeType, chk.Equals, true)
+
+	raw.serverSideCopy = false
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+
+	raw.serverSideCopy = true
+	raw.serverSideCopyProperties = "merge"
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "invalid server-side-copy-properties")
+}
+
+func (s *copyUtilTestSuite) TestPreserveLastModifiedTimeAllowedForBlobUploads(c *chk.C) {
+	raw := getDefaultCopyRawInput("/tmp/source", "https://account.blob.core.windows.net/container")
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+	raw.preserveLastModifiedTime = true
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.preserveLastModifiedTime, chk.Equals, true)
+
+	// Azure Files keeps its own modification times, which this flag does not set
+	raw = getDefaultCopyRawInput("/tmp/source", "https://account.file.core.windows.net/share")
+	raw.fromTo = common.EFromTo.LocalFile().String()
+	raw.preserveLastModifiedTime = true
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+}
+
+func (s *copyUtilTestSuite) TestMetadataValidation(c *chk.C) {
+	raw := getDefaultCopyRawInput("/tmp/source", "https://account.blob.core.windows.net/container")
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+
+	raw.metadata = "env=prod;team=data"
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.metadata, chk.Equals, "env=prod;team=data")
+
+	raw.metadata = "env=prod;cost-center=42"
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "invalid metadata key 'cost-center'")
+
+	raw.metadata = "key=" + strings.Repeat("v", ste.MetadataMaxBytes)
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "metadata is too long")
+}
+
+func (s *copyUtilTestSuite) TestBlobTagsValidation(c *chk.C) {
+	raw := getDefaultCopyRawInput("/tmp/source", "https://account.blob.core.windows.net/container")
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+
+	tags := make([]string, 0)
+	for i := 0; i < 10; i++ {
+		tags = append(tags, fmt.Sprintf("key%d=value%d", i, i))
+	}
+	raw.blobTags = strings.Join(tags, "&")
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.blobTags, chk.HasLen, 10)
+
+	raw.blobTags = strings.Join(append(tags, "key10=value10"), "&")
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "at-most 10 tags")
+
+	// values may hold '=', and may be empty
+	raw.blobTags = "project=a=b&empty="
+	cooked, err = raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.blobTags, chk.DeepEquals, common.BlobTags{"project": "a=b", "empty": ""})
+
+	for tagsString, expected := range map[string]string{
+		"project":           "must be given as key=value",
+		"a=1&a=2":           "given more than once",
+		"a=1&&b=2":          "must be given as key=value",
+		"bad%21char=value":  "incorrect character set used in key",
+		"key=" + "value%2A": "incorrect character set used in value",
+	} {
+		raw.blobTags = tagsString
+		_, err = raw.cook()
+		c.Assert(err, chk.NotNil, chk.Commentf(tagsString))
+		c.Assert(err.Error(), StringContains, expected)
+	}
+}
+
+func (s *copyUtilTestSuite) TestWriteChecksumsValidation(c *chk.C) {
+	checksumFile := filepath.Join(c.MkDir(), "checksums.md5")
+
+	raw := getDefaultCopyRawInput("https://account.blob.core.windows.net/container", c.MkDir())
+	raw.fromTo = common.EFromTo.BlobLocal().String()
+	raw.writeChecksums = checksumFile
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.checksumFile, chk.Equals, checksumFile)
+	_, err = os.Stat(checksumFile) // created up front, so that a path that can't be written to is found straight away
+	c.Assert(err, chk.IsNil)
+
+	raw.autoDecompress = true
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "cannot be used with decompress")
+
+	raw = getDefaultCopyRawInput("/tmp/source", "https://account.blob.core.windows.net/container")
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+	raw.writeChecksums = checksumFile
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "only supported for downloads")
+
+	raw = getDefaultCopyRawInput("https://account.blob.core.windows.net/container", c.MkDir())
+	raw.fromTo = common.EFromTo.BlobLocal().String()
+	raw.writeChecksums = filepath.Join(c.MkDir(), "no-such-folder", "checksums.md5")
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "cannot write the checksum file")
+}
+
+func (s *copyUtilTestSuite) TestPreservePermissionsOnUploadToAzureFiles(c *chk.C) {
+	raw := getDefaultCopyRawInput(c.MkDir(), "https://account.file.core.windows.net/share")
+	raw.fromTo = common.EFromTo.LocalFile().String()
+	raw.recursive = true
+	raw.preservePermissions = true
+	raw.preserveSMBInfo = true // as the flag defaults to
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.preservePermissions, chk.Equals, common.EPreservePermissionsOption.OwnershipAndACLs())
+	// attributes go along with the permissions, whether they come from Windows or are mapped from POSIX
+	c.Assert(cooked.preserveSMBInfo, chk.Equals, true)
+
+	raw.preserveSMBInfo = false
+	cooked, err = raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.preserveSMBInfo, chk.Equals, false)
+
+	if runtime.GOOS != "windows" {
+		raw = getDefaultCopyRawInput("https://account.file.core.windows.net/share", c.MkDir())
+		raw.fromTo = common.EFromTo.FileLocal().String()
+		raw.recursive = true
+		raw.preservePermissions = true
+		_, err = raw.cook()
+		c.Assert(err, chk.NotNil)
+		c.Assert(err.Error(), StringContains, "persistence for downloads is a Windows-only feature")
+	}
+}
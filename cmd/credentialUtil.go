@@ -26,6 +26,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
@@ -129,6 +130,7 @@ func GetOAuthTokenManagerInstance() (*common.UserOAuthTokenManager, error) {
 // getBlobCredentialType is used to get Blob's credential type when user wishes to use OAuth session mode.
 // The verification logic follows following rules:
 // 1. For source or dest url, if the url contains SAS or SAS is provided standalone, indicating using anonymous credential(SAS).
+// 1a. If an account key was given for the url, indicating using shared key (see sharedKeyGivenFor).
 // 2. If the blob URL can be public access resource, and validated as public resource, indicating using anonymous credential(public resource).
 // 3. If there is cached OAuth token, indicating using token credential.
 // 4. If there is OAuth token info passed from env var, indicating using token credential. (Note: this is only for testing)
@@ -148,6 +150,12 @@ func getBlobCredentialType(ctx context.Context, blobResourceURL string, canBePub
 		return common.ECredentialType.Anonymous(), false, nil
 	}
 
+	if useSharedKey, err := sharedKeyGivenFor(blobResourceURL, common.ELocation.Blob()); err != nil {
+		return common.ECredentialType.Unknown(), false, err
+	} else if useSharedKey {
+		return common.ECredentialType.SharedKey(), false, nil
+	}
+
 	checkPublic := func() (isPublicResource bool) {
 		if !canBePublic { // Cannot possibly be public - like say a destination EP
 			return false
@@ -230,9 +238,9 @@ func getBlobCredentialType(ctx context.Context, blobResourceURL string, canBePub
 // getBlobFSCredentialType is used to get BlobFS's credential type when user wishes to use OAuth session mode.
 // The verification logic follows following rules:
 // 1. Check if there is a SAS query appended to the URL
-// 2. If there is cached session OAuth token, indicating using token credential.
-// 3. If there is OAuth token info passed from env var, indicating using token credential. (Note: this is only for testing)
-// 4. Otherwise use shared key.
+// 2. If an account key was given for the url, indicating using shared key (see sharedKeyGivenFor).
+// 3. If there is cached session OAuth token, indicating using token credential.
+// 4. If there is OAuth token info passed from env var, indicating using token credential. (Note: this is only for testing)
 func getBlobFSCredentialType(ctx context.Context, blobResourceURL string, standaloneSAS bool) (common.CredentialType, error) {
 	resourceURL, err := url.Parse(blobResourceURL)
 	if err != nil {
@@ -246,18 +254,75 @@ func getBlobFSCredentialType(ctx context.Context, blobResourceURL string, standa
 		return common.ECredentialType.Anonymous(), nil
 	}
 
+	if useSharedKey, err := sharedKeyGivenFor(blobResourceURL, common.ELocation.BlobFS()); err != nil {
+		return common.ECredentialType.Unknown(), err
+	} else if useSharedKey {
+		return common.ECredentialType.SharedKey(), nil
+	}
+
 	if oAuthTokenExists() {
 		return common.ECredentialType.OAuthToken(), nil
 	}
 
-	name := glcm.GetEnvironmentVariable(common.EEnvironmentVariable.AccountName())
-	key := glcm.GetEnvironmentVariable(common.EEnvironmentVariable.AccountKey())
-	if name != "" && key != "" { // TODO: To remove, use for internal testing, SharedKey should not be supported from commandline
-		return common.ECredentialType.SharedKey(), nil
-	} else {
-		return common.ECredentialType.Unknown(),
-			common.NewAzError(common.EAzError.LoginCredMissing(), "OAuth token, SAS token, or shared key should be provided for Blob FS")
+	return common.ECredentialType.Unknown(),
+		common.NewAzError(common.EAzError.LoginCredMissing(), "OAuth token, SAS token, or shared key should be provided for Blob FS")
+}
+
+// sharedKeyGivenFor reports whether an account key was given for resourceURL, so that requests to it should be signed with
+// shared key. It decides that the same way for Blob, File and BlobFS: the key is either that of the connection string in
+// AZCOPY_CONNECTION_STRING, when the url is on its blob endpoint, or the one in AZCOPY_ACCOUNT_KEY, when the url is for
+// the account in AZCOPY_ACCOUNT_NAME.
+func sharedKeyGivenFor(resourceURL string, location common.Location) (bool, error) {
+	if location == common.ELocation.Blob() {
+		if covered, err := connectionStringCoversBlobURL(resourceURL); err != nil || covered {
+			return covered, err
+		}
+	}
+
+	accountName, err := sharedKeyAccountName(resourceURL, location)
+	if err != nil {
+		return false, err
 	}
+	key, err := common.AccountKeyFromEnvironment(accountName)
+	return key != "", err
+}
+
+// sharedKeyAccountName returns the name of the account whose key signs requests to resourceURL.
+// That's the account of the connection string for its blob endpoint, or else the account named in the url,
+// either as the first label of the host, or as the first path segment for an IP-style url (e.g. a storage emulator).
+func sharedKeyAccountName(resourceURL string, location common.Location) (string, error) {
+	if location == common.ELocation.Blob() {
+		cs, isSet, err := common.GetStorageConnectionString()
+		if err != nil {
+			return "", err
+		}
+		if isSet && cs.CoversBlobURL(resourceURL) {
+			return cs.AccountName, nil
+		}
+	}
+	return accountNameInURL(resourceURL)
+}
+
+// accountNameInURL returns the name of the storage account that resourceURL is in. That's the first label of the host,
+// or, for an IP address such as the emulator's, the first segment of the path.
+func accountNameInURL(resourceURL string) (string, error) {
+	u, err := url.Parse(resourceURL)
+	if err != nil {
+		return "", fmt.Errorf("cannot find the account name in %s: %w", resourceURL, err)
+	}
+	if net.ParseIP(u.Hostname()) != nil {
+		return strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 2)[0], nil
+	}
+	return strings.SplitN(u.Hostname(), ".", 2)[0], nil
+}
+
+// connectionStringCoversBlobURL reports whether the account key of the connection string in AZCOPY_CONNECTION_STRING is for blobResourceURL
+func connectionStringCoversBlobURL(blobResourceURL string) (bool, error) {
+	cs, isSet, err := common.GetStorageConnectionString()
+	if err != nil || !isSet {
+		return false, err
+	}
+	return cs.CoversBlobURL(blobResourceURL), nil
 }
 
 var announceOAuthTokenOnce sync.Once
@@ -286,9 +351,14 @@ func oAuthTokenExists() (oauthTokenExists bool) {
 	return
 }
 
-// getAzureFileCredentialType is used to get Azure file's credential type
-func getAzureFileCredentialType() (common.CredentialType, error) {
-	// Azure file only support anonymous credential currently.
+// getAzureFileCredentialType is used to get Azure file's credential type.
+// Azure file supports shared key, when an account key was given for the url, and otherwise anonymous credential (SAS).
+func getAzureFileCredentialType(fileResourceURL string) (common.CredentialType, error) {
+	if useSharedKey, err := sharedKeyGivenFor(fileResourceURL, common.ELocation.File()); err != nil {
+		return common.ECredentialType.Unknown(), err
+	} else if useSharedKey {
+		return common.ECredentialType.SharedKey(), nil
+	}
 	return common.ECredentialType.Anonymous(), nil
 }
 
@@ -368,6 +438,13 @@ func checkAuthSafeForTarget(ct common.CredentialType, resource, extraSuffixesAAD
 		return nil
 	case common.ECredentialType.OAuthToken(),
 		common.ECredentialType.SharedKey():
+		if ct == common.ECredentialType.SharedKey() && resourceType == common.ELocation.Blob() {
+			// the user chose this endpoint, e.g. a local storage emulator, by giving its connection string
+			if covered, _ := connectionStringCoversBlobURL(resource); covered {
+				return nil
+			}
+		}
+
 		// Files doesn't currently support OAuth, but it's a valid azure endpoint anyway, so it'll pass the check.
 		if resourceType != common.ELocation.Blob() && resourceType != common.ELocation.BlobFS() && resourceType != common.ELocation.File() {
 			// There may be a reason for files->blob to specify this.
@@ -493,7 +570,7 @@ func doGetCredentialTypeForLocation(ctx context.Context, location common.Locatio
 				return common.ECredentialType.Unknown(), false, err
 			}
 		case common.ELocation.File():
-			if credType, err = getAzureFileCredentialType(); err != nil {
+			if credType, err = getAzureFileCredentialType(resource); err != nil {
 				return common.ECredentialType.Unknown(), false, err
 			}
 		case common.ELocation.BlobFS():
@@ -547,6 +624,11 @@ func GetCredentialInfoForLocation(ctx context.Context, location common.Location,
 		} else {
 			credInfo.OAuthTokenInfo = *tokenInfo
 		}
+	} else if credInfo.CredentialType == common.ECredentialType.SharedKey() {
+		// the account name travels with the credential info, but the key is read from the environment when the credential is made
+		if credInfo.SharedKeyAccountName, err = sharedKeyAccountName(resource, location); err != nil {
+			return credInfo, false, err
+		}
 	} else if credInfo.CredentialType == common.ECredentialType.S3AccessKey() || credInfo.CredentialType == common.ECredentialType.S3PublicBucket() {
 		// nothing to do here. The extra fields for S3 are fleshed out at the time
 		// we make the S3Client
@@ -659,7 +741,6 @@ func createBlobFSPipeline(ctx context.Context, credInfo common.CredentialInfo, l
 	), nil
 }
 
-// TODO note: ctx and credInfo are ignored at the moment because we only support SAS for Azure File
 func createFilePipeline(ctx context.Context, credInfo common.CredentialInfo, logLevel pipeline.LogLevel) (pipeline.Pipeline, error) {
 	logOption := pipeline.LogOptions{}
 	if azcopyScanningLogger != nil {
@@ -669,8 +750,13 @@ func createFilePipeline(ctx context.Context, credInfo common.CredentialInfo, log
 		}
 	}
 
+	credential := common.CreateFileCredential(ctx, credInfo, common.CredentialOpOptions{
+		//LogInfo:  glcm.Info, //Comment out for debugging
+		LogError: glcm.Info,
+	})
+
 	return ste.NewFilePipeline(
-		azfile.NewAnonymousCredential(),
+		credential,
 		azfile.PipelineOptions{
 			Telemetry: azfile.TelemetryOptions{
 				Value: glcm.AddUserAgentPrefix(common.UserAgent),
@@ -44,6 +44,9 @@ The built-in lookup table is small, but on Unix, it is augmented by the local sy
 
 On Windows, MIME types are extracted from the registry. This feature can be turned off with the help of a flag. Please refer to the flag section.
 
+The exit code says how the job went: 0 if every transfer succeeded or was skipped, 1 if some transfers failed, 2 if transfers failed and none succeeded,
+3 if the job was cancelled, and 4 if the command line was not valid. If AzCopy crashes, it also exits with 2, but then the panic is printed to stderr.
+
 ` + environmentVariableNotice
 
 const copyCmdExample = `Upload a single file by using OAuth authentication. If you have not yet logged into AzCopy, please run the azcopy login command before you run the following command.
@@ -110,14 +113,19 @@ Download an entire directory by using a SAS token:
 
 A note about using a wildcard character (*) in URLs:
 
-There's only two supported ways to use a wildcard character in a URL. 
+There's only three supported ways to use a wildcard character in a URL. 
 - You can use one just after the final forward slash (/) of a URL. This copies all of the files in a directory directly to the destination without placing them into a subdirectory. 
 - You can also use one in the name of a container as long as the URL refers only to a container and not to a blob. You can use this approach to obtain files from a subset of containers. 
+- When the source is Blob Storage, you can use them anywhere in the blob name. A * matches within one virtual directory and a ** matches across virtual directories. The matching blobs are placed in the destination relative to the virtual directory that holds the first wildcard. 
 
 Download the contents of a directory without copying the containing directory itself.
  
   - azcopy cp "https://[srcaccount].blob.core.windows.net/[container]/[path/to/folder]/*?[SAS]" "/path/to/dir"
 
+Download the blobs in a directory whose names match a wildcard pattern.
+
+  - azcopy cp "https://[srcaccount].blob.core.windows.net/[container]/[path/to/folder]/2023-*?[SAS]" "/path/to/dir"
+
 Download an entire storage account.
 
   - azcopy cp "https://[srcaccount].blob.core.windows.net/" "/path/to/dir" --recursive
@@ -225,7 +233,9 @@ const showJobsCmdShortDescription = "Show detailed information for the given job
 const showJobsCmdLongDescription = `
 If you provide only a job ID, and not a flag, then this command returns the progress summary only.
 The byte counts and percent complete that appears when you run this command reflect only files that are completed in the job. They don't reflect partially completed files.
-If you set the with-status flag, then only the list of transfers associated with the given status appear.`
+If you set the with-status flag, then only the list of transfers associated with the given status appear.
+If you set the grep flag, then only the transfers whose source, destination or failure reason contains the given text appear. For example, use --with-status=Failed --grep=403 to find the transfers that were refused.
+If you set the watch flag, then the progress summary is refreshed on a single line until the job finishes, followed by the full summary.`
 
 const resumeJobsCmdShortDescription = "Resume the existing job with the given job ID."
 
@@ -235,7 +245,8 @@ Resume the existing job with the given job ID.`
 const removeJobsCmdShortDescription = "Remove all files associated with the given job ID."
 
 const removeJobsCmdLongDescription = `
-Remove all files associated with the given job ID.
+Remove all files associated with the given job ID. A job that is still in progress cannot be removed, unless --force is given.
+Use that for a job whose AzCopy process was killed, as the job then stays in progress forever.
 
 Note that you can customize the location where log and plan files are saved. See the env command to learn more.`
 
@@ -244,9 +255,16 @@ const removeJobsCmdExample = "  azcopy jobs rm e52247de-0323-b14d-4cc8-76e0be2e2
 const cleanJobsCmdShortDescription = "Remove all log and plan files for all jobs"
 
 const cleanJobsCmdLongDescription = `
+Remove the log and plan files of all jobs, or of the jobs with the given status and age, and report the space reclaimed.
+Jobs that are still in progress are skipped, unless --force is given, e.g. to remove the jobs of AzCopy processes that were killed.
+
 Note that you can customize the location where log and plan files are saved. See the env command to learn more.`
 
-const cleanJobsCmdExample = "  azcopy jobs clean --with-status=completed"
+const cleanJobsCmdExample = `  azcopy jobs clean --with-status=completed
+
+Remove the files of the jobs that started more than a week ago:
+
+  - azcopy jobs clean --since=168h`
 
 // ===================================== LIST COMMAND ===================================== //
 const listCmdShortDescription = "List the entities in a given resource"
@@ -257,6 +275,25 @@ const listCmdExample = "azcopy list [containerURL] --properties [semicolon(;) se
 	"(LastModifiedTime, VersionId, BlobType, BlobAccessTier, ContentType, ContentEncoding, LeaseState, LeaseDuration, LeaseStatus) " +
 	"enclosed in double quotes (\")]"
 
+// ===================================== VERIFY COMMAND ===================================== //
+const verifyCmdShortDescription = "Compare local files with their blobs, without transferring any data"
+
+const verifyCmdLongDescription = `
+Compare the files in a local directory with the blobs in a container or virtual directory, in the same way that sync pairs them up.
+For each file, the size of the blob is compared with the size of the file and, if the blob has a Content-MD5 (for example,
+because it was uploaded with --put-md5), the MD5 hash of the file is computed and compared with it.
+
+Files that are missing at the destination, or that don't match their blob, are listed, followed by a summary of the counts.
+The exit code is non-zero if any file is missing or doesn't match.`
+
+const verifyCmdExample = `Verify that the contents of a directory were uploaded to a container:
+
+  - azcopy verify "/path/to/dir" "https://[account].blob.core.windows.net/[container]?[SAS]"
+
+Verify a single file:
+
+  - azcopy verify "/path/to/file.txt" "https://[account].blob.core.windows.net/[container]/[path/to/blob]?[SAS]"`
+
 // ===================================== LOGIN COMMAND ===================================== //
 const loginCmdShortDescription = "Log in to Azure Active Directory (AD) to access Azure Storage resources."
 
@@ -488,10 +525,11 @@ The benchmark command runs the same process as 'copy', except that:
   
   - Only a few of the optional parameters that are available to the copy command are supported.
   
-  - Additional diagnostics are measured and reported.
+  - Additional diagnostics are measured and reported: the average throughput over the whole run, IOPS, the end-to-end time
+    per request (i.e. per chunk, for uploads), and the number of retries.
   
-  - For uploads, the default behaviour is to delete the transferred data at the end of the test run.  For downloads, the data
-    is never actually saved locally.
+  - For uploads, the default behaviour is to delete the transferred data at the end of the test run, unless --keep is given.
+    For downloads, the data is never actually saved locally.
 
 Benchmark mode will automatically tune itself to the number of parallel TCP connections that gives 
 the maximum throughput. It will display that number at the end. To prevent auto-tuning, set the 
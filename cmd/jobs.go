@@ -21,7 +21,14 @@
 package cmd
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
 	"github.com/spf13/cobra"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
 )
 
 // jobs command is used to encapsulate all sub-commands related to managing jobs
@@ -38,3 +45,41 @@ func init() {
 	// add jobs command as a top level command
 	rootCmd.AddCommand(jobsCmd)
 }
+
+// exitWithOutput ends a jobs sub-command with the given output, as glcm.Exit does. If outputFile is given, the output
+// goes to that file instead of stdout, which is easier for scripts to capture than a shell redirect (notably on Windows).
+// Either way, the exit code is the same.
+func exitWithOutput(outputFile string, o common.OutputBuilder, applicationExitCode common.ExitCode) {
+	if outputFile == "" {
+		glcm.Exit(o, applicationExitCode)
+		return
+	}
+
+	if err := writeFileAtomically(outputFile, []byte(o(azcopyOutputFormat)+"\n")); err != nil {
+		glcm.Error(fmt.Sprintf("failed to write the output to %s: %s", outputFile, err))
+		return
+	}
+	glcm.Exit(nil, applicationExitCode)
+}
+
+// writeFileAtomically creates or replaces the file with the given content. The content is written to a temporary file
+// alongside it, which is then renamed, so that nobody ever sees a partly-written file.
+func writeFileAtomically(path string, content []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // only has anything to remove if we fail before the rename
+
+	if _, err = tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), 0644); err != nil { // temporary files are created private, but this is ordinary output
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
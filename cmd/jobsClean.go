@@ -21,9 +21,10 @@
 package cmd
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
-	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -33,6 +34,8 @@ import (
 func init() {
 	type JobsCleanReq struct {
 		withStatus string
+		since      time.Duration
+		force      bool
 	}
 
 	commandLineInput := JobsCleanReq{}
@@ -56,18 +59,20 @@ func init() {
 			if err != nil {
 				glcm.Error(fmt.Sprintf("Failed to parse --with-status due to error: %s.", err))
 			}
+			if commandLineInput.since < 0 {
+				glcm.Error("--since cannot be negative.")
+			}
 
-			err = handleCleanJobsCommand(withStatus)
+			resp, err := handleCleanJobsCommand(withStatus, commandLineInput.since, commandLineInput.force)
 			if err == nil {
-				if withStatus == common.EJobStatus.All() {
-					glcm.Exit(func(format common.OutputFormat) string {
-						return fmt.Sprintf("Successfully removed all jobs.")
-					}, common.EExitCode.Success())
-				} else {
-					glcm.Exit(func(format common.OutputFormat) string {
-						return fmt.Sprintf("Successfully removed jobs with status: %s.", withStatus)
-					}, common.EExitCode.Success())
-				}
+				glcm.Exit(func(format common.OutputFormat) string {
+					if format == common.EOutputFormat.Json() {
+						jsonOutput, err := json.Marshal(resp)
+						common.PanicIfErr(err)
+						return string(jsonOutput)
+					}
+					return formatRemovedJobFiles(resp)
+				}, common.EExitCode.Success())
 			} else {
 				glcm.Error(fmt.Sprintf("Failed to remove log/plan files due to error: %s.", err))
 			}
@@ -80,56 +85,12 @@ func init() {
 	jobsCleanCmd.PersistentFlags().StringVar(&commandLineInput.withStatus, "with-status", "All",
 		"only remove the jobs with this status, available values: All, Cancelled, Failed, Completed"+
 			" CompletedWithErrors, CompletedWithSkipped, CompletedWithErrorsAndSkipped")
+	jobsCleanCmd.PersistentFlags().DurationVar(&commandLineInput.since, "since", 0,
+		"only remove the jobs that started at least this long ago, for example 168h for a week. By default, jobs of any age are removed")
+	jobsCleanCmd.PersistentFlags().BoolVar(&commandLineInput.force, "force", false,
+		"also remove the jobs that are still in progress, e.g. because the AzCopy process running them was killed. Jobs that the transfer engine itself is running are never removed")
 }
 
-func handleCleanJobsCommand(givenStatus common.JobStatus) error {
-	if givenStatus == common.EJobStatus.All() {
-		numFilesDeleted, err := blindDeleteAllJobFiles()
-		glcm.Info(fmt.Sprintf("Removed %v files.", numFilesDeleted))
-		return err
-	}
-
-	// we must query the jobs and find out which one to remove
-	resp := common.ListJobsResponse{}
-	Rpc(common.ERpcCmd.ListJobs(), common.EJobStatus.All(), &resp)
-
-	if resp.ErrorMessage != "" {
-		return errors.New("failed to query the list of jobs")
-	}
-
-	for _, job := range resp.JobIDDetails {
-		// delete all jobs matching the givenStatus
-		if job.JobStatus == givenStatus {
-			glcm.Info(fmt.Sprintf("Removing files for job %s", job.JobId))
-			err := handleRemoveSingleJob(job.JobId)
-			if err != nil {
-				return err
-			}
-		}
-	}
-
-	return nil
-}
-
-func blindDeleteAllJobFiles() (int, error) {
-	// get rid of the job plan files
-	numPlanFilesRemoved, err := removeFilesWithPredicate(azcopyJobPlanFolder, func(s string) bool {
-		if strings.Contains(s, ".steV") {
-			return true
-		}
-		return false
-	})
-	if err != nil {
-		return numPlanFilesRemoved, err
-	}
-
-	// get rid of the logs
-	numLogFilesRemoved, err := removeFilesWithPredicate(azcopyLogPathFolder, func(s string) bool {
-		if strings.HasSuffix(s, ".log") {
-			return true
-		}
-		return false
-	})
-
-	return numPlanFilesRemoved + numLogFilesRemoved, err
+func handleCleanJobsCommand(givenStatus common.JobStatus, since time.Duration, force bool) (common.RemoveJobFilesResponse, error) {
+	return removeJobFiles(common.RemoveJobFilesRequest{WithStatus: givenStatus, OlderThan: since, Force: force})
 }
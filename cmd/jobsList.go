@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/Azure/azure-storage-azcopy/v10/common"
@@ -38,6 +39,8 @@ type ListResponse struct {
 func init() {
 	type JobsListReq struct {
 		withStatus string
+		sortBy     string
+		outputFile string
 	}
 
 	commandLineInput := JobsListReq{}
@@ -63,8 +66,12 @@ func init() {
 			if err != nil {
 				glcm.Error(fmt.Sprintf("Failed to parse --with-status due to error: %s.", err))
 			}
+			sortBy := strings.ToLower(commandLineInput.sortBy)
+			if sortBy != jobsSortByDate && sortBy != jobsSortByStatus {
+				glcm.Error(fmt.Sprintf("Invalid --sort-by value '%s', available values: %s, %s.", commandLineInput.sortBy, jobsSortByDate, jobsSortByStatus))
+			}
 
-			err = HandleListJobsCommand(withStatus)
+			err = HandleListJobsCommand(withStatus, sortBy, commandLineInput.outputFile)
 			if err == nil {
 				glcm.Exit(nil, common.EExitCode.Success())
 			} else {
@@ -78,52 +85,73 @@ func init() {
 	lsCmd.PersistentFlags().StringVar(&commandLineInput.withStatus, "with-status", "All",
 		"List the jobs with given status, available values: All, Cancelled, Failed, InProgress, Completed,"+
 			" CompletedWithErrors, CompletedWithFailures, CompletedWithErrorsAndSkipped")
+	lsCmd.PersistentFlags().StringVar(&commandLineInput.sortBy, "sort-by", jobsSortByDate,
+		"Order in which to list the jobs, available values: date (most recent first), status (in progress first, then by date)")
+	lsCmd.PersistentFlags().StringVar(&commandLineInput.outputFile, "output-file", "",
+		"Write the list of jobs to this file, in the chosen --output-type, instead of to the console. The file is created or replaced.")
 }
 
+const (
+	jobsSortByDate   = "date"
+	jobsSortByStatus = "status"
+)
+
 // HandleListJobsCommand sends the ListJobs request to transfer engine
-// Print the Jobs in the history of Azcopy
-func HandleListJobsCommand(jobStatus common.JobStatus) error {
+// Print the Jobs in the history of Azcopy, to outputFile if one is given
+func HandleListJobsCommand(jobStatus common.JobStatus, sortBy string, outputFile string) error {
 	resp := common.ListJobsResponse{}
 	Rpc(common.ERpcCmd.ListJobs(), jobStatus, &resp)
-	return PrintExistingJobIds(resp)
+	return PrintExistingJobIds(resp, sortBy, outputFile)
 }
 
 // PrintExistingJobIds prints the response of listOrder command when listOrder command requested the list of existing jobs
-func PrintExistingJobIds(listJobResponse common.ListJobsResponse) error {
+func PrintExistingJobIds(listJobResponse common.ListJobsResponse, sortBy string, outputFile string) error {
 	if listJobResponse.ErrorMessage != "" {
 		return fmt.Errorf("request failed with following error message: %s", listJobResponse.ErrorMessage)
 	}
 
 	// before displaying the jobs, sort them accordingly so that they are displayed in a consistent way
-	sortJobs(listJobResponse.JobIDDetails)
+	sortJobs(listJobResponse.JobIDDetails, sortBy)
 
-	glcm.Exit(func(format common.OutputFormat) string {
+	exitWithOutput(outputFile, func(format common.OutputFormat) string {
 		if format == common.EOutputFormat.Json() {
 			jsonOutput, err := json.Marshal(listJobResponse)
 			common.PanicIfErr(err)
 			return string(jsonOutput)
 		}
 
-		var sb strings.Builder
-		sb.WriteString("Existing Jobs \n")
-		for index := 0; index < len(listJobResponse.JobIDDetails); index++ {
-			jobDetail := listJobResponse.JobIDDetails[index]
-			sb.WriteString(fmt.Sprintf("JobId: %s\nStart Time: %s\nStatus: %s\nCommand: %s\n\n",
-				jobDetail.JobId.String(),
-				time.Unix(0, jobDetail.StartTime).Format(time.RFC850),
-				jobDetail.JobStatus,
-				jobDetail.CommandString))
-		}
-		return sb.String()
+		return formatJobsTable(listJobResponse.JobIDDetails)
 	}, common.EExitCode.Success())
 	return nil
 }
 
-func sortJobs(jobsDetails []common.JobIDDetails) {
-	// sort the jobs so that the latest one is shown first
+// formatJobsTable lays the jobs out one per row, with the columns aligned
+func formatJobsTable(jobsDetails []common.JobIDDetails) string {
+	var sb strings.Builder
+	sb.WriteString("Existing Jobs \n")
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "JobId\tStart Time\tStatus\tTransfers\tCommand")
+	for _, jobDetail := range jobsDetails {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n",
+			jobDetail.JobId.String(),
+			time.Unix(0, jobDetail.StartTime).Format(time.RFC850),
+			jobDetail.JobStatus,
+			jobDetail.TotalTransfers,
+			jobDetail.CommandString)
+	}
+	common.PanicIfErr(w.Flush())
+	return sb.String()
+}
+
+func sortJobs(jobsDetails []common.JobIDDetails, sortBy string) {
+	// sort the jobs so that the latest one is shown first, within each status if sorting by status
 	sort.Slice(jobsDetails, func(i, j int) bool {
 		// this function essentially asks whether i should be placed before j
-		// we say yes if the job i is more recent
+		// when sorting by status, we say yes if job i's status comes first (in progress jobs are listed first),
+		// otherwise we say yes if the job i is more recent
+		if sortBy == jobsSortByStatus && jobsDetails[i].JobStatus != jobsDetails[j].JobStatus {
+			return jobsDetails[i].JobStatus < jobsDetails[j].JobStatus
+		}
 		return jobsDetails[i].StartTime > jobsDetails[j].StartTime
 	})
 }
@@ -21,6 +21,7 @@
 package cmd
 
 import (
+	"strings"
 	"time"
 
 	"github.com/Azure/azure-storage-azcopy/v10/common"
@@ -58,10 +59,57 @@ func (s *jobsListTestSuite) TestSortJobs(c *chk.C) {
 	jobsList := []common.JobIDDetails{job2, job1, job0}
 
 	// act
-	sortJobs(jobsList)
+	sortJobs(jobsList, jobsSortByDate)
 
 	// verify
 	c.Assert(jobsList[0], chk.DeepEquals, job0)
 	c.Assert(jobsList[1], chk.DeepEquals, job1)
 	c.Assert(jobsList[2], chk.DeepEquals, job2)
 }
+
+func (s *jobsListTestSuite) TestSortJobsByStatus(c *chk.C) {
+	// setup
+	now := time.Now()
+	oldFailed := common.JobIDDetails{JobId: common.NewJobID(), StartTime: now.Add(-3 * time.Hour).UnixNano(), JobStatus: common.EJobStatus.Failed()}
+	newCompleted := common.JobIDDetails{JobId: common.NewJobID(), StartTime: now.Add(-1 * time.Hour).UnixNano(), JobStatus: common.EJobStatus.Completed()}
+	oldCompleted := common.JobIDDetails{JobId: common.NewJobID(), StartTime: now.Add(-2 * time.Hour).UnixNano(), JobStatus: common.EJobStatus.Completed()}
+	inProgress := common.JobIDDetails{JobId: common.NewJobID(), StartTime: now.Add(-4 * time.Hour).UnixNano(), JobStatus: common.EJobStatus.InProgress()}
+	jobsList := []common.JobIDDetails{oldFailed, oldCompleted, inProgress, newCompleted}
+
+	// act
+	sortJobs(jobsList, jobsSortByStatus)
+
+	// verify: in progress jobs first, and the most recent first among jobs with the same status
+	c.Assert(jobsList[0], chk.DeepEquals, inProgress)
+	c.Assert(jobsList[1], chk.DeepEquals, newCompleted)
+	c.Assert(jobsList[2], chk.DeepEquals, oldCompleted)
+	c.Assert(jobsList[3], chk.DeepEquals, oldFailed)
+
+	// sorting by date ignores the status
+	sortJobs(jobsList, jobsSortByDate)
+	c.Assert(jobsList[0], chk.DeepEquals, newCompleted)
+	c.Assert(jobsList[1], chk.DeepEquals, oldCompleted)
+	c.Assert(jobsList[2], chk.DeepEquals, oldFailed)
+	c.Assert(jobsList[3], chk.DeepEquals, inProgress)
+}
+
+func (s *jobsListTestSuite) TestFormatJobsTable(c *chk.C) {
+	job := common.JobIDDetails{
+		JobId:          common.NewJobID(),
+		StartTime:      time.Now().UnixNano(),
+		JobStatus:      common.EJobStatus.CompletedWithErrors(),
+		TotalTransfers: 1234,
+		CommandString:  "copy a b",
+	}
+
+	lines := strings.Split(strings.TrimRight(formatJobsTable([]common.JobIDDetails{job}), "\n"), "\n")
+	c.Assert(lines, chk.HasLen, 3)
+	header, row := lines[1], lines[2]
+
+	// every column of the row starts where its heading does
+	for i, heading := range []string{"Start Time", "Status", "Transfers", "Command"} {
+		value := []string{time.Unix(0, job.StartTime).Format(time.RFC850), "CompletedWithErrors", "1234", "copy a b"}[i]
+		c.Assert(strings.Index(row, value), chk.Equals, strings.Index(header, heading))
+	}
+	c.Assert(strings.HasPrefix(row, job.JobId.String()), chk.Equals, true)
+}
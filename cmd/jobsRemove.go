@@ -21,11 +21,9 @@
 package cmd
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
-	"os"
-	"path"
 	"strings"
 
 	"github.com/Azure/azure-storage-azcopy/v10/common"
@@ -35,6 +33,7 @@ import (
 func init() {
 	type JobsRemoveReq struct {
 		JobID common.JobID
+		force bool
 	}
 
 	commandLineInput := JobsRemoveReq{}
@@ -59,10 +58,16 @@ func init() {
 			return nil
 		},
 		Run: func(cmd *cobra.Command, args []string) {
-			err := handleRemoveSingleJob(commandLineInput.JobID)
+			resp, err := handleRemoveSingleJob(commandLineInput.JobID, commandLineInput.force)
 			if err == nil {
 				glcm.Exit(func(format common.OutputFormat) string {
-					return fmt.Sprintf("Successfully removed log and job plan files for job %s.", commandLineInput.JobID)
+					if format == common.EOutputFormat.Json() {
+						jsonOutput, err := json.Marshal(resp)
+						common.PanicIfErr(err)
+						return string(jsonOutput)
+					}
+					return fmt.Sprintf("Successfully removed log and job plan files for job %s, reclaiming %s.",
+						commandLineInput.JobID, byteSizeToString(resp.BytesReclaimed))
 				}, common.EExitCode.Success())
 			} else {
 				glcm.Error(fmt.Sprintf("Failed to remove log and job plan files for job %s due to error: %s.", commandLineInput.JobID, err))
@@ -71,58 +76,32 @@ func init() {
 	}
 
 	jobsCmd.AddCommand(jobsRemoveCmd)
-}
-
-func handleRemoveSingleJob(jobID common.JobID) error {
-	// get rid of the job plan files
-	numPlanFileRemoved, err := removeFilesWithPredicate(azcopyJobPlanFolder, func(s string) bool {
-		if strings.Contains(s, jobID.String()) && strings.Contains(s, ".steV") {
-			return true
-		}
-		return false
-	})
-	if err != nil {
-		return err
-	}
 
-	// get rid of the logs
-	// even though we only have 1 file right now, still scan the directory since we may change the
-	// way we name the logs in the future (with suffix or whatnot)
-	numLogFileRemoved, err := removeFilesWithPredicate(azcopyLogPathFolder, func(s string) bool {
-		if strings.Contains(s, jobID.String()) && strings.HasSuffix(s, ".log") {
-			return true
-		}
-		return false
-	})
-	if err != nil {
-		return err
-	}
-
-	if numLogFileRemoved+numPlanFileRemoved == 0 {
-		return errors.New("cannot find any log or job plan file with the specified ID")
-	}
+	jobsRemoveCmd.PersistentFlags().BoolVar(&commandLineInput.force, "force", false,
+		"remove the job's files even if it is still in progress, e.g. because the AzCopy process running it was killed. A job that the transfer engine itself is running is never removed")
+}
 
-	return nil
+func handleRemoveSingleJob(jobID common.JobID, force bool) (common.RemoveJobFilesResponse, error) {
+	return removeJobFiles(common.RemoveJobFilesRequest{JobID: jobID, WithStatus: common.EJobStatus.All(), Force: force})
 }
 
-// remove all files whose names are approved by the predicate in the targetFolder
-func removeFilesWithPredicate(targetFolder string, predicate func(string) bool) (int, error) {
-	count := 0
-	files, err := ioutil.ReadDir(targetFolder)
-	if err != nil {
-		return count, err
+// removeJobFiles asks the STE to remove the log and plan files of the requested jobs
+func removeJobFiles(req common.RemoveJobFilesRequest) (common.RemoveJobFilesResponse, error) {
+	resp := common.RemoveJobFilesResponse{}
+	Rpc(common.ERpcCmd.RemoveJobFiles(), &req, &resp)
+	if resp.ErrorMsg != "" {
+		return resp, errors.New(resp.ErrorMsg)
 	}
+	return resp, nil
+}
 
-	// go through the files and return if any of them fail to be removed
-	for _, singleFile := range files {
-		if predicate(singleFile.Name()) {
-			err := os.Remove(path.Join(targetFolder, singleFile.Name()))
-			if err != nil {
-				return count, err
-			}
-			count += 1
-		}
+// formatRemovedJobFiles describes what a removal reclaimed, and which jobs were left alone as they are still running
+func formatRemovedJobFiles(resp common.RemoveJobFilesResponse) string {
+	lines := make([]string, 0, len(resp.SkippedRunningJobs)+1)
+	for _, jobID := range resp.SkippedRunningJobs {
+		lines = append(lines, fmt.Sprintf("Skipped job %s as it is still in progress. If the AzCopy process running it was killed, use --force to remove it.", jobID))
 	}
-
-	return count, nil
+	lines = append(lines, fmt.Sprintf("Removed %d job(s) and %d file(s), reclaiming %s.",
+		len(resp.RemovedJobs), resp.FilesRemoved, byteSizeToString(resp.BytesReclaimed)))
+	return strings.Join(lines, "\n")
 }
@@ -80,6 +80,12 @@ func (cca *resumeJobController) Cancel(lcm common.LifecycleMgr) {
 	}
 }
 
+func (cca *resumeJobController) Pause(lcm common.LifecycleMgr) {
+	if err := pauseJob(cca.jobID); err != nil {
+		lcm.Error("error occurred while pausing the job " + cca.jobID.String() + ". Failed with error " + err.Error())
+	}
+}
+
 // TODO: can we combine this with the copy one (and the sync one?)
 func (cca *resumeJobController) ReportProgressOrExit(lcm common.LifecycleMgr) (totalKnownCount uint32) {
 	// fetch a job status
@@ -88,17 +94,14 @@ func (cca *resumeJobController) ReportProgressOrExit(lcm common.LifecycleMgr) (t
 	glcmSwapOnce.Do(func() {
 		Rpc(common.ERpcCmd.GetJobLCMWrapper(), &cca.jobID, &glcm)
 	})
-	jobDone := summary.JobStatus.IsJobDone()
+	jobDone := isJobDoneOrPaused(summary.JobStatus)
 	totalKnownCount = summary.TotalTransfers
 
 	// if json is not desired, and job is done, then we generate a special end message to conclude the job
 	duration := time.Now().Sub(cca.jobStartTime) // report the total run time of the job
 
 	if jobDone {
-		exitCode := common.EExitCode.Success()
-		if summary.TransfersFailed > 0 {
-			exitCode = common.EExitCode.Error()
-		}
+		exitCode := jobExitCode(summary, common.EExitCode.Success())
 
 		lcm.Exit(func(format common.OutputFormat) string {
 			if format == common.EOutputFormat.Json() {
@@ -117,7 +120,7 @@ func (cca *resumeJobController) ReportProgressOrExit(lcm common.LifecycleMgr) (t
 					summary.TransfersFailed,
 					summary.TransfersSkipped,
 					summary.TotalBytesTransferred,
-					summary.JobStatus)
+					summary.JobStatus) + pausedJobHint(summary)
 			}
 		}, exitCode)
 	}
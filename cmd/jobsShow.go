@@ -23,17 +23,25 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"os"
+	"os/signal"
 	"strings"
+	"time"
 
 	"encoding/json"
 
 	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-azcopy/v10/ste"
 	"github.com/spf13/cobra"
 )
 
 type ListReq struct {
-	JobID    common.JobID
-	OfStatus string
+	JobID         common.JobID
+	OfStatus      string
+	Grep          string
+	Watch         bool
+	WatchInterval time.Duration
+	OutputFile    string
 }
 
 func init() {
@@ -60,8 +68,25 @@ func init() {
 			listRequest := common.ListRequest{}
 			listRequest.JobID = commandLineInput.JobID
 			listRequest.OfStatus = commandLineInput.OfStatus
+			listRequest.Grep = commandLineInput.Grep
 
-			err := HandleShowCommand(listRequest)
+			if commandLineInput.Watch {
+				if err := validateWatchFlags(commandLineInput, azcopyOutputFormat); err != nil {
+					glcm.Error(err.Error())
+				}
+				stop := make(chan os.Signal, 1)
+				signal.Notify(stop, os.Interrupt)
+				defer signal.Stop(stop)
+				summary := watchJobProgressSummary(commandLineInput.WatchInterval, func() common.ListJobSummaryResponse {
+					resp := common.ListJobSummaryResponse{}
+					Rpc(common.ERpcCmd.ListJobSummary(), &listRequest.JobID, &resp)
+					return resp
+				}, glcm.Progress, stop)
+				PrintJobProgressSummary(summary, commandLineInput.OutputFile)
+				return
+			}
+
+			err := HandleShowCommand(listRequest, commandLineInput.OutputFile)
 			if err == nil {
 				glcm.Exit(nil, common.EExitCode.Success())
 			} else {
@@ -74,20 +99,84 @@ func init() {
 
 	// filters
 	shJob.PersistentFlags().StringVar(&commandLineInput.OfStatus, "with-status", "", "Only list the transfers of job with this status, available values: Started, Success, Failed.")
+	shJob.PersistentFlags().StringVar(&commandLineInput.Grep, "grep", "", "Only list the transfers whose source, destination or failure reason contains this text, for example 403. "+
+		"Can be combined with --with-status. Without it, transfers of any status are listed.")
+	shJob.PersistentFlags().BoolVar(&commandLineInput.Watch, "watch", false, "Keep refreshing the progress summary on a single line until the job is finished, or Ctrl-C is pressed. Cannot be used with --output-type=json.")
+	shJob.PersistentFlags().DurationVar(&commandLineInput.WatchInterval, "watch-interval", 2*time.Second, "How often to refresh the progress summary when using --watch.")
+	shJob.PersistentFlags().StringVar(&commandLineInput.OutputFile, "output-file", "", "Write the summary or list of transfers to this file, in the chosen --output-type, instead of to the console. The file is created or replaced.")
+}
+
+func validateWatchFlags(input ListReq, format common.OutputFormat) error {
+	if input.OfStatus != "" {
+		return errors.New("--watch cannot be used with --with-status")
+	}
+	if input.Grep != "" {
+		return errors.New("--watch cannot be used with --grep")
+	}
+	if format == common.EOutputFormat.Json() {
+		return errors.New("--watch cannot be used with --output-type=json, since it redraws a line of text")
+	}
+	if input.WatchInterval <= 0 {
+		return errors.New("--watch-interval must be greater than zero")
+	}
+	return nil
+}
+
+// watchJobProgressSummary polls for the job's progress summary every interval, and reports each one as progress
+// (which redraws a single line), until the job is finished or a signal arrives on stop. It returns the last summary
+func watchJobProgressSummary(interval time.Duration, getSummary func() common.ListJobSummaryResponse,
+	progress func(common.OutputBuilder), stop <-chan os.Signal) common.ListJobSummaryResponse {
+	for {
+		summary := getSummary()
+		if summary.ErrorMsg != "" {
+			return summary
+		}
+
+		progress(func(common.OutputFormat) string {
+			return formatWatchedProgress(summary)
+		})
+		if isJobDoneOrPaused(summary.JobStatus) {
+			return summary
+		}
+
+		select {
+		case <-stop:
+			return summary
+		case <-time.After(interval):
+		}
+	}
+}
+
+func formatWatchedProgress(summary common.ListJobSummaryResponse) string {
+	return fmt.Sprintf("%.1f %%, %v Done, %v Failed, %v Skipped, %v Total, Throughput (Mb/s): %v, Time Remaining: %s",
+		summary.PercentComplete,
+		summary.TransfersCompleted,
+		summary.TransfersFailed,
+		summary.TransfersSkipped,
+		summary.TotalTransfers,
+		ste.ToFixed(summary.ThroughputMbps, 4),
+		formatTimeRemaining(summary.EstimatedSecondsRemaining))
 }
 
 // handles the list command
 // dispatches the list order to the transfer engine
-func HandleShowCommand(listRequest common.ListRequest) error {
+// the result is written to outputFile, if one is given, instead of stdout
+func HandleShowCommand(listRequest common.ListRequest, outputFile string) error {
 	rpcCmd := common.ERpcCmd.None()
+	if listRequest.OfStatus == "" && listRequest.Grep != "" {
+		// searching the transfers makes no sense for the summary, so search all of them
+		listRequest.OfStatus = common.ETransferStatus.All().String()
+	}
+
 	if listRequest.OfStatus == "" {
 		resp := common.ListJobSummaryResponse{}
 		rpcCmd = common.ERpcCmd.ListJobSummary()
 		Rpc(rpcCmd, &listRequest.JobID, &resp)
-		PrintJobProgressSummary(resp)
+		PrintJobProgressSummary(resp, outputFile)
 	} else {
 		lsRequest := common.ListJobTransfersRequest{}
 		lsRequest.JobID = listRequest.JobID
+		lsRequest.Grep = listRequest.Grep
 		// Parse the given expected Transfer Status
 		// If there is an error parsing, then kill return the error
 		err := lsRequest.OfStatus.Parse(listRequest.OfStatus)
@@ -97,18 +186,18 @@ func HandleShowCommand(listRequest common.ListRequest) error {
 		resp := common.ListJobTransfersResponse{}
 		rpcCmd = common.ERpcCmd.ListJobTransfers()
 		Rpc(rpcCmd, lsRequest, &resp)
-		PrintJobTransfers(resp)
+		PrintJobTransfers(resp, outputFile)
 	}
 	return nil
 }
 
 // PrintJobTransfers prints the response of listOrder command when list Order command requested the list of specific transfer of an existing job
-func PrintJobTransfers(listTransfersResponse common.ListJobTransfersResponse) {
+func PrintJobTransfers(listTransfersResponse common.ListJobTransfersResponse, outputFile string) {
 	if listTransfersResponse.ErrorMsg != "" {
 		glcm.Error("request failed with following message " + listTransfersResponse.ErrorMsg)
 	}
 
-	glcm.Exit(func(format common.OutputFormat) string {
+	exitWithOutput(outputFile, func(format common.OutputFormat) string {
 		if format == common.EOutputFormat.Json() {
 			jsonOutput, err := json.Marshal(listTransfersResponse)
 			common.PanicIfErr(err)
@@ -123,7 +212,14 @@ func PrintJobTransfers(listTransfersResponse common.ListJobTransfersResponse) {
 				folderChar = "/"
 			}
 			sb.WriteString("transfer--> source: " + listTransfersResponse.Details[index].Src + folderChar + " destination: " +
-				listTransfersResponse.Details[index].Dst + folderChar + " status " + listTransfersResponse.Details[index].TransferStatus.String() + "\n")
+				listTransfersResponse.Details[index].Dst + folderChar + " status " + listTransfersResponse.Details[index].TransferStatus.String())
+			if etag := listTransfersResponse.Details[index].ETag; etag != "" {
+				sb.WriteString(" etag " + etag)
+			}
+			if reason := listTransfersResponse.Details[index].FailureReason; reason != "" {
+				sb.WriteString(" : " + reason)
+			}
+			sb.WriteString("\n")
 		}
 
 		return sb.String()
@@ -131,7 +227,7 @@ func PrintJobTransfers(listTransfersResponse common.ListJobTransfersResponse) {
 }
 
 // PrintJobProgressSummary prints the response of listOrder command when listOrder command requested the progress summary of an existing job
-func PrintJobProgressSummary(summary common.ListJobSummaryResponse) {
+func PrintJobProgressSummary(summary common.ListJobSummaryResponse, outputFile string) {
 	if summary.ErrorMsg != "" {
 		glcm.Error("list progress summary of job failed because " + summary.ErrorMsg)
 	}
@@ -139,7 +235,7 @@ func PrintJobProgressSummary(summary common.ListJobSummaryResponse) {
 	// Reset the bytes over the wire counter
 	summary.BytesOverWire = 0
 
-	glcm.Exit(func(format common.OutputFormat) string {
+	exitWithOutput(outputFile, func(format common.OutputFormat) string {
 		if format == common.EOutputFormat.Json() {
 			jsonOutput, err := json.Marshal(summary) // see note below re % complete being approximate. We can't include "approx" in the JSON.
 			common.PanicIfErr(err)
@@ -147,7 +243,8 @@ func PrintJobProgressSummary(summary common.ListJobSummaryResponse) {
 		}
 
 		return fmt.Sprintf(
-			"\nJob %s summary\nNumber of File Transfers: %v\nNumber of Folder Property Transfers: %v\nTotal Number Of Transfers: %v\nNumber of Transfers Completed: %v\nNumber of Transfers Failed: %v\nNumber of Transfers Skipped: %v\nPercent Complete (approx): %.1f\nFinal Job Status: %v\n",
+			"%s\nJob %s summary\nNumber of File Transfers: %v\nNumber of Folder Property Transfers: %v\nTotal Number Of Transfers: %v\nNumber of Transfers Completed: %v\nNumber of Transfers Failed: %v\nNumber of Transfers Skipped: %v\nPercent Complete (approx): %.1f\nThroughput (Mb/s): %v\nEstimated Time Remaining: %s\nFinal Job Status: %v\n",
+			formatFailedTransfers(summary.FailedTransfers),
 			summary.JobID.String(),
 			summary.FileTransfers,
 			summary.FolderPropertyTransfers,
@@ -155,8 +252,31 @@ func PrintJobProgressSummary(summary common.ListJobSummaryResponse) {
 			summary.TransfersCompleted,
 			summary.TransfersFailed,
 			summary.TransfersSkipped,
-			summary.PercentComplete, // noted as approx in the format string because won't include in-flight files if this Show command is run from a different process
+			summary.PercentComplete, // noted as approx in the format string because, from a different process, in-flight files only count their completed chunks
+			ste.ToFixed(summary.ThroughputMbps, 4),
+			formatTimeRemaining(summary.EstimatedSecondsRemaining),
 			summary.JobStatus,
 		)
 	}, common.EExitCode.Success())
 }
+
+// formatFailedTransfers lists each failed transfer along with why it failed, one per line
+func formatFailedTransfers(failed []common.TransferDetail) string {
+	var sb strings.Builder
+	for _, t := range failed {
+		sb.WriteString("\nFailed: " + t.Src + " -> " + t.Dst)
+		if t.FailureReason != "" {
+			sb.WriteString(" : " + t.FailureReason)
+		}
+	}
+	return sb.String()
+}
+
+// formatTimeRemaining renders an ETA for display. A negative value means the ETA can't be known,
+// which is the case when nothing has been transferred recently
+func formatTimeRemaining(seconds float64) string {
+	if seconds < 0 {
+		return "unknown (stalled)"
+	}
+	return time.Duration(seconds * float64(time.Second)).Round(time.Second).String()
+}
@@ -0,0 +1,224 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	chk "gopkg.in/check.v1"
+)
+
+type jobsShowTestSuite struct{}
+
+var _ = chk.Suite(&jobsShowTestSuite{})
+
+// fakeProgressRpc answers summary requests with a job that advances a quarter of the way each time it's asked
+type fakeProgressRpc struct {
+	calls int
+}
+
+func (f *fakeProgressRpc) summary() common.ListJobSummaryResponse {
+	f.calls++
+	s := common.ListJobSummaryResponse{
+		JobStatus:          common.EJobStatus.InProgress(),
+		TotalTransfers:     4,
+		TransfersCompleted: uint32(f.calls),
+		PercentComplete:    float32(25 * f.calls),
+	}
+	if f.calls == 4 {
+		s.JobStatus = common.EJobStatus.Completed()
+	}
+	return s
+}
+
+func (s *jobsShowTestSuite) TestWatchPollsUntilJobIsDone(c *chk.C) {
+	rpc := &fakeProgressRpc{}
+	var lines []string
+	progress := func(o common.OutputBuilder) { lines = append(lines, o(common.EOutputFormat.Text())) }
+
+	final := watchJobProgressSummary(time.Millisecond, rpc.summary, progress, make(chan os.Signal))
+
+	c.Assert(rpc.calls, chk.Equals, 4)
+	c.Assert(final.JobStatus, chk.Equals, common.EJobStatus.Completed())
+	c.Assert(lines, chk.HasLen, 4)
+	c.Assert(strings.HasPrefix(lines[0], "25.0 %, 1 Done"), chk.Equals, true, chk.Commentf(lines[0]))
+	c.Assert(strings.HasPrefix(lines[3], "100.0 %, 4 Done"), chk.Equals, true, chk.Commentf(lines[3]))
+}
+
+func (s *jobsShowTestSuite) TestWatchStopsOnInterrupt(c *chk.C) {
+	rpc := &fakeProgressRpc{}
+	stop := make(chan os.Signal, 1)
+	stop <- os.Interrupt
+
+	final := watchJobProgressSummary(time.Hour, rpc.summary, func(common.OutputBuilder) {}, stop)
+
+	c.Assert(rpc.calls, chk.Equals, 1)
+	c.Assert(final.JobStatus, chk.Equals, common.EJobStatus.InProgress())
+}
+
+func (s *jobsShowTestSuite) TestWatchFlagValidation(c *chk.C) {
+	input := ListReq{Watch: true, WatchInterval: time.Second}
+	c.Assert(validateWatchFlags(input, common.EOutputFormat.Text()), chk.IsNil)
+
+	err := validateWatchFlags(input, common.EOutputFormat.Json())
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "--output-type=json")
+
+	input.WatchInterval = 0
+	c.Assert(validateWatchFlags(input, common.EOutputFormat.Text()), chk.NotNil)
+
+	input = ListReq{Watch: true, WatchInterval: time.Second, OfStatus: "Failed"}
+	c.Assert(validateWatchFlags(input, common.EOutputFormat.Text()), chk.NotNil)
+}
+
+// consoleAndFileOutput runs print once to the console and once to a file, and returns what each got
+func (s *jobsShowTestSuite) consoleAndFileOutput(c *chk.C, print func(outputFile string)) (console string, file string) {
+	mockedLcm := mockedLifecycleManager{exitLog: make(chan string, 1)}
+	glcm = &mockedLcm
+
+	print("")
+	console = <-mockedLcm.exitLog
+
+	outputFile := filepath.Join(c.MkDir(), "result.txt")
+	c.Assert(ioutil.WriteFile(outputFile, []byte(strings.Repeat("stale output from an earlier run\n", 100)), 0644), chk.IsNil)
+	print(outputFile)
+	c.Assert(<-mockedLcm.exitLog, chk.Equals, "") // nothing more goes to the console
+
+	content, err := ioutil.ReadFile(outputFile)
+	c.Assert(err, chk.IsNil)
+	entries, err := ioutil.ReadDir(filepath.Dir(outputFile))
+	c.Assert(err, chk.IsNil)
+	c.Assert(entries, chk.HasLen, 1) // no temporary file is left behind
+	return console, string(content)
+}
+
+func (s *jobsShowTestSuite) TestOutputFileMatchesConsoleOutput(c *chk.C) {
+	summary := common.ListJobSummaryResponse{
+		JobID:              common.NewJobID(),
+		JobStatus:          common.EJobStatus.CompletedWithErrors(),
+		TotalTransfers:     3,
+		TransfersCompleted: 2,
+		TransfersFailed:    1,
+		FailedTransfers:    []common.TransferDetail{{Src: "/a/b.txt", Dst: "https://account.blob.core.windows.net/c/b.txt", FailureReason: "403"}},
+	}
+	console, file := s.consoleAndFileOutput(c, func(outputFile string) { PrintJobProgressSummary(summary, outputFile) })
+	c.Assert(console, StringContains, "Number of Transfers Failed: 1")
+	c.Assert(file, chk.Equals, console+"\n")
+
+	transfers := common.ListJobTransfersResponse{
+		JobID:   summary.JobID,
+		Details: []common.TransferDetail{{Src: "/a/b.txt", Dst: "https://account.blob.core.windows.net/c/b.txt", TransferStatus: common.ETransferStatus.Failed()}},
+	}
+	console, file = s.consoleAndFileOutput(c, func(outputFile string) { PrintJobTransfers(transfers, outputFile) })
+	c.Assert(console, StringContains, "/a/b.txt")
+	c.Assert(file, chk.Equals, console+"\n")
+
+	jobs := common.ListJobsResponse{JobIDDetails: []common.JobIDDetails{{JobId: summary.JobID, CommandString: "copy a b", JobStatus: common.EJobStatus.Completed()}}}
+	console, file = s.consoleAndFileOutput(c, func(outputFile string) { c.Assert(PrintExistingJobIds(jobs, jobsSortByDate, outputFile), chk.IsNil) })
+	c.Assert(console, StringContains, "copy a b")
+	c.Assert(file, chk.Equals, console+"\n")
+}
+
+func (s *jobsShowTestSuite) TestOutputFileHonoursOutputType(c *chk.C) {
+	defer func(format common.OutputFormat) { azcopyOutputFormat = format }(azcopyOutputFormat)
+	azcopyOutputFormat = common.EOutputFormat.Json()
+
+	summary := common.ListJobSummaryResponse{JobID: common.NewJobID(), JobStatus: common.EJobStatus.Completed(), TotalTransfers: 7}
+	_, file := s.consoleAndFileOutput(c, func(outputFile string) { PrintJobProgressSummary(summary, outputFile) })
+
+	var written common.ListJobSummaryResponse
+	c.Assert(json.Unmarshal([]byte(file), &written), chk.IsNil)
+	c.Assert(written.JobID, chk.Equals, summary.JobID)
+	c.Assert(written.TotalTransfers, chk.Equals, uint32(7))
+}
+
+func (s *jobsShowTestSuite) TestOutputFileErrorIsReported(c *chk.C) {
+	mockedLcm := mockedLifecycleManager{exitLog: make(chan string, 1), errorLog: make(chan string, 1)}
+	glcm = &mockedLcm
+
+	outputFile := filepath.Join(c.MkDir(), "no-such-folder", "result.txt")
+	PrintJobProgressSummary(common.ListJobSummaryResponse{}, outputFile)
+	c.Assert(<-mockedLcm.errorLog, StringContains, "failed to write the output to "+outputFile)
+	c.Assert(mockedLcm.exitLog, chk.HasLen, 0)
+}
+
+func (s *jobsShowTestSuite) TestTransfersListIncludesETag(c *chk.C) {
+	transfers := common.ListJobTransfersResponse{
+		JobID: common.NewJobID(),
+		Details: []common.TransferDetail{
+			{Src: "/a/b.txt", Dst: "https://account.blob.core.windows.net/c/b.txt", TransferStatus: common.ETransferStatus.Success(), ETag: `"0x8D9A1B2C3D4E5F6"`},
+			{Src: "/a/c.txt", Dst: "https://account.blob.core.windows.net/c/c.txt", TransferStatus: common.ETransferStatus.Failed()},
+		},
+	}
+
+	console, _ := s.consoleAndFileOutput(c, func(outputFile string) { PrintJobTransfers(transfers, outputFile) })
+	lines := strings.Split(strings.TrimSpace(console), "\n")
+	c.Assert(lines, chk.HasLen, 3)
+	c.Assert(lines[1], StringContains, `status Success etag "0x8D9A1B2C3D4E5F6"`)
+	c.Assert(lines[2], chk.Not(StringContains), "etag") // nothing was written, so there's no ETag
+
+	defer func(format common.OutputFormat) { azcopyOutputFormat = format }(azcopyOutputFormat)
+	azcopyOutputFormat = common.EOutputFormat.Json()
+	_, file := s.consoleAndFileOutput(c, func(outputFile string) { PrintJobTransfers(transfers, outputFile) })
+
+	var written common.ListJobTransfersResponse
+	c.Assert(json.Unmarshal([]byte(file), &written), chk.IsNil)
+	c.Assert(written.Details[0].ETag, chk.Equals, `"0x8D9A1B2C3D4E5F6"`)
+	c.Assert(file, chk.Not(StringContains), `"ETag":""`) // left out when there's none
+}
+
+func (s *jobsShowTestSuite) TestGrepListsMatchingTransfersOfAnyStatus(c *chk.C) {
+	mockedLcm := mockedLifecycleManager{exitLog: make(chan string, 1)}
+	glcm = &mockedLcm
+	defer func(rpc func(common.RpcCmd, interface{}, interface{})) { Rpc = rpc }(Rpc)
+
+	var sent common.ListJobTransfersRequest
+	Rpc = func(cmd common.RpcCmd, request interface{}, response interface{}) {
+		c.Assert(cmd, chk.Equals, common.ERpcCmd.ListJobTransfers())
+		sent = request.(common.ListJobTransfersRequest)
+		// the STE does the filtering, so answer with what it would have matched
+		*(response.(*common.ListJobTransfersResponse)) = common.ListJobTransfersResponse{
+			JobID: sent.JobID,
+			Details: []common.TransferDetail{{Src: "/a/b.txt", Dst: "https://account.blob.core.windows.net/c/b.txt",
+				TransferStatus: common.ETransferStatus.Failed(), ErrorCode: 403, FailureReason: "403 Forbidden"}},
+		}
+	}
+
+	jobID := common.NewJobID()
+	c.Assert(HandleShowCommand(common.ListRequest{JobID: jobID, Grep: "403"}, ""), chk.IsNil)
+	c.Assert(sent.JobID, chk.Equals, jobID)
+	c.Assert(sent.Grep, chk.Equals, "403")
+	c.Assert(sent.OfStatus, chk.Equals, common.ETransferStatus.All())
+	c.Assert(<-mockedLcm.exitLog, StringContains, "status Failed : 403 Forbidden")
+
+	// combined with a status, the status is kept
+	c.Assert(HandleShowCommand(common.ListRequest{JobID: jobID, OfStatus: "Failed", Grep: "403"}, ""), chk.IsNil)
+	c.Assert(sent.OfStatus, chk.Equals, common.ETransferStatus.Failed())
+	<-mockedLcm.exitLog
+
+	c.Assert(validateWatchFlags(ListReq{Watch: true, WatchInterval: time.Second, Grep: "403"}, common.EOutputFormat.Text()), chk.NotNil)
+}
@@ -2,8 +2,10 @@ package cmd
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-storage-blob-go/azblob"
 	"github.com/Azure/azure-storage-file-go/azfile"
@@ -74,7 +76,9 @@ func DetermineLocationLevel(location string, locationType common.Location, sourc
 			return ELocationLevel.Service(), errors.New("can't use a wildcarded container name and specific blob name in combination")
 		}
 
-		if bURL.GetObjectName() != "" {
+		if locationType == common.ELocation.Blob() && blobURLHasWildcard(*URL) {
+			return ELocationLevel.Container(), nil // like a local wildcard, this stands for many objects
+		} else if bURL.GetObjectName() != "" {
 			return ELocationLevel.Object(), nil
 		} else if bURL.GetContainerName() != "" && !strings.Contains(bURL.GetContainerName(), "*") {
 			return ELocationLevel.Container(), nil
@@ -115,6 +119,13 @@ func GetResourceRoot(resource string, location common.Location) (resourceBase st
 			}
 
 			bURLParts.ContainerName = ""
+		} else if blobURLHasWildcard(*resourceURL) {
+			// the root is the virtual directory holding the first wildcard, which is what the matches are relative to
+			wildcard, err := parseBlobWildcard(escapedBlobName(*resourceURL))
+			if err != nil {
+				return resource, err
+			}
+			bURLParts.BlobName = wildcard.root
 		}
 
 		bURL := bURLParts.URL()
@@ -196,6 +207,108 @@ func SplitResourceString(raw string, loc common.Location) (common.ResourceString
 	}, nil
 }
 
+// applyOutOfBandSAS gives dst the SAS token read from sasTokenFile or, failing that, from AZCOPY_SAS_TOKEN.
+// This keeps the token out of the command line. A SAS that is already in the destination URL wins over the
+// environment variable, but conflicts with the file, since that was asked for explicitly.
+// AZCOPY_SAS_TOKEN is the token of the account named in AZCOPY_SAS_TOKEN_ACCOUNT, so that it's never sent to another account.
+func applyOutOfBandSAS(dst *common.ResourceString, loc common.Location, sasTokenFile string) error {
+	sas := ""
+	if sasTokenFile != "" {
+		content, err := ioutil.ReadFile(sasTokenFile)
+		if err != nil {
+			return fmt.Errorf("cannot read the SAS token file: %w", err)
+		}
+		sas = string(content)
+	} else {
+		sas = glcm.GetEnvironmentVariable(common.EEnvironmentVariable.SASToken())
+	}
+	sas = strings.TrimPrefix(strings.TrimSpace(sas), "?")
+	if sas == "" {
+		if sasTokenFile != "" {
+			return errors.New("the SAS token file is empty")
+		}
+		return nil
+	}
+
+	switch loc {
+	case common.ELocation.Blob(), common.ELocation.File(), common.ELocation.BlobFS():
+	default:
+		if sasTokenFile != "" {
+			return errors.New("--sas-token-file can only be used when the destination is Blob, File or ADLS Gen2 storage")
+		}
+		return nil
+	}
+
+	if dst.SAS != "" {
+		if sasTokenFile != "" {
+			return errors.New("the destination URL already has a SAS token, so --sas-token-file cannot be used")
+		}
+		return nil
+	}
+	if sasTokenFile == "" {
+		if isForDst, err := sasTokenFromEnvironmentIsFor(dst.Value); err != nil || !isForDst {
+			return err
+		}
+	}
+
+	if _, err := url.ParseQuery(sas); err != nil {
+		return fmt.Errorf("the given SAS token is not a valid query string: %w", err)
+	}
+	dst.SAS = sas
+	return nil
+}
+
+// sasTokenFromEnvironmentIsFor says whether the SAS token in AZCOPY_SAS_TOKEN is for the account of resourceURL
+func sasTokenFromEnvironmentIsFor(resourceURL string) (bool, error) {
+	tokenAccountName := glcm.GetEnvironmentVariable(common.EEnvironmentVariable.SASTokenAccount())
+	if tokenAccountName == "" {
+		return false, fmt.Errorf("%s must be set to the name of the account that %s is for",
+			common.EEnvironmentVariable.SASTokenAccount().Name, common.EEnvironmentVariable.SASToken().Name)
+	}
+	accountName, err := accountNameInURL(resourceURL)
+	if err != nil {
+		return false, err
+	}
+	return strings.EqualFold(tokenAccountName, accountName), nil
+}
+
+// sasValidityWarning checks the start (st) and expiry (se) times of a SAS token against the local clock, and says
+// what's wrong if the token can't be valid right now. Otherwise, every request made with it would fail with a 403
+// that doesn't say why. It returns an empty string for a token that is valid, or has neither time, or isn't a SAS at all.
+func sasValidityWarning(sas string, which string, now time.Time) string {
+	if sas == "" {
+		return ""
+	}
+	u := url.URL{Scheme: "https", Host: "account", RawQuery: sas}
+	sasParams := azblob.NewBlobURLParts(u).SAS // Blob, File and ADLS Gen2 SAS tokens all use the same times
+	start, expiry := sasParams.StartTime(), sasParams.ExpiryTime()
+
+	const format = "2006-01-02T15:04:05Z"
+	switch {
+	case !expiry.IsZero() && !now.Before(expiry):
+		return fmt.Sprintf("The SAS token for the %s expired at %s, and the clock of this machine says it is now %s. "+
+			"Requests made with it will fail with 403 (Forbidden). If the token should still be valid, check this machine's clock.",
+			which, expiry.UTC().Format(format), now.UTC().Format(format))
+	case !start.IsZero() && now.Before(start):
+		return fmt.Sprintf("The SAS token for the %s is not valid until %s, and the clock of this machine says it is now %s. "+
+			"Requests made with it will fail with 403 (Forbidden) until then. If the token was just created, this machine's clock may be behind.",
+			which, start.UTC().Format(format), now.UTC().Format(format))
+	default:
+		return ""
+	}
+}
+
+// warnIfSASIsNotValidNow tells the user, before any transfers are scheduled, about SAS tokens on the source or
+// destination that the local clock says are expired or not yet valid
+func warnIfSASIsNotValidNow(source, destination common.ResourceString) {
+	now := time.Now()
+	for _, msg := range []string{sasValidityWarning(source.SAS, "source", now), sasValidityWarning(destination.SAS, "destination", now)} {
+		if msg != "" {
+			glcm.Info(msg)
+		}
+	}
+}
+
 // resourceBase will always be returned regardless of the location.
 // resourceToken will be separated and returned depending on the location.
 func splitAuthTokenFromResource(resource string, location common.Location) (resourceBase, resourceToken string, err error) {
@@ -22,13 +22,16 @@ package cmd
 
 import (
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/Azure/azure-storage-azcopy/v10/common"
 	"github.com/spf13/cobra"
 )
 
-// TODO should this command be removed? Previously AzCopy was supposed to have an independent backend (out of proc)
-// TODO but that's not the plan anymore
+// Like cancel, the pause command runs in a different process from the job it pauses. The process running the job
+// notices the change of status in the job's plan files, stops its in-flight transfers, and exits.
+// The job can then be continued with "azcopy jobs resume".
 func init() {
 	var commandLineInput = ""
 
@@ -37,7 +40,8 @@ func init() {
 		Use:        "pause",
 		SuggestFor: []string{"pase", "ause", "paue"},
 		Short:      "Pause the existing job with the given Job Id",
-		Long:       `Pause the existing job with the given Job Id`,
+		Long: "Pause the existing job with the given Job Id. Run this from a separate terminal while the job is running. " +
+			"Transfers that are in flight are stopped, but blocks that were already uploaded are kept, so that 'azcopy jobs resume' can carry on from where the job left off.",
 		Args: func(cmd *cobra.Command, args []string) error {
 			// the pause command requires necessarily to have an argument
 			// pause jobId -- pause all the parts of an existing job for given jobId
@@ -53,9 +57,6 @@ func init() {
 			HandlePauseCommand(commandLineInput)
 			glcm.Exit(nil, common.EExitCode.Success())
 		},
-		// hide features not relevant to BFS
-		// TODO remove after preview release
-		Hidden: true,
 	}
 	rootCmd.AddCommand(pauseCmd)
 }
@@ -71,9 +72,66 @@ func HandlePauseCommand(jobIdString string) {
 		glcm.Error("invalid jobId string passed. Failed while parsing string to jobId")
 	}
 
-	var pauseJobResponse common.CancelPauseResumeResponse
-	Rpc(common.ERpcCmd.PauseJob(), jobID, &pauseJobResponse)
+	if err := pauseJob(jobID); err != nil {
+		glcm.Error("failed to pause job due to error: " + err.Error())
+	}
+	if err := waitForJobToPause(jobID, func() common.ListJobSummaryResponse {
+		var summary common.ListJobSummaryResponse
+		Rpc(common.ERpcCmd.ListJobSummary(), &jobID, &summary)
+		return summary
+	}, pausePollInterval, pauseWaitTimeout); err != nil {
+		glcm.Error(err.Error())
+	}
 	glcm.Exit(func(format common.OutputFormat) string {
-		return "Job " + jobID.String() + " paused successfully"
+		return "Job " + jobID.String() + " paused successfully. To continue it, run: azcopy jobs resume " + jobID.String()
 	}, common.EExitCode.Success())
 }
+
+// pauseJob dispatches the pause Job order to the storage engine
+func pauseJob(jobID common.JobID) error {
+	var pauseJobResponse common.CancelPauseResumeResponse
+	Rpc(common.ERpcCmd.PauseJob(), jobID, &pauseJobResponse)
+	if !pauseJobResponse.CancelledPauseResumed {
+		return errors.New(pauseJobResponse.ErrorMsg)
+	}
+	return nil
+}
+
+const (
+	pausePollInterval = time.Second
+	// pauseWaitTimeout allows for the transfer engine to let the chunks that are in flight finish, before it stops the job
+	pauseWaitTimeout = time.Minute
+)
+
+// waitForJobToPause polls the job's summary until the pause that was ordered has taken effect.
+// The transfer engine records the pause straight away, but the job stops only once its in-flight chunks are done.
+func waitForJobToPause(jobID common.JobID, getSummary func() common.ListJobSummaryResponse, interval, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		summary := getSummary()
+		if summary.ErrorMsg != "" {
+			return errors.New(summary.ErrorMsg)
+		}
+		if isJobDoneOrPaused(summary.JobStatus) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("job %v was told to pause, but has not stopped after %v. Check its status with: azcopy jobs show %v", jobID, timeout, jobID)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// isJobDoneOrPaused says whether the FE should stop reporting progress for a job.
+// A job can be paused from another process, in which case the process running it stops.
+func isJobDoneOrPaused(status common.JobStatus) bool {
+	return status.IsJobDone() || status == common.EJobStatus.Paused()
+}
+
+// pausedJobHint tells the user how to continue a job that was paused, and is empty for any other job
+func pausedJobHint(summary common.ListJobSummaryResponse) string {
+	if summary.JobStatus != common.EJobStatus.Paused() {
+		return ""
+	}
+	return "\nThe job was paused. To continue it, run: azcopy jobs resume " + summary.JobID.String() + "\n"
+}
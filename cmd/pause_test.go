@@ -0,0 +1,70 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+
+	chk "gopkg.in/check.v1"
+)
+
+type pauseTestSuite struct{}
+
+var _ = chk.Suite(&pauseTestSuite{})
+
+func (s *pauseTestSuite) TestPausedJobsStopProgressReporting(c *chk.C) {
+	c.Assert(isJobDoneOrPaused(common.EJobStatus.InProgress()), chk.Equals, false)
+	c.Assert(isJobDoneOrPaused(common.EJobStatus.Cancelling()), chk.Equals, false)
+	c.Assert(isJobDoneOrPaused(common.EJobStatus.Paused()), chk.Equals, true)
+	c.Assert(isJobDoneOrPaused(common.EJobStatus.Completed()), chk.Equals, true)
+	c.Assert(isJobDoneOrPaused(common.EJobStatus.Cancelled()), chk.Equals, true)
+
+	summary := common.ListJobSummaryResponse{JobID: common.NewJobID(), JobStatus: common.EJobStatus.Paused()}
+	c.Assert(strings.Contains(pausedJobHint(summary), "azcopy jobs resume "+summary.JobID.String()), chk.Equals, true)
+
+	summary.JobStatus = common.EJobStatus.Completed()
+	c.Assert(pausedJobHint(summary), chk.Equals, "")
+}
+
+func (s *pauseTestSuite) TestPauseWaitsForTheJobToStop(c *chk.C) {
+	jobID := common.NewJobID()
+	statuses := []common.JobStatus{common.EJobStatus.InProgress(), common.EJobStatus.InProgress(), common.EJobStatus.Paused()}
+	polls := 0
+	getSummary := func() common.ListJobSummaryResponse {
+		status := statuses[polls]
+		polls++
+		return common.ListJobSummaryResponse{JobID: jobID, JobStatus: status}
+	}
+
+	c.Assert(waitForJobToPause(jobID, getSummary, time.Millisecond, time.Minute), chk.IsNil)
+	c.Assert(polls, chk.Equals, 3)
+
+	// a job that never stops is reported, rather than waited for forever
+	stuck := func() common.ListJobSummaryResponse {
+		return common.ListJobSummaryResponse{JobID: jobID, JobStatus: common.EJobStatus.InProgress()}
+	}
+	err := waitForJobToPause(jobID, stuck, time.Millisecond, 50*time.Millisecond)
+	c.Assert(err, chk.NotNil)
+	c.Assert(strings.Contains(err.Error(), "azcopy jobs show "+jobID.String()), chk.Equals, true)
+}
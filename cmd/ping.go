@@ -0,0 +1,88 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	// pingCmd represents the ping command
+	pingCmd := &cobra.Command{
+		Use:     "ping",
+		Aliases: []string{"status"},
+		Short:   "Check that the transfer engine is up and responding",
+		Long: "Check that the transfer engine (STE) at --ste-url, or at " + common.EEnvironmentVariable.SteURL().Name + " if the flag is omitted, is up and responding, e.g. one started with 'azcopy serve'. " +
+			"Reports the version of the STE, how long it has been running, and how many jobs it is currently working on.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return errors.New("this command does not take any arguments")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			resp, err := pingSte(configuredSteURL(cmdLineSteURL))
+			if err != nil {
+				glcm.Error(err.Error())
+			}
+			if resp.ErrorMsg != "" {
+				glcm.Error("the transfer engine failed to answer the ping: " + resp.ErrorMsg)
+			}
+			glcm.Exit(func(format common.OutputFormat) string {
+				return pingOutput(resp, format)
+			}, common.EExitCode.Success())
+		},
+	}
+	rootCmd.AddCommand(pingCmd)
+}
+
+// pingSte pings the STE at the given URL. The STE inside this process is always up, so there has to be a URL.
+func pingSte(steURL string) (common.PingResponse, error) {
+	if steURL == "" {
+		return common.PingResponse{}, fmt.Errorf("no transfer engine to ping. Use --ste-url or %s to give the URL of one", common.EEnvironmentVariable.SteURL().Name)
+	}
+	client, err := NewHttpClient(steURL)
+	if err != nil {
+		return common.PingResponse{}, err
+	}
+	resp, err := client.Ping()
+	if err != nil {
+		return resp, fmt.Errorf("the transfer engine at %s did not answer the ping: %w", steURL, err)
+	}
+	return resp, nil
+}
+
+// pingOutput formats the answer to a ping, either as JSON or as a human-readable line
+func pingOutput(resp common.PingResponse, format common.OutputFormat) string {
+	if format == common.EOutputFormat.Json() {
+		jsonOutput, err := json.Marshal(resp)
+		common.PanicIfErr(err)
+		return string(jsonOutput)
+	}
+	return fmt.Sprintf("The transfer engine is running. Version: %s; Uptime: %v; Active jobs: %d",
+		resp.Version, resp.Uptime.Round(time.Second), resp.ActiveJobs)
+}
@@ -0,0 +1,145 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// pipingBlockSize returns the size of the block at blockIndex in a piped upload that started with initialBlockSize.
+// Since the length of a piped stream isn't known up front, the block size can't be chosen to fit it. Instead, the block
+// size doubles each time the number of blocks left halves: the first half of the allowed blocks have the initial size,
+// the next quarter twice that, and so on. So a long stream takes fewer blocks as it goes, and never needs more than
+// common.MaxNumberOfBlocksPerBlob of them, unless it's long enough to need blocks beyond the maximum block size.
+func pipingBlockSize(blockIndex int32, initialBlockSize int64) int64 {
+	blockSize := initialBlockSize
+	blocksLeft := int32(common.MaxNumberOfBlocksPerBlob) - blockIndex
+	for threshold := int32(common.MaxNumberOfBlocksPerBlob / 2); threshold > 0 && blocksLeft <= threshold; threshold /= 2 {
+		blockSize *= 2
+	}
+	if blockSize > common.MaxBlockBlobBlockSize {
+		blockSize = common.MaxBlockBlobBlockSize
+	}
+	return blockSize
+}
+
+// stageStreamInBlocks reads src to its end and stages it as blocks, sized by pipingBlockSize, with up to parallelism
+// blocks being staged at once. The memory for each block is reserved from limiter before the block is read, so the
+// blocks count towards the same limit as the chunks of transfers. It returns the IDs of the staged blocks, in order,
+// ready to be committed.
+func stageStreamInBlocks(ctx context.Context, src io.Reader, initialBlockSize int64, parallelism int, limiter common.CacheLimiter,
+	stage func(ctx context.Context, blockID string, block []byte) error) ([]string, error) {
+	if initialBlockSize <= 0 {
+		return nil, fmt.Errorf("block size must be positive")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var failOnce sync.Once
+	var failure error
+	fail := func(err error) {
+		failOnce.Do(func() {
+			failure = err
+			cancel()
+		})
+	}
+
+	blockIDPrefix := common.NewUUID().String()
+	blockIDs := make([]string, 0)
+	inFlight := make(chan struct{}, parallelism)
+	blockSize := initialBlockSize
+
+readLoop:
+	for blockIndex := int32(0); ; blockIndex++ {
+		if blockIndex >= common.MaxNumberOfBlocksPerBlob {
+			fail(fmt.Errorf("the piped data needs more than %d blocks, even with blocks of the maximum size", common.MaxNumberOfBlocksPerBlob))
+			break
+		}
+		if size := pipingBlockSize(blockIndex, initialBlockSize); size != blockSize {
+			glcm.Info(fmt.Sprintf("Increasing the block size from %d to %d bytes at block %d, so that the upload fits in %d blocks",
+				blockSize, size, blockIndex, common.MaxNumberOfBlocksPerBlob))
+			blockSize = size
+		}
+
+		if blockSize > limiter.Limit() {
+			fail(fmt.Errorf("the piped data needs blocks of %d bytes, but AzCopy is limited to use only %d bytes of memory. "+
+				"Use a bigger block size, or allow more memory with %s", blockSize, limiter.Limit(), common.EEnvironmentVariable.BufferGB().Name))
+			break
+		}
+
+		// wait for a free slot before reading, so that no more than parallelism blocks are held in memory
+		select {
+		case inFlight <- struct{}{}:
+		case <-ctx.Done():
+			break readLoop
+		}
+		// a block too big for the strict limit may use the rest, or it could never be read
+		reservedSize := blockSize
+		if err := limiter.WaitUntilAdd(ctx, reservedSize, func() bool { return reservedSize > limiter.StrictLimit() }); err != nil {
+			<-inFlight
+			break
+		}
+		release := func() {
+			limiter.Remove(reservedSize)
+			<-inFlight
+		}
+
+		block := make([]byte, blockSize)
+		n, err := io.ReadFull(src, block)
+		if err == io.EOF {
+			release()
+			break
+		} else if err != nil && err != io.ErrUnexpectedEOF {
+			release()
+			fail(fmt.Errorf("cannot read the piped data: %w", err))
+			break
+		}
+
+		blockID := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s%05d", blockIDPrefix, blockIndex)))
+		blockIDs = append(blockIDs, blockID)
+		wg.Add(1)
+		go func(block []byte) {
+			defer wg.Done()
+			defer release()
+			if err := stage(ctx, blockID, block); err != nil {
+				fail(err)
+			}
+		}(block[:n])
+
+		if err == io.ErrUnexpectedEOF {
+			break // a short block is the last one
+		}
+	}
+
+	wg.Wait()
+	if failure != nil {
+		return nil, failure
+	}
+	return blockIDs, ctx.Err()
+}
@@ -79,7 +79,7 @@ func init() {
 
 			cooked, err := raw.cook()
 			if err != nil {
-				glcm.Error("failed to parse user input due to error: " + err.Error())
+				glcm.ExitWithError("failed to parse user input due to error: "+err.Error(), common.EExitCode.InvalidUsage())
 			}
 
 			if cooked.permanentDeleteOption != common.EPermanentDeleteOption.None() {
@@ -27,6 +27,7 @@ import (
 	"net/url"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -44,9 +45,16 @@ var azcopyLogPathFolder string
 var azcopyJobPlanFolder string
 var azcopyMaxFileAndSocketHandles int
 var outputFormatRaw string
+var quiet bool
 var cancelFromStdin bool
 var azcopyOutputFormat common.OutputFormat
-var cmdLineCapMegaBitsPerSecond float64
+var logFormatRaw string
+var cmdLineCapMbpsRaw string
+var cmdLineMaxIdleConns int
+var cmdLineHTTP2 bool
+var cmdLineMetricsPort int
+var cmdLineMaxOpenFiles int
+var cmdLineSteURL string
 var azcopyAwaitContinue bool
 var azcopyAwaitAllowOpenFiles bool
 var azcopyScanningLogger common.ILoggerResetable
@@ -74,6 +82,20 @@ var rootCmd = &cobra.Command{
 				ste.UploadTryTimeout = timeout
 			}
 		}
+		if jitter := glcm.GetEnvironmentVariable(common.EEnvironmentVariable.RetryJitter()); jitter != "" {
+			if err := useRetryJitter(jitter); err != nil {
+				return err
+			}
+		}
+		if glcm.GetEnvironmentVariable(common.EEnvironmentVariable.ChunkRetries()) != "" {
+			retries, err := strconv.Atoi(glcm.GetEnvironmentVariable(common.EEnvironmentVariable.ChunkRetries()))
+			if err == nil && retries >= 0 {
+				ste.UploadChunkRetries = retries
+			}
+		}
+		if err := useSteURL(cmdLineSteURL); err != nil {
+			return err
+		}
 		glcm.E2EEnableAwaitAllowOpenFiles(azcopyAwaitAllowOpenFiles)
 		if azcopyAwaitContinue {
 			glcm.E2EAwaitContinue()
@@ -83,10 +105,22 @@ var rootCmd = &cobra.Command{
 
 		err := azcopyOutputFormat.Parse(outputFormatRaw)
 		glcm.SetOutputFormat(azcopyOutputFormat)
+		glcm.SetQuiet(quiet)
 		if err != nil {
 			return err
 		}
 
+		capMbpsSchedule, err := parseCapMbps(cmdLineCapMbpsRaw)
+		if err != nil {
+			return err
+		}
+
+		var logFormat common.LogFormat
+		if err := logFormat.Parse(logFormatRaw); err != nil {
+			return fmt.Errorf("invalid log-format %q. The choices are text and json", logFormatRaw)
+		}
+		glcm.SetLogFormat(logFormat)
+
 		glcm.SetForceLogging()
 
 		// warn Windows users re quoting (since our docs all use single quotes, but CMD needs double)
@@ -110,11 +144,23 @@ var rootCmd = &cobra.Command{
 		providePerformanceAdvice := cmd == benchCmd
 
 		// startup of the STE happens here, so that the startup can access the values of command line parameters that are defined for "root" command
-		concurrencySettings := ste.NewConcurrencySettings(azcopyMaxFileAndSocketHandles, preferToAutoTuneGRs)
-		err = ste.MainSTE(concurrencySettings, float64(cmdLineCapMegaBitsPerSecond), azcopyJobPlanFolder, azcopyLogPathFolder, providePerformanceAdvice)
+		concurrencySettings, err := ste.NewConcurrencySettings(azcopyMaxFileAndSocketHandles, preferToAutoTuneGRs)
 		if err != nil {
 			return err
 		}
+		if err := applyConnectionFlags(&concurrencySettings, cmdLineMaxIdleConns, cmdLineHTTP2); err != nil {
+			return err
+		}
+		if err := applyMaxOpenFiles(&concurrencySettings, cmdLineMaxOpenFiles); err != nil {
+			return err
+		}
+		err = ste.MainSTE(concurrencySettings, capMbpsSchedule, azcopyJobPlanFolder, azcopyLogPathFolder, providePerformanceAdvice)
+		if err != nil {
+			return err
+		}
+		if err := startMetricsServer(cmdLineMetricsPort); err != nil {
+			return err
+		}
 	        EnumerationParallelism = concurrencySettings.EnumerationPoolSize.Value
 		EnumerationParallelStatFiles = concurrencySettings.ParallelStatFiles.Value
 
@@ -153,6 +199,75 @@ var rootCmd = &cobra.Command{
 var glcm = common.GetLifecycleMgr()
 var glcmSwapOnce = &sync.Once{}
 
+// applyConnectionFlags overrides the automatically-chosen connection settings with any that were given on the command line
+func applyConnectionFlags(settings *ste.ConcurrencySettings, maxIdleConns int, http2 bool) error {
+	if maxIdleConns < 0 {
+		return fmt.Errorf("invalid max-idle-conns %d. It must be zero (automatic) or a positive number", maxIdleConns)
+	}
+	if maxIdleConns > 0 {
+		settings.MaxIdleConnections = maxIdleConns
+	}
+	settings.ForceHTTP2 = http2
+	return nil
+}
+
+// applyMaxOpenFiles overrides the automatically-chosen limit on open files, if one was given on the command line
+func applyMaxOpenFiles(settings *ste.ConcurrencySettings, maxOpenFiles int) error {
+	if maxOpenFiles < 0 {
+		return fmt.Errorf("invalid max-open-files %d. It must be zero (automatic) or a positive number", maxOpenFiles)
+	}
+	if maxOpenFiles > 0 {
+		settings.MaxOpenFiles = maxOpenFiles
+	}
+	return nil
+}
+
+// startMetricsServer serves the STE's Prometheus metrics on the given port. Zero means the metrics aren't served.
+func startMetricsServer(port int) error {
+	if port < 0 || port > 65535 {
+		return fmt.Errorf("invalid metrics-port %d. It must be zero (no metrics endpoint) or a TCP port number", port)
+	}
+	if port == 0 {
+		return nil
+	}
+	return ste.StartMetricsServer(port)
+}
+
+// validateCapMbps rejects negative caps. Zero means the throughput isn't capped at all.
+func validateCapMbps(capMbps float64) error {
+	if capMbps < 0 {
+		return fmt.Errorf("invalid cap-mbps %v. It must be zero (no cap) or a positive number of megabits per second", capMbps)
+	}
+	return nil
+}
+
+// parseCapMbps parses --cap-mbps, which is either a single cap or a time-of-day schedule of caps, and validates every cap in it
+func parseCapMbps(raw string) (common.CapMbpsSchedule, error) {
+	schedule, err := common.ParseCapMbpsSchedule(raw)
+	if err != nil {
+		return common.CapMbpsSchedule{}, err
+	}
+	for _, capMbps := range schedule.Caps() {
+		if err := validateCapMbps(capMbps); err != nil {
+			return common.CapMbpsSchedule{}, err
+		}
+	}
+	return schedule, nil
+}
+
+// useRetryJitter sets how much the delays between retries are randomized, from the value of AZCOPY_RETRY_JITTER
+func useRetryJitter(raw string) error {
+	jitter, err := strconv.ParseFloat(raw, 32)
+	if err != nil || jitter < 0 || jitter > 1 {
+		return fmt.Errorf("invalid %s %q. It must be a number from 0 (no jitter) to 1", common.EEnvironmentVariable.RetryJitter().Name, raw)
+	}
+	ste.UploadRetryJitter = float32(jitter)
+	if jitter == 0 {
+		ste.UploadRetryJitter = -1 // zero means the default to the retry policy, so say "off" explicitly
+	}
+	return nil
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute(azsAppPathFolder, logPathFolder string, jobPlanFolder string, maxFileAndSocketHandles int) {
@@ -181,8 +296,24 @@ func init() {
 	// replace the word "global" to avoid confusion (e.g. it doesn't affect all instances of AzCopy)
 	rootCmd.SetUsageTemplate(strings.Replace((&cobra.Command{}).UsageTemplate(), "Global Flags", "Flags Applying to All Commands", -1))
 
-	rootCmd.PersistentFlags().Float64Var(&cmdLineCapMegaBitsPerSecond, "cap-mbps", 0, "Caps the transfer rate, in megabits per second. Moment-by-moment throughput might vary slightly from the cap. If this option is set to zero, or it is omitted, the throughput isn't capped.")
-	rootCmd.PersistentFlags().StringVar(&outputFormatRaw, "output-type", "text", "Format of the command's output. The choices include: text, json. The default value is 'text'.")
+	rootCmd.PersistentFlags().StringVar(&cmdLineCapMbpsRaw, "cap-mbps", "0", "Caps the transfer rate, in megabits per second. Moment-by-moment throughput might vary slightly from the cap. If this option is set to zero, or it is omitted, the throughput isn't capped. Negative values are not allowed. "+
+		"The cap can also follow a schedule of local times of day, e.g. '08:00-18:00=50,18:00-08:00=0' caps the rate at 50 during the working day, and not at all overnight. "+
+		"Windows may wrap around midnight, times outside all the windows aren't capped, and the cap changes as each window starts, without restarting the job.")
+	rootCmd.PersistentFlags().IntVar(&cmdLineMaxIdleConns, "max-idle-conns", 0, "Max number of idle connections to keep open to each host, for re-use by later requests. "+
+		"If this option is set to zero, or it is omitted, it matches the number of concurrent requests. Raise it if a highly concurrent job keeps making new connections.")
+	rootCmd.PersistentFlags().IntVar(&cmdLineMaxOpenFiles, "max-open-files", 0, "Max number of files, being uploaded or downloaded, to have open at once. "+
+		"If this option is set to zero, or it is omitted, it is worked out from the system's limit on file handles. Lower it if a job runs out of file handles.")
+	rootCmd.PersistentFlags().IntVar(&cmdLineMetricsPort, "metrics-port", 0, "Serve Prometheus metrics at http://localhost:<port>/metrics while AzCopy runs: bytes transferred, throughput, chunks succeeded and failed, retries, and active transfers. "+
+		"By default no metrics are served.")
+	rootCmd.PersistentFlags().StringVar(&cmdLineSteURL, "ste-url", "", "URL (http or https) of a transfer engine, possibly on another host, to send jobs to instead of the one that runs inside AzCopy. Run 'azcopy serve' with the same URL to start that transfer engine. "+
+		"If this option is omitted, the value of "+common.EEnvironmentVariable.SteURL().Name+" is used, if that is set.")
+	rootCmd.PersistentFlags().BoolVar(&cmdLineHTTP2, "http2", false, "Offer HTTP/2 when connecting, so that a service which supports it can carry many requests over one connection. False by default, which means HTTP/1.1 is always used.")
+	rootCmd.PersistentFlags().StringVar(&outputFormatRaw, "output-type", "text", "Format of the command's output. The choices include: text, json. The default value is 'text'. "+
+		"With json, progress is written to stderr, and everything else to stdout, one JSON object per line.")
+	rootCmd.PersistentFlags().BoolVar(&quiet, "quiet", false, "Print nothing but errors, e.g. for jobs run by cron. The exit code still says how the job went. "+
+		"This only affects what is printed to the console; what goes to the log file is still set by log-level.")
+	rootCmd.PersistentFlags().StringVar(&logFormatRaw, "log-format", "text", "Format of the log files. The choices include: text, json. With json, each line of the log is a JSON object, "+
+		"with the fields time, level, jobID, transferID (for messages about a single transfer) and message, for easier ingestion by log analysis tools.")
 
 	rootCmd.PersistentFlags().StringVar(&cmdLineExtraSuffixesAAD, trustedSuffixesNameAAD, "", "Specifies additional domain suffixes where Azure Active Directory login tokens may be sent.  The default is '"+
 		trustedSuffixesAAD+"'. Any listed here are added to the default. For security, you should only put Microsoft Azure domains here. Separate multiple entries with semi-colons.")
@@ -0,0 +1,115 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/ste"
+)
+
+type rootCmdSuite struct{}
+
+var _ = chk.Suite(&rootCmdSuite{})
+
+func (s *rootCmdSuite) TestCapMbpsValidation(c *chk.C) {
+	c.Assert(validateCapMbps(0), chk.IsNil) // no cap
+	c.Assert(validateCapMbps(0.5), chk.IsNil)
+	c.Assert(validateCapMbps(1000), chk.IsNil)
+
+	err := validateCapMbps(-10)
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "invalid cap-mbps -10")
+}
+
+func (s *rootCmdSuite) TestParseCapMbps(c *chk.C) {
+	schedule, err := parseCapMbps("100")
+	c.Assert(err, chk.IsNil)
+	c.Assert(schedule.IsScheduled(), chk.Equals, false)
+
+	schedule, err = parseCapMbps("08:00-18:00=50,18:00-08:00=0")
+	c.Assert(err, chk.IsNil)
+	c.Assert(schedule.IsScheduled(), chk.Equals, true)
+
+	_, err = parseCapMbps("08:00-18:00=-5")
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "invalid cap-mbps -5")
+
+	_, err = parseCapMbps("-10")
+	c.Assert(err, chk.NotNil)
+}
+
+func (s *rootCmdSuite) TestRetryJitterValidation(c *chk.C) {
+	defer func(old float32) { ste.UploadRetryJitter = old }(ste.UploadRetryJitter)
+
+	c.Assert(useRetryJitter("0.25"), chk.IsNil)
+	c.Assert(ste.UploadRetryJitter, chk.Equals, float32(0.25))
+	c.Assert(useRetryJitter("0"), chk.IsNil)
+	c.Assert(ste.UploadRetryJitter, chk.Equals, float32(-1)) // off
+
+	for _, raw := range []string{"-0.1", "1.5", "lots"} {
+		err := useRetryJitter(raw)
+		c.Assert(err, chk.NotNil)
+		c.Assert(err.Error(), StringContains, `invalid AZCOPY_RETRY_JITTER "`+raw+`"`)
+	}
+	c.Assert(ste.UploadRetryJitter, chk.Equals, float32(-1)) // a bad value changes nothing
+}
+
+func (s *rootCmdSuite) TestApplyConnectionFlags(c *chk.C) {
+	settings := ste.ConcurrencySettings{MaxIdleConnections: 32}
+
+	// nothing given leaves the automatic choice alone
+	c.Assert(applyConnectionFlags(&settings, 0, false), chk.IsNil)
+	c.Assert(settings.MaxIdleConnections, chk.Equals, 32)
+	c.Assert(settings.ForceHTTP2, chk.Equals, false)
+
+	c.Assert(applyConnectionFlags(&settings, 500, true), chk.IsNil)
+	c.Assert(settings.MaxIdleConnections, chk.Equals, 500)
+	c.Assert(settings.ForceHTTP2, chk.Equals, true)
+
+	err := applyConnectionFlags(&settings, -1, false)
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "invalid max-idle-conns -1")
+}
+
+func (s *rootCmdSuite) TestApplyMaxOpenFiles(c *chk.C) {
+	settings := ste.ConcurrencySettings{MaxOpenFiles: 1000}
+
+	c.Assert(applyMaxOpenFiles(&settings, 0), chk.IsNil)
+	c.Assert(settings.MaxOpenFiles, chk.Equals, 1000) // nothing given leaves the automatic choice alone
+
+	c.Assert(applyMaxOpenFiles(&settings, 64), chk.IsNil)
+	c.Assert(settings.MaxOpenFiles, chk.Equals, 64)
+
+	err := applyMaxOpenFiles(&settings, -1)
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "invalid max-open-files -1")
+}
+
+func (s *rootCmdSuite) TestMetricsPortValidation(c *chk.C) {
+	c.Assert(startMetricsServer(0), chk.IsNil) // no endpoint, so nothing to start
+
+	for _, port := range []int{-1, 65536} {
+		err := startMetricsServer(port)
+		c.Assert(err, chk.NotNil)
+		c.Assert(err.Error(), StringContains, "invalid metrics-port")
+	}
+}
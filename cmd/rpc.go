@@ -22,10 +22,17 @@ package cmd
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/Azure/azure-storage-azcopy/v10/common"
 	"github.com/Azure/azure-storage-azcopy/v10/ste"
@@ -37,6 +44,90 @@ var Rpc = func(cmd common.RpcCmd, request interface{}, response interface{}) {
 	common.PanicIfErr(err)
 }
 
+// configuredSteURL returns the given URL or, if that's empty, the URL in AZCOPY_STE_URL
+func configuredSteURL(steURL string) string {
+	if steURL == "" {
+		steURL = glcm.GetEnvironmentVariable(common.EEnvironmentVariable.SteURL())
+	}
+	return steURL
+}
+
+// useSteURL rebinds Rpc, so that commands go over HTTP to the STE at the given URL (or, if that's empty, at the URL
+// in AZCOPY_STE_URL) instead of to the STE in this process. If no URL is given either way, Rpc is left alone.
+func useSteURL(steURL string) error {
+	steURL = configuredSteURL(steURL)
+	if steURL == "" {
+		return nil
+	}
+	if err := validateSteURL(steURL); err != nil {
+		return err
+	}
+
+	client, err := NewHttpClient(steURL)
+	if err != nil {
+		return err
+	}
+	Rpc = client.Rpc
+	return nil
+}
+
+// validateSteURL checks that the URL is one the front end can send requests to
+func validateSteURL(steURL string) error {
+	u, err := url.Parse(steURL)
+	if err != nil {
+		return fmt.Errorf("invalid ste-url %q: %w", steURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid ste-url %q. It must start with http:// or https://", steURL)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid ste-url %q. It must name a host", steURL)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("invalid ste-url %q. It must not have a query or fragment", steURL)
+	}
+	return nil
+}
+
+// listenAtSteURL opens the port that front ends reach the STE at, when they're given this URL.
+// The STE only serves plain HTTP, at the root of the URL, so URLs that need anything else are refused.
+func listenAtSteURL(steURL string) (net.Listener, error) {
+	if steURL == "" {
+		return nil, fmt.Errorf("no ste-url to listen at. Use --ste-url or %s to give one", common.EEnvironmentVariable.SteURL().Name)
+	}
+	if err := validateSteURL(steURL); err != nil {
+		return nil, err
+	}
+	u, _ := url.Parse(steURL)
+	if u.Scheme != "http" {
+		return nil, fmt.Errorf("cannot serve at ste-url %q. The transfer engine only serves http; put a proxy that terminates TLS in front of it to offer https", steURL)
+	}
+	if strings.Trim(u.Path, "/") != "" {
+		return nil, fmt.Errorf("cannot serve at ste-url %q. The transfer engine only serves at the root of a host, so the URL must not have a path", steURL)
+	}
+	// the commands travel in plain http, so only a secret stops other machines from sending them
+	secretFile := common.EEnvironmentVariable.RpcSecretFile().Name
+	if !isLoopbackHost(u.Hostname()) && glcm.GetEnvironmentVariable(common.EEnvironmentVariable.RpcSecretFile()) == "" {
+		return nil, fmt.Errorf("cannot serve at ste-url %q without %s. The transfer engine can be reached from other machines there, "+
+			"so it must only answer commands signed with a secret", steURL, secretFile)
+	}
+	listener, err := net.Listen("tcp", u.Host)
+	if err != nil {
+		return nil, fmt.Errorf("cannot serve at ste-url %q: %w", steURL, err)
+	}
+	return listener, nil
+}
+
+// isLoopbackHost says whether host, which has no port, can only be reached from this machine.
+// An empty host means every interface
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
 // Send method on HttpClient sends the data passed in the interface for given command type to the client url
 func inprocSend(rpcCmd common.RpcCmd, requestData interface{}, responseData interface{}) error {
 	switch rpcCmd {
@@ -56,7 +147,7 @@ func inprocSend(rpcCmd common.RpcCmd, requestData interface{}, responseData inte
 		*(responseData.(*common.ListJobTransfersResponse)) = ste.ListJobTransfers(requestData.(common.ListJobTransfersRequest))
 
 	case common.ERpcCmd.PauseJob():
-		responseData = ste.CancelPauseJobOrder(requestData.(common.JobID), common.EJobStatus.Paused())
+		*(responseData.(*common.CancelPauseResumeResponse)) = ste.CancelPauseJobOrder(requestData.(common.JobID), common.EJobStatus.Paused())
 
 	case common.ERpcCmd.CancelJob():
 		*(responseData.(*common.CancelPauseResumeResponse)) = ste.CancelPauseJobOrder(requestData.(common.JobID), common.EJobStatus.Cancelling())
@@ -67,6 +158,12 @@ func inprocSend(rpcCmd common.RpcCmd, requestData interface{}, responseData inte
 	case common.ERpcCmd.GetJobFromTo():
 		*(responseData.(*common.GetJobFromToResponse)) = ste.GetJobFromTo(*requestData.(*common.GetJobFromToRequest))
 
+	case common.ERpcCmd.RemoveJobFiles():
+		*(responseData.(*common.RemoveJobFilesResponse)) = ste.RemoveJobFiles(*requestData.(*common.RemoveJobFilesRequest))
+
+	case common.ERpcCmd.Ping():
+		*(responseData.(*common.PingResponse)) = ste.Ping()
+
 	default:
 		panic(fmt.Errorf("Unrecognized RpcCmd: %q", rpcCmd.String()))
 	}
@@ -76,34 +173,70 @@ func inprocSend(rpcCmd common.RpcCmd, requestData interface{}, responseData inte
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
 // NewHttpClient returns the instance of struct containing an instance of http.client and url
-func NewHttpClient(url string) *HTTPClient {
+// If the user has opted in to RPC authentication, every request sent by the client is signed with the session secret
+func NewHttpClient(url string) (*HTTPClient, error) {
+	auth, err := common.NewRpcAuthenticatorFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("cannot load the RPC secret: %w", err)
+	}
+
 	return &HTTPClient{
 		client: &http.Client{},
 		url:    url,
-	}
+		auth:   auth,
+	}, nil
 }
 
+// DefaultRpcTimeout bounds how long the front end waits for the STE to answer a single request,
+// so that a hung STE cannot block the CLI forever
+const DefaultRpcTimeout = 30 * time.Second
+
+// PingRpcTimeout is much shorter than DefaultRpcTimeout, since answering a ping needs no real work from the STE
+const PingRpcTimeout = 5 * time.Second
+
 // todo : use url in case of string
 type HTTPClient struct {
 	client *http.Client
 	url    string
+	auth   *common.RpcAuthenticator // nil if RPC authentication is disabled
 }
 
 // Send method on HttpClient sends the data passed in the interface for given command type to the client url
-func (httpClient *HTTPClient) send(rpcCmd common.RpcCmd, requestData interface{}, responseData interface{}) error {
+// If ctx has no deadline of its own, DefaultRpcTimeout is applied.
+func (httpClient *HTTPClient) send(ctx context.Context, rpcCmd common.RpcCmd, requestData interface{}, responseData interface{}) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultRpcTimeout)
+		defer cancel()
+	}
+
 	// Create HTTP request with command in query parameter & request data as JSON payload
 	requestJson, err := json.Marshal(requestData)
 	if err != nil {
 		return fmt.Errorf("error marshalling request payload for command type %q", rpcCmd.String())
 	}
-	request, err := http.NewRequest("POST", httpClient.url, bytes.NewReader(requestJson))
+	// each command has its own path, which is where the STE's handler for it listens
+	request, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(httpClient.url, "/")+rpcCmd.Pattern(), bytes.NewReader(requestJson))
+	if err != nil {
+		return err
+	}
 	// adding the commandType as a query param
 	q := request.URL.Query()
 	q.Add("commandType", rpcCmd.String())
 	request.URL.RawQuery = q.Encode()
 
+	if httpClient.auth != nil {
+		request.Header.Set("Authorization", httpClient.auth.AuthorizationHeader(rpcCmd, requestJson))
+	}
+
 	response, err := httpClient.client.Do(request)
 	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("STE not responding: no reply to command type %q within the timeout", rpcCmd.String())
+		}
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			return fmt.Errorf("STE not running: nothing is listening at %s", httpClient.url)
+		}
 		return err
 	}
 
@@ -113,9 +246,32 @@ func (httpClient *HTTPClient) send(rpcCmd common.RpcCmd, requestData interface{}
 	if err != nil {
 		return fmt.Errorf("error reading response for the request")
 	}
+	if response.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("the transfer engine rejected the request for command type %q as unauthorized", rpcCmd.String())
+	}
 	err = json.Unmarshal(responseJson, responseData)
 	common.PanicIfErr(err)
 	return nil
 }
 
+// Rpc sends the command to the STE, and, like the in-process Rpc, panics if that fails.
+// The job's lifecycle manager can't be sent over HTTP, so GetJobLCMWrapper leaves the front end with its own.
+func (httpClient *HTTPClient) Rpc(cmd common.RpcCmd, request interface{}, response interface{}) {
+	if cmd == common.ERpcCmd.GetJobLCMWrapper() {
+		return
+	}
+	err := httpClient.send(context.Background(), cmd, request, response)
+	common.PanicIfErr(err)
+}
+
+// Ping checks that the STE is up, waiting at most PingRpcTimeout for it to answer
+func (httpClient *HTTPClient) Ping() (common.PingResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), PingRpcTimeout)
+	defer cancel()
+
+	var resp common.PingResponse
+	err := httpClient.send(ctx, common.ERpcCmd.Ping(), nil, &resp)
+	return resp, err
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,284 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+
+	chk "gopkg.in/check.v1"
+)
+
+type rpcTestSuite struct{}
+
+var _ = chk.Suite(&rpcTestSuite{})
+
+func (s *rpcTestSuite) TestRpcSendTimesOutOnSlowSte(c *chk.C) {
+	// setup a fake STE that never answers in time
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client, err := NewHttpClient(server.URL)
+	c.Assert(err, chk.IsNil)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// act
+	start := time.Now()
+	var resp common.ListJobSummaryResponse
+	err = client.send(ctx, common.ERpcCmd.ListJobSummary(), common.NewJobID(), &resp)
+
+	// verify
+	c.Assert(err, chk.NotNil)
+	c.Assert(err, chk.ErrorMatches, "STE not responding.*")
+	c.Assert(time.Since(start) < 5*time.Second, chk.Equals, true)
+}
+
+func (s *rpcTestSuite) TestRpcSendReturnsResponse(c *chk.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Assert(r.URL.Query().Get("commandType"), chk.Equals, common.ERpcCmd.ListJobSummary().String())
+		w.Write([]byte(`{"TotalTransfers":"3"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewHttpClient(server.URL)
+	c.Assert(err, chk.IsNil)
+	var resp common.ListJobSummaryResponse
+	err = client.send(context.Background(), common.ERpcCmd.ListJobSummary(), common.NewJobID(), &resp)
+	c.Assert(err, chk.IsNil)
+	c.Assert(resp.TotalTransfers, chk.Equals, uint32(3))
+}
+
+// newSigningSte pretends to be an STE that only answers requests signed with auth's secret
+func newSigningSte(c *chk.C, auth *common.RpcAuthenticator) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		c.Assert(err, chk.IsNil)
+		if !auth.IsAuthorized(r.Header.Get("Authorization"), common.ERpcCmd.ListJobSummary(), body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"TotalTransfers":"3"}`))
+	}))
+}
+
+func (s *rpcTestSuite) TestRpcSendSignsRequests(c *chk.C) {
+	auth, err := common.NewRpcAuthenticatorFromFile(filepath.Join(c.MkDir(), "secret"))
+	c.Assert(err, chk.IsNil)
+	server := newSigningSte(c, auth)
+	defer server.Close()
+
+	client, err := NewHttpClient(server.URL)
+	c.Assert(err, chk.IsNil)
+	client.auth = auth
+	var resp common.ListJobSummaryResponse
+	err = client.send(context.Background(), common.ERpcCmd.ListJobSummary(), common.NewJobID(), &resp)
+	c.Assert(err, chk.IsNil)
+	c.Assert(resp.TotalTransfers, chk.Equals, uint32(3))
+}
+
+func (s *rpcTestSuite) TestRpcSendReportsUnauthorized(c *chk.C) {
+	dir := c.MkDir()
+	auth, err := common.NewRpcAuthenticatorFromFile(filepath.Join(dir, "secret"))
+	c.Assert(err, chk.IsNil)
+	stranger, err := common.NewRpcAuthenticatorFromFile(filepath.Join(dir, "otherSecret"))
+	c.Assert(err, chk.IsNil)
+	server := newSigningSte(c, auth)
+	defer server.Close()
+
+	// unsigned, or signed with the wrong secret
+	for _, clientAuth := range []*common.RpcAuthenticator{nil, stranger} {
+		client, err := NewHttpClient(server.URL)
+		c.Assert(err, chk.IsNil)
+		client.auth = clientAuth
+		var resp common.ListJobSummaryResponse
+		err = client.send(context.Background(), common.ERpcCmd.ListJobSummary(), common.NewJobID(), &resp)
+		c.Assert(err, chk.ErrorMatches, `the transfer engine rejected the request for command type "ListJobSummary" as unauthorized`)
+	}
+}
+
+func (s *rpcTestSuite) TestRpcPingAgainstFakeSte(c *chk.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Assert(r.URL.Query().Get("commandType"), chk.Equals, common.ERpcCmd.Ping().String())
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"Version":"10.14.1","Uptime":90000000000,"ActiveJobs":2}`))
+	}))
+	defer server.Close()
+
+	client, err := NewHttpClient(server.URL)
+	c.Assert(err, chk.IsNil)
+	resp, err := client.Ping()
+	c.Assert(err, chk.IsNil)
+	c.Assert(resp.Version, chk.Equals, "10.14.1")
+	c.Assert(resp.Uptime, chk.Equals, 90*time.Second)
+	c.Assert(resp.ActiveJobs, chk.Equals, 2)
+}
+
+func (s *rpcTestSuite) TestRpcPingWhenSteNotRunning(c *chk.C) {
+	// grab a free port, then stop listening on it, so that connections to it are refused
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	client, err := NewHttpClient(url)
+	c.Assert(err, chk.IsNil)
+	_, err = client.Ping()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err, chk.ErrorMatches, "STE not running: nothing is listening at "+url)
+}
+
+func (s *rpcTestSuite) TestPingSteGoesToItsURL(c *chk.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Assert(r.URL.Path, chk.Equals, common.ERpcCmd.Ping().Pattern())
+		w.Write([]byte(`{"Version":"10.14.1"}`))
+	}))
+	defer server.Close()
+
+	resp, err := pingSte(server.URL)
+	c.Assert(err, chk.IsNil)
+	c.Assert(resp.Version, chk.Equals, "10.14.1")
+
+	server.Close()
+	_, err = pingSte(server.URL)
+	c.Assert(err, chk.ErrorMatches, "the transfer engine at "+server.URL+" did not answer the ping: STE not running.*")
+
+	// there's no point pinging the STE in this process, which is always up
+	_, err = pingSte("")
+	c.Assert(err, chk.ErrorMatches, "no transfer engine to ping.*")
+}
+
+func (s *rpcTestSuite) TestPingOutput(c *chk.C) {
+	resp := common.PingResponse{Version: "10.14.1", Uptime: 90*time.Second + 400*time.Millisecond, ActiveJobs: 1}
+
+	c.Assert(pingOutput(resp, common.EOutputFormat.Text()), chk.Equals,
+		"The transfer engine is running. Version: 10.14.1; Uptime: 1m30s; Active jobs: 1")
+	c.Assert(pingOutput(resp, common.EOutputFormat.Json()), chk.Equals,
+		`{"ErrorMsg":"","Version":"10.14.1","Uptime":90400000000,"ActiveJobs":1}`)
+}
+
+func (s *rpcTestSuite) TestCommandsGoToConfiguredSteURL(c *chk.C) {
+	received := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.URL.Path+"?"+r.URL.RawQuery)
+		w.Write([]byte(`{"TotalTransfers":"7"}`))
+	}))
+	defer server.Close()
+
+	originalRpc := Rpc
+	defer func() { Rpc = originalRpc }()
+	c.Assert(useSteURL(server.URL+"/"), chk.IsNil)
+
+	var summary common.ListJobSummaryResponse
+	Rpc(common.ERpcCmd.ListJobSummary(), common.NewJobID(), &summary)
+	c.Assert(summary.TotalTransfers, chk.Equals, uint32(7))
+
+	// the lifecycle manager stays local, so asking for it doesn't reach the STE
+	var lcm common.LifecycleMgr
+	Rpc(common.ERpcCmd.GetJobLCMWrapper(), common.NewJobID(), &lcm)
+
+	c.Assert(received, chk.DeepEquals, []string{"/ListJobSummary?commandType=ListJobSummary"})
+}
+
+func (s *rpcTestSuite) TestSteURLFromEnvironment(c *chk.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Version":"10.14.1"}`))
+	}))
+	defer server.Close()
+
+	originalRpc := Rpc
+	defer func() { Rpc = originalRpc }()
+	glcm = &mockedLifecycleManager{}
+	c.Assert(os.Setenv(common.EEnvironmentVariable.SteURL().Name, server.URL), chk.IsNil)
+	defer os.Unsetenv(common.EEnvironmentVariable.SteURL().Name)
+
+	c.Assert(useSteURL(""), chk.IsNil)
+	var resp common.PingResponse
+	Rpc(common.ERpcCmd.Ping(), nil, &resp)
+	c.Assert(resp.Version, chk.Equals, "10.14.1")
+}
+
+func (s *rpcTestSuite) TestInvalidSteURL(c *chk.C) {
+	originalRpc := Rpc
+	defer func() { Rpc = originalRpc }()
+
+	for _, steURL := range []string{"localhost:1337", "ftp://host:1337", "http://", "http://host:1337/?x=1", "http://host:%zz"} {
+		err := useSteURL(steURL)
+		c.Assert(err, chk.NotNil)
+		c.Assert(err.Error(), StringContains, "invalid ste-url")
+	}
+}
+
+func (s *rpcTestSuite) TestListenAtSteURL(c *chk.C) {
+	listener, err := listenAtSteURL("http://127.0.0.1:0/")
+	c.Assert(err, chk.IsNil)
+	listener.Close()
+
+	for steURL, complaint := range map[string]string{
+		"":                           "no ste-url to listen at",
+		"localhost:1337":             "invalid ste-url",
+		"https://127.0.0.1:0":        "only serves http",
+		"http://127.0.0.1:0/azcopy/": "must not have a path",
+		"http://0.0.0.0:0":           "without " + common.EEnvironmentVariable.RpcSecretFile().Name,
+		"http://:0":                  "without " + common.EEnvironmentVariable.RpcSecretFile().Name,
+	} {
+		_, err := listenAtSteURL(steURL)
+		c.Assert(err, chk.NotNil, chk.Commentf("ste-url %q", steURL))
+		c.Assert(err.Error(), StringContains, complaint)
+	}
+}
+
+func (s *rpcTestSuite) TestListeningOffLoopbackNeedsASecret(c *chk.C) {
+	c.Assert(os.Setenv(common.EEnvironmentVariable.RpcSecretFile().Name, filepath.Join(c.MkDir(), "secret")), chk.IsNil)
+	defer os.Unsetenv(common.EEnvironmentVariable.RpcSecretFile().Name)
+
+	listener, err := listenAtSteURL("http://0.0.0.0:0")
+	c.Assert(err, chk.IsNil)
+	listener.Close()
+
+	c.Assert(isLoopbackHost("localhost"), chk.Equals, true)
+	c.Assert(isLoopbackHost("::1"), chk.Equals, true)
+	c.Assert(isLoopbackHost("127.0.0.2"), chk.Equals, true)
+	c.Assert(isLoopbackHost(""), chk.Equals, false)
+	c.Assert(isLoopbackHost("10.0.0.1"), chk.Equals, false)
+	c.Assert(isLoopbackHost("steserver"), chk.Equals, false)
+}
+
+func (s *rpcTestSuite) TestUnreadableSecretIsAnError(c *chk.C) {
+	// a folder can't be read as a secret file
+	c.Assert(os.Setenv(common.EEnvironmentVariable.RpcSecretFile().Name, c.MkDir()), chk.IsNil)
+	defer os.Unsetenv(common.EEnvironmentVariable.RpcSecretFile().Name)
+
+	_, err := NewHttpClient("http://127.0.0.1:1337")
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "cannot load the RPC secret")
+}
@@ -0,0 +1,58 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"errors"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-azcopy/v10/ste"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	// serveCmd represents the serve command
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run the transfer engine for other AzCopy processes to send commands to",
+		Long: "Run the transfer engine (STE) until AzCopy is stopped, answering the commands that AzCopy processes given the same --ste-url send to it. " +
+			"It listens at the host and port of --ste-url, or of " + common.EEnvironmentVariable.SteURL().Name + " if the flag is omitted. The URL must be http, with no path. " +
+			"Set " + common.EEnvironmentVariable.RpcSecretFile().Name + " to the same secret file here and in the senders, to only answer commands signed with that secret. " +
+			"The secret is required unless the host is a loopback address, such as localhost, since the commands are sent in plain http.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return errors.New("this command does not take any arguments")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			steURL := configuredSteURL(cmdLineSteURL)
+			listener, err := listenAtSteURL(steURL)
+			if err != nil {
+				glcm.Error(err.Error())
+			}
+			glcm.Info("The transfer engine is listening at " + steURL)
+			err = ste.ServeRPC(listener)
+			glcm.Error("the transfer engine stopped listening: " + err.Error())
+		},
+	}
+	rootCmd.AddCommand(serveCmd)
+}
@@ -63,6 +63,7 @@ type rawSyncCmdArgs struct {
 	backupMode             bool
 	putMd5                 bool
 	md5ValidationOption    string
+	compareHash            string
 	// this flag indicates the user agreement with respect to deleting the extra files at the destination
 	// which do not exists at source. With this flag turned on/off, users will not be asked for permission.
 	// otherwise the user is prompted to make a decision
@@ -85,8 +86,15 @@ type rawSyncCmdArgs struct {
 	// Key is present in AzureKeyVault and Azure KeyVault is linked with storage account.
 	// Provided key name will be fetched from Azure Key Vault and will be used to encrypt the data
 	cpkScopeInfo string
+	// Key (and optionally its hash) to use instead of the ones in the environment variables. Implies cpkInfo
+	cpkEncryptionKey       string
+	cpkEncryptionKeySHA256 string
 	// dry run mode bool
 	dryrun bool
+	// skip the confirmation prompt before deleting extra files with --delete-destination=true
+	yes bool
+	// file holding the SAS token of the destination, see applyOutOfBandSAS
+	sasTokenFile string
 }
 
 func (raw *rawSyncCmdArgs) parsePatterns(pattern string) (cookedPatterns []string) {
@@ -183,6 +191,10 @@ func (raw *rawSyncCmdArgs) cook() (cookedSyncCmdArgs, error) {
 		cooked.destination = common.ResourceString{Value: common.ToExtendedPath(cleanLocalPath(raw.dst))}
 	}
 
+	if err = applyOutOfBandSAS(&cooked.destination, cooked.fromTo.To(), raw.sasTokenFile); err != nil {
+		return cooked, err
+	}
+
 	// we do not support service level sync yet
 	if cooked.fromTo.From().IsRemote() {
 		err = raw.validateURLIsNotServiceLevel(cooked.source.Value, cooked.fromTo.From())
@@ -242,7 +254,8 @@ func (raw *rawSyncCmdArgs) cook() (cookedSyncCmdArgs, error) {
 	cooked.includeFileAttributes = raw.parsePatterns(raw.includeFileAttributes)
 	cooked.excludeFileAttributes = raw.parsePatterns(raw.excludeFileAttributes)
 
-	cooked.preserveSMBInfo = areBothLocationsSMBAware(cooked.fromTo)
+	isUserPersistingPermissions := raw.preserveSMBPermissions || raw.preservePermissions
+	cooked.preserveSMBInfo = areBothLocationsSMBAware(cooked.fromTo) || areSMBPropertiesMappedFromPOSIX(cooked.fromTo, isUserPersistingPermissions)
 	// If user has explicitly specified not to copy SMB Information, set cooked.preserveSMBInfo to false
 	if !raw.preserveSMBInfo {
 		cooked.preserveSMBInfo = false
@@ -252,7 +265,6 @@ func (raw *rawSyncCmdArgs) cook() (cookedSyncCmdArgs, error) {
 		return cooked, err
 	}
 
-	isUserPersistingPermissions := raw.preserveSMBPermissions || raw.preservePermissions
 	if cooked.preserveSMBInfo && !isUserPersistingPermissions {
 		glcm.Info("Please note: the preserve-permissions flag is set to false, thus AzCopy will not copy SMB ACLs between the source and destination. To learn more: https://aka.ms/AzCopyandAzureFiles.")
 	}
@@ -299,18 +311,19 @@ func (raw *rawSyncCmdArgs) cook() (cookedSyncCmdArgs, error) {
 
 	// Setting CPK-N
 	cpkOptions := common.CpkOptions{}
+	// Setting CPK-V
+	// The key (EncryptionKey and EncryptionKeySHA256) comes from the command line, or else from environment variables.
+	if cpkOptions.CpkInfo, err = cookCpkByValue(raw.cpkInfo, raw.cpkEncryptionKey, raw.cpkEncryptionKeySHA256); err != nil {
+		return cooked, err
+	}
 	// Setting CPK-N
 	if raw.cpkScopeInfo != "" {
-		if raw.cpkInfo {
+		if cpkOptions.CpkInfo {
 			return cooked, fmt.Errorf("cannot use both cpk-by-name and cpk-by-value at the same time")
 		}
 		cpkOptions.CpkScopeInfo = raw.cpkScopeInfo
 	}
 
-	// Setting CPK-V
-	// Get the key (EncryptionKey and EncryptionKeySHA256) value from environment variables when required.
-	cpkOptions.CpkInfo = raw.cpkInfo
-
 	// We only support transfer from source encrypted by user key when user wishes to download.
 	// Due to service limitation, S2S transfer is not supported for source encrypted by user key.
 	if cooked.fromTo.IsDownload() && (cpkOptions.CpkScopeInfo != "" || cpkOptions.CpkInfo) {
@@ -323,14 +336,47 @@ func (raw *rawSyncCmdArgs) cook() (cookedSyncCmdArgs, error) {
 
 	cooked.mirrorMode = raw.mirrorMode
 
+	if err = cooked.compareHash.Parse(raw.compareHash); err != nil {
+		return cooked, fmt.Errorf("invalid compare-hash %q. The choices are None and MD5", raw.compareHash)
+	}
+	if err = validateCompareHash(cooked.compareHash, cooked.fromTo, cooked.mirrorMode); err != nil {
+		return cooked, err
+	}
+	if cooked.compareHash == common.ESyncHashType.MD5() {
+		// save the hash of every file we upload, so that the next sync can compare with it
+		cooked.putMd5 = true
+	}
+
 	cooked.includeRegex = raw.parsePatterns(raw.includeRegex)
 	cooked.excludeRegex = raw.parsePatterns(raw.excludeRegex)
 
 	cooked.dryrunMode = raw.dryrun
 
+	// with --delete-destination=true, the deletions are confirmed once, up front, unless the user has already said yes
+	cooked.confirmDeletions = cooked.deleteDestination == common.EDeleteDestination.True() && !raw.yes && !cooked.dryrunMode
+	if cooked.confirmDeletions && !stdinIsTerminal() {
+		return cooked, fmt.Errorf("--delete-destination=true requires confirmation, which cannot be given when azcopy is not run from a terminal. " +
+			"Please use --yes to confirm the deletions in advance")
+	}
+
 	return cooked, nil
 }
 
+// validateCompareHash checks that files can be compared by their hashes. For now, that's only when uploading to blobs,
+// since the local files are hashed as we go and compared with the Content-MD5 listed for each blob.
+func validateCompareHash(compareHash common.SyncHashType, fromTo common.FromTo, mirrorMode bool) error {
+	if compareHash == common.ESyncHashType.None() {
+		return nil
+	}
+	if fromTo != common.EFromTo.LocalBlob() {
+		return fmt.Errorf("compare-hash is only supported when syncing local files to Blob storage")
+	}
+	if mirrorMode {
+		return fmt.Errorf("compare-hash cannot be used with mirror-mode, which transfers every file without comparing them")
+	}
+	return nil
+}
+
 type cookedSyncCmdArgs struct {
 	// NOTE: for the 64 bit atomic functions to work on a 32 bit system, we have to guarantee the right 64-bit alignment
 	// so the 64 bit integers are placed first in the struct to avoid future breaks
@@ -371,6 +417,7 @@ type cookedSyncCmdArgs struct {
 	preserveSMBInfo     bool
 	putMd5              bool
 	md5ValidationOption common.HashValidationOption
+	compareHash         common.SyncHashType
 	blockSize           int64
 	logVerbosity        common.LogLevel
 	forceIfReadOnly     bool
@@ -411,6 +458,9 @@ type cookedSyncCmdArgs struct {
 	mirrorMode bool
 
 	dryrunMode bool
+
+	// whether the extra files found at the destination are held back until the user confirms they can all be deleted
+	confirmDeletions bool
 }
 
 func (cca *cookedSyncCmdArgs) incrementDeletionCount() {
@@ -538,7 +588,7 @@ func (cca *cookedSyncCmdArgs) ReportProgressOrExit(lcm common.LifecycleMgr) (tot
 	if cca.firstPartOrdered() {
 		Rpc(common.ERpcCmd.ListJobSummary(), &cca.jobID, &summary)
 		Rpc(common.ERpcCmd.GetJobLCMWrapper(), &cca.jobID, &lcm)
-		jobDone = summary.JobStatus.IsJobDone()
+		jobDone = isJobDoneOrPaused(summary.JobStatus)
 		totalKnownCount = summary.TotalTransfers
 
 		// compute the average throughput for the last time interval
@@ -559,16 +609,13 @@ func (cca *cookedSyncCmdArgs) ReportProgressOrExit(lcm common.LifecycleMgr) (tot
 	}
 
 	if jobDone {
-		exitCode := common.EExitCode.Success()
-		if summary.TransfersFailed > 0 {
-			exitCode = common.EExitCode.Error()
-		}
+		exitCode := jobExitCode(summary, common.EExitCode.Success())
 
 		lcm.Exit(func(format common.OutputFormat) string {
 			if format == common.EOutputFormat.Json() {
 				return cca.getJsonOfSyncJobSummary(summary)
 			}
-			screenStats, logStats := formatExtraStats(cca.fromTo, summary.AverageIOPS, summary.AverageE2EMilliseconds, summary.NetworkErrorPercentage, summary.ServerBusyPercentage)
+			screenStats, logStats := formatExtraStats(cca.fromTo, summary, duration)
 
 			output := fmt.Sprintf(
 				`
@@ -638,6 +685,8 @@ func (cca *cookedSyncCmdArgs) process() (err error) {
 		return err
 	}
 
+	warnIfSASIsNotValidNow(cca.source, cca.destination)
+
 	// Verifies credential type and initializes credential info.
 	// Note that this is for the destination.
 	cca.credentialInfo, _, err = GetCredentialInfoForLocation(ctx, cca.fromTo.To(), cca.destination.Value, cca.destination.SAS, false, cca.cpkOptions)
@@ -712,7 +761,7 @@ func init() {
 
 			cooked, err := raw.cook()
 			if err != nil {
-				glcm.Error("error parsing the input given by the user. Failed with error " + err.Error())
+				glcm.ExitWithError("error parsing the input given by the user. Failed with error "+err.Error(), common.EExitCode.InvalidUsage())
 			}
 			cooked.commandString = copyHandlerUtil{}.ConstructCommandStringFromArgs()
 			err = cooked.process()
@@ -741,7 +790,8 @@ func init() {
 	//syncCmd.PersistentFlags().BoolVar(&raw.preserveOwner, common.PreserveOwnerFlagName, common.PreserveOwnerDefault, "Only has an effect in downloads, and only when --preserve-smb-permissions is used. If true (the default), the file Owner and Group are preserved in downloads. If set to false, --preserve-smb-permissions will still preserve ACLs but Owner and Group will be based on the user running AzCopy")
 	//syncCmd.PersistentFlags().BoolVar(&raw.backupMode, common.BackupModeFlagName, false, "Activates Windows' SeBackupPrivilege for uploads, or SeRestorePrivilege for downloads, to allow AzCopy to see read all files, regardless of their file system permissions, and to restore all permissions. Requires that the account running AzCopy already has these permissions (e.g. has Administrator rights or is a member of the 'Backup Operators' group). All this flag does is activate privileges that the account already has")
 
-	syncCmd.PersistentFlags().Float64Var(&raw.blockSizeMB, "block-size-mb", 0, "Use this block size (specified in MiB) when uploading to Azure Storage or downloading from Azure Storage. Default is automatically calculated based on file size. Decimal fractions are allowed (For example: 0.25).")
+	syncCmd.PersistentFlags().Float64Var(&raw.blockSizeMB, "block-size-mb", 0, "Use this block size (specified in MiB) when uploading to Azure Storage or downloading from Azure Storage. Default is automatically calculated based on file size. Decimal fractions are allowed (For example: 0.25). "+
+		"Block blobs can use blocks of up to 4000 MiB.")
 	syncCmd.PersistentFlags().StringVar(&raw.include, "include-pattern", "", "Include only files where the name matches the pattern list. For example: *.jpg;*.pdf;exactName")
 	syncCmd.PersistentFlags().StringVar(&raw.exclude, "exclude-pattern", "", "Exclude files where the name matches the pattern list. For example: *.jpg;*.pdf;exactName")
 	syncCmd.PersistentFlags().StringVar(&raw.excludePath, "exclude-path", "", "Exclude these paths when comparing the source against the destination. "+
@@ -750,10 +800,12 @@ func init() {
 	syncCmd.PersistentFlags().StringVar(&raw.excludeFileAttributes, "exclude-attributes", "", "(Windows only) Exclude files whose attributes match the attribute list. For example: A;S;R")
 	syncCmd.PersistentFlags().StringVar(&raw.includeRegex, "include-regex", "", "Include the relative path of the files that match with the regular expressions. Separate regular expressions with ';'.")
 	syncCmd.PersistentFlags().StringVar(&raw.excludeRegex, "exclude-regex", "", "Exclude the relative path of the files that match with the regular expressions. Separate regular expressions with ';'.")
-	syncCmd.PersistentFlags().StringVar(&raw.logVerbosity, "log-level", "INFO", "Define the log verbosity for the log file, available levels: INFO(all requests and responses), WARNING(slow responses), ERROR(only failed requests), and NONE(no output logs). (default INFO).")
+	syncCmd.PersistentFlags().StringVar(&raw.logVerbosity, "log-level", "INFO", "Define the log verbosity for the log file, available levels: DEBUG(INFO plus details of each chunk), INFO(all requests and responses), WARNING(slow responses), ERROR(only failed requests), and NONE(no output logs). (default INFO).")
 	syncCmd.PersistentFlags().StringVar(&raw.deleteDestination, "delete-destination", "false", "Defines whether to delete extra files from the destination that are not present at the source. Could be set to true, false, or prompt. "+
-		"If set to prompt, the user will be asked a question before scheduling files and blobs for deletion. (default 'false').")
-	syncCmd.PersistentFlags().BoolVar(&raw.putMd5, "put-md5", false, "Create an MD5 hash of each file, and save the hash as the Content-MD5 property of the destination blob or file. (By default the hash is NOT created.) Only available when uploading.")
+		"If set to prompt, the user will be asked a question before scheduling files and blobs for deletion. "+
+		"If set to true, the user will be asked once, after the extra files have been counted, whether to delete them; use --yes to skip the question. (default 'false').")
+	syncCmd.PersistentFlags().BoolVar(&raw.putMd5, "put-md5", false, "Create an MD5 hash of each file, and save the hash as the Content-MD5 property of the destination blob or file. (By default the hash is NOT created.) "+
+		"Block blobs also get the hash, base64-encoded, in an 'md5' metadata entry, which takes precedence over any 'md5' key given with --metadata. Only available when uploading.")
 	syncCmd.PersistentFlags().StringVar(&raw.md5ValidationOption, "check-md5", common.DefaultHashValidationOption.String(), "Specifies how strictly MD5 hashes should be validated when downloading. This option is only available when downloading. Available values include: NoCheck, LogOnly, FailIfDifferent, FailIfDifferentOrMissing. (default 'FailIfDifferent').")
 	syncCmd.PersistentFlags().BoolVar(&raw.s2sPreserveAccessTier, "s2s-preserve-access-tier", true, "Preserve access tier during service to service copy. "+
 		"Please refer to [Azure Blob storage: hot, cool, and archive access tiers](https://docs.microsoft.com/azure/storage/blobs/storage-blob-storage-tiers) to ensure destination storage account supports setting access tier. "+
@@ -765,8 +817,21 @@ func init() {
 	// Customer-provided keys can be stored in Azure Key Vault or in another key store linked to storage account.
 	syncCmd.PersistentFlags().StringVar(&raw.cpkScopeInfo, "cpk-by-name", "", "Client provided key by name let clients making requests against Azure Blob storage an option to provide an encryption key on a per-request basis. Provided key name will be fetched from Azure Key Vault and will be used to encrypt the data")
 	syncCmd.PersistentFlags().BoolVar(&raw.cpkInfo, "cpk-by-value", false, "Client provided key by name let clients making requests against Azure Blob storage an option to provide an encryption key on a per-request basis. Provided key and its hash will be fetched from environment variables")
+	syncCmd.PersistentFlags().StringVar(&raw.cpkEncryptionKey, "cpk-encryption-key", "", "Base64 encoded AES-256 key to encrypt or decrypt the data with, on every request to Azure Blob storage. "+
+		"Implies --cpk-by-value, and is used instead of the key in the environment variable "+common.EEnvironmentVariable.CPKEncryptionKey().Name+". "+
+		"Note that command lines can be seen by other users of the machine, which the environment variable avoids. "+
+		"Can't be used with --ste-url, since the key isn't sent to the transfer engine.")
+	syncCmd.PersistentFlags().StringVar(&raw.cpkEncryptionKeySHA256, "cpk-encryption-key-sha256", "", "Base64 encoded SHA-256 hash of the key given with --cpk-encryption-key. "+
+		"It's computed from the key if not given; if given, it must match the key.")
+	syncCmd.PersistentFlags().StringVar(&raw.compareHash, "compare-hash", common.ESyncHashType.None().String(), "Compare files by their hashes, rather than their last modified times, to decide whether they have changed. "+
+		"Available values include: None, MD5. With MD5, a local file is uploaded only if it is new, its size differs from the blob's, or its MD5 hash differs from the blob's Content-MD5. "+
+		"Files are only read to hash them when their sizes match. Blobs without a Content-MD5 are compared by last modified time. This implies --put-md5. Only available when uploading to Blob storage.")
 	syncCmd.PersistentFlags().BoolVar(&raw.mirrorMode, "mirror-mode", false, "Disable last-modified-time based comparison and overwrites the conflicting files and blobs at the destination if this flag is set to true. Default is false")
 	syncCmd.PersistentFlags().BoolVar(&raw.dryrun, "dry-run", false, "Prints the path of files that would be copied or removed by the sync command. This flag does not copy or remove the actual files.")
+	syncCmd.PersistentFlags().BoolVar(&raw.yes, "yes", false, "Confirm in advance the deletions made by --delete-destination=true, so that no question is asked. "+
+		"Required when azcopy is not run from a terminal, such as in scripts and scheduled tasks.")
+	syncCmd.PersistentFlags().StringVar(&raw.sasTokenFile, "sas-token-file", "", "Path of a file holding the SAS token for the destination, so that it doesn't have to be put in the destination URL, "+
+		"where it would end up in the shell history. The AZCOPY_SAS_TOKEN environment variable can be used instead, with AZCOPY_SAS_TOKEN_ACCOUNT set to the name of the account it is for.")
 
 	// temp, to assist users with change in param names, by providing a clearer message when these obsolete ones are accidentally used
 	syncCmd.PersistentFlags().StringVar(&raw.legacyInclude, "include", "", "Legacy include param. DO NOT USE")
@@ -20,7 +20,27 @@
 
 package cmd
 
-import "strings"
+import (
+	"strings"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// syncHashComparer compares a source file with its destination by their hashes.
+// It says verifyOutcomeNoHash if there's no hash at the destination to compare with.
+type syncHashComparer func(source StoredObject, destination StoredObject) verifyOutcome
+
+// newLocalMD5Comparer compares local files with the Content-MD5 of their blobs. Files whose sizes differ are known to have
+// changed without being read.
+func newLocalMD5Comparer(localRoot string) syncHashComparer {
+	return func(source StoredObject, destination StoredObject) verifyOutcome {
+		outcome, _, err := verifyLocalFile(common.GenerateFullPath(localRoot, source.relativePath), source, &destination)
+		if err != nil {
+			return verifyOutcomeMismatched // let the transfer try it, so that the failure is reported like any other
+		}
+		return outcome
+	}
+}
 
 // with the help of an objectIndexer containing the source objects
 // find out the destination objects that should be transferred
@@ -36,10 +56,30 @@ type syncDestinationComparator struct {
 	sourceIndex *objectIndexer
 
 	disableComparison bool
+
+	// when set, files are compared by their hashes rather than their last modified times
+	compareHash syncHashComparer
 }
 
-func newSyncDestinationComparator(i *objectIndexer, copyScheduler, cleaner objectProcessor, disableComparison bool) *syncDestinationComparator {
-	return &syncDestinationComparator{sourceIndex: i, copyTransferScheduler: copyScheduler, destinationCleaner: cleaner, disableComparison: disableComparison}
+func newSyncDestinationComparator(i *objectIndexer, copyScheduler, cleaner objectProcessor, disableComparison bool, compareHash syncHashComparer) *syncDestinationComparator {
+	return &syncDestinationComparator{sourceIndex: i, copyTransferScheduler: copyScheduler, destinationCleaner: cleaner, disableComparison: disableComparison, compareHash: compareHash}
+}
+
+// isStale says whether the destination object should be replaced by its source
+func (f *syncDestinationComparator) isStale(sourceObject, destinationObject StoredObject) bool {
+	if f.compareHash == nil || sourceObject.entityType != common.EEntityType.File() {
+		return sourceObject.isMoreRecentThan(destinationObject)
+	}
+
+	switch f.compareHash(sourceObject, destinationObject) {
+	case verifyOutcomeMatched:
+		return false
+	case verifyOutcomeNoHash:
+		// nothing to compare the hash with, so fall back to the last modified times
+		return sourceObject.isMoreRecentThan(destinationObject)
+	default:
+		return true
+	}
 }
 
 // it will only schedule transfers for destination objects that are present in the indexer but stale compared to the entry in the map
@@ -57,7 +97,7 @@ func (f *syncDestinationComparator) processIfNecessary(destinationObject StoredO
 	// if the destinationObject is present at source and stale, we transfer the up-to-date version from source
 	if present {
 		defer delete(f.sourceIndex.indexMap, destinationObject.relativePath)
-		if f.disableComparison || sourceObjectInMap.isMoreRecentThan(destinationObject) {
+		if f.disableComparison || f.isStale(sourceObjectInMap, destinationObject) {
 			err := f.copyTransferScheduler(sourceObjectInMap)
 			if err != nil {
 				return err
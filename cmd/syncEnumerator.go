@@ -152,7 +152,11 @@ func (cca *cookedSyncCmdArgs) initEnumerator(ctx context.Context) (enumerator *s
 		// when uploading, we can delete remote objects immediately, because as we traverse the remote location
 		// we ALREADY have available a complete map of everything that exists locally
 		// so as soon as we see a remote destination object we can know whether it exists in the local source
-		comparator = newSyncDestinationComparator(indexer, transferScheduler.scheduleCopyTransfer, destCleanerFunc, cca.mirrorMode).processIfNecessary
+		var compareHash syncHashComparer
+		if cca.compareHash == common.ESyncHashType.MD5() {
+			compareHash = newLocalMD5Comparer(cca.source.ValueLocal())
+		}
+		comparator = newSyncDestinationComparator(indexer, transferScheduler.scheduleCopyTransfer, destCleanerFunc, cca.mirrorMode, compareHash).processIfNecessary
 		finalize = func() error {
 			// schedule every local file that doesn't exist at the destination
 			err = indexer.traverse(transferScheduler.scheduleCopyTransfer, filters)
@@ -160,6 +164,12 @@ func (cca *cookedSyncCmdArgs) initEnumerator(ctx context.Context) (enumerator *s
 				return err
 			}
 
+			// the destination has been fully traversed, so we know every extra object that the user may be asked about
+			err = destinationCleaner.deletePending()
+			if err != nil {
+				return err
+			}
+
 			jobInitiated, err := transferScheduler.dispatchFinalPart()
 			// sync cleanly exits if nothing is scheduled.
 			if err != nil && err != NothingScheduledError {
@@ -182,19 +192,23 @@ func (cca *cookedSyncCmdArgs) initEnumerator(ctx context.Context) (enumerator *s
 			// remove the extra files at the destination that were not present at the source
 			// we can only know what needs to be deleted when we have FINISHED traversing the remote source
 			// since only then can we know which local files definitely don't exist remotely
-			var deleteScheduler objectProcessor
+			var deleter *interactiveDeleteProcessor
 			switch cca.fromTo.To() {
 			case common.ELocation.Blob(), common.ELocation.File():
-				deleter, err := newSyncDeleteProcessor(cca)
+				deleter, err = newSyncDeleteProcessor(cca)
 				if err != nil {
 					return err
 				}
-				deleteScheduler = newFpoAwareProcessor(fpo, deleter.removeImmediately)
 			default:
-				deleteScheduler = newFpoAwareProcessor(fpo, newSyncLocalDeleteProcessor(cca).removeImmediately)
+				deleter = newSyncLocalDeleteProcessor(cca)
+			}
+
+			err = indexer.traverse(newFpoAwareProcessor(fpo, deleter.removeImmediately), nil)
+			if err != nil {
+				return err
 			}
 
-			err = indexer.traverse(deleteScheduler, nil)
+			err = deleter.deletePending()
 			if err != nil {
 				return err
 			}
@@ -99,6 +99,21 @@ type interactiveDeleteProcessor struct {
 
 	//dryrunMode
 	dryrunMode bool
+
+	// whether deletions are held back in pending until the user confirms them all at once, see deletePending
+	confirmFirst bool
+	pending      []StoredObject
+}
+
+// maxPendingDeletions caps how many deletions are held back, waiting to be confirmed, so that a destination with a
+// great many extra objects doesn't run azcopy out of memory. Once it's reached, the user is asked straight away,
+// and the answer goes for the rest of the extra objects too. It's a variable so that tests can lower it.
+var maxPendingDeletions = 100000
+
+// stdinIsTerminal reports whether the user can answer a prompt. It's a variable so that tests can replace it.
+var stdinIsTerminal = func() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
 }
 
 func newDeleteTransfer(object StoredObject) (newDeleteTransfer common.CopyTransfer) {
@@ -128,6 +143,14 @@ func (d *interactiveDeleteProcessor) removeImmediately(object StoredObject) (err
 		return nil
 	}
 
+	if d.confirmFirst {
+		d.pending = append(d.pending, object)
+		if len(d.pending) >= maxPendingDeletions {
+			return d.confirmPending(true)
+		}
+		return nil
+	}
+
 	if d.dryrunMode {
 		glcm.Dryrun(func(format common.OutputFormat) string {
 			if format == common.EOutputFormat.Json() {
@@ -197,8 +220,58 @@ func (d *interactiveDeleteProcessor) promptForConfirmation(object StoredObject)
 	}
 }
 
+// deletePending asks the user, once, whether the deletions held back so far should go ahead, and carries them out if so.
+// It must be called after the last object has been given to removeImmediately.
+func (d *interactiveDeleteProcessor) deletePending() error {
+	return d.confirmPending(false)
+}
+
+// confirmPending asks the user whether the deletions held back so far should go ahead, and carries them out if so.
+// Either way, the objects given to removeImmediately afterwards are dealt with the same way, without asking again.
+// moreToCome says whether there may be more extra objects than those held back.
+func (d *interactiveDeleteProcessor) confirmPending(moreToCome bool) error {
+	pending := d.pending
+	d.pending = nil
+	d.confirmFirst = false
+	if len(pending) == 0 {
+		return nil
+	}
+
+	count := fmt.Sprintf("%d", len(pending))
+	if moreToCome {
+		count = fmt.Sprintf("At least %d", len(pending))
+	}
+	answer := glcm.Prompt(fmt.Sprintf("%s %s(s) do not exist at the source. "+
+		"Do you wish to delete them from the destination(%s)?",
+		count, d.objectTypeToDisplay, d.objectLocationToDisplay),
+		common.PromptDetails{
+			PromptType: common.EPromptType.DeleteDestination(),
+			ResponseOptions: []common.ResponseOption{
+				common.EResponseOption.Yes(),
+				common.EResponseOption.No()},
+		},
+	)
+
+	if answer != common.EResponseOption.Yes() {
+		d.shouldDelete = false
+		if moreToCome {
+			glcm.Info(fmt.Sprintf("Keeping all the extra %ss.", d.objectTypeToDisplay))
+		} else {
+			glcm.Info(fmt.Sprintf("Keeping the %d extra %s(s).", len(pending), d.objectTypeToDisplay))
+		}
+		return nil
+	}
+
+	for _, object := range pending {
+		if err := d.removeImmediately(object); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func newInteractiveDeleteProcessor(deleter objectProcessor, deleteDestination common.DeleteDestination,
-	objectTypeToDisplay string, objectLocationToDisplay common.ResourceString, incrementDeletionCounter func(), dryrun bool) *interactiveDeleteProcessor {
+	objectTypeToDisplay string, objectLocationToDisplay common.ResourceString, incrementDeletionCounter func(), dryrun bool, confirmFirst bool) *interactiveDeleteProcessor {
 
 	return &interactiveDeleteProcessor{
 		deleter:                 deleter,
@@ -208,12 +281,13 @@ func newInteractiveDeleteProcessor(deleter objectProcessor, deleteDestination co
 		shouldPromptUser:        deleteDestination == common.EDeleteDestination.Prompt(),
 		shouldDelete:            deleteDestination == common.EDeleteDestination.True(), // if shouldPromptUser is true, this will start as false, but we will determine its value later
 		dryrunMode:              dryrun,
+		confirmFirst:            confirmFirst,
 	}
 }
 
 func newSyncLocalDeleteProcessor(cca *cookedSyncCmdArgs) *interactiveDeleteProcessor {
 	localDeleter := localFileDeleter{rootPath: cca.destination.ValueLocal()}
-	return newInteractiveDeleteProcessor(localDeleter.deleteFile, cca.deleteDestination, "local file", cca.destination, cca.incrementDeletionCount, cca.dryrunMode)
+	return newInteractiveDeleteProcessor(localDeleter.deleteFile, cca.deleteDestination, "local file", cca.destination, cca.incrementDeletionCount, cca.dryrunMode, cca.confirmDeletions)
 }
 
 type localFileDeleter struct {
@@ -261,7 +335,7 @@ func newSyncDeleteProcessor(cca *cookedSyncCmdArgs) (*interactiveDeleteProcessor
 	}
 
 	return newInteractiveDeleteProcessor(newRemoteResourceDeleter(rawURL, p, ctx, cca.fromTo.To()).delete,
-		cca.deleteDestination, cca.fromTo.To().String(), cca.destination, cca.incrementDeletionCount, cca.dryrunMode), nil
+		cca.deleteDestination, cca.fromTo.To().String(), cca.destination, cca.incrementDeletionCount, cca.dryrunMode, cca.confirmDeletions), nil
 }
 
 type remoteResourceDeleter struct {
@@ -0,0 +1,156 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+const tarContentType = "application/x-tar"
+
+// validateAsTar checks that --as-tar can be used with the rest of the command
+func validateAsTar(raw rawCopyCmdArgs, cooked CookedCopyCmdArgs) error {
+	if !cooked.asTar {
+		return nil
+	}
+	if cooked.FromTo != common.EFromTo.LocalBlob() {
+		return errors.New("as-tar is only supported when uploading to Blob storage")
+	}
+	if info, err := os.Stat(cooked.Source.ValueLocal()); err != nil || !info.IsDir() {
+		return errors.New("as-tar needs the source to be a directory")
+	}
+	u, err := cooked.Destination.FullURL()
+	if err != nil {
+		return err
+	}
+	if azblob.NewBlobURLParts(*u).BlobName == "" {
+		return errors.New("as-tar needs the destination to be the URL of the blob to create, not of a container")
+	}
+	if cooked.blobType != common.EBlobType.Detect() && cooked.blobType != common.EBlobType.BlockBlob() {
+		return fmt.Errorf("as-tar always creates a block blob, so cannot be used with blob-type %s", cooked.blobType)
+	}
+	if raw.include != "" || raw.exclude != "" || raw.includePath != "" || raw.excludePath != "" ||
+		raw.includeRegex != "" || raw.excludeRegex != "" || raw.listOfFilesToCopy != "" ||
+		raw.includeFileAttributes != "" || raw.excludeFileAttributes != "" || raw.includeBefore != "" || raw.includeAfter != "" ||
+		raw.minSize != "" || raw.maxSize != "" || raw.newerThan != "" || raw.olderThan != "" {
+		return errors.New("as-tar archives the whole directory, so cannot be used with include or exclude filters")
+	}
+	if raw.followSymlinks {
+		return errors.New("as-tar archives symbolic links as links, so cannot be used with follow-symlinks")
+	}
+	if cooked.compress || cooked.dryrunMode {
+		return errors.New("as-tar cannot be used with compress or dry-run")
+	}
+	return nil
+}
+
+// processTarUpload archives the source directory as a tar stream, and stages the stream as the blocks of one block
+// blob as it is written, so that the archive never exists on disk
+func (cca *CookedCopyCmdArgs) processTarUpload() error {
+	archive, archiveWriter := io.Pipe()
+	defer archive.Close() // if the upload fails, so that writeDirectoryAsTar stops too
+
+	var fileCount int
+	go func() {
+		tw := tar.NewWriter(archiveWriter)
+		count, err := writeDirectoryAsTar(cca.Source.ValueLocal(), tw)
+		if err == nil {
+			err = tw.Close()
+		}
+		fileCount = count
+		_ = archiveWriter.CloseWithError(err) // a nil error is a normal end of the stream
+	}()
+
+	if cca.contentType == "" {
+		cca.contentType = tarContentType
+	}
+	if err := cca.processRedirectionUpload(cca.Destination, cca.blockSize, archive); err != nil {
+		return err
+	}
+	glcm.Info(fmt.Sprintf("Uploaded %d files and folders as a tar archive", fileCount))
+	return nil
+}
+
+// writeDirectoryAsTar writes everything under root to tw, with paths relative to root and each entry's mode and
+// modification time. Symbolic links are archived as links. It returns how many entries it wrote.
+func writeDirectoryAsTar(root string, tw *tar.Writer) (int, error) {
+	count := 0
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		} else if !info.Mode().IsRegular() && !info.IsDir() {
+			glcm.Info(fmt.Sprintf("Leaving %s out of the tar archive, since it is not a file, folder or symbolic link", path))
+			return nil
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return fmt.Errorf("cannot archive %s: %w", path, err)
+		}
+		header.Name = filepath.ToSlash(relPath)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err = tw.WriteHeader(header); err != nil {
+			return err
+		}
+		count++
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		// the header has the size the file had when it was listed, and the archive is broken if the content isn't that size
+		if n, err := io.Copy(tw, f); err != nil {
+			return fmt.Errorf("cannot archive %s: %w", path, err)
+		} else if n != header.Size {
+			return fmt.Errorf("cannot archive %s, since it changed size while it was being read", path)
+		}
+		return nil
+	})
+	return count, err
+}
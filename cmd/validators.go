@@ -48,9 +48,51 @@ func ValidateFromTo(src, dst string, userSpecifiedFromTo string) (common.FromTo,
 
 	}
 
+	// ...unless it plainly can't be right for the arguments given
+	if err := checkArgumentLocation("source", src, userFromTo.From()); err != nil {
+		return common.EFromTo.Unknown(), err
+	}
+	if err := checkArgumentLocation("destination", dst, userFromTo.To()); err != nil {
+		return common.EFromTo.Unknown(), err
+	}
+
 	return userFromTo, nil
 }
 
+// checkArgumentLocation returns an error if --from-to names a location that obviously isn't the argument's:
+// a local location for a URL, a remote location for something that isn't a URL, or a different service from the
+// one that the URL's host names. Arguments that could be in more than one place, such as URLs with custom domains
+// or IP addresses, and ADLS Gen2 accounts, which can be reached through either their Blob or their DFS endpoint,
+// are left to --from-to.
+func checkArgumentLocation(which, arg string, location common.Location) error {
+	switch location {
+	case common.ELocation.Local(), common.ELocation.Blob(), common.ELocation.BlobFS(), common.ELocation.File(),
+		common.ELocation.S3(), common.ELocation.GCP():
+	default:
+		return nil // pipes, benchmarks and deletions have no argument to check
+	}
+
+	contradiction := func(description string) error {
+		return fmt.Errorf("--from-to says the %s is %s, but %s %s. Check the order of the arguments, or the --from-to value",
+			which, location, common.URLStringExtension(arg).RedactSecretQueryParamForLogging(), description)
+	}
+	if u, err := url.Parse(arg); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+		if location == common.ELocation.Local() {
+			return contradiction("is a URL")
+		}
+		inferred := InferArgumentLocation(arg)
+		isBlobOrDFS := func(l common.Location) bool { return l == common.ELocation.Blob() || l == common.ELocation.BlobFS() }
+		if inferred.IsRemote() && inferred != location && !(isBlobOrDFS(inferred) && isBlobOrDFS(location)) {
+			return contradiction("is a " + inferred.String() + " URL")
+		}
+		return nil
+	}
+	if location != common.ELocation.Local() {
+		return contradiction("is not a URL")
+	}
+	return nil
+}
+
 const fromToHelpText = "Valid values are two-word phases of the form BlobLocal, LocalBlob etc.  Use the word 'Blob' for Blob Storage, " +
 	"'Local' for the local file system, 'File' for Azure Files, and 'BlobFS' for ADLS Gen2. " +
 	"If you need a combination that is not supported yet, please log an issue on the AzCopy GitHub issues list."
@@ -154,6 +196,9 @@ func InferArgumentLocation(arg string) common.Location {
 			if common.IsGCPURL(*u) {
 				return common.ELocation.GCP()
 			}
+
+			// a URL to some other host, such as a custom domain, isn't a local path, but we can't tell which service it is
+			return common.ELocation.Unknown()
 		}
 	}
 
@@ -0,0 +1,105 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+type validatorsSuite struct{}
+
+var _ = chk.Suite(&validatorsSuite{})
+
+const (
+	testLocalPath = "/tmp/data"
+	testBlobURL   = "https://account.blob.core.windows.net/container/dir?sv=2020-08-04&sig=secret"
+	testDFSURL    = "https://account.dfs.core.windows.net/filesystem/dir"
+	testFileURL   = "https://account.file.core.windows.net/share/dir"
+	testCustomURL = "https://data.contoso.com/container/dir"
+)
+
+func (s *validatorsSuite) TestFromToIsInferred(c *chk.C) {
+	for _, t := range []struct {
+		src, dst string
+		expected common.FromTo
+	}{
+		{testLocalPath, testBlobURL, common.EFromTo.LocalBlob()},
+		{testBlobURL, testLocalPath, common.EFromTo.BlobLocal()},
+		{testBlobURL, testBlobURL, common.EFromTo.BlobBlob()},
+	} {
+		fromTo, err := ValidateFromTo(t.src, t.dst, "")
+		c.Assert(err, chk.IsNil)
+		c.Assert(fromTo, chk.Equals, t.expected)
+	}
+
+	// a URL to a custom domain isn't mistaken for a local path
+	c.Assert(InferArgumentLocation(testCustomURL), chk.Equals, common.ELocation.Unknown())
+	_, err := ValidateFromTo(testLocalPath, testCustomURL, "")
+	c.Assert(err, chk.NotNil)
+}
+
+func (s *validatorsSuite) TestFromToOverridesInference(c *chk.C) {
+	for _, t := range []struct {
+		src, dst, fromTo string
+		expected         common.FromTo
+	}{
+		{testLocalPath, testBlobURL, "LocalBlob", common.EFromTo.LocalBlob()},
+		{testBlobURL, testLocalPath, "BlobLocal", common.EFromTo.BlobLocal()},
+		{testBlobURL, testBlobURL, "BlobBlob", common.EFromTo.BlobBlob()},
+
+		// endpoints that can't be inferred
+		{testLocalPath, testCustomURL, "LocalBlob", common.EFromTo.LocalBlob()},
+		{testCustomURL, testLocalPath, "BlobLocal", common.EFromTo.BlobLocal()},
+		{"https://10.1.2.3:10000/devstoreaccount1/container", testLocalPath, "BlobLocal", common.EFromTo.BlobLocal()},
+
+		// endpoints that are inferred, but could be either
+		{testDFSURL, testLocalPath, "BlobLocal", common.EFromTo.BlobLocal()},
+		{testLocalPath, testBlobURL, "LocalBlobFS", common.EFromTo.LocalBlobFS()},
+	} {
+		fromTo, err := ValidateFromTo(t.src, t.dst, t.fromTo)
+		c.Assert(err, chk.IsNil, chk.Commentf("--from-to=%s", t.fromTo))
+		c.Assert(fromTo, chk.Equals, t.expected)
+	}
+}
+
+func (s *validatorsSuite) TestFromToThatContradictsTheEndpoints(c *chk.C) {
+	for _, t := range []struct {
+		src, dst, fromTo string
+		expectedError    string
+	}{
+		// arguments the wrong way round
+		{testLocalPath, testBlobURL, "BlobLocal", "--from-to says the source is Blob, but /tmp/data is not a URL"},
+		{testLocalPath, testBlobURL, "LocalFile", "--from-to says the destination is File, but https://account.blob.core.windows.net/container/dir?sig=REDACTED&sv=2020-08-04 is a Blob URL"},
+		{testBlobURL, testLocalPath, "LocalBlob", "--from-to says the source is Local, but https://account.blob.core.windows.net/container/dir?sig=REDACTED&sv=2020-08-04 is a URL"},
+
+		{testBlobURL, testLocalPath, "BlobBlob", "--from-to says the destination is Blob, but /tmp/data is not a URL"},
+		{testFileURL, testBlobURL, "BlobBlob", "--from-to says the source is Blob, but https://account.file.core.windows.net/share/dir is a File URL"},
+	} {
+		_, err := ValidateFromTo(t.src, t.dst, t.fromTo)
+		c.Assert(err, chk.NotNil, chk.Commentf("--from-to=%s", t.fromTo))
+		c.Assert(err.Error(), StringContains, t.expectedError)
+	}
+
+	_, err := ValidateFromTo(testLocalPath, testBlobURL, "UpDown")
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "invalid --from-to value specified")
+}
@@ -0,0 +1,309 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/spf13/cobra"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-azcopy/v10/ste"
+)
+
+type rawVerifyCmdArgs struct {
+	// obtained from argument
+	src string
+	dst string
+
+	recursive      bool
+	followSymlinks bool
+}
+
+type cookedVerifyCmdArgs struct {
+	source      common.ResourceString
+	destination common.ResourceString
+
+	recursive      bool
+	followSymlinks bool
+}
+
+func (raw rawVerifyCmdArgs) cook() (cookedVerifyCmdArgs, error) {
+	cooked := cookedVerifyCmdArgs{recursive: raw.recursive, followSymlinks: raw.followSymlinks}
+
+	// only local to blob is supported, since that's where the Content-MD5 comes from when uploading with --put-md5
+	if location := InferArgumentLocation(raw.src); location != common.ELocation.Local() {
+		return cooked, fmt.Errorf("the source to verify must be a local path, but a location of type %s was given", location)
+	}
+	if location := InferArgumentLocation(raw.dst); location != common.ELocation.Blob() {
+		return cooked, fmt.Errorf("the destination to verify against must be a blob, container or virtual directory, but a location of type %s was given", location)
+	}
+
+	var err error
+	if cooked.source, err = SplitResourceString(raw.src, common.ELocation.Local()); err != nil {
+		return cooked, err
+	}
+	if cooked.destination, err = SplitResourceString(raw.dst, common.ELocation.Blob()); err != nil {
+		return cooked, err
+	}
+	return cooked, nil
+}
+
+// verifyOutcome is the result of comparing a local file with its blob
+type verifyOutcome uint8
+
+const (
+	verifyOutcomeMatched verifyOutcome = iota
+	verifyOutcomeMismatched
+	verifyOutcomeMissing
+	verifyOutcomeNoHash // the sizes match, but the blob has no Content-MD5 to compare the content against
+)
+
+// VerifySummary counts the outcomes of the verify command
+type VerifySummary struct {
+	Matched    int
+	Mismatched int
+	Missing    int
+	NoHash     int
+}
+
+func (s *VerifySummary) add(outcome verifyOutcome) {
+	switch outcome {
+	case verifyOutcomeMatched:
+		s.Matched++
+	case verifyOutcomeMismatched:
+		s.Mismatched++
+	case verifyOutcomeMissing:
+		s.Missing++
+	case verifyOutcomeNoHash:
+		s.NoHash++
+	}
+}
+
+func (s VerifySummary) String() string {
+	return fmt.Sprintf("Matched: %d\nMismatched: %d\nMissing: %d\nNot verifiable (no Content-MD5 on the blob): %d",
+		s.Matched, s.Mismatched, s.Missing, s.NoHash)
+}
+
+// verifyLocalFile compares the local file with its blob, if there is one. The local MD5 hash is only computed if the sizes match,
+// and the blob has a hash to compare it to
+func verifyLocalFile(localPath string, local StoredObject, blob *StoredObject) (outcome verifyOutcome, reason string, err error) {
+	if blob == nil {
+		return verifyOutcomeMissing, "not found at the destination", nil
+	}
+	if blob.size != local.size {
+		return verifyOutcomeMismatched, fmt.Sprintf("the local file has %d bytes, but the blob has %d", local.size, blob.size), nil
+	}
+	if len(blob.md5) == 0 {
+		return verifyOutcomeNoHash, "the blob has no Content-MD5", nil
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return verifyOutcomeMismatched, "", err
+	}
+	defer f.Close()
+	hasher := md5.New()
+	if _, err = io.Copy(hasher, f); err != nil {
+		return verifyOutcomeMismatched, "", err
+	}
+	if !bytes.Equal(hasher.Sum(nil), blob.md5) {
+		return verifyOutcomeMismatched, "the Content-MD5 of the blob does not match the local file", nil
+	}
+	return verifyOutcomeMatched, "", nil
+}
+
+// blobFor finds the blob in the index that the local file was uploaded to. A single-file source has no relative path of
+// its own, so its blob is either the destination itself, when that's a blob, or the one named after the file in the
+// destination container or virtual directory.
+func blobFor(blobs *objectIndexer, local StoredObject) *StoredObject {
+	relativePath := local.relativePath
+	if relativePath == "" {
+		if _, isSingleBlob := blobs.indexMap[""]; !isSingleBlob {
+			relativePath = local.name
+		}
+	}
+	if blob, ok := blobs.indexMap[relativePath]; ok {
+		return &blob
+	}
+	return nil
+}
+
+// verifyAgainstIndex compares each local file found by the traverser with the blobs in the index.
+// Files are hashed in parallel, and report is called (serially) for each file that didn't match.
+func verifyAgainstIndex(localTraverser ResourceTraverser, localRoot string, blobs *objectIndexer,
+	report func(relativePath string, outcome verifyOutcome, reason string)) (VerifySummary, error) {
+	summary := VerifySummary{}
+	mu := &sync.Mutex{}
+	var firstErr error
+
+	files := make(chan StoredObject, 1000)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for local := range files {
+				outcome, reason, err := verifyLocalFile(common.GenerateFullPath(localRoot, local.relativePath), local, blobFor(blobs, local))
+
+				displayPath := local.relativePath
+				if displayPath == "" {
+					displayPath = local.name // the source is a single file
+				}
+				mu.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = fmt.Errorf("cannot verify %s: %w", displayPath, err)
+					}
+				} else {
+					summary.add(outcome)
+					if outcome != verifyOutcomeMatched {
+						report(displayPath, outcome, reason)
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	err := localTraverser.Traverse(noPreProccessor, func(local StoredObject) error {
+		if local.entityType == common.EEntityType.File() {
+			files <- local
+		}
+		return nil
+	}, nil)
+	close(files)
+	wg.Wait()
+
+	if err != nil {
+		return summary, err
+	}
+	return summary, firstErr
+}
+
+// HandleVerifyCommand compares the local files with their blobs, without transferring anything
+func (cooked cookedVerifyCmdArgs) HandleVerifyCommand() (VerifySummary, error) {
+	// TODO: Temporarily use context.TODO(), this should be replaced with a root context from main.
+	ctx := context.WithValue(context.TODO(), ste.ServiceAPIVersionOverride, ste.DefaultServiceApiVersion)
+
+	// isSource is rather misnomer for canBePublic. We only read the blobs, so they can be public
+	credentialInfo, _, err := GetCredentialInfoForLocation(ctx, common.ELocation.Blob(), cooked.destination.Value, cooked.destination.SAS, true, common.CpkOptions{})
+	if err != nil {
+		return VerifySummary{}, fmt.Errorf("failed to obtain credential info: %s", err.Error())
+	} else if credentialInfo.CredentialType == common.ECredentialType.OAuthToken() {
+		uotm := GetUserOAuthTokenManagerInstance()
+		if tokenInfo, err := uotm.GetTokenInfo(ctx); err != nil {
+			return VerifySummary{}, err
+		} else {
+			credentialInfo.OAuthTokenInfo = *tokenInfo
+		}
+	}
+
+	// the properties of the blobs, including their Content-MD5, come back in the listing, so there's no need to get them separately
+	blobTraverser, err := InitResourceTraverser(cooked.destination, common.ELocation.Blob(), &ctx, &credentialInfo, nil, nil,
+		cooked.recursive, false, false, common.EPermanentDeleteOption.None(), func(common.EntityType) {},
+		nil, false, pipeline.LogNone, common.CpkOptions{})
+	if err != nil {
+		return VerifySummary{}, fmt.Errorf("failed to initialize destination traverser: %s", err.Error())
+	}
+	blobs := newObjectIndexer()
+	if err = blobTraverser.Traverse(noPreProccessor, blobs.store, nil); err != nil {
+		return VerifySummary{}, fmt.Errorf("failed to list the destination: %s", err.Error())
+	}
+
+	localTraverser, err := InitResourceTraverser(cooked.source, common.ELocation.Local(), &ctx, &common.CredentialInfo{}, &cooked.followSymlinks, nil,
+		cooked.recursive, false, false, common.EPermanentDeleteOption.None(), func(common.EntityType) {},
+		nil, false, pipeline.LogNone, common.CpkOptions{})
+	if err != nil {
+		return VerifySummary{}, fmt.Errorf("failed to initialize source traverser: %s", err.Error())
+	}
+
+	return verifyAgainstIndex(localTraverser, cooked.source.ValueLocal(), blobs, func(relativePath string, outcome verifyOutcome, reason string) {
+		switch outcome {
+		case verifyOutcomeMissing:
+			glcm.Info("Missing: " + relativePath)
+		case verifyOutcomeMismatched:
+			glcm.Info(fmt.Sprintf("Mismatched: %s (%s)", relativePath, reason))
+		case verifyOutcomeNoHash:
+			glcm.Info(fmt.Sprintf("Not verifiable: %s (%s)", relativePath, reason))
+		}
+	})
+}
+
+func init() {
+	raw := rawVerifyCmdArgs{}
+
+	verifyCmd := &cobra.Command{
+		Use:     "verify [localPath] [containerURL]",
+		Short:   verifyCmdShortDescription,
+		Long:    verifyCmdLongDescription,
+		Example: verifyCmdExample,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return errors.New("verify command requires exactly a local path and a blob URL")
+			}
+			raw.src = args[0]
+			raw.dst = args[1]
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			cooked, err := raw.cook()
+			if err != nil {
+				glcm.Error("failed to parse user input due to error: " + err.Error())
+				return
+			}
+
+			summary, err := cooked.HandleVerifyCommand()
+			if err != nil {
+				glcm.Error("failed to verify due to error: " + err.Error())
+				return
+			}
+
+			exitCode := common.EExitCode.Success()
+			if summary.Mismatched > 0 || summary.Missing > 0 {
+				exitCode = common.EExitCode.Error()
+			}
+			glcm.Exit(func(format common.OutputFormat) string {
+				if format == common.EOutputFormat.Json() {
+					jsonOutput, err := json.Marshal(summary)
+					common.PanicIfErr(err)
+					return string(jsonOutput)
+				}
+				return summary.String()
+			}, exitCode)
+		},
+	}
+
+	verifyCmd.PersistentFlags().BoolVar(&raw.recursive, "recursive", true, "True by default, look into sub-directories recursively when verifying a directory.")
+	verifyCmd.PersistentFlags().BoolVar(&raw.followSymlinks, "follow-symlinks", false, "Follow symbolic links when reading the local files.")
+
+	rootCmd.AddCommand(verifyCmd)
+}
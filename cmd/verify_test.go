@@ -0,0 +1,168 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"crypto/md5"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+type verifySuite struct{}
+
+var _ = chk.Suite(&verifySuite{})
+
+func (s *verifySuite) blobFor(content string, withMD5 bool) StoredObject {
+	blob := StoredObject{entityType: common.EEntityType.File(), size: int64(len(content))}
+	if withMD5 {
+		hash := md5.Sum([]byte(content))
+		blob.md5 = hash[:]
+	}
+	return blob
+}
+
+func (s *verifySuite) TestVerifyLocalFileOutcomes(c *chk.C) {
+	dir := c.MkDir()
+	localPath := filepath.Join(dir, "file.txt")
+	c.Assert(ioutil.WriteFile(localPath, []byte("hello world"), 0644), chk.IsNil)
+	local := StoredObject{entityType: common.EEntityType.File(), size: 11, relativePath: "file.txt"}
+
+	cases := []struct {
+		name    string
+		blob    *StoredObject
+		outcome verifyOutcome
+	}{
+		{"matching content", &StoredObject{size: 11, md5: s.blobFor("hello world", true).md5}, verifyOutcomeMatched},
+		{"same size, different content", &StoredObject{size: 11, md5: s.blobFor("HELLO WORLD", true).md5}, verifyOutcomeMismatched},
+		{"different size", &StoredObject{size: 5, md5: s.blobFor("hello", true).md5}, verifyOutcomeMismatched},
+		{"no blob", nil, verifyOutcomeMissing},
+		{"no hash on the blob", &StoredObject{size: 11}, verifyOutcomeNoHash},
+	}
+	for _, tc := range cases {
+		outcome, _, err := verifyLocalFile(localPath, local, tc.blob)
+		c.Assert(err, chk.IsNil, chk.Commentf(tc.name))
+		c.Assert(outcome, chk.Equals, tc.outcome, chk.Commentf(tc.name))
+	}
+
+	// the file is only read if the size matches and the blob has a hash
+	_, _, err := verifyLocalFile(filepath.Join(dir, "absent.txt"), local, &StoredObject{size: 5})
+	c.Assert(err, chk.IsNil)
+	_, _, err = verifyLocalFile(filepath.Join(dir, "absent.txt"), local, &StoredObject{size: 11, md5: []byte("0123456789abcdef")})
+	c.Assert(err, chk.NotNil)
+}
+
+func (s *verifySuite) TestVerifyDirectoryAgainstBlobs(c *chk.C) {
+	dir := c.MkDir()
+	files := map[string]string{
+		"matched.txt":        "same",
+		"sub/matched.txt":    "same in a sub directory",
+		"mismatched.txt":     "local content",
+		"missing.txt":        "only here",
+		"unverifiable.txt":   "no hash",
+		"sub/wrong-size.txt": "longer locally",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		c.Assert(os.MkdirAll(filepath.Dir(path), 0755), chk.IsNil)
+		c.Assert(ioutil.WriteFile(path, []byte(content), 0644), chk.IsNil)
+	}
+
+	blobs := newObjectIndexer()
+	for name, blob := range map[string]StoredObject{
+		"matched.txt":        s.blobFor("same", true),
+		"sub/matched.txt":    s.blobFor("same in a sub directory", true),
+		"mismatched.txt":     s.blobFor("other content", true),
+		"unverifiable.txt":   s.blobFor("no hash", false),
+		"sub/wrong-size.txt": s.blobFor("short", true),
+		"only-in-blob.txt":   s.blobFor("not compared", true),
+	} {
+		blob.relativePath = name
+		c.Assert(blobs.store(blob), chk.IsNil)
+	}
+
+	reported := map[string]verifyOutcome{}
+	summary, err := verifyAgainstIndex(newLocalTraverser(dir, true, false, func(common.EntityType) {}), dir, blobs,
+		func(relativePath string, outcome verifyOutcome, reason string) {
+			reported[relativePath] = outcome
+		})
+	c.Assert(err, chk.IsNil)
+	c.Assert(summary, chk.Equals, VerifySummary{Matched: 2, Mismatched: 2, Missing: 1, NoHash: 1})
+	c.Assert(reported, chk.DeepEquals, map[string]verifyOutcome{
+		"mismatched.txt":     verifyOutcomeMismatched,
+		"sub/wrong-size.txt": verifyOutcomeMismatched,
+		"missing.txt":        verifyOutcomeMissing,
+		"unverifiable.txt":   verifyOutcomeNoHash,
+	})
+}
+
+func (s *verifySuite) TestVerifySingleFile(c *chk.C) {
+	localPath := filepath.Join(c.MkDir(), "file.txt")
+	c.Assert(ioutil.WriteFile(localPath, []byte("hello world"), 0644), chk.IsNil)
+
+	verify := func(blobs map[string]StoredObject) (VerifySummary, map[string]verifyOutcome) {
+		index := newObjectIndexer()
+		for name, blob := range blobs {
+			blob.relativePath = name
+			c.Assert(index.store(blob), chk.IsNil)
+		}
+		reported := map[string]verifyOutcome{}
+		summary, err := verifyAgainstIndex(newLocalTraverser(localPath, true, false, func(common.EntityType) {}), localPath, index,
+			func(relativePath string, outcome verifyOutcome, reason string) {
+				reported[relativePath] = outcome
+			})
+		c.Assert(err, chk.IsNil)
+		return summary, reported
+	}
+
+	// against the container (or virtual directory) it was uploaded into, the file is found by its name
+	summary, reported := verify(map[string]StoredObject{"file.txt": s.blobFor("hello world", true), "other.txt": s.blobFor("other", true)})
+	c.Assert(summary, chk.Equals, VerifySummary{Matched: 1})
+	c.Assert(reported, chk.HasLen, 0)
+
+	// against a single blob, the blob is the file's, whatever it's called
+	summary, _ = verify(map[string]StoredObject{"": s.blobFor("hello world", true)})
+	c.Assert(summary, chk.Equals, VerifySummary{Matched: 1})
+
+	// and when it's really not there, it's reported by name
+	summary, reported = verify(map[string]StoredObject{"other.txt": s.blobFor("other", true)})
+	c.Assert(summary, chk.Equals, VerifySummary{Missing: 1})
+	c.Assert(reported, chk.DeepEquals, map[string]verifyOutcome{"file.txt": verifyOutcomeMissing})
+}
+
+func (s *verifySuite) TestVerifyOnlyComparesLocalWithBlob(c *chk.C) {
+	raw := rawVerifyCmdArgs{src: "/tmp/source", dst: "https://account.blob.core.windows.net/container", recursive: true}
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.destination.Value, chk.Equals, "https://account.blob.core.windows.net/container")
+
+	raw.dst = "https://account.file.core.windows.net/share"
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+
+	raw.src, raw.dst = "https://account.blob.core.windows.net/container", "/tmp/destination"
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+}
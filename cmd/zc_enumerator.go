@@ -422,6 +422,11 @@ func InitResourceTraverser(resource common.ResourceString, location common.Locat
 			}
 
 			output = newBlobAccountTraverser(resourceURL, *p, *ctx, includeDirectoryStubs, incrementEnumerationCounter, s2sPreserveBlobTags, cpkOptions)
+		} else if blobURLHasWildcard(*resourceURL) {
+			output, err = newBlobWildcardTraverser(resourceURL, *p, *ctx, includeDirectoryStubs, incrementEnumerationCounter, s2sPreserveBlobTags, cpkOptions)
+			if err != nil {
+				return nil, err
+			}
 		} else if listOfVersionIds != nil {
 			output = newBlobVersionsTraverser(resourceURL, *p, *ctx, recursive, includeDirectoryStubs, incrementEnumerationCounter, listOfVersionIds, cpkOptions)
 		} else {
@@ -742,6 +747,17 @@ func getProcessingError(errin error) (ignored bool, err error) {
 	return false, err
 }
 
+// getProcessingErrorToStop is like getProcessingError, but keeps any other error, for traversers that stop at the
+// processor's errors and report them. So far, that's only the local traverser, so that uploads with
+// --normalize-blob-names can refuse to give two files the same blob name. The other traversers carry on past them.
+func getProcessingErrorToStop(errin error) (ignored bool, err error) {
+	if errin == ignoredError {
+		return true, nil
+	}
+
+	return false, errin
+}
+
 func processIfPassedFilters(filters []ObjectFilter, storedObject StoredObject, processor objectProcessor) (err error) {
 	if passedFilters(filters, storedObject) {
 		err = processor(storedObject)
@@ -21,9 +21,12 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"path"
 	"regexp"
+	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
@@ -60,6 +63,18 @@ func (f *excludeBlobTypeFilter) DoesPass(object StoredObject) bool {
 	return false
 }
 
+// file names are case-insensitive on Windows, so our name patterns are too
+var patternsIgnoreCase = runtime.GOOS == "windows"
+
+// matchNamePattern matches a name-based include/exclude pattern against the name of a file (not its path)
+func matchNamePattern(pattern string, name string) (bool, error) {
+	if patternsIgnoreCase {
+		pattern = strings.ToLower(pattern)
+		name = strings.ToLower(name)
+	}
+	return path.Match(pattern, name)
+}
+
 type excludeFilter struct {
 	pattern     string
 	targetsPath bool
@@ -85,7 +100,7 @@ func (f *excludeFilter) DoesPass(storedObject StoredObject) bool {
 		matched = strings.HasPrefix(storedObject.relativePath, pattern)
 	} else {
 		var err error
-		matched, err = path.Match(f.pattern, storedObject.name)
+		matched, err = matchNamePattern(f.pattern, storedObject.name)
 
 		// if the pattern failed to match with an error, then we assume the pattern is invalid
 		// and let it pass
@@ -144,7 +159,7 @@ func (f *IncludeFilter) DoesPass(storedObject StoredObject) bool {
 		matched := false
 
 		var err error
-		matched, err = path.Match(pattern, checkItem) // note: getEnumerationPreFilter below encodes assumptions about the valid wildcards used here
+		matched, err = matchNamePattern(pattern, checkItem) // note: getEnumerationPreFilter below encodes assumptions about the valid wildcards used here
 
 		// if the pattern failed to match with an error, then we assume the pattern is invalid
 		// and ignore it
@@ -167,6 +182,10 @@ func (f *IncludeFilter) DoesPass(storedObject StoredObject) bool {
 // "foo*bar", then this routine will return "foo", since only things starting with "foo" can pass the filters.
 // Service side enumeration code can be given that prefix, to optimize the enumeration.
 func (f *IncludeFilter) getEnumerationPreFilter() string {
+	if patternsIgnoreCase {
+		// service-side prefixes are case-sensitive, so they could wrongly leave out names that we'd match
+		return ""
+	}
 	if len(f.patterns) == 1 {
 		pat := f.patterns[0]
 		if strings.ContainsAny(pat, "?[\\") {
@@ -357,6 +376,80 @@ func (_ IncludeBeforeDateFilter) FormatAsUTC(t time.Time) string {
 	return formatAsUTC(t)
 }
 
+// minSizeFilter includes files of at least the given size
+type minSizeFilter struct {
+	size int64
+}
+
+func (f *minSizeFilter) DoesSupportThisOS() (msg string, supported bool) {
+	return "", true
+}
+
+func (f *minSizeFilter) AppliesOnlyToFiles() bool {
+	return true // folders don't have a size
+}
+
+func (f *minSizeFilter) DoesPass(storedObject StoredObject) bool {
+	return storedObject.size >= f.size
+}
+
+// maxSizeFilter includes files of at most the given size
+type maxSizeFilter struct {
+	size int64
+}
+
+func (f *maxSizeFilter) DoesSupportThisOS() (msg string, supported bool) {
+	return "", true
+}
+
+func (f *maxSizeFilter) AppliesOnlyToFiles() bool {
+	return true
+}
+
+func (f *maxSizeFilter) DoesPass(storedObject StoredObject) bool {
+	return storedObject.size <= f.size
+}
+
+// parseFilterSize parses the value of --min-size or --max-size. That's a number of bytes, optionally followed by
+// K, M or G (with or without a trailing B), in units of 1024
+func parseFilterSize(s string, name string) (int64, error) {
+	message := name + " must be a number of bytes, or " + sizeStringDescription + ", optionally followed by B"
+
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if n < 0 {
+			return 0, errors.New(message)
+		}
+		return n, nil
+	}
+	if len(s) > 2 && strings.EqualFold(s[len(s)-1:], "b") {
+		s = s[:len(s)-1] // 10MB means the same as 10M
+	}
+	n, err := ParseSizeString(s, name)
+	if err != nil || n < 0 {
+		return 0, errors.New(message)
+	}
+	return n, nil
+}
+
+// parseAge parses the value of --newer-than or --older-than, into the time that files must have been modified after
+// (or before). The value is either an age, counted back from now, as a Go duration (e.g. 36h) or a number of days (e.g. 7d),
+// or an ISO 8601 date, as taken by include-after and include-before
+func parseAge(s string, now time.Time, chooseEarliest bool) (time.Time, error) {
+	if strings.HasSuffix(s, "d") {
+		if days, err := strconv.ParseFloat(strings.TrimSuffix(s, "d"), 64); err == nil && days >= 0 {
+			return now.Add(-time.Duration(days * float64(24*time.Hour))), nil
+		}
+	}
+	if age, err := time.ParseDuration(s); err == nil && age >= 0 {
+		return now.Add(-age), nil
+	}
+	t, err := parseISO8601(s, chooseEarliest)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("'%s' is neither an age, such as 36h or 7d, nor an ISO 8601 date", s)
+	}
+	return t, nil
+}
+
 type permDeleteFilter struct {
 	deleteSnapshots bool
 	deleteVersions  bool
@@ -0,0 +1,162 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// blobWildcard is a blob name containing wildcards, such as logs/2023-*.
+// A '*' matches within a single virtual directory, and a '**' matches across virtual directories.
+// A literal star must be escaped as %2A.
+type blobWildcard struct {
+	// the virtual directory holding the first wildcard, e.g. logs/
+	// the matching blobs are enumerated relative to it, just as local files are relative to the directory holding the first wildcard
+	root string
+
+	// the literal text between root and the first wildcard, e.g. 2023-, used to narrow the listing
+	prefix string
+
+	// matches the blob names relative to root
+	pattern *regexp.Regexp
+}
+
+// escapedBlobName returns the blob name from the URL, still escaped, so that wildcards can be told apart from escaped stars
+func escapedBlobName(u url.URL) string {
+	blobURLParts := azblob.NewBlobURLParts(u)
+	containerURL := copyHandlerUtil{}.getContainerUrl(blobURLParts)
+	return strings.TrimPrefix(strings.TrimPrefix(u.EscapedPath(), containerURL.EscapedPath()), common.AZCOPY_PATH_SEPARATOR_STRING)
+}
+
+// blobURLHasWildcard reports whether the blob name in the URL contains a wildcard
+func blobURLHasWildcard(u url.URL) bool {
+	return strings.Contains(escapedBlobName(u), "*")
+}
+
+// parseBlobWildcard parses an escaped blob name containing at least one wildcard
+func parseBlobWildcard(escapedName string) (blobWildcard, error) {
+	firstWildcard := strings.Index(escapedName, "*")
+	if firstWildcard == -1 {
+		return blobWildcard{}, errors.New("the blob name does not contain a wildcard")
+	}
+
+	escapedRoot := escapedName[:strings.LastIndex(escapedName[:firstWildcard], common.AZCOPY_PATH_SEPARATOR_STRING)+1]
+	root, err := url.PathUnescape(escapedRoot)
+	if err != nil {
+		return blobWildcard{}, err
+	}
+	prefix, err := url.PathUnescape(escapedName[len(escapedRoot):firstWildcard])
+	if err != nil {
+		return blobWildcard{}, err
+	}
+
+	// build the pattern from the literal pieces between the wildcards
+	expr := strings.Builder{}
+	expr.WriteString("^")
+	for remaining := escapedName[len(escapedRoot):]; remaining != ""; {
+		literal := remaining
+		if i := strings.Index(remaining, "*"); i != -1 {
+			literal = remaining[:i]
+		}
+		unescapedLiteral, err := url.PathUnescape(literal)
+		if err != nil {
+			return blobWildcard{}, err
+		}
+		expr.WriteString(regexp.QuoteMeta(unescapedLiteral))
+		remaining = remaining[len(literal):]
+
+		switch {
+		case remaining == "":
+		case strings.HasPrefix(remaining, "**/"):
+			expr.WriteString("(.*/)?") // any number of virtual directories, including none
+			remaining = remaining[3:]
+		case strings.HasPrefix(remaining, "**"):
+			expr.WriteString(".*")
+			remaining = remaining[2:]
+		default:
+			expr.WriteString("[^/]*")
+			remaining = remaining[1:]
+		}
+	}
+	expr.WriteString("$")
+
+	pattern, err := regexp.Compile(expr.String())
+	if err != nil {
+		return blobWildcard{}, err
+	}
+
+	return blobWildcard{root: root, prefix: prefix, pattern: pattern}, nil
+}
+
+func (w *blobWildcard) DoesSupportThisOS() (msg string, supported bool) {
+	return "", true
+}
+
+func (w *blobWildcard) AppliesOnlyToFiles() bool {
+	return false
+}
+
+func (w *blobWildcard) DoesPass(storedObject StoredObject) bool {
+	return w.pattern.MatchString(storedObject.relativePath)
+}
+
+// blobWildcardTraverser enumerates the blobs matching a wildcarded blob name.
+// It lists everything that starts with the literal text before the first wildcard, and keeps what matches the whole pattern.
+type blobWildcardTraverser struct {
+	*blobTraverser // rooted at the wildcard's root
+	wildcard       *blobWildcard
+}
+
+func (t *blobWildcardTraverser) IsDirectory(isSource bool) bool {
+	return true // a wildcard may match any number of blobs, so it is treated like a directory whose top level is stripped
+}
+
+func (t *blobWildcardTraverser) Traverse(preprocessor objectMorpher, processor objectProcessor, filters []ObjectFilter) error {
+	blobUrlParts := azblob.NewBlobURLParts(*t.rawURL)
+	containerURL := azblob.NewContainerURL(copyHandlerUtil{}.getContainerUrl(blobUrlParts), t.p)
+
+	filters = append([]ObjectFilter{t.wildcard}, filters...)
+	return t.serialList(containerURL, blobUrlParts.ContainerName, t.wildcard.root, t.wildcard.prefix, preprocessor, processor, filters)
+}
+
+func newBlobWildcardTraverser(rawURL *url.URL, p pipeline.Pipeline, ctx context.Context, includeDirectoryStubs bool, incrementEnumerationCounter enumerationCounterFunc, s2sPreserveSourceTags bool, cpkOptions common.CpkOptions) (*blobWildcardTraverser, error) {
+	wildcard, err := parseBlobWildcard(escapedBlobName(*rawURL))
+	if err != nil {
+		return nil, err
+	}
+
+	blobUrlParts := azblob.NewBlobURLParts(*rawURL)
+	blobUrlParts.BlobName = wildcard.root
+	rootURL := blobUrlParts.URL()
+
+	// the pattern, not the recursive flag, decides how deep the matches may be, so the listing itself is always recursive
+	inner := newBlobTraverser(&rootURL, p, ctx, true, includeDirectoryStubs, incrementEnumerationCounter, s2sPreserveSourceTags, cpkOptions, false, false, false)
+	return &blobWildcardTraverser{blobTraverser: inner, wildcard: &wildcard}, nil
+}
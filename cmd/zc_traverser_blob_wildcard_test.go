@@ -0,0 +1,184 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	chk "gopkg.in/check.v1"
+)
+
+type blobWildcardTestSuite struct{}
+
+var _ = chk.Suite(&blobWildcardTestSuite{})
+
+func (s *blobWildcardTestSuite) TestParseBlobWildcardPrefix(c *chk.C) {
+	testCases := []struct {
+		escapedName, root, prefix string
+	}{
+		{"logs/2023-*", "logs/", "2023-"},
+		{"logs/2023-*/*.log", "logs/", "2023-"},
+		{"*.log", "", ""},
+		{"a/b/c*", "a/b/", "c"},
+		{"a/b/*", "a/b/", ""},
+		{"a/**/x.log", "a/", ""},
+		{"my%20logs/day%2A1-*", "my logs/", "day*1-"}, // escapes are decoded, and %2A is a literal star
+	}
+
+	for _, tc := range testCases {
+		wildcard, err := parseBlobWildcard(tc.escapedName)
+		c.Assert(err, chk.IsNil)
+		c.Assert(wildcard.root, chk.Equals, tc.root, chk.Commentf("name %s", tc.escapedName))
+		c.Assert(wildcard.prefix, chk.Equals, tc.prefix, chk.Commentf("name %s", tc.escapedName))
+	}
+
+	_, err := parseBlobWildcard("logs/2023")
+	c.Assert(err, chk.NotNil)
+	_, err = parseBlobWildcard("logs/%zz*")
+	c.Assert(err, chk.NotNil)
+}
+
+func (s *blobWildcardTestSuite) TestBlobWildcardMatching(c *chk.C) {
+	testCases := []struct {
+		escapedName string
+		matches     []string // relative to the wildcard's root
+		nonMatches  []string
+	}{
+		{"logs/2023-*", []string{"2023-01.log", "2023-"}, []string{"2022-01.log", "2023-01/a.log", "x2023-01.log"}},
+		{"logs/*.log", []string{"a.log", ".log"}, []string{"a.txt", "a/b.log", "a.log.bak"}},
+		{"logs/*/*.log", []string{"a/b.log"}, []string{"b.log", "a/b/c.log"}},
+		{"logs/**", []string{"a", "a/b/c.txt"}, []string{}},
+		{"logs/**/*.log", []string{"a.log", "a/b.log", "a/b/c.log"}, []string{"a.txt", "a/b.txt"}},
+		{"logs/2023-**.log", []string{"2023-01.log", "2023-01/a.log"}, []string{"2023-01.txt"}},
+		{"logs/a.b*", []string{"a.b", "a.bc"}, []string{"axb"}},         // '.' is literal
+		{"logs/a%2Ab*", []string{"a*b", "a*bc"}, []string{"ab", "axb"}}, // %2A is a literal star
+	}
+
+	for _, tc := range testCases {
+		wildcard, err := parseBlobWildcard(tc.escapedName)
+		c.Assert(err, chk.IsNil)
+		for _, m := range tc.matches {
+			c.Assert(wildcard.DoesPass(StoredObject{relativePath: m}), chk.Equals, true, chk.Commentf("%s should match %s", tc.escapedName, m))
+		}
+		for _, m := range tc.nonMatches {
+			c.Assert(wildcard.DoesPass(StoredObject{relativePath: m}), chk.Equals, false, chk.Commentf("%s should not match %s", tc.escapedName, m))
+		}
+	}
+}
+
+func (s *blobWildcardTestSuite) TestBlobWildcardURLs(c *chk.C) {
+	src := "https://account.blob.core.windows.net/container/logs/2023-*?sv=2019-12-12&sig=xyz"
+	u, _ := url.Parse(src)
+	c.Assert(blobURLHasWildcard(*u), chk.Equals, true)
+	c.Assert(escapedBlobName(*u), chk.Equals, "logs/2023-*")
+
+	// an escaped star is not a wildcard
+	u, _ = url.Parse("https://account.blob.core.windows.net/container/logs/2023-%2A")
+	c.Assert(blobURLHasWildcard(*u), chk.Equals, false)
+
+	// the wildcard stays in the source, and the matches are placed directly in the destination
+	raw := rawCopyCmdArgs{src: src}
+	result, stripTopDir, err := raw.stripTrailingWildcardOnRemoteSource(common.ELocation.Blob())
+	c.Assert(err, chk.IsNil)
+	c.Assert(stripTopDir, chk.Equals, true)
+	c.Assert(result, chk.Equals, src)
+
+	// other remote locations still only accept a trailing wildcard
+	raw = rawCopyCmdArgs{src: "https://account.file.core.windows.net/share/logs/2023-*"}
+	_, _, err = raw.stripTrailingWildcardOnRemoteSource(common.ELocation.File())
+	c.Assert(err, chk.NotNil)
+
+	level, err := DetermineLocationLevel("https://account.blob.core.windows.net/container/logs/2023-*", common.ELocation.Blob(), true)
+	c.Assert(err, chk.IsNil)
+	c.Assert(level, chk.Equals, ELocationLevel.Container())
+
+	// transfers are relative to the virtual directory holding the first wildcard
+	root, err := GetResourceRoot("https://account.blob.core.windows.net/container/logs/2023-*", common.ELocation.Blob())
+	c.Assert(err, chk.IsNil)
+	c.Assert(root, chk.Equals, "https://account.blob.core.windows.net/container/logs/")
+}
+
+// newFakeBlobListingServer serves flat blob listings of the given blob names, honouring the prefix, and records the prefixes asked for
+func newFakeBlobListingServer(blobNames []string, prefixes *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := r.URL.Query().Get("prefix")
+		*prefixes = append(*prefixes, prefix)
+
+		var sb strings.Builder
+		sb.WriteString(`<?xml version="1.0" encoding="utf-8"?><EnumerationResults><Blobs>`)
+		for _, name := range blobNames {
+			if strings.HasPrefix(name, prefix) {
+				fmt.Fprintf(&sb, `<Blob><Name>%s</Name><Properties><Last-Modified>Mon, 02 Jan 2023 00:00:00 GMT</Last-Modified>`+
+					`<Content-Length>5</Content-Length><BlobType>BlockBlob</BlobType></Properties></Blob>`, name)
+			}
+		}
+		sb.WriteString(`</Blobs><NextMarker /></EnumerationResults>`)
+
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = w.Write([]byte(sb.String()))
+	}))
+}
+
+func (s *blobWildcardTestSuite) TestBlobWildcardTraversal(c *chk.C) {
+	blobNames := []string{"logs/2022-12.log", "logs/2023-01.log", "logs/2023-02.log", "logs/2023-03/detail.log", "logs/archive/2023-01.log", "logs/notes.txt"}
+
+	testCases := []struct {
+		blobName       string
+		expectedPrefix string
+		expected       []string
+	}{
+		{"logs/2023-*", "logs/2023-", []string{"2023-01.log", "2023-02.log"}},
+		{"logs/2023-**", "logs/2023-", []string{"2023-01.log", "2023-02.log", "2023-03/detail.log"}},
+		{"logs/**/2023-*.log", "logs/", []string{"2023-01.log", "2023-02.log", "archive/2023-01.log"}},
+	}
+
+	for _, tc := range testCases {
+		var prefixes []string
+		server := newFakeBlobListingServer(blobNames, &prefixes)
+
+		// the fake server has an IP address for a host, so the account name goes in the path
+		rawURL, err := url.Parse(server.URL + "/account/container/" + tc.blobName)
+		c.Assert(err, chk.IsNil)
+		p := azblob.NewPipeline(azblob.NewAnonymousCredential(), azblob.PipelineOptions{})
+		traverser, err := newBlobWildcardTraverser(rawURL, p, context.Background(), false, nil, false, common.CpkOptions{})
+		c.Assert(err, chk.IsNil)
+		c.Assert(traverser.IsDirectory(true), chk.Equals, true)
+
+		var found []string
+		err = traverser.Traverse(noPreProccessor, func(object StoredObject) error {
+			found = append(found, object.relativePath)
+			c.Assert(object.ContainerName, chk.Equals, "container")
+			return nil
+		}, nil)
+		server.Close()
+
+		c.Assert(err, chk.IsNil)
+		c.Assert(prefixes, chk.DeepEquals, []string{tc.expectedPrefix}, chk.Commentf("wildcard %s", tc.blobName))
+		c.Assert(found, chk.DeepEquals, tc.expected, chk.Commentf("wildcard %s", tc.blobName))
+	}
+}
@@ -25,6 +25,8 @@ import (
 	"fmt"
 	"github.com/Azure/azure-pipeline-go/pipeline"
 	"net/url"
+	"path"
+	"time"
 
 	"github.com/Azure/azure-storage-azcopy/v10/common"
 )
@@ -35,8 +37,15 @@ type listTraverser struct {
 	listReader              chan string
 	recursive               bool
 	childTraverserGenerator childTraverserGenerator
+
+	// reportMissingChildren turns a listed path that can't be found into a transfer of its own. The transfer fails when
+	// the STE can't open its source, so the path is reported with the job's other failures, instead of being skipped
+	reportMissingChildren bool
 }
 
+// listOfFilesCommentPrefix starts the lines of a list of files that are comments, not paths
+const listOfFilesCommentPrefix = "#"
+
 type childTraverserGenerator func(childPath string) (ResourceTraverser, error)
 
 // There is no impact to a list traverser returning false because a list traverser points directly to relative paths.
@@ -56,6 +65,13 @@ func (l *listTraverser) Traverse(preprocessor objectMorpher, processor objectPro
 		//   2. a directory entity that needs to be scanned
 		childTraverser, err := l.childTraverserGenerator(childPath)
 		if err != nil {
+			if l.reportMissingChildren {
+				err = l.processMissingChild(childPath, err, preprocessor, processor, filters)
+				if err != nil {
+					return err
+				}
+				continue
+			}
 			glcm.Info(fmt.Sprintf("Skipping %s due to error %s", childPath, err))
 			continue
 		}
@@ -79,8 +95,21 @@ func (l *listTraverser) Traverse(preprocessor objectMorpher, processor objectPro
 		}
 		preProcessorForThisChild := preprocessor.FollowedBy(childPreProcessor)
 
-		err = childTraverser.Traverse(preProcessorForThisChild, processor, filters)
+		found := false
+		countingProcessor := func(object StoredObject) error {
+			found = true
+			return processor(object)
+		}
+
+		err = childTraverser.Traverse(preProcessorForThisChild, countingProcessor, filters)
 		if err != nil {
+			if l.reportMissingChildren && !found {
+				err = l.processMissingChild(childPath, err, preprocessor, processor, filters)
+				if err != nil {
+					return err
+				}
+				continue
+			}
 			glcm.Info(fmt.Sprintf("Skipping %s as it cannot be scanned due to error: %s", childPath, err))
 		}
 	}
@@ -88,6 +117,30 @@ func (l *listTraverser) Traverse(preprocessor objectMorpher, processor objectPro
 	return nil
 }
 
+// processMissingChild sends the processor an empty file in place of a listed path that couldn't be found (or scanned),
+// so that the path gets a transfer, and its failure is reported like any other
+func (l *listTraverser) processMissingChild(childPath string, cause error, preprocessor objectMorpher, processor objectProcessor, filters []ObjectFilter) error {
+	glcm.Info(fmt.Sprintf("%s will be reported as a failed transfer, since it cannot be found: %s", childPath, cause))
+
+	childPreProcessor := func(object *StoredObject) {
+		object.relativePath = common.GenerateFullPath(childPath, object.relativePath)
+	}
+	return processIfPassedFilters(filters,
+		newStoredObject(
+			preprocessor.FollowedBy(childPreProcessor),
+			path.Base(childPath),
+			"",
+			common.EEntityType.File(),
+			time.Time{},
+			0,
+			noContentProps,
+			noBlobProps,
+			noMetdata,
+			"",
+		),
+		processor)
+}
+
 func newListTraverser(parent common.ResourceString, parentType common.Location, credential *common.CredentialInfo,
 	ctx *context.Context, recursive, followSymlinks, getProperties bool, listChan chan string,
 	includeDirectoryStubs bool, incrementEnumerationCounter enumerationCounterFunc, s2sPreserveBlobTags bool,
@@ -29,6 +29,8 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/Azure/azure-pipeline-go/pipeline"
+
 	"github.com/Azure/azure-storage-azcopy/v10/common"
 	"github.com/Azure/azure-storage-azcopy/v10/common/parallel"
 )
@@ -153,6 +155,9 @@ func (s symlinkTargetFileInfo) Name() string {
 // Separate this from the traverser for two purposes:
 // 1) Cleaner code
 // 2) Easier to test individually than to test the entire traverser.
+// When following symlinks, the target of a link is always enumerated as if it lived at the link's location,
+// even when the target is outside of fullPath. Directories are only walked once, so link cycles are harmless.
+// When not following them, symlinks are skipped (with a note in the scanning log).
 func WalkWithSymlinks(fullPath string, walkFunc filepath.WalkFunc, followSymlinks bool) (err error) {
 
 	// We want to re-queue symlinks up in their evaluated form because filepath.Walk doesn't evaluate them for us.
@@ -171,6 +176,17 @@ func WalkWithSymlinks(fullPath string, walkFunc filepath.WalkFunc, followSymlink
 
 	walkQueue := []walkItem{{fullPath: fullPath, relativeBase: ""}}
 
+	// parallel.Walk stops at the first error that walkFunc returns, but doesn't return it, so we keep it to return it ourselves
+	var walkErr error
+	innerWalkFunc := walkFunc
+	walkFunc = func(filePath string, fileInfo os.FileInfo, fileError error) error {
+		err := innerWalkFunc(filePath, fileInfo, fileError)
+		if err != nil && err != ignoredError && walkErr == nil {
+			walkErr = err
+		}
+		return err
+	}
+
 	// do NOT put fullPath: true into the map at this time, because we want to match the semantics of filepath.Walk, where the walkfunc is called for the root
 	// When following symlinks, our current implementation tracks folders and files.  Which may consume GB's of RAM when there are 10s of millions of files.
 	var seenPaths seenPathsRecorder = &nullSeenPathsRecorder{} // uses no RAM
@@ -178,7 +194,7 @@ func WalkWithSymlinks(fullPath string, walkFunc filepath.WalkFunc, followSymlink
 		seenPaths = &realSeenPathsRecorder{make(map[string]struct{})} // have to use the RAM if we are dealing with symlinks, to prevent cycles
 	}
 
-	for len(walkQueue) > 0 {
+	for len(walkQueue) > 0 && walkErr == nil {
 		queueItem := walkQueue[0]
 		walkQueue = walkQueue[1:]
 		// walk contents of this queueItem in parallel
@@ -198,6 +214,7 @@ func WalkWithSymlinks(fullPath string, walkFunc filepath.WalkFunc, followSymlink
 
 			if fileInfo.Mode()&os.ModeSymlink != 0 {
 				if !followSymlinks {
+					logSkippedSymlink(common.GenerateFullPath(fullPath, computedRelativePath))
 					return nil // skip it
 				}
 				result, err := UnfurlSymlinks(filePath)
@@ -229,7 +246,7 @@ func WalkWithSymlinks(fullPath string, walkFunc filepath.WalkFunc, followSymlink
 					if !seenPaths.HasSeen(result) {
 						err := walkFunc(common.GenerateFullPath(fullPath, computedRelativePath), symlinkTargetFileInfo{rStat, fileInfo.Name()}, fileError)
 						// Since this doesn't directly manipulate the error, and only checks for a specific error, it's OK to use in a generic function.
-						skipped, err := getProcessingError(err)
+						skipped, err := getProcessingErrorToStop(err)
 
 						if !skipped { // Don't go any deeper (or record it) if we skipped it.
 							seenPaths.Record(common.ToExtendedPath(result))
@@ -243,28 +260,19 @@ func WalkWithSymlinks(fullPath string, walkFunc filepath.WalkFunc, followSymlink
 						return err
 					} else {
 						WarnStdoutAndScanningLog(fmt.Sprintf("Ignored already linked directory pointed at %s (link at %s)", result, common.GenerateFullPath(fullPath, computedRelativePath)))
+						return nil
 					}
 				} else {
-					WarnStdoutAndScanningLog(fmt.Sprintf("Symlinks to individual files are not currently supported, so will ignore file at %s (link at %s)", result, common.GenerateFullPath(fullPath, computedRelativePath)))
-					// TODO: remove the above info call and enable the below, with suitable multi-OS testing
-					//    including enable the test: TestWalkWithSymlinks_ToFile
-					/*
-							// It's a symlink to a file. Just process the file because there's no danger of cycles with links to individual files.
-							// (this does create the inconsistency that if there are two symlinks to the same file we will process it twice,
-							// but if there are two symlinks to the same directory we will process it only once. Because only directories are
-							// deduped to break cycles.  For now, we are living with the inconsistency. The alternative would be to "burn" more
-							// RAM by putting filepaths into seenDirs too, but that could be a non-trivial amount of RAM in big directories trees).
-
-							// TODO: this code here won't handle the case of (file-type symlink) -> (another file-type symlink) -> file
-						    //    But do we WANT to handle that?  (since it opens us to risk of file->file cycles, and we are deliberately NOT
-						    //    putting files in our map, to reduce RAM usage).  Maybe just detect if the target of a file symlink its itself a symlink
-						    //    and skip those cases with an error message?
-							// Make file info that has name of source, and stats of dest (to mirror what os.Stat calls on source will give us later)
-							targetFi := symlinkTargetFileInfo{rStat, fileInfo.Name()}
-							return walkFunc(common.GenerateFullPath(fullPath, computedRelativePath), targetFi, fileError)
-					*/
+					// It's a symlink to a file. Just process the file because there's no danger of cycles with links to individual files.
+					// (this does create the inconsistency that if there are two symlinks to the same file we will process it twice,
+					// but if there are two symlinks to the same directory we will process it only once. Because only directories are
+					// deduped to break cycles. The alternative would be to "burn" more RAM by putting file paths into seenPaths
+					// for every link too).
+					// UnfurlSymlinks has already followed any chain of links, so rStat describes the real file at the end of it.
+					// Make file info that has name of source, and stats of dest (to mirror what os.Stat calls on source will give us later)
+					targetFi := symlinkTargetFileInfo{rStat, fileInfo.Name()}
+					return walkFunc(common.GenerateFullPath(fullPath, computedRelativePath), targetFi, fileError)
 				}
-				return nil
 			} else {
 				// not a symlink
 				result, err := filepath.Abs(filePath)
@@ -277,7 +285,7 @@ func WalkWithSymlinks(fullPath string, walkFunc filepath.WalkFunc, followSymlink
 				if !seenPaths.HasSeen(result) {
 					err := walkFunc(common.GenerateFullPath(fullPath, computedRelativePath), fileInfo, fileError)
 					// Since this doesn't directly manipulate the error, and only checks for a specific error, it's OK to use in a generic function.
-					skipped, err := getProcessingError(err)
+					skipped, err := getProcessingErrorToStop(err)
 
 					// If the file was skipped, don't record it.
 					if !skipped {
@@ -298,7 +306,7 @@ func WalkWithSymlinks(fullPath string, walkFunc filepath.WalkFunc, followSymlink
 			}
 		})
 	}
-	return
+	return walkErr
 }
 
 func (t *localTraverser) Traverse(preprocessor objectMorpher, processor objectProcessor, filters []ObjectFilter) (err error) {
@@ -329,7 +337,7 @@ func (t *localTraverser) Traverse(preprocessor objectMorpher, processor objectPr
 			),
 			processor,
 		)
-		_, err = getProcessingError(err)
+		_, err = getProcessingErrorToStop(err)
 		return err
 	} else {
 		if t.recursive {
@@ -396,6 +404,7 @@ func (t *localTraverser) Traverse(preprocessor objectMorpher, processor objectPr
 				relativePath := singleFile.Name()
 				if singleFile.Mode()&os.ModeSymlink != 0 {
 					if !t.followSymlinks {
+						logSkippedSymlink(common.GenerateFullPath(t.fullPath, singleFile.Name()))
 						continue
 					} else {
 						// Because this only goes one layer deep, we can just append the filename to fullPath and resolve with it.
@@ -446,7 +455,7 @@ func (t *localTraverser) Traverse(preprocessor objectMorpher, processor objectPr
 						"", // Local has no such thing as containers
 					),
 					processor)
-				_, err = getProcessingError(err)
+				_, err = getProcessingErrorToStop(err)
 				if err != nil {
 					return err
 				}
@@ -487,3 +496,11 @@ func cleanLocalPath(localPath string) string {
 
 	return normalizedPath
 }
+
+// logSkippedSymlink notes, in the scanning log only, that a symlink was not followed.
+// Not worth a warning on stdout, since not following them is the default.
+func logSkippedSymlink(linkPath string) {
+	if azcopyScanningLogger != nil {
+		azcopyScanningLogger.Log(pipeline.LogInfo, fmt.Sprintf("Skipping over symlink at %s because --follow-symlinks is false", linkPath))
+	}
+}
@@ -1,9 +1,17 @@
 package cmd
 
 import (
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
 )
 
 type localTraverserTestSuite struct{}
@@ -50,3 +58,103 @@ func (s *localTraverserTestSuite) TestCleanLocalPathForWindows(c *chk.C) {
 		c.Assert(cleanLocalPath(orig), chk.Equals, expected)
 	}
 }
+
+// makeNestedTree creates top.txt, sub/mid.txt and sub/deeper/low.txt under a new directory
+func (s *localTraverserTestSuite) makeNestedTree(c *chk.C) string {
+	root := c.MkDir()
+	c.Assert(os.MkdirAll(filepath.Join(root, "sub", "deeper"), 0755), chk.IsNil)
+	for _, name := range []string{"top.txt", "sub/mid.txt", "sub/deeper/low.txt"} {
+		c.Assert(ioutil.WriteFile(filepath.Join(root, filepath.FromSlash(name)), []byte(name), 0644), chk.IsNil)
+	}
+	return root
+}
+
+// filesFound lists the relative paths of the files the local traverser finds under root
+func (s *localTraverserTestSuite) filesFound(c *chk.C, root string, recursive bool) []string {
+	found := make([]string, 0)
+	traverser := newLocalTraverser(root, recursive, false, func(common.EntityType) {})
+	err := traverser.Traverse(noPreProccessor, func(o StoredObject) error {
+		if o.entityType == common.EEntityType.File() {
+			found = append(found, filepath.ToSlash(o.relativePath))
+		}
+		return nil
+	}, nil)
+	c.Assert(err, chk.IsNil)
+	sort.Strings(found)
+	return found
+}
+
+func (s *localTraverserTestSuite) TestRecursiveEnumerationOfNestedTree(c *chk.C) {
+	root := s.makeNestedTree(c)
+	c.Assert(s.filesFound(c, root, true), chk.DeepEquals, []string{"sub/deeper/low.txt", "sub/mid.txt", "top.txt"})
+}
+
+func (s *localTraverserTestSuite) TestNonRecursiveEnumerationOnlyFindsTopLevelFiles(c *chk.C) {
+	root := s.makeNestedTree(c)
+	c.Assert(s.filesFound(c, root, false), chk.DeepEquals, []string{"top.txt"})
+}
+
+func (s *localTraverserTestSuite) TestDirectorySourceNeedsRecursiveOrWildcard(c *chk.C) {
+	err := validateDirectorySource(true, false, false)
+	c.Assert(err, chk.NotNil)
+	c.Assert(err, chk.ErrorMatches, ".*--recursive.*")
+
+	c.Assert(validateDirectorySource(true, true, false), chk.IsNil)   // the whole tree
+	c.Assert(validateDirectorySource(true, false, true), chk.IsNil)   // dir/*, top-level files only
+	c.Assert(validateDirectorySource(false, false, false), chk.IsNil) // a single file needs neither
+}
+
+// makeDeepAndWideTree creates a tree that is both wide (several subdirectories in each directory) and deep
+// (a long chain of nested directories), with a few files in every directory. It returns the relative paths of the files
+func (s *localTraverserTestSuite) makeDeepAndWideTree(c *chk.C, root string) map[string]int {
+	files := make(map[string]int)
+	var makeDir func(rel string, depth int)
+	makeDir = func(rel string, depth int) {
+		c.Assert(os.MkdirAll(filepath.Join(root, filepath.FromSlash(rel)), 0755), chk.IsNil)
+		for i := 0; i < 4; i++ {
+			name := path.Join(rel, fmt.Sprintf("file%d.txt", i))
+			c.Assert(ioutil.WriteFile(filepath.Join(root, filepath.FromSlash(name)), nil, 0644), chk.IsNil)
+			files[strings.TrimPrefix(name, "./")] = 0
+		}
+		if depth == 0 {
+			return
+		}
+		for i := 0; i < 3; i++ {
+			makeDir(path.Join(rel, fmt.Sprintf("dir%d", i)), depth-1)
+		}
+	}
+	makeDir(".", 5)
+
+	deep := "."
+	for i := 0; i < 40; i++ {
+		deep = path.Join(deep, fmt.Sprintf("level%d", i))
+	}
+	makeDir(deep, 0)
+	return files
+}
+
+func (s *localTraverserTestSuite) TestParallelEnumerationFindsEachFileOnce(c *chk.C) {
+	defer func(original int) { EnumerationParallelism = original }(EnumerationParallelism)
+	EnumerationParallelism = 16
+
+	root := c.MkDir()
+	expected := s.makeDeepAndWideTree(c, root)
+	c.Assert(len(expected) > 1000, chk.Equals, true)
+
+	found := make(map[string]int)
+	traverser := newLocalTraverser(root, true, false, func(common.EntityType) {})
+	err := traverser.Traverse(noPreProccessor, func(o StoredObject) error {
+		if o.entityType == common.EEntityType.File() {
+			found[filepath.ToSlash(o.relativePath)]++
+		}
+		return nil
+	}, nil)
+	c.Assert(err, chk.IsNil)
+
+	c.Assert(len(found), chk.Equals, len(expected))
+	for name, count := range found {
+		_, ok := expected[name]
+		c.Check(ok, chk.Equals, true, chk.Commentf("unexpected %s", name))
+		c.Check(count, chk.Equals, 1, chk.Commentf(name))
+	}
+}
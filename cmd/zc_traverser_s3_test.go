@@ -0,0 +1,158 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/minio/minio-go"
+	"github.com/minio/minio-go/pkg/credentials"
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+type s3TraverserTestSuite struct{}
+
+var _ = chk.Suite(&s3TraverserTestSuite{})
+
+// fakeS3Object is a single object served by newFakeS3Server.
+type fakeS3Object struct {
+	key          string
+	size         int64
+	storageClass string
+}
+
+// newFakeS3Server serves just enough of the S3 API (ListObjectsV2 and HEAD object) for the traverser,
+// using path-style addressing against a single bucket.
+func newFakeS3Server(bucket string, objects []fakeS3Object) *httptest.Server {
+	const lastModified = "2021-01-02T03:04:05.000Z"
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/")
+		if !strings.HasPrefix(path, bucket) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		key := strings.TrimPrefix(strings.TrimPrefix(path, bucket), "/")
+
+		if r.Method == http.MethodHead {
+			for _, o := range objects {
+				if o.key == key {
+					w.Header().Set("Content-Length", fmt.Sprint(o.size))
+					w.Header().Set("Last-Modified", "Sat, 02 Jan 2021 03:04:05 GMT")
+					w.Header().Set("ETag", `"etag"`)
+					w.WriteHeader(http.StatusOK)
+					return
+				}
+			}
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if r.URL.Query().Get("list-type") != "2" {
+			w.WriteHeader(http.StatusNotImplemented)
+			return
+		}
+		prefix := r.URL.Query().Get("prefix")
+		var contents strings.Builder
+		for _, o := range objects {
+			if strings.HasPrefix(o.key, prefix) {
+				fmt.Fprintf(&contents, "<Contents><Key>%s</Key><LastModified>%s</LastModified><ETag>&quot;etag&quot;</ETag><Size>%d</Size><StorageClass>%s</StorageClass></Contents>",
+					o.key, lastModified, o.size, o.storageClass)
+			}
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Name>%s</Name><Prefix>%s</Prefix><KeyCount>%d</KeyCount><MaxKeys>1000</MaxKeys><IsTruncated>false</IsTruncated>%s</ListBucketResult>`,
+			bucket, prefix, len(objects), contents.String())
+	}))
+}
+
+// newFakeS3Traverser builds an s3Traverser for rawURL whose client talks to the fake server instead of AWS.
+func newFakeS3Traverser(c *chk.C, server *httptest.Server, rawURL string, recursive bool) *s3Traverser {
+	u, err := url.Parse(rawURL)
+	c.Assert(err, chk.IsNil)
+	parts, err := common.NewS3URLParts(*u)
+	c.Assert(err, chk.IsNil)
+
+	client, err := minio.NewWithOptions(strings.TrimPrefix(server.URL, "http://"), &minio.Options{
+		Creds:        credentials.NewStatic("", "", "", credentials.SignatureAnonymous),
+		Secure:       false,
+		Region:       "us-east-1",
+		BucketLookup: minio.BucketLookupPath,
+	})
+	c.Assert(err, chk.IsNil)
+
+	return &s3Traverser{
+		rawURL:     u,
+		ctx:        context.Background(),
+		recursive:  recursive,
+		s3URLParts: s3URLPartsExtension{parts},
+		s3Client:   client,
+	}
+}
+
+func (s *s3TraverserTestSuite) TestS3TraverserEnumeratesVirtualDirectory(c *chk.C) {
+	server := newFakeS3Server("bucket", []fakeS3Object{
+		{key: "dir/a.txt", size: 10, storageClass: "STANDARD"},
+		{key: "dir/sub/b.txt", size: 20, storageClass: "STANDARD"},
+		{key: "dir/placeholder/", size: 0, storageClass: "STANDARD"}, // folder marker, skipped
+		{key: "dir/bad.", size: 1, storageClass: "STANDARD"},         // not a valid blob name, skipped
+		{key: "dir/prefix", size: 0},                                 // directories have no storage class
+	})
+	defer server.Close()
+
+	t := newFakeS3Traverser(c, server, "https://s3.amazonaws.com/bucket/dir/", true)
+	c.Assert(t.IsDirectory(true), chk.Equals, true)
+
+	found := map[string]int64{}
+	err := t.Traverse(noPreProccessor, func(o StoredObject) error {
+		found[o.relativePath] = o.size
+		c.Assert(o.ContainerName, chk.Equals, "bucket")
+		return nil
+	}, nil)
+	c.Assert(err, chk.IsNil)
+	c.Assert(found, chk.DeepEquals, map[string]int64{"a.txt": 10, "sub/b.txt": 20})
+}
+
+func (s *s3TraverserTestSuite) TestS3TraverserSingleObject(c *chk.C) {
+	server := newFakeS3Server("bucket", []fakeS3Object{
+		{key: "dir/a.txt", size: 42, storageClass: "STANDARD"},
+	})
+	defer server.Close()
+
+	t := newFakeS3Traverser(c, server, "https://s3.amazonaws.com/bucket/dir/a.txt", false)
+	c.Assert(t.IsDirectory(true), chk.Equals, false)
+
+	var objects []StoredObject
+	err := t.Traverse(noPreProccessor, func(o StoredObject) error {
+		objects = append(objects, o)
+		return nil
+	}, nil)
+	c.Assert(err, chk.IsNil)
+	c.Assert(objects, chk.HasLen, 1)
+	c.Assert(objects[0].name, chk.Equals, "a.txt")
+	c.Assert(objects[0].size, chk.Equals, int64(42))
+}
@@ -0,0 +1,135 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	chk "gopkg.in/check.v1"
+)
+
+type azuriteSuite struct{}
+
+var _ = chk.Suite(&azuriteSuite{})
+
+func (s *azuriteSuite) useConnectionString(value string) func() {
+	env := common.EEnvironmentVariable.ConnectionString().Name
+	original, wasSet := os.LookupEnv(env)
+	_ = os.Setenv(env, value)
+	return func() {
+		if wasSet {
+			_ = os.Setenv(env, original)
+		} else {
+			_ = os.Unsetenv(env)
+		}
+	}
+}
+
+func (s *azuriteSuite) TestEmulatorURLsUseSharedKey(c *chk.C) {
+	defer s.useConnectionString("UseDevelopmentStorage=true")()
+	noForcedCredType := func() common.CredentialType { return common.ECredentialType.Unknown() }
+
+	credType, isPublic, err := doGetCredentialTypeForLocation(context.Background(), common.ELocation.Blob(),
+		"http://127.0.0.1:10000/devstoreaccount1/container/blob", "", false, noForcedCredType, common.CpkOptions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(credType, chk.Equals, common.ECredentialType.SharedKey())
+	c.Assert(isPublic, chk.Equals, false)
+
+	// a SAS still takes precedence
+	credType, _, err = doGetCredentialTypeForLocation(context.Background(), common.ELocation.Blob(),
+		"http://127.0.0.1:10000/devstoreaccount1/container/blob", "sv=2019-12-12&sig=x", false, noForcedCredType, common.CpkOptions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(credType, chk.Equals, common.ECredentialType.Anonymous())
+
+	// and the key is only trusted for the endpoint it came with
+	c.Assert(checkAuthSafeForTarget(common.ECredentialType.SharedKey(), "http://127.0.0.1:10000/devstoreaccount1/c", "", common.ELocation.Blob()), chk.IsNil)
+	c.Assert(checkAuthSafeForTarget(common.ECredentialType.SharedKey(), "http://127.0.0.1:8080/devstoreaccount1/c", "", common.ELocation.Blob()), chk.NotNil)
+}
+
+// TestUploadToAzuriteAndReadBack needs the storage emulator, e.g. "docker run -p 10000:10000 mcr.microsoft.com/azure-storage/azurite azurite-blob --blobHost 0.0.0.0"
+func (s *azuriteSuite) TestUploadToAzuriteAndReadBack(c *chk.C) {
+	conn, err := net.DialTimeout("tcp", "127.0.0.1:10000", time.Second)
+	if err != nil {
+		c.Skip("the storage emulator (Azurite) is not listening on 127.0.0.1:10000")
+	}
+	_ = conn.Close()
+	defer s.useConnectionString("UseDevelopmentStorage=true")()
+	ctx := context.Background()
+
+	containerName := generateContainerName()
+	rawContainerURL := common.DevelopmentStorageBlobEndpoint + "/" + containerName
+	credInfo, _, err := GetCredentialInfoForLocation(ctx, common.ELocation.Blob(), rawContainerURL, "", false, common.CpkOptions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(credInfo.CredentialType, chk.Equals, common.ECredentialType.SharedKey())
+	p, err := createBlobPipeline(ctx, credInfo, pipeline.LogNone)
+	c.Assert(err, chk.IsNil)
+	containerURL, err := url.Parse(rawContainerURL)
+	c.Assert(err, chk.IsNil)
+	container := azblob.NewContainerURL(*containerURL, p)
+	_, err = container.Create(ctx, nil, azblob.PublicAccessNone)
+	c.Assert(err, chk.IsNil)
+	defer container.Delete(ctx, azblob.ContainerAccessConditions{})
+
+	data := make([]byte, 3*1024*1024+17)
+	rand.Read(data)
+	blobURL := rawContainerURL + "/piped.bin"
+
+	// upload, from stdin
+	stdin, feeder, err := os.Pipe()
+	c.Assert(err, chk.IsNil)
+	originalStdin := os.Stdin
+	os.Stdin = stdin
+	go func() {
+		_, _ = feeder.Write(data)
+		_ = feeder.Close()
+	}()
+	upload := &CookedCopyCmdArgs{Destination: common.ResourceString{Value: blobURL}, FromTo: common.EFromTo.PipeBlob(), blockBlobTier: common.EBlockBlobTier.None()}
+	err = upload.processRedirectionUpload(upload.Destination, 1024*1024, os.Stdin)
+	os.Stdin = originalStdin
+	c.Assert(err, chk.IsNil)
+
+	// and read it back, to stdout
+	drain, stdout, err := os.Pipe()
+	c.Assert(err, chk.IsNil)
+	originalStdout := os.Stdout
+	os.Stdout = stdout
+	downloaded := make(chan []byte)
+	go func() {
+		b, _ := ioutil.ReadAll(drain)
+		downloaded <- b
+	}()
+	download := &CookedCopyCmdArgs{Source: common.ResourceString{Value: blobURL}, FromTo: common.EFromTo.BlobPipe()}
+	err = download.processRedirectionDownload(download.Source)
+	os.Stdout = originalStdout
+	_ = stdout.Close()
+	c.Assert(err, chk.IsNil)
+	c.Assert(bytes.Equal(<-downloaded, data), chk.Equals, true)
+}
@@ -40,6 +40,9 @@ func (s *genericFilterSuite) TestConversions(c *chk.C) {
 		{0.25, 256 * 1024, ""},
 		{0.000030517578125, 32, ""}, // 32 bytes, extremely small case
 		{-1, 0, "negative block size not allowed"},
+		{256, 256 * 1024 * 1024, ""},
+		{4000, 4000 * 1024 * 1024, ""}, // the largest block the service accepts
+		{4001, 0, "block size of 4001 MiB is larger than the maximum the service allows, which is 4000 MiB"},
 		{0.333, 0, "while fractional numbers of MiB are allowed as the block size, the fraction must result to a whole number of bytes. 0.333000000000 MiB resolves to 349175.808 bytes"},
 	}
 
@@ -52,3 +55,29 @@ func (s *genericFilterSuite) TestConversions(c *chk.C) {
 		}
 	}
 }
+
+func (s *genericFilterSuite) TestPutBlobSizeConversions(c *chk.C) {
+
+	testData := []struct {
+		floatMiB         float64
+		expectedBytes    int64
+		expectedErrorMsg string
+	}{
+		{0, 0, ""},
+		{256, 256 * 1024 * 1024, ""},
+		{4001, 4001 * 1024 * 1024, ""}, // bigger than any block
+		{5000, 5000 * 1024 * 1024, ""}, // the largest single put the service accepts
+		{5001, 0, "put blob size of 5001 MiB is larger than the maximum the service allows, which is 5000 MiB"},
+		{-1, 0, "negative put blob size not allowed"},
+	}
+
+	for _, d := range testData {
+		actualBytes, err := putBlobSizeInBytes(d.floatMiB)
+		if d.expectedErrorMsg != "" {
+			c.Check(err.Error(), chk.Equals, d.expectedErrorMsg)
+		} else {
+			c.Check(err, chk.IsNil)
+			c.Check(actualBytes, chk.Equals, d.expectedBytes)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"os"
+	"sort"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	chk "gopkg.in/check.v1"
+)
+
+type blobNameNormalizationSuite struct{}
+
+var _ = chk.Suite(&blobNameNormalizationSuite{})
+
+func (s *blobNameNormalizationSuite) TestNormalize(c *chk.C) {
+	combining := "café/Menu.TXT" // "e" followed by COMBINING ACUTE ACCENT
+	precomposed := "café/Menu.TXT"
+
+	c.Assert(common.EBlobNameNormalization.None().Normalize(combining), chk.Equals, combining)
+	c.Assert(common.EBlobNameNormalization.NFC().Normalize(combining), chk.Equals, precomposed)
+	c.Assert(common.EBlobNameNormalization.NFC().Normalize(precomposed), chk.Equals, precomposed)
+	c.Assert(common.EBlobNameNormalization.Lowercase().Normalize(combining), chk.Equals, "café/menu.txt")
+}
+
+// upload uploads a local tree of the given files with --normalize-blob-names, and returns where each file would be written
+func (s *blobNameNormalizationSuite) upload(c *chk.C, normalization string, files []string) ([]string, error) {
+	return s.uploadTree(c, normalization, files, true)
+}
+
+func (s *blobNameNormalizationSuite) uploadTree(c *chk.C, normalization string, files []string, recursive bool) ([]string, error) {
+	srcDirName := scenarioHelper{}.generateLocalDirectory(c)
+	defer os.RemoveAll(srcDirName)
+	scenarioHelper{}.generateLocalFilesFromList(c, srcDirName, files)
+
+	mockedRPC := interceptor{}
+	Rpc = mockedRPC.intercept
+	mockedRPC.init()
+
+	src := srcDirName
+	if !recursive {
+		src += "/*" // only the files at the top of the tree
+	}
+	raw := getDefaultCopyRawInput(src, "https://account.blob.core.windows.net/container?sv=2019-12-12&sp=rwl&sig=fake")
+	raw.recursive = recursive
+	raw.asSubdir = false
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+	raw.skipContainerCheck = true
+	raw.normalizeBlobNames = normalization
+
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	if err = cooked.process(); err != nil {
+		return nil, err
+	}
+
+	destinations := make([]string, 0, len(mockedRPC.transfers))
+	for _, transfer := range mockedRPC.transfers {
+		destinations = append(destinations, transfer.Destination)
+	}
+	sort.Strings(destinations)
+	return destinations, nil
+}
+
+func (s *blobNameNormalizationSuite) TestLowercaseNames(c *chk.C) {
+	destinations, err := s.upload(c, "lowercase", []string{"Logs/App.TXT", "readme.md"})
+	c.Assert(err, chk.IsNil)
+	c.Assert(destinations, chk.DeepEquals, []string{"/logs/app.txt", "/readme.md"})
+
+	// by default, names are kept as they are
+	destinations, err = s.upload(c, "", []string{"Logs/App.TXT", "readme.md"})
+	c.Assert(err, chk.IsNil)
+	c.Assert(destinations, chk.DeepEquals, []string{"/Logs/App.TXT", "/readme.md"})
+}
+
+func (s *blobNameNormalizationSuite) TestCaseCollisionFails(c *chk.C) {
+	_, err := s.upload(c, "lowercase", []string{"File.TXT", "file.txt"})
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "because both would be named file.txt at the destination")
+}
+
+func (s *blobNameNormalizationSuite) TestCollisionFailsWithoutRecursion(c *chk.C) {
+	_, err := s.uploadTree(c, "lowercase", []string{"File.TXT", "file.txt"}, false)
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "because both would be named file.txt at the destination")
+}
+
+func (s *blobNameNormalizationSuite) TestNFCCollisionFails(c *chk.C) {
+	files := []string{"café.txt", "café.txt"}
+
+	destinations, err := s.upload(c, "none", files)
+	c.Assert(err, chk.IsNil)
+	c.Assert(destinations, chk.HasLen, 2)
+
+	_, err = s.upload(c, "nfc", files)
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "after --normalize-blob-names is applied")
+}
+
+func (s *blobNameNormalizationSuite) TestOnlyForBlobDestinations(c *chk.C) {
+	raw := getDefaultCopyRawInput("/tmp/src", "https://account.file.core.windows.net/share?sv=2019-12-12&sig=fake")
+	raw.fromTo = common.EFromTo.LocalFile().String()
+	raw.normalizeBlobNames = "lowercase"
+	_, err := raw.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "only applies when the destination is Blob storage")
+
+	raw = getDefaultCopyRawInput("/tmp/src", "https://account.blob.core.windows.net/container?sv=2019-12-12&sig=fake")
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+	raw.normalizeBlobNames = "upper"
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "invalid --normalize-blob-names value")
+}
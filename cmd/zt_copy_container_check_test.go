@@ -0,0 +1,129 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	chk "gopkg.in/check.v1"
+)
+
+type copyContainerCheckSuite struct{}
+
+var _ = chk.Suite(&copyContainerCheckSuite{})
+
+// containerPropertiesServer answers every request with the given status, and counts the requests
+type containerPropertiesServer struct {
+	*httptest.Server
+	requests int32
+}
+
+func newContainerPropertiesServer(status int) *containerPropertiesServer {
+	s := &containerPropertiesServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&s.requests, 1)
+		w.WriteHeader(status)
+	}))
+	return s
+}
+
+// containerURL is the URL of a container on the server. It uses a host name, since an IP address would be taken as a path-style URL
+func (s *containerPropertiesServer) containerURL(sas string) string {
+	return strings.Replace(s.URL, "127.0.0.1", "localhost", 1) + "/container?" + sas
+}
+
+// uploadTo uploads a small local directory to the destination, as far as scheduling the transfers
+func (s *copyContainerCheckSuite) uploadTo(c *chk.C, dst string, skipCheck bool) (*interceptor, error) {
+	srcDirName := scenarioHelper{}.generateLocalDirectory(c)
+	defer os.RemoveAll(srcDirName)
+	scenarioHelper{}.generateLocalFilesFromList(c, srcDirName, []string{"file1.txt", "sub/file2.txt"})
+
+	mockedRPC := &interceptor{}
+	Rpc = mockedRPC.intercept
+	mockedRPC.init()
+	glcm = &mockedLifecycleManager{infoLog: make(chan string, 50)}
+
+	raw := getDefaultCopyRawInput(srcDirName, dst)
+	raw.recursive = true
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+	raw.skipContainerCheck = skipCheck
+
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	return mockedRPC, cooked.process()
+}
+
+func (s *copyContainerCheckSuite) TestMissingContainerFailsTheJob(c *chk.C) {
+	server := newContainerPropertiesServer(http.StatusNotFound)
+	defer server.Close()
+
+	mockedRPC, err := s.uploadTo(c, server.containerURL("sv=2019-12-12&sr=c&sp=rwl&sig=fake"), false)
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "the destination container container does not exist")
+	c.Assert(err.Error(), StringContains, "--skip-container-check")
+	c.Assert(mockedRPC.transfers, chk.HasLen, 0)
+	c.Assert(atomic.LoadInt32(&server.requests), chk.Equals, int32(1)) // one request, for the whole job
+}
+
+func (s *copyContainerCheckSuite) TestSasWithoutWritePermissionFailsTheJob(c *chk.C) {
+	server := newContainerPropertiesServer(http.StatusOK)
+	defer server.Close()
+
+	mockedRPC, err := s.uploadTo(c, server.containerURL("sv=2019-12-12&sr=c&sp=rl&sig=fake"), false)
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "only grants the permissions 'rl'")
+	c.Assert(mockedRPC.transfers, chk.HasLen, 0)
+	c.Assert(atomic.LoadInt32(&server.requests), chk.Equals, int32(0)) // no need to ask the service
+}
+
+func (s *copyContainerCheckSuite) TestRefusedAccountSasFailsTheJob(c *chk.C) {
+	server := newContainerPropertiesServer(http.StatusForbidden)
+	defer server.Close()
+
+	mockedRPC, err := s.uploadTo(c, server.containerURL("sv=2019-12-12&ss=b&srt=sco&sp=rwlac&sig=fake"), false)
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "not authorized to access the destination container container")
+	c.Assert(mockedRPC.transfers, chk.HasLen, 0)
+}
+
+func (s *copyContainerCheckSuite) TestRefusedServiceSasCarriesOn(c *chk.C) {
+	// a container SAS may be able to write, but not to get the container's properties, so a 403 proves nothing
+	server := newContainerPropertiesServer(http.StatusForbidden)
+	defer server.Close()
+
+	mockedRPC, err := s.uploadTo(c, server.containerURL("sv=2019-12-12&sr=c&sp=w&sig=fake"), false)
+	c.Assert(err, chk.IsNil)
+	c.Assert(mockedRPC.transfers, chk.HasLen, 2)
+}
+
+func (s *copyContainerCheckSuite) TestCheckCanBeSkipped(c *chk.C) {
+	server := newContainerPropertiesServer(http.StatusNotFound)
+	defer server.Close()
+
+	mockedRPC, err := s.uploadTo(c, server.containerURL("sv=2019-12-12&sr=c&sp=rwl&sig=fake"), true)
+	c.Assert(err, chk.IsNil)
+	c.Assert(mockedRPC.transfers, chk.HasLen, 2)
+	c.Assert(atomic.LoadInt32(&server.requests), chk.Equals, int32(0))
+}
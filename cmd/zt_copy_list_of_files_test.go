@@ -0,0 +1,114 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"os"
+	"strings"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	chk "gopkg.in/check.v1"
+)
+
+type copyListOfFilesSuite struct{}
+
+var _ = chk.Suite(&copyListOfFilesSuite{})
+
+func (s *copyListOfFilesSuite) TestCommentLinesAreNotPaths(c *chk.C) {
+	listPath := scenarioHelper{}.generateListOfFiles(c, []string{
+		"# files that failed in yesterday's run",
+		"file1.txt",
+		"  # not a comment, since it doesn't start the line",
+		"#sub/file2.txt",
+		"sub/file3.txt",
+	})
+	defer os.Remove(listPath)
+
+	raw := getDefaultCopyRawInput("/tmp/source", "https://account.blob.core.windows.net/container")
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+	raw.listOfFilesToCopy = listPath
+
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.listOfFilesLocation, chk.Equals, listPath)
+
+	listed := make([]string, 0)
+	for v := range cooked.ListOfFilesChannel {
+		listed = append(listed, v)
+	}
+	c.Assert(listed, chk.DeepEquals, []string{"file1.txt", "  # not a comment, since it doesn't start the line", "sub/file3.txt"})
+}
+
+func (s *copyListOfFilesSuite) TestMissingLocalFileGetsATransfer(c *chk.C) {
+	srcDirName := scenarioHelper{}.generateLocalDirectory(c)
+	defer os.RemoveAll(srcDirName)
+	scenarioHelper{}.generateLocalFilesFromList(c, srcDirName, []string{"file1.txt", "sub/file2.txt"})
+
+	listPath := scenarioHelper{}.generateListOfFiles(c, []string{"# rerun", "file1.txt", "sub/gone.txt", "sub/file2.txt"})
+	defer os.Remove(listPath)
+
+	mockedRPC := interceptor{}
+	Rpc = mockedRPC.intercept
+	mockedLcm := mockedLifecycleManager{dryrunLog: make(chan string, 50), infoLog: make(chan string, 50)}
+	mockedLcm.SetOutputFormat(common.EOutputFormat.Text())
+	glcm = &mockedLcm
+
+	raw := getDefaultCopyRawInput(srcDirName, "https://127.0.0.1:1/container?sv=2019-12-12&sig=fake")
+	raw.dryrun = true
+	raw.recursive = true
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+	raw.listOfFilesToCopy = listPath
+
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.process(), chk.IsNil)
+
+	// the missing file is scheduled like the others. The STE then fails it, as it can't open its source
+	dryrunLogs := mockedLcm.GatherAllLogs(mockedLcm.dryrunLog)
+	c.Assert(dryrunLogs, chk.HasLen, 3)
+	c.Assert(strings.Join(dryrunLogs, "\n"), StringContains, "sub/gone.txt")
+
+	infoLogs := mockedLcm.GatherAllLogs(mockedLcm.infoLog)
+	c.Assert(strings.Join(infoLogs, "\n"), StringContains, "sub/gone.txt will be reported as a failed transfer")
+}
+
+func (s *copyListOfFilesSuite) TestMissingFilesAreOnlySkippedWithoutReporting(c *chk.C) {
+	srcDirName := scenarioHelper{}.generateLocalDirectory(c)
+	defer os.RemoveAll(srcDirName)
+	scenarioHelper{}.generateLocalFilesFromList(c, srcDirName, []string{"file1.txt"})
+
+	listChan := make(chan string, 2)
+	listChan <- "file1.txt"
+	listChan <- "gone.txt"
+	close(listChan)
+
+	mockedLcm := mockedLifecycleManager{infoLog: make(chan string, 50)}
+	glcm = &mockedLcm
+	traverser := newListTraverser(common.ResourceString{Value: srcDirName}, common.ELocation.Local(), nil, nil, true, false, false,
+		listChan, false, nil, false, 0, common.CpkOptions{})
+
+	found := make([]string, 0)
+	err := traverser.Traverse(noPreProccessor, func(object StoredObject) error {
+		found = append(found, object.relativePath)
+		return nil
+	}, nil)
+	c.Assert(err, chk.IsNil)
+	c.Assert(found, chk.DeepEquals, []string{"file1.txt"}) // include-path, say, only skips what isn't there
+}
@@ -0,0 +1,110 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"os"
+	"sort"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	chk "gopkg.in/check.v1"
+)
+
+type copyPathPrefixSuite struct{}
+
+var _ = chk.Suite(&copyPathPrefixSuite{})
+
+func (s *copyPathPrefixSuite) TestCookPathPrefix(c *chk.C) {
+	for raw, expected := range map[string]string{
+		"":                 "",
+		"/":                "",
+		"2023/logs":        "2023/logs",
+		"/2023/logs/":      "2023/logs",
+		`2023\logs\`:       "2023/logs", // as typed on Windows
+		"backups/my files": "backups/my files",
+	} {
+		prefix, err := cookPathPrefix("dest-prefix", raw)
+		c.Assert(err, chk.IsNil)
+		c.Assert(prefix, chk.Equals, expected)
+	}
+
+	for _, raw := range []string{"a//b", "a/../b", "./a"} {
+		_, err := cookPathPrefix("strip-prefix", raw)
+		c.Assert(err, chk.NotNil)
+		c.Assert(err.Error(), StringContains, "--strip-prefix '"+raw+"' is not valid")
+	}
+}
+
+// uploadDestinations uploads a local tree with the given prefixes, and returns where each file would be written
+func (s *copyPathPrefixSuite) uploadDestinations(c *chk.C, stripPrefix string, destPrefix string) []string {
+	srcDirName := scenarioHelper{}.generateLocalDirectory(c)
+	defer os.RemoveAll(srcDirName)
+	scenarioHelper{}.generateLocalFilesFromList(c, srcDirName, []string{"2023/logs/app.txt", "2023/logs/sub/db.txt", "2023/readme.txt", "other.txt"})
+
+	mockedRPC := interceptor{}
+	Rpc = mockedRPC.intercept
+	mockedRPC.init()
+
+	raw := getDefaultCopyRawInput(srcDirName, "https://account.blob.core.windows.net/container?sv=2019-12-12&sp=rwl&sig=fake")
+	raw.recursive = true
+	raw.asSubdir = false
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+	raw.skipContainerCheck = true
+	raw.stripPrefix = stripPrefix
+	raw.destPrefix = destPrefix
+
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.process(), chk.IsNil)
+
+	destinations := make([]string, 0, len(mockedRPC.transfers))
+	for _, transfer := range mockedRPC.transfers {
+		destinations = append(destinations, transfer.Destination)
+	}
+	sort.Strings(destinations)
+	return destinations
+}
+
+func (s *copyPathPrefixSuite) TestStripPrefix(c *chk.C) {
+	destinations := s.uploadDestinations(c, "2023/logs", "")
+	c.Assert(destinations, chk.DeepEquals, []string{"/2023/readme.txt", "/app.txt", "/other.txt", "/sub/db.txt"})
+}
+
+func (s *copyPathPrefixSuite) TestDestPrefix(c *chk.C) {
+	destinations := s.uploadDestinations(c, "", `backups\my files`)
+	c.Assert(destinations, chk.DeepEquals, []string{
+		"/backups/my%20files/2023/logs/app.txt",
+		"/backups/my%20files/2023/logs/sub/db.txt",
+		"/backups/my%20files/2023/readme.txt",
+		"/backups/my%20files/other.txt",
+	})
+}
+
+func (s *copyPathPrefixSuite) TestStripAndDestPrefix(c *chk.C) {
+	destinations := s.uploadDestinations(c, "/2023/", "2024")
+	c.Assert(destinations, chk.DeepEquals, []string{"/2024/logs/app.txt", "/2024/logs/sub/db.txt", "/2024/other.txt", "/2024/readme.txt"})
+}
+
+func (s *copyPathPrefixSuite) TestDestPrefixGoesInsideTheContainer(c *chk.C) {
+	// copying a whole account: the first part of each path is the container that the object goes to
+	cca := CookedCopyCmdArgs{FromTo: common.EFromTo.BlobBlob(), destPrefix: "copied"}
+	object := StoredObject{name: "b.txt", relativePath: "dir/b.txt", entityType: common.EEntityType.File(), DstContainerName: "photos"}
+	c.Assert(cca.destinationRelativePath(true, object), chk.Equals, "/photos/copied/dir/b.txt")
+}
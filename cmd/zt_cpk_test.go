@@ -0,0 +1,227 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	chk "gopkg.in/check.v1"
+)
+
+type cpkSuite struct{}
+
+var _ = chk.Suite(&cpkSuite{})
+
+func newTestCpkKey(seed byte) (key string, keySHA256 string) {
+	k := bytes.Repeat([]byte{seed}, 32)
+	hash := sha256.Sum256(k)
+	return base64.StdEncoding.EncodeToString(k), base64.StdEncoding.EncodeToString(hash[:])
+}
+
+func (s *cpkSuite) TestKeyIsValidated(c *chk.C) {
+	defer common.SetCpkEncryptionKey("", "")
+	key, keySHA256 := newTestCpkKey(1)
+	otherKey, _ := newTestCpkKey(2)
+	raw := getDefaultCopyRawInput("/tmp/src", "https://account.blob.core.windows.net/container?sv=2019-12-12&sig=fake")
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+
+	for _, bad := range []struct{ key, keySHA256, expected string }{
+		{"not base64!", "", "must be a base64 encoded 256-bit (32 byte) key"},
+		{base64.StdEncoding.EncodeToString([]byte("too short")), "", "must be a base64 encoded 256-bit (32 byte) key"},
+		{otherKey, keySHA256, "doesn't match the key"},
+		{"", keySHA256, "can only be used with --cpk-encryption-key"},
+	} {
+		raw.cpkEncryptionKey, raw.cpkEncryptionKeySHA256 = bad.key, bad.keySHA256
+		_, err := raw.cook()
+		c.Assert(err, chk.NotNil)
+		c.Assert(err.Error(), StringContains, bad.expected)
+	}
+
+	// the hash is optional, and a key on the command line implies --cpk-by-value
+	raw.cpkEncryptionKey, raw.cpkEncryptionKeySHA256 = key, ""
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.CpkOptions.CpkInfo, chk.Equals, true)
+	cpkInfo := common.GetCpkInfo(true)
+	c.Assert(*cpkInfo.EncryptionKey, chk.Equals, key)
+	c.Assert(*cpkInfo.EncryptionKeySha256, chk.Equals, keySHA256)
+
+	raw.cpkScopeInfo = "scope"
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "cannot use both cpk-by-name and cpk-by-value")
+}
+
+// cpkBlobService is a fake Blob service for one block blob, which like the real one only returns a blob's content
+// to requests that give the key the blob was written with
+type cpkBlobService struct {
+	mu        sync.Mutex
+	blocks    map[string][]byte
+	content   []byte
+	keySHA256 string
+	writeKeys map[string]bool // the key hashes of all the writes
+}
+
+func (f *cpkBlobService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keySHA256 := r.Header.Get("x-ms-encryption-key-sha256")
+	body, _ := ioutil.ReadAll(r.Body)
+
+	switch {
+	case r.Method == http.MethodPut && r.URL.Query().Get("comp") == "block":
+		f.writeKeys[keySHA256] = true
+		f.blocks[r.URL.Query().Get("blockid")] = body
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && r.URL.Query().Get("comp") == "blocklist":
+		f.writeKeys[keySHA256] = true
+		var blockList struct {
+			Latest []string `xml:"Latest"`
+		}
+		_ = xml.Unmarshal(body, &blockList)
+		f.content = nil
+		for _, id := range blockList.Latest {
+			f.content = append(f.content, f.blocks[id]...)
+		}
+		f.keySHA256 = keySHA256
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodGet:
+		if keySHA256 != f.keySHA256 {
+			w.Header().Set("x-ms-error-code", "BlobUsesCustomerSpecifiedEncryption")
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(f.content)))
+		w.Header().Set("x-ms-blob-type", "BlockBlob")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(f.content)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// download reads the blob to stdout, like "azcopy copy <blob> --from-to=BlobPipe"
+func (s *cpkSuite) TestKeyIsNotUsableWithAnotherEngine(c *chk.C) {
+	defer common.SetCpkEncryptionKey("", "")
+	key, _ := newTestCpkKey(1)
+
+	originalSteURL := cmdLineSteURL
+	cmdLineSteURL = "http://127.0.0.1:9999"
+	_, err := cookCpkByValue(false, key, "")
+	cmdLineSteURL = originalSteURL
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "--cpk-encryption-key cannot be used with --ste-url")
+
+	// nor with the URL of the engine in the environment
+	steURL := common.EEnvironmentVariable.SteURL().Name
+	originalEnv, wasSet := os.LookupEnv(steURL)
+	_ = os.Setenv(steURL, "http://127.0.0.1:9999")
+	_, err = cookCpkByValue(false, key, "")
+	if wasSet {
+		_ = os.Setenv(steURL, originalEnv)
+	} else {
+		_ = os.Unsetenv(steURL)
+	}
+	c.Assert(err, chk.NotNil)
+
+	// the environment variables of the engine still work with --cpk-by-value
+	cpkByValue, err := cookCpkByValue(true, "", "")
+	c.Assert(err, chk.IsNil)
+	c.Assert(cpkByValue, chk.Equals, true)
+}
+
+func (s *cpkSuite) download(c *chk.C, blob common.ResourceString, cpkOptions common.CpkOptions) ([]byte, error) {
+	drain, stdout, err := os.Pipe()
+	c.Assert(err, chk.IsNil)
+	originalStdout := os.Stdout
+	os.Stdout = stdout
+	downloaded := make(chan []byte)
+	go func() {
+		b, _ := ioutil.ReadAll(drain)
+		downloaded <- b
+	}()
+	download := &CookedCopyCmdArgs{Source: blob, FromTo: common.EFromTo.BlobPipe(), CpkOptions: cpkOptions}
+	err = download.processRedirectionDownload(download.Source)
+	os.Stdout = originalStdout
+	_ = stdout.Close()
+	return <-downloaded, err
+}
+
+func (s *cpkSuite) TestBlobCanOnlyBeReadWithItsKey(c *chk.C) {
+	defer common.SetCpkEncryptionKey("", "")
+	service := &cpkBlobService{blocks: map[string][]byte{}, writeKeys: map[string]bool{}}
+	server := httptest.NewServer(service)
+	defer server.Close()
+	blob := common.ResourceString{Value: server.URL + "/account/container/encrypted.bin", SAS: "sv=2019-12-12&sig=fake"}
+
+	key, keySHA256 := newTestCpkKey(1)
+	cpkByValue, err := cookCpkByValue(false, key, keySHA256)
+	c.Assert(err, chk.IsNil)
+	cpkOptions := common.CpkOptions{CpkInfo: cpkByValue}
+
+	// upload, from stdin, in several blocks
+	data := make([]byte, 5*1024+17)
+	rand.Read(data)
+	stdin, feeder, err := os.Pipe()
+	c.Assert(err, chk.IsNil)
+	originalStdin := os.Stdin
+	os.Stdin = stdin
+	go func() {
+		_, _ = feeder.Write(data)
+		_ = feeder.Close()
+	}()
+	upload := &CookedCopyCmdArgs{Destination: blob, FromTo: common.EFromTo.PipeBlob(), blockBlobTier: common.EBlockBlobTier.None(), CpkOptions: cpkOptions}
+	err = upload.processRedirectionUpload(upload.Destination, 1024, os.Stdin)
+	os.Stdin = originalStdin
+	c.Assert(err, chk.IsNil)
+	c.Assert(len(service.blocks) > 1, chk.Equals, true)
+	// every block, and the commit, used the same key
+	c.Assert(service.writeKeys, chk.DeepEquals, map[string]bool{keySHA256: true})
+
+	// it reads back with the same key
+	cpkOptions.IsSourceEncrypted = true
+	downloaded, err := s.download(c, blob, cpkOptions)
+	c.Assert(err, chk.IsNil)
+	c.Assert(bytes.Equal(downloaded, data), chk.Equals, true)
+
+	// but not with another key
+	otherKey, _ := newTestCpkKey(2)
+	_, err = cookCpkByValue(false, otherKey, "")
+	c.Assert(err, chk.IsNil)
+	_, err = s.download(c, blob, cpkOptions)
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "BlobUsesCustomerSpecifiedEncryption")
+
+	// nor with none
+	_, err = s.download(c, blob, common.CpkOptions{})
+	c.Assert(err, chk.NotNil)
+}
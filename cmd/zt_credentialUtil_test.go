@@ -93,3 +93,21 @@ func (s *credentialUtilSuite) TestCheckAuthSafeForTargetIsCalledWhenGettingAuthT
 	c.Assert(strings.Contains(err.Error(), "If this URL is in fact an Azure service, you can enable Azure authentication to notblob.example.com."),
 		chk.Equals, true)
 }
+
+func (s *credentialUtilSuite) TestOAuthTokenUsedOnlyWhenNoSASIsPresent(c *chk.C) {
+	glcm = &mockedLifecycleManager{infoLog: make(chan string, 10)}
+	tokenIsAvailable := func() common.CredentialType {
+		return common.ECredentialType.OAuthToken() // as if the user had logged in, or supplied a token in the environment
+	}
+	const blobURL = "https://account.blob.core.windows.net/container/blob"
+
+	credType, isPublic, err := doGetCredentialTypeForLocation(context.Background(), common.ELocation.Blob(), blobURL, "", false, tokenIsAvailable, common.CpkOptions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(credType, chk.Equals, common.ECredentialType.OAuthToken())
+	c.Assert(isPublic, chk.Equals, false)
+
+	// a SAS authorizes the request by itself, so the token is not sent
+	credType, _, err = doGetCredentialTypeForLocation(context.Background(), common.ELocation.Blob(), blobURL, "sv=2019-12-12&sig=fake", false, tokenIsAvailable, common.CpkOptions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(credType, chk.Equals, common.ECredentialType.Anonymous())
+}
@@ -0,0 +1,63 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	chk "gopkg.in/check.v1"
+)
+
+type exitCodeSuite struct{}
+
+var _ = chk.Suite(&exitCodeSuite{})
+
+func (s *exitCodeSuite) TestJobExitCodes(c *chk.C) {
+	testData := []struct {
+		status    common.JobStatus
+		completed uint32
+		failed    uint32
+		skipped   uint32
+		expected  common.ExitCode
+	}{
+		{common.EJobStatus.Completed(), 10, 0, 0, common.EExitCode.Success()},
+		{common.EJobStatus.CompletedWithSkipped(), 5, 0, 5, common.EExitCode.Success()},
+		{common.EJobStatus.CompletedWithErrors(), 7, 3, 0, common.EExitCode.Error()},
+		{common.EJobStatus.CompletedWithErrorsAndSkipped(), 1, 3, 2, common.EExitCode.Error()},
+		{common.EJobStatus.Failed(), 0, 10, 0, common.EExitCode.AllFailed()},
+		{common.EJobStatus.CompletedWithErrorsAndSkipped(), 0, 3, 2, common.EExitCode.AllFailed()},
+		{common.EJobStatus.Cancelled(), 4, 0, 0, common.EExitCode.Cancelled()},
+		{common.EJobStatus.Cancelling(), 4, 2, 0, common.EExitCode.Cancelled()},
+	}
+
+	for _, d := range testData {
+		summary := common.ListJobSummaryResponse{
+			JobStatus:          d.status,
+			TransfersCompleted: d.completed,
+			TransfersFailed:    d.failed,
+			TransfersSkipped:   d.skipped,
+		}
+		c.Check(jobExitCode(summary, common.EExitCode.Success()), chk.Equals, d.expected, chk.Commentf("%v", d.status))
+	}
+
+	// in a chain of jobs, a clean run keeps whatever the earlier job finished with
+	summary := common.ListJobSummaryResponse{JobStatus: common.EJobStatus.Completed(), TransfersCompleted: 1}
+	c.Check(jobExitCode(summary, common.EExitCode.Error()), chk.Equals, common.EExitCode.Error())
+}
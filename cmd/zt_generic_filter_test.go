@@ -26,6 +26,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Azure/azure-storage-azcopy/v10/common"
 	chk "gopkg.in/check.v1"
 )
 
@@ -182,3 +183,162 @@ func (_ *genericFilterSuite) findAmbiguousTime() (string, time.Time, time.Time,
 
 	return "", time.Time{}, time.Time{}, noAmbiguousHourError
 }
+
+func (s *genericFilterSuite) TestIncludeAndExcludeFiltersTogether(c *chk.C) {
+	raw := rawSyncCmdArgs{}
+	filters := buildIncludeFilters(raw.parsePatterns("*.log;*.txt"))
+	filters = append(filters, buildExcludeFilters(raw.parsePatterns("debug*;*.tmp"), false)...)
+
+	// when a name matches both an include and an exclude pattern, the exclude pattern wins
+	filesToPass := []string{"app.log", "notes.txt"}
+	filesNotToPass := []string{"debug.log", "debug-notes.txt", "scratch.tmp", "image.png", "noextension"}
+
+	for _, file := range filesToPass {
+		dummyProcessor := &dummyProcessor{}
+		err := processIfPassedFilters(filters, StoredObject{name: file}, dummyProcessor.process)
+		c.Assert(err, chk.IsNil)
+		c.Assert(len(dummyProcessor.record), chk.Equals, 1)
+	}
+
+	for _, file := range filesNotToPass {
+		dummyProcessor := &dummyProcessor{}
+		err := processIfPassedFilters(filters, StoredObject{name: file}, dummyProcessor.process)
+		c.Assert(err, chk.Equals, ignoredError)
+		c.Assert(len(dummyProcessor.record), chk.Equals, 0)
+	}
+}
+
+func (s *genericFilterSuite) TestNamePatternsIgnoreCaseOnlyWhenRequired(c *chk.C) {
+	defer func(original bool) { patternsIgnoreCase = original }(patternsIgnoreCase)
+
+	raw := rawSyncCmdArgs{}
+	includeFilter := buildIncludeFilters(raw.parsePatterns("*.LOG"))[0]
+	excludeFilter := buildExcludeFilters(raw.parsePatterns("Temp*"), false)[0]
+
+	patternsIgnoreCase = false
+	c.Assert(includeFilter.DoesPass(StoredObject{name: "app.log"}), chk.Equals, false)
+	c.Assert(excludeFilter.DoesPass(StoredObject{name: "tempfile"}), chk.Equals, true)
+	c.Assert(includeFilter.(*IncludeFilter).getEnumerationPreFilter(), chk.Equals, "")
+
+	patternsIgnoreCase = true
+	c.Assert(includeFilter.DoesPass(StoredObject{name: "app.log"}), chk.Equals, true)
+	c.Assert(excludeFilter.DoesPass(StoredObject{name: "tempfile"}), chk.Equals, false)
+}
+
+func (s *genericFilterSuite) TestSizeFilterBoundaries(c *chk.C) {
+	filters := []ObjectFilter{&minSizeFilter{size: 1024}, &maxSizeFilter{size: 2048}}
+	file := func(size int64) StoredObject {
+		return StoredObject{name: "f", size: size, entityType: common.EEntityType.File()}
+	}
+
+	// both ends of the window are included
+	for size, shouldPass := range map[int64]bool{0: false, 1023: false, 1024: true, 1500: true, 2048: true, 2049: false} {
+		c.Check(passedFilters(filters, file(size)), chk.Equals, shouldPass, chk.Commentf("size %d", size))
+	}
+
+	// folders have no size, so the size filters don't weed them out
+	c.Check(passedFilters(filters, StoredObject{name: "dir", entityType: common.EEntityType.Folder()}), chk.Equals, true)
+}
+
+func (s *genericFilterSuite) TestSizeParsingForFilters(c *chk.C) {
+	for value, expected := range map[string]int64{
+		"0":     0,
+		"100":   100,
+		"10K":   10 * 1024,
+		"10KB":  10 * 1024,
+		"10mb":  10 * 1024 * 1024,
+		"2G":    2 * 1024 * 1024 * 1024,
+		"2GB":   2 * 1024 * 1024 * 1024,
+		"123kB": 123 * 1024,
+	} {
+		size, err := parseFilterSize(value, "min-size")
+		c.Check(err, chk.IsNil, chk.Commentf(value))
+		c.Check(size, chk.Equals, expected, chk.Commentf(value))
+	}
+
+	for _, value := range []string{"", "-1", "-1K", "10T", "10TB", "abc", "KB", "10 MB", "1.5M"} {
+		_, err := parseFilterSize(value, "min-size")
+		c.Check(err, chk.NotNil, chk.Commentf(value))
+	}
+}
+
+func (s *genericFilterSuite) TestAgeParsingForFilters(c *chk.C) {
+	now := time.Date(2021, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	t, err := parseAge("36h", now, true)
+	c.Assert(err, chk.IsNil)
+	c.Assert(t, chk.Equals, now.Add(-36*time.Hour))
+
+	t, err = parseAge("7d", now, true)
+	c.Assert(err, chk.IsNil)
+	c.Assert(t, chk.Equals, now.AddDate(0, 0, -7))
+
+	t, err = parseAge("1.5d", now, true)
+	c.Assert(err, chk.IsNil)
+	c.Assert(t, chk.Equals, now.Add(-36*time.Hour))
+
+	t, err = parseAge("2020-08-19T15:04:00Z", now, true)
+	c.Assert(err, chk.IsNil)
+	c.Assert(t.Equal(time.Date(2020, 8, 19, 15, 4, 0, 0, time.UTC)), chk.Equals, true)
+
+	for _, value := range []string{"", "-5h", "-2d", "yesterday", "7 days"} {
+		_, err = parseAge(value, now, true)
+		c.Check(err, chk.NotNil, chk.Commentf(value))
+	}
+}
+
+func (s *genericFilterSuite) TestSizeAgeAndNameFiltersTogether(c *chk.C) {
+	raw := getDefaultCopyRawInput("/tmp/source", "https://account.blob.core.windows.net/container")
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+	raw.recursive = true
+	raw.include = "*.bak"
+	raw.minSize = "1MB"
+	raw.newerThan = "7d"
+	raw.includeAfter = "2000-01-01T00:00:00Z" // a looser window than newer-than, so newer-than decides
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.IncludeAfter.After(time.Now().AddDate(0, 0, -8)), chk.Equals, true)
+	filters := cooked.InitModularFilters()
+
+	recent := time.Now().Add(-time.Hour)
+	old := time.Now().AddDate(0, 0, -30)
+	file := func(name string, size int64, lmt time.Time) StoredObject {
+		return StoredObject{name: name, size: size, lastModifiedTime: lmt, entityType: common.EEntityType.File()}
+	}
+
+	// a file has to pass all the filters
+	c.Check(passedFilters(filters, file("db.bak", 5*1024*1024, recent)), chk.Equals, true)
+	c.Check(passedFilters(filters, file("db.bak", 1024*1024, recent)), chk.Equals, true)
+	c.Check(passedFilters(filters, file("db.bak", 1024*1024-1, recent)), chk.Equals, false) // too small
+	c.Check(passedFilters(filters, file("db.bak", 5*1024*1024, old)), chk.Equals, false)    // too old
+	c.Check(passedFilters(filters, file("db.log", 5*1024*1024, recent)), chk.Equals, false) // wrong name
+}
+
+func (s *genericFilterSuite) TestSizeAndAgeFlagValidation(c *chk.C) {
+	raw := getDefaultCopyRawInput("/tmp/source", "https://account.blob.core.windows.net/container")
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+	raw.minSize = "2M"
+	raw.maxSize = "1M"
+	_, err := raw.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), chk.Equals, "min-size cannot be larger than max-size")
+
+	// a window of a single size is fine
+	raw.maxSize = "2MB"
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(*cooked.MinSize, chk.Equals, *cooked.MaxSize)
+
+	raw.maxSize = ""
+	raw.olderThan = "a while"
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "invalid older-than")
+
+	// older-than narrows include-before, whichever is the earlier
+	raw.olderThan = "2020-01-01"
+	raw.includeBefore = "2021-01-01"
+	cooked, err = raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.IncludeBefore.Year(), chk.Equals, 2020)
+}
@@ -22,6 +22,7 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"io"
 	"io/ioutil"
 	"os"
@@ -245,6 +246,25 @@ func (s *genericTraverserSuite) TestGCPGetProperties(c *chk.C) {
 }
 
 // Test follow symlink functionality
+// only the local traverser stops at the processor's errors; the others carry on past them, as they always have
+func (s *genericTraverserSuite) TestProcessingErrors(c *chk.C) {
+	processorErr := errors.New("processor failed")
+
+	ignored, err := getProcessingError(ignoredError)
+	c.Assert(ignored, chk.Equals, true)
+	c.Assert(err, chk.IsNil)
+	ignored, err = getProcessingError(processorErr)
+	c.Assert(ignored, chk.Equals, false)
+	c.Assert(err, chk.IsNil)
+
+	ignored, err = getProcessingErrorToStop(ignoredError)
+	c.Assert(ignored, chk.Equals, true)
+	c.Assert(err, chk.IsNil)
+	ignored, err = getProcessingErrorToStop(processorErr)
+	c.Assert(ignored, chk.Equals, false)
+	c.Assert(err, chk.Equals, processorErr)
+}
+
 func (s *genericTraverserSuite) TestWalkWithSymlinks_ToFolder(c *chk.C) {
 	fileNames := []string{"March 20th is international happiness day.txt", "wonderwall but it goes on and on and on.mp3", "bonzi buddy.exe"}
 	tmpDir := scenarioHelper{}.generateLocalDirectory(c)
@@ -283,8 +303,6 @@ func (s *genericTraverserSuite) TestWalkWithSymlinks_ToFolder(c *chk.C) {
 	c.Assert(sawLinkTargetDir, chk.Equals, true)
 }
 
-// Next test is temporarily disabled, to avoid changing functionality near 10.4 release date
-/*
 // symlinks are not just to folders. They may be to individual files
 func (s *genericTraverserSuite) TestWalkWithSymlinks_ToFile(c *chk.C) {
 	mainDirFilenames := []string{"iAmANormalFile.txt"}
@@ -317,7 +335,30 @@ func (s *genericTraverserSuite) TestWalkWithSymlinks_ToFile(c *chk.C) {
 	// processing them both. For efficiency of dedupe algorithm, we only dedupe directories, not files).
 	c.Assert(fileCount, chk.Equals, 3)
 }
-*/
+
+// when not following symlinks, neither links to files nor links to folders are enumerated
+func (s *genericTraverserSuite) TestWalkWithSymlinks_NotFollowed(c *chk.C) {
+	tmpDir := scenarioHelper{}.generateLocalDirectory(c)
+	defer os.RemoveAll(tmpDir)
+	symlinkTmpDir := scenarioHelper{}.generateLocalDirectory(c)
+	defer os.RemoveAll(symlinkTmpDir)
+	scenarioHelper{}.generateLocalFilesFromList(c, tmpDir, []string{"iAmANormalFile.txt"})
+	scenarioHelper{}.generateLocalFilesFromList(c, symlinkTmpDir, []string{"iAmASymlinkTargetFile.txt"})
+	trySymlink(filepath.Join(symlinkTmpDir, "iAmASymlinkTargetFile.txt"), filepath.Join(tmpDir, "iPointToAFile"), c)
+	trySymlink(symlinkTmpDir, filepath.Join(tmpDir, "iPointToAFolder"), c)
+
+	seen := make([]string, 0)
+	c.Assert(WalkWithSymlinks(tmpDir, func(path string, fi os.FileInfo, err error) error {
+		c.Assert(err, chk.IsNil)
+		if !fi.IsDir() {
+			seen = append(seen, fi.Name())
+		}
+		return nil
+	},
+		false), chk.IsNil)
+
+	c.Assert(seen, chk.DeepEquals, []string{"iAmANormalFile.txt"})
+}
 
 // Test cancel symlink loop functionality
 func (s *genericTraverserSuite) TestWalkWithSymlinksBreakLoop(c *chk.C) {
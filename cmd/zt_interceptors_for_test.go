@@ -105,8 +105,12 @@ func (*mockedLifecycleManager) Prompt(message string, details common.PromptDetai
 	return common.EResponseOption.Default()
 }
 func (m *mockedLifecycleManager) Exit(o common.OutputBuilder, e common.ExitCode) {
+	msg := ""
+	if o != nil {
+		msg = o(common.EOutputFormat.Text())
+	}
 	select {
-	case m.exitLog <- o(common.EOutputFormat.Text()):
+	case m.exitLog <- msg:
 	default:
 	}
 }
@@ -116,6 +120,10 @@ func (m *mockedLifecycleManager) Error(msg string) {
 	default:
 	}
 }
+func (*mockedLifecycleManager) SetQuiet(bool) {}
+func (m *mockedLifecycleManager) ExitWithError(msg string, _ common.ExitCode) {
+	m.Error(msg)
+}
 func (*mockedLifecycleManager) SurrenderControl()                               {}
 func (*mockedLifecycleManager) RegisterCloseFunc(func())                        {}
 func (mockedLifecycleManager) AllowReinitiateProgressReporting()                {}
@@ -133,6 +141,10 @@ func (*mockedLifecycleManager) GetEnvironmentVariable(env common.EnvironmentVari
 func (m *mockedLifecycleManager) SetOutputFormat(format common.OutputFormat) {
 	m.outputFormat = format
 }
+func (*mockedLifecycleManager) SetLogFormat(common.LogFormat) {}
+func (*mockedLifecycleManager) GetLogFormat() common.LogFormat {
+	return common.ELogFormat.Text()
+}
 func (*mockedLifecycleManager) EnableInputWatcher()    {}
 func (*mockedLifecycleManager) EnableCancelFromStdIn() {}
 func (*mockedLifecycleManager) AddUserAgentPrefix(userAgent string) string {
@@ -0,0 +1,171 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	chk "gopkg.in/check.v1"
+)
+
+type pipingUploadSuite struct{}
+
+var _ = chk.Suite(&pipingUploadSuite{})
+
+// stagedBlocks is a fake destination for stageStreamInBlocks, that keeps what was staged
+type stagedBlocks struct {
+	mu     sync.Mutex
+	blocks map[string][]byte
+}
+
+func (s *stagedBlocks) stage(ctx context.Context, blockID string, block []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blocks[blockID] = append([]byte(nil), block...)
+	return nil
+}
+
+// roomyLimiter has room for all the blocks the tests stage
+func roomyLimiter() common.CacheLimiter {
+	return common.NewCacheLimiter(1024 * 1024 * 1024)
+}
+
+func (s *pipingUploadSuite) TestPipingBlockSizeSchedule(c *chk.C) {
+	c.Assert(pipingBlockSize(0, 8), chk.Equals, int64(8))
+	c.Assert(pipingBlockSize(24999, 8), chk.Equals, int64(8))
+	c.Assert(pipingBlockSize(25000, 8), chk.Equals, int64(16))
+	c.Assert(pipingBlockSize(37499, 8), chk.Equals, int64(16))
+	c.Assert(pipingBlockSize(37500, 8), chk.Equals, int64(32))
+	c.Assert(pipingBlockSize(common.MaxNumberOfBlocksPerBlob-1, 8), chk.Equals, int64(8<<15))
+
+	// the size never shrinks as the upload goes on
+	for i := int32(1); i < common.MaxNumberOfBlocksPerBlob; i++ {
+		c.Assert(pipingBlockSize(i, 8) >= pipingBlockSize(i-1, 8), chk.Equals, true)
+	}
+
+	// and never goes over the maximum
+	c.Assert(pipingBlockSize(common.MaxNumberOfBlocksPerBlob-1, pipingDefaultBlockSize), chk.Equals, int64(common.MaxBlockBlobBlockSize))
+}
+
+func (s *pipingUploadSuite) TestLongStreamFitsByGrowingBlocks(c *chk.C) {
+	mockedLcm := mockedLifecycleManager{infoLog: make(chan string, 50)}
+	glcm = &mockedLcm
+
+	// more than the maximum number of blocks of the initial size
+	data := make([]byte, common.MaxNumberOfBlocksPerBlob+12345)
+	rand.Read(data)
+
+	dst := &stagedBlocks{blocks: map[string][]byte{}}
+	blockIDs, err := stageStreamInBlocks(context.Background(), bytes.NewReader(data), 1, pipingUploadParallelism, roomyLimiter(), dst.stage)
+	c.Assert(err, chk.IsNil)
+	c.Assert(len(blockIDs) <= common.MaxNumberOfBlocksPerBlob, chk.Equals, true)
+	c.Assert(len(dst.blocks), chk.Equals, len(blockIDs))
+
+	// committing the blocks in order gives back the data
+	committed := make([]byte, 0, len(data))
+	for i, id := range blockIDs {
+		block := dst.blocks[id]
+		if i < len(blockIDs)-1 {
+			c.Assert(int64(len(block)), chk.Equals, pipingBlockSize(int32(i), 1))
+		}
+		committed = append(committed, block...)
+	}
+	c.Assert(bytes.Equal(committed, data), chk.Equals, true)
+	c.Assert(<-mockedLcm.infoLog, StringContains, "Increasing the block size from 1 to 2 bytes at block 25000")
+}
+
+func (s *pipingUploadSuite) TestEmptyAndShortStreams(c *chk.C) {
+	dst := &stagedBlocks{blocks: map[string][]byte{}}
+	blockIDs, err := stageStreamInBlocks(context.Background(), bytes.NewReader(nil), 8, 2, roomyLimiter(), dst.stage)
+	c.Assert(err, chk.IsNil)
+	c.Assert(blockIDs, chk.HasLen, 0)
+
+	blockIDs, err = stageStreamInBlocks(context.Background(), bytes.NewReader([]byte("0123456789")), 8, 2, roomyLimiter(), dst.stage)
+	c.Assert(err, chk.IsNil)
+	c.Assert(blockIDs, chk.HasLen, 2)
+	c.Assert(string(dst.blocks[blockIDs[0]]), chk.Equals, "01234567")
+	c.Assert(string(dst.blocks[blockIDs[1]]), chk.Equals, "89")
+}
+
+func (s *pipingUploadSuite) TestStagingFailureStopsUpload(c *chk.C) {
+	var mu sync.Mutex
+	staged := 0
+	stageErr := errors.New("staging failed")
+	_, err := stageStreamInBlocks(context.Background(), bytes.NewReader(make([]byte, 1000)), 1, 2, roomyLimiter(),
+		func(ctx context.Context, blockID string, block []byte) error {
+			mu.Lock()
+			defer mu.Unlock()
+			staged++
+			if staged == 10 {
+				return stageErr
+			}
+			return nil
+		})
+	c.Assert(err, chk.Equals, stageErr)
+	c.Assert(staged < 1000, chk.Equals, true)
+}
+
+func (s *pipingUploadSuite) TestBlocksAreReservedFromTheLimiter(c *chk.C) {
+	// room for three blocks under the strict limit, though five could be staged at once
+	limiter := common.NewCacheLimiter(400)
+	var mu sync.Mutex
+	inUse, mostInUse := int64(0), int64(0)
+	stage := func(ctx context.Context, blockID string, block []byte) error {
+		mu.Lock()
+		inUse += 100
+		if inUse > mostInUse {
+			mostInUse = inUse
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		inUse -= 100
+		mu.Unlock()
+		return nil
+	}
+
+	blockIDs, err := stageStreamInBlocks(context.Background(), bytes.NewReader(make([]byte, 500)), 100, 5, limiter, stage)
+	c.Assert(err, chk.IsNil)
+	c.Assert(blockIDs, chk.HasLen, 5)
+	c.Assert(mostInUse <= 300, chk.Equals, true)
+
+	// everything reserved has been given back
+	c.Assert(limiter.TryAdd(limiter.StrictLimit(), false), chk.Equals, true)
+}
+
+func (s *pipingUploadSuite) TestBlockBiggerThanTheMemoryLimit(c *chk.C) {
+	dst := &stagedBlocks{blocks: map[string][]byte{}}
+	_, err := stageStreamInBlocks(context.Background(), bytes.NewReader(make([]byte, 1000)), 100, 2, common.NewCacheLimiter(99), dst.stage)
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, common.EEnvironmentVariable.BufferGB().Name)
+	c.Assert(dst.blocks, chk.HasLen, 0)
+
+	// a block that only fits under the relaxed limit can still be staged
+	blockIDs, err := stageStreamInBlocks(context.Background(), bytes.NewReader(make([]byte, 200)), 100, 2, common.NewCacheLimiter(120), dst.stage)
+	c.Assert(err, chk.IsNil)
+	c.Assert(blockIDs, chk.HasLen, 2)
+}
@@ -0,0 +1,174 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	chk "gopkg.in/check.v1"
+)
+
+type sasTokenFileSuite struct{}
+
+var _ = chk.Suite(&sasTokenFileSuite{})
+
+const testSASToken = "sv=2019-12-12&ss=b&srt=co&sp=rwdlac&se=2030-01-01T00:00:00Z&sig=c2VjcmV0U2lnbmF0dXJl"
+
+func (s *sasTokenFileSuite) writeSASFile(c *chk.C, dir, content string) string {
+	path := filepath.Join(dir, "sas.txt")
+	c.Assert(ioutil.WriteFile(path, []byte(content), 0600), chk.IsNil)
+	return path
+}
+
+func (s *sasTokenFileSuite) withEnv(env common.EnvironmentVariable, value string) func() {
+	original, wasSet := os.LookupEnv(env.Name)
+	_ = os.Setenv(env.Name, value)
+	return func() {
+		if wasSet {
+			_ = os.Setenv(env.Name, original)
+		} else {
+			_ = os.Unsetenv(env.Name)
+		}
+	}
+}
+
+// withSASTokenEnv sets AZCOPY_SAS_TOKEN to value, as the token of fakeaccount
+func (s *sasTokenFileSuite) withSASTokenEnv(value string) func() {
+	restoreToken := s.withEnv(common.EEnvironmentVariable.SASToken(), value)
+	restoreAccount := s.withEnv(common.EEnvironmentVariable.SASTokenAccount(), "fakeaccount")
+	return func() {
+		restoreAccount()
+		restoreToken()
+	}
+}
+
+func (s *sasTokenFileSuite) TestCopyDestinationSASFromFile(c *chk.C) {
+	defer s.withSASTokenEnv("")()
+	srcDirName := scenarioHelper{}.generateLocalDirectory(c)
+	defer os.RemoveAll(srcDirName)
+
+	raw := getDefaultCopyRawInput(srcDirName, "https://fakeaccount.blob.core.windows.net/container")
+	raw.recursive = true
+	raw.sasTokenFile = s.writeSASFile(c, srcDirName, "?"+testSASToken+"\n")
+
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.Destination.SAS, chk.Equals, testSASToken)
+
+	fullURL, err := cooked.Destination.FullURL()
+	c.Assert(err, chk.IsNil)
+	c.Assert(fullURL.Query().Get("sig"), chk.Equals, "c2VjcmV0U2lnbmF0dXJl")
+
+	// wherever the URL is logged, the signature is redacted
+	logged := common.URLExtension{URL: *fullURL}.RedactSecretQueryParamForLogging()
+	c.Assert(logged, chk.Not(StringContains), "c2VjcmV0U2lnbmF0dXJl")
+	c.Assert(logged, StringContains, "sig=REDACTED")
+	sanitized := common.NewAzCopyLogSanitizer().SanitizeLogMessage("PUT " + fullURL.String())
+	c.Assert(sanitized, chk.Not(StringContains), "c2VjcmV0U2lnbmF0dXJl")
+}
+
+func (s *sasTokenFileSuite) TestSyncDestinationSASFromEnvironment(c *chk.C) {
+	defer s.withSASTokenEnv(testSASToken)()
+	srcDirName := scenarioHelper{}.generateLocalDirectory(c)
+	defer os.RemoveAll(srcDirName)
+
+	raw := getDefaultSyncRawInput(srcDirName, "https://fakeaccount.blob.core.windows.net/container")
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.destination.SAS, chk.Equals, testSASToken)
+
+	// a SAS in the URL takes precedence over the environment variable
+	raw = getDefaultSyncRawInput(srcDirName, "https://fakeaccount.blob.core.windows.net/container?sv=2019-12-12&sig=fromURL")
+	cooked, err = raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.destination.SAS, StringContains, "sig=fromURL")
+
+	// the file takes precedence over the environment variable
+	raw = getDefaultSyncRawInput(srcDirName, "https://fakeaccount.blob.core.windows.net/container")
+	raw.sasTokenFile = s.writeSASFile(c, srcDirName, "sv=2019-12-12&sig=fromFile")
+	cooked, err = raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.destination.SAS, chk.Equals, "sv=2019-12-12&sig=fromFile")
+}
+
+func (s *sasTokenFileSuite) TestSASTokenFileErrors(c *chk.C) {
+	defer s.withSASTokenEnv("")()
+	srcDirName := scenarioHelper{}.generateLocalDirectory(c)
+	defer os.RemoveAll(srcDirName)
+
+	raw := getDefaultCopyRawInput(srcDirName, "https://fakeaccount.blob.core.windows.net/container?sv=2019-12-12&sig=fromURL")
+	raw.recursive = true
+	raw.sasTokenFile = s.writeSASFile(c, srcDirName, testSASToken)
+	_, err := raw.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "already has a SAS token")
+
+	raw = getDefaultCopyRawInput(srcDirName, "https://fakeaccount.blob.core.windows.net/container")
+	raw.recursive = true
+	raw.sasTokenFile = s.writeSASFile(c, srcDirName, "  \n")
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "empty")
+
+	raw.sasTokenFile = filepath.Join(srcDirName, "missing.txt")
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "cannot read the SAS token file")
+
+	// downloads have no destination SAS to give
+	raw = getDefaultCopyRawInput("https://fakeaccount.blob.core.windows.net/container/blob?sv=2019-12-12&sig=fromURL", srcDirName)
+	raw.sasTokenFile = s.writeSASFile(c, srcDirName, testSASToken)
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "--sas-token-file can only be used")
+}
+
+func (s *sasTokenFileSuite) TestSASTokenFromEnvironmentIsOnlyForItsAccount(c *chk.C) {
+	defer s.withSASTokenEnv(testSASToken)()
+	srcDirName := scenarioHelper{}.generateLocalDirectory(c)
+	defer os.RemoveAll(srcDirName)
+
+	// another account's destination doesn't get the token
+	raw := getDefaultSyncRawInput(srcDirName, "https://otheraccount.blob.core.windows.net/container")
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.destination.SAS, chk.Equals, "")
+
+	raw = getDefaultSyncRawInput(srcDirName, "https://FakeAccount.blob.core.windows.net/container")
+	cooked, err = raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.destination.SAS, chk.Equals, testSASToken)
+
+	// without the name of its account, the token isn't used at all
+	defer s.withEnv(common.EEnvironmentVariable.SASTokenAccount(), "")()
+	raw = getDefaultSyncRawInput(srcDirName, "https://fakeaccount.blob.core.windows.net/container")
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "AZCOPY_SAS_TOKEN_ACCOUNT must be set")
+
+	// nor is it needed for a destination that takes no SAS
+	raw = getDefaultSyncRawInput("https://fakeaccount.blob.core.windows.net/container?sv=2019-12-12&sig=fromURL", srcDirName)
+	_, err = raw.cook()
+	c.Assert(err, chk.IsNil)
+}
@@ -0,0 +1,82 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	chk "gopkg.in/check.v1"
+)
+
+type sasValiditySuite struct{}
+
+var _ = chk.Suite(&sasValiditySuite{})
+
+var sasValidityTestNow = time.Date(2021, 6, 15, 12, 0, 0, 0, time.UTC)
+
+func (s *sasValiditySuite) TestExpiredSAS(c *chk.C) {
+	sas := "sv=2019-12-12&ss=b&srt=co&sp=rwdlac&st=2021-06-01T00:00:00Z&se=2021-06-15T11:59:00Z&sig=c2lnbmF0dXJl"
+	msg := sasValidityWarning(sas, "destination", sasValidityTestNow)
+	c.Assert(msg, StringContains, "The SAS token for the destination expired at 2021-06-15T11:59:00Z")
+	c.Assert(msg, StringContains, "it is now 2021-06-15T12:00:00Z")
+
+	// a token stops being valid at its expiry time
+	sas = "sv=2019-12-12&se=2021-06-15T12:00:00Z&sig=c2lnbmF0dXJl"
+	c.Assert(sasValidityWarning(sas, "source", sasValidityTestNow), StringContains, "The SAS token for the source expired")
+}
+
+func (s *sasValiditySuite) TestNotYetValidSAS(c *chk.C) {
+	// e.g. a token made on a machine whose clock is ahead of this one's
+	sas := "sv=2019-12-12&sp=r&st=2021-06-15T12:05:00Z&se=2021-06-16T12:00:00Z&sig=c2lnbmF0dXJl"
+	msg := sasValidityWarning(sas, "source", sasValidityTestNow)
+	c.Assert(msg, StringContains, "The SAS token for the source is not valid until 2021-06-15T12:05:00Z")
+	c.Assert(msg, StringContains, "this machine's clock may be behind")
+
+	// date-only times are accepted too
+	sas = "sv=2019-12-12&sp=r&st=2021-06-16&se=2021-06-20&sig=c2lnbmF0dXJl"
+	c.Assert(sasValidityWarning(sas, "source", sasValidityTestNow), StringContains, "is not valid until 2021-06-16T00:00:00Z")
+}
+
+func (s *sasValiditySuite) TestValidSAS(c *chk.C) {
+	for _, sas := range []string{
+		"sv=2019-12-12&sp=r&st=2021-06-15T12:00:00Z&se=2021-06-15T13:00:00Z&sig=c2lnbmF0dXJl", // starts right now
+		"sv=2019-12-12&sp=r&se=2021-06-15T12:00:01.0000000Z&sig=c2lnbmF0dXJl",
+		"sv=2019-12-12&si=storedPolicy&sig=c2lnbmF0dXJl", // the times are in a stored access policy, which we can't see
+		"",
+	} {
+		c.Check(sasValidityWarning(sas, "destination", sasValidityTestNow), chk.Equals, "", chk.Commentf(sas))
+	}
+}
+
+func (s *sasValiditySuite) TestWarningIsPrintedBeforeTransfersAreScheduled(c *chk.C) {
+	mockedLcm := mockedLifecycleManager{infoLog: make(chan string, 10)}
+	glcmBefore := glcm
+	glcm = &mockedLcm
+	defer func() { glcm = glcmBefore }()
+
+	expired := common.ResourceString{Value: "https://account.blob.core.windows.net/container", SAS: "sv=2019-12-12&se=2000-01-01T00:00:00Z&sig=c2lnbmF0dXJl"}
+	valid := common.ResourceString{Value: "https://other.blob.core.windows.net/container", SAS: "sv=2019-12-12&se=2999-01-01T00:00:00Z&sig=c2lnbmF0dXJl"}
+	warnIfSASIsNotValidNow(valid, expired)
+
+	c.Assert(mockedLcm.infoLog, chk.HasLen, 1)
+	c.Assert(<-mockedLcm.infoLog, StringContains, "The SAS token for the destination expired at 2000-01-01T00:00:00Z")
+}
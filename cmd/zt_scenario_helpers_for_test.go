@@ -866,6 +866,8 @@ func getDefaultSyncRawInput(src, dst string) rawSyncCmdArgs {
 		logVerbosity:        defaultLogVerbosityForSync,
 		deleteDestination:   deleteDestination.String(),
 		md5ValidationOption: common.DefaultHashValidationOption.String(),
+		compareHash:         common.ESyncHashType.None().String(),
+		yes:                 true,
 	}
 }
 
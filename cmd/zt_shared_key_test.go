@@ -0,0 +1,165 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	chk "gopkg.in/check.v1"
+)
+
+type sharedKeySuite struct{}
+
+var _ = chk.Suite(&sharedKeySuite{})
+
+var testAccountKey = base64.StdEncoding.EncodeToString([]byte("key"))
+
+func (s *sharedKeySuite) setEnv(env common.EnvironmentVariable, value string) func() {
+	original, wasSet := os.LookupEnv(env.Name)
+	if value == "" {
+		_ = os.Unsetenv(env.Name)
+	} else {
+		_ = os.Setenv(env.Name, value)
+	}
+	return func() {
+		if wasSet {
+			_ = os.Setenv(env.Name, original)
+		} else {
+			_ = os.Unsetenv(env.Name)
+		}
+	}
+}
+
+func (s *sharedKeySuite) TestAccountKeyChoosesSharedKeyForEveryService(c *chk.C) {
+	defer s.setEnv(common.EEnvironmentVariable.StorageAccountName(), "myaccount")()
+	defer s.setEnv(common.EEnvironmentVariable.StorageAccountKey(), testAccountKey)()
+	defer s.setEnv(common.EEnvironmentVariable.ConnectionString(), "")()
+	ctx := context.Background()
+
+	testCases := []struct {
+		location common.Location
+		resource string
+		account  string
+	}{
+		{common.ELocation.Blob(), "https://myaccount.blob.core.windows.net/container/blob", "myaccount"},
+		{common.ELocation.File(), "https://myaccount.file.core.windows.net/share/dir/file", "myaccount"},
+		{common.ELocation.BlobFS(), "https://myaccount.dfs.core.windows.net/filesystem/file", "myaccount"},
+	}
+
+	for _, tc := range testCases {
+		credInfo, isPublic, err := GetCredentialInfoForLocation(ctx, tc.location, tc.resource, "", false, common.CpkOptions{})
+		c.Assert(err, chk.IsNil)
+		c.Assert(isPublic, chk.Equals, false)
+		c.Assert(credInfo.CredentialType, chk.Equals, common.ECredentialType.SharedKey())
+		c.Assert(credInfo.SharedKeyAccountName, chk.Equals, tc.account)
+
+		// a SAS still takes precedence
+		credInfo, _, err = GetCredentialInfoForLocation(ctx, tc.location, tc.resource, "sv=2019-12-12&sig=x", false, common.CpkOptions{})
+		c.Assert(err, chk.IsNil)
+		c.Assert(credInfo.CredentialType, chk.Equals, common.ECredentialType.Anonymous())
+	}
+
+	// IP-style urls name the account in their path
+	account, err := sharedKeyAccountName("http://127.0.0.1:10000/devstoreaccount1/container", common.ELocation.Blob())
+	c.Assert(err, chk.IsNil)
+	c.Assert(account, chk.Equals, "devstoreaccount1")
+}
+
+func (s *sharedKeySuite) TestAccountKeyIsOnlyForItsAccount(c *chk.C) {
+	defer s.setEnv(common.EEnvironmentVariable.StorageAccountName(), "myaccount")()
+	defer s.setEnv(common.EEnvironmentVariable.StorageAccountKey(), testAccountKey)()
+	defer s.setEnv(common.EEnvironmentVariable.ConnectionString(), "")()
+
+	// another account's url gets no shared key, so a source in one account can't be signed with the destination's key
+	credType, err := getAzureFileCredentialType("https://otheraccount.file.core.windows.net/share/file")
+	c.Assert(err, chk.IsNil)
+	c.Assert(credType, chk.Equals, common.ECredentialType.Anonymous())
+
+	useSharedKey, err := sharedKeyGivenFor("https://otheraccount.blob.core.windows.net/container/blob", common.ELocation.Blob())
+	c.Assert(err, chk.IsNil)
+	c.Assert(useSharedKey, chk.Equals, false)
+	_, err = common.GetSharedKey("otheraccount")
+	c.Assert(err, chk.NotNil)
+
+	key, err := common.GetSharedKey("myaccount")
+	c.Assert(err, chk.IsNil)
+	c.Assert(key, chk.Equals, testAccountKey)
+}
+
+func (s *sharedKeySuite) TestAccountKeyNeedsAccountName(c *chk.C) {
+	defer s.setEnv(common.EEnvironmentVariable.StorageAccountName(), "")()
+	defer s.setEnv(common.EEnvironmentVariable.StorageAccountKey(), testAccountKey)()
+	defer s.setEnv(common.EEnvironmentVariable.ConnectionString(), "")()
+
+	_, err := sharedKeyGivenFor("https://myaccount.blob.core.windows.net/container/blob", common.ELocation.Blob())
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "AZCOPY_ACCOUNT_NAME must be set")
+}
+
+func (s *sharedKeySuite) TestFileIsAnonymousWithoutAccountKey(c *chk.C) {
+	defer s.setEnv(common.EEnvironmentVariable.StorageAccountKey(), "")()
+	credType, err := getAzureFileCredentialType("https://myaccount.file.core.windows.net/share/file")
+	c.Assert(err, chk.IsNil)
+	c.Assert(credType, chk.Equals, common.ECredentialType.Anonymous())
+}
+
+func (s *sharedKeySuite) authorizationSentBy(c *chk.C, credInfo common.CredentialInfo) string {
+	authorization := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case authorization <- r.Header.Get("Authorization"):
+		default:
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	p, err := createBlobPipeline(context.Background(), credInfo, pipeline.LogNone)
+	c.Assert(err, chk.IsNil)
+	u, err := url.Parse(server.URL + "/myaccount/container")
+	c.Assert(err, chk.IsNil)
+	_, err = azblob.NewContainerURL(*u, p).Create(context.Background(), nil, azblob.PublicAccessNone)
+	c.Assert(err, chk.IsNil)
+	return <-authorization
+}
+
+func (s *sharedKeySuite) TestRequestsAreSignedWithSharedKey(c *chk.C) {
+	defer s.setEnv(common.EEnvironmentVariable.StorageAccountName(), "myaccount")()
+	defer s.setEnv(common.EEnvironmentVariable.StorageAccountKey(), testAccountKey)()
+	defer s.setEnv(common.EEnvironmentVariable.ConnectionString(), "")()
+
+	credInfo := common.CredentialInfo{CredentialType: common.ECredentialType.SharedKey(), SharedKeyAccountName: "myaccount"}
+	authorization := s.authorizationSentBy(c, credInfo)
+	// the signature is a base64 encoded HMAC-SHA256, i.e. 32 bytes
+	c.Assert(authorization, chk.Matches, "SharedKey myaccount:[A-Za-z0-9+/]{43}=")
+
+	// without a key, nothing is signed
+	authorization = s.authorizationSentBy(c, common.CredentialInfo{CredentialType: common.ECredentialType.Anonymous()})
+	c.Assert(authorization, chk.Equals, "")
+}
@@ -37,3 +37,25 @@ func (s *cmdIntegrationSuite) TestSyncS2SWithDFS(c *chk.C) {
 	c.Assert(err, chk.IsNil)
 	c.Assert(cooked.fromTo, chk.Equals, common.EFromTo.BlobBlob())
 }
+
+func (s *cmdIntegrationSuite) TestSyncCompareHashValidation(c *chk.C) {
+	c.Assert(validateCompareHash(common.ESyncHashType.None(), common.EFromTo.BlobLocal(), true), chk.IsNil)
+	c.Assert(validateCompareHash(common.ESyncHashType.MD5(), common.EFromTo.LocalBlob(), false), chk.IsNil)
+
+	// the local files are hashed as the blobs are listed, so other directions aren't supported
+	c.Assert(validateCompareHash(common.ESyncHashType.MD5(), common.EFromTo.BlobLocal(), false), chk.NotNil)
+	c.Assert(validateCompareHash(common.ESyncHashType.MD5(), common.EFromTo.LocalFile(), false), chk.NotNil)
+
+	// mirror mode doesn't compare at all
+	c.Assert(validateCompareHash(common.ESyncHashType.MD5(), common.EFromTo.LocalBlob(), true), chk.NotNil)
+}
+
+func (s *cmdIntegrationSuite) TestSyncCompareHashImpliesPutMd5(c *chk.C) {
+	raw := getDefaultSyncRawInput(c.MkDir(), "https://myaccount.blob.core.windows.net/container")
+	raw.compareHash = "md5"
+
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.compareHash, chk.Equals, common.ESyncHashType.MD5())
+	c.Assert(cooked.putMd5, chk.Equals, true)
+}
@@ -21,8 +21,13 @@
 package cmd
 
 import (
-	chk "gopkg.in/check.v1"
+	"crypto/md5"
+	"io/ioutil"
+	"path/filepath"
 	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	chk "gopkg.in/check.v1"
 )
 
 type syncComparatorSuite struct{}
@@ -137,7 +142,7 @@ func (s *syncComparatorSuite) TestSyncDestinationComparator(c *chk.C) {
 
 	// set up the indexer as well as the destination comparator
 	indexer := newObjectIndexer()
-	destinationComparator := newSyncDestinationComparator(indexer, dummyCopyScheduler.process, dummyCleaner.process, false)
+	destinationComparator := newSyncDestinationComparator(indexer, dummyCopyScheduler.process, dummyCleaner.process, false, nil)
 
 	// create a sample source object
 	sampleSourceObject := StoredObject{name: "test", relativePath: "/usr/test", lastModifiedTime: time.Now(), md5: srcMD5}
@@ -194,7 +199,7 @@ func (s *syncComparatorSuite) TestSyncDestCompDisableComparison(c *chk.C) {
 
 	// set up the indexer as well as the destination comparator
 	indexer := newObjectIndexer()
-	destinationComparator := newSyncDestinationComparator(indexer, dummyCopyScheduler.process, dummyCleaner.process, true)
+	destinationComparator := newSyncDestinationComparator(indexer, dummyCopyScheduler.process, dummyCleaner.process, true, nil)
 
 	// create a sample source object
 	currTime := time.Now()
@@ -237,3 +242,71 @@ func (s *syncComparatorSuite) TestSyncDestCompDisableComparison(c *chk.C) {
 		c.Assert(len(dummyCopyScheduler.record), chk.Equals, key+1)
 	}
 }
+
+func (s *syncComparatorSuite) TestSyncDestinationComparatorWithMD5(c *chk.C) {
+	dummyCopyScheduler := dummyProcessor{}
+	dummyCleaner := dummyProcessor{}
+	localRoot := c.MkDir()
+	now := time.Now()
+
+	localFile := func(name, content string) StoredObject {
+		c.Assert(ioutil.WriteFile(filepath.Join(localRoot, name), []byte(content), 0644), chk.IsNil)
+		// the local files are all newer than their blobs, so comparing by time alone would upload every one of them
+		return StoredObject{name: name, relativePath: name, entityType: common.EEntityType.File(), size: int64(len(content)), lastModifiedTime: now}
+	}
+	blob := func(name, content string, withHash bool) StoredObject {
+		obj := StoredObject{name: name, relativePath: name, entityType: common.EEntityType.File(), size: int64(len(content)), lastModifiedTime: now.Add(-time.Hour)}
+		if withHash {
+			hash := md5.Sum([]byte(content))
+			obj.md5 = hash[:]
+		}
+		return obj
+	}
+
+	indexer := newObjectIndexer()
+	destinationComparator := newSyncDestinationComparator(indexer, dummyCopyScheduler.process, dummyCleaner.process, false, newLocalMD5Comparer(localRoot))
+	for _, source := range []StoredObject{
+		localFile("unchanged.txt", "same content"),
+		localFile("changed.txt", "new content!"),
+		localFile("resized.txt", "longer content"),
+		localFile("nohash.txt", "anything"),
+		localFile("added.txt", "only here"),
+	} {
+		c.Assert(indexer.store(source), chk.IsNil)
+	}
+
+	for _, destination := range []StoredObject{
+		blob("unchanged.txt", "same content", true),
+		blob("changed.txt", "old content!", true), // same size, different content
+		blob("resized.txt", "short", true),
+		blob("nohash.txt", "anything", false),
+		blob("deleted.txt", "gone locally", true),
+	} {
+		c.Assert(destinationComparator.processIfNecessary(destination), chk.IsNil)
+	}
+
+	scheduled := make([]string, 0)
+	for _, obj := range dummyCopyScheduler.record {
+		scheduled = append(scheduled, obj.relativePath)
+	}
+	// the blob without a hash falls back to the time comparison, by which the local file is newer
+	c.Assert(scheduled, chk.DeepEquals, []string{"changed.txt", "resized.txt", "nohash.txt"})
+
+	c.Assert(len(dummyCleaner.record), chk.Equals, 1)
+	c.Assert(dummyCleaner.record[0].relativePath, chk.Equals, "deleted.txt")
+
+	// what's left in the index is only at the source, and is scheduled when the enumeration finishes
+	c.Assert(len(indexer.indexMap), chk.Equals, 1)
+	_, present := indexer.indexMap["added.txt"]
+	c.Assert(present, chk.Equals, true)
+}
+
+func (s *syncComparatorSuite) TestMD5ComparisonSkipsReadingWhenSizesDiffer(c *chk.C) {
+	// the file doesn't exist, so reading it would fail
+	missing := StoredObject{relativePath: "missing.txt", entityType: common.EEntityType.File(), size: 5}
+	blob := StoredObject{relativePath: "missing.txt", entityType: common.EEntityType.File(), size: 9, md5: []byte{1, 2, 3}}
+
+	outcome, _, err := verifyLocalFile(filepath.Join(c.MkDir(), "missing.txt"), missing, &blob)
+	c.Assert(err, chk.IsNil)
+	c.Assert(outcome, chk.Equals, verifyOutcomeMismatched)
+}
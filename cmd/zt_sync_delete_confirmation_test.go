@@ -0,0 +1,191 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	chk "gopkg.in/check.v1"
+)
+
+type syncDeleteConfirmationSuite struct{}
+
+var _ = chk.Suite(&syncDeleteConfirmationSuite{})
+
+// answeringLifecycleManager gives a fixed answer to every prompt, and counts the prompts
+type answeringLifecycleManager struct {
+	mockedLifecycleManager
+	answer  common.ResponseOption
+	prompts []string
+}
+
+func (m *answeringLifecycleManager) Prompt(message string, details common.PromptDetails) common.ResponseOption {
+	m.prompts = append(m.prompts, message)
+	return m.answer
+}
+
+func (s *syncDeleteConfirmationSuite) withStdinTerminal(isTerminal bool) func() {
+	original := stdinIsTerminal
+	stdinIsTerminal = func() bool { return isTerminal }
+	return func() { stdinIsTerminal = original }
+}
+
+func (s *syncDeleteConfirmationSuite) TestDeleteRefusedWithoutTerminal(c *chk.C) {
+	defer s.withStdinTerminal(false)()
+	srcDirName := scenarioHelper{}.generateLocalDirectory(c)
+	defer os.RemoveAll(srcDirName)
+
+	raw := getDefaultSyncRawInput(srcDirName, "https://fakeaccount.blob.core.windows.net/container?sv=2019-12-12&sig=fake")
+	raw.yes = false
+
+	_, err := raw.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "--yes")
+
+	// prompt and dry-run need no up-front confirmation
+	raw.deleteDestination = common.EDeleteDestination.Prompt().String()
+	_, err = raw.cook()
+	c.Assert(err, chk.IsNil)
+
+	raw.deleteDestination = common.EDeleteDestination.True().String()
+	raw.dryrun = true
+	_, err = raw.cook()
+	c.Assert(err, chk.IsNil)
+}
+
+func (s *syncDeleteConfirmationSuite) TestYesSkipsConfirmation(c *chk.C) {
+	defer s.withStdinTerminal(false)()
+	srcDirName := scenarioHelper{}.generateLocalDirectory(c)
+	defer os.RemoveAll(srcDirName)
+
+	raw := getDefaultSyncRawInput(srcDirName, "https://fakeaccount.blob.core.windows.net/container?sv=2019-12-12&sig=fake")
+	raw.yes = true
+
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.confirmDeletions, chk.Equals, false)
+
+	// without confirmation, the deleter removes the extra file straight away, without asking
+	dstDirName := scenarioHelper{}.generateLocalDirectory(c)
+	defer os.RemoveAll(dstDirName)
+	scenarioHelper{}.generateLocalFilesFromList(c, dstDirName, []string{"extraFile.txt"})
+
+	lcm := &answeringLifecycleManager{answer: common.EResponseOption.No()}
+	glcm = lcm
+	cca := &cookedSyncCmdArgs{
+		destination:       newLocalRes(dstDirName),
+		deleteDestination: cooked.deleteDestination,
+		confirmDeletions:  cooked.confirmDeletions,
+	}
+	deleter := newSyncLocalDeleteProcessor(cca)
+	c.Assert(deleter.removeImmediately(StoredObject{relativePath: "extraFile.txt", entityType: common.EEntityType.File()}), chk.IsNil)
+	c.Assert(deleter.deletePending(), chk.IsNil)
+
+	_, err = os.Stat(filepath.Join(dstDirName, "extraFile.txt"))
+	c.Assert(os.IsNotExist(err), chk.Equals, true)
+	c.Assert(lcm.prompts, chk.HasLen, 0)
+	c.Assert(cca.getDeletionCount(), chk.Equals, uint32(1))
+}
+
+func (s *syncDeleteConfirmationSuite) TestDeletionsConfirmedOnce(c *chk.C) {
+	defer s.withStdinTerminal(true)()
+	srcDirName := scenarioHelper{}.generateLocalDirectory(c)
+	defer os.RemoveAll(srcDirName)
+
+	raw := getDefaultSyncRawInput(srcDirName, "https://fakeaccount.blob.core.windows.net/container?sv=2019-12-12&sig=fake")
+	raw.yes = false
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.confirmDeletions, chk.Equals, true)
+
+	extraFiles := []string{"extra1.txt", "extra2.txt", "extra3.txt"}
+	for _, answer := range []common.ResponseOption{common.EResponseOption.No(), common.EResponseOption.Yes()} {
+		dstDirName := scenarioHelper{}.generateLocalDirectory(c)
+		defer os.RemoveAll(dstDirName)
+		scenarioHelper{}.generateLocalFilesFromList(c, dstDirName, extraFiles)
+
+		lcm := &answeringLifecycleManager{answer: answer}
+		glcm = lcm
+		cca := &cookedSyncCmdArgs{
+			destination:       newLocalRes(dstDirName),
+			deleteDestination: cooked.deleteDestination,
+			confirmDeletions:  cooked.confirmDeletions,
+		}
+		deleter := newSyncLocalDeleteProcessor(cca)
+		for _, name := range extraFiles {
+			c.Assert(deleter.removeImmediately(StoredObject{relativePath: name, entityType: common.EEntityType.File()}), chk.IsNil)
+		}
+
+		// nothing is deleted before the user has answered
+		for _, name := range extraFiles {
+			_, err = os.Stat(filepath.Join(dstDirName, name))
+			c.Assert(err, chk.IsNil)
+		}
+
+		c.Assert(deleter.deletePending(), chk.IsNil)
+		c.Assert(lcm.prompts, chk.HasLen, 1)
+		c.Assert(lcm.prompts[0], StringContains, "3 local file(s)")
+
+		for _, name := range extraFiles {
+			_, err = os.Stat(filepath.Join(dstDirName, name))
+			c.Assert(os.IsNotExist(err), chk.Equals, answer == common.EResponseOption.Yes())
+		}
+	}
+}
+
+func (s *syncDeleteConfirmationSuite) TestTooManyDeletionsAreConfirmedEarly(c *chk.C) {
+	originalMax := maxPendingDeletions
+	maxPendingDeletions = 2
+	defer func() { maxPendingDeletions = originalMax }()
+
+	extraFiles := []string{"extra1.txt", "extra2.txt", "extra3.txt"}
+	for _, answer := range []common.ResponseOption{common.EResponseOption.No(), common.EResponseOption.Yes()} {
+		dstDirName := scenarioHelper{}.generateLocalDirectory(c)
+		defer os.RemoveAll(dstDirName)
+		scenarioHelper{}.generateLocalFilesFromList(c, dstDirName, extraFiles)
+
+		lcm := &answeringLifecycleManager{answer: answer}
+		glcm = lcm
+		cca := &cookedSyncCmdArgs{
+			destination:       newLocalRes(dstDirName),
+			deleteDestination: common.EDeleteDestination.True(),
+			confirmDeletions:  true,
+		}
+		deleter := newSyncLocalDeleteProcessor(cca)
+		for _, name := range extraFiles {
+			c.Assert(deleter.removeImmediately(StoredObject{relativePath: name, entityType: common.EEntityType.File()}), chk.IsNil)
+		}
+
+		// the user is asked once the cap is reached, and the answer goes for the extra file found after that too
+		c.Assert(lcm.prompts, chk.HasLen, 1)
+		c.Assert(lcm.prompts[0], StringContains, "At least 2 local file(s)")
+		c.Assert(deleter.pending, chk.HasLen, 0)
+		c.Assert(deleter.deletePending(), chk.IsNil)
+		c.Assert(lcm.prompts, chk.HasLen, 1)
+
+		for _, name := range extraFiles {
+			_, err := os.Stat(filepath.Join(dstDirName, name))
+			c.Assert(os.IsNotExist(err), chk.Equals, answer == common.EResponseOption.Yes())
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+type tarUploadSuite struct{}
+
+var _ = chk.Suite(&tarUploadSuite{})
+
+func (s *tarUploadSuite) TestAsTarValidation(c *chk.C) {
+	srcDir := c.MkDir()
+	raw := getDefaultCopyRawInput(srcDir, "https://account.blob.core.windows.net/container/archive.tar")
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+	raw.asTar = true
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.asTar, chk.Equals, true)
+
+	for _, t := range []struct {
+		change   func(raw *rawCopyCmdArgs)
+		expected string
+	}{
+		{func(raw *rawCopyCmdArgs) { raw.dst = "https://account.blob.core.windows.net/container" }, "the URL of the blob to create"},
+		{func(raw *rawCopyCmdArgs) { raw.src = filepath.Join(srcDir, "missing") }, "needs the source to be a directory"},
+		{func(raw *rawCopyCmdArgs) { raw.exclude = "*.tmp" }, "cannot be used with include or exclude filters"},
+		{func(raw *rawCopyCmdArgs) { raw.blobType = common.EBlobType.PageBlob().String() }, "cannot be used with blob-type PageBlob"},
+		{func(raw *rawCopyCmdArgs) { raw.followSymlinks = true }, "cannot be used with follow-symlinks"},
+	} {
+		bad := raw
+		t.change(&bad)
+		_, err = bad.cook()
+		c.Assert(err, chk.NotNil, chk.Commentf(t.expected))
+		c.Assert(err.Error(), StringContains, t.expected)
+	}
+
+	raw = getDefaultCopyRawInput("https://account.blob.core.windows.net/container/archive.tar", c.MkDir())
+	raw.fromTo = common.EFromTo.BlobLocal().String()
+	raw.asTar = true
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), StringContains, "only supported when uploading to Blob storage")
+}
+
+func (s *tarUploadSuite) TestDirectoryUploadedAsTarUntarsToTheSame(c *chk.C) {
+	srcDir := c.MkDir()
+	mtime := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	files := map[string]struct {
+		content string
+		mode    os.FileMode
+	}{
+		"top.txt":               {"at the top", 0644},
+		"run.sh":                {"#!/bin/sh\necho hi\n", 0755},
+		"sub/private.key":       {"secret", 0600},
+		"sub/deeper/large.bin":  {string(bytes.Repeat([]byte("0123456789"), 10000)), 0640},
+		"sub/deeper/empty.file": {"", 0444},
+	}
+	for name, f := range files {
+		path := filepath.Join(srcDir, filepath.FromSlash(name))
+		c.Assert(os.MkdirAll(filepath.Dir(path), 0755), chk.IsNil)
+		c.Assert(ioutil.WriteFile(path, []byte(f.content), f.mode), chk.IsNil)
+		c.Assert(os.Chmod(path, f.mode), chk.IsNil) // not masked by the umask
+		c.Assert(os.Chtimes(path, mtime, mtime), chk.IsNil)
+	}
+	c.Assert(os.Mkdir(filepath.Join(srcDir, "emptyFolder"), 0700), chk.IsNil)
+	c.Assert(os.Symlink("top.txt", filepath.Join(srcDir, "link")), chk.IsNil)
+
+	service := &cpkBlobService{blocks: map[string][]byte{}, writeKeys: map[string]bool{}}
+	server := httptest.NewServer(service)
+	defer server.Close()
+	blob := common.ResourceString{Value: server.URL + "/account/container/archive.tar", SAS: "sv=2019-12-12&sig=fake"}
+
+	// small blocks, so that the archive takes several
+	upload := &CookedCopyCmdArgs{Source: common.ResourceString{Value: srcDir}, Destination: blob, FromTo: common.EFromTo.LocalBlob(),
+		blockBlobTier: common.EBlockBlobTier.None(), blockSize: 16 * 1024, asTar: true}
+	c.Assert(upload.processTarUpload(), chk.IsNil)
+	c.Assert(len(service.blocks) > 1, chk.Equals, true)
+	c.Assert(upload.contentType, chk.Equals, tarContentType)
+
+	// untar the blob's content somewhere else
+	dstDir := c.MkDir()
+	tr := tar.NewReader(bytes.NewReader(service.content))
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, chk.IsNil)
+		path := filepath.Join(dstDir, filepath.FromSlash(header.Name))
+		switch header.Typeflag {
+		case tar.TypeDir:
+			c.Assert(os.MkdirAll(path, header.FileInfo().Mode().Perm()), chk.IsNil)
+		case tar.TypeSymlink:
+			c.Assert(os.Symlink(header.Linkname, path), chk.IsNil)
+		case tar.TypeReg:
+			content, err := ioutil.ReadAll(tr)
+			c.Assert(err, chk.IsNil)
+			c.Assert(ioutil.WriteFile(path, content, 0600), chk.IsNil)
+			c.Assert(os.Chmod(path, header.FileInfo().Mode().Perm()), chk.IsNil)
+			c.Assert(os.Chtimes(path, header.ModTime, header.ModTime), chk.IsNil)
+		default:
+			c.Fatalf("unexpected entry %s of type %c", header.Name, header.Typeflag)
+		}
+	}
+
+	for name, f := range files {
+		path := filepath.Join(dstDir, filepath.FromSlash(name))
+		content, err := ioutil.ReadFile(path)
+		c.Assert(err, chk.IsNil, chk.Commentf(name))
+		c.Assert(string(content), chk.Equals, f.content, chk.Commentf(name))
+		info, err := os.Stat(path)
+		c.Assert(err, chk.IsNil)
+		c.Assert(info.Mode().Perm(), chk.Equals, f.mode, chk.Commentf(name))
+		c.Assert(info.ModTime().Equal(mtime), chk.Equals, true, chk.Commentf(name))
+	}
+	info, err := os.Stat(filepath.Join(dstDir, "emptyFolder"))
+	c.Assert(err, chk.IsNil)
+	c.Assert(info.IsDir(), chk.Equals, true)
+	c.Assert(info.Mode().Perm(), chk.Equals, os.FileMode(0700))
+	target, err := os.Readlink(filepath.Join(dstDir, "link"))
+	c.Assert(err, chk.IsNil)
+	c.Assert(target, chk.Equals, "top.txt")
+}
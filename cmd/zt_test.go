@@ -50,7 +50,17 @@ import (
 )
 
 // Hookup to the testing framework
-func Test(t *testing.T) { chk.TestingT(t) }
+func Test(t *testing.T) {
+	// cooking a command opens its scanning log, which must not land in the source tree
+	logDir, err := ioutil.TempDir("", "azcopy-test-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(logDir)
+	azcopyLogPathFolder = logDir
+
+	chk.TestingT(t)
+}
 
 type cmdIntegrationSuite struct{}
 
@@ -39,6 +39,7 @@ type CacheLimiter interface {
 	WaitUntilAdd(ctx context.Context, count int64, useRelaxedLimit Predicate) error
 	Remove(count int64)
 	Limit() int64
+	StrictLimit() int64
 }
 
 type cacheLimiter struct {
@@ -58,7 +59,7 @@ func (c *cacheLimiter) TryAdd(count int64, useRelaxedLimit bool) (added bool) {
 	// for high-priority things (i.e. things we deem to be allowable under a relaxed (non-strict) limit)
 	strict := !useRelaxedLimit
 	if strict {
-		lim = int64(float32(lim) * 0.75)
+		lim = c.StrictLimit()
 		// Rationale for the level of the strict limit: as at Jan 2018, we are using 0.75 of the total as the strict
 		// limit, leaving the other 0.25 of the total accessible under the "relaxed" limit.
 		// That last 25% gets use for two things: in downloads it is used for things where we KNOW there's
@@ -109,3 +110,9 @@ func (c *cacheLimiter) Remove(count int64) {
 func (c *cacheLimiter) Limit() int64 {
 	return c.limit
 }
+
+// StrictLimit is the part of the limit that's available to things that aren't high-priority. Anything bigger than this
+// can never be added under the strict limit, however long it waits
+func (c *cacheLimiter) StrictLimit() int64 {
+	return int64(float32(c.limit) * 0.75)
+}
@@ -0,0 +1,150 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CapMbpsSchedule is the throughput cap given by --cap-mbps. It is either a single cap, or a list of time-of-day windows
+// that each have their own cap, e.g. "08:00-18:00=50,18:00-08:00=0". A cap of zero means the throughput isn't capped.
+type CapMbpsSchedule struct {
+	fixedCapMbps float64
+	windows      []capMbpsWindow
+}
+
+// capMbpsWindow applies a cap from start (inclusive) to end (exclusive), both measured from midnight local time.
+// A window whose end is before its start wraps around midnight.
+type capMbpsWindow struct {
+	start   time.Duration
+	end     time.Duration
+	capMbps float64
+}
+
+func (w capMbpsWindow) contains(timeOfDay time.Duration) bool {
+	if w.start <= w.end {
+		return timeOfDay >= w.start && timeOfDay < w.end
+	}
+	return timeOfDay >= w.start || timeOfDay < w.end
+}
+
+// ParseCapMbpsSchedule parses either a plain number of megabits per second, or a comma-separated list of
+// windows in the form HH:MM-HH:MM=cap. Times of day outside all the windows are not capped.
+// If windows overlap, the first one listed wins.
+func ParseCapMbpsSchedule(s string) (CapMbpsSchedule, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return CapMbpsSchedule{}, nil
+	}
+	if !strings.Contains(s, "=") {
+		capMbps, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return CapMbpsSchedule{}, fmt.Errorf("invalid cap-mbps %q. It must be a number, or a schedule such as 08:00-18:00=50,18:00-08:00=0", s)
+		}
+		return CapMbpsSchedule{fixedCapMbps: capMbps}, nil
+	}
+
+	var schedule CapMbpsSchedule
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue // tolerate a trailing comma
+		}
+		window, err := parseCapMbpsWindow(entry)
+		if err != nil {
+			return CapMbpsSchedule{}, err
+		}
+		schedule.windows = append(schedule.windows, window)
+	}
+	return schedule, nil
+}
+
+func parseCapMbpsWindow(entry string) (capMbpsWindow, error) {
+	parts := strings.SplitN(entry, "=", 2)
+	times := strings.Split(parts[0], "-")
+	if len(parts) != 2 || len(times) != 2 {
+		return capMbpsWindow{}, fmt.Errorf("invalid cap-mbps window %q. Windows must be in the form HH:MM-HH:MM=cap", entry)
+	}
+
+	start, err := parseTimeOfDay(times[0])
+	if err != nil {
+		return capMbpsWindow{}, fmt.Errorf("invalid start time in cap-mbps window %q: %w", entry, err)
+	}
+	end, err := parseTimeOfDay(times[1])
+	if err != nil {
+		return capMbpsWindow{}, fmt.Errorf("invalid end time in cap-mbps window %q: %w", entry, err)
+	}
+	if start == end {
+		return capMbpsWindow{}, fmt.Errorf("invalid cap-mbps window %q. The start and end times must differ", entry)
+	}
+
+	capMbps, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return capMbpsWindow{}, fmt.Errorf("invalid cap in cap-mbps window %q. It must be a number of megabits per second", entry)
+	}
+
+	return capMbpsWindow{start: start, end: end, capMbps: capMbps}, nil
+}
+
+// parseTimeOfDay turns HH:MM into the time since midnight
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time of day in the form HH:MM", strings.TrimSpace(s))
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// IsScheduled says whether the cap varies with the time of day
+func (s CapMbpsSchedule) IsScheduled() bool {
+	return len(s.windows) > 0
+}
+
+// CapAt returns the cap, in megabits per second, that applies at the given time. Zero means no cap.
+func (s CapMbpsSchedule) CapAt(t time.Time) float64 {
+	if !s.IsScheduled() {
+		return s.fixedCapMbps
+	}
+
+	timeOfDay := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	for _, w := range s.windows {
+		if w.contains(timeOfDay) {
+			return w.capMbps
+		}
+	}
+	return 0
+}
+
+// Caps lists every cap in the schedule, so that they can be validated
+func (s CapMbpsSchedule) Caps() []float64 {
+	if !s.IsScheduled() {
+		return []float64{s.fixedCapMbps}
+	}
+
+	caps := make([]float64, len(s.windows))
+	for i, w := range s.windows {
+		caps[i] = w.capMbps
+	}
+	return caps
+}
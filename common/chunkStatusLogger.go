@@ -325,6 +325,9 @@ func (csl *chunkStatusLogger) main(chunkLogPath string) {
 	w := bufio.NewWriter(f)
 	_, _ = w.WriteString("Name,Offset,State,StateStartTime\n")
 
+	// the names of chunks read from remote sources are URLs, which may have SAS tokens in them
+	sanitizer := NewAzCopyLogSanitizer()
+
 	doFlush := func() {
 		_ = w.Flush()
 		_ = f.Sync()
@@ -339,7 +342,7 @@ func (csl *chunkStatusLogger) main(chunkLogPath string) {
 			csl.flushDone <- struct{}{}
 			continue // TODO can become break (or be moved to later if we close unsaved entries, once we figure out how we got stuff written to us after CloseLog was called)
 		}
-		_, _ = w.WriteString(fmt.Sprintf("%s,%d,%s,%s\n", x.Name, x.OffsetInFile(), x.reason, x.waitStart))
+		_, _ = w.WriteString(fmt.Sprintf("%s,%d,%s,%s\n", sanitizer.SanitizeLogMessage(x.Name), x.OffsetInFile(), x.reason, x.waitStart))
 		if alwaysFlushFromNowOn {
 			// TODO: remove when we figure out how we got stuff written to us after CloseLog was called. For now, this should handle those cases (if they still exist)
 			doFlush()
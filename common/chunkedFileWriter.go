@@ -89,10 +89,11 @@ type chunkedFileWriter struct {
 	// controls body-read retries. Public so value can be shared with retryReader
 	maxRetryPerDownloadBody int
 
-	// how will hashes be validated?
-	md5ValidationOption HashValidationOption
+	// is the MD5 hash of the file as written needed? If not, it isn't computed
+	computeMd5 bool
 
-	sourceMd5Exists bool
+	// how much of the file was already saved before this writer was created (e.g. by a download that was paused)
+	alreadySaved int64
 }
 
 type fileChunk struct {
@@ -100,7 +101,11 @@ type fileChunk struct {
 	data []byte
 }
 
-func NewChunkedFileWriter(ctx context.Context, slicePool ByteSlicePooler, cacheLimiter CacheLimiter, chunkLogger ChunkStatusLogger, file io.WriteCloser, numChunks uint32, maxBodyRetries int, md5ValidationOption HashValidationOption, sourceMd5Exists bool) ChunkedFileWriter {
+// NewChunkedFileWriter returns a writer for the chunks of file. If the first alreadySaved bytes of the file were
+// saved earlier, file must be positioned after them, and chunks are expected from there on. In that case, if the
+// MD5 hash is needed, file must also be an io.ReaderAt, so that the bytes already saved can be hashed too.
+// Flush returns the hash only if computeMd5 is true.
+func NewChunkedFileWriter(ctx context.Context, slicePool ByteSlicePooler, cacheLimiter CacheLimiter, chunkLogger ChunkStatusLogger, file io.WriteCloser, numChunks uint32, maxBodyRetries int, computeMd5 bool, alreadySaved int64) ChunkedFileWriter {
 	// Set max size for buffered channel. The upper limit here is believed to be generous, given worker routine drains it constantly.
 	// Use num chunks in file if lower than the upper limit, to prevent allocating RAM for lots of large channel buffers when dealing with
 	// very large numbers of very small files.
@@ -115,8 +120,8 @@ func NewChunkedFileWriter(ctx context.Context, slicePool ByteSlicePooler, cacheL
 		failureError:            make(chan error, 1),
 		newUnorderedChunks:      make(chan fileChunk, chanBufferSize),
 		maxRetryPerDownloadBody: maxBodyRetries,
-		md5ValidationOption:     md5ValidationOption,
-		sourceMd5Exists:         sourceMd5Exists,
+		computeMd5:              computeMd5,
+		alreadySaved:            alreadySaved,
 	}
 	go w.workerRoutine(ctx)
 	return w
@@ -213,12 +218,19 @@ func (w *chunkedFileWriter) MaxRetryPerDownloadBody() int {
 // resorting to the likes of SetFileValidData (https://docs.microsoft.com/en-us/windows/desktop/api/fileapi/nf-fileapi-setfilevaliddata)
 // and (b) we can compute MD5 hashes - which can only be computed when moving through the data sequentially
 func (w *chunkedFileWriter) workerRoutine(ctx context.Context) {
-	nextOffsetToSave := int64(0)
+	nextOffsetToSave := w.alreadySaved
 	unsavedChunksByFileOffset := make(map[int64]fileChunk)
 	md5Hasher := md5.New()
-	if w.md5ValidationOption == EHashValidationOption.NoCheck() || !w.sourceMd5Exists {
-		// save CPU time by not even computing a hash, if we don't want to check it, or have nothing to check it against
+	if !w.computeMd5 {
+		// save CPU time by not even computing a hash, if nothing needs it
 		md5Hasher = &nullHasher{}
+	} else if w.alreadySaved > 0 {
+		// the hash is of the whole file, so it must start with what was saved before
+		if err := w.hashAlreadySaved(md5Hasher); err != nil {
+			w.failureError <- err
+			close(w.failureError)
+			return
+		}
 	}
 
 	for {
@@ -255,6 +267,16 @@ func (w *chunkedFileWriter) workerRoutine(ctx context.Context) {
 	}
 }
 
+// Reads back the bytes that were saved before this writer was created, into the hash
+func (w *chunkedFileWriter) hashAlreadySaved(md5Hasher hash.Hash) error {
+	r, ok := w.file.(io.ReaderAt)
+	if !ok {
+		return errors.New("cannot hash the part of the file that was already saved, because the file cannot be read")
+	}
+	_, err := io.Copy(md5Hasher, io.NewSectionReader(r, 0, w.alreadySaved))
+	return err
+}
+
 // Hashes and saves available chunks that are sequential from nextOffsetToSave. Stops and returns as soon as it hits
 // a gap (i.e. the position of a chunk that hasn't arrived yet)
 func (w *chunkedFileWriter) sequentiallyProcessAvailableChunks(unsavedChunksByFileOffset map[int64]fileChunk, nextOffsetToSave *int64, md5Hasher hash.Hash, ctx context.Context) error {
@@ -0,0 +1,138 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// The storage emulator (Azurite) uses the same account, key and endpoint for everyone
+const (
+	DevelopmentStorageAccountName  = "devstoreaccount1"
+	DevelopmentStorageAccountKey   = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+	DevelopmentStorageBlobEndpoint = "http://127.0.0.1:10000/" + DevelopmentStorageAccountName
+	developmentStorageBlobPort     = "10000"
+)
+
+// StorageConnectionString holds the parts of an Azure Storage connection string that AzCopy uses:
+// the account key, and the blob endpoint of the account it's for.
+type StorageConnectionString struct {
+	AccountName  string
+	AccountKey   string
+	BlobEndpoint string // without a trailing slash
+}
+
+// ParseStorageConnectionString parses a connection string of the form "AccountName=x;AccountKey=y;...", as shown for
+// storage accounts in the portal, or "UseDevelopmentStorage=true" for the storage emulator.
+// Connection strings with a SAS, instead of an account key, are not supported, since the SAS can be put in the URL.
+func ParseStorageConnectionString(connectionString string) (StorageConnectionString, error) {
+	settings := make(map[string]string)
+	for _, setting := range strings.Split(connectionString, ";") {
+		setting = strings.TrimSpace(setting)
+		if setting == "" {
+			continue
+		}
+		kv := strings.SplitN(setting, "=", 2) // the value may contain '=', e.g. as base64 padding in the key
+		if len(kv) != 2 {
+			return StorageConnectionString{}, fmt.Errorf("the connection string setting '%s' is not of the form key=value", kv[0])
+		}
+		settings[strings.ToLower(strings.TrimSpace(kv[0]))] = strings.TrimSpace(kv[1])
+	}
+
+	if strings.EqualFold(settings["usedevelopmentstorage"], "true") {
+		cs := StorageConnectionString{
+			AccountName:  DevelopmentStorageAccountName,
+			AccountKey:   DevelopmentStorageAccountKey,
+			BlobEndpoint: DevelopmentStorageBlobEndpoint,
+		}
+		if proxy := settings["developmentstorageproxyuri"]; proxy != "" {
+			u, err := url.Parse(proxy)
+			if err != nil || u.Host == "" {
+				return StorageConnectionString{}, fmt.Errorf("the DevelopmentStorageProxyUri '%s' is not a valid URL", proxy)
+			}
+			cs.BlobEndpoint = u.Scheme + "://" + u.Hostname() + ":" + developmentStorageBlobPort + "/" + DevelopmentStorageAccountName
+		}
+		return cs, nil
+	}
+
+	cs := StorageConnectionString{
+		AccountName:  settings["accountname"],
+		AccountKey:   settings["accountkey"],
+		BlobEndpoint: strings.TrimSuffix(settings["blobendpoint"], "/"),
+	}
+	if cs.AccountName == "" || cs.AccountKey == "" {
+		return StorageConnectionString{}, errors.New("the connection string must have an AccountName and an AccountKey, or be UseDevelopmentStorage=true")
+	}
+
+	if cs.BlobEndpoint == "" {
+		if cs.AccountName == DevelopmentStorageAccountName && cs.AccountKey == DevelopmentStorageAccountKey {
+			// the well-known emulator credentials, given without an endpoint, can only mean the local emulator
+			cs.BlobEndpoint = DevelopmentStorageBlobEndpoint
+		} else {
+			protocol := settings["defaultendpointsprotocol"]
+			if protocol == "" {
+				protocol = "https"
+			}
+			suffix := settings["endpointsuffix"]
+			if suffix == "" {
+				suffix = "core.windows.net"
+			}
+			cs.BlobEndpoint = fmt.Sprintf("%s://%s.blob.%s", strings.ToLower(protocol), cs.AccountName, suffix)
+		}
+	}
+
+	if u, err := url.Parse(cs.BlobEndpoint); err != nil || u.Host == "" {
+		return StorageConnectionString{}, fmt.Errorf("the BlobEndpoint '%s' is not a valid URL", cs.BlobEndpoint)
+	}
+	return cs, nil
+}
+
+// CoversBlobURL reports whether rawURL is on the connection string's blob endpoint, so that its account key can be used for it
+func (cs StorageConnectionString) CoversBlobURL(rawURL string) bool {
+	endpoint, err := url.Parse(cs.BlobEndpoint)
+	if err != nil {
+		return false
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	if !strings.EqualFold(u.Scheme, endpoint.Scheme) || !strings.EqualFold(u.Host, endpoint.Host) {
+		return false
+	}
+	return endpoint.Path == "" || u.Path == endpoint.Path || strings.HasPrefix(u.Path, endpoint.Path+"/")
+}
+
+// GetStorageConnectionString returns the connection string set in AZCOPY_CONNECTION_STRING, if there is one
+func GetStorageConnectionString() (cs StorageConnectionString, isSet bool, err error) {
+	raw := GetLifecycleMgr().GetEnvironmentVariable(EEnvironmentVariable.ConnectionString())
+	if raw == "" {
+		return StorageConnectionString{}, false, nil
+	}
+	cs, err = ParseStorageConnectionString(raw)
+	if err != nil {
+		return StorageConnectionString{}, false, fmt.Errorf("%s: %w", EEnvironmentVariable.ConnectionString().Name, err)
+	}
+	return cs, true, nil
+}
@@ -0,0 +1,109 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ContentTypeSignature recognizes a file format by the magic number at the start of its content.
+// Mask, if given, is ANDed with the content before comparing it to Magic, so that a 0x00 in the mask skips a byte
+// (such as a length field). It must be the same length as Magic.
+type ContentTypeSignature struct {
+	Offset      int
+	Magic       []byte
+	Mask        []byte
+	ContentType string
+}
+
+func (s ContentTypeSignature) matches(leadingBytes []byte) bool {
+	if s.Offset < 0 || len(leadingBytes) < s.Offset+len(s.Magic) {
+		return false
+	}
+
+	content := leadingBytes[s.Offset : s.Offset+len(s.Magic)]
+	if s.Mask == nil {
+		return bytes.Equal(content, s.Magic)
+	}
+
+	for i := range s.Magic {
+		if content[i]&s.Mask[i] != s.Magic[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// checked before http.DetectContentType, which doesn't know most of these formats
+var builtinContentTypeSignatures = []ContentTypeSignature{
+	{Magic: []byte("RIFF\x00\x00\x00\x00WEBPVP"), Mask: []byte("\xFF\xFF\xFF\xFF\x00\x00\x00\x00\xFF\xFF\xFF\xFF\xFF\xFF"), ContentType: "image/webp"},
+	{Offset: 4, Magic: []byte("ftypavif"), ContentType: "image/avif"},
+	{Offset: 4, Magic: []byte("ftypavis"), ContentType: "image/avif"},
+	{Offset: 4, Magic: []byte("ftypheic"), ContentType: "image/heic"},
+	{Offset: 4, Magic: []byte("ftypheix"), ContentType: "image/heic"},
+	{Magic: []byte("fLaC"), ContentType: "audio/flac"},
+	{Magic: []byte{0x28, 0xB5, 0x2F, 0xFD}, ContentType: "application/zstd"},
+	{Magic: []byte{0xFD, '7', 'z', 'X', 'Z', 0x00}, ContentType: "application/x-xz"},
+	{Magic: []byte{'7', 'z', 0xBC, 0xAF, 0x27, 0x1C}, ContentType: "application/x-7z-compressed"},
+	{Magic: []byte("BZh\x001AY&SY"), Mask: []byte("\xFF\xFF\xFF\x00\xFF\xFF\xFF\xFF\xFF\xFF"), ContentType: "application/x-bzip2"}, // the block size digit, then the first block's magic number
+	{Magic: []byte("PAR1"), ContentType: "application/vnd.apache.parquet"},
+	{Magic: []byte("Obj\x01"), ContentType: "application/avro"},
+	{Magic: []byte("SQLite format 3\x00"), ContentType: "application/vnd.sqlite3"},
+}
+
+var registeredContentTypeSignatures struct {
+	lock       sync.RWMutex
+	signatures []ContentTypeSignature
+}
+
+// RegisterContentTypeSignature teaches DetectContentType another format.
+// Registered signatures are checked before the built-in ones, the most recently registered first, so they can also
+// be used to change the content type given to a format that is already known.
+func RegisterContentTypeSignature(signature ContentTypeSignature) {
+	if signature.Mask != nil && len(signature.Mask) != len(signature.Magic) {
+		panic("the mask of a content type signature must be the same length as its magic number")
+	}
+
+	registeredContentTypeSignatures.lock.Lock()
+	defer registeredContentTypeSignatures.lock.Unlock()
+	registeredContentTypeSignatures.signatures = append([]ContentTypeSignature{signature}, registeredContentTypeSignatures.signatures...)
+}
+
+// DetectContentType guesses the content type from the leading bytes of a file, falling back to
+// http.DetectContentType for formats that no signature matches. Any charset parameter is dropped.
+func DetectContentType(leadingBytes []byte) string {
+	registeredContentTypeSignatures.lock.RLock()
+	registered := registeredContentTypeSignatures.signatures
+	registeredContentTypeSignatures.lock.RUnlock()
+
+	for _, signatures := range [][]ContentTypeSignature{registered, builtinContentTypeSignatures} {
+		for _, s := range signatures {
+			if s.matches(leadingBytes) {
+				return s.ContentType
+			}
+		}
+	}
+
+	return strings.Split(http.DetectContentType(leadingBytes), ";")[0]
+}
@@ -23,6 +23,8 @@ package common
 import (
 	gcpUtils "cloud.google.com/go/storage"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"math"
@@ -32,6 +34,7 @@ import (
 
 	"github.com/Azure/azure-storage-azcopy/v10/azbfs"
 	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/Azure/azure-storage-file-go/azfile"
 	"github.com/Azure/go-autorest/autorest/adal"
 	"github.com/minio/minio-go"
 	"github.com/minio/minio-go/pkg/credentials"
@@ -106,6 +109,86 @@ func CreateBlobCredential(ctx context.Context, credInfo CredentialInfo, options
 			})
 	}
 
+	if credInfo.CredentialType == ECredentialType.SharedKey() {
+		key, err := GetSharedKey(credInfo.SharedKeyAccountName)
+		if err != nil {
+			options.panicError(err)
+			return credential
+		}
+		sharedKey, err := azblob.NewSharedKeyCredential(credInfo.SharedKeyAccountName, key)
+		if err != nil {
+			options.panicError(fmt.Errorf("cannot use the account key: %w", err))
+			return credential
+		}
+		return sharedKey
+	}
+
+	return credential
+}
+
+// AccountKeyFromEnvironment returns the key that the environment gives for accountName, or "" if there's none.
+// AZCOPY_ACCOUNT_KEY is the key of the account named in AZCOPY_ACCOUNT_NAME, so that it's never sent to sign requests to
+// some other account. ACCOUNT_KEY, which was read for BlobFS before AZCOPY_ACCOUNT_KEY existed, is likewise the key of ACCOUNT_NAME.
+func AccountKeyFromEnvironment(accountName string) (string, error) {
+	if key := lcm.GetEnvironmentVariable(EEnvironmentVariable.StorageAccountKey()); key != "" {
+		keyAccountName := lcm.GetEnvironmentVariable(EEnvironmentVariable.StorageAccountName())
+		if keyAccountName == "" {
+			return "", fmt.Errorf("%s must be set to the name of the account that %s is for",
+				EEnvironmentVariable.StorageAccountName().Name, EEnvironmentVariable.StorageAccountKey().Name)
+		}
+		if strings.EqualFold(keyAccountName, accountName) {
+			return key, nil
+		}
+	}
+
+	if key := lcm.GetEnvironmentVariable(EEnvironmentVariable.AccountKey()); key != "" &&
+		strings.EqualFold(lcm.GetEnvironmentVariable(EEnvironmentVariable.AccountName()), accountName) {
+		return key, nil
+	}
+	return "", nil
+}
+
+// GetSharedKey returns the key to sign requests to accountName with. That's the key of the connection string in
+// AZCOPY_CONNECTION_STRING, if it's for that account, or else the one the environment gives for it (see AccountKeyFromEnvironment).
+func GetSharedKey(accountName string) (string, error) {
+	if accountName == "" {
+		return "", errors.New("invalid state, the account for the SharedKey credential is not known")
+	}
+
+	cs, isSet, err := GetStorageConnectionString()
+	if err != nil {
+		return "", err
+	}
+	if isSet && cs.AccountName == accountName {
+		return cs.AccountKey, nil
+	}
+
+	key, err := AccountKeyFromEnvironment(accountName)
+	if err != nil || key != "" {
+		return key, err
+	}
+	return "", fmt.Errorf("%s must be set, with %s set to %s, before creating a SharedKey credential for the account %s",
+		EEnvironmentVariable.StorageAccountKey().Name, EEnvironmentVariable.StorageAccountName().Name, accountName, accountName)
+}
+
+// CreateFileCredential creates Azure File credential according to credential info.
+func CreateFileCredential(ctx context.Context, credInfo CredentialInfo, options CredentialOpOptions) azfile.Credential {
+	credential := azfile.NewAnonymousCredential()
+
+	if credInfo.CredentialType == ECredentialType.SharedKey() {
+		key, err := GetSharedKey(credInfo.SharedKeyAccountName)
+		if err != nil {
+			options.panicError(err)
+			return credential
+		}
+		sharedKey, err := azfile.NewSharedKeyCredential(credInfo.SharedKeyAccountName, key)
+		if err != nil {
+			options.panicError(fmt.Errorf("cannot use the account key: %w", err))
+			return credential
+		}
+		return sharedKey
+	}
+
 	return credential
 }
 
@@ -174,12 +257,14 @@ func CreateBlobFSCredential(ctx context.Context, credInfo CredentialInfo, option
 			})
 
 	case ECredentialType.SharedKey():
-		// Get the Account Name and Key variables from environment
-		name := lcm.GetEnvironmentVariable(EEnvironmentVariable.AccountName())
-		key := lcm.GetEnvironmentVariable(EEnvironmentVariable.AccountKey())
-		// If the ACCOUNT_NAME and ACCOUNT_KEY are not set in environment variables
-		if name == "" || key == "" {
-			options.panicError(errors.New("ACCOUNT_NAME and ACCOUNT_KEY environment variables must be set before creating the blobfs SharedKey credential"))
+		name := credInfo.SharedKeyAccountName
+		if name == "" {
+			// for compatibility, the account may be given as ACCOUNT_NAME when the credential type is forced
+			name = lcm.GetEnvironmentVariable(EEnvironmentVariable.AccountName())
+		}
+		key, err := GetSharedKey(name)
+		if err != nil {
+			options.panicError(err)
 		}
 		// create the shared key credentials
 		cred = azbfs.NewSharedKeyCredential(name, key)
@@ -332,15 +417,58 @@ func (f *GCPClientFactory) GetGCPClient(ctx context.Context, credInfo Credential
 // Default Encryption Algorithm Supported
 const EncryptionAlgorithmAES256 string = "AES256"
 
+// cpkFromCommandLine is the key given with --cpk-encryption-key, if any. GetCpkInfo prefers it to the environment variables.
+// It's set once, before the job starts, so every request of every transfer uses the same key.
+var cpkFromCommandLine struct {
+	encryptionKey       string
+	encryptionKeySHA256 string
+}
+
+// SetCpkEncryptionKey makes GetCpkInfo return the given key, after ValidateCpkEncryptionKey has checked it.
+// An empty encryptionKey reverts to the key in the environment variables.
+func SetCpkEncryptionKey(encryptionKey, encryptionKeySHA256 string) error {
+	if encryptionKey == "" {
+		cpkFromCommandLine.encryptionKey, cpkFromCommandLine.encryptionKeySHA256 = "", ""
+		return nil
+	}
+
+	encryptionKeySHA256, err := ValidateCpkEncryptionKey(encryptionKey, encryptionKeySHA256)
+	if err != nil {
+		return err
+	}
+	cpkFromCommandLine.encryptionKey, cpkFromCommandLine.encryptionKeySHA256 = encryptionKey, encryptionKeySHA256
+	return nil
+}
+
+// ValidateCpkEncryptionKey checks that encryptionKey is a base64 encoded AES-256 key, and that encryptionKeySHA256, if given,
+// is the base64 encoded SHA-256 hash of it. It returns that hash.
+func ValidateCpkEncryptionKey(encryptionKey, encryptionKeySHA256 string) (string, error) {
+	key, err := base64.StdEncoding.DecodeString(encryptionKey)
+	if err != nil || len(key) != 32 {
+		return "", errors.New("the client provided encryption key must be a base64 encoded 256-bit (32 byte) key")
+	}
+
+	hash := sha256.Sum256(key)
+	computedSHA256 := base64.StdEncoding.EncodeToString(hash[:])
+	if encryptionKeySHA256 != "" && encryptionKeySHA256 != computedSHA256 {
+		return "", errors.New("the SHA-256 hash of the client provided encryption key doesn't match the key")
+	}
+	return computedSHA256, nil
+}
+
 func GetCpkInfo(cpkInfo bool) CpkInfo {
 	if !cpkInfo {
 		return CpkInfo{}
 	}
 
-	// fetch EncryptionKey and EncryptionKeySHA256 from the environment variables
+	// fetch EncryptionKey and EncryptionKeySHA256 from the command line, or else the environment variables
 	glcm := GetLifecycleMgr()
-	encryptionKey := glcm.GetEnvironmentVariable(EEnvironmentVariable.CPKEncryptionKey())
-	encryptionKeySHA256 := glcm.GetEnvironmentVariable(EEnvironmentVariable.CPKEncryptionKeySHA256())
+	encryptionKey := cpkFromCommandLine.encryptionKey
+	encryptionKeySHA256 := cpkFromCommandLine.encryptionKeySHA256
+	if encryptionKey == "" {
+		encryptionKey = glcm.GetEnvironmentVariable(EEnvironmentVariable.CPKEncryptionKey())
+		encryptionKeySHA256 = glcm.GetEnvironmentVariable(EEnvironmentVariable.CPKEncryptionKeySHA256())
+	}
 	encryptionAlgorithmAES256 := EncryptionAlgorithmAES256
 
 	if encryptionKey == "" || encryptionKeySHA256 == "" {
@@ -39,10 +39,16 @@ var VisibleEnvironmentVariables = []EnvironmentVariable{
 	EEnvironmentVariable.LogLocation(),
 	EEnvironmentVariable.JobPlanLocation(),
 	EEnvironmentVariable.ConcurrencyValue(),
+	EEnvironmentVariable.AutoTuneMinConcurrency(),
+	EEnvironmentVariable.AutoTuneMaxConcurrency(),
 	EEnvironmentVariable.TransferInitiationPoolSize(),
+	EEnvironmentVariable.SmallFileThreshold(),
+	EEnvironmentVariable.SmallFilePoolSize(),
+	EEnvironmentVariable.CommitPoolSize(),
 	EEnvironmentVariable.EnumerationPoolSize(),
 	EEnvironmentVariable.DisableHierarchicalScanning(),
 	EEnvironmentVariable.ParallelStatFiles(),
+	EEnvironmentVariable.InterleaveChunks(),
 	EEnvironmentVariable.BufferGB(),
 	EEnvironmentVariable.AWSAccessKeyID(),
 	EEnvironmentVariable.AWSSecretAccessKey(),
@@ -66,10 +72,19 @@ var VisibleEnvironmentVariables = []EnvironmentVariable{
 	EEnvironmentVariable.ManagedIdentityObjectID(),
 	EEnvironmentVariable.ManagedIdentityResourceString(),
 	EEnvironmentVariable.RequestTryTimeout(),
+	EEnvironmentVariable.RetryJitter(),
+	EEnvironmentVariable.ChunkRetries(),
 	EEnvironmentVariable.CPKEncryptionKey(),
 	EEnvironmentVariable.CPKEncryptionKeySHA256(),
 	EEnvironmentVariable.DisableSyslog(),
 	EEnvironmentVariable.MimeMapping(),
+	EEnvironmentVariable.RpcSecretFile(),
+	EEnvironmentVariable.SteURL(),
+	EEnvironmentVariable.SASToken(),
+	EEnvironmentVariable.SASTokenAccount(),
+	EEnvironmentVariable.ConnectionString(),
+	EEnvironmentVariable.StorageAccountName(),
+	EEnvironmentVariable.StorageAccountKey(),
 }
 
 var EEnvironmentVariable = EnvironmentVariable{}
@@ -118,6 +133,46 @@ func (EnvironmentVariable) ClientSecret() EnvironmentVariable {
 	}
 }
 
+func (EnvironmentVariable) SASToken() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_SAS_TOKEN",
+		Description: "SAS token to use for the destination of copy and sync, when the destination URL does not have one and is for the account in AZCOPY_SAS_TOKEN_ACCOUNT. The --sas-token-file flag takes precedence over this.",
+		Hidden:      true,
+	}
+}
+
+func (EnvironmentVariable) SASTokenAccount() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_SAS_TOKEN_ACCOUNT",
+		Description: "Name of the storage account whose SAS token is in AZCOPY_SAS_TOKEN.",
+		Hidden:      true,
+	}
+}
+
+func (EnvironmentVariable) StorageAccountName() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_ACCOUNT_NAME",
+		Description: "Name of the storage account whose key is in AZCOPY_ACCOUNT_KEY.",
+	}
+}
+
+func (EnvironmentVariable) StorageAccountKey() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_ACCOUNT_KEY",
+		Description: "Storage account key used to sign requests to Blob, File and ADLS Gen2 URLs that have no SAS, when they're for the account named in AZCOPY_ACCOUNT_NAME.",
+		Hidden:      true,
+	}
+}
+
+func (EnvironmentVariable) ConnectionString() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name: "AZCOPY_CONNECTION_STRING",
+		Description: "Storage account connection string, whose account key is used for Blob URLs on its endpoint that have no SAS. " +
+			"Set it to UseDevelopmentStorage=true to use the storage emulator (Azurite) at http://127.0.0.1:10000/devstoreaccount1.",
+		Hidden: true,
+	}
+}
+
 func (EnvironmentVariable) CertificatePath() EnvironmentVariable {
 	return EnvironmentVariable{
 		Name:        "AZCOPY_SPA_CERT_PATH",
@@ -170,6 +225,20 @@ func (EnvironmentVariable) AutoTuneToCpu() EnvironmentVariable {
 	}
 }
 
+func (EnvironmentVariable) AutoTuneMinConcurrency() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_CONCURRENCY_AUTO_MIN",
+		Description: "When concurrency is auto-tuned (i.e. AZCOPY_CONCURRENCY_VALUE is AUTO, or in the benchmark command), sets the lowest number of HTTP connections the tuner will use. Tuning starts from this value.",
+	}
+}
+
+func (EnvironmentVariable) AutoTuneMaxConcurrency() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_CONCURRENCY_AUTO_MAX",
+		Description: "When concurrency is auto-tuned (i.e. AZCOPY_CONCURRENCY_VALUE is AUTO, or in the benchmark command), sets the highest number of HTTP connections the tuner will try.",
+	}
+}
+
 func (EnvironmentVariable) TransferInitiationPoolSize() EnvironmentVariable {
 	return EnvironmentVariable{
 		Name:        "AZCOPY_CONCURRENT_FILES",
@@ -177,6 +246,27 @@ func (EnvironmentVariable) TransferInitiationPoolSize() EnvironmentVariable {
 	}
 }
 
+func (EnvironmentVariable) SmallFileThreshold() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_SMALL_FILE_THRESHOLD",
+		Description: "Local files of up to this many bytes are uploaded by a separate pool of workers, which read each file in one go and send it in a single request. This speeds up jobs made of many tiny files, e.g. with a value of 131072 (128 KiB). The pool's workers are in addition to AZCOPY_CONCURRENCY_VALUE. Off (0) by default.",
+	}
+}
+
+func (EnvironmentVariable) SmallFilePoolSize() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_SMALL_FILE_CONCURRENCY",
+		Description: "Overrides the number of small files (see AZCOPY_SMALL_FILE_THRESHOLD) that are read and sent at the same time.",
+	}
+}
+
+func (EnvironmentVariable) CommitPoolSize() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_COMMIT_CONCURRENCY",
+		Description: "Overrides the number of block blobs that can be committed (i.e. have their block lists put) at the same time. Commits are done by their own pool of workers, so that the workers sending blocks can move straight on to the next file. Set to 0 to commit on the worker that sent the last block.",
+	}
+}
+
 const azCopyConcurrentScan = "AZCOPY_CONCURRENT_SCAN"
 
 func (EnvironmentVariable) EnumerationPoolSize() EnvironmentVariable {
@@ -201,6 +291,14 @@ func (EnvironmentVariable) ParallelStatFiles() EnvironmentVariable {
 	}
 }
 
+func (EnvironmentVariable) InterleaveChunks() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:         "AZCOPY_INTERLEAVE_CHUNKS",
+		Description:  "Set to true to take turns between the files being transferred, when queuing their chunks to be sent. This stops one big file from holding up the others, and shows progress on all of them. Append blobs are always sent in order.",
+		DefaultValue: "false",
+	}
+}
+
 func (EnvironmentVariable) OptimizeSparsePageBlobTransfers() EnvironmentVariable {
 	return EnvironmentVariable{
 		Name:         "AZCOPY_OPTIMIZE_SPARSE_PAGE_BLOB",
@@ -337,6 +435,24 @@ func (EnvironmentVariable) RequestTryTimeout() EnvironmentVariable {
 	}
 }
 
+func (EnvironmentVariable) RetryJitter() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:         "AZCOPY_RETRY_JITTER",
+		DefaultValue: "0.5",
+		Description: "How much to randomize the delay before retrying a failed request, as a fraction between 0 and 1 of the delay. " +
+			"Randomizing stops requests that were throttled together from all retrying at the same moment. Set to 0 to turn it off.",
+	}
+}
+
+func (EnvironmentVariable) ChunkRetries() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:         "AZCOPY_CHUNK_RETRIES",
+		DefaultValue: "3",
+		Description: "How many more times to stage a block whose request failed, even after retrying, before failing the whole transfer. " +
+			"A one-off failure of a single block then costs a re-send of that block, rather than of the whole file. Set to 0 to turn it off.",
+	}
+}
+
 func (EnvironmentVariable) CPKEncryptionKey() EnvironmentVariable {
 	return EnvironmentVariable{Name: "CPK_ENCRYPTION_KEY", Hidden: true}
 }
@@ -361,3 +477,17 @@ func (EnvironmentVariable) MimeMapping() EnvironmentVariable {
 		Description:  "Location of the file to override default OS mime mapping",
 	}
 }
+
+func (EnvironmentVariable) SteURL() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_STE_URL",
+		Description: "URL (http or https) of a transfer engine, possibly on another host, to send jobs to instead of the one that runs inside AzCopy, and where 'azcopy serve' listens. The --ste-url flag takes precedence over this.",
+	}
+}
+
+func (EnvironmentVariable) RpcSecretFile() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_RPC_SECRET_FILE",
+		Description: "Path of a file holding the secret used to authenticate requests between the front end and the transfer engine. The file is created, readable only by the current user, if it does not exist. Authentication is off when this is not set.",
+	}
+}
@@ -38,6 +38,7 @@ import (
 	"github.com/Azure/azure-storage-blob-go/azblob"
 	"github.com/Azure/azure-storage-file-go/azfile"
 	"github.com/JeffreyRichter/enum/enum"
+	"golang.org/x/text/unicode/norm"
 )
 
 const (
@@ -206,6 +207,70 @@ func (dd DeleteDestination) String() string {
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
+// BlobNameNormalization says how the names of source files are normalized to make the names of the blobs they're copied to
+type BlobNameNormalization uint8
+
+var EBlobNameNormalization = BlobNameNormalization(0)
+
+func (BlobNameNormalization) None() BlobNameNormalization      { return BlobNameNormalization(0) }
+func (BlobNameNormalization) NFC() BlobNameNormalization       { return BlobNameNormalization(1) }
+func (BlobNameNormalization) Lowercase() BlobNameNormalization { return BlobNameNormalization(2) }
+
+func (n *BlobNameNormalization) Parse(s string) error {
+	// allow empty to mean "None"
+	if s == "" {
+		*n = EBlobNameNormalization.None()
+		return nil
+	}
+
+	val, err := enum.Parse(reflect.TypeOf(n), s, true)
+	if err == nil {
+		*n = val.(BlobNameNormalization)
+	}
+	return err
+}
+
+func (n BlobNameNormalization) String() string {
+	return enum.StringInt(n, reflect.TypeOf(n))
+}
+
+// Normalize returns name in Unicode normalization form C, so that e.g. "e" followed by a combining acute accent and a
+// precomposed "é" give the same name. Lowercase also lowercases the name after that.
+func (n BlobNameNormalization) Normalize(name string) string {
+	switch n {
+	case EBlobNameNormalization.NFC():
+		return norm.NFC.String(name)
+	case EBlobNameNormalization.Lowercase():
+		return strings.ToLower(norm.NFC.String(name))
+	default:
+		return name
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// SyncHashType says which hash, if any, sync compares to decide whether a file has changed
+type SyncHashType uint8
+
+var ESyncHashType = SyncHashType(0)
+
+func (SyncHashType) None() SyncHashType { return SyncHashType(0) }
+func (SyncHashType) MD5() SyncHashType  { return SyncHashType(1) }
+
+func (ht *SyncHashType) Parse(s string) error {
+	val, err := enum.Parse(reflect.TypeOf(ht), s, true)
+	if err == nil {
+		*ht = val.(SyncHashType)
+	}
+	return err
+}
+
+func (ht SyncHashType) String() string {
+	return enum.StringInt(ht, reflect.TypeOf(ht))
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
 // represents one possible response
 var EResponseOption = ResponseOption{ResponseType: "", UserFriendlyResponseType: "", ResponseString: ""}
 
@@ -228,6 +293,9 @@ func (ResponseOption) YesForAll() ResponseOption {
 func (ResponseOption) NoForAll() ResponseOption {
 	return ResponseOption{ResponseType: "NoForAll", UserFriendlyResponseType: "No for all", ResponseString: "l"}
 }
+func (ResponseOption) Quit() ResponseOption {
+	return ResponseOption{ResponseType: "Quit", UserFriendlyResponseType: "Quit (cancel the job)", ResponseString: "q"}
+}
 func (ResponseOption) Default() ResponseOption {
 	return ResponseOption{ResponseType: "", UserFriendlyResponseType: "", ResponseString: ""}
 }
@@ -285,15 +353,48 @@ func (of OutputFormat) String() string {
 	return enum.StringInt(of, reflect.TypeOf(of))
 }
 
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+var ELogFormat = LogFormat(0)
+
+// LogFormat is the format of each line in the log files
+type LogFormat uint8
+
+func (LogFormat) Text() LogFormat { return LogFormat(0) }
+func (LogFormat) Json() LogFormat { return LogFormat(1) } // one JSON object per line
+
+func (lf *LogFormat) Parse(s string) error {
+	val, err := enum.Parse(reflect.TypeOf(lf), s, true)
+	if err == nil {
+		*lf = val.(LogFormat)
+	}
+	return err
+}
+
+func (lf LogFormat) String() string {
+	return enum.StringInt(lf, reflect.TypeOf(lf))
+}
+
 var EExitCode = ExitCode(0)
 
+// ExitCode is what the process exits with. For a job, it says how the job went, so that scripts can tell a partial
+// failure from a total one:
+//
+//	0 all transfers succeeded (or were skipped)
+//	1 some transfers failed, or a general error
+//	2 transfers failed, and none succeeded
+//	3 the job was cancelled
+//	4 the command line was not valid
 type ExitCode uint32
 
-func (ExitCode) Success() ExitCode { return ExitCode(0) }
-func (ExitCode) Error() ExitCode   { return ExitCode(1) }
+func (ExitCode) Success() ExitCode      { return ExitCode(0) }
+func (ExitCode) Error() ExitCode        { return ExitCode(1) }
+func (ExitCode) AllFailed() ExitCode    { return ExitCode(2) }
+func (ExitCode) Cancelled() ExitCode    { return ExitCode(3) }
+func (ExitCode) InvalidUsage() ExitCode { return ExitCode(4) }
 
 // note: if AzCopy exits due to a panic, we don't directly control what the exit code will be. The Go runtime seems to be
-// hard-coded to give an exit code of 2 in that case, but there is discussion of changing it to 1, so it may become
+// hard-coded to give an exit code of 2 in that case, which is the same as EExitCode.AllFailed, but there is discussion of changing it to 1, so it may become
 // impossible to tell from exit code alone whether AzCopy panic or return EExitCode.Error.
 // See https://groups.google.com/forum/#!topic/golang-nuts/u9NgKibJsKI
 // However, fortunately, in the panic case, stderr will get the panic message;
@@ -318,6 +419,15 @@ func (ll *LogLevel) Parse(s string) error {
 	val, err := enum.ParseInt(reflect.TypeOf(ll), s, true, true)
 	if err == nil {
 		*ll = val.(LogLevel)
+		return nil
+	}
+
+	// also accept the abbreviations that String() produces, e.g. WARN, as they are what users see in the logs
+	for _, l := range []LogLevel{ELogLevel.Error(), ELogLevel.Warning(), ELogLevel.Debug()} {
+		if strings.EqualFold(s, l.String()) {
+			*ll = l
+			return nil
+		}
 	}
 	return err
 }
@@ -941,6 +1051,7 @@ func (i *InvalidMetadataHandleOption) UnmarshalJSON(b []byte) error {
 const (
 	DefaultBlockBlobBlockSize      = 8 * 1024 * 1024
 	MaxBlockBlobBlockSize          = 4000 * 1024 * 1024
+	MaxPutBlobSize                 = 5000 * 1024 * 1024 // the largest block blob that can be uploaded with a single Put Blob
 	MaxAppendBlobBlockSize         = 4 * 1024 * 1024
 	DefaultPageBlobChunkSize       = 4 * 1024 * 1024
 	DefaultAzureFileChunkSize      = 4 * 1024 * 1024
@@ -1025,6 +1136,40 @@ func UnMarshalToCommonMetadata(metadataString string) (Metadata, error) {
 	return result, nil
 }
 
+// ParseMetadata parses a semicolon-separated list of key=value pairs, e.g. "env=prod;team=data", as given to --metadata.
+// Only the first '=' of a pair separates the key from the value, so values may contain '='.
+// Keys must be valid Azure metadata keys, and since Azure treats keys case-insensitively, none may be given twice.
+func ParseMetadata(s string) (Metadata, error) {
+	m := Metadata{}
+	if strings.TrimSpace(s) == "" {
+		return m, nil
+	}
+
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(s, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue // tolerate a trailing semicolon
+		}
+
+		kv := strings.SplitN(entry, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid metadata '%s', expected the form key=value", entry)
+		}
+		key := strings.TrimSpace(kv[0])
+		if key == "" || !isValidMetadataKey(key) {
+			return nil, fmt.Errorf("invalid metadata key '%s', keys must start with a letter or underscore, and contain only letters, digits and underscores", key)
+		}
+		if seen[strings.ToLower(key)] {
+			return nil, fmt.Errorf("metadata key '%s' is given more than once (keys are case-insensitive)", key)
+		}
+		seen[strings.ToLower(key)] = true
+
+		m[key] = kv[1]
+	}
+
+	return m, nil
+}
+
 // isValidMetadataKey checks if the given string is a valid metadata key for Azure.
 // For Azure, metadata key must adhere to the naming rules for C# identifiers.
 // As testing, reserved keywords for C# identifiers are also valid metadata key. (e.g. this, int)
@@ -1104,7 +1249,10 @@ func ToCommonBlobTagsMap(blobTagsString string) BlobTags {
 
 	blobTagsMap := BlobTags{}
 	for _, keyAndValue := range strings.Split(blobTagsString, "&") { // key/value pairs are separated by '&'
-		kv := strings.Split(keyAndValue, "=") // key/value are separated by '='
+		kv := strings.SplitN(keyAndValue, "=", 2) // key/value are separated by the first '=', since values may contain more of them
+		if len(kv) < 2 {
+			kv = append(kv, "") // the front end rejects tags without a value, so this can only be an empty one
+		}
 		blobTagsMap[kv[0]] = kv[1]
 	}
 	return blobTagsMap
@@ -1155,6 +1303,39 @@ func (m Metadata) ResolveInvalidKey() (resolvedMetadata Metadata, err error) {
 	return resolvedMetadata, nil
 }
 
+// LastModifiedTimeMetadataKey is the metadata key under which uploads with --preserve-last-modified-time record
+// the modification time of the source file, since Blob Storage has no property of its own to hold it.
+const LastModifiedTimeMetadataKey = "azcopy_last_modified_time"
+
+// WithLastModifiedTime returns a copy of the metadata that also records the given modification time, in UTC.
+func (m Metadata) WithLastModifiedTime(t time.Time) Metadata {
+	result := make(Metadata, len(m)+1)
+	for k, v := range m {
+		if strings.EqualFold(k, LastModifiedTimeMetadataKey) {
+			continue // the recorded time wins over any value the user gave for the key, in whatever case
+		}
+		result[k] = v
+	}
+	result[LastModifiedTimeMetadataKey] = t.UTC().Format(time.RFC3339Nano)
+	return result
+}
+
+// LastModifiedTime returns the modification time recorded by WithLastModifiedTime, if the metadata holds a valid one.
+// The key is matched case-insensitively, since services do not all preserve the case of metadata keys.
+func (m Metadata) LastModifiedTime() (time.Time, bool) {
+	for k, v := range m {
+		if !strings.EqualFold(k, LastModifiedTimeMetadataKey) {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return t.UTC(), true
+	}
+	return time.Time{}, false
+}
+
 func (m Metadata) ConcatenatedKeys() string {
 	buf := bytes.Buffer{}
 
@@ -1217,6 +1398,50 @@ func (h ResourceHTTPHeaders) ToBlobFSHTTPHeaders() azbfs.BlobFSHTTPHeaders {
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
+// ContentTypeMap holds user-specified content types, keyed by lower-case file extension (including the leading dot).
+type ContentTypeMap map[string]string
+
+// ParseContentTypeMap parses a comma-separated list of extension=content-type pairs,
+// e.g. ".md=text/markdown,.wasm=application/wasm".
+// If the same extension is listed more than once, the later entry wins.
+func ParseContentTypeMap(s string) (ContentTypeMap, error) {
+	m := ContentTypeMap{}
+	if strings.TrimSpace(s) == "" {
+		return m, nil
+	}
+
+	for _, entry := range strings.Split(s, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue // tolerate a trailing comma
+		}
+
+		kv := strings.SplitN(entry, "=", 2) // the content type itself may contain '=', e.g. in a charset parameter
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid content type mapping '%s', expected the form .ext=content/type", entry)
+		}
+		ext := strings.ToLower(strings.TrimSpace(kv[0]))
+		contentType := strings.TrimSpace(kv[1])
+		if ext == "" || ext == "." || contentType == "" {
+			return nil, fmt.Errorf("invalid content type mapping '%s', both the extension and the content type must be given", entry)
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+
+		m[ext] = contentType
+	}
+
+	return m, nil
+}
+
+// ContentTypeFor returns the content type mapped to the given extension, if there is one.
+func (m ContentTypeMap) ContentTypeFor(fileExtension string) (string, bool) {
+	contentType, ok := m[strings.ToLower(fileExtension)]
+	return contentType, ok
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
 var ETransferDirection = TransferDirection(0)
 
 type TransferDirection int32
@@ -21,6 +21,8 @@
 package common_test
 
 import (
+	"time"
+
 	"github.com/Azure/azure-storage-azcopy/v10/common"
 	chk "gopkg.in/check.v1"
 )
@@ -173,3 +175,121 @@ func (s *feSteModelsTestSuite) TestMetadataResolveInvalidKeyNegative(c *chk.C) {
 	_, err = mNegative3.ResolveInvalidKey()
 	c.Assert(err, chk.NotNil)
 }
+
+func (s *feSteModelsTestSuite) TestLogLevelParse(c *chk.C) {
+	expected := map[string]common.LogLevel{
+		"DEBUG":   common.ELogLevel.Debug(),
+		"dbg":     common.ELogLevel.Debug(),
+		"INFO":    common.ELogLevel.Info(),
+		"warning": common.ELogLevel.Warning(),
+		"WARN":    common.ELogLevel.Warning(),
+		"ERROR":   common.ELogLevel.Error(),
+		"ERR":     common.ELogLevel.Error(),
+		"NONE":    common.ELogLevel.None(),
+	}
+	for s, want := range expected {
+		var ll common.LogLevel
+		c.Assert(ll.Parse(s), chk.IsNil, chk.Commentf("parsing %s", s))
+		c.Assert(ll, chk.Equals, want)
+	}
+
+	var ll common.LogLevel
+	c.Assert(ll.Parse("VERBOSE"), chk.NotNil)
+}
+
+func (s *feSteModelsTestSuite) TestMetadataLastModifiedTime(c *chk.C) {
+	original := common.Metadata{"owner": "alice"}
+	zone := time.FixedZone("UTC+9", 9*60*60)
+	mtime := time.Date(2021, 3, 4, 5, 6, 7, 890000000, zone)
+
+	stamped := original.WithLastModifiedTime(mtime)
+	c.Assert(stamped["owner"], chk.Equals, "alice")
+	c.Assert(stamped[common.LastModifiedTimeMetadataKey], chk.Equals, "2021-03-03T20:06:07.89Z") // normalized to UTC
+	c.Assert(original, chk.HasLen, 1)                                                            // the original is left alone
+
+	recorded, ok := stamped.LastModifiedTime()
+	c.Assert(ok, chk.Equals, true)
+	c.Assert(recorded.Equal(mtime), chk.Equals, true)
+	c.Assert(recorded.Location(), chk.Equals, time.UTC)
+
+	// keys may come back from the service in a different case
+	recorded, ok = common.Metadata{"Azcopy_Last_Modified_Time": "2021-03-03T20:06:07Z"}.LastModifiedTime()
+	c.Assert(ok, chk.Equals, true)
+	c.Assert(recorded.Equal(mtime.Truncate(time.Second)), chk.Equals, true)
+
+	_, ok = common.Metadata{"owner": "alice"}.LastModifiedTime()
+	c.Assert(ok, chk.Equals, false)
+	_, ok = common.Metadata{common.LastModifiedTimeMetadataKey: "yesterday"}.LastModifiedTime()
+	c.Assert(ok, chk.Equals, false)
+}
+
+func (s *feSteModelsTestSuite) TestParseContentTypeMap(c *chk.C) {
+	m, err := common.ParseContentTypeMap(".md=text/markdown, .WASM=application/wasm,txt=text/plain; charset=utf-8,")
+	c.Assert(err, chk.IsNil)
+	validateMapEqual(c, m, map[string]string{
+		".md":   "text/markdown",
+		".wasm": "application/wasm",          // extensions are case-insensitive
+		".txt":  "text/plain; charset=utf-8", // the leading dot is optional, and only the first '=' separates
+	})
+
+	// later entries for the same extension win
+	m, err = common.ParseContentTypeMap(".md=text/plain,.MD=text/markdown")
+	c.Assert(err, chk.IsNil)
+	validateMapEqual(c, m, map[string]string{".md": "text/markdown"})
+
+	contentType, ok := m.ContentTypeFor(".Md")
+	c.Assert(ok, chk.Equals, true)
+	c.Assert(contentType, chk.Equals, "text/markdown")
+	_, ok = m.ContentTypeFor(".txt")
+	c.Assert(ok, chk.Equals, false)
+
+	m, err = common.ParseContentTypeMap("")
+	c.Assert(err, chk.IsNil)
+	c.Assert(m, chk.HasLen, 0)
+}
+
+func (s *feSteModelsTestSuite) TestParseContentTypeMapNegative(c *chk.C) {
+	for _, malformed := range []string{
+		".md",                     // missing '='
+		".md=text/markdown,.wasm", // one good entry does not excuse a bad one
+		"=text/plain",             // missing extension
+		".=text/plain",            // extension is just the dot
+		".md=",                    // missing content type
+	} {
+		_, err := common.ParseContentTypeMap(malformed)
+		c.Assert(err, chk.NotNil, chk.Commentf("input %q", malformed))
+	}
+}
+
+func (s *feSteModelsTestSuite) TestParseMetadata(c *chk.C) {
+	m, err := common.ParseMetadata("env=prod; team=data;query=a=b;empty=;")
+	c.Assert(err, chk.IsNil)
+	validateMapEqual(c, m, map[string]string{
+		"env":   "prod",
+		"team":  "data", // space around the key is ignored
+		"query": "a=b",  // only the first '=' separates
+		"empty": "",
+	})
+
+	m, err = common.ParseMetadata("")
+	c.Assert(err, chk.IsNil)
+	c.Assert(m, chk.HasLen, 0)
+}
+
+func (s *feSteModelsTestSuite) TestParseMetadataNegative(c *chk.C) {
+	for _, malformed := range []string{
+		"env",              // missing '='
+		"env=prod;team",    // one good entry does not excuse a bad one
+		"=prod",            // missing key
+		"1env=prod",        // keys can't start with a digit
+		"my-key=value",     // or contain anything but letters, digits and underscores
+		"my key=value",     // including spaces
+		"env=prod;ENV=dev", // keys are case-insensitive, so this is a duplicate
+	} {
+		_, err := common.ParseMetadata(malformed)
+		c.Assert(err, chk.NotNil, chk.Commentf("input %q", malformed))
+	}
+
+	_, err := common.ParseMetadata("env=prod;my-key=value")
+	c.Assert(err, chk.ErrorMatches, "invalid metadata key 'my-key'.*")
+}
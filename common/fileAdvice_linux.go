@@ -0,0 +1,45 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// fadvise and madvise are variables so that tests can see what advice is given
+var fadvise = unix.Fadvise
+var madvise = syscall.Madvise
+
+// AdviseSequentialRead tells the kernel that the file will be read from start to end, so that it reads further ahead.
+// Like all advice, it is only a hint, so failing to give it is not an error.
+func AdviseSequentialRead(file *os.File) {
+	_ = fadvise(int(file.Fd()), 0, 0, unix.FADV_SEQUENTIAL)
+}
+
+// adviseSequentialMapping tells the kernel that the mapped memory will be read from start to end, and soon.
+// The advice values aren't flags, so each is given separately.
+func adviseSequentialMapping(mapping []byte) {
+	_ = madvise(mapping, syscall.MADV_SEQUENTIAL)
+	_ = madvise(mapping, syscall.MADV_WILLNEED)
+}
@@ -3,6 +3,7 @@ package common
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"runtime"
@@ -10,6 +11,7 @@ import (
 	"strconv"
 	"strings"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/Azure/azure-pipeline-go/pipeline"
@@ -26,9 +28,13 @@ var lcm = func() (lcmgr *lifecycleMgr) {
 		outputFormat:         EOutputFormat.Text(), // output text by default
 		logSanitizer:         NewAzCopyLogSanitizer(),
 		inputQueue:           make(chan userInput, 1000),
+		inputClosed:          make(chan struct{}),
 		allowCancelFromStdIn: false,
 		allowWatchInput:      false,
 		closeFunc:            func() {}, // noop since we have nothing to do by default
+		stdout:               os.Stdout,
+		stderr:               os.Stderr,
+		stdin:                os.Stdin,
 	}
 
 	// kick off the single routine that processes output
@@ -52,6 +58,7 @@ type LifecycleMgr interface {
 	Info(string)                                                 // simple print, allowed to float up
 	Dryrun(OutputBuilder)                                        // print files for dry run mode
 	Error(string)                                                // indicates fatal error, exit after printing, exit code is always Failed (1)
+	ExitWithError(string, ExitCode)                              // like Error, but with the given exit code
 	Prompt(message string, details PromptDetails) ResponseOption // ask the user a question(after erasing the progress), then return the response
 	SurrenderControl()                                           // give up control, this should never return
 	InitiateProgressReporting(WorkController)                    // start writing progress with another routine
@@ -59,6 +66,9 @@ type LifecycleMgr interface {
 	GetEnvironmentVariable(EnvironmentVariable) string           // get the environment variable or its default value
 	ClearEnvironmentVariable(EnvironmentVariable)                // clears the environment variable
 	SetOutputFormat(OutputFormat)                                // change the output format of the entire application
+	SetQuiet(bool)                                               // print only errors, though the process still exits as it would have
+	SetLogFormat(LogFormat)                                      // change the format of the log files opened from now on
+	GetLogFormat() LogFormat                                     // the format that log files opened from now on will have
 	EnableInputWatcher()                                         // depending on the command, we may allow user to give input through Stdin
 	EnableCancelFromStdIn()                                      // allow user to send in `cancel` to stop the job
 	AddUserAgentPrefix(string) string                            // append the global user agent prefix, if applicable
@@ -83,14 +93,20 @@ type lifecycleMgr struct {
 	e2eAllowOpenChannel   chan struct{}
 	waitEverCalled        int32
 	outputFormat          OutputFormat
+	quiet                 bool // print nothing but errors (and prompts, which need an answer)
+	logFormat             LogFormat
 	logSanitizer          pipeline.LogSanitizer
 	inputQueue            chan userInput // msgs from the user
+	inputClosed           chan struct{}  // closed once stdin has nothing more to give, so that nobody waits on it forever
 	allowWatchInput       bool           // accept user inputs and place then in the inputQueue
 	allowCancelFromStdIn  bool           // allow user to send in 'cancel' from the stdin to stop the current job
 	e2eAllowAwaitContinue bool           // allow the user to send 'continue' from stdin to start the current job
 	e2eAllowAwaitOpen     bool           // allow the user to send 'open' from stdin to allow the opening of the first file
 	closeFunc             func()         // used to close logs before exiting
 	disableSyslog         bool
+	stdout                io.Writer // where results go
+	stderr                io.Writer // where JSON progress goes, so that it doesn't get mixed up with the results
+	stdin                 io.Reader // where the user's answers come from
 }
 
 type userInput struct {
@@ -100,7 +116,7 @@ type userInput struct {
 
 // should be started in a single go routine
 func (lcm *lifecycleMgr) watchInputs() {
-	consoleReader := bufio.NewReader(os.Stdin)
+	consoleReader := bufio.NewReader(lcm.stdin)
 	for {
 		// sleep for a bit, the option might be enabled later
 		if !lcm.allowWatchInput {
@@ -111,7 +127,11 @@ func (lcm *lifecycleMgr) watchInputs() {
 		// reads input until the first occurrence of \n in the input,
 		input, err := consoleReader.ReadString('\n')
 		timeReceived := time.Now()
-		if err != nil {
+		if err == io.EOF && input == "" {
+			// there will never be another answer, stop anyone from waiting for one
+			close(lcm.inputClosed)
+			return
+		} else if err != nil && err != io.EOF {
 			continue
 		}
 
@@ -135,7 +155,18 @@ func (lcm *lifecycleMgr) watchInputs() {
 // NOTE: to ask a question, go through Prompt, to guarantee that only 1 question is asked at a time
 func (lcm *lifecycleMgr) getInputAfterTime(time time.Time) string {
 	for {
-		msg := <-lcm.inputQueue
+		var msg userInput
+		select {
+		case msg = <-lcm.inputQueue:
+		case <-lcm.inputClosed:
+			// stdin has ended, but the answer may already have been queued before it did
+			select {
+			case msg = <-lcm.inputQueue:
+			default:
+				// no answer is coming, the caller falls back to its default
+				return ""
+			}
+		}
 
 		// keep reading until we find an input that came in after the user specified time
 		if msg.timeReceived.After(time) {
@@ -162,6 +193,18 @@ func (lcm *lifecycleMgr) SetOutputFormat(format OutputFormat) {
 	lcm.outputFormat = format
 }
 
+func (lcm *lifecycleMgr) SetQuiet(quiet bool) {
+	lcm.quiet = quiet
+}
+
+func (lcm *lifecycleMgr) SetLogFormat(format LogFormat) {
+	lcm.logFormat = format
+}
+
+func (lcm *lifecycleMgr) GetLogFormat() LogFormat {
+	return lcm.logFormat
+}
+
 func (lcm *lifecycleMgr) checkAndStartCPUProfiling() {
 	// CPU Profiling add-on. Set AZCOPY_PROFILE_CPU to enable CPU profiling,
 	// the value AZCOPY_PROFILE_CPU indicates the path to save CPU profiling data.
@@ -279,6 +322,10 @@ func (lcm *lifecycleMgr) Dryrun(o OutputBuilder) {
 
 // TODO minor: consider merging with Exit
 func (lcm *lifecycleMgr) Error(msg string) {
+	lcm.ExitWithError(msg, EExitCode.Error())
+}
+
+func (lcm *lifecycleMgr) ExitWithError(msg string, exitCode ExitCode) {
 
 	msg = lcm.logSanitizer.SanitizeLogMessage(msg)
 
@@ -291,7 +338,7 @@ func (lcm *lifecycleMgr) Error(msg string) {
 	lcm.msgQueue <- outputMessage{
 		msgContent: msg,
 		msgType:    eOutputMessageType.Error(),
-		exitCode:   EExitCode.Error(),
+		exitCode:   exitCode,
 	}
 
 	// stall forever until the success message is printed and program exits
@@ -340,6 +387,12 @@ func (lcm *lifecycleMgr) processOutputMessage() {
 	for {
 		msgToPrint := <-lcm.msgQueue
 
+		if lcm.quiet && msgToPrint.msgType != eOutputMessageType.Error() && msgToPrint.msgType != eOutputMessageType.Prompt() {
+			// print nothing, but still exit (with the right code) at the end of the job
+			lcm.processNoneOutput(msgToPrint)
+			continue
+		}
+
 		switch lcm.outputFormat {
 		case EOutputFormat.Json():
 			lcm.processJSONOutput(msgToPrint)
@@ -354,10 +407,7 @@ func (lcm *lifecycleMgr) processOutputMessage() {
 }
 
 func (lcm *lifecycleMgr) processNoneOutput(msgToOutput outputMessage) {
-	if msgToOutput.msgType == eOutputMessageType.Error() {
-		lcm.closeFunc()
-		os.Exit(int(EExitCode.Error()))
-	} else if msgToOutput.shouldExitProcess() {
+	if msgToOutput.shouldExitProcess() {
 		lcm.closeFunc()
 		os.Exit(int(msgToOutput.exitCode))
 	}
@@ -370,9 +420,16 @@ func (lcm *lifecycleMgr) processJSONOutput(msgToOutput outputMessage) {
 	msgType := msgToOutput.msgType
 	questionTime := time.Now()
 
+	// progress goes to stderr, so that what's on stdout can be piped to another program (e.g. azcopy ls --output-type=json | jq)
+	// while the progress is still shown
+	out := lcm.stdout
+	if msgType == eOutputMessageType.Progress() {
+		out = lcm.stderr
+	}
+
 	// simply output the json message
 	// we assume the msgContent is already formatted correctly
-	fmt.Println(GetJsonStringFromTemplate(newJsonOutputTemplate(msgType, msgToOutput.msgContent,
+	fmt.Fprintln(out, GetJsonStringFromTemplate(newJsonOutputTemplate(msgType, msgToOutput.msgContent,
 		msgToOutput.promptDetails)))
 
 	// exit if needed
@@ -450,7 +507,7 @@ func (lcm *lifecycleMgr) processTextOutput(msgToOutput outputMessage) {
 			fmt.Print(msgToOutput.msgContent)
 		}
 
-		// example output: Please confirm with: [Y] Yes  [N] No  [A] Yes for all  [L] No for all
+		// example output: Please confirm with: [Y] Yes  [N] No  [A] Yes for all  [L] No for all  [Q] Quit (cancel the job)
 		fmt.Print(" Please confirm with:")
 		for _, option := range msgToOutput.promptDetails.ResponseOptions {
 			fmt.Printf(" [%s] %s ", strings.ToUpper(option.ResponseString), option.UserFriendlyResponseType)
@@ -467,6 +524,12 @@ type WorkController interface {
 	ReportProgressOrExit(mgr LifecycleMgr) (totalKnownCount uint32) // print the progress status, optionally exit the application if work is done
 }
 
+// PausableWorkController is implemented by work that can be paused, to be resumed later by another process
+type PausableWorkController interface {
+	WorkController
+	Pause(mgr LifecycleMgr) // handle to pause the work
+}
+
 // AllowReinitiateProgressReporting must be called before running an cleanup job, to allow the initiation of that job's
 // progress reporting to begin
 func (lcm *lifecycleMgr) AllowReinitiateProgressReporting() {
@@ -485,21 +548,20 @@ func (lcm *lifecycleMgr) InitiateProgressReporting(jc WorkController) {
 		const progressFrequencyThreshold = 1000000
 		var oldCount, newCount uint32
 
-		// cancelChannel will be notified when os receives os.Interrupt and os.Kill signals
-		signal.Notify(lcm.cancelChannel, os.Interrupt, os.Kill)
+		// cancelChannel will be notified when os receives os.Interrupt, SIGTERM and os.Kill signals
+		signal.Notify(lcm.cancelChannel, os.Interrupt, syscall.SIGTERM, os.Kill)
 
 		cancelCalled := false
 
-		doCancel := func() {
+		doCancel := func(sig os.Signal) {
 			cancelCalled = true
-			lcm.Info("Cancellation requested. Beginning clean shutdown...")
-			jc.Cancel(lcm)
+			lcm.stopWork(jc, sig)
 		}
 
 		for {
 			select {
-			case <-lcm.cancelChannel:
-				doCancel()
+			case sig := <-lcm.cancelChannel:
+				doCancel(sig)
 				continue // to exit on next pass through loop
 			default:
 				newCount = jc.ReportProgressOrExit(lcm)
@@ -517,8 +579,8 @@ func (lcm *lifecycleMgr) InitiateProgressReporting(jc WorkController) {
 
 			// wait a bit before fetching job status again, as fetching has costs associated with it on the backend
 			select {
-			case <-lcm.cancelChannel:
-				doCancel()
+			case sig := <-lcm.cancelChannel:
+				doCancel(sig)
 			case <-time.After(wait):
 			}
 
@@ -527,6 +589,19 @@ func (lcm *lifecycleMgr) InitiateProgressReporting(jc WorkController) {
 	}()
 }
 
+// stopWork reacts to a signal to stop. SIGTERM, which is what e.g. a container orchestrator sends before killing us,
+// pauses the work where possible, so that it can be resumed later. Anything else cancels it.
+func (lcm *lifecycleMgr) stopWork(jc WorkController, sig os.Signal) {
+	if pausable, ok := jc.(PausableWorkController); ok && sig == syscall.SIGTERM {
+		lcm.Info("Termination requested. Pausing the job so that it can be resumed later...")
+		pausable.Pause(lcm)
+		return
+	}
+
+	lcm.Info("Cancellation requested. Beginning clean shutdown...")
+	jc.Cancel(lcm)
+}
+
 func (lcm *lifecycleMgr) GetEnvironmentVariable(env EnvironmentVariable) string {
 	value := os.Getenv(env.Name)
 	if value == "" {
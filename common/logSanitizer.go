@@ -49,11 +49,18 @@ var sensitiveQueryStringKeys = []string{
 	"token",     // seems worth removing in case something uses it one day (e.g. if we support a new backend)
 }
 
+// sensitiveHeaderKey matches the names of headers whose whole value is a secret. azure-pipeline-go redacts the Authorization
+// header when it logs a request, but not headers that merely end in "authorization", such as x-ms-copy-source-authorization.
+// Unlike query string values, header values may contain spaces (e.g. "Bearer xyz"), so they are redacted up to the end of the line.
+const sensitiveHeaderKey = "authorization"
+
+var sensitiveHeaderRegex = regexp.MustCompile("(?i)(?P<key>" + sensitiveHeaderKey + "[ \t]*:[ \t]*)(?P<value>[^\r\n]+)")
+
 // SanitizeLogLine removes credentials and credential-like strings that are expected to exist
 // in material logged by this application.
-// Note: it does not remove whole headers. Specifically, it does not remove the entire value of
-// Authorization headers.  Those are assumed to be removed by azure-pipeline-go.
-// It does however remove signatures of the type found in SAS tokens and AWS presigned URLs,
+// Note: it does not remove whole headers, but it does remove the entire value of any header
+// whose name ends in Authorization (see sensitiveHeaderKey).
+// It also removes signatures of the type found in SAS tokens and AWS presigned URLs,
 // plus several other things.
 // The implementation uses a 'to lower' of the raw string, because the alternative (of
 // using case-insensitive regexs) was surprisingly measured as 36 times slower in testing.
@@ -68,21 +75,24 @@ func (s *azCopyLogSanitizer) SanitizeLogMessage(msg string) string {
 		}
 	}
 
+	if strings.Contains(lowerMsg, sensitiveHeaderKey) {
+		msg = sensitiveHeaderRegex.ReplaceAllString(msg, "$1"+redactedValue)
+	}
+
 	return msg
 }
 
 func (s *azCopyLogSanitizer) redact(msg, key string) string {
-
-	// The leading and trailing '-' chars are not in our older redaction code (which will continue to run,
-	// at the point of logging requests etc.)
-	// By including the dashes here we can examine logs (if we ever want to)
-	// to see how many redactions are from here (with the -'s) and how many
-	// are from the old code (without -'s).
-	const redacted = "-REDACTED-"
-
-	return sensitiveRegexMap[key].ReplaceAllString(msg, "$1"+redacted)
+	return sensitiveRegexMap[key].ReplaceAllString(msg, "$1"+redactedValue)
 }
 
+// The leading and trailing '-' chars are not in our older redaction code (which will continue to run,
+// at the point of logging requests etc.)
+// By including the dashes here we can examine logs (if we ever want to)
+// to see how many redactions are from here (with the -'s) and how many
+// are from the old code (without -'s).
+const redactedValue = "-REDACTED-"
+
 // as per https://groups.google.com/forum/#!topic/golang-nuts/3FVAs9dPR8k, this map should be
 // safe for concurrent reads
 var sensitiveRegexMap = make(map[string]*regexp.Regexp)
@@ -21,11 +21,14 @@
 package common
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/url"
 	"os"
 	"path"
+	"regexp"
 	"runtime"
 	"strings"
 	"time"
@@ -113,6 +116,7 @@ type jobLogger struct {
 	appLogger         ILogger
 	sanitizer         pipeline.LogSanitizer
 	logFileNameSuffix string // Used to allow more than 1 log per job, ex: front-end and back-end logs should be separate
+	format            LogFormat
 }
 
 func NewJobLogger(jobID JobID, minimumLevelToLog LogLevel, logFileFolder string, logFileNameSuffix string) ILoggerResetable {
@@ -122,6 +126,7 @@ func NewJobLogger(jobID JobID, minimumLevelToLog LogLevel, logFileFolder string,
 		logFileFolder:     logFileFolder,
 		sanitizer:         NewAzCopyLogSanitizer(),
 		logFileNameSuffix: logFileNameSuffix,
+		format:            GetLifecycleMgr().GetLogFormat(),
 	}
 }
 
@@ -137,15 +142,73 @@ func (jl *jobLogger) OpenLog() {
 	jl.file = file
 
 	flags := log.LstdFlags | log.LUTC
+	if jl.format == ELogFormat.Json() {
+		flags = 0 // the time is a field of each entry
+	}
 	utcMessage := fmt.Sprintf("Log times are in UTC. Local time is " + time.Now().Format("2 Jan 2006 15:04:05"))
 
 	jl.logger = log.New(jl.file, "", flags)
 	// Log the Azcopy Version
-	jl.logger.Println("AzcopyVersion ", AzcopyVersion)
+	jl.writeLine(pipeline.LogInfo, "AzcopyVersion  "+AzcopyVersion)
 	// Log the OS Environment and OS Architecture
-	jl.logger.Println("OS-Environment ", runtime.GOOS)
-	jl.logger.Println("OS-Architecture ", runtime.GOARCH)
-	jl.logger.Println(utcMessage)
+	jl.writeLine(pipeline.LogInfo, "OS-Environment  "+runtime.GOOS)
+	jl.writeLine(pipeline.LogInfo, "OS-Architecture  "+runtime.GOARCH)
+	jl.writeLine(pipeline.LogInfo, utcMessage)
+}
+
+// jsonLogEntry is one line of a log written with ELogFormat.Json()
+type jsonLogEntry struct {
+	Time       string `json:"time"`
+	Level      string `json:"level"`
+	JobID      string `json:"jobID"`
+	TransferID string `json:"transferID,omitempty"`
+	ChunkID    string `json:"chunkID,omitempty"`
+	WorkerID   string `json:"workerID,omitempty"`
+	Message    string `json:"message"`
+}
+
+// matches the "[P#0-T#12] " that transfer-specific messages start with, after their level
+var transferIDPrefix = regexp.MustCompile(`^\[(P#\d+-T#\d+)\] `)
+
+// matches the prefix made by ChunkLogPrefix, which chunk-specific messages have after their transfer's
+var chunkIDPrefix = regexp.MustCompile(`^\[(C#\d+)-(W#\d+)\] `)
+
+// ChunkLogPrefix starts a message about a chunk, naming the chunk (by its offset in the file) and the worker
+// processing it, e.g. "[C#8388608-W#3] ". In a JSON log, they become the chunkID and workerID fields.
+func ChunkLogPrefix(id ChunkID, workerID int) string {
+	return fmt.Sprintf("[C#%d-W#%d] ", id.OffsetInFile(), workerID)
+}
+
+// writeLine writes msg in the log's format
+func (jl jobLogger) writeLine(level pipeline.LogLevel, msg string) {
+	if jl.format != ELogFormat.Json() {
+		jl.logger.Println(msg)
+		return
+	}
+
+	entry := jsonLogEntry{
+		Time:  time.Now().UTC().Format("2006-01-02T15:04:05.000Z"),
+		Level: LogLevel(level).String(),
+		JobID: jl.jobID.String(),
+	}
+	// messages often repeat their level as a prefix, which is redundant here
+	msg = strings.TrimPrefix(msg, entry.Level+": ")
+	if m := transferIDPrefix.FindStringSubmatch(msg); m != nil {
+		entry.TransferID = m[1]
+		msg = msg[len(m[0]):]
+	}
+	if m := chunkIDPrefix.FindStringSubmatch(msg); m != nil {
+		entry.ChunkID, entry.WorkerID = m[1], m[2]
+		msg = msg[len(m[0]):]
+	}
+	entry.Message = strings.TrimRight(msg, "\n")
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		jl.logger.Println(msg) // can't happen for a struct of strings, but don't lose the message if it does
+		return
+	}
+	jl.logger.Println(string(line))
 }
 
 func (jl *jobLogger) MinimumLogLevel() pipeline.LogLevel {
@@ -164,7 +227,7 @@ func (jl *jobLogger) CloseLog() {
 		return
 	}
 
-	jl.logger.Println("Closing Log")
+	jl.writeLine(pipeline.LogInfo, "Closing Log")
 	err := jl.file.Close()
 	PanicIfErr(err)
 }
@@ -178,18 +241,25 @@ func (jl jobLogger) Log(loglevel pipeline.LogLevel, msg string) {
 
 	// Go, and therefore the sdk, defaults to \n for line endings, so if the platform has a different line ending,
 	// we should replace them to ensure readability on the given platform.
-	if lineEnding != "\n" {
+	// (Not needed for JSON, which escapes them)
+	if lineEnding != "\n" && jl.format != ELogFormat.Json() {
 		msg = strings.Replace(msg, "\n", lineEnding, -1)
 	}
 	if jl.ShouldLog(loglevel) {
-		jl.logger.Println(msg)
+		jl.writeLine(loglevel, msg)
 	}
 }
 
 func (jl jobLogger) Panic(err error) {
-	jl.logger.Println(err)  // We do NOT panic here as the app would terminate; we just log it
-	jl.appLogger.Panic(err) // We panic here that it logs and the app terminates
-	// We should never reach this line of code!
+	if err == nil {
+		err = errors.New("panic with no error")
+	}
+	msg := jl.sanitizer.SanitizeLogMessage(err.Error())
+	jl.writeLine(pipeline.LogPanic, msg) // We do NOT panic here as the app would terminate; we just log it
+	if jl.appLogger != nil {
+		jl.appLogger.Panic(err) // We panic here that it logs and the app terminates
+	}
+	panic(err) // the app logger no longer panics (and jobs aren't given one), so terminate here
 }
 
 const TryEquals string = "Try=" // TODO: refactor so that this can be used by the retry policies too?  So that when you search the logs for Try= you are guaranteed to find both types of retry (i.e. request send retries, and body read retries)
@@ -54,8 +54,8 @@ func NewMMF(file *os.File, writable bool, offset int64, length int64) (*MMF, err
 		prot, flags = syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED
 	}
 	addr, err := syscall.Mmap(int(file.Fd()), offset, int(length), prot, flags)
-	if !writable {
-		syscall.Madvise(addr, syscall.MADV_SEQUENTIAL|syscall.MADV_WILLNEED)
+	if !writable && err == nil {
+		adviseSequentialMapping(addr)
 	}
 	return &MMF{slice: (addr), isMapped: true, lock: sync.RWMutex{}}, err
 }
@@ -26,6 +26,8 @@ func (RpcCmd) CancelJob() RpcCmd          { return RpcCmd("Cancel") }
 func (RpcCmd) PauseJob() RpcCmd           { return RpcCmd("PauseJob") }
 func (RpcCmd) ResumeJob() RpcCmd          { return RpcCmd("ResumeJob") }
 func (RpcCmd) GetJobFromTo() RpcCmd       { return RpcCmd("GetJobFromTo") }
+func (RpcCmd) RemoveJobFiles() RpcCmd     { return RpcCmd("RemoveJobFiles") }
+func (RpcCmd) Ping() RpcCmd               { return RpcCmd("Ping") }
 
 func (c RpcCmd) String() string {
 	return enum.String(c, reflect.TypeOf(c))
@@ -116,6 +118,15 @@ type Transfers struct {
 	FolderTransferCount uint32
 }
 
+// RetryOptions carries the user's overrides of how the STE times out and retries requests.
+// A zero value for any field means the STE uses its default for that field
+type RetryOptions struct {
+	MaxTries      int32
+	TryTimeout    time.Duration
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+}
+
 // This struct represents the job info (a single part) to be sent to the storage engine
 type CopyJobPartOrderRequest struct {
 	Version         Version         // version of azcopy
@@ -125,6 +136,9 @@ type CopyJobPartOrderRequest struct {
 	ForceWrite      OverwriteOption // to determine if the existing needs to be overwritten or not. If set to true, existing blobs are overwritten
 	ForceIfReadOnly bool            // Supplements ForceWrite with addition setting for Azure Files objects with read-only attribute
 	AutoDecompress  bool            // if true, source data with encodings that represent compression are automatically decompressed when downloading
+	Compress        bool            // if true, source data is gzip-compressed while uploading, and stored with a Content-Encoding of gzip
+	RetryOptions    RetryOptions    // overrides for how requests are retried. Zero values mean use the default
+	TransferTimeout time.Duration   // if non-zero, any transfer that hasn't completed this long after it started is failed
 	Priority        JobPriority     // priority of the task
 	FromTo          FromTo
 	Fpo             FolderPropertyOption // passed in from front-end to ensure that front-end and STE agree on the desired behaviour for the job
@@ -157,6 +171,8 @@ type CredentialInfo struct {
 	OAuthTokenInfo    OAuthTokenInfo
 	S3CredentialInfo  S3CredentialInfo
 	GCPCredentialInfo GCPCredentialInfo
+	// for SharedKey, the name of the account. The key itself is not passed around, but read from the environment, see GetSharedKey
+	SharedKeyAccountName string
 }
 
 type GCPCredentialInfo struct {
@@ -185,6 +201,7 @@ type CopyJobPartOrderResponse struct {
 type ListRequest struct {
 	JobID    JobID
 	OfStatus string // TODO: OfStatus with string type sounds not good, change it to enum
+	Grep     string // only list the transfers whose source, destination or failure reason contains this
 	Output   OutputFormat
 }
 
@@ -200,20 +217,30 @@ type BlobTransferAttributes struct {
 	PageBlobTier             PageBlobTier          // Specifies the tier to set on the page blobs.
 	Metadata                 string                // User-defined Name-value pairs associated with the blob
 	NoGuessMimeType          bool                  // represents user decision to interpret the content-encoding from source file
+	ContentTypeMap           string                // per-extension content types, which take precedence over any other way of choosing one
 	PreserveLastModifiedTime bool                  // when downloading, tell engine to set file's timestamp to timestamp of blob
+	LastModifiedInMetadata   bool                  // when uploading, record the file's timestamp in blob metadata; when downloading, prefer that timestamp to the blob's own
 	PutMd5                   bool                  // when uploading, should we create and PUT Content-MD5 hashes
+	IfNoneMatch              bool                  // when writing block blobs, only create the blob if it does not already exist
+	ValidateBlocks           bool                  // when uploading block blobs, send the MD5 of each block, so that the service can validate it on receipt
+	SnapshotBeforeOverwrite  bool                  // when writing blobs, snapshot any existing blob before overwriting it
+	ServerSideCopy           bool                  // when copying blob to blob, have the service copy each blob, rather than sending its data through AzCopy
+	ReplaceProperties        bool                  // when copying server-side, replace the source's headers and metadata with those given by the user
 	MD5ValidationOption      HashValidationOption  // when downloading, how strictly should we validate MD5 hashes?
+	ChecksumFile             string                // when downloading, the file to append an md5sum-style line to for each downloaded file
 	BlockSizeInBytes         int64                 // when uploading/downloading/copying, specify the size of each chunk
+	PutBlobSizeInBytes       int64                 // when uploading block blobs, files up to this size are sent with a single Put Blob, rather than in blocks. 0 means the block size
 	DeleteSnapshotsOption    DeleteSnapshotsOption // when deleting, specify what to do with the snapshots
 	BlobTagsString           string                // when user explicitly provides blob tags
 	PermanentDeleteOption    PermanentDeleteOption // Permanently deletes soft-deleted snapshots when indicated by user
 }
 
 type JobIDDetails struct {
-	JobId         JobID
-	CommandString string
-	StartTime     int64
-	JobStatus     JobStatus
+	JobId          JobID
+	CommandString  string
+	StartTime      int64
+	JobStatus      JobStatus
+	TotalTransfers uint32
 }
 
 // ListJobsResponse represent the Job with JobId and
@@ -263,6 +290,11 @@ type ListJobSummaryResponse struct {
 
 	PercentComplete float32 `json:",string"`
 
+	// Smoothed recent throughput of successful bytes, in megabits per second, and the estimated time to transfer
+	// the remaining bytes at that rate. EstimatedSecondsRemaining is -1 when it can't be known (e.g. when the job is stalled).
+	ThroughputMbps            float64 `json:",string"`
+	EstimatedSecondsRemaining float64 `json:",string"`
+
 	// Stats measured from the network pipeline
 	// Values are all-time values, for the duration of the job.
 	// Will be zero if read outside the process running the job (e.g. with 'jobs show' command)
@@ -270,6 +302,8 @@ type ListJobSummaryResponse struct {
 	AverageE2EMilliseconds int     `json:",string"`
 	ServerBusyPercentage   float32 `json:",string"`
 	NetworkErrorPercentage float32 `json:",string"`
+	// Requests that were retried, by all the jobs of the process running the job
+	RetryCount int64 `json:",string"`
 
 	FailedTransfers  []TransferDetail
 	SkippedTransfers []TransferDetail
@@ -290,6 +324,7 @@ type ListSyncJobSummaryResponse struct {
 type ListJobTransfersRequest struct {
 	JobID    JobID
 	OfStatus TransferStatus
+	Grep     string // if not empty, only the transfers whose source, destination or failure reason contains it are returned
 }
 
 type ResumeJobRequest struct {
@@ -308,7 +343,10 @@ type TransferDetail struct {
 	IsFolderProperties bool
 	TransferStatus     TransferStatus
 	TransferSize       uint64
-	ErrorCode          int32 `json:",string"`
+	ErrorCode          int32  `json:",string"`
+	FailureReason      string `json:",omitempty"`
+	ContentMD5         []byte `json:",omitempty"`
+	ETag               string `json:",omitempty"` // the ETag of the destination, as written by the transfer
 }
 
 type CancelPauseResumeResponse struct {
@@ -335,3 +373,32 @@ type GetJobFromToResponse struct {
 	Source      string
 	Destination string
 }
+
+// RemoveJobFilesRequest asks the STE to delete the plan and log files of jobs.
+// If JobID is empty, every job with the given status that started at least OlderThan ago is removed.
+// Force also removes jobs that are still in progress according to their plan files, e.g. because the process running
+// them was killed, unless the STE is running them itself.
+type RemoveJobFilesRequest struct {
+	JobID      JobID
+	WithStatus JobStatus
+	OlderThan  time.Duration
+	Force      bool
+}
+
+// RemoveJobFilesResponse reports what was reclaimed by a RemoveJobFilesRequest.
+// Jobs that are still in progress are not removed (unless forced), and are listed in SkippedRunningJobs instead.
+type RemoveJobFilesResponse struct {
+	ErrorMsg           string
+	RemovedJobs        []JobID
+	SkippedRunningJobs []JobID
+	FilesRemoved       int
+	BytesReclaimed     int64
+}
+
+// PingResponse tells the front end that the STE is alive, and gives a short summary of its state.
+type PingResponse struct {
+	ErrorMsg   string
+	Version    string
+	Uptime     time.Duration
+	ActiveJobs int
+}
@@ -0,0 +1,168 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const rpcAuthScheme = "AzCopyRPC "
+const rpcAuthSecretLength = 32
+
+// RpcAuthMaxAge is how far the time a request was signed at may be from the receiver's clock.
+// It allows for clock skew between hosts, and is how long signatures are remembered to stop them being replayed.
+const RpcAuthMaxAge = 5 * time.Minute
+
+// RpcAuthenticator signs and verifies requests sent over the RPC channel between the front end and the STE.
+// Both sides share a per-session secret, which lives in a file that only the current user can read.
+// This stops other local users from driving the STE.
+// Each request is signed along with the time it was sent, so that a captured request can't be replayed later,
+// and the receiver rejects a signature it has seen before.
+type RpcAuthenticator struct {
+	secret []byte
+	now    func() time.Time
+
+	seenLock sync.Mutex
+	seen     map[string]time.Time // signatures received recently, and when they were signed
+}
+
+func newRpcAuthenticator(secret []byte) *RpcAuthenticator {
+	return &RpcAuthenticator{secret: secret, now: time.Now, seen: make(map[string]time.Time)}
+}
+
+// NewRpcAuthenticatorFromEnv returns nil (i.e. authentication is disabled) unless the user has opted in
+// by pointing AZCOPY_RPC_SECRET_FILE at a secret file. That keeps the RPC channel backwards compatible by default.
+func NewRpcAuthenticatorFromEnv() (*RpcAuthenticator, error) {
+	path := GetLifecycleMgr().GetEnvironmentVariable(EEnvironmentVariable.RpcSecretFile())
+	if path == "" {
+		return nil, nil
+	}
+	return NewRpcAuthenticatorFromFile(path)
+}
+
+// NewRpcAuthenticatorFromFile loads the secret from the given file, creating the file with a fresh random secret
+// if it does not exist yet
+func NewRpcAuthenticatorFromFile(path string) (*RpcAuthenticator, error) {
+	encoded, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		secret := make([]byte, rpcAuthSecretLength)
+		if _, err = rand.Read(secret); err != nil {
+			return nil, err
+		}
+		// O_EXCL so that, if both sides race to create the file, only one secret wins and the loser re-reads it
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			return NewRpcAuthenticatorFromFile(path)
+		} else if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if _, err = f.WriteString(base64.StdEncoding.EncodeToString(secret)); err != nil {
+			return nil, err
+		}
+		return newRpcAuthenticator(secret), nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	secret, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil {
+		return nil, errors.New("the RPC secret file is corrupt: " + err.Error())
+	}
+	if len(secret) < rpcAuthSecretLength {
+		return nil, errors.New("the RPC secret file does not contain a secret of sufficient length")
+	}
+	return newRpcAuthenticator(secret), nil
+}
+
+// AuthorizationHeader computes the value of the Authorization header for the given command and (JSON) payload,
+// sent now. It has the form "AzCopyRPC <unix time in nanoseconds>:<base64 signature>".
+func (a *RpcAuthenticator) AuthorizationHeader(rpcCmd RpcCmd, payload []byte) string {
+	signedAt := a.now().UnixNano()
+	return rpcAuthScheme + strconv.FormatInt(signedAt, 10) + ":" + base64.StdEncoding.EncodeToString(a.computeMAC(rpcCmd, signedAt, payload))
+}
+
+// IsAuthorized checks the given Authorization header value against the command and payload that was received.
+// A request signed more than RpcAuthMaxAge away from now, or whose signature has been received before, is rejected.
+func (a *RpcAuthenticator) IsAuthorized(authorizationHeader string, rpcCmd RpcCmd, payload []byte) bool {
+	if !strings.HasPrefix(authorizationHeader, rpcAuthScheme) {
+		return false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(authorizationHeader, rpcAuthScheme), ":", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	signedAt, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return false
+	}
+	receivedMAC, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	if !hmac.Equal(receivedMAC, a.computeMAC(rpcCmd, signedAt, payload)) {
+		return false
+	}
+
+	now := a.now()
+	age := now.Sub(time.Unix(0, signedAt))
+	if age > RpcAuthMaxAge || age < -RpcAuthMaxAge {
+		return false
+	}
+	return a.firstSighting(parts[1], now)
+}
+
+// firstSighting records the signature, and says whether it was the first time it was seen.
+// Signatures too old to be accepted anyway are forgotten.
+func (a *RpcAuthenticator) firstSighting(signature string, now time.Time) bool {
+	a.seenLock.Lock()
+	defer a.seenLock.Unlock()
+	for s, seenAt := range a.seen {
+		if now.Sub(seenAt) > 2*RpcAuthMaxAge {
+			delete(a.seen, s)
+		}
+	}
+	if _, replayed := a.seen[signature]; replayed {
+		return false
+	}
+	a.seen[signature] = now
+	return true
+}
+
+func (a *RpcAuthenticator) computeMAC(rpcCmd RpcCmd, signedAt int64, payload []byte) []byte {
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(rpcCmd.String()))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(strconv.FormatInt(signedAt, 10)))
+	mac.Write([]byte{'\n'})
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
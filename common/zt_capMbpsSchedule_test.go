@@ -0,0 +1,96 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"time"
+
+	chk "gopkg.in/check.v1"
+)
+
+type capMbpsScheduleSuite struct{}
+
+var _ = chk.Suite(&capMbpsScheduleSuite{})
+
+func atTimeOfDay(hour, min, sec int) time.Time {
+	return time.Date(2022, 3, 14, hour, min, sec, 0, time.Local)
+}
+
+func (s *capMbpsScheduleSuite) TestParseFixedCap(c *chk.C) {
+	schedule, err := ParseCapMbpsSchedule("12.5")
+	c.Assert(err, chk.IsNil)
+	c.Assert(schedule.IsScheduled(), chk.Equals, false)
+	c.Assert(schedule.CapAt(atTimeOfDay(3, 0, 0)), chk.Equals, 12.5)
+	c.Assert(schedule.Caps(), chk.DeepEquals, []float64{12.5})
+
+	schedule, err = ParseCapMbpsSchedule("")
+	c.Assert(err, chk.IsNil)
+	c.Assert(schedule.CapAt(atTimeOfDay(3, 0, 0)), chk.Equals, float64(0))
+}
+
+func (s *capMbpsScheduleSuite) TestParseSchedule(c *chk.C) {
+	schedule, err := ParseCapMbpsSchedule(" 08:00-18:00=50, 18:00-08:00=0,")
+	c.Assert(err, chk.IsNil)
+	c.Assert(schedule.IsScheduled(), chk.Equals, true)
+	c.Assert(schedule.Caps(), chk.DeepEquals, []float64{50, 0})
+
+	c.Assert(schedule.CapAt(atTimeOfDay(7, 59, 59)), chk.Equals, float64(0))
+	c.Assert(schedule.CapAt(atTimeOfDay(8, 0, 0)), chk.Equals, float64(50))
+	c.Assert(schedule.CapAt(atTimeOfDay(17, 59, 59)), chk.Equals, float64(50))
+	c.Assert(schedule.CapAt(atTimeOfDay(18, 0, 0)), chk.Equals, float64(0))
+}
+
+func (s *capMbpsScheduleSuite) TestWindowWrappingMidnight(c *chk.C) {
+	schedule, err := ParseCapMbpsSchedule("22:30-06:00=10")
+	c.Assert(err, chk.IsNil)
+
+	c.Assert(schedule.CapAt(atTimeOfDay(22, 29, 59)), chk.Equals, float64(0)) // outside all windows, so not capped
+	c.Assert(schedule.CapAt(atTimeOfDay(22, 30, 0)), chk.Equals, float64(10))
+	c.Assert(schedule.CapAt(atTimeOfDay(23, 59, 59)), chk.Equals, float64(10))
+	c.Assert(schedule.CapAt(atTimeOfDay(0, 0, 0)), chk.Equals, float64(10))
+	c.Assert(schedule.CapAt(atTimeOfDay(5, 59, 59)), chk.Equals, float64(10))
+	c.Assert(schedule.CapAt(atTimeOfDay(6, 0, 0)), chk.Equals, float64(0))
+}
+
+func (s *capMbpsScheduleSuite) TestFirstOverlappingWindowWins(c *chk.C) {
+	schedule, err := ParseCapMbpsSchedule("09:00-12:00=5,00:00-23:59=100")
+	c.Assert(err, chk.IsNil)
+
+	c.Assert(schedule.CapAt(atTimeOfDay(10, 0, 0)), chk.Equals, float64(5))
+	c.Assert(schedule.CapAt(atTimeOfDay(13, 0, 0)), chk.Equals, float64(100))
+}
+
+func (s *capMbpsScheduleSuite) TestParseInvalidSchedules(c *chk.C) {
+	for _, raw := range []string{
+		"fast",
+		"08:00-18:00", // the whole thing is taken as a number, so fails for that reason
+		"08:00=50",    // no end time
+		"08:00-18:00-20:00=50",
+		"8am-6pm=50",
+		"25:00-06:00=50",
+		"08:00-18:00=lots",
+		"08:00-08:00=50", // empty window
+		"08:00-18:00=50,bad",
+	} {
+		_, err := ParseCapMbpsSchedule(raw)
+		c.Assert(err, chk.NotNil, chk.Commentf("%q should be rejected", raw))
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"context"
+	"os"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	chk "gopkg.in/check.v1"
+)
+
+type connectionStringSuite struct{}
+
+var _ = chk.Suite(&connectionStringSuite{})
+
+func (s *connectionStringSuite) TestParseDevelopmentStorage(c *chk.C) {
+	cs, err := ParseStorageConnectionString("UseDevelopmentStorage=true")
+	c.Assert(err, chk.IsNil)
+	c.Assert(cs, chk.Equals, StorageConnectionString{
+		AccountName:  "devstoreaccount1",
+		AccountKey:   DevelopmentStorageAccountKey,
+		BlobEndpoint: "http://127.0.0.1:10000/devstoreaccount1",
+	})
+
+	cs, err = ParseStorageConnectionString("UseDevelopmentStorage=true;DevelopmentStorageProxyUri=http://azurite.local")
+	c.Assert(err, chk.IsNil)
+	c.Assert(cs.BlobEndpoint, chk.Equals, "http://azurite.local:10000/devstoreaccount1")
+
+	// the well-known account and key, without an endpoint, are for the emulator too
+	cs, err = ParseStorageConnectionString("DefaultEndpointsProtocol=http;AccountName=devstoreaccount1;AccountKey=" + DevelopmentStorageAccountKey + ";")
+	c.Assert(err, chk.IsNil)
+	c.Assert(cs.BlobEndpoint, chk.Equals, DevelopmentStorageBlobEndpoint)
+}
+
+func (s *connectionStringSuite) TestParseAccountConnectionString(c *chk.C) {
+	cs, err := ParseStorageConnectionString("DefaultEndpointsProtocol=https;AccountName=myaccount;AccountKey=a2V5==;EndpointSuffix=core.chinacloudapi.cn")
+	c.Assert(err, chk.IsNil)
+	c.Assert(cs, chk.Equals, StorageConnectionString{AccountName: "myaccount", AccountKey: "a2V5==", BlobEndpoint: "https://myaccount.blob.core.chinacloudapi.cn"})
+
+	cs, err = ParseStorageConnectionString("AccountName=myaccount;AccountKey=a2V5;BlobEndpoint=http://127.0.0.1:10000/myaccount/")
+	c.Assert(err, chk.IsNil)
+	c.Assert(cs.BlobEndpoint, chk.Equals, "http://127.0.0.1:10000/myaccount")
+
+	_, err = ParseStorageConnectionString("BlobEndpoint=https://myaccount.blob.core.windows.net;SharedAccessSignature=sv=2019-12-12&sig=x")
+	c.Assert(err, chk.NotNil)
+	_, err = ParseStorageConnectionString("AccountName=myaccount;AccountKey")
+	c.Assert(err, chk.NotNil)
+}
+
+func (s *connectionStringSuite) TestCoversBlobURL(c *chk.C) {
+	emulator := StorageConnectionString{BlobEndpoint: DevelopmentStorageBlobEndpoint}
+	c.Assert(emulator.CoversBlobURL("http://127.0.0.1:10000/devstoreaccount1/container/blob"), chk.Equals, true)
+	c.Assert(emulator.CoversBlobURL("http://127.0.0.1:10000/devstoreaccount1"), chk.Equals, true)
+	c.Assert(emulator.CoversBlobURL("http://127.0.0.1:10000/devstoreaccount2/container"), chk.Equals, false)
+	c.Assert(emulator.CoversBlobURL("http://127.0.0.1:10000/devstoreaccount10/container"), chk.Equals, false)
+	c.Assert(emulator.CoversBlobURL("https://127.0.0.1:10000/devstoreaccount1/container"), chk.Equals, false)
+	c.Assert(emulator.CoversBlobURL("http://127.0.0.1:10001/devstoreaccount1/container"), chk.Equals, false)
+
+	account := StorageConnectionString{BlobEndpoint: "https://myaccount.blob.core.windows.net"}
+	c.Assert(account.CoversBlobURL("https://MyAccount.blob.core.windows.net/container"), chk.Equals, true)
+	c.Assert(account.CoversBlobURL("https://other.blob.core.windows.net/container"), chk.Equals, false)
+}
+
+func (s *connectionStringSuite) TestBlobSharedKeyCredentialFromConnectionString(c *chk.C) {
+	env := EEnvironmentVariable.ConnectionString().Name
+	original, wasSet := os.LookupEnv(env)
+	defer func() {
+		if wasSet {
+			_ = os.Setenv(env, original)
+		} else {
+			_ = os.Unsetenv(env)
+		}
+	}()
+	_ = os.Setenv(env, "UseDevelopmentStorage=true")
+
+	credInfo := CredentialInfo{CredentialType: ECredentialType.SharedKey(), SharedKeyAccountName: DevelopmentStorageAccountName}
+	credential := CreateBlobCredential(context.Background(), credInfo, CredentialOpOptions{})
+	sharedKey, ok := credential.(*azblob.SharedKeyCredential)
+	c.Assert(ok, chk.Equals, true)
+	c.Assert(sharedKey.AccountName(), chk.Equals, DevelopmentStorageAccountName)
+
+	// without a connection string (or account key), there's no key to use
+	_ = os.Unsetenv(env)
+	c.Assert(func() {
+		CreateBlobCredential(context.Background(), credInfo, CredentialOpOptions{})
+	}, chk.PanicMatches, ".*AZCOPY_ACCOUNT_KEY must be set.*")
+}
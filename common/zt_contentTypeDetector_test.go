@@ -0,0 +1,69 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	chk "gopkg.in/check.v1"
+)
+
+type contentTypeDetectorSuite struct{}
+
+var _ = chk.Suite(&contentTypeDetectorSuite{})
+
+func (s *contentTypeDetectorSuite) TestDetectsFormatsByLeadingBytes(c *chk.C) {
+	testCases := map[string][]byte{
+		"image/webp":                     []byte("RIFF\x24\x00\x00\x00WEBPVP8 "),
+		"image/avif":                     []byte("\x00\x00\x00\x1cftypavif\x00\x00\x00\x00"),
+		"application/zstd":               {0x28, 0xB5, 0x2F, 0xFD, 0x04, 0x58},
+		"application/vnd.apache.parquet": []byte("PAR1\x15\x04\x15"),
+		"application/x-bzip2":            []byte("BZh91AY&SY\x00"),
+		"application/vnd.sqlite3":        []byte("SQLite format 3\x00\x10\x00"),
+		"image/png":                      {0x89, 'P', 'N', 'G', 0x0D, 0x0A, 0x1A, 0x0A},          // left to http.DetectContentType
+		"text/plain":                     []byte("BZh, that's not a bzip2 file, just some text"), // without its charset
+	}
+
+	for expectedType, leadingBytes := range testCases {
+		c.Assert(DetectContentType(leadingBytes), chk.Equals, expectedType, chk.Commentf("%q", leadingBytes))
+	}
+}
+
+func (s *contentTypeDetectorSuite) TestSignatureNeedsAllItsBytes(c *chk.C) {
+	// a file too short to hold the whole signature doesn't match it, and doesn't cause a panic either
+	c.Assert(DetectContentType([]byte("\x00\x00\x00\x1cftypav")), chk.Equals, "application/octet-stream")
+	c.Assert(DetectContentType(nil), chk.Equals, "text/plain")
+}
+
+func (s *contentTypeDetectorSuite) TestRegisteredSignaturesComeFirst(c *chk.C) {
+	defer func(original []ContentTypeSignature) {
+		registeredContentTypeSignatures.signatures = original
+	}(registeredContentTypeSignatures.signatures)
+
+	RegisterContentTypeSignature(ContentTypeSignature{Offset: 2, Magic: []byte("MYFMT"), ContentType: "application/x-my-format"})
+	c.Assert(DetectContentType([]byte("\x00\x00MYFMT\x01")), chk.Equals, "application/x-my-format")
+
+	// and can replace what a known format is called
+	RegisterContentTypeSignature(ContentTypeSignature{Magic: []byte("PAR1"), ContentType: "application/x-parquet"})
+	c.Assert(DetectContentType([]byte("PAR1\x15\x04\x15")), chk.Equals, "application/x-parquet")
+
+	c.Assert(func() {
+		RegisterContentTypeSignature(ContentTypeSignature{Magic: []byte("AB"), Mask: []byte{0xFF}, ContentType: "x/y"})
+	}, chk.PanicMatches, ".*same length.*")
+}
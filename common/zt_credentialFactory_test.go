@@ -0,0 +1,55 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest/adal"
+	chk "gopkg.in/check.v1"
+)
+
+type credentialFactorySuite struct{}
+
+var _ = chk.Suite(&credentialFactorySuite{})
+
+func (s *credentialFactorySuite) TestTokenRefreshedHalfwayToExpiry(c *chk.C) {
+	expiresIn := func(d time.Duration) *adal.Token {
+		token := &adal.Token{}
+		token.ExpiresOn = json.Number(strconv.FormatInt(time.Now().Add(d).Unix(), 10))
+		return token
+	}
+
+	wait := refreshPolicyHalfOfExpiryWithin(expiresIn(time.Hour), CredentialOpOptions{})
+	c.Assert(wait > 29*time.Minute && wait <= 30*time.Minute, chk.Equals, true, chk.Commentf("waited %v", wait))
+
+	// a token that is about to expire, or already has, is refreshed again soon, but not in a tight loop
+	c.Assert(refreshPolicyHalfOfExpiryWithin(expiresIn(-time.Minute), CredentialOpOptions{}), chk.Equals, time.Second)
+}
+
+func (s *credentialFactorySuite) TestRefreshGivesUpWithoutAToken(c *chk.C) {
+	cancelled := false
+	wait := refreshPolicyHalfOfExpiryWithin(nil, CredentialOpOptions{Cancel: func() { cancelled = true }})
+	c.Assert(cancelled, chk.Equals, true)
+	c.Assert(wait > 24*time.Hour, chk.Equals, true)
+}
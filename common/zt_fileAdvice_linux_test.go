@@ -0,0 +1,87 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+	chk "gopkg.in/check.v1"
+)
+
+type fileAdviceSuite struct{}
+
+var _ = chk.Suite(&fileAdviceSuite{})
+
+func (s *fileAdviceSuite) openTempFile(c *chk.C) *os.File {
+	path := filepath.Join(c.MkDir(), "file")
+	c.Assert(ioutil.WriteFile(path, make([]byte, 8192), 0644), chk.IsNil)
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	c.Assert(err, chk.IsNil)
+	return f
+}
+
+func (s *fileAdviceSuite) TestSequentialReadAdvice(c *chk.C) {
+	defer func(old func(int, int64, int64, int) error) { fadvise = old }(fadvise)
+	var fds, advice []int
+	fadvise = func(fd int, offset int64, length int64, adv int) error {
+		c.Assert(offset, chk.Equals, int64(0))
+		c.Assert(length, chk.Equals, int64(0)) // the whole file
+		fds = append(fds, fd)
+		advice = append(advice, adv)
+		return unix.Fadvise(fd, offset, length, adv)
+	}
+
+	f := s.openTempFile(c)
+	defer f.Close()
+	AdviseSequentialRead(f)
+	c.Assert(fds, chk.DeepEquals, []int{int(f.Fd())})
+	c.Assert(advice, chk.DeepEquals, []int{unix.FADV_SEQUENTIAL})
+}
+
+func (s *fileAdviceSuite) TestReadOnlyMappingAdvice(c *chk.C) {
+	defer func(old func([]byte, int) error) { madvise = old }(madvise)
+	var advice []int
+	madvise = func(b []byte, adv int) error {
+		c.Assert(b, chk.HasLen, 8192)
+		advice = append(advice, adv)
+		return syscall.Madvise(b, adv)
+	}
+
+	f := s.openTempFile(c)
+	defer f.Close()
+
+	mmf, err := NewMMF(f, false, 0, 8192)
+	c.Assert(err, chk.IsNil)
+	mmf.Unmap()
+	// separately, since MADV_SEQUENTIAL|MADV_WILLNEED would be MADV_WILLNEED alone
+	c.Assert(advice, chk.DeepEquals, []int{syscall.MADV_SEQUENTIAL, syscall.MADV_WILLNEED})
+
+	// a writable mapping, like a job plan that's being updated, isn't read sequentially
+	advice = nil
+	mmf, err = NewMMF(f, true, 0, 8192)
+	c.Assert(err, chk.IsNil)
+	mmf.Unmap()
+	c.Assert(advice, chk.HasLen, 0)
+}
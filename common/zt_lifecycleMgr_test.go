@@ -0,0 +1,261 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	chk "gopkg.in/check.v1"
+)
+
+type lifecycleMgrSuite struct{}
+
+var _ = chk.Suite(&lifecycleMgrSuite{})
+
+// recordingWorkController notes how it was asked to stop
+type recordingWorkController struct {
+	cancelled bool
+}
+
+func (w *recordingWorkController) Cancel(mgr LifecycleMgr) {
+	w.cancelled = true
+}
+
+func (w *recordingWorkController) ReportProgressOrExit(mgr LifecycleMgr) uint32 {
+	return 0
+}
+
+type recordingPausableWorkController struct {
+	recordingWorkController
+	paused bool
+}
+
+func (w *recordingPausableWorkController) Pause(mgr LifecycleMgr) {
+	w.paused = true
+}
+
+// newQuietLifecycleMgr makes a lifecycle manager whose messages are queued but never printed
+func newQuietLifecycleMgr() *lifecycleMgr {
+	return &lifecycleMgr{msgQueue: make(chan outputMessage, 10), logSanitizer: NewAzCopyLogSanitizer()}
+}
+
+func (s *lifecycleMgrSuite) TestSigtermPausesPausableWork(c *chk.C) {
+	lcm := newQuietLifecycleMgr()
+	jc := &recordingPausableWorkController{}
+
+	lcm.stopWork(jc, syscall.SIGTERM)
+
+	c.Assert(jc.paused, chk.Equals, true)
+	c.Assert(jc.cancelled, chk.Equals, false)
+}
+
+func (s *lifecycleMgrSuite) TestInterruptCancelsPausableWork(c *chk.C) {
+	lcm := newQuietLifecycleMgr()
+	jc := &recordingPausableWorkController{}
+
+	lcm.stopWork(jc, os.Interrupt)
+
+	c.Assert(jc.paused, chk.Equals, false)
+	c.Assert(jc.cancelled, chk.Equals, true)
+}
+
+func (s *lifecycleMgrSuite) TestSigtermCancelsWorkThatCannotPause(c *chk.C) {
+	lcm := newQuietLifecycleMgr()
+	jc := &recordingWorkController{}
+
+	lcm.stopWork(jc, syscall.SIGTERM)
+
+	c.Assert(jc.cancelled, chk.Equals, true)
+}
+
+func (s *lifecycleMgrSuite) TestJSONProgressGoesToStderr(c *chk.C) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	lcm := &lifecycleMgr{outputFormat: EOutputFormat.Json(), stdout: stdout, stderr: stderr, closeFunc: func() {}}
+
+	for _, msg := range []outputMessage{
+		{msgType: eOutputMessageType.Init(), msgContent: `{"JobID":"x"}`},
+		{msgType: eOutputMessageType.Progress(), msgContent: `{"PercentComplete":"50"}`},
+		{msgType: eOutputMessageType.Info(), msgContent: "INFO: blob.txt; Content Length: 1 B"},
+		{msgType: eOutputMessageType.Progress(), msgContent: `{"PercentComplete":"100"}`},
+		{msgType: eOutputMessageType.EndOfJob(), msgContent: `{"JobStatus":"Completed"}`, exitCode: EExitCode.NoExit()},
+	} {
+		lcm.processJSONOutput(msg)
+	}
+
+	// stdout has one JSON object per line, and no progress, so it can be piped to e.g. jq
+	lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	c.Assert(lines, chk.HasLen, 3)
+	for _, line := range lines {
+		var msg JsonOutputTemplate
+		c.Assert(json.Unmarshal([]byte(line), &msg), chk.IsNil)
+		c.Assert(msg.MessageType, chk.Not(chk.Equals), eOutputMessageType.Progress().String())
+	}
+
+	lines = strings.Split(strings.TrimSuffix(stderr.String(), "\n"), "\n")
+	c.Assert(lines, chk.HasLen, 2)
+	for _, line := range lines {
+		var msg JsonOutputTemplate
+		c.Assert(json.Unmarshal([]byte(line), &msg), chk.IsNil)
+		c.Assert(msg.MessageType, chk.Equals, eOutputMessageType.Progress().String())
+	}
+}
+
+func (s *lifecycleMgrSuite) TestErrorsExitWithTheirCode(c *chk.C) {
+	lcm := newQuietLifecycleMgr()
+
+	go lcm.Error("something went wrong") // never returns, as the process would have exited
+	msg := <-lcm.msgQueue
+	c.Assert(msg.shouldExitProcess(), chk.Equals, true)
+	c.Assert(msg.exitCode, chk.Equals, EExitCode.Error())
+
+	go lcm.ExitWithError("bad flag", EExitCode.InvalidUsage())
+	msg = <-lcm.msgQueue
+	c.Assert(msg.msgType, chk.Equals, eOutputMessageType.Error())
+	c.Assert(msg.msgContent, chk.Equals, "bad flag")
+	c.Assert(msg.exitCode, chk.Equals, EExitCode.InvalidUsage())
+}
+
+// newPromptingLifecycleMgr makes a running lifecycle manager that prints its questions, as JSON, to questions
+// and reads the answers from answers
+func newPromptingLifecycleMgr(questions io.Writer, answers io.Reader) *lifecycleMgr {
+	lcm := &lifecycleMgr{
+		msgQueue:        make(chan outputMessage, 10),
+		inputQueue:      make(chan userInput, 10),
+		inputClosed:     make(chan struct{}),
+		allowWatchInput: true,
+		outputFormat:    EOutputFormat.Json(),
+		logSanitizer:    NewAzCopyLogSanitizer(),
+		closeFunc:       func() {},
+		stdout:          questions,
+		stderr:          ioutil.Discard,
+		stdin:           answers,
+	}
+	go lcm.processOutputMessage()
+	go lcm.watchInputs()
+	return lcm
+}
+
+func (s *lifecycleMgrSuite) TestPromptReturnsTheOptionTyped(c *chk.C) {
+	options := []ResponseOption{EResponseOption.Yes(), EResponseOption.No(), EResponseOption.YesForAll(), EResponseOption.Quit()}
+
+	for _, option := range options {
+		questionsReader, questionsWriter := io.Pipe()
+		answersReader, answersWriter := io.Pipe()
+		lcm := newPromptingLifecycleMgr(questionsWriter, answersReader)
+
+		go func(answer string) {
+			// answers typed before the question is shown are ignored, so wait for it
+			_, _ = bufio.NewReader(questionsReader).ReadString('\n')
+			_, _ = answersWriter.Write([]byte(answer + "\n"))
+		}(option.ResponseString)
+
+		answer := lcm.Prompt("Overwrite?", PromptDetails{PromptType: EPromptType.Overwrite(), ResponseOptions: options})
+		c.Assert(answer, chk.Equals, option)
+	}
+}
+
+func (s *lifecycleMgrSuite) TestPromptGivesDefaultWhenStdinEnds(c *chk.C) {
+	// e.g. azcopy < /dev/null; the prompt must not wait forever for an answer that cannot come
+	lcm := newPromptingLifecycleMgr(ioutil.Discard, strings.NewReader(""))
+
+	answer := lcm.Prompt("Overwrite?", PromptDetails{PromptType: EPromptType.Overwrite(),
+		ResponseOptions: []ResponseOption{EResponseOption.Yes(), EResponseOption.No()}})
+	c.Assert(answer, chk.Equals, EResponseOption.Default())
+}
+
+// quietRunEnvVar tells a re-run of the test binary to act as an AzCopy run in quiet mode
+const quietRunEnvVar = "AZCOPY_TEST_QUIET_RUN"
+
+// TestQuietRunHelper is not a test by itself. It runs in a child process, started by the quiet mode tests, and prints
+// what a job would, through the real lifecycle manager, which then exits the process
+func (s *lifecycleMgrSuite) TestQuietRunHelper(c *chk.C) {
+	outcome := os.Getenv(quietRunEnvVar)
+	if outcome == "" {
+		c.Skip("only runs in a child process")
+	}
+	lcm := GetLifecycleMgr()
+	lcm.SetOutputFormat(EOutputFormat.Text())
+	lcm.SetQuiet(true)
+
+	lcm.Init(func(OutputFormat) string { return "Job has started" })
+	lcm.Info("Scanning...")
+	lcm.Progress(func(OutputFormat) string { return "50.0 %, 1 Done, 0 Failed, 1 Pending" })
+	if outcome == "fail" {
+		lcm.Error("failed to perform copy command due to error: no such file")
+	}
+	if outcome == "panic" {
+		// not on the test's goroutine, where gocheck would recover it, but somewhere nothing does, as in a real crash
+		go panic("crashed")
+		time.Sleep(time.Minute)
+	}
+	lcm.Exit(func(OutputFormat) string { return "Final Job Status: Completed" }, EExitCode.Success())
+}
+
+func runQuietly(c *chk.C, outcome string) (stdout string, exitCode int) {
+	stdout, _, exitCode = runQuietlyWithStderr(c, outcome)
+	return stdout, exitCode
+}
+
+func runQuietlyWithStderr(c *chk.C, outcome string) (stdout, stderr string, exitCode int) {
+	cmd := exec.Command(os.Args[0], "-test.run=Test", "-check.f=lifecycleMgrSuite.TestQuietRunHelper")
+	cmd.Env = append(os.Environ(), quietRunEnvVar+"="+outcome)
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.Stdout = out
+	cmd.Stderr = errOut
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return out.String(), errOut.String(), exitErr.ExitCode()
+	}
+	c.Assert(err, chk.IsNil)
+	return out.String(), errOut.String(), 0
+}
+
+func (s *lifecycleMgrSuite) TestQuietRunPrintsNothing(c *chk.C) {
+	stdout, exitCode := runQuietly(c, "succeed")
+	c.Assert(stdout, chk.Equals, "")
+	c.Assert(exitCode, chk.Equals, int(EExitCode.Success()))
+}
+
+// a panic exits with the same code as a job where every transfer failed, so it's told apart by what's on stderr
+func (s *lifecycleMgrSuite) TestPanicIsToldApartByStderr(c *chk.C) {
+	_, stderr, exitCode := runQuietlyWithStderr(c, "panic")
+	c.Assert(exitCode, chk.Equals, int(EExitCode.AllFailed()))
+	c.Assert(strings.Contains(stderr, "panic: crashed"), chk.Equals, true, chk.Commentf("stderr: %s", stderr))
+
+	_, stderr, exitCode = runQuietlyWithStderr(c, "fail")
+	c.Assert(exitCode, chk.Equals, int(EExitCode.Error()))
+	c.Assert(strings.Contains(stderr, "panic"), chk.Equals, false)
+}
+
+func (s *lifecycleMgrSuite) TestQuietRunStillPrintsErrors(c *chk.C) {
+	stdout, exitCode := runQuietly(c, "fail")
+	c.Assert(strings.TrimSpace(stdout), chk.Equals, "failed to perform copy command due to error: no such file")
+	c.Assert(exitCode, chk.Equals, int(EExitCode.Error()))
+}
@@ -64,6 +64,11 @@ func (s *logSanitizerSuite) TestLogSanitizer(c *chk.C) {
 
 		// word "sig" inside the signature
 		{"http://foo?sig=sigvalue BlahBlah", "http://foo?sig=-REDACTED- BlahBlah"},
+
+		// whole values of authorization headers, as written by pipeline.WriteRequestWithResponse
+		{"   X-Ms-Copy-Source-Authorization: [Bearer abc.def]\n   X-Ms-Version: [2019-12-12]", "   X-Ms-Copy-Source-Authorization: -REDACTED-\n   X-Ms-Version: [2019-12-12]"},
+		{"   Authorization: [SharedKey account:c2lnbmF0dXJl]\r\n", "   Authorization: -REDACTED-\r\n"},
+		{"403 (AuthorizationPermissionMismatch)", "403 (AuthorizationPermissionMismatch)"}, // an error code, not a header
 	}
 
 	san := NewAzCopyLogSanitizer()
@@ -0,0 +1,154 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	chk "gopkg.in/check.v1"
+)
+
+type loggerSuite struct{}
+
+var _ = chk.Suite(&loggerSuite{})
+
+func (s *loggerSuite) readLogLines(c *chk.C, dir string, jobID JobID) []string {
+	f, err := os.Open(filepath.Join(dir, jobID.String()+".log"))
+	c.Assert(err, chk.IsNil)
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	c.Assert(scanner.Err(), chk.IsNil)
+	return lines
+}
+
+func (s *loggerSuite) TestJsonLogLinesHaveStructuredFields(c *chk.C) {
+	dir := c.MkDir()
+	jobID := NewJobID()
+	logger := NewJobLogger(jobID, ELogLevel.Info(), dir, "")
+	logger.(*jobLogger).format = ELogFormat.Json()
+	logger.OpenLog()
+
+	// as written by a transfer's logger, when a block has been staged
+	logger.Log(pipeline.LogInfo, "INFO: [P#0-T#12] "+ChunkLogPrefix(NewChunkID("/data/file.bin", 8388608, 4194304), 3)+"Staged block 3 of /data/file.bin\nwith a second line")
+	logger.Log(pipeline.LogDebug, "below the level, so not logged")
+	logger.CloseLog()
+
+	lines := s.readLogLines(c, dir, jobID)
+	c.Assert(len(lines) > 2, chk.Equals, true)
+	var staged *jsonLogEntry
+	for _, line := range lines {
+		entry := jsonLogEntry{}
+		c.Assert(json.Unmarshal([]byte(line), &entry), chk.IsNil, chk.Commentf("line is not JSON: %s", line))
+		c.Assert(entry.JobID, chk.Equals, jobID.String())
+		c.Assert(entry.Time, chk.Not(chk.Equals), "")
+		if strings.HasPrefix(entry.Message, "Staged block") {
+			staged = &entry
+		}
+		c.Assert(entry.Message, chk.Not(chk.Equals), "below the level, so not logged")
+	}
+
+	c.Assert(staged, chk.NotNil)
+	c.Assert(staged.Level, chk.Equals, "INFO")
+	c.Assert(staged.TransferID, chk.Equals, "P#0-T#12")
+	c.Assert(staged.ChunkID, chk.Equals, "C#8388608")
+	c.Assert(staged.WorkerID, chk.Equals, "W#3")
+	c.Assert(staged.Message, chk.Equals, "Staged block 3 of /data/file.bin\nwith a second line")
+	c.Assert(strings.Contains(lines[len(lines)-1], `"message":"Closing Log"`), chk.Equals, true)
+}
+
+func (s *loggerSuite) TestTextLogIsTheDefault(c *chk.C) {
+	dir := c.MkDir()
+	jobID := NewJobID()
+	logger := NewJobLogger(jobID, ELogLevel.Info(), dir, "")
+	logger.OpenLog()
+	logger.Log(pipeline.LogInfo, "INFO: [P#0-T#12] Staged block 3")
+	logger.CloseLog()
+
+	lines := s.readLogLines(c, dir, jobID)
+	c.Assert(strings.HasSuffix(lines[0], "AzcopyVersion  "+AzcopyVersion), chk.Equals, true)
+	found := false
+	for _, line := range lines {
+		if strings.HasSuffix(line, " INFO: [P#0-T#12] Staged block 3") {
+			found = true
+		}
+	}
+	c.Assert(found, chk.Equals, true)
+}
+
+func (s *loggerSuite) TestSecretsRedactedInLog(c *chk.C) {
+	dir := c.MkDir()
+	jobID := NewJobID()
+	logger := NewJobLogger(jobID, ELogLevel.Info(), dir, "")
+	logger.OpenLog()
+	logger.Log(pipeline.LogInfo, "INFO: [P#0-T#1] Starting transfer: Source \"https://account.blob.core.windows.net/c/blob?sv=2019-12-12&sig=c2VjcmV0\"\n"+
+		"   X-Ms-Copy-Source-Authorization: [Bearer eyJhbGciOi]")
+	logger.Log(pipeline.LogError, "ERR: failed to read https://account.blob.core.windows.net/c/blob?sig=c2VjcmV0&sv=2019-12-12")
+	logger.CloseLog()
+
+	log := strings.Join(s.readLogLines(c, dir, jobID), "\n")
+	c.Assert(strings.Contains(log, "c2VjcmV0"), chk.Equals, false)
+	c.Assert(strings.Contains(log, "eyJhbGciOi"), chk.Equals, false)
+	c.Assert(strings.Count(log, "sig=-REDACTED-"), chk.Equals, 2)
+	c.Assert(strings.Contains(log, "X-Ms-Copy-Source-Authorization: -REDACTED-"), chk.Equals, true)
+}
+
+func (s *loggerSuite) TestDebugMessagesOnlyLoggedAtDebugLevel(c *chk.C) {
+	for _, level := range []LogLevel{ELogLevel.Info(), ELogLevel.Debug()} {
+		dir := c.MkDir()
+		jobID := NewJobID()
+		logger := NewJobLogger(jobID, level, dir, "")
+		logger.OpenLog()
+		logger.Log(pipeline.LogDebug, "DBG: [P#0-T#1] Chunk 0 staged")
+		logger.CloseLog()
+
+		found := false
+		for _, line := range s.readLogLines(c, dir, jobID) {
+			if strings.HasSuffix(line, "Chunk 0 staged") {
+				found = true
+			}
+		}
+		c.Assert(found, chk.Equals, level == ELogLevel.Debug(), chk.Commentf("log level %s", level))
+	}
+}
+
+func (s *loggerSuite) TestPanicWithNilErrorIsLogged(c *chk.C) {
+	dir := c.MkDir()
+	jobID := NewJobID()
+	logger := NewJobLogger(jobID, ELogLevel.Info(), dir, "")
+	logger.(*jobLogger).format = ELogFormat.Json()
+	logger.OpenLog()
+
+	c.Assert(func() { logger.Panic(nil) }, chk.PanicMatches, "panic with no error")
+	logger.CloseLog()
+
+	lines := s.readLogLines(c, dir, jobID)
+	c.Assert(strings.Contains(lines[len(lines)-2], `"message":"panic with no error"`), chk.Equals, true, chk.Commentf("log was %v", lines))
+}
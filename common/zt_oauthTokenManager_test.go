@@ -0,0 +1,148 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	chk "gopkg.in/check.v1"
+)
+
+type oauthTokenManagerTestSuite struct{}
+
+var _ = chk.Suite(&oauthTokenManagerTestSuite{})
+
+// newMockTokenEndpoint stands in for AAD's token endpoint, granting a token for the client credentials
+// it is configured with and refusing everything else.
+func newMockTokenEndpoint(c *chk.C, tenantID, applicationID, secret string, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		c.Check(r.Method, chk.Equals, http.MethodPost)
+		c.Check(r.URL.Path, chk.Equals, "/"+tenantID+"/oauth2/token")
+
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.PostForm.Get("grant_type") != "client_credentials" ||
+			r.PostForm.Get("client_id") != applicationID ||
+			r.PostForm.Get("client_secret") != secret {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+		c.Check(r.PostForm.Get("resource"), chk.Equals, Resource)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"access_token":"fake-access-token","expires_in":"3599","expires_on":"%d","not_before":"%d","resource":"%s","token_type":"Bearer"}`,
+			time.Now().Add(time.Hour).Unix(), time.Now().Unix(), Resource)
+	}))
+}
+
+func newTestTokenManager() *UserOAuthTokenManager {
+	return NewUserOAuthTokenManagerInstance(CredCacheOptions{
+		DPAPIFilePath: ".",
+		KeyName:       "AzCopyOAuthTokenManagerTest",
+		ServiceName:   "AzCopyV10Test",
+		AccountName:   "AzCopyOAuthTokenManagerTest",
+	})
+}
+
+func (s *oauthTokenManagerTestSuite) TestSecretLoginAgainstMockTokenEndpoint(c *chk.C) {
+	var requests int32
+	server := newMockTokenEndpoint(c, "fake-tenant", "fake-app", "fake-secret", &requests)
+	defer server.Close()
+
+	uotm := newTestTokenManager()
+	tokenInfo, err := uotm.SecretLogin("fake-tenant", server.URL, "fake-secret", "fake-app", false)
+	c.Assert(err, chk.IsNil)
+	c.Assert(atomic.LoadInt32(&requests), chk.Equals, int32(1))
+
+	c.Assert(tokenInfo.AccessToken, chk.Equals, "fake-access-token")
+	c.Assert(tokenInfo.ServicePrincipalName, chk.Equals, true)
+	c.Assert(tokenInfo.ApplicationID, chk.Equals, "fake-app")
+	c.Assert(tokenInfo.Tenant, chk.Equals, "fake-tenant")
+	c.Assert(tokenInfo.SPNInfo.Secret, chk.Equals, "fake-secret")
+	c.Assert(tokenInfo.Expires().After(time.Now()), chk.Equals, true)
+
+	// the token obtained at login is what later operations in this process use
+	stashed, err := uotm.GetTokenInfo(nil)
+	c.Assert(err, chk.IsNil)
+	c.Assert(stashed, chk.Equals, tokenInfo)
+
+	// without persist, nothing should have been written to the cache
+	hasCachedToken, _ := uotm.HasCachedToken()
+	c.Assert(hasCachedToken, chk.Equals, false)
+}
+
+func (s *oauthTokenManagerTestSuite) TestSecretLoginRejectedByTokenEndpoint(c *chk.C) {
+	var requests int32
+	server := newMockTokenEndpoint(c, "fake-tenant", "fake-app", "fake-secret", &requests)
+	defer server.Close()
+
+	uotm := newTestTokenManager()
+	_, err := uotm.SecretLogin("fake-tenant", server.URL, "wrong-secret", "fake-app", true)
+	c.Assert(err, chk.NotNil)
+	c.Assert(strings.Contains(err.Error(), "invalid_client"), chk.Equals, true)
+
+	hasCachedToken, _ := uotm.HasCachedToken()
+	c.Assert(hasCachedToken, chk.Equals, false)
+}
+
+func (s *oauthTokenManagerTestSuite) TestPersistedSecretLoginClearedByLogout(c *chk.C) {
+	var requests int32
+	server := newMockTokenEndpoint(c, "fake-tenant", "fake-app", "fake-secret", &requests)
+	defer server.Close()
+
+	uotm := newTestTokenManager()
+	defer func() {
+		if hasCachedToken, _ := uotm.HasCachedToken(); hasCachedToken {
+			_ = uotm.RemoveCachedToken()
+		}
+	}()
+
+	_, err := uotm.SecretLogin("fake-tenant", server.URL, "fake-secret", "fake-app", true)
+	c.Assert(err, chk.IsNil)
+
+	hasCachedToken, err := uotm.HasCachedToken()
+	c.Assert(err, chk.IsNil)
+	c.Assert(hasCachedToken, chk.Equals, true)
+
+	// a fresh manager, as a later azcopy process would create, picks the token up from the cache
+	cached, err := newTestTokenManager().getCachedTokenInfo(nil)
+	c.Assert(err, chk.IsNil)
+	c.Assert(cached.AccessToken, chk.Equals, "fake-access-token")
+	c.Assert(cached.ServicePrincipalName, chk.Equals, true)
+	c.Assert(cached.ActiveDirectoryEndpoint, chk.Equals, server.URL)
+
+	// logout removes the cached token, after which there is nothing to reuse
+	c.Assert(uotm.RemoveCachedToken(), chk.IsNil)
+	hasCachedToken, _ = uotm.HasCachedToken()
+	c.Assert(hasCachedToken, chk.Equals, false)
+
+	_, err = newTestTokenManager().getCachedTokenInfo(nil)
+	c.Assert(err, chk.NotNil)
+}
@@ -0,0 +1,116 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	chk "gopkg.in/check.v1"
+)
+
+type rpcAuthSuite struct{}
+
+var _ = chk.Suite(&rpcAuthSuite{})
+
+func (s *rpcAuthSuite) TestRpcAuthAcceptsSignedRequest(c *chk.C) {
+	path := filepath.Join(c.MkDir(), "secret")
+	payload := []byte(`{"JobID":"abc"}`)
+
+	// the front end creates the secret, and the STE loads the same one
+	client, err := NewRpcAuthenticatorFromFile(path)
+	c.Assert(err, chk.IsNil)
+	server, err := NewRpcAuthenticatorFromFile(path)
+	c.Assert(err, chk.IsNil)
+
+	header := client.AuthorizationHeader(ERpcCmd.ListJobSummary(), payload)
+	c.Assert(server.IsAuthorized(header, ERpcCmd.ListJobSummary(), payload), chk.Equals, true)
+
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		c.Assert(err, chk.IsNil)
+		c.Assert(info.Mode().Perm(), chk.Equals, os.FileMode(0600))
+	}
+}
+
+func (s *rpcAuthSuite) TestRpcAuthRejectsBadRequests(c *chk.C) {
+	dir := c.MkDir()
+	payload := []byte(`{"JobID":"abc"}`)
+
+	server, err := NewRpcAuthenticatorFromFile(filepath.Join(dir, "secret"))
+	c.Assert(err, chk.IsNil)
+	stranger, err := NewRpcAuthenticatorFromFile(filepath.Join(dir, "otherSecret"))
+	c.Assert(err, chk.IsNil)
+
+	header := server.AuthorizationHeader(ERpcCmd.ListJobSummary(), payload)
+
+	// no header, or a malformed one
+	c.Assert(server.IsAuthorized("", ERpcCmd.ListJobSummary(), payload), chk.Equals, false)
+	c.Assert(server.IsAuthorized("Bearer xyz", ERpcCmd.ListJobSummary(), payload), chk.Equals, false)
+
+	// signed with a different secret
+	c.Assert(server.IsAuthorized(stranger.AuthorizationHeader(ERpcCmd.ListJobSummary(), payload), ERpcCmd.ListJobSummary(), payload), chk.Equals, false)
+
+	// valid signature replayed against another command, or with a tampered payload
+	c.Assert(server.IsAuthorized(header, ERpcCmd.CancelJob(), payload), chk.Equals, false)
+	c.Assert(server.IsAuthorized(header, ERpcCmd.ListJobSummary(), []byte(`{"JobID":"xyz"}`)), chk.Equals, false)
+}
+
+func (s *rpcAuthSuite) TestRpcAuthRejectsStaleAndReplayedRequests(c *chk.C) {
+	path := filepath.Join(c.MkDir(), "secret")
+	payload := []byte(`{"JobID":"abc"}`)
+	client, err := NewRpcAuthenticatorFromFile(path)
+	c.Assert(err, chk.IsNil)
+	server, err := NewRpcAuthenticatorFromFile(path)
+	c.Assert(err, chk.IsNil)
+
+	// the same request, captured and sent again, is refused
+	header := client.AuthorizationHeader(ERpcCmd.ListJobSummary(), payload)
+	c.Assert(server.IsAuthorized(header, ERpcCmd.ListJobSummary(), payload), chk.Equals, true)
+	c.Assert(server.IsAuthorized(header, ERpcCmd.ListJobSummary(), payload), chk.Equals, false)
+
+	// as are requests signed too long ago, or too far in the future
+	for _, skew := range []time.Duration{-RpcAuthMaxAge - time.Second, RpcAuthMaxAge + time.Second} {
+		client.now = func() time.Time { return time.Now().Add(skew) }
+		header = client.AuthorizationHeader(ERpcCmd.ListJobSummary(), payload)
+		c.Assert(server.IsAuthorized(header, ERpcCmd.ListJobSummary(), payload), chk.Equals, false, chk.Commentf("skew %v", skew))
+	}
+
+	// but a little clock skew is fine
+	client.now = func() time.Time { return time.Now().Add(-time.Minute) }
+	header = client.AuthorizationHeader(ERpcCmd.ListJobSummary(), payload)
+	c.Assert(server.IsAuthorized(header, ERpcCmd.ListJobSummary(), payload), chk.Equals, true)
+}
+
+func (s *rpcAuthSuite) TestRpcAuthRejectsTamperedTimestamp(c *chk.C) {
+	server, err := NewRpcAuthenticatorFromFile(filepath.Join(c.MkDir(), "secret"))
+	c.Assert(err, chk.IsNil)
+	payload := []byte(`{"JobID":"abc"}`)
+
+	header := server.AuthorizationHeader(ERpcCmd.ListJobSummary(), payload)
+	signature := header[strings.Index(header, ":"):]
+	tampered := rpcAuthScheme + strconv.FormatInt(time.Now().UnixNano(), 10) + signature
+	c.Assert(server.IsAuthorized(tampered, ERpcCmd.ListJobSummary(), payload), chk.Equals, false)
+}
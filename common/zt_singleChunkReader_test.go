@@ -0,0 +1,159 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	chk "gopkg.in/check.v1"
+)
+
+type singleChunkReaderSuite struct{}
+
+var _ = chk.Suite(&singleChunkReaderSuite{})
+
+type nullChunkStatusLogger struct{}
+
+func (nullChunkStatusLogger) LogChunkStatus(ChunkID, WaitReason) {}
+func (nullChunkStatusLogger) IsWaitingOnFinalBodyReads() bool    { return false }
+
+type nullGeneralLogger struct{}
+
+func (nullGeneralLogger) ShouldLog(pipeline.LogLevel) bool { return false }
+func (nullGeneralLogger) Log(pipeline.LogLevel, string)    {}
+func (nullGeneralLogger) Panic(err error)                  { panic(err) }
+
+// peakTrackingCacheLimiter records the most bytes that were ever allocated at once
+type peakTrackingCacheLimiter struct {
+	*cacheLimiter
+	peak int64
+}
+
+func (l *peakTrackingCacheLimiter) WaitUntilAdd(ctx context.Context, count int64, useRelaxedLimit Predicate) error {
+	err := l.cacheLimiter.WaitUntilAdd(ctx, count, useRelaxedLimit)
+	if err == nil {
+		current := atomic.LoadInt64(&l.cacheLimiter.value)
+		for {
+			peak := atomic.LoadInt64(&l.peak)
+			if current <= peak || atomic.CompareAndSwapInt64(&l.peak, peak, current) {
+				break
+			}
+		}
+	}
+	return err
+}
+
+// Large files are read one chunk at a time, into pooled buffers, so memory use is capped by the
+// cache limiter however large the file is, and however many workers are reading it
+func (s *singleChunkReaderSuite) TestLargeFileReadsUseBoundedMemory(c *chk.C) {
+	const (
+		fileSize   = 512 * 1024 * 1024
+		chunkSize  = 4 * 1024 * 1024
+		memLimit   = 64 * 1024 * 1024
+		numWorkers = 32 // more than can fit in memLimit at once
+	)
+
+	// sparse, so the test doesn't need half a gig of disk
+	path := filepath.Join(c.MkDir(), "large")
+	f, err := os.Create(path)
+	c.Assert(err, chk.IsNil)
+	c.Assert(f.Truncate(fileSize), chk.IsNil)
+	c.Assert(f.Close(), chk.IsNil)
+
+	file, err := os.Open(path)
+	c.Assert(err, chk.IsNil)
+	defer file.Close()
+	sourceFactory := func() (CloseableReaderAt, error) { return os.Open(path) }
+
+	limiter := &peakTrackingCacheLimiter{cacheLimiter: NewCacheLimiter(memLimit).(*cacheLimiter)}
+	slicePool := NewMultiSizeSlicePool(chunkSize)
+
+	// sample the heap while the chunks are read
+	runtime.GC()
+	var baseline runtime.MemStats
+	runtime.ReadMemStats(&baseline)
+	var peakHeap uint64
+	stopSampling := make(chan struct{})
+	samplingDone := make(chan struct{})
+	go func() {
+		defer close(samplingDone)
+		var m runtime.MemStats
+		for {
+			runtime.ReadMemStats(&m)
+			if m.HeapInuse > peakHeap {
+				peakHeap = m.HeapInuse
+			}
+			select {
+			case <-stopSampling:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}()
+
+	offsets := make(chan int64, fileSize/chunkSize)
+	for offset := int64(0); offset < fileSize; offset += chunkSize {
+		offsets <- offset
+	}
+	close(offsets)
+
+	var bytesRead int64
+	var wg sync.WaitGroup
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for offset := range offsets {
+				reader := NewSingleChunkReader(context.Background(), sourceFactory, NewChunkID(path, offset, chunkSize), chunkSize,
+					nullChunkStatusLogger{}, nullGeneralLogger{}, slicePool, limiter)
+				if err := reader.BlockingPrefetch(file, false); err != nil {
+					panic(err)
+				}
+				n, err := io.Copy(ioutil.Discard, reader)
+				if err != nil {
+					panic(err)
+				}
+				atomic.AddInt64(&bytesRead, n)
+				_ = reader.Close()
+			}
+		}()
+	}
+	wg.Wait()
+	close(stopSampling)
+	<-samplingDone
+
+	c.Assert(bytesRead, chk.Equals, int64(fileSize))
+	c.Assert(limiter.peak <= memLimit, chk.Equals, true, chk.Commentf("peak allocation %d", limiter.peak))
+	c.Assert(atomic.LoadInt64(&limiter.cacheLimiter.value), chk.Equals, int64(0))
+
+	// allow for pooled slices and garbage that hasn't been collected yet, but nothing like the size of the file
+	heapGrowth := int64(peakHeap) - int64(baseline.HeapInuse)
+	c.Assert(heapGrowth < 3*memLimit, chk.Equals, true, chk.Commentf("heap grew by %d", heapGrowth))
+}
@@ -211,7 +211,7 @@ func (t *TestRunner) ExecuteAzCopyCommand(operation Operation, src, dst string,
 	stdErr := make([]byte, 0)
 	if err != nil {
 		if ee, ok := err.(*exec.ExitError); ok {
-			stdErr = capLen(ee.Stderr) // cap length of this, because it can be a panic. But don't cap stdout, because we need its last line in newCopyOrSyncCommandResult
+			stdErr = capLen(withoutJSONMessages(ee.Stderr)) // cap length of this, because it can be a panic. But don't cap stdout, because we need its last line in newCopyOrSyncCommandResult
 			if len(stdErr) > 0 {
 				wasClean = false // something was written to stderr, probably a panic
 			}
@@ -235,6 +235,18 @@ func (t *TestRunner) ExecuteAzCopyCommand(operation Operation, src, dst string,
 		fmt.Errorf("azcopy run error: %w\n  with stderr: %s\n  and stdout: %s\n  from args %v", err, stdErr, out, args)
 }
 
+// withoutJSONMessages removes the lines of output that are JSON messages (i.e. the progress, which goes to stderr)
+func withoutJSONMessages(output []byte) []byte {
+	var rest bytes.Buffer
+	for _, line := range strings.SplitAfter(string(output), "\n") {
+		var msg common.JsonOutputTemplate
+		if json.Unmarshal([]byte(strings.TrimSpace(line)), &msg) != nil {
+			rest.WriteString(line)
+		}
+	}
+	return rest.Bytes()
+}
+
 func (t *TestRunner) SetTransferStatusFlag(value string) {
 	t.flags["with-status"] = value
 }
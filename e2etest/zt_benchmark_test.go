@@ -0,0 +1,130 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package e2etest
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// fakeBlobBackend is just enough of the Blob service for uploads of block blobs, kept in memory
+type fakeBlobBackend struct {
+	mu     sync.Mutex
+	blocks map[string][]byte
+	blobs  map[string][]byte
+}
+
+func newFakeBlobBackend() *fakeBlobBackend {
+	return &fakeBlobBackend{blocks: map[string][]byte{}, blobs: map[string][]byte{}}
+}
+
+func (b *fakeBlobBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	query := r.URL.Query()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case query.Get("restype") == "container":
+		// the container always exists
+		if r.Method == http.MethodPut {
+			w.WriteHeader(http.StatusCreated)
+		}
+	case r.Method == http.MethodPut && query.Get("comp") == "block":
+		b.blocks[r.URL.Path+"/"+query.Get("blockid")] = body
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && query.Get("comp") == "blocklist":
+		var blockList struct {
+			Latest []string `xml:"Latest"`
+		}
+		_ = xml.Unmarshal(body, &blockList)
+		var content []byte
+		for _, id := range blockList.Latest {
+			content = append(content, b.blocks[r.URL.Path+"/"+id]...)
+		}
+		b.blobs[r.URL.Path] = content
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && query.Get("comp") == "":
+		b.blobs[r.URL.Path] = body
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodHead || r.Method == http.MethodGet:
+		content, ok := b.blobs[r.URL.Path]
+		if !ok {
+			w.Header().Set("x-ms-error-code", "BlobNotFound")
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.Header().Set("x-ms-blob-type", "BlockBlob")
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(content)
+		}
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func TestBenchmark_UploadReportsThroughput(t *testing.T) {
+	backend := newFakeBlobBackend()
+	server := httptest.NewServer(backend)
+	defer server.Close()
+
+	// bench only accepts Blob, Files and ADLS Gen2 hosts, so the fake backend is reached as a proxy for a plain http blob URL.
+	// It can't list blobs, so the data is kept rather than cleaned up.
+	cmd := exec.Command(GlobalInputManager{}.GetExecutablePath(), "bench", "http://account.blob.core.windows.net/container?sv=2019-12-12&sig=fake",
+		"--file-count=10", "--size-per-file=1M", "--block-size-mb=0.25", "--keep")
+	cmd.Env = append(os.Environ(), "HTTP_PROXY="+server.URL, "NO_PROXY=")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("bench failed: %v\n%s", err, out)
+	}
+
+	throughput := regexp.MustCompile(`Throughput \(Mb/s\): ([0-9.]+)`).FindSubmatch(out)
+	if throughput == nil {
+		t.Fatalf("no throughput was reported:\n%s", out)
+	}
+	if mbps, _ := strconv.ParseFloat(string(throughput[1]), 64); mbps <= 0 {
+		t.Errorf("throughput was %s Mb/s", throughput[1])
+	}
+	if !regexp.MustCompile(`Retries: \d+`).Match(out) {
+		t.Errorf("no retry count was reported:\n%s", out)
+	}
+
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	if len(backend.blobs) != 10 {
+		t.Fatalf("%d blobs were uploaded, not 10", len(backend.blobs))
+	}
+	for name, content := range backend.blobs {
+		if len(content) != 1024*1024 {
+			t.Errorf("%s has %d bytes, not 1M", name, len(content))
+		}
+	}
+}
@@ -27,6 +27,7 @@ require (
 	golang.org/x/oauth2 v0.0.0-20220223155221-ee480838109b
 	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
 	golang.org/x/sys v0.0.0-20220224120231-95c6836cb0e7
+	golang.org/x/text v0.3.7
 	google.golang.org/api v0.70.0
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c
 )
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"reflect"
 	"sync/atomic"
+	"time"
 	"unsafe"
 
 	"github.com/Azure/azure-storage-azcopy/v10/common"
@@ -13,12 +14,25 @@ import (
 // dataSchemaVersion defines the data schema version of JobPart order files supported by
 // current version of azcopy
 // To be Incremented every time when we release azcopy with changed dataSchema
-const DataSchemaVersion common.Version = 16
+//
+// Each part of a job is persisted in its own plan file, named <JobID>--<PartNum>.steV<DataSchemaVersion>, and laid out as:
+//  1. the JobPartPlanHeader, whose first field is always the Version it was written with
+//  2. the command string, padded to 8 bytes
+//  3. NumTransfers JobPartPlanTransfer records, which hold each transfer's status (and are updated in place as the job runs)
+//  4. the source and destination strings of the transfers, at the offsets given in their records
+//
+// The plan file is memory-mapped, so every status change is on disk as soon as it's made, and a job whose process was
+// stopped can be resumed from its plan files alone. Because the version is in the file name, a version of AzCopy never
+// loads a plan file written in another format; ResumeJobOrder says so, rather than reporting that the job doesn't exist.
+const DataSchemaVersion common.Version = 31
 
 const (
-	CustomHeaderMaxBytes = 256
-	MetadataMaxBytes     = 1000 // If > 65536, then jobPartPlanBlobData's MetadataLength field's type must change
-	BlobTagsMaxByte      = 4000
+	CustomHeaderMaxBytes   = 256
+	MetadataMaxBytes       = 1000 // If > 65536, then jobPartPlanBlobData's MetadataLength field's type must change
+	BlobTagsMaxByte        = 4000
+	ContentTypeMapMaxBytes = 1000
+	ETagMaxBytes           = 64 // blob ETags are around 20 bytes, so this leaves plenty of room
+	ChecksumFileMaxBytes   = 1000
 )
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
@@ -53,6 +67,9 @@ type JobPartPlanHeader struct {
 	ForceWrite             common.OverwriteOption      // True if the existing blobs needs to be overwritten.
 	ForceIfReadOnly        bool                        // Supplements ForceWrite with an additional setting for Azure Files. If true, the read-only attribute will be cleared before we overwrite
 	AutoDecompress         bool                        // if true, source data with encodings that represent compression are automatically decompressed when downloading
+	Compress               bool                        // if true, source data is gzip-compressed while uploading
+	RetryOptions           common.RetryOptions         // overrides for the retry policy of this part's pipelines
+	TransferTimeout        time.Duration               // if non-zero, transfers still running after this long are failed
 	Priority               common.JobPriority          // The Job Part's priority
 	TTLAfterCompletion     uint32                      // Time to live after completion is used to persists the file on disk of specified time after the completion of JobPartOrder
 	FromTo                 common.FromTo               // The location of the transfer's source & destination
@@ -295,6 +312,12 @@ type JobPartPlanDstBlob struct {
 	// Specifies the cache control of the blob
 	CacheControl [CustomHeaderMaxBytes]byte
 
+	// Specifies the length of the per-extension content type mapping.
+	ContentTypeMapLength uint16
+
+	// Specifies content types for particular file extensions. These take precedence over ContentType and mime type guessing
+	ContentTypeMap [ContentTypeMapMaxBytes]byte
+
 	// Specifies the tier if this is a block or page blob
 	BlockBlobTier common.BlockBlobTier
 	PageBlobTier  common.PageBlobTier
@@ -302,6 +325,21 @@ type JobPartPlanDstBlob struct {
 	// Controls uploading of MD5 hashes
 	PutMd5 bool
 
+	// If true, block blobs are only written if they do not exist yet, which is checked by the service as part of the write
+	IfNoneMatch bool
+
+	// If true, the MD5 of each staged block is sent with it, so that the service rejects a block corrupted on the way
+	ValidateBlocks bool
+
+	// If true, a blob that is about to be overwritten is snapshotted first, so that its prior version is retained
+	SnapshotBeforeOverwrite bool
+
+	// If true, blob to blob copies are made by the service, with Copy Blob, rather than by reading and writing blocks
+	ServerSideCopy bool
+
+	// If true, server-side copies replace the source's headers and metadata with those given by the user, rather than keeping them
+	ReplaceProperties bool
+
 	MetadataLength uint16
 	Metadata       [MetadataMaxBytes]byte
 
@@ -315,6 +353,9 @@ type JobPartPlanDstBlob struct {
 
 	// Specifies the maximum size of block which determines the number of chunks and chunk size of a transfer
 	BlockSize int64
+
+	// Block blobs up to this size are uploaded with a single Put Blob, rather than in blocks. Zero means up to BlockSize
+	PutBlobSize int64
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
@@ -326,8 +367,16 @@ type JobPartPlanDstLocal struct {
 	// Specifies whether the timestamp of destination file has to be set to the modified time of source file
 	PreserveLastModifiedTime bool
 
+	// Specifies whether the modification time is carried in blob metadata: stamped into it when uploading,
+	// and, when present, used in preference to the blob's own timestamp when downloading
+	LastModifiedInMetadata bool
+
 	// says how MD5 verification failures should be actioned
 	MD5VerificationOption common.HashValidationOption
+
+	// the file to which a line in the format of md5sum is appended for each downloaded file, if any
+	ChecksumFileLength uint16
+	ChecksumFile       [ChecksumFileMaxBytes]byte
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
@@ -380,6 +429,19 @@ type JobPartPlanTransfer struct {
 	// atomicErrorCode has a default value (0) which means either there was no error or transfer failed because some non storageError.
 	// atomicErrorCode should not be directly accessed anywhere except by transferStatus and setTransferStatus
 	atomicErrorCode int32
+
+	// atomicNumChunks and atomicChunksDone record how far an in-flight transfer has got, so that a process other than the one
+	// running the job can still report its progress. They are reset whenever the transfer is started again
+	atomicNumChunks  uint32
+	atomicChunksDone uint32
+
+	// contentMD5 holds the MD5 hash computed by this azcopy process while moving the transfer's data.
+	// It is written once, by the goroutine that completes the transfer, and is all zeros when no hash was computed
+	contentMD5 [16]byte
+
+	// etag holds the ETag the service gave the destination when this azcopy process finished writing it, padded with zeros.
+	// Like contentMD5, it is written once, by the goroutine that completes the transfer
+	etag [ETagMaxBytes]byte
 }
 
 // TransferStatus returns the transfer's status
@@ -404,6 +466,71 @@ func (jppt *JobPartPlanTransfer) SetTransferStatus(status common.TransferStatus,
 	}
 }
 
+// SetNumChunks records how many chunks the transfer is split into, and starts counting its completed chunks from zero
+func (jppt *JobPartPlanTransfer) SetNumChunks(numChunks uint32) {
+	atomic.StoreUint32(&jppt.atomicChunksDone, 0)
+	atomic.StoreUint32(&jppt.atomicNumChunks, numChunks)
+}
+
+// ReportChunkDone counts one more chunk of the transfer as successfully completed.
+// The count lives in the memory-mapped plan file, whose pages the OS writes back in its own time, so counting each chunk
+// costs no I/O of its own, and there is nothing to gain from batching the counts up (see BenchmarkChunkProgressPersistence)
+func (jppt *JobPartPlanTransfer) ReportChunkDone() {
+	atomic.AddUint32(&jppt.atomicChunksDone, 1)
+}
+
+// InFlightBytes estimates how many bytes of the source have been transferred, from the share of its chunks completed so far
+func (jppt *JobPartPlanTransfer) InFlightBytes() uint64 {
+	numChunks := atomic.LoadUint32(&jppt.atomicNumChunks)
+	chunksDone := atomic.LoadUint32(&jppt.atomicChunksDone)
+	if numChunks == 0 || jppt.SourceSize <= 0 {
+		return 0
+	}
+	if chunksDone > numChunks {
+		chunksDone = numChunks
+	}
+	// float math, since size times chunk count can overflow 64 bits for the largest blobs
+	return uint64(float64(jppt.SourceSize) * float64(chunksDone) / float64(numChunks))
+}
+
+// ContentMD5 returns the MD5 hash computed for the transfer, or nil if none was computed
+func (jppt *JobPartPlanTransfer) ContentMD5() []byte {
+	if jppt.contentMD5 == [16]byte{} {
+		return nil
+	}
+	hash := make([]byte, len(jppt.contentMD5))
+	copy(hash, jppt.contentMD5[:])
+	return hash
+}
+
+// SetContentMD5 records the MD5 hash computed for the transfer. Hashes of any other length are ignored.
+func (jppt *JobPartPlanTransfer) SetContentMD5(hash []byte) {
+	if len(hash) != len(jppt.contentMD5) {
+		return
+	}
+	copy(jppt.contentMD5[:], hash)
+}
+
+// ETag returns the ETag of the destination as written by the transfer, or "" if none was recorded
+func (jppt *JobPartPlanTransfer) ETag() string {
+	n := 0
+	for n < len(jppt.etag) && jppt.etag[n] != 0 {
+		n++
+	}
+	return string(jppt.etag[:n])
+}
+
+// SetETag records the ETag of the destination as written by the transfer. ETags too long to store are ignored.
+func (jppt *JobPartPlanTransfer) SetETag(etag string) {
+	if len(etag) > len(jppt.etag) {
+		return
+	}
+	n := copy(jppt.etag[:], etag)
+	for i := n; i < len(jppt.etag); i++ {
+		jppt.etag[i] = 0 // clear what's left of any ETag from an earlier attempt
+	}
+}
+
 // ErrorCode returns the transfer's errorCode.
 func (jppt *JobPartPlanTransfer) ErrorCode() int32 {
 	return atomic.LoadInt32(&jppt.atomicErrorCode)
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math"
 	"os"
 	"reflect"
@@ -28,6 +29,27 @@ func (jppfn *JobPartPlanFileName) GetJobPartPlanPath() string {
 
 const jobPartPlanFileNameFormat = "%v--%05d.steV%d"
 
+// planFileVersionsOfJob lists the data schema version of every plan file of the given job that is in planDir,
+// whether or not this version of AzCopy can read it
+func planFileVersionsOfJob(planDir string, jobID common.JobID) []common.Version {
+	entries, err := ioutil.ReadDir(planDir)
+	if err != nil {
+		return nil
+	}
+	versions := make([]common.Version, 0)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), jobID.String()+"--") {
+			continue
+		}
+		var partNumber common.PartNumber
+		var version common.Version
+		if n, err := fmt.Sscanf(strings.TrimPrefix(entry.Name(), jobID.String()+"--"), "%05d.steV%d", &partNumber, &version); err == nil && n == 2 {
+			versions = append(versions, version)
+		}
+	}
+	return versions
+}
+
 // TODO: This needs testing
 func (jpfn JobPartPlanFileName) Parse() (jobID common.JobID, partNumber common.PartNumber, err error) {
 	var dataSchemaVersion common.Version
@@ -110,9 +132,15 @@ func (jpfn JobPartPlanFileName) Create(order common.CopyJobPartOrderRequest) {
 	if len(order.BlobAttributes.Metadata) > len(JobPartPlanDstBlob{}.Metadata) {
 		panic(fmt.Errorf("metadata string is too large: %q", order.BlobAttributes.Metadata))
 	}
+	if len(order.BlobAttributes.ContentTypeMap) > len(JobPartPlanDstBlob{}.ContentTypeMap) {
+		panic(fmt.Errorf("content type map is too large: %q", order.BlobAttributes.ContentTypeMap))
+	}
 	if len(order.BlobAttributes.BlobTagsString) > len(JobPartPlanDstBlob{}.BlobTags) {
 		panic(fmt.Errorf("blob tags string is too large: %q", order.BlobAttributes.BlobTagsString))
 	}
+	if len(order.BlobAttributes.ChecksumFile) > len(JobPartPlanDstLocal{}.ChecksumFile) {
+		panic(fmt.Errorf("checksum file path is too large: %q", order.BlobAttributes.ChecksumFile))
+	}
 
 	// This nested function writes a structure value to an io.Writer & returns the number of bytes written
 	writeValue := func(writer io.Writer, v interface{}) int64 {
@@ -177,6 +205,9 @@ func (jpfn JobPartPlanFileName) Create(order common.CopyJobPartOrderRequest) {
 		ForceWrite:             order.ForceWrite,
 		ForceIfReadOnly:        order.ForceIfReadOnly,
 		AutoDecompress:         order.AutoDecompress,
+		Compress:               order.Compress,
+		RetryOptions:           order.RetryOptions,
+		TransferTimeout:        order.TransferTimeout,
 		Priority:               order.Priority,
 		TTLAfterCompletion:     uint32(time.Time{}.Nanosecond()),
 		FromTo:                 order.FromTo,
@@ -192,11 +223,18 @@ func (jpfn JobPartPlanFileName) Create(order common.CopyJobPartOrderRequest) {
 			ContentDispositionLength: uint16(len(order.BlobAttributes.ContentDisposition)),
 			ContentLanguageLength:    uint16(len(order.BlobAttributes.ContentLanguage)),
 			CacheControlLength:       uint16(len(order.BlobAttributes.CacheControl)),
+			ContentTypeMapLength:     uint16(len(order.BlobAttributes.ContentTypeMap)),
 			PutMd5:                   order.BlobAttributes.PutMd5, // here because it relates to uploads (blob destination)
+			IfNoneMatch:              order.BlobAttributes.IfNoneMatch,
+			ValidateBlocks:           order.BlobAttributes.ValidateBlocks,
+			SnapshotBeforeOverwrite:  order.BlobAttributes.SnapshotBeforeOverwrite,
+			ServerSideCopy:           order.BlobAttributes.ServerSideCopy,
+			ReplaceProperties:        order.BlobAttributes.ReplaceProperties,
 			BlockBlobTier:            order.BlobAttributes.BlockBlobTier,
 			PageBlobTier:             order.BlobAttributes.PageBlobTier,
 			MetadataLength:           uint16(len(order.BlobAttributes.Metadata)),
 			BlockSize:                blockSize,
+			PutBlobSize:              order.BlobAttributes.PutBlobSizeInBytes,
 			BlobTagsLength:           uint16(len(order.BlobAttributes.BlobTagsString)),
 			CpkInfo:                  order.CpkOptions.CpkInfo,
 			CpkScopeInfoLength:       uint16(len(order.CpkOptions.CpkScopeInfo)),
@@ -204,7 +242,9 @@ func (jpfn JobPartPlanFileName) Create(order common.CopyJobPartOrderRequest) {
 		},
 		DstLocalData: JobPartPlanDstLocal{
 			PreserveLastModifiedTime: order.BlobAttributes.PreserveLastModifiedTime,
+			LastModifiedInMetadata:   order.BlobAttributes.LastModifiedInMetadata,
 			MD5VerificationOption:    order.BlobAttributes.MD5ValidationOption, // here because it relates to downloads (file destination)
+			ChecksumFileLength:       uint16(len(order.BlobAttributes.ChecksumFile)),
 		},
 		PreservePermissions: order.PreserveSMBPermissions,
 		PreserveSMBInfo:     order.PreserveSMBInfo,
@@ -229,9 +269,11 @@ func (jpfn JobPartPlanFileName) Create(order common.CopyJobPartOrderRequest) {
 	copy(jpph.DstBlobData.ContentLanguage[:], order.BlobAttributes.ContentLanguage)
 	copy(jpph.DstBlobData.ContentDisposition[:], order.BlobAttributes.ContentDisposition)
 	copy(jpph.DstBlobData.CacheControl[:], order.BlobAttributes.CacheControl)
+	copy(jpph.DstBlobData.ContentTypeMap[:], order.BlobAttributes.ContentTypeMap)
 	copy(jpph.DstBlobData.Metadata[:], order.BlobAttributes.Metadata)
 	copy(jpph.DstBlobData.BlobTags[:], order.BlobAttributes.BlobTagsString)
 	copy(jpph.DstBlobData.CpkScopeInfo[:], order.CpkOptions.CpkScopeInfo)
+	copy(jpph.DstLocalData.ChecksumFile[:], order.BlobAttributes.ChecksumFile)
 
 	eof += writeValue(file, &jpph)
 
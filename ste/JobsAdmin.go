@@ -107,12 +107,15 @@ var JobsAdmin interface {
 
 	CurrentMainPoolSize() int
 
+	// Uptime returns how long ago the JobsAdmin was initialized
+	Uptime() time.Duration
+
 	RequestTuneSlowly()
 
 	SetConcurrencySettingsToAuto()
 }
 
-func initJobsAdmin(appCtx context.Context, concurrency ConcurrencySettings, targetRateInMegaBitsPerSec float64, azcopyJobPlanFolder string, azcopyLogPathFolder string, providePerfAdvice bool) {
+func initJobsAdmin(appCtx context.Context, concurrency ConcurrencySettings, capMbpsSchedule common.CapMbpsSchedule, azcopyJobPlanFolder string, azcopyLogPathFolder string, providePerfAdvice bool) {
 	if JobsAdmin != nil {
 		panic("initJobsAdmin was already called once")
 	}
@@ -142,20 +145,23 @@ func initJobsAdmin(appCtx context.Context, concurrency ConcurrencySettings, targ
 	// Create normal & low transfer/chunk channels
 	normalTransferCh, normalChunkCh := make(chan IJobPartTransferMgr, channelSize), make(chan chunkFunc, channelSize)
 	lowTransferCh, lowChunkCh := make(chan IJobPartTransferMgr, channelSize), make(chan chunkFunc, channelSize)
+	smallFileCh := make(chan chunkFunc, channelSize)
+	commitCh := make(chan func(), channelSize)
 
 	maxRamBytesToUse := getMaxRamForChunks()
 
 	// default to a pacer that doesn't actually control the rate
 	// (it just records total throughput, since for historical reasons we do that in the pacer)
 	var pacer pacerAdmin = newNullAutoPacer()
-	if targetRateInMegaBitsPerSec > 0 {
-		// use the "networking mega" (based on powers of 10, not powers of 2, since that's what mega means in networking context)
-		targetRateInBytesPerSec := int64(targetRateInMegaBitsPerSec * 1000 * 1000 / 8)
+	if capMbpsSchedule.IsScheduled() {
+		pacer = newScheduledPacer(capMbpsSchedule, time.Now)
+	} else if targetRateInMegaBitsPerSec := capMbpsSchedule.CapAt(time.Now()); targetRateInMegaBitsPerSec > 0 {
+		targetRateInBytesPerSec := mbpsToBytesPerSecond(targetRateInMegaBitsPerSec)
 		unusedExpectedCoarseRequestByteCount := int64(0)
 		pacer = newTokenBucketPacer(targetRateInBytesPerSec, unusedExpectedCoarseRequestByteCount)
-		// Note: as at July 2019, we don't currently have a shutdown method/event on JobsAdmin where this pacer
-		// could be shut down. But, it's global anyway, so we just leave it running until application exit.
 	}
+	// Note: as at July 2019, we don't currently have a shutdown method/event on JobsAdmin where the pacer
+	// could be shut down. But, it's global anyway, so we just leave it running until application exit.
 
 	ja := &jobsAdmin{
 		concurrency:             concurrency,
@@ -166,11 +172,12 @@ func initJobsAdmin(appCtx context.Context, concurrency ConcurrencySettings, targ
 		pacer:                   pacer,
 		slicePool:               common.NewMultiSizeSlicePool(common.MaxBlockBlobBlockSize),
 		cacheLimiter:            common.NewCacheLimiter(maxRamBytesToUse),
-		fileCountLimiter:        common.NewCacheLimiter(int64(concurrency.MaxOpenDownloadFiles)),
+		fileCountLimiter:        common.NewCacheLimiter(int64(concurrency.MaxOpenFiles)),
 		cpuMonitor:              cpuMon,
 		appCtx:                  appCtx,
-		commandLineMbpsCap:      targetRateInMegaBitsPerSec,
+		capMbpsSchedule:         capMbpsSchedule,
 		provideBenchmarkResults: providePerfAdvice,
+		startTime:               time.Now(),
 		coordinatorChannels: CoordinatorChannels{
 			partsChannel:     partsCh,
 			normalTransferCh: normalTransferCh,
@@ -182,6 +189,8 @@ func initJobsAdmin(appCtx context.Context, concurrency ConcurrencySettings, targ
 			lowTransferCh:    lowTransferCh,
 			normalChunckCh:   normalChunkCh,
 			lowChunkCh:       lowChunkCh,
+			smallFileCh:      smallFileCh,
+			commitCh:         commitCh,
 		},
 		poolSizingChannels: poolSizingChannels{ // all deliberately unbuffered, because pool sizer routine works in lock-step with these - processing them as they happen, never catching up on populated buffer later
 			entryNotificationCh: make(chan struct{}),
@@ -204,6 +213,11 @@ func initJobsAdmin(appCtx context.Context, concurrency ConcurrencySettings, targ
 	// the tuning results and, in the worst case, leads to "completion" of tuning before any traffic has been sent.
 	ja.concurrencyTuner = ja.createConcurrencyTuner()
 
+	if concurrency.InterleaveChunks != nil && concurrency.InterleaveChunks.Value {
+		ja.normalChunkInterleaver = newChunkInterleaver(func(cf chunkFunc) { normalChunkCh <- cf })
+		ja.lowChunkInterleaver = newChunkInterleaver(func(cf chunkFunc) { lowChunkCh <- cf })
+	}
+
 	JobsAdmin = ja
 
 	// Spin up slice pool pruner
@@ -220,6 +234,19 @@ func initJobsAdmin(appCtx context.Context, concurrency ConcurrencySettings, targ
 	for cc := 0; cc < concurrency.TransferInitiationPoolSize.Value; cc++ {
 		go ja.transferProcessor(cc)
 	}
+
+	// Small files skip the main pool. Transfer initiation hands them straight to this pool, which reads each one and sends it
+	// in a single request. Since a small file costs little RAM, many more of them can be in flight than there are main pool workers.
+	for cc := 0; cc < concurrency.activeSmallFilePoolSize(); cc++ {
+		go ja.smallFileProcessor(cc)
+	}
+
+	// Committing a blob that was sent in many blocks is one more round trip, after the last block. It's done by its own pool
+	// so that the main pool worker that sent the last block can go straight back to sending blocks. Otherwise, when many
+	// files finish at around the same time, the main pool stalls on their commits and the tail of the job drags out.
+	for cc := 0; cc < concurrency.CommitPoolSize.Value; cc++ {
+		go ja.commitProcessor(cc)
+	}
 }
 
 // Decide on a max amount of RAM we are willing to use. This functions as a cap, and prevents excessive usage.
@@ -257,6 +284,25 @@ func getMaxRamForChunks() int64 {
 	return maxRamBytesToUse
 }
 
+// ChunkMemoryLimiter returns the limiter of the RAM that chunks may use, so that data read outside of a job, such as the
+// blocks of a piped upload, counts towards the same limit. When there's no JobsAdmin, it's a new limiter of the same size.
+func ChunkMemoryLimiter() common.CacheLimiter {
+	if ja, ok := JobsAdmin.(*jobsAdmin); ok && ja != nil {
+		return ja.cacheLimiter
+	}
+	return common.NewCacheLimiter(getMaxRamForChunks())
+}
+
+// MaxSingleChunkSize is the size of the largest chunk that can be read into memory: one that fits under the strict limit
+// of the chunk cache, and that the slice pool can provide
+func MaxSingleChunkSize() int64 {
+	maxSize := common.NewCacheLimiter(getMaxRamForChunks()).StrictLimit()
+	if maxSize > common.MaxBlockBlobBlockSize {
+		maxSize = common.MaxBlockBlobBlockSize
+	}
+	return maxSize
+}
+
 // QueueJobParts puts the given JobPartManager into the partChannel
 // from where this JobPartMgr will be picked by a routine and
 // its transfers will be scheduled
@@ -484,6 +530,20 @@ func (ja *jobsAdmin) transferProcessor(workerID int) {
 	}
 }
 
+// dedicated worker that reads and sends small files, which are handed over whole by transferProcessor
+func (ja *jobsAdmin) smallFileProcessor(workerID int) {
+	for chunkFunc := range ja.xferChannels.smallFileCh {
+		chunkFunc(workerID)
+	}
+}
+
+// dedicated worker that runs the epilogues of multi-block transfers, which are handed over by the worker that sent the last block
+func (ja *jobsAdmin) commitProcessor(workerID int) {
+	for commitFunc := range ja.xferChannels.commitCh {
+		commitFunc()
+	}
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
 // There will be only 1 instance of the jobsAdmin type.
@@ -501,6 +561,8 @@ type jobsAdmin struct {
 	planDir                     string // Initialize to directory where Job Part Plans are stored
 	coordinatorChannels         CoordinatorChannels
 	xferChannels                XferChannels
+	normalChunkInterleaver      *chunkInterleaver // nil unless chunks are interleaved
+	lowChunkInterleaver         *chunkInterleaver
 	poolSizingChannels          poolSizingChannels
 	appCtx                      context.Context
 	pacer                       pacerAdmin
@@ -512,9 +574,10 @@ type jobsAdmin struct {
 		pipeline.LogLevel
 	}
 	concurrencyTuner        ConcurrencyTuner
-	commandLineMbpsCap      float64
+	capMbpsSchedule         common.CapMbpsSchedule
 	provideBenchmarkResults bool
 	cpuMonitor              common.CPUMonitor
+	startTime               time.Time
 }
 
 type CoordinatorChannels struct {
@@ -529,6 +592,8 @@ type XferChannels struct {
 	lowTransferCh    <-chan IJobPartTransferMgr // Read-only
 	normalChunckCh   chan chunkFunc             // Read-write
 	lowChunkCh       chan chunkFunc             // Read-write
+	smallFileCh      chan chunkFunc             // Read-write
+	commitCh         chan func()                // Read-write
 }
 
 type poolSizingChannels struct {
@@ -604,6 +669,39 @@ func (ja *jobsAdmin) ScheduleChunk(priority common.JobPriority, chunkFunc chunkF
 	}
 }
 
+// ScheduleSmallFileSend queues the func that reads and sends a whole small file, for the small-file pool to run
+func (ja *jobsAdmin) ScheduleSmallFileSend(chunkFunc chunkFunc) {
+	ja.xferChannels.smallFileCh <- chunkFunc
+}
+
+// ScheduleCommit queues the epilogue of a multi-block transfer for the commit pool to run. If that pool is turned off,
+// the epilogue runs right here instead.
+func (ja *jobsAdmin) ScheduleCommit(commitFunc func()) {
+	if ja.concurrency.CommitPoolSize.Value <= 0 {
+		commitFunc()
+		return
+	}
+	ja.xferChannels.commitCh <- commitFunc
+}
+
+// ScheduleTransferChunk schedules a chunk of the given transfer. If chunks are interleaved, it waits for the transfer's
+// turn to go into the chunk channel; otherwise it goes straight in.
+func (ja *jobsAdmin) ScheduleTransferChunk(priority common.JobPriority, transfer IJobPartTransferMgr, chunkFunc chunkFunc) {
+	var interleaver *chunkInterleaver
+	switch priority {
+	case common.EJobPriority.Normal():
+		interleaver = ja.normalChunkInterleaver
+	case common.EJobPriority.Low():
+		interleaver = ja.lowChunkInterleaver
+	}
+
+	if interleaver == nil {
+		ja.ScheduleChunk(priority, chunkFunc)
+		return
+	}
+	interleaver.Schedule(transfer, chunkFunc)
+}
+
 func (ja *jobsAdmin) BytesOverWire() int64 {
 	return ja.pacer.GetTotalTraffic()
 }
@@ -679,6 +777,12 @@ func (ja *jobsAdmin) ResurrectJobParts() {
 		if err != nil {
 			continue
 		}
+		// a part that is already loaded may be in use, e.g. by a job this process is running, so it's left as it is
+		if jm, found := ja.JobMgr(jobID); found {
+			if _, found := jm.JobPartMgr(partNum); found {
+				continue
+			}
+		}
 		mmf := planFile.Map()
 		//todo : call the compute transfer function here for each job.
 		jm := ja.JobMgrEnsureExists(jobID, mmf.Plan().LogLevel, "")
@@ -696,6 +800,22 @@ func (ja *jobsAdmin) SetConcurrencySettingsToAuto() {
 	ja.concurrencyTuner = ja.createConcurrencyTuner()
 }
 
+// unloadJobs closes the plan files and logs of the given jobs, and forgets them, so that they are loaded afresh if
+// they're needed again. Jobs that are busy in this process are left alone, since their transfers are still using them.
+func (ja *jobsAdmin) unloadJobs(jobIDs []common.JobID) {
+	for _, jobID := range jobIDs {
+		jm, found := ja.JobMgr(jobID)
+		if !found || jm.(*jobMgr).isBusyInThisProcess() {
+			continue
+		}
+		jm.(*jobMgr).jobPartMgrs.Iterate(false, func(k common.PartNumber, v IJobPartMgr) {
+			v.Close()
+		})
+		jm.CloseLog()
+		ja.DeleteJob(jobID)
+	}
+}
+
 // TODO: I think something is wrong here: I think delete and cleanup should be merged together.
 // DeleteJobInfo api deletes an entry of given JobId the JobsInfo
 // TODO: add the clean up logic for all Jobparts.
@@ -740,6 +860,10 @@ func (ja *jobsAdmin) Log(level pipeline.LogLevel, msg string) { ja.logger.Log(le
 func (ja *jobsAdmin) Panic(err error)                         { ja.logger.Panic(err) }
 func (ja *jobsAdmin) CloseLog()                               { ja.logger.CloseLog() }
 
+func (ja *jobsAdmin) Uptime() time.Duration {
+	return time.Since(ja.startTime)
+}
+
 func (ja *jobsAdmin) CurrentMainPoolSize() int {
 	return int(atomic.LoadInt32(&ja.atomicCurrentMainPoolSize))
 }
@@ -0,0 +1,102 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// checksumFileWriter appends a line, in the format that md5sum -c reads, to the --write-checksums file for each
+// downloaded file. Transfers finish concurrently, so all the lines go through one goroutine, which writes each one whole
+type checksumFileWriter struct {
+	lines  chan string
+	closed chan error
+}
+
+func newChecksumFileWriter(path string) *checksumFileWriter {
+	w := &checksumFileWriter{
+		lines:  make(chan string, 1000),
+		closed: make(chan error, 1),
+	}
+	go w.main(path)
+	return w
+}
+
+// Add queues the line for the file at relativePath, whose content has the given MD5 hash
+func (w *checksumFileWriter) Add(md5Hash []byte, relativePath string) {
+	w.lines <- checksumLine(md5Hash, relativePath)
+}
+
+// Close waits until every line is in the file, then closes it, and ends the goroutine that writes it.
+// It returns the first error, if any, in writing the lines. Nothing can be added afterwards
+func (w *checksumFileWriter) Close() error {
+	close(w.lines)
+	return <-w.closed
+}
+
+func (w *checksumFileWriter) main(path string) {
+	// the file is appended to, not replaced, so that a resumed job, or several jobs, can add to the same one
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, common.DEFAULT_FILE_PERM)
+
+	for line := range w.lines {
+		// once anything has failed, the rest of the lines are dropped, since the file can't be trusted anyway
+		if err == nil {
+			_, err = f.WriteString(line)
+		}
+	}
+
+	if f != nil {
+		if err == nil {
+			err = f.Sync()
+		}
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	w.closed <- err
+}
+
+// checksumLine formats a line the way md5sum does: the hash, two spaces, then the file name.
+// As md5sum does, names with a backslash or line break in them are escaped, and the line is marked with a leading backslash
+func checksumLine(md5Hash []byte, relativePath string) string {
+	prefix := ""
+	if strings.ContainsAny(relativePath, "\\\n") {
+		prefix = "\\"
+		relativePath = strings.NewReplacer("\\", "\\\\", "\n", "\\n").Replace(relativePath)
+	}
+	return prefix + hex.EncodeToString(md5Hash) + "  " + relativePath + "\n"
+}
+
+// checksumRelativePath gives the path, with forward slashes, of the downloaded file at destination, relative to the
+// job's destination root, so that md5sum -c can be run from there
+func checksumRelativePath(destinationRoot, destination string) string {
+	rel, err := filepath.Rel(destinationRoot, destination)
+	if err != nil || rel == "." {
+		// a single file was downloaded, to the root itself
+		return filepath.Base(destination)
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,116 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"sync"
+)
+
+// How many chunks of any one transfer can wait their turn in the chunkInterleaver. Once a transfer has this many waiting,
+// scheduling its next chunk blocks, just as it would if it was put straight into a full chunk channel
+const maxInterleavedChunksPerTransfer = 32
+
+// chunkInterleaver takes turns, round-robin, between the transfers that have chunks waiting, when putting their chunks
+// into the chunk channel. Without it, one big file can fill the channel with its chunks, and all the other files
+// have to wait until it's nearly done.
+// The chunks of each transfer keep their order.
+type chunkInterleaver struct {
+	send func(chunkFunc) // puts a chunk in the chunk channel, blocking while the channel is full
+
+	mu             sync.Mutex
+	spaceAvailable *sync.Cond
+	queues         map[interface{}][]chunkFunc // the chunks waiting for each transfer
+	turns          []interface{}               // the transfers with waiting chunks, in the order they take their turns
+	nextTurn       int
+	chunksWaiting  chan struct{}
+}
+
+func newChunkInterleaver(send func(chunkFunc)) *chunkInterleaver {
+	ci := &chunkInterleaver{
+		send:          send,
+		queues:        make(map[interface{}][]chunkFunc),
+		chunksWaiting: make(chan struct{}, 1),
+	}
+	ci.spaceAvailable = sync.NewCond(&ci.mu)
+
+	go ci.interleaverBody()
+
+	return ci
+}
+
+// Schedule queues a chunk of the given transfer, until it's that transfer's turn
+func (ci *chunkInterleaver) Schedule(transfer interface{}, cf chunkFunc) {
+	ci.mu.Lock()
+	for len(ci.queues[transfer]) >= maxInterleavedChunksPerTransfer {
+		ci.spaceAvailable.Wait()
+	}
+	if _, ok := ci.queues[transfer]; !ok {
+		ci.turns = append(ci.turns, transfer)
+	}
+	ci.queues[transfer] = append(ci.queues[transfer], cf)
+	ci.mu.Unlock()
+
+	select {
+	case ci.chunksWaiting <- struct{}{}:
+	default:
+		// already signalled
+	}
+}
+
+// takeNext returns the first waiting chunk of the transfer whose turn it is
+func (ci *chunkInterleaver) takeNext() (chunkFunc, bool) {
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+
+	if len(ci.turns) == 0 {
+		return nil, false
+	}
+	if ci.nextTurn >= len(ci.turns) {
+		ci.nextTurn = 0
+	}
+
+	transfer := ci.turns[ci.nextTurn]
+	queue := ci.queues[transfer]
+	cf := queue[0]
+	queue[0] = nil // don't hold on to it, once it's been sent
+	if len(queue) == 1 {
+		// nothing more waiting for this transfer, so it drops out of the turns (until it schedules again)
+		delete(ci.queues, transfer)
+		ci.turns = append(ci.turns[:ci.nextTurn], ci.turns[ci.nextTurn+1:]...)
+	} else {
+		ci.queues[transfer] = queue[1:]
+		ci.nextTurn++
+	}
+
+	ci.spaceAvailable.Broadcast()
+	return cf, true
+}
+
+func (ci *chunkInterleaver) interleaverBody() {
+	for {
+		cf, ok := ci.takeNext()
+		if !ok {
+			<-ci.chunksWaiting
+			continue
+		}
+		ci.send(cf)
+	}
+}
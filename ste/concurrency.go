@@ -103,6 +103,17 @@ type ConcurrencySettings struct {
 	// (i.e. creates chunkfuncs)
 	TransferInitiationPoolSize *ConfiguredInt
 
+	// SmallFileThreshold is the size, in bytes, at or below which a local file is read and sent in one go by the small-file pool,
+	// instead of being prefetched by transfer initiation and sent by the main pool. Zero turns the small-file pool off.
+	SmallFileThreshold *ConfiguredInt
+
+	// SmallFilePoolSize is the size of the auxiliary goroutine pool that reads and sends small files
+	SmallFilePoolSize *ConfiguredInt
+
+	// CommitPoolSize is the size of the auxiliary goroutine pool that runs the epilogues (block list commits etc) of
+	// multi-block blobs. Zero means epilogues run on the main pool worker that finished the last block.
+	CommitPoolSize *ConfiguredInt
+
 	// EnumerationPoolSize is size of auxiliary goroutine pool used in enumerators (only some of which are in fact parallelized)
 	EnumerationPoolSize *ConfiguredInt
 
@@ -110,17 +121,22 @@ type ConcurrencySettings struct {
 	// on Linux, but is not necessary and should not be activate on Windows.
 	ParallelStatFiles *ConfiguredBool
 
+	// InterleaveChunks says whether the chunks of different transfers should take turns to go into the chunk channels,
+	// rather than each transfer queuing all of its chunks as fast as it can
+	InterleaveChunks *ConfiguredBool
+
 	// MaxIdleConnections is the max number of idle TCP connections to keep open
 	MaxIdleConnections int
 
-	// MaxOpenFiles is the max number of file handles that we should have open at any time
-	// Currently (July 2019) this is only used for downloads, which is where we wouldn't
-	// otherwise have strict control of the number of open files.
-	// For uploads, the number of open files is effectively controlled by
-	// TransferInitiationPoolSize, since all the file IO (except retries) happens in
-	// transfer initiation.
-	MaxOpenDownloadFiles int
-	// TODO: consider whether we should also use this (renamed to( MaxOpenFiles) for uploads, somehow (see command above). Is there any actual value in that? Maybe only highly handle-constrained Linux environments?
+	// ForceHTTP2 says whether to offer HTTP/2 when connecting. Our transport has its own dialer, so without this
+	// Go never attempts HTTP/2
+	ForceHTTP2 bool
+
+	// MaxOpenFiles is the max number of payload file handles that we should have open at any time.
+	// Downloads hold one for the whole transfer. Uploads hold one while their chunks are scheduled (or while
+	// a small file is read), but not for the brief re-reads done by retries, since those must never wait
+	// on transfers that are themselves waiting for the retries to free up RAM.
+	MaxOpenFiles int
 
 	// CheckCpuWhenTuning determines whether CPU usage should be taken into account when auto-tuning
 	CheckCpuWhenTuning *ConfiguredBool
@@ -133,26 +149,36 @@ func (c ConcurrencySettings) AutoTuneMainPool() bool {
 
 const defaultTransferInitiationPoolSize = 64
 const defaultEnumerationPoolSize = 16
+const defaultSmallFileThreshold = 0 // off unless asked for, since the small-file pool's workers come on top of the main pool's
+const defaultSmallFilePoolSize = 256
+const defaultCommitPoolSize = 64
 const concurrentFilesFloor = 32
 
 // NewConcurrencySettings gets concurrency settings by referring to the
 // environment variable AZCOPY_CONCURRENCY_VALUE (if set) and to properties of the
 // machine where we are running
-func NewConcurrencySettings(maxFileAndSocketHandles int, requestAutoTuneGRs bool) ConcurrencySettings {
+func NewConcurrencySettings(maxFileAndSocketHandles int, requestAutoTuneGRs bool) (ConcurrencySettings, error) {
 
-	initialMainPoolSize, maxMainPoolSize := getMainPoolSize(runtime.NumCPU(), requestAutoTuneGRs)
+	initialMainPoolSize, maxMainPoolSize, err := getMainPoolSize(runtime.NumCPU(), requestAutoTuneGRs)
+	if err != nil {
+		return ConcurrencySettings{}, err
+	}
 
 	s := ConcurrencySettings{
 		InitialMainPoolSize:        initialMainPoolSize,
 		MaxMainPoolSize:            maxMainPoolSize,
 		TransferInitiationPoolSize: getTransferInitiationPoolSize(),
+		SmallFileThreshold:         getSmallFileThreshold(),
+		SmallFilePoolSize:          getSmallFilePoolSize(),
+		CommitPoolSize:             getCommitPoolSize(),
 		EnumerationPoolSize:        GetEnumerationPoolSize(),
 		ParallelStatFiles:          GetParallelStatFiles(),
+		InterleaveChunks:           getInterleaveChunks(),
 		CheckCpuWhenTuning:         getCheckCpuUsageWhenTuning(),
 	}
 
-	s.MaxOpenDownloadFiles = getMaxOpenPayloadFiles(maxFileAndSocketHandles,
-		maxMainPoolSize.Value+s.TransferInitiationPoolSize.Value+s.EnumerationPoolSize.Value)
+	s.MaxOpenFiles = getMaxOpenPayloadFiles(maxFileAndSocketHandles,
+		maxMainPoolSize.Value+s.TransferInitiationPoolSize.Value+s.activeSmallFilePoolSize()+s.EnumerationPoolSize.Value)
 
 	// Set the max idle connections that we allow. If there are any more idle connections
 	// than this, they will be closed, and then will result in creation of new connections
@@ -168,12 +194,21 @@ func NewConcurrencySettings(maxFileAndSocketHandles int, requestAutoTuneGRs bool
 	// on Windows when this value was set to 500 but there were 1000 to 2000 goroutines in the
 	// main pool size.  Using DialContext appears to mitigate that issue, so the value
 	// we compute here is really just to reduce unneeded make and break of connections)
-	s.MaxIdleConnections = maxMainPoolSize.Value
+	// Small files are sent, and block lists committed, from their own pools, at the same time as the main pool is busy, so allow for all three.
+	s.MaxIdleConnections = maxMainPoolSize.Value + s.activeSmallFilePoolSize() + s.CommitPoolSize.Value
 
-	return s
+	return s, nil
 }
 
-func getMainPoolSize(numOfCPUs int, requestAutoTune bool) (initial int, max *ConfiguredInt) {
+// activeSmallFilePoolSize is the number of small-file workers that will actually run, which is none if the pool is turned off
+func (c ConcurrencySettings) activeSmallFilePoolSize() int {
+	if c.SmallFileThreshold.Value <= 0 {
+		return 0
+	}
+	return c.SmallFilePoolSize.Value
+}
+
+func getMainPoolSize(numOfCPUs int, requestAutoTune bool) (initial int, max *ConfiguredInt, err error) {
 
 	envVar := common.EEnvironmentVariable.ConcurrencyValue()
 
@@ -189,7 +224,7 @@ func getMainPoolSize(numOfCPUs int, requestAutoTune bool) (initial int, max *Con
 			// This case happens when benchmarking with a fixed value from the env var
 			common.GetLifecycleMgr().Info(fmt.Sprintf("Cannot auto-tune concurrency because it is fixed by environment variable %s", envVar.Name))
 		}
-		return c.Value, c // initial and max are same, fixed to the env var
+		return c.Value, c, nil // initial and max are same, fixed to the env var
 	}
 
 	var initialValue int
@@ -210,14 +245,33 @@ func getMainPoolSize(numOfCPUs int, requestAutoTune bool) (initial int, max *Con
 		initialValue = 16 * numOfCPUs
 	}
 
-	reason := "number of CPUs"
-	maxValue := initialValue
 	if requestAutoTune {
-		reason = "auto-tuning limit"
-		maxValue = 3000 // TODO: what should this be?  Testing indicates that this value is all we're ever likely to need, even in small-files cases
+		maxValue := &ConfiguredInt{3000, false, envVar.Name, "auto-tuning limit"} // TODO: what should this be?  Testing indicates that this value is all we're ever likely to need, even in small-files cases
+
+		// let advanced users bound the range that the tuner explores
+		// (the tuner never goes below its starting point, so the min is used as that starting point)
+		minValue := tryNewConfiguredInt(common.EEnvironmentVariable.AutoTuneMinConcurrency())
+		if c := tryNewConfiguredInt(common.EEnvironmentVariable.AutoTuneMaxConcurrency()); c != nil {
+			if c.Value < 1 {
+				return 0, nil, fmt.Errorf("invalid auto-tuning bounds: %s (%d) must be at least 1", c.EnvVarName, c.Value)
+			}
+			maxValue = c
+		}
+		if minValue == nil {
+			// only the max was given, so start no higher than that
+			if initialValue > maxValue.Value {
+				initialValue = maxValue.Value
+			}
+			return initialValue, maxValue, nil
+		}
+		if minValue.Value < 1 || minValue.Value > maxValue.Value {
+			return 0, nil, fmt.Errorf("invalid auto-tuning bounds: %s (%d) must be at least 1, and no greater than the max (%d)",
+				minValue.EnvVarName, minValue.Value, maxValue.Value)
+		}
+		return minValue.Value, maxValue, nil
 	}
 
-	return initialValue, &ConfiguredInt{maxValue, false, envVar.Name, reason}
+	return initialValue, &ConfiguredInt{initialValue, false, envVar.Name, "number of CPUs"}, nil
 }
 
 func getTransferInitiationPoolSize() *ConfiguredInt {
@@ -230,6 +284,36 @@ func getTransferInitiationPoolSize() *ConfiguredInt {
 	return &ConfiguredInt{defaultTransferInitiationPoolSize, false, envVar.Name, "hard-coded default"}
 }
 
+func getSmallFileThreshold() *ConfiguredInt {
+	envVar := common.EEnvironmentVariable.SmallFileThreshold()
+
+	if c := tryNewConfiguredInt(envVar); c != nil {
+		return c
+	}
+
+	return &ConfiguredInt{defaultSmallFileThreshold, false, envVar.Name, "hard-coded default"}
+}
+
+func getSmallFilePoolSize() *ConfiguredInt {
+	envVar := common.EEnvironmentVariable.SmallFilePoolSize()
+
+	if c := tryNewConfiguredInt(envVar); c != nil {
+		return c
+	}
+
+	return &ConfiguredInt{defaultSmallFilePoolSize, false, envVar.Name, "hard-coded default"}
+}
+
+func getCommitPoolSize() *ConfiguredInt {
+	envVar := common.EEnvironmentVariable.CommitPoolSize()
+
+	if c := tryNewConfiguredInt(envVar); c != nil {
+		return c
+	}
+
+	return &ConfiguredInt{defaultCommitPoolSize, false, envVar.Name, "hard-coded default"}
+}
+
 func GetEnumerationPoolSize() *ConfiguredInt {
 	envVar := common.EEnvironmentVariable.EnumerationPoolSize()
 
@@ -284,3 +368,12 @@ func getMaxOpenPayloadFiles(maxFileAndSocketHandles int, concurrentConnections i
 	return concurrentFilesLimit
 
 }
+
+func getInterleaveChunks() *ConfiguredBool {
+	envVar := common.EEnvironmentVariable.InterleaveChunks()
+	if c := tryNewConfiguredBool(envVar); c != nil {
+		return c
+	}
+
+	return &ConfiguredBool{false, false, envVar.Name, "hard-coded default"}
+}
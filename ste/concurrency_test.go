@@ -1,6 +1,10 @@
 package ste
 
 import (
+	"os"
+	"strings"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
 	chk "gopkg.in/check.v1"
 )
 
@@ -16,22 +20,122 @@ const (
 func (s *mainTestSuite) TestConcurrencyValue(c *chk.C) {
 	// weak machines
 	for i := 1; i < 5; i++ {
-		min, max := getMainPoolSize(i, false)
+		min, max, _ := getMainPoolSize(i, false)
 		c.Assert(min, chk.Equals, minConcurrency)
 		c.Assert(max.Value, chk.Equals, minConcurrency)
 	}
 
 	// moderately powerful machines
 	for i := 5; i < 19; i++ {
-		min, max := getMainPoolSize(i, false)
+		min, max, _ := getMainPoolSize(i, false)
 		c.Assert(min, chk.Equals, 16*i)
 		c.Assert(max.Value, chk.Equals, 16*i)
 	}
 
 	// powerful machines
 	for i := 19; i < 24; i++ {
-		min, max := getMainPoolSize(i, false)
+		min, max, _ := getMainPoolSize(i, false)
 		c.Assert(min, chk.Equals, maxConcurrency)
 		c.Assert(max.Value, chk.Equals, maxConcurrency)
 	}
 }
+
+func (s *mainTestSuite) TestConcurrencyAutoTuneBounds(c *chk.C) {
+	// defaults
+	min, max, err := getMainPoolSize(8, true)
+	c.Assert(err, chk.IsNil)
+	c.Assert(min, chk.Equals, 4)
+	c.Assert(max.Value, chk.Equals, 3000)
+	c.Assert(max.IsUserSpecified, chk.Equals, false)
+
+	// user-specified bounds
+	minEnv := common.EEnvironmentVariable.AutoTuneMinConcurrency().Name
+	maxEnv := common.EEnvironmentVariable.AutoTuneMaxConcurrency().Name
+	os.Setenv(minEnv, "16")
+	os.Setenv(maxEnv, "200")
+	defer os.Unsetenv(minEnv)
+	defer os.Unsetenv(maxEnv)
+
+	min, max, err = getMainPoolSize(8, true)
+	c.Assert(err, chk.IsNil)
+	c.Assert(min, chk.Equals, 16)
+	c.Assert(max.Value, chk.Equals, 200)
+	c.Assert(max.IsUserSpecified, chk.Equals, true)
+
+	// the bounds have no effect when we're not auto-tuning
+	min, max, err = getMainPoolSize(8, false)
+	c.Assert(err, chk.IsNil)
+	c.Assert(min, chk.Equals, 128)
+	c.Assert(max.Value, chk.Equals, 128)
+}
+
+func (s *mainTestSuite) TestConcurrencyAutoTuneMaxOnly(c *chk.C) {
+	maxEnv := common.EEnvironmentVariable.AutoTuneMaxConcurrency().Name
+	os.Setenv(maxEnv, "2")
+	defer os.Unsetenv(maxEnv)
+
+	// the tuner's usual starting point is above the max, so it starts at the max instead
+	min, max, err := getMainPoolSize(8, true)
+	c.Assert(err, chk.IsNil)
+	c.Assert(min, chk.Equals, 2)
+	c.Assert(max.Value, chk.Equals, 2)
+}
+
+func (s *mainTestSuite) TestConcurrencyAutoTuneInvalidBounds(c *chk.C) {
+	minEnv := common.EEnvironmentVariable.AutoTuneMinConcurrency().Name
+	maxEnv := common.EEnvironmentVariable.AutoTuneMaxConcurrency().Name
+	defer os.Unsetenv(minEnv)
+	defer os.Unsetenv(maxEnv)
+
+	// each error names the variable at fault
+	for _, bounds := range []struct{ min, max, culprit string }{
+		{"", "0", maxEnv},
+		{"0", "", minEnv},
+		{"300", "200", minEnv},
+	} {
+		os.Setenv(minEnv, bounds.min)
+		os.Setenv(maxEnv, bounds.max)
+		_, _, err := getMainPoolSize(8, true)
+		c.Assert(err, chk.NotNil)
+		c.Assert(strings.Contains(err.Error(), bounds.culprit), chk.Equals, true, chk.Commentf("error %q", err))
+	}
+}
+
+func (s *mainTestSuite) TestSmallFileSettings(c *chk.C) {
+	// off by default, in which case the pool needs no connections
+	settings, err := NewConcurrencySettings(10000, false)
+	c.Assert(err, chk.IsNil)
+	c.Assert(settings.SmallFileThreshold.Value, chk.Equals, 0)
+	c.Assert(settings.activeSmallFilePoolSize(), chk.Equals, 0)
+	c.Assert(settings.MaxIdleConnections, chk.Equals, settings.MaxMainPoolSize.Value+defaultCommitPoolSize)
+
+	// turned on by the user
+	thresholdEnv := common.EEnvironmentVariable.SmallFileThreshold().Name
+	os.Setenv(thresholdEnv, "131072")
+	defer os.Unsetenv(thresholdEnv)
+
+	settings, err = NewConcurrencySettings(10000, false)
+	c.Assert(err, chk.IsNil)
+	c.Assert(settings.SmallFileThreshold.IsUserSpecified, chk.Equals, true)
+	c.Assert(settings.SmallFileThreshold.Value, chk.Equals, 128*1024)
+	c.Assert(settings.activeSmallFilePoolSize(), chk.Equals, 256)
+	c.Assert(settings.MaxIdleConnections, chk.Equals, settings.MaxMainPoolSize.Value+256+defaultCommitPoolSize)
+}
+
+func (s *mainTestSuite) TestCommitPoolSettings(c *chk.C) {
+	settings, err := NewConcurrencySettings(10000, false)
+	c.Assert(err, chk.IsNil)
+	c.Assert(settings.CommitPoolSize.Value, chk.Equals, 64)
+	c.Assert(settings.CommitPoolSize.IsUserSpecified, chk.Equals, false)
+
+	// turned off by the user, in which case the pool needs no connections
+	commitEnv := common.EEnvironmentVariable.CommitPoolSize().Name
+	os.Setenv(commitEnv, "0")
+	defer os.Unsetenv(commitEnv)
+
+	settings, err = NewConcurrencySettings(10000, false)
+	c.Assert(err, chk.IsNil)
+	c.Assert(settings.CommitPoolSize.Value, chk.Equals, 0)
+	c.Assert(settings.CommitPoolSize.IsUserSpecified, chk.Equals, true)
+	c.Assert(settings.MaxIdleConnections, chk.Equals, settings.MaxMainPoolSize.Value+settings.activeSmallFilePoolSize())
+}
@@ -0,0 +1,86 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+var explainedSnapshotsPresentOnce sync.Once
+
+func isSnapshotsPresent(err error) bool {
+	stgErr, ok := err.(azblob.StorageError)
+	return ok && stgErr.Response() != nil && stgErr.Response().StatusCode == http.StatusConflict &&
+		stgErr.ServiceCode() == azblob.ServiceCodeSnapshotsPresent
+}
+
+// overwriteDespiteSnapshots makes a write that replaces the destination blob. The service refuses some of those, such
+// as changing the type of the blob, while the blob has snapshots. If it does, and --delete-snapshots says what to do
+// with them, the snapshots (and, with 'include', the blob itself) are deleted and the write is made once more.
+// Otherwise the error is returned as is, after explaining the flag to the user.
+func overwriteDespiteSnapshots(jptm IJobPartTransferMgr, dest azblob.BlobURL, write func() error) error {
+	return overwriteDespiteSnapshotsDeleting(jptm, dest, jptm.DeleteSnapshotsOption(), write)
+}
+
+// commitDespiteSnapshots is overwriteDespiteSnapshots for Put Block List. The blocks to commit have already been staged
+// on the blob, and would be deleted along with it, so only the snapshots are deleted, even with --delete-snapshots=include.
+func commitDespiteSnapshots(jptm IJobPartTransferMgr, dest azblob.BlobURL, commit func() error) error {
+	deleteSnapshots := jptm.DeleteSnapshotsOption()
+	if deleteSnapshots == common.EDeleteSnapshotsOption.Include() {
+		deleteSnapshots = common.EDeleteSnapshotsOption.Only()
+	}
+	return overwriteDespiteSnapshotsDeleting(jptm, dest, deleteSnapshots, commit)
+}
+
+func overwriteDespiteSnapshotsDeleting(jptm IJobPartTransferMgr, dest azblob.BlobURL, deleteSnapshots common.DeleteSnapshotsOption, write func() error) error {
+	err := write()
+	if err == nil || !isSnapshotsPresent(err) {
+		return err
+	}
+
+	destination := strings.Split(dest.String(), "?")[0]
+	if deleteSnapshots == common.EDeleteSnapshotsOption.None() {
+		explainedSnapshotsPresentOnce.Do(func() {
+			common.GetLifecycleMgr().Info("Some destination blobs could not be overwritten because they have snapshots. " +
+				"Please specify --delete-snapshots=include to delete those blobs along with their snapshots before overwriting them, " +
+				"or --delete-snapshots=only to delete just the snapshots.")
+		})
+		jptm.Log(pipeline.LogError, fmt.Sprintf("OVERWRITE FAILED(blob has snapshots, see --delete-snapshots): %s", destination))
+		return err
+	}
+
+	jptm.Log(pipeline.LogInfo, fmt.Sprintf("Deleting snapshots (--delete-snapshots=%s) so that %s can be overwritten", strings.ToLower(deleteSnapshots.String()), destination))
+	if _, delErr := dest.Delete(jptm.Context(), deleteSnapshots.ToDeleteSnapshotsOptionType(), azblob.BlobAccessConditions{}); delErr != nil {
+		if stgErr, ok := delErr.(azblob.StorageError); !ok || stgErr.Response() == nil || stgErr.Response().StatusCode != http.StatusNotFound {
+			return delErr
+		}
+	}
+
+	return write()
+}
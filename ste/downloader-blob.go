@@ -22,6 +22,7 @@ package ste
 
 import (
 	"net/url"
+	"sync/atomic"
 
 	"github.com/Azure/azure-pipeline-go/pipeline"
 	"github.com/Azure/azure-storage-azcopy/v10/common"
@@ -37,6 +38,9 @@ type blobDownloader struct {
 
 	// used to avoid downloading zero ranges of page blobs
 	pageRangeOptimizer *pageRangeOptimizer
+
+	// the ETag returned with the downloaded data, so that a paused download can tell if the blob changed before it was resumed
+	sourceETag atomic.Value
 }
 
 func newBlobDownloader() downloader {
@@ -62,6 +66,27 @@ func (bd *blobDownloader) Epilogue() {
 	_ = bd.filePacer.Close()
 }
 
+func (bd *blobDownloader) SourceETag() string {
+	etag, _ := bd.sourceETag.Load().(string)
+	return etag
+}
+
+func (bd *blobDownloader) CurrentSourceETag(jptm IJobPartTransferMgr, srcPipeline pipeline.Pipeline) (string, error) {
+	u, err := url.Parse(jptm.Info().Source)
+	if err != nil {
+		return "", err
+	}
+	clientProvidedKey := azblob.ClientProvidedKeyOptions{}
+	if jptm.IsSourceEncrypted() {
+		clientProvidedKey = common.ToClientProvidedKeyOptions(jptm.CpkInfo(), jptm.CpkScopeInfo())
+	}
+	props, err := azblob.NewBlobURL(*u, srcPipeline).GetProperties(jptm.Context(), azblob.BlobAccessConditions{}, clientProvidedKey)
+	if err != nil {
+		return "", err
+	}
+	return string(props.ETag()), nil
+}
+
 // Returns a chunk-func for blob downloads
 func (bd *blobDownloader) GenerateDownloadFunc(jptm IJobPartTransferMgr, srcPipeline pipeline.Pipeline, destWriter common.ChunkedFileWriter, id common.ChunkID, length int64, pacer pacer) chunkFunc {
 	return createDownloadChunkFunc(jptm, id, func() {
@@ -121,6 +146,7 @@ func (bd *blobDownloader) GenerateDownloadFunc(jptm IJobPartTransferMgr, srcPipe
 			jptm.FailActiveDownload("Downloading response body", err) // cancel entire transfer because this chunk has failed
 			return
 		}
+		bd.sourceETag.Store(string(get.ETag()))
 
 		// Enqueue the response body to be written out to disk
 		// The retryReader encapsulates any retries that may be necessary while downloading the body
@@ -46,6 +46,17 @@ type folderDownloader interface {
 	SetFolderProperties(jptm IJobPartTransferMgr) error
 }
 
+// resumableDownloader is a downloader whose partial downloads can be kept when the job is paused, and continued when it is resumed
+type resumableDownloader interface {
+	downloader
+
+	// SourceETag returns the ETag of the source, as seen in the data downloaded so far. Empty if nothing has been downloaded yet
+	SourceETag() string
+
+	// CurrentSourceETag asks the service for the ETag of the source as it is now
+	CurrentSourceETag(jptm IJobPartTransferMgr, srcPipeline pipeline.Pipeline) (string, error)
+}
+
 // smbPropertyAwareDownloader is a windows-triggered interface.
 // Code outside of windows-specific files shouldn't implement this ever.
 type smbPropertyAwareDownloader interface {
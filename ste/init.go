@@ -21,12 +21,17 @@
 package ste
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"math"
+	"net"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/Azure/azure-pipeline-go/pipeline"
@@ -56,9 +61,9 @@ func ToFixed(num float64, precision int) float64 {
 }
 
 // MainSTE initializes the Storage Transfer Engine
-func MainSTE(concurrency ConcurrencySettings, targetRateInMegaBitsPerSec float64, azcopyJobPlanFolder, azcopyLogPathFolder string, providePerfAdvice bool) error {
+func MainSTE(concurrency ConcurrencySettings, capMbpsSchedule common.CapMbpsSchedule, azcopyJobPlanFolder, azcopyLogPathFolder string, providePerfAdvice bool) error {
 	// Initialize the JobsAdmin, resurrect Job plan files
-	initJobsAdmin(steCtx, concurrency, targetRateInMegaBitsPerSec, azcopyJobPlanFolder, azcopyLogPathFolder, providePerfAdvice)
+	initJobsAdmin(steCtx, concurrency, capMbpsSchedule, azcopyJobPlanFolder, azcopyLogPathFolder, providePerfAdvice)
 	// No need to read the existing JobPartPlan files since Azcopy is running in process
 	//JobsAdmin.ResurrectJobParts()
 	// TODO: We may want to list listen first and terminate if there is already an instance listening
@@ -79,6 +84,31 @@ func MainSTE(concurrency ConcurrencySettings, targetRateInMegaBitsPerSec float64
 		environmentMimeMap = config.MIMETypeMapping
 	}
 
+	// optionally, only accept requests signed with the session secret shared with the front end
+	auth, err := common.NewRpcAuthenticatorFromEnv()
+	if err != nil {
+		return err
+	}
+	rpcHandler = newRpcHandler(auth)
+	return nil
+}
+
+// rpcHandler answers the front end's commands when they come over HTTP, rather than from inside this process
+var rpcHandler http.Handler
+
+// ServeRPC answers the commands sent to the listener by front ends in other processes (see --ste-url),
+// until the listener fails. MainSTE must have been called first.
+func ServeRPC(listener net.Listener) error {
+	return http.Serve(listener, rpcHandler)
+}
+
+// newRpcHandler routes each RPC command, which is sent to its own path, to the STE function that carries it out
+func newRpcHandler(auth *common.RpcAuthenticator) *http.ServeMux {
+	mux := http.NewServeMux()
+	authorize := func(rpcCmd common.RpcCmd, handler http.HandlerFunc) http.HandlerFunc {
+		return authorizeRpc(auth, rpcCmd, handler)
+	}
+
 	deserialize := func(request *http.Request, v interface{}) {
 		// TODO: Check the HTTP verb here?
 		// reading the entire request body and closing the request body
@@ -90,7 +120,7 @@ func MainSTE(concurrency ConcurrencySettings, targetRateInMegaBitsPerSec float64
 		json.Unmarshal(body, v)
 	}
 	serialize := func(v interface{}, response http.ResponseWriter) {
-		payload, err := json.Marshal(response)
+		payload, err := json.Marshal(v)
 		if err != nil {
 			JobsAdmin.Panic(fmt.Errorf("error serializing HTTP response"))
 		}
@@ -98,62 +128,87 @@ func MainSTE(concurrency ConcurrencySettings, targetRateInMegaBitsPerSec float64
 		response.WriteHeader(http.StatusAccepted)
 		response.Write(payload)
 	}
-	http.HandleFunc(common.ERpcCmd.CopyJobPartOrder().Pattern(),
-		func(writer http.ResponseWriter, request *http.Request) {
+	mux.HandleFunc(common.ERpcCmd.CopyJobPartOrder().Pattern(),
+		authorize(common.ERpcCmd.CopyJobPartOrder(), func(writer http.ResponseWriter, request *http.Request) {
 			var payload common.CopyJobPartOrderRequest
 			deserialize(request, &payload)
 			serialize(ExecuteNewCopyJobPartOrder(payload), writer)
-		})
-	http.HandleFunc(common.ERpcCmd.ListJobs().Pattern(),
-		func(writer http.ResponseWriter, request *http.Request) {
-			//var payload common.ListRequest
-			//deserialize(request, &payload)
-			serialize(ListJobs(common.EJobStatus.All()), writer)
-		})
-	http.HandleFunc(common.ERpcCmd.ListJobSummary().Pattern(),
-		func(writer http.ResponseWriter, request *http.Request) {
+		}))
+	mux.HandleFunc(common.ERpcCmd.ListJobs().Pattern(),
+		authorize(common.ERpcCmd.ListJobs(), func(writer http.ResponseWriter, request *http.Request) {
+			var payload common.JobStatus
+			deserialize(request, &payload)
+			serialize(ListJobs(payload), writer)
+		}))
+	mux.HandleFunc(common.ERpcCmd.ListJobSummary().Pattern(),
+		authorize(common.ERpcCmd.ListJobSummary(), func(writer http.ResponseWriter, request *http.Request) {
 			var payload common.JobID
 			deserialize(request, &payload)
 			serialize(GetJobSummary(payload), writer)
-		})
-	http.HandleFunc(common.ERpcCmd.ListJobTransfers().Pattern(),
-		func(writer http.ResponseWriter, request *http.Request) {
+		}))
+	mux.HandleFunc(common.ERpcCmd.ListJobTransfers().Pattern(),
+		authorize(common.ERpcCmd.ListJobTransfers(), func(writer http.ResponseWriter, request *http.Request) {
 			var payload common.ListJobTransfersRequest
 			deserialize(request, &payload)
 			serialize(ListJobTransfers(payload), writer) // TODO: make struct
-		})
-	http.HandleFunc(common.ERpcCmd.CancelJob().Pattern(),
-		func(writer http.ResponseWriter, request *http.Request) {
+		}))
+	mux.HandleFunc(common.ERpcCmd.CancelJob().Pattern(),
+		authorize(common.ERpcCmd.CancelJob(), func(writer http.ResponseWriter, request *http.Request) {
 			var payload common.JobID
 			deserialize(request, &payload)
 			serialize(CancelPauseJobOrder(payload, common.EJobStatus.Cancelling()), writer)
-		})
-	http.HandleFunc(common.ERpcCmd.PauseJob().Pattern(),
-		func(writer http.ResponseWriter, request *http.Request) {
+		}))
+	mux.HandleFunc(common.ERpcCmd.PauseJob().Pattern(),
+		authorize(common.ERpcCmd.PauseJob(), func(writer http.ResponseWriter, request *http.Request) {
 			var payload common.JobID
 			deserialize(request, &payload)
 			serialize(CancelPauseJobOrder(payload, common.EJobStatus.Paused()), writer)
-		})
-	http.HandleFunc(common.ERpcCmd.ResumeJob().Pattern(),
-		func(writer http.ResponseWriter, request *http.Request) {
+		}))
+	mux.HandleFunc(common.ERpcCmd.ResumeJob().Pattern(),
+		authorize(common.ERpcCmd.ResumeJob(), func(writer http.ResponseWriter, request *http.Request) {
 			var payload common.ResumeJobRequest
 			deserialize(request, &payload)
 			serialize(ResumeJobOrder(payload), writer)
-		})
+		}))
 
-	http.HandleFunc(common.ERpcCmd.GetJobFromTo().Pattern(),
-		func(writer http.ResponseWriter, request *http.Request) {
+	mux.HandleFunc(common.ERpcCmd.GetJobFromTo().Pattern(),
+		authorize(common.ERpcCmd.GetJobFromTo(), func(writer http.ResponseWriter, request *http.Request) {
 			var payload common.GetJobFromToRequest
 			deserialize(request, &payload)
 			serialize(GetJobFromTo(payload), writer)
-		})
+		}))
+
+	mux.HandleFunc(common.ERpcCmd.RemoveJobFiles().Pattern(),
+		authorize(common.ERpcCmd.RemoveJobFiles(), func(writer http.ResponseWriter, request *http.Request) {
+			var payload common.RemoveJobFilesRequest
+			deserialize(request, &payload)
+			serialize(RemoveJobFiles(payload), writer)
+		}))
+
+	mux.HandleFunc(common.ERpcCmd.Ping().Pattern(),
+		authorize(common.ERpcCmd.Ping(), func(writer http.ResponseWriter, request *http.Request) {
+			serialize(Ping(), writer)
+		}))
 
-	// Listen for front-end requests
-	//if err := http.ListenAndServe("localhost:1337", nil); err != nil {
-	//	fmt.Print("Server already initialized")
-	//	return err
-	//}
-	return nil // TODO: don't return (like normal main)
+	return mux
+}
+
+// authorizeRpc wraps the handler for an RPC command, so that it only sees requests signed with the session secret.
+// Others are answered with 401. A nil auth means authentication is disabled, and the handler is returned as is.
+func authorizeRpc(auth *common.RpcAuthenticator, rpcCmd common.RpcCmd, handler http.HandlerFunc) http.HandlerFunc {
+	if auth == nil {
+		return handler
+	}
+	return func(writer http.ResponseWriter, request *http.Request) {
+		body, err := ioutil.ReadAll(request.Body)
+		request.Body.Close()
+		if err != nil || !auth.IsAuthorized(request.Header.Get("Authorization"), rpcCmd, body) {
+			writer.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		handler(writer, request)
+	}
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -251,14 +306,25 @@ func CancelPauseJobOrder(jobID common.JobID, desiredJobStatus common.JobStatus)
 		// Job immediately stop.
 		fallthrough
 	case common.EJobStatus.Paused(): // Logically, It's OK to pause an already-paused job
-		jpp0.SetJobStatus(desiredJobStatus)
 		msg := fmt.Sprintf("JobID=%v %s", jobID,
 			common.IffString(desiredJobStatus == common.EJobStatus.Paused(), "paused", "canceled"))
+		stop := func() {
+			jpp0.SetJobStatus(desiredJobStatus)
+			if jm.ShouldLog(pipeline.LogInfo) {
+				jm.Log(pipeline.LogInfo, msg)
+			}
+			jm.Cancel() // Stop all inflight-chunks/transfer for this job (this includes all parts)
+		}
 
-		if jm.ShouldLog(pipeline.LogInfo) {
-			jm.Log(pipeline.LogInfo, msg)
+		if desiredJobStatus == common.EJobStatus.Paused() {
+			// Let the chunks that are already being sent finish first, so that their work is kept for the resume.
+			// That can take a while, so the job is stopped once they have, and the caller polls for the job to show as paused.
+			if !jm.(*jobMgr).drainInFlightChunksThen(pauseDrainTimeout, stop) {
+				msg = fmt.Sprintf("JobID=%v is already being paused", jobID)
+			}
+		} else {
+			stop()
 		}
-		jm.Cancel() // Stop all inflight-chunks/transfer for this job (this includes all parts)
 		jr = common.CancelPauseResumeResponse{
 			CancelledPauseResumed: true,
 			ErrorMsg:              msg,
@@ -267,6 +333,19 @@ func CancelPauseJobOrder(jobID common.JobID, desiredJobStatus common.JobStatus)
 	return jr
 }
 
+// jobNotResurrectedMessage explains why a job could not be loaded from its plan files, given the versions of the plan files
+// that were found for it. The usual reason is that there are none, but they may have been written by an AzCopy version
+// that uses another plan file format.
+func jobNotResurrectedMessage(jobID common.JobID, versionsFound []common.Version) string {
+	for _, v := range versionsFound {
+		if v != DataSchemaVersion {
+			return fmt.Sprintf("job %v was planned by a different version of AzCopy, which saves job plans in format %d, "+
+				"but this version reads format %d. Resume the job with the version of AzCopy that started it", jobID, v, DataSchemaVersion)
+		}
+	}
+	return fmt.Sprintf("no job with JobId %v exists", jobID)
+}
+
 func ResumeJobOrder(req common.ResumeJobRequest) common.CancelPauseResumeResponse {
 	// Strip '?' if present as first character of the source sas / destination sas
 	if len(req.SourceSAS) > 0 && req.SourceSAS[0] == '?' {
@@ -280,7 +359,7 @@ func ResumeJobOrder(req common.ResumeJobRequest) common.CancelPauseResumeRespons
 	if !JobsAdmin.ResurrectJob(req.JobID, req.SourceSAS, req.DestinationSAS) {
 		return common.CancelPauseResumeResponse{
 			CancelledPauseResumed: false,
-			ErrorMsg:              fmt.Sprintf("no job with JobId %v exists", req.JobID),
+			ErrorMsg:              jobNotResurrectedMessage(req.JobID, planFileVersionsOfJob(JobsAdmin.AppPathFolder(), req.JobID)),
 		}
 	}
 	// If the job manager was not found, then Job was resurrected
@@ -461,25 +540,31 @@ func GetJobSummary(jobID common.JobID) common.ListJobSummaryResponse {
 		jm, _ = JobsAdmin.JobMgr(jobID)
 	}
 
-	js := jm.ListJobSummary()
-	js.Timestamp = time.Now().UTC()
-	js.JobID = jm.JobID()
-	js.ErrorMsg = ""
-
 	part0, ok := jm.JobPartMgr(0)
 	if !ok {
+		js := jm.ListJobSummary()
+		js.Timestamp = time.Now().UTC()
+		js.JobID = jm.JobID()
+		js.ErrorMsg = ""
 		return js
 	}
-	part0PlanStatus := part0.Plan().JobStatus()
 
-	// Add on byte count from files in flight, to get a more accurate running total
-	js.TotalBytesTransferred += JobsAdmin.SuccessfulBytesInActiveFiles()
-	if js.TotalBytesExpected == 0 {
-		// if no bytes expected, and we should avoid dividing by 0 (which results in NaN)
-		js.PercentComplete = 100
+	var js common.ListJobSummaryResponse
+	if jm.(*jobMgr).isRunningInThisProcess() {
+		js = jm.ListJobSummary()
+		// Add on byte count from files in flight, to get a more accurate running total
+		js.TotalBytesTransferred += JobsAdmin.SuccessfulBytesInActiveFiles()
 	} else {
-		js.PercentComplete = 100 * float32(js.TotalBytesTransferred) / float32(js.TotalBytesExpected)
+		// Any progress is being made by another process, and the plan files are the only record of it we can see.
+		// So count everything up again, including how far through their chunks the in-flight files are
+		js = resurrectJobSummary(jm)
+		js.TotalBytesTransferred += inFlightBytesFromPlans(jm)
 	}
+	js.Timestamp = time.Now().UTC()
+	js.JobID = jm.JobID()
+	js.ErrorMsg = ""
+	part0PlanStatus := part0.Plan().JobStatus()
+	js.PercentComplete = percentComplete(js.TotalBytesTransferred, js.TotalBytesExpected)
 
 	// This is added to let FE to continue fetching the Job Progress Summary
 	// in case of resume. In case of resume, the Job is already completely
@@ -489,6 +574,15 @@ func GetJobSummary(jobID common.JobID) common.ListJobSummaryResponse {
 
 	js.BytesOverWire = uint64(JobsAdmin.BytesOverWire())
 
+	// estimate speed and time remaining from the recent rate, rather than the all-time average
+	bytesPerSecond := jm.(*jobMgr).throughput.update(js.TotalBytesTransferred, time.Now())
+	js.ThroughputMbps = bytesPerSecond * 8 / (1000 * 1000)
+	bytesRemaining := uint64(0)
+	if js.TotalBytesExpected > js.TotalBytesTransferred {
+		bytesRemaining = js.TotalBytesExpected - js.TotalBytesTransferred
+	}
+	js.EstimatedSecondsRemaining = estimateSecondsRemaining(bytesRemaining, bytesPerSecond)
+
 	// Get the number of active go routines performing the transfer or executing the chunk Func
 	// TODO: added for debugging purpose. remove later (is covered by GetPerfInfo now anyway)
 	js.ActiveConnections = jm.ActiveConnections()
@@ -502,6 +596,7 @@ func GetJobSummary(jobID common.JobID) common.ListJobSummaryResponse {
 		js.NetworkErrorPercentage = pipeStats.NetworkErrorPercentage()
 		js.ServerBusyPercentage = pipeStats.TotalServerBusyPercentage()
 	}
+	js.RetryCount = transferMetrics.retryCount()
 
 	// If the status is cancelled, then no need to check for completerJobOrdered
 	// since user must have provided the consent to cancel an incompleteJob if that
@@ -509,6 +604,11 @@ func GetJobSummary(jobID common.JobID) common.ListJobSummaryResponse {
 	if part0PlanStatus == common.EJobStatus.Cancelled() {
 		js.JobStatus = part0PlanStatus
 		js.PerformanceAdvice = jm.TryGetPerformanceAdvice(js.TotalBytesExpected, js.TotalTransfers-js.TransfersSkipped, part0.Plan().FromTo)
+	} else if part0PlanStatus == common.EJobStatus.Paused() {
+		// only report the pause once nothing is in flight, so that the FE doesn't exit while transfers are still stopping
+		if jm.(*jobMgr).isPauseVisible() {
+			js.JobStatus = part0PlanStatus
+		}
 	} else {
 		// Job is completed if Job order is complete AND ALL transfers are completed/failed
 		// FIX: active or inactive state, then job order is said to be completed if final part of job has been ordered.
@@ -524,6 +624,30 @@ func GetJobSummary(jobID common.JobID) common.ListJobSummaryResponse {
 	return js
 }
 
+// percentComplete is the share of the expected bytes that have been transferred. Since in-flight files contribute the bytes
+// of the chunks they have completed, a job of a single huge file still moves steadily from 0 to 100
+func percentComplete(bytesTransferred, bytesExpected uint64) float32 {
+	if bytesExpected == 0 {
+		// if no bytes expected, and we should avoid dividing by 0 (which results in NaN)
+		return 100
+	}
+	return 100 * float32(bytesTransferred) / float32(bytesExpected)
+}
+
+// inFlightBytesFromPlans adds up the progress recorded in the plan files for transfers that have started but not yet finished
+func inFlightBytesFromPlans(jm IJobMgr) uint64 {
+	inFlight := uint64(0)
+	jm.(*jobMgr).jobPartMgrs.Iterate(true, func(partNum common.PartNumber, jpm IJobPartMgr) {
+		jpp := jpm.Plan()
+		for t := uint32(0); t < jpp.NumTransfers; t++ {
+			if jppt := jpp.Transfer(t); jppt.TransferStatus() == common.ETransferStatus.Started() {
+				inFlight += jppt.InFlightBytes()
+			}
+		}
+	})
+	return inFlight
+}
+
 func resurrectJobSummary(jm IJobMgr) common.ListJobSummaryResponse {
 	js := common.ListJobSummaryResponse{
 		Timestamp:          time.Now().UTC(),
@@ -586,7 +710,8 @@ func resurrectJobSummary(jm IJobMgr) common.ListJobSummaryResponse {
 						Dst:                dst,
 						IsFolderProperties: isFolder,
 						TransferStatus:     common.ETransferStatus.Failed(),
-						ErrorCode:          jppt.ErrorCode()}) // TODO: Optimize
+						ErrorCode:          jppt.ErrorCode(),
+						FailureReason:      failureReasonFromErrorCode(jppt.ErrorCode())}) // TODO: Optimize
 			case common.ETransferStatus.SkippedEntityAlreadyExists(),
 				common.ETransferStatus.SkippedBlobHasSnapshots():
 				js.TransfersSkipped++
@@ -605,12 +730,7 @@ func resurrectJobSummary(jm IJobMgr) common.ListJobSummaryResponse {
 
 	// Add on byte count from files in flight, to get a more accurate running total
 	js.TotalBytesTransferred += JobsAdmin.SuccessfulBytesInActiveFiles()
-	if js.TotalBytesExpected == 0 {
-		// if no bytes expected, and we should avoid dividing by 0 (which results in NaN)
-		js.PercentComplete = 100
-	} else {
-		js.PercentComplete = 100 * float32(js.TotalBytesTransferred) / float32(js.TotalBytesExpected)
-	}
+	js.PercentComplete = percentComplete(js.TotalBytesTransferred, js.TotalBytesExpected)
 
 	// This is added to let FE to continue fetching the Job Progress Summary
 	// in case of resume. In case of resume, the Job is already completely
@@ -633,6 +753,7 @@ func resurrectJobSummary(jm IJobMgr) common.ListJobSummaryResponse {
 		js.NetworkErrorPercentage = pipeStats.NetworkErrorPercentage()
 		js.ServerBusyPercentage = pipeStats.TotalServerBusyPercentage()
 	}
+	js.RetryCount = transferMetrics.retryCount()
 
 	// If the status is cancelled, then no need to check for completerJobOrdered
 	// since user must have provided the consent to cancel an incompleteJob if that
@@ -704,8 +825,14 @@ func ListJobTransfers(r common.ListJobTransfersRequest) common.ListJobTransfersR
 			}
 			// getting source and destination of a transfer at index index for given jobId and part number.
 			src, dst, isFolder := jpp.TransferSrcDstStrings(t)
-			ljt.Details = append(ljt.Details,
-				common.TransferDetail{Src: src, Dst: dst, IsFolderProperties: isFolder, TransferStatus: transferEntry.TransferStatus(), ErrorCode: transferEntry.ErrorCode()})
+			detail := common.TransferDetail{Src: src, Dst: dst, IsFolderProperties: isFolder, TransferStatus: transferEntry.TransferStatus(),
+				TransferSize: uint64(transferEntry.SourceSize), ErrorCode: transferEntry.ErrorCode(), ContentMD5: transferEntry.ContentMD5(),
+				ETag: transferEntry.ETag(), FailureReason: failureReasonFromErrorCode(transferEntry.ErrorCode())}
+			// filter here, rather than in the front end, so that only the matches are sent back
+			if !transferMatchesGrep(detail, r.Grep) {
+				continue
+			}
+			ljt.Details = append(ljt.Details, detail)
 		}
 	}
 	return ljt
@@ -727,15 +854,22 @@ func GetJobLCMWrapper(jobID common.JobID) common.LifecycleMgr {
 
 // ListJobs returns the jobId of all the jobs existing in the current instance of azcopy
 func ListJobs(givenStatus common.JobStatus) common.ListJobsResponse {
-	// Resurrect all the Jobs from the existing JobPart Plan files
+	// Resurrect all the Jobs from the existing JobPart Plan files. The ones that weren't loaded already are unloaded
+	// again afterwards; the others may be in use, e.g. by jobs this process is running.
+	alreadyLoaded := make(map[common.JobID]bool)
+	for _, jobID := range JobsAdmin.JobIDs() {
+		alreadyLoaded[jobID] = true
+	}
 	JobsAdmin.ResurrectJobParts()
 	// building the ListJobsResponse for sending response back to front-end
 	jobIds := JobsAdmin.JobIDs()
+	loadedHere := make([]common.JobID, 0, len(jobIds))
+	defer func() { JobsAdmin.(*jobsAdmin).unloadJobs(loadedHere) }()
 	// Silently ignore if no JobIDs are present.
 	if len(jobIds) == 0 {
 		return common.ListJobsResponse{}
 	}
-	listJobResponse := common.ListJobsResponse{JobIDDetails: []common.JobIDDetails{}}
+	jobs := make([]common.JobIDDetails, 0, len(jobIds))
 	for _, jobId := range jobIds {
 		jm, found := JobsAdmin.JobMgr(jobId)
 		if !found {
@@ -745,19 +879,230 @@ func ListJobs(givenStatus common.JobStatus) common.ListJobsResponse {
 		if !found {
 			continue
 		}
-		if givenStatus == common.EJobStatus.All() || givenStatus == jpm.Plan().JobStatus() {
-			listJobResponse.JobIDDetails = append(listJobResponse.JobIDDetails,
-				common.JobIDDetails{JobId: jobId, CommandString: jpm.Plan().CommandString(),
-					StartTime: jpm.Plan().StartTime, JobStatus: jpm.Plan().JobStatus()})
-		}
+		details := common.JobIDDetails{JobId: jobId, CommandString: jpm.Plan().CommandString(),
+			StartTime: jpm.Plan().StartTime, JobStatus: jpm.Plan().JobStatus()}
 
-		// Close the job part managers and the log.
+		// Count the transfers across all the parts
 		jm.(*jobMgr).jobPartMgrs.Iterate(false, func(k common.PartNumber, v IJobPartMgr) {
-			v.Close()
+			details.TotalTransfers += v.Plan().NumTransfers
+		})
+		if !alreadyLoaded[jobId] {
+			loadedHere = append(loadedHere, jobId)
+		}
+
+		jobs = append(jobs, details)
+	}
+	return common.ListJobsResponse{JobIDDetails: filterJobsByStatus(jobs, givenStatus)}
+}
+
+// Ping reports that the STE is alive, along with its version, how long it has been running and how many jobs it is working on.
+// Unlike ListJobs, it only looks at the jobs already loaded in memory, so that it stays cheap enough to call at any time.
+func Ping() common.PingResponse {
+	active := 0
+	for _, jobID := range JobsAdmin.JobIDs() {
+		jm, found := JobsAdmin.JobMgr(jobID)
+		if !found {
+			continue
+		}
+		jpm, found := jm.JobPartMgr(0)
+		if !found {
+			continue
+		}
+		if (jobFilesInfo{jobID: jobID, status: jpm.Plan().JobStatus()}).isRunning() {
+			active++
+		}
+	}
+	return common.PingResponse{
+		Version:    common.AzcopyVersion,
+		Uptime:     JobsAdmin.Uptime(),
+		ActiveJobs: active,
+	}
+}
+
+// transferMatchesGrep tells whether the transfer's source, destination or failure reason contains grep.
+// Every transfer matches an empty grep
+func transferMatchesGrep(t common.TransferDetail, grep string) bool {
+	return grep == "" ||
+		strings.Contains(t.Src, grep) ||
+		strings.Contains(t.Dst, grep) ||
+		strings.Contains(t.FailureReason, grep)
+}
+
+// filterJobsByStatus keeps only the jobs with the given status, or all of them if the status is All
+func filterJobsByStatus(jobs []common.JobIDDetails, givenStatus common.JobStatus) []common.JobIDDetails {
+	filtered := []common.JobIDDetails{}
+	for _, j := range jobs {
+		if givenStatus == common.EJobStatus.All() || givenStatus == j.JobStatus {
+			filtered = append(filtered, j)
+		}
+	}
+	return filtered
+}
+
+// jobFilesInfo is what RemoveJobFiles needs to know about a job to decide whether its files may be removed
+type jobFilesInfo struct {
+	jobID     common.JobID
+	status    common.JobStatus
+	startTime time.Time
+
+	runningInThisProcess bool
+}
+
+// a job which is still in progress (or being cancelled) is using its plan files, so they must not be removed
+func (j jobFilesInfo) isRunning() bool {
+	return j.status == common.EJobStatus.InProgress() || j.status == common.EJobStatus.Cancelling()
+}
+
+// mayBeRemoved says whether the job's files can be removed. If the process running a job is killed, nothing updates
+// its status again, so it looks like it's running forever. Forcing lets its files go, but never those of a job this
+// process is running, whose transfers may still be using them even once its status has changed, e.g. while a pause
+// takes effect.
+func (j jobFilesInfo) mayBeRemoved(force bool) bool {
+	return !j.runningInThisProcess && (!j.isRunning() || force)
+}
+
+// selectJobsToRemove applies the status and age filters of the request,
+// splitting the matching jobs into those that can be removed and those that are still running
+func selectJobsToRemove(jobs []jobFilesInfo, req common.RemoveJobFilesRequest, now time.Time) (toRemove []common.JobID, running []common.JobID) {
+	for _, j := range jobs {
+		if !req.JobID.IsEmpty() && j.jobID != req.JobID {
+			continue
+		}
+		if req.WithStatus != common.EJobStatus.All() && j.status != req.WithStatus {
+			continue
+		}
+		if req.OlderThan > 0 && now.Sub(j.startTime) < req.OlderThan {
+			continue
+		}
+		if !j.mayBeRemoved(req.Force) {
+			running = append(running, j.jobID)
+			continue
+		}
+		toRemove = append(toRemove, j.jobID)
+	}
+	return
+}
+
+// removeFilesWithPredicate removes all files whose names are approved by the predicate in the targetFolder,
+// and returns how many files and bytes were removed
+func removeFilesWithPredicate(targetFolder string, predicate func(string) bool) (count int, bytes int64, err error) {
+	files, err := ioutil.ReadDir(targetFolder)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, f := range files {
+		if f.IsDir() || !predicate(f.Name()) {
+			continue
+		}
+		if err = os.Remove(filepath.Join(targetFolder, f.Name())); err != nil {
+			return count, bytes, err
+		}
+		count++
+		bytes += f.Size()
+	}
+	return count, bytes, nil
+}
+
+// RemoveJobFiles api deletes the plan files and logs of the jobs selected by the request.
+// Jobs that are still in progress are skipped, and reported back to the caller.
+func RemoveJobFiles(req common.RemoveJobFilesRequest) common.RemoveJobFilesResponse {
+	// Resurrect all the Jobs from the existing JobPart Plan files, so that we know their status and start time
+	JobsAdmin.ResurrectJobParts()
+	jobs := make([]jobFilesInfo, 0)
+	for _, jobID := range JobsAdmin.JobIDs() {
+		jm, found := JobsAdmin.JobMgr(jobID)
+		if !found {
+			continue
+		}
+		jpm, found := jm.JobPartMgr(0)
+		if !found {
+			continue
+		}
+		jobs = append(jobs, jobFilesInfo{jobID: jobID, status: jpm.Plan().JobStatus(), startTime: time.Unix(0, jpm.Plan().StartTime),
+			runningInThisProcess: jm.(*jobMgr).isBusyInThisProcess()})
+	}
+
+	// a job whose plan files were written by another version of AzCopy cannot be resurrected, and therefore cannot be running either
+	if !req.JobID.IsEmpty() && !containsJob(jobs, req.JobID) {
+		jobs = append(jobs, jobFilesInfo{jobID: req.JobID, status: common.EJobStatus.Completed()})
+	}
+
+	toRemove, running := selectJobsToRemove(jobs, req, time.Now())
+	resp := common.RemoveJobFilesResponse{RemovedJobs: []common.JobID{}, SkippedRunningJobs: running}
+	if len(running) == 1 && running[0] == req.JobID {
+		resp.ErrorMsg = fmt.Sprintf("job %s is still in progress, so its files cannot be removed", req.JobID)
+		if !req.Force {
+			resp.ErrorMsg += ". If the AzCopy process running it was killed, use --force to remove them anyway"
+		}
+		return resp
+	}
+
+	// Close the plan files and logs of the jobs being removed, so that the files can be deleted
+	JobsAdmin.(*jobsAdmin).unloadJobs(toRemove)
+
+	planDir, logDir := JobsAdmin.AppPathFolder(), JobsAdmin.(*jobsAdmin).logDir
+	remove := func(belongsToJob func(name string) bool) error {
+		for _, folder := range []struct {
+			path      string
+			isJobFile func(name string) bool
+		}{
+			{planDir, func(name string) bool { return strings.Contains(name, ".steV") }},
+			{logDir, func(name string) bool { return strings.HasSuffix(name, ".log") }},
+		} {
+			count, bytes, err := removeFilesWithPredicate(folder.path, func(name string) bool {
+				return folder.isJobFile(name) && belongsToJob(name)
+			})
+			resp.FilesRemoved += count
+			resp.BytesReclaimed += bytes
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, jobID := range toRemove {
+		filesBefore := resp.FilesRemoved
+		if err := remove(func(name string) bool { return strings.Contains(name, jobID.String()) }); err != nil {
+			resp.ErrorMsg = fmt.Sprintf("failed to remove the files of job %s: %s", jobID, err)
+			return resp
+		}
+		if resp.FilesRemoved > filesBefore {
+			resp.RemovedJobs = append(resp.RemovedJobs, jobID)
+		}
+	}
+
+	if !req.JobID.IsEmpty() && len(resp.RemovedJobs) == 0 {
+		resp.ErrorMsg = "cannot find any log or job plan file with the specified ID"
+		return resp
+	}
+
+	// when removing everything, also sweep up the files that do not belong to any job we know of,
+	// such as plan files from other versions of AzCopy and the logs of jobs that were never ordered
+	if req.JobID.IsEmpty() && req.WithStatus == common.EJobStatus.All() && req.OlderThan == 0 {
+		err := remove(func(name string) bool {
+			for _, jobID := range running {
+				if strings.Contains(name, jobID.String()) {
+					return false
+				}
+			}
+			return true
 		})
-		jm.CloseLog()
+		if err != nil {
+			resp.ErrorMsg = fmt.Sprintf("failed to remove log/plan files: %s", err)
+		}
+	}
+	return resp
+}
+
+func containsJob(jobs []jobFilesInfo, jobID common.JobID) bool {
+	for _, j := range jobs {
+		if j.jobID == jobID {
+			return true
+		}
 	}
-	return listJobResponse
+	return false
 }
 
 // GetJobFromTo api returns the job FromTo info.
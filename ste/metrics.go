@@ -0,0 +1,133 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package ste
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// transferMetrics counts what the STE has done, across all jobs, for the optional Prometheus metrics endpoint.
+// Counting is cheap enough that it's always on, whether or not the endpoint has been started
+var transferMetrics = &steMetrics{}
+
+type steMetrics struct {
+	atomicChunksSucceeded  int64
+	atomicChunksFailed     int64
+	atomicRetries          int64
+	atomicActiveTransfers  int64
+	atomicTransfersStarted int64
+}
+
+func (m *steMetrics) chunkDone(succeeded bool) {
+	if succeeded {
+		atomic.AddInt64(&m.atomicChunksSucceeded, 1)
+	} else {
+		atomic.AddInt64(&m.atomicChunksFailed, 1)
+	}
+}
+
+func (m *steMetrics) retry() {
+	atomic.AddInt64(&m.atomicRetries, 1)
+}
+
+func (m *steMetrics) retryCount() int64 {
+	return atomic.LoadInt64(&m.atomicRetries)
+}
+
+func (m *steMetrics) transferStarted() {
+	atomic.AddInt64(&m.atomicTransfersStarted, 1)
+	atomic.AddInt64(&m.atomicActiveTransfers, 1)
+}
+
+func (m *steMetrics) transferDone() {
+	atomic.AddInt64(&m.atomicActiveTransfers, -1)
+}
+
+// metricsHandler serves the metrics in the Prometheus text exposition format.
+// Throughput is averaged over the time since the previous scrape (or since the handler was made, for the first one),
+// so it matches the scrape interval that Prometheus is using
+type metricsHandler struct {
+	metrics       *steMetrics
+	bytesOverWire func() int64
+
+	mu            sync.Mutex
+	lastBytes     int64
+	lastScrapedAt time.Time
+}
+
+func newMetricsHandler(metrics *steMetrics, bytesOverWire func() int64) *metricsHandler {
+	return &metricsHandler{
+		metrics:       metrics,
+		bytesOverWire: bytesOverWire,
+		lastBytes:     bytesOverWire(),
+		lastScrapedAt: time.Now(),
+	}
+}
+
+func (h *metricsHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	bytes := h.bytesOverWire()
+
+	h.mu.Lock()
+	now := time.Now()
+	bytesPerSecond := 0.0
+	if elapsed := now.Sub(h.lastScrapedAt).Seconds(); elapsed > 0 {
+		bytesPerSecond = float64(bytes-h.lastBytes) / elapsed
+	}
+	h.lastBytes, h.lastScrapedAt = bytes, now
+	h.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeMetric(w, "azcopy_bytes_transferred_total", "counter", "Bytes sent or received over the network by all jobs.", float64(bytes))
+	writeMetric(w, "azcopy_throughput_bytes_per_second", "gauge", "Network throughput since the previous scrape.", bytesPerSecond)
+	writeMetric(w, "azcopy_chunks_succeeded_total", "counter", "Chunks that were transferred successfully.",
+		float64(atomic.LoadInt64(&h.metrics.atomicChunksSucceeded)))
+	writeMetric(w, "azcopy_chunks_failed_total", "counter", "Chunks that finished without being transferred, because their transfer failed or was cancelled.",
+		float64(atomic.LoadInt64(&h.metrics.atomicChunksFailed)))
+	writeMetric(w, "azcopy_retries_total", "counter", "Requests to Blob or ADLS Gen2 that were retried.",
+		float64(atomic.LoadInt64(&h.metrics.atomicRetries)))
+	writeMetric(w, "azcopy_transfers_started_total", "counter", "Transfers that have been started.",
+		float64(atomic.LoadInt64(&h.metrics.atomicTransfersStarted)))
+	writeMetric(w, "azcopy_active_transfers", "gauge", "Transfers that have been started and have not finished yet.",
+		float64(atomic.LoadInt64(&h.metrics.atomicActiveTransfers)))
+}
+
+func writeMetric(w io.Writer, name, metricType, help string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", name, help, name, metricType, name, value)
+}
+
+// StartMetricsServer serves Prometheus metrics at http://localhost:<port>/metrics, until the process exits.
+// The port is opened before this returns, so that a port that's already in use is reported as an error
+func StartMetricsServer(port int) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", port))
+	if err != nil {
+		return fmt.Errorf("cannot serve metrics: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", newMetricsHandler(transferMetrics, JobsAdmin.BytesOverWire))
+	go http.Serve(listener, mux)
+	return nil
+}
@@ -80,6 +80,8 @@ type IJobMgr interface {
 	HttpClient() *http.Client
 	PipelineNetworkStats() *pipelineNetworkStats
 	getOverwritePrompter() *overwritePrompter
+	wasResumed() bool
+	holdableChunk(schedule func(chunkFunc), chunkFunc chunkFunc) chunkFunc
 	common.ILoggerCloser
 
 	/* Status related functions */
@@ -96,21 +98,19 @@ func newJobMgr(concurrency ConcurrencySettings, jobID common.JobID, appCtx conte
 	enableChunkLogOutput := level.ToPipelineLogLevel() == pipeline.LogDebug
 	/* Create book-keeping channels */
 	jobPartProgressCh := make(chan jobPartProgressInfo)
-	jstm.respChan = make(chan common.ListJobSummaryResponse)
-	jstm.listReq = make(chan bool)
-	jstm.partCreated = make(chan jobPartCreatedMsg, 100)
-	jstm.xferDone = make(chan xferDoneMsg, 1000)
 
 	jm := jobMgr{jobID: jobID, jobPartMgrs: newJobPartToJobPartMgr(), include: map[string]int{}, exclude: map[string]int{},
-		httpClient:                    NewAzcopyHTTPClient(concurrency.MaxIdleConnections),
+		httpClient:                    newAzcopyHTTPClient(concurrency.MaxIdleConnections, concurrency.ForceHTTP2),
 		logger:                        common.NewJobLogger(jobID, level, logFileFolder, ""),
 		chunkStatusLogger:             common.NewChunkStatusLogger(jobID, cpuMon, logFileFolder, enableChunkLogOutput),
 		concurrency:                   concurrency,
-		overwritePrompter:             newOverwritePrompter(),
+		overwritePrompter:             newOverwritePrompter(func() { CancelPauseJobOrder(jobID, common.EJobStatus.Cancelling()) }),
 		pipelineNetworkStats:          newPipelineNetworkStats(JobsAdmin.(*jobsAdmin).concurrencyTuner), // let the stats coordinate with the concurrency tuner
 		exclusiveDestinationMapHolder: &atomic.Value{},
 		initMu:                        &sync.Mutex{},
 		jobPartProgress:               jobPartProgressCh,
+		jstm:                          newJobStatusManager(),
+		throughput:                    &throughputEstimator{},
 		/*Other fields remain zero-value until this job is scheduled */}
 	jm.reset(appCtx, commandString)
 	jm.logJobsAdminMessages()
@@ -133,12 +133,71 @@ func (jm *jobMgr) reset(appCtx context.Context, commandString string) IJobMgr {
 	}
 	jm.logConcurrencyParameters()
 	jm.ctx, jm.cancel = context.WithCancel(appCtx)
+	jm.startWatchingForExternalCancellation()
+	atomic.StoreInt32(&jm.atomicPauseCompleteIndicator, 0)
 	atomic.StoreUint64(&jm.atomicNumberOfBytesCovered, 0)
 	atomic.StoreUint64(&jm.atomicTotalBytesToXfer, 0)
 	jm.partsDone = 0
 	return jm
 }
 
+// how often we check whether another AzCopy process has asked for this job to stop
+const externalCancellationCheckInterval = 2 * time.Second
+
+// startWatchingForExternalCancellation starts a watcher for the job's current context,
+// after stopping the one started for its previous context (if any), so that each reset doesn't leave another behind
+func (jm *jobMgr) startWatchingForExternalCancellation() {
+	if jm.stopWatchingForExternalCancellation != nil {
+		jm.stopWatchingForExternalCancellation()
+	}
+	var watchCtx context.Context
+	watchCtx, jm.stopWatchingForExternalCancellation = context.WithCancel(jm.ctx)
+	go jm.watchForExternalCancellation(watchCtx, jm.cancel)
+}
+
+// watchForExternalCancellation lets the cancel (and pause) commands work from a different AzCopy process.
+// They don't talk to this process directly; instead they set the job status in the (shared, memory-mapped)
+// plan file of part 0. So we watch that status, and cancel our in-flight transfers when we see it change.
+func (jm *jobMgr) watchForExternalCancellation(ctx context.Context, cancel context.CancelFunc) {
+	ticker := time.NewTicker(externalCancellationCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return // already cancelled, or this process is exiting
+		case <-ticker.C:
+			if !jm.checkForExternalCancellation(cancel) {
+				return
+			}
+		}
+	}
+}
+
+// checkForExternalCancellation calls cancel if the job has been cancelled or paused.
+// It returns false once there is no need to keep checking.
+func (jm *jobMgr) checkForExternalCancellation(cancel context.CancelFunc) (keepWatching bool) {
+	jpm, found := jm.jobPartMgrs.Get(0)
+	if !found {
+		return true // part 0 hasn't been ordered yet
+	}
+
+	status := jpm.Plan().JobStatus()
+	switch {
+	case status == common.EJobStatus.Cancelling() || status == common.EJobStatus.Paused():
+		if jm.ShouldLog(pipeline.LogInfo) {
+			jm.Log(pipeline.LogInfo, fmt.Sprintf("JobID=%v was %s by another process", jm.jobID,
+				common.IffString(status == common.EJobStatus.Paused(), "paused", "canceled")))
+		}
+		cancel()
+		return false
+	case status.IsJobDone():
+		return false
+	default:
+		return true
+	}
+}
+
 func (jm *jobMgr) logConcurrencyParameters() {
 	level := pipeline.LogWarning // log all this stuff at warning level, so that it can still be see it when running at that level. (It won't have the WARN prefix, because we don't add that)
 
@@ -164,6 +223,18 @@ func (jm *jobMgr) logConcurrencyParameters() {
 		jm.concurrency.TransferInitiationPoolSize.Value,
 		jm.concurrency.TransferInitiationPoolSize.GetDescription()))
 
+	jm.logger.Log(level, fmt.Sprintf("Max size of small files sent in one go: %d bytes (%s)",
+		jm.concurrency.SmallFileThreshold.Value,
+		jm.concurrency.SmallFileThreshold.GetDescription()))
+
+	jm.logger.Log(level, fmt.Sprintf("Max concurrent small file routines: %d (%s)",
+		jm.concurrency.activeSmallFilePoolSize(),
+		jm.concurrency.SmallFilePoolSize.GetDescription()))
+
+	jm.logger.Log(level, fmt.Sprintf("Max concurrent block list commit routines: %d (%s)",
+		jm.concurrency.CommitPoolSize.Value,
+		jm.concurrency.CommitPoolSize.GetDescription()))
+
 	jm.logger.Log(level, fmt.Sprintf("Max enumeration routines: %d (%s)",
 		jm.concurrency.EnumerationPoolSize.Value,
 		jm.concurrency.EnumerationPoolSize.GetDescription()))
@@ -172,8 +243,8 @@ func (jm *jobMgr) logConcurrencyParameters() {
 		jm.concurrency.ParallelStatFiles.Value,
 		jm.concurrency.ParallelStatFiles.GetDescription()))
 
-	jm.logger.Log(level, fmt.Sprintf("Max open files when downloading: %d (auto-computed)",
-		jm.concurrency.MaxOpenDownloadFiles))
+	jm.logger.Log(level, fmt.Sprintf("Max open files: %d",
+		jm.concurrency.MaxOpenFiles))
 }
 
 // jobMgrInitState holds one-time init structures (such as SIPM), that initialize when the first part is added.
@@ -196,7 +267,15 @@ type jobMgr struct {
 	// atomicAllTransfersScheduled defines whether all job parts have been iterated and resumed or not
 	atomicAllTransfersScheduled     int32
 	atomicFinalPartOrderedIndicator int32
-	atomicTransferDirection         common.TransferDirection
+	// atomicPauseCompleteIndicator is set to 1 once the job has been paused and all its in-flight transfers have stopped
+	atomicPauseCompleteIndicator int32
+	// atomicRunningInThisProcess is set to 1 once this process has queued any of the job's transfers
+	atomicRunningInThisProcess int32
+	// atomicResumedIndicator is set to 1 once the job has been resumed, so an earlier run may have done some of its work
+	atomicResumedIndicator int32
+	// atomicChunkProcessingHeld is set to 1 while the job's chunks that have not yet started are held back
+	atomicChunkProcessingHeld int32
+	atomicTransferDirection   common.TransferDirection
 
 	concurrency          ConcurrencySettings
 	logger               common.ILoggerResetable
@@ -206,6 +285,9 @@ type jobMgr struct {
 	cancel               context.CancelFunc
 	pipelineNetworkStats *pipelineNetworkStats
 
+	// stopWatchingForExternalCancellation stops the goroutine watching for the job to be cancelled by another process
+	stopWatchingForExternalCancellation context.CancelFunc
+
 	exclusiveDestinationMapHolder *atomic.Value
 
 	// Share the same HTTP Client across all job parts, so that the we maximize re-use of
@@ -232,7 +314,17 @@ type jobMgr struct {
 	initMu    *sync.Mutex
 	initState *jobMgrInitState
 
+	// the writer of the job's --write-checksums file, created when the first line is written to it
+	checksumFileMu sync.Mutex
+	checksumFile   *checksumFileWriter
+
 	jobPartProgress chan jobPartProgressInfo
+
+	// jstm keeps the job's summary up to date, from the messages sent to it as parts are created and transfers finish
+	jstm *jobStatusManager
+
+	// smoothed throughput, for progress reporting
+	throughput *throughputEstimator
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
@@ -342,7 +434,7 @@ func (jm *jobMgr) TryGetPerformanceAdvice(bytesInJob uint64, filesInJob uint32,
 
 	dir := jm.atomicTransferDirection.AtomicLoad()
 	isToAzureFiles := fromTo.To() == common.ELocation.File()
-	a := NewPerformanceAdvisor(jm.pipelineNetworkStats, ja.commandLineMbpsCap, int64(megabitsPerSec), finalReason, finalConcurrency, dir, averageBytesPerFile, isToAzureFiles)
+	a := NewPerformanceAdvisor(jm.pipelineNetworkStats, ja.capMbpsSchedule.CapAt(time.Now()), int64(megabitsPerSec), finalReason, finalConcurrency, dir, averageBytesPerFile, isToAzureFiles)
 	return a.GetAdvice()
 }
 
@@ -379,6 +471,7 @@ func (jm *jobMgr) AddJobPart(partNum PartNumber, planFile JobPartPlanFileName, e
 	jpm.jobMgrInitState = jm.initState // so jpm can use it as much as desired without locking (since the only mutation is the init in jobManager. As far as jobPartManager is concerned, the init state is read-only
 
 	if scheduleTransfers {
+		atomic.StoreInt32(&jm.atomicRunningInThisProcess, 1)
 		// If the schedule transfer is set to true
 		// Instead of the scheduling the Transfer for given JobPart
 		// JobPart is put into the partChannel
@@ -463,12 +556,39 @@ func (jm *jobMgr) ResumeTransfers(appCtx context.Context) {
 	// Since while creating the JobMgr, atomicAllTransfersScheduled is set to true
 	// reset it to false while resuming it
 	//jm.ResetAllTransfersScheduled()
+	atomic.StoreInt32(&jm.atomicRunningInThisProcess, 1)
+	atomic.StoreInt32(&jm.atomicResumedIndicator, 1)
 	jm.jobPartMgrs.Iterate(false, func(p common.PartNumber, jpm IJobPartMgr) {
 		JobsAdmin.QueueJobParts(jpm)
 		//jpm.ScheduleTransfers(jm.ctx, includeTransfer, excludeTransfer)
 	})
 }
 
+// isRunningInThisProcess says whether this process is executing the job, rather than having only loaded its plan files,
+// e.g. to show the progress of a job that another process is running
+func (jm *jobMgr) isRunningInThisProcess() bool {
+	return atomic.LoadInt32(&jm.atomicRunningInThisProcess) == 1
+}
+
+// isBusyInThisProcess says whether this process is running the job, and the job's transfers may still be using its
+// plan files and log: i.e. it hasn't finished, and hasn't completed being paused
+func (jm *jobMgr) isBusyInThisProcess() bool {
+	if !jm.isRunningInThisProcess() || jm.isPauseComplete() {
+		return false
+	}
+	jpm, found := jm.jobPartMgrs.Get(0)
+	if !found {
+		return true
+	}
+	status := jpm.Plan().JobStatus()
+	return !status.IsJobDone()
+}
+
+// wasResumed says whether this process resumed the job, rather than starting it
+func (jm *jobMgr) wasResumed() bool {
+	return atomic.LoadInt32(&jm.atomicResumedIndicator) == 1
+}
+
 // AllTransfersScheduled returns whether Job has completely resumed or not
 func (jm *jobMgr) AllTransfersScheduled() bool {
 	return atomic.LoadInt32(&jm.atomicAllTransfersScheduled) == 1
@@ -511,7 +631,7 @@ func (jm *jobMgr) reportJobPartDoneHandler() {
 		// JobPart 0 status is not changed (unless we are cancelling)
 		haveFinalPart = atomic.LoadInt32(&jm.atomicFinalPartOrderedIndicator) == 1
 		allKnownPartsDone := partsDone == jm.jobPartMgrs.Count()
-		isCancelling := jobStatus == common.EJobStatus.Cancelling()
+		isCancelling := jobStatus == common.EJobStatus.Cancelling() || jobStatus == common.EJobStatus.Paused()
 		shouldComplete := allKnownPartsDone && (haveFinalPart || isCancelling)
 		if shouldComplete {
 			partDescription := "all parts of entire Job"
@@ -522,12 +642,20 @@ func (jm *jobMgr) reportJobPartDoneHandler() {
 				jm.Log(pipeline.LogInfo, fmt.Sprintf("%s %s successfully completed, cancelled or paused", partDescription, jm.jobID.String()))
 			}
 
+			// the checksums must all be written before the job is seen to be done
+			jm.closeChecksumFile()
+
 			switch part0Plan.JobStatus() {
 			case common.EJobStatus.Cancelling():
 				part0Plan.SetJobStatus(common.EJobStatus.Cancelled())
 				if shouldLog {
 					jm.Log(pipeline.LogInfo, fmt.Sprintf("%s %v successfully cancelled", partDescription, jm.jobID))
 				}
+			case common.EJobStatus.Paused():
+				atomic.StoreInt32(&jm.atomicPauseCompleteIndicator, 1)
+				if shouldLog {
+					jm.Log(pipeline.LogInfo, fmt.Sprintf("%s %v successfully paused", partDescription, jm.jobID))
+				}
 			case common.EJobStatus.InProgress():
 				part0Plan.SetJobStatus((common.EJobStatus).EnhanceJobStatusInfo(jobProgressInfo.transfersSkipped > 0,
 					jobProgressInfo.transfersFailed > 0,
@@ -548,6 +676,91 @@ func (jm *jobMgr) reportJobPartDoneHandler() {
 	}
 }
 
+// isPauseComplete says whether the job has been paused, and everything that was in flight has stopped
+func (jm *jobMgr) isPauseComplete() bool {
+	return atomic.LoadInt32(&jm.atomicPauseCompleteIndicator) == 1
+}
+
+// isPauseVisible says whether a paused job can be reported as paused. If this process is running the job, that's once
+// its in-flight transfers have stopped. If not (e.g. it was paused by another process), there's nothing in flight here
+// to wait for, and the pause will never be completed in this process.
+func (jm *jobMgr) isPauseVisible() bool {
+	return !jm.isRunningInThisProcess() || jm.isPauseComplete()
+}
+
+// pauseDrainTimeout is how long a pause waits for the chunks that are already running to finish, before stopping them.
+// It's kept well inside the grace period that container orchestrators allow between SIGTERM and killing the process.
+const pauseDrainTimeout = 20 * time.Second
+
+// drainInFlightChunksThen holds back the chunks that have not started yet, and waits (up to the timeout) for those
+// that are already running to finish, so that their work is kept for when the job is resumed. Then it calls stop,
+// which must cancel the job, and lets the held chunks run again so that they see the cancellation and do nothing.
+// If this process is running the job, the wait happens in the background, so that the caller isn't held up by it.
+// It returns false, and does nothing, if the job's chunks are already being drained.
+func (jm *jobMgr) drainInFlightChunksThen(timeout time.Duration, stop func()) bool {
+	if !jm.holdChunkProcessing() {
+		return false
+	}
+	drain := func() {
+		if !waitForChunksToFinish(jm.ActiveConnections, timeout) && jm.ShouldLog(pipeline.LogWarning) {
+			jm.Log(pipeline.LogWarning, fmt.Sprintf("%d chunks were still running after waiting %v, and will be abandoned", jm.ActiveConnections(), timeout))
+		}
+		stop()
+		jm.releaseChunkProcessing()
+	}
+	if jm.isRunningInThisProcess() {
+		go drain()
+	} else {
+		drain() // nothing is running here, and the process may be about to exit
+	}
+	return true
+}
+
+// holdChunkProcessing stops this job's chunks from starting, until releaseChunkProcessing is called.
+// Chunks that are already running carry on, and the chunks of other jobs are not affected.
+// It returns false if they were already held.
+func (jm *jobMgr) holdChunkProcessing() bool {
+	return atomic.CompareAndSwapInt32(&jm.atomicChunkProcessingHeld, 0, 1)
+}
+
+func (jm *jobMgr) releaseChunkProcessing() {
+	atomic.StoreInt32(&jm.atomicChunkProcessingHeld, 0)
+}
+
+func (jm *jobMgr) isChunkProcessingHeld() bool {
+	return atomic.LoadInt32(&jm.atomicChunkProcessingHeld) == 1
+}
+
+// holdableChunk wraps one of the job's chunk funcs so that, if the job's chunk processing is held when a worker picks
+// it up, it goes back in the queue (by way of schedule) instead of running. The worker is free to run other jobs'
+// chunks meanwhile.
+func (jm *jobMgr) holdableChunk(schedule func(chunkFunc), chunkFunc chunkFunc) chunkFunc {
+	var holdable func(workerID int)
+	holdable = func(workerID int) {
+		if jm.isChunkProcessingHeld() {
+			go func() {
+				time.Sleep(100 * time.Millisecond) // so that a held chunk doesn't keep the workers spinning
+				schedule(holdable)
+			}()
+			return
+		}
+		chunkFunc(workerID)
+	}
+	return holdable
+}
+
+// waitForChunksToFinish polls until no chunks are running, or the timeout has passed. It says whether they all finished.
+func waitForChunksToFinish(activeChunks func() int64, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for activeChunks() > 0 {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return true
+}
+
 func (jm *jobMgr) getInMemoryTransitJobState() InMemoryTransitJobState {
 	return jm.inMemoryTransitJobState
 }
@@ -578,6 +791,34 @@ func (jm *jobMgr) ChunkStatusLogger() common.ChunkStatusLogger {
 	return jm.chunkStatusLogger
 }
 
+// checksumFileWriter returns the writer of the --write-checksums file at path. All the parts of the job share it
+func (jm *jobMgr) checksumFileWriter(path string) *checksumFileWriter {
+	jm.checksumFileMu.Lock()
+	defer jm.checksumFileMu.Unlock()
+	if jm.checksumFile == nil {
+		jm.checksumFile = newChecksumFileWriter(path)
+	}
+	return jm.checksumFile
+}
+
+// closeChecksumFile makes sure that the lines of all the finished downloads are in the --write-checksums file, if there
+// is one, and closes it. If the job is resumed, checksumFileWriter opens it again, to append to it
+func (jm *jobMgr) closeChecksumFile() {
+	jm.checksumFileMu.Lock()
+	checksums := jm.checksumFile
+	jm.checksumFile = nil
+	jm.checksumFileMu.Unlock()
+	if checksums == nil {
+		return
+	}
+
+	if err := checksums.Close(); err != nil {
+		msg := "Failed to write the checksum file: " + err.Error()
+		jm.Log(pipeline.LogError, msg)
+		common.GetLifecycleMgr().Info(msg)
+	}
+}
+
 // TODO: find a better way for JobsAdmin to log (it doesn't have direct access to the job log, because it was originally designed to support multiple jobs
 func (jm *jobMgr) logJobsAdminMessages() {
 	for {
@@ -31,11 +31,20 @@ type IJobPartMgr interface {
 	GetOverwriteOption() common.OverwriteOption
 	GetForceIfReadOnly() bool
 	AutoDecompress() bool
+	Compress() bool
 	ScheduleChunks(chunkFunc chunkFunc)
+	ScheduleTransferChunk(transfer IJobPartTransferMgr, chunkFunc chunkFunc)
+	ScheduleSmallFileSend(chunkFunc chunkFunc)
+	SmallFileThreshold() int64
+	ScheduleCommit(commitFunc func())
 	RescheduleTransfer(jptm IJobPartTransferMgr)
 	BlobTypeOverride() common.BlobType
 	BlobTiers() (blockBlobTier common.BlockBlobTier, pageBlobTier common.PageBlobTier)
 	ShouldPutMd5() bool
+	ShouldUploadIfNoneMatch() bool
+	ShouldSnapshotBeforeOverwrite() bool
+	ShouldReplaceProperties() bool
+	ShouldValidateBlocks() bool
 	SAS() (string, string)
 	//CancelJob()
 	Close()
@@ -92,6 +101,11 @@ func NewVersionPolicyFactory() pipeline.Factory {
 // number of available network sockets on resource-constrained Linux systems. (E.g. when
 // 'ulimit -Hn' is low).
 func NewAzcopyHTTPClient(maxIdleConns int) *http.Client {
+	return newAzcopyHTTPClient(maxIdleConns, false)
+}
+
+// newAzcopyHTTPClient is NewAzcopyHTTPClient, with the choice of whether to offer HTTP/2 to the service
+func newAzcopyHTTPClient(maxIdleConns int, forceHTTP2 bool) *http.Client {
 	return &http.Client{
 		Transport: &http.Transport{
 			Proxy: common.GlobalProxyLookup,
@@ -108,6 +122,7 @@ func NewAzcopyHTTPClient(maxIdleConns int) *http.Client {
 			DisableKeepAlives:      false,
 			DisableCompression:     true, // must disable the auto-decompression of gzipped files, and just download the gzipped version. See https://github.com/Azure/azure-storage-azcopy/issues/374
 			MaxResponseHeaderBytes: 0,
+			ForceAttemptHTTP2:      forceHTTP2,
 			//ResponseHeaderTimeout:  time.Duration{},
 			//ExpectContinueTimeout:  time.Duration{},
 		},
@@ -257,6 +272,9 @@ type jobPartMgr struct {
 	// Additional data shared by all of this Job Part's transfers; initialized when this jobPartMgr is created
 	httpHeaders common.ResourceHTTPHeaders
 
+	// Content types the user has given for particular file extensions; these win over httpHeaders.ContentType and guessing
+	contentTypeMap common.ContentTypeMap
+
 	// Additional data shared by all of this Job Part's transfers; initialized when this jobPartMgr is created
 	blockBlobTier common.BlockBlobTier
 
@@ -264,7 +282,14 @@ type jobPartMgr struct {
 	pageBlobTier common.PageBlobTier
 
 	// Additional data shared by all of this Job Part's transfers; initialized when this jobPartMgr is created
-	putMd5 bool
+	putMd5      bool
+	ifNoneMatch bool
+
+	snapshotBeforeOverwrite bool
+
+	replaceProperties bool
+
+	validateBlocks bool
 
 	metadata common.Metadata
 
@@ -354,19 +379,26 @@ func (jpm *jobPartMgr) ScheduleTransfers(jobCtx context.Context) {
 		CacheControl:       string(dstData.CacheControl[:dstData.CacheControlLength]),
 	}
 
+	// the map was validated by the front end before the job was ordered, so a parse failure here means a corrupt plan.
+	// That's no reason to take the whole process down, so the part's transfers are failed with the error instead
+	contentTypeMap, planErr := common.ParseContentTypeMap(string(dstData.ContentTypeMap[:dstData.ContentTypeMapLength]))
+	jpm.contentTypeMap = contentTypeMap
+
 	jpm.putMd5 = dstData.PutMd5
+	jpm.ifNoneMatch = dstData.IfNoneMatch
+	jpm.snapshotBeforeOverwrite = dstData.SnapshotBeforeOverwrite
+	jpm.replaceProperties = dstData.ReplaceProperties
+	jpm.validateBlocks = dstData.ValidateBlocks
 	jpm.blockBlobTier = dstData.BlockBlobTier
 	jpm.pageBlobTier = dstData.PageBlobTier
 
-	// For this job part, split the metadata string apart and create an common.Metadata out of it
-	metadataString := string(dstData.Metadata[:dstData.MetadataLength])
-	jpm.metadata = common.Metadata{}
-	if len(metadataString) > 0 {
-		for _, keyAndValue := range strings.Split(metadataString, ";") { // key/value pairs are separated by ';'
-			kv := strings.Split(keyAndValue, "=") // key/value are separated by '='
-			jpm.metadata[kv[0]] = kv[1]
-		}
+	// For this job part, split the metadata string apart and create an common.Metadata out of it.
+	// Like the content type map, it was validated by the front end before the job was ordered
+	metadata, err := common.ParseMetadata(string(dstData.Metadata[:dstData.MetadataLength]))
+	if err != nil && planErr == nil {
+		planErr = err
 	}
+	jpm.metadata = metadata
 	blobTagsStr := string(dstData.BlobTags[:dstData.BlobTagsLength])
 	jpm.blobTags = common.BlobTags{}
 	if len(blobTagsStr) > 0 {
@@ -387,7 +419,7 @@ func (jpm *jobPartMgr) ScheduleTransfers(jobCtx context.Context) {
 	jpm.preserveLastModifiedTime = plan.DstLocalData.PreserveLastModifiedTime
 
 	jpm.blobTypeOverride = plan.DstBlobData.BlobType
-	jpm.newJobXfer = computeJobXfer(plan.FromTo, plan.DstBlobData.BlobType)
+	jpm.newJobXfer = computeJobXfer(plan.FromTo, plan.DstBlobData.BlobType, plan.DstBlobData.ServerSideCopy)
 
 	jpm.priority = plan.Priority
 
@@ -423,8 +455,9 @@ func (jpm *jobPartMgr) ScheduleTransfers(jobCtx context.Context) {
 			}
 		}
 
-		// Each transfer gets its own context (so any chunk can cancel the whole transfer) based off the job's context
-		transferCtx, transferCancel := context.WithCancel(jobCtx)
+		// Each transfer gets its own context (so any chunk can cancel the whole transfer) based off the job's context.
+		// It also samples the routine request log lines, so that big files don't log one line per chunk
+		transferCtx, transferCancel := context.WithCancel(withRequestLogThrottle(jobCtx, newRequestLogThrottle()))
 		// Initialize a job part transfer manager
 		jptm := &jobPartTransferMgr{
 			jobPartMgr:          jpm,
@@ -470,7 +503,12 @@ func (jpm *jobPartMgr) ScheduleTransfers(jobCtx context.Context) {
 			}
 		}
 		// ===== TEST KNOB
-		JobsAdmin.(*jobsAdmin).ScheduleTransfer(jpm.priority, jptm)
+		if planErr != nil {
+			jptm.failActive("reading the job part plan", planErr)
+			jptm.ReportTransferDone()
+		} else {
+			JobsAdmin.(*jobsAdmin).ScheduleTransfer(jpm.priority, jptm)
+		}
 
 		// This sets the atomic variable atomicAllTransfersScheduled to 1
 		// atomicAllTransfersScheduled variables is used in case of resume job
@@ -486,14 +524,68 @@ func (jpm *jobPartMgr) ScheduleTransfers(jobCtx context.Context) {
 	}
 }
 
-func (jpm *jobPartMgr) ScheduleChunks(chunkFunc chunkFunc) {
+// scheduleChunkFunc puts a chunk func in the chunk channel for this part's priority. A chunk that was held back while
+// the job was paused goes back the same way
+func (jpm *jobPartMgr) scheduleChunkFunc(chunkFunc chunkFunc) {
 	JobsAdmin.ScheduleChunk(jpm.priority, chunkFunc)
 }
 
+func (jpm *jobPartMgr) ScheduleChunks(chunkFunc chunkFunc) {
+	jpm.scheduleChunkFunc(jpm.jobMgr.holdableChunk(jpm.scheduleChunkFunc, chunkFunc))
+}
+
+func (jpm *jobPartMgr) ScheduleTransferChunk(transfer IJobPartTransferMgr, chunkFunc chunkFunc) {
+	// the interleaver has already had its say about the order by the time a held chunk comes around again
+	JobsAdmin.(*jobsAdmin).ScheduleTransferChunk(jpm.priority, transfer, jpm.jobMgr.holdableChunk(jpm.scheduleChunkFunc, chunkFunc))
+}
+
+func (jpm *jobPartMgr) ScheduleSmallFileSend(chunkFunc chunkFunc) {
+	ja := JobsAdmin.(*jobsAdmin)
+	ja.ScheduleSmallFileSend(jpm.jobMgr.holdableChunk(ja.ScheduleSmallFileSend, chunkFunc))
+}
+
+func (jpm *jobPartMgr) ScheduleCommit(commitFunc func()) {
+	JobsAdmin.(*jobsAdmin).ScheduleCommit(commitFunc)
+}
+
+// SmallFileThreshold is the size at or below which local files are sent by the small-file pool. Zero means never.
+func (jpm *jobPartMgr) SmallFileThreshold() int64 {
+	concurrency := JobsAdmin.(*jobsAdmin).concurrency
+	if concurrency.activeSmallFilePoolSize() == 0 {
+		return 0
+	}
+	return int64(concurrency.SmallFileThreshold.Value)
+}
+
 func (jpm *jobPartMgr) RescheduleTransfer(jptm IJobPartTransferMgr) {
 	JobsAdmin.(*jobsAdmin).ScheduleTransfer(jpm.priority, jptm)
 }
 
+// newXferRetryOptions applies the user's overrides, if any, to the standard retry options for transfers
+func newXferRetryOptions(overrides common.RetryOptions) XferRetryOptions {
+	o := XferRetryOptions{
+		Policy:        RetryPolicyExponential,
+		MaxTries:      UploadMaxTries, // TODO: Consider to unify options.
+		TryTimeout:    UploadTryTimeout,
+		RetryDelay:    UploadRetryDelay,
+		MaxRetryDelay: UploadMaxRetryDelay,
+		RetryJitter:   UploadRetryJitter,
+	}
+	if overrides.MaxTries != 0 {
+		o.MaxTries = overrides.MaxTries
+	}
+	if overrides.TryTimeout != 0 {
+		o.TryTimeout = overrides.TryTimeout
+	}
+	if overrides.RetryDelay != 0 {
+		o.RetryDelay = overrides.RetryDelay
+	}
+	if overrides.MaxRetryDelay != 0 {
+		o.MaxRetryDelay = overrides.MaxRetryDelay
+	}
+	return o
+}
+
 func (jpm *jobPartMgr) createPipelines(ctx context.Context) {
 	if atomic.SwapUint32(&jpm.atomicPipelinesInitedIndicator, 1) != 0 {
 		panic("init client and pipelines for same jobPartMgr twice")
@@ -519,12 +611,7 @@ func (jpm *jobPartMgr) createPipelines(ctx context.Context) {
 		Cancel:   jpm.jobMgr.Cancel,
 	}
 	// TODO: Consider to remove XferRetryPolicy and Options?
-	xferRetryOption := XferRetryOptions{
-		Policy:        0,
-		MaxTries:      UploadMaxTries, // TODO: Consider to unify options.
-		TryTimeout:    UploadTryTimeout,
-		RetryDelay:    UploadRetryDelay,
-		MaxRetryDelay: UploadMaxRetryDelay}
+	xferRetryOption := newXferRetryOptions(jpm.planMMF.Plan().RetryOptions)
 
 	var statsAccForSip *pipelineNetworkStats = nil // we don't accumulate stats on the source info provider
 
@@ -571,10 +658,10 @@ func (jpm *jobPartMgr) createPipelines(ctx context.Context) {
 			},
 			azfile.RetryOptions{
 				Policy:        azfile.RetryPolicyExponential,
-				MaxTries:      UploadMaxTries,
-				TryTimeout:    UploadTryTimeout,
-				RetryDelay:    UploadRetryDelay,
-				MaxRetryDelay: UploadMaxRetryDelay,
+				MaxTries:      xferRetryOption.MaxTries,
+				TryTimeout:    xferRetryOption.TryTimeout,
+				RetryDelay:    xferRetryOption.RetryDelay,
+				MaxRetryDelay: xferRetryOption.MaxRetryDelay,
 			},
 			jpm.pacer,
 			jpm.jobMgr.HttpClient(),
@@ -636,8 +723,9 @@ func (jpm *jobPartMgr) createPipelines(ctx context.Context) {
 	// Create pipeline for Azure File.
 	case common.EFromTo.FileTrash(), common.EFromTo.FileLocal(), common.EFromTo.LocalFile(), common.EFromTo.BenchmarkFile(),
 		common.EFromTo.FileFile(), common.EFromTo.BlobFile():
+		credential := common.CreateFileCredential(ctx, credInfo, credOption)
 		jpm.pipeline = NewFilePipeline(
-			azfile.NewAnonymousCredential(),
+			credential,
 			azfile.PipelineOptions{
 				Log: jpm.jobMgr.PipelineLogInfo(),
 				Telemetry: azfile.TelemetryOptions{
@@ -646,10 +734,10 @@ func (jpm *jobPartMgr) createPipelines(ctx context.Context) {
 			},
 			azfile.RetryOptions{
 				Policy:        azfile.RetryPolicyExponential,
-				MaxTries:      UploadMaxTries,
-				TryTimeout:    UploadTryTimeout,
-				RetryDelay:    UploadRetryDelay,
-				MaxRetryDelay: UploadMaxRetryDelay,
+				MaxTries:      xferRetryOption.MaxTries,
+				TryTimeout:    xferRetryOption.TryTimeout,
+				RetryDelay:    xferRetryOption.RetryDelay,
+				MaxRetryDelay: xferRetryOption.MaxRetryDelay,
 			},
 			jpm.pacer,
 			jpm.jobMgr.HttpClient(),
@@ -691,8 +779,19 @@ func (jpm *jobPartMgr) AutoDecompress() bool {
 	return jpm.Plan().AutoDecompress
 }
 
+func (jpm *jobPartMgr) Compress() bool {
+	return jpm.Plan().Compress
+}
+
 func (jpm *jobPartMgr) resourceDstData(fullFilePath string, dataFileToXfer []byte) (headers common.ResourceHTTPHeaders,
 	metadata common.Metadata, blobTags common.BlobTags, cpkOptions common.CpkOptions) {
+	// a content type the user gave for this extension wins over both --content-type and guessing
+	if contentType, ok := jpm.contentTypeMap.ContentTypeFor(filepath.Ext(fullFilePath)); ok {
+		headers = jpm.httpHeaders
+		headers.ContentType = contentType
+		return headers, jpm.metadata, jpm.blobTags, jpm.cpkOptions
+	}
+
 	if jpm.planMMF.Plan().DstBlobData.NoGuessMimeType {
 		return jpm.httpHeaders, jpm.metadata, jpm.blobTags, jpm.cpkOptions
 	}
@@ -747,7 +846,7 @@ func (jpm *jobPartMgr) inferContentType(fullFilePath string, dataFileToXfer []by
 		return strings.Split(guessedType, ";")[0]
 	}
 
-	return strings.Split(http.DetectContentType(dataFileToXfer), ";")[0]
+	return common.DetectContentType(dataFileToXfer)
 }
 
 func (jpm *jobPartMgr) BlobTypeOverride() common.BlobType {
@@ -774,6 +873,22 @@ func (jpm *jobPartMgr) ShouldPutMd5() bool {
 	return jpm.putMd5
 }
 
+func (jpm *jobPartMgr) ShouldUploadIfNoneMatch() bool {
+	return jpm.ifNoneMatch
+}
+
+func (jpm *jobPartMgr) ShouldSnapshotBeforeOverwrite() bool {
+	return jpm.snapshotBeforeOverwrite
+}
+
+func (jpm *jobPartMgr) ShouldReplaceProperties() bool {
+	return jpm.replaceProperties
+}
+
+func (jpm *jobPartMgr) ShouldValidateBlocks() bool {
+	return jpm.validateBlocks
+}
+
 func (jpm *jobPartMgr) SAS() (string, string) {
 	return jpm.sourceSAS, jpm.destinationSAS
 }
@@ -847,6 +962,7 @@ func (jpm *jobPartMgr) Close() {
 	jpm.planMMF.Unmap()
 	// Clear other fields to all for GC
 	jpm.httpHeaders = common.ResourceHTTPHeaders{}
+	jpm.contentTypeMap = nil
 	jpm.metadata = common.Metadata{}
 	jpm.preserveLastModifiedTime = false
 	// TODO: Delete file?
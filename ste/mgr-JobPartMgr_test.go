@@ -24,6 +24,9 @@ import (
 	chk "gopkg.in/check.v1"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
 )
 
 // Hookup to the testing framework
@@ -60,3 +63,70 @@ func (s *jobPartMgrTestSuite) TestInferContentType(c *chk.C) {
 		c.Assert(strings.Contains(contentType, expectedType), chk.Equals, true)
 	}
 }
+
+func (s *jobPartMgrTestSuite) TestInferContentTypeFromContentWhenExtensionIsMissing(c *chk.C) {
+	// Arrange
+	partMgr := jobPartMgr{}
+
+	testCases := map[string][]byte{
+		"image/png":                {0x89, 'P', 'N', 'G', 0x0D, 0x0A, 0x1A, 0x0A, 0, 0, 0, 0x0D},
+		"application/x-gzip":       {0x1F, 0x8B, 0x08, 0, 0, 0, 0, 0},
+		"application/zstd":         {0x28, 0xB5, 0x2F, 0xFD, 0x04, 0x58},
+		"text/plain":               []byte("just some plain text in a file without an extension\n"),
+		"application/octet-stream": {0, 1, 2, 3, 4},
+	}
+
+	// Action & Assert
+	for expectedType, content := range testCases {
+		contentType := partMgr.inferContentType("/usr/foo/no/extension", content)
+		c.Assert(contentType, chk.Equals, expectedType)
+	}
+}
+
+func (s *jobPartMgrTestSuite) TestRetryOptionsFromJobOrder(c *chk.C) {
+	// no overrides gives the standard options
+	o := newXferRetryOptions(common.RetryOptions{})
+	c.Assert(o.MaxTries, chk.Equals, int32(UploadMaxTries))
+	c.Assert(o.TryTimeout, chk.Equals, UploadTryTimeout)
+	c.Assert(o.RetryDelay, chk.Equals, UploadRetryDelay)
+	c.Assert(o.MaxRetryDelay, chk.Equals, UploadMaxRetryDelay)
+
+	// overrides replace only what they set
+	o = newXferRetryOptions(common.RetryOptions{MaxTries: 3, TryTimeout: time.Hour})
+	c.Assert(o.MaxTries, chk.Equals, int32(3))
+	c.Assert(o.TryTimeout, chk.Equals, time.Hour)
+	c.Assert(o.RetryDelay, chk.Equals, UploadRetryDelay)
+	c.Assert(o.MaxRetryDelay, chk.Equals, UploadMaxRetryDelay)
+
+	o = newXferRetryOptions(common.RetryOptions{RetryDelay: 10 * time.Second, MaxRetryDelay: 10 * time.Minute})
+	c.Assert(o.RetryDelay, chk.Equals, 10*time.Second)
+	c.Assert(o.MaxRetryDelay, chk.Equals, 10*time.Minute)
+
+	// and the result is acceptable to the retry policy
+	o.defaults()
+}
+
+func (s *jobPartMgrTestSuite) TestContentTypeMapTakesPrecedence(c *chk.C) {
+	contentTypeMap, err := common.ParseContentTypeMap(".md=text/markdown,.js=text/plain,.js=text/javascript")
+	c.Assert(err, chk.IsNil)
+	partMgr := jobPartMgr{
+		contentTypeMap: contentTypeMap,
+		httpHeaders:    common.ResourceHTTPHeaders{ContentType: "application/octet-stream", CacheControl: "no-cache"},
+	}
+
+	testCases := map[string]string{
+		"/usr/foo/readme.md":  "text/markdown",   // nothing would have been detected for this one
+		"/usr/foo/app.js":     "text/javascript", // wins over the built-in type, and the later entry wins
+		"/usr/foo/APP.JS":     "text/javascript",
+		"/usr/foo/app.min.js": "text/javascript",
+	}
+
+	for testPath, expectedType := range testCases {
+		headers, _, _, _ := partMgr.resourceDstData(testPath, []byte("<html></html>"))
+		c.Assert(headers.ContentType, chk.Equals, expectedType, chk.Commentf("path %s", testPath))
+		c.Assert(headers.CacheControl, chk.Equals, "no-cache") // other headers are left as given
+	}
+
+	// the job part's own headers are not changed by the override
+	c.Assert(partMgr.httpHeaders.ContentType, chk.Equals, "application/octet-stream")
+}
@@ -23,15 +23,22 @@ type IJobPartTransferMgr interface {
 	ResourceDstData(dataFileToXfer []byte) (headers common.ResourceHTTPHeaders, metadata common.Metadata, blobTags common.BlobTags, cpkOptions common.CpkOptions)
 	LastModifiedTime() time.Time
 	PreserveLastModifiedTime() (time.Time, bool)
+	LastModifiedInMetadata() bool
 	ShouldPutMd5() bool
+	ShouldUploadIfNoneMatch() bool
+	ShouldSnapshotBeforeOverwrite() bool
+	ShouldReplaceProperties() bool
+	ShouldValidateBlocks() bool
 	MD5ValidationOption() common.HashValidationOption
 	BlobTypeOverride() common.BlobType
 	BlobTiers() (blockBlobTier common.BlockBlobTier, pageBlobTier common.PageBlobTier)
 	JobHasLowFileCount() bool
+	JobWasResumed() bool
 	//ScheduleChunk(chunkFunc chunkFunc)
 	Context() context.Context
 	SlicePool() common.ByteSlicePooler
 	CacheLimiter() common.CacheLimiter
+	FileCountLimiter() common.CacheLimiter
 	WaitUntilLockDestination(ctx context.Context) error
 	EnsureDestinationUnlocked()
 	HoldsDestinationLock() bool
@@ -40,18 +47,28 @@ type IJobPartTransferMgr interface {
 	GetForceIfReadOnly() bool
 	ShouldDecompress() bool
 	GetSourceCompressionType() (common.CompressionType, error)
+	ShouldCompress() bool
 	ReportChunkDone(id common.ChunkID) (lastChunk bool, chunksDone uint32)
 	TransferStatusIgnoringCancellation() common.TransferStatus
 	SetStatus(status common.TransferStatus)
 	SetErrorCode(errorCode int32)
+	SetContentMD5(hash []byte)
+	ShouldWriteChecksum() bool
+	WriteChecksum(md5Hash []byte)
+	SetETag(etag string)
 	SetNumberOfChunks(numChunks uint32)
 	SetActionAfterLastChunk(f func())
 	ReportTransferDone() uint32
 	RescheduleTransfer()
 	ScheduleChunks(chunkFunc chunkFunc)
+	ScheduleChunksInOrder(chunkFunc chunkFunc)
+	ScheduleSmallFileSend(chunkFunc chunkFunc)
+	SmallFileThreshold() int64
+	ScheduleCommit(commitFunc func())
 	SetDestinationIsModified()
 	Cancel()
 	WasCanceled() bool
+	WasPaused() bool
 	IsLive() bool
 	IsDeadBeforeStart() bool
 	IsDeadInflight() bool
@@ -96,6 +113,7 @@ type IJobPartTransferMgr interface {
 type TransferInfo struct {
 	JobID                  common.JobID
 	BlockSize              int64
+	PutBlobSize            int64 // block blobs up to this size are uploaded with a single Put Blob; zero means up to BlockSize
 	Source                 string
 	SourceSize             int64
 	Destination            string
@@ -172,12 +190,18 @@ type jobPartTransferMgr struct {
 	// used defensively to protect against accidental double counting
 	atomicCompletionIndicator uint32
 
+	// used to show whether the transfer was started, and so is counted as active in the metrics until it's done
+	atomicStartedIndicator uint32
+
 	// used to show whether we have started doing things that may affect the destination
 	atomicDestModifiedIndicator uint32
 
 	// used to show whether THIS jptm holds the destination lock
 	atomicDestLockHeldIndicator uint32
 
+	// why the transfer failed, as a string, for display in the job summary. Only held in memory, since the plan file only records the status code
+	atomicFailureReason atomic.Value
+
 	jobPartMgr          IJobPartMgr // Refers to the "owning" Job Part
 	jobPartPlanTransfer *JobPartPlanTransfer
 	transferIndex       uint32
@@ -190,6 +214,9 @@ type jobPartTransferMgr struct {
 
 	numChunks uint32
 
+	// fails the transfer if it is still running when the job's transfer timeout expires. Nil if there is no timeout
+	timeoutTimer *time.Timer
+
 	transferInfo *TransferInfo
 
 	actionAfterLastChunk func()
@@ -213,9 +240,24 @@ func (jptm *jobPartTransferMgr) FromTo() common.FromTo {
 }
 
 func (jptm *jobPartTransferMgr) StartJobXfer() {
+	atomic.StoreUint32(&jptm.atomicStartedIndicator, 1)
+	transferMetrics.transferStarted()
+	if timeout := jptm.jobPartMgr.Plan().TransferTimeout; timeout > 0 {
+		// armed before the transfer starts, since small transfers can be done before StartJobXfer returns
+		jptm.timeoutTimer = time.AfterFunc(timeout, func() { jptm.failOnTimeout(timeout) })
+	}
 	jptm.jobPartMgr.StartJobXfer(jptm)
 }
 
+// failOnTimeout fails a transfer that has run for longer than the job's transfer timeout.
+// Cancelling it makes any chunks that are stuck in requests give up, so that the transfer can finish as usual
+func (jptm *jobPartTransferMgr) failOnTimeout(timeout time.Duration) {
+	if jptm.TransferStatusIgnoringCancellation() != common.ETransferStatus.Started() {
+		return // already finished (or failed) of its own accord
+	}
+	jptm.failActive("waiting for the transfer to complete", fmt.Errorf("transfer timed out: not complete after %v", timeout))
+}
+
 func (jptm *jobPartTransferMgr) GetOverwriteOption() common.OverwriteOption {
 	return jptm.jobPartMgr.GetOverwriteOption()
 }
@@ -237,6 +279,11 @@ func (jptm *jobPartTransferMgr) GetSourceCompressionType() (common.CompressionTy
 	return common.GetCompressionType(encoding)
 }
 
+// ShouldCompress says whether the source should be gzip-compressed as it is uploaded
+func (jptm *jobPartTransferMgr) ShouldCompress() bool {
+	return jptm.jobPartMgr.Compress() && jptm.FromTo() == common.EFromTo.LocalBlob()
+}
+
 func (jptm *jobPartTransferMgr) Info() TransferInfo {
 	if jptm.transferInfo != nil {
 		return *jptm.transferInfo
@@ -316,21 +363,8 @@ func (jptm *jobPartTransferMgr) Info() TransferInfo {
 	sourceSize := plan.Transfer(jptm.transferIndex).SourceSize
 	var blockSize = dstBlobData.BlockSize
 	// If the blockSize is 0, then User didn't provide any blockSize
-	// We need to set the blockSize in such way that number of blocks per blob
-	// does not exceeds 50000 (max number of block per blob)
 	if blockSize == 0 {
-		blockSize = common.DefaultBlockBlobBlockSize
-		for ; uint32(sourceSize/blockSize) > common.MaxNumberOfBlocksPerBlob; blockSize = 2 * blockSize {
-			if blockSize > common.BlockSizeThreshold {
-				/*
-				 * For a RAM usage of 0.5G/core, we would have 4G memory on typical 8 core device, meaning at a blockSize of 256M,
-				 * we can have 4 blocks in core, waiting for a disk or n/w operation. Any higher block size would *sort of*
-				 * serialize n/w and disk operations, and is better avoided.
-				 */
-				blockSize = sourceSize / common.MaxNumberOfBlocksPerBlob
-				break
-			}
-		}
+		blockSize = autoBlockSize(sourceSize)
 	}
 	blockSize = common.Iffint64(blockSize > common.MaxBlockBlobBlockSize, common.MaxBlockBlobBlockSize, blockSize)
 
@@ -347,6 +381,7 @@ func (jptm *jobPartTransferMgr) Info() TransferInfo {
 	jptm.transferInfo = &TransferInfo{
 		JobID:                          plan.JobID,
 		BlockSize:                      blockSize,
+		PutBlobSize:                    dstBlobData.PutBlobSize,
 		Source:                         src,
 		SourceSize:                     sourceSize,
 		Destination:                    dst,
@@ -457,14 +492,56 @@ func (jptm *jobPartTransferMgr) RescheduleTransfer() {
 	jptm.jobPartMgr.RescheduleTransfer(jptm)
 }
 
+// ScheduleChunks schedules a chunk of this transfer. If chunks are interleaved, it may be preceded in the chunk channel
+// by chunks of other transfers that were scheduled after it, but never by later chunks of this transfer.
 func (jptm *jobPartTransferMgr) ScheduleChunks(chunkFunc chunkFunc) {
+	jptm.jobPartMgr.ScheduleTransferChunk(jptm, chunkFunc)
+}
+
+// ScheduleChunksInOrder puts a chunk of this transfer straight into the chunk channel, regardless of interleaving
+func (jptm *jobPartTransferMgr) ScheduleChunksInOrder(chunkFunc chunkFunc) {
 	jptm.jobPartMgr.ScheduleChunks(chunkFunc)
 }
 
+// ScheduleSmallFileSend hands the func that reads and sends this whole (small) file to the small-file pool
+func (jptm *jobPartTransferMgr) ScheduleSmallFileSend(chunkFunc chunkFunc) {
+	jptm.jobPartMgr.ScheduleSmallFileSend(chunkFunc)
+}
+
+// ScheduleCommit hands this transfer's epilogue to the commit pool, so that the worker calling it is free to move on
+func (jptm *jobPartTransferMgr) ScheduleCommit(commitFunc func()) {
+	jptm.jobPartMgr.ScheduleCommit(commitFunc)
+}
+
+func (jptm *jobPartTransferMgr) SmallFileThreshold() int64 {
+	return jptm.jobPartMgr.SmallFileThreshold()
+}
+
 func (jptm *jobPartTransferMgr) ResourceDstData(dataFileToXfer []byte) (headers common.ResourceHTTPHeaders, metadata common.Metadata, blobTags common.BlobTags, cpkOptions common.CpkOptions) {
 	return jptm.jobPartMgr.(*jobPartMgr).resourceDstData(jptm.Info().Source, dataFileToXfer)
 }
 
+// autoBlockSize picks the block size for a source when the user didn't provide one.
+// We need to set the blockSize in such way that number of blocks per blob
+// does not exceeds 50000 (max number of block per blob), so we start from the default and double it
+// until the source fits. Files too large to fit at BlockSizeThreshold get the smallest block size that fits.
+// Sources no bigger than the default block size are one chunk, and so are uploaded with a single Put Blob.
+func autoBlockSize(sourceSize int64) int64 {
+	blockSize := int64(common.DefaultBlockBlobBlockSize)
+	for getNumChunks(sourceSize, blockSize) > common.MaxNumberOfBlocksPerBlob {
+		if blockSize > common.BlockSizeThreshold {
+			/*
+			 * For a RAM usage of 0.5G/core, we would have 4G memory on typical 8 core device, meaning at a blockSize of 256M,
+			 * we can have 4 blocks in core, waiting for a disk or n/w operation. Any higher block size would *sort of*
+			 * serialize n/w and disk operations, and is better avoided.
+			 */
+			return (sourceSize + common.MaxNumberOfBlocksPerBlob - 1) / common.MaxNumberOfBlocksPerBlob
+		}
+		blockSize *= 2
+	}
+	return blockSize
+}
+
 // TODO refactor into something like jptm.IsLastModifiedTimeEqual() so that there is NO LastModifiedTime method and people therefore CAN'T do it wrong due to time zone
 func (jptm *jobPartTransferMgr) LastModifiedTime() time.Time {
 	return time.Unix(0, jptm.jobPartPlanTransfer.ModifiedTime)
@@ -472,22 +549,70 @@ func (jptm *jobPartTransferMgr) LastModifiedTime() time.Time {
 
 // PreserveLastModifiedTime checks for the PreserveLastModifiedTime flag in JobPartPlan of a transfer.
 // If PreserveLastModifiedTime is set to true, it returns the lastModifiedTime of the source.
+// That is the time recorded in the source's metadata at upload, if there is one and the job asked for it.
 func (jptm *jobPartTransferMgr) PreserveLastModifiedTime() (time.Time, bool) {
 	if preserveLastModifiedTime := jptm.jobPartMgr.(*jobPartMgr).localDstData().PreserveLastModifiedTime; preserveLastModifiedTime {
-		lastModifiedTime := jptm.jobPartPlanTransfer.ModifiedTime
-		return time.Unix(0, lastModifiedTime), true
+		lastModifiedTime := time.Unix(0, jptm.jobPartPlanTransfer.ModifiedTime)
+		if jptm.LastModifiedInMetadata() {
+			lastModifiedTime = lastModifiedTimeToPreserve(lastModifiedTime, jptm.Info().SrcMetadata)
+		}
+		return lastModifiedTime, true
 	}
 	return time.Time{}, false
 }
 
+// LastModifiedInMetadata says whether the modification time travels in blob metadata
+func (jptm *jobPartTransferMgr) LastModifiedInMetadata() bool {
+	return jptm.jobPartMgr.(*jobPartMgr).localDstData().LastModifiedInMetadata
+}
+
+// lastModifiedTimeToPreserve prefers the time an upload recorded in the metadata to the source's own timestamp,
+// since the latter only says when the blob was written
+func lastModifiedTimeToPreserve(sourceTime time.Time, srcMetadata common.Metadata) time.Time {
+	if recorded, ok := srcMetadata.LastModifiedTime(); ok {
+		return recorded
+	}
+	return sourceTime
+}
+
 func (jptm *jobPartTransferMgr) ShouldPutMd5() bool {
 	return jptm.jobPartMgr.ShouldPutMd5()
 }
 
+func (jptm *jobPartTransferMgr) ShouldUploadIfNoneMatch() bool {
+	return jptm.jobPartMgr.ShouldUploadIfNoneMatch()
+}
+
+func (jptm *jobPartTransferMgr) ShouldSnapshotBeforeOverwrite() bool {
+	return jptm.jobPartMgr.ShouldSnapshotBeforeOverwrite()
+}
+
+func (jptm *jobPartTransferMgr) ShouldReplaceProperties() bool {
+	return jptm.jobPartMgr.ShouldReplaceProperties()
+}
+
+func (jptm *jobPartTransferMgr) ShouldValidateBlocks() bool {
+	return jptm.jobPartMgr.ShouldValidateBlocks()
+}
+
 func (jptm *jobPartTransferMgr) MD5ValidationOption() common.HashValidationOption {
 	return jptm.jobPartMgr.(*jobPartMgr).localDstData().MD5VerificationOption
 }
 
+// ShouldWriteChecksum says whether the MD5 hash of the downloaded file goes in a --write-checksums file
+func (jptm *jobPartTransferMgr) ShouldWriteChecksum() bool {
+	return jptm.jobPartMgr.(*jobPartMgr).localDstData().ChecksumFileLength > 0
+}
+
+// WriteChecksum adds the line for the downloaded file, whose content has the given MD5 hash, to the job's --write-checksums file
+func (jptm *jobPartTransferMgr) WriteChecksum(md5Hash []byte) {
+	jpm := jptm.jobPartMgr.(*jobPartMgr)
+	dstData := jpm.localDstData()
+	plan := jpm.Plan()
+	checksums := jpm.jobMgr.(*jobMgr).checksumFileWriter(string(dstData.ChecksumFile[:dstData.ChecksumFileLength]))
+	checksums.Add(md5Hash, checksumRelativePath(string(plan.DestinationRoot[:plan.DestinationRootLength]), jptm.Info().Destination))
+}
+
 func (jptm *jobPartTransferMgr) DeleteSnapshotsOption() common.DeleteSnapshotsOption {
 	return jptm.jobPartMgr.(*jobPartMgr).deleteSnapshotsOption()
 }
@@ -527,8 +652,14 @@ func (jptm *jobPartTransferMgr) JobHasLowFileCount() bool {
 	return jptm.jobPartMgr.Plan().NumTransfers < lowFileCountThreshold
 }
 
+// JobWasResumed says whether an earlier run of the job may have done part of this transfer
+func (jptm *jobPartTransferMgr) JobWasResumed() bool {
+	return jptm.jobPartMgr.(*jobPartMgr).jobMgr.wasResumed()
+}
+
 func (jptm *jobPartTransferMgr) SetNumberOfChunks(numChunks uint32) {
 	jptm.numChunks = numChunks
+	jptm.jobPartPlanTransfer.SetNumChunks(numChunks)
 }
 
 func (jptm *jobPartTransferMgr) SetActionAfterLastChunk(f func()) {
@@ -545,9 +676,12 @@ func (jptm *jobPartTransferMgr) ReportChunkDone(id common.ChunkID) (lastChunk bo
 	id.SetCompletionNotificationSent()
 
 	// track progress
-	if jptm.IsLive() {
+	isLive := jptm.IsLive()
+	transferMetrics.chunkDone(isLive)
+	if isLive {
 		atomic.AddInt64(&jptm.atomicSuccessfulBytes, id.Length())
 		JobsAdmin.AddSuccessfulBytesInActiveFiles(id.Length())
+		jptm.jobPartPlanTransfer.ReportChunkDone()
 	}
 
 	// Do our actual processing
@@ -599,6 +733,16 @@ func (jptm *jobPartTransferMgr) SetErrorCode(errorCode int32) {
 	jptm.jobPartPlanTransfer.SetErrorCode(errorCode, false)
 }
 
+// SetContentMD5 records the MD5 hash computed while moving the transfer's data, so that it can be reported later
+func (jptm *jobPartTransferMgr) SetContentMD5(hash []byte) {
+	jptm.jobPartPlanTransfer.SetContentMD5(hash)
+}
+
+// SetETag records the ETag the service returned when the destination was written, so that it can be reported later
+func (jptm *jobPartTransferMgr) SetETag(etag string) {
+	jptm.jobPartPlanTransfer.SetETag(etag)
+}
+
 // TODO: Can we kill this method?
 /*func (jptm *jobPartTransferMgr) ChunksDone() uint32 {
 	return atomic.LoadUint32(&jptm.atomicChunksDone)
@@ -607,6 +751,15 @@ func (jptm *jobPartTransferMgr) SetErrorCode(errorCode int32) {
 func (jptm *jobPartTransferMgr) Cancel()           { jptm.cancel() }
 func (jptm *jobPartTransferMgr) WasCanceled() bool { return jptm.ctx.Err() != nil }
 
+// WasPaused says whether the transfer was cancelled because its job was paused (rather than cancelled outright)
+func (jptm *jobPartTransferMgr) WasPaused() bool {
+	if !jptm.WasCanceled() {
+		return false
+	}
+	part0, ok := jptm.jobPartMgr.(*jobPartMgr).jobMgr.JobPartMgr(0)
+	return ok && part0.Plan().JobStatus() == common.EJobStatus.Paused()
+}
+
 // SetDestinationIsModified tells the jptm that it should consider the destination to have been modified
 func (jptm *jobPartTransferMgr) SetDestinationIsModified() {
 	old := atomic.SwapUint32(&jptm.atomicDestModifiedIndicator, 1)
@@ -698,6 +851,19 @@ func (jptm *jobPartTransferMgr) FailActiveS2SCopyWithStatus(where string, err er
 	jptm.failActiveTransfer(transferErrorCodeCopyFailed, where, err, failureStatus)
 }
 
+// failActive fails the transfer with the error type that matches its direction
+func (jptm *jobPartTransferMgr) failActive(where string, err error) {
+	fromTo := jptm.FromTo()
+	switch {
+	case fromTo.IsUpload():
+		jptm.FailActiveUpload(where, err)
+	case fromTo.IsS2S():
+		jptm.FailActiveS2SCopy(where, err)
+	default:
+		jptm.FailActiveDownload(where, err)
+	}
+}
+
 // TODO: FailActive* need be further refactored with a separate workitem.
 func (jptm *jobPartTransferMgr) TempJudgeUploadOrCopy() (isUpload, isCopy bool) {
 	fromTo := jptm.FromTo()
@@ -757,6 +923,7 @@ func (jptm *jobPartTransferMgr) failActiveTransfer(typ transferErrorCode, descri
 		requestID := ErrorEx{err}.MSRequestID()
 		fullMsg := fmt.Sprintf("%s. When %s. X-Ms-Request-Id: %s\n", msg, descriptionOfWhereErrorOccurred, requestID) // trailing \n to separate it better from any later, unrelated, log lines
 		jptm.logTransferError(typ, jptm.Info().Source, jptm.Info().Destination, fullMsg, status)
+		jptm.atomicFailureReason.Store(transferFailureReason(err))
 		jptm.SetStatus(failureStatus)
 		jptm.SetErrorCode(int32(status)) // TODO: what are the rules about when this needs to be set, and doesn't need to be (e.g. for earlier failures)?
 		// If the status code was 403, it means there was an authentication error and we exit.
@@ -784,6 +951,37 @@ func (jptm *jobPartTransferMgr) failActiveTransfer(typ transferErrorCode, descri
 	// TODO: ... if all expected chunks report as done
 }
 
+// transferFailureReason summarizes err for display next to the failed transfer,
+// e.g. "403 This request is not authorized to perform this operation. (AuthorizationPermissionMismatch)"
+func transferFailureReason(err error) string {
+	serviceCode, status, msg := ErrorEx{err}.ErrorCodeAndString()
+	if status == 0 {
+		// not a storage error, so msg is the whole error text. The first line is the useful part
+		return strings.TrimSpace(strings.SplitN(msg, "\n", 2)[0])
+	}
+	if serviceCode != "" {
+		return fmt.Sprintf("%s (%s)", msg, serviceCode)
+	}
+	return msg
+}
+
+// failureReasonFromErrorCode is the best we can do when only the status code of the failure is known,
+// which is the case for failures that were recorded by another process, or without going through failActiveTransfer
+func failureReasonFromErrorCode(errorCode int32) string {
+	if errorCode == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d %s", errorCode, http.StatusText(int(errorCode)))
+}
+
+// failureReason returns why the transfer failed, or "" if it didn't
+func (jptm *jobPartTransferMgr) failureReason() string {
+	if reason, ok := jptm.atomicFailureReason.Load().(string); ok {
+		return reason
+	}
+	return failureReasonFromErrorCode(jptm.ErrorCode())
+}
+
 func (jptm *jobPartTransferMgr) PipelineLogInfo() pipeline.LogOptions {
 	return jptm.jobPartMgr.(*jobPartMgr).jobMgr.(*jobMgr).PipelineLogInfo()
 }
@@ -885,6 +1083,11 @@ func (jptm *jobPartTransferMgr) Panic(err error) { jptm.jobPartMgr.Panic(err) }
 // Call ReportTransferDone to report when a Transfer for this Job Part has completed
 // TODO: I feel like this should take the status & we kill SetStatus
 func (jptm *jobPartTransferMgr) ReportTransferDone() uint32 {
+	// the transfer is over, so it must not be timed out now
+	if jptm.timeoutTimer != nil {
+		jptm.timeoutTimer.Stop()
+	}
+
 	// In case of context leak in job part transfer manager.
 	jptm.Cancel()
 
@@ -898,6 +1101,9 @@ func (jptm *jobPartTransferMgr) ReportTransferDone() uint32 {
 	if atomic.SwapUint32(&jptm.atomicCompletionIndicator, 1) != 0 {
 		panic("cannot report the same transfer done twice")
 	}
+	if atomic.LoadUint32(&jptm.atomicStartedIndicator) == 1 {
+		transferMetrics.transferDone()
+	}
 
 	//Update Status Manager
 	jptm.jobPartMgr.SendXferDoneMsg(xferDoneMsg{Src: jptm.Info().Source,
@@ -906,6 +1112,7 @@ func (jptm *jobPartTransferMgr) ReportTransferDone() uint32 {
 		TransferStatus:     jptm.jobPartPlanTransfer.TransferStatus(),
 		TransferSize:       uint64(jptm.Info().SourceSize),
 		ErrorCode:          jptm.ErrorCode(),
+		FailureReason:      jptm.failureReason(),
 	})
 
 	return jptm.jobPartMgr.ReportTransferDone(jptm.jobPartPlanTransfer.TransferStatus())
@@ -38,6 +38,12 @@ type overwritePrompter struct {
 
 	// if the user made a "for all" selection, save the response here
 	savedResponse map[common.EntityType]bool
+
+	// asks the user the question. It's the lifecycle manager's Prompt, except in tests
+	prompt func(message string, details common.PromptDetails) common.ResponseOption
+
+	// cancels the job, when the user chooses to quit
+	cancelJob func()
 }
 
 func (o *overwritePrompter) ShouldOverwrite(objectPath string, objectType common.EntityType) (shouldOverwrite bool) {
@@ -63,7 +69,7 @@ func (o *overwritePrompter) promptForConfirmation(objectPath string, objectType
 			"Do you wish to overwrite its properties?", objectPath)
 	}
 
-	answer := common.GetLifecycleMgr().Prompt(question,
+	answer := o.prompt(question,
 		common.PromptDetails{
 			PromptType:   common.EPromptType.Overwrite(),
 			PromptTarget: objectPath,
@@ -71,7 +77,8 @@ func (o *overwritePrompter) promptForConfirmation(objectPath string, objectType
 				common.EResponseOption.Yes(),
 				common.EResponseOption.No(),
 				common.EResponseOption.YesForAll(),
-				common.EResponseOption.NoForAll()},
+				common.EResponseOption.NoForAll(),
+				common.EResponseOption.Quit()},
 		})
 
 	switch answer {
@@ -96,15 +103,26 @@ func (o *overwritePrompter) promptForConfirmation(objectPath string, objectType
 		o.shouldPromptUser[objectType] = false
 		o.savedResponse[objectType] = false
 		return false
+	case common.EResponseOption.Quit():
+		common.GetLifecycleMgr().Info("Cancelling the job. Nothing more will be overwritten.")
+		// transfers that are already waiting on the lock must not ask again, or overwrite anything
+		for entityType := range o.shouldPromptUser {
+			o.shouldPromptUser[entityType] = false
+			o.savedResponse[entityType] = false
+		}
+		go o.cancelJob() // not while holding the lock, which transfers being cancelled may be waiting on
+		return false
 	default:
 		common.GetLifecycleMgr().Info(fmt.Sprintf("Unrecognizable answer, skipping %s.", objectPath))
 		return false
 	}
 }
 
-func newOverwritePrompter() *overwritePrompter {
+func newOverwritePrompter(cancelJob func()) *overwritePrompter {
 	return &overwritePrompter{
-		lock: &sync.Mutex{},
+		lock:      &sync.Mutex{},
+		prompt:    common.GetLifecycleMgr().Prompt,
+		cancelJob: cancelJob,
 		shouldPromptUser: map[common.EntityType]bool{
 			common.EEntityType.Folder(): true,
 			common.EEntityType.File():   true,
@@ -38,20 +38,55 @@ type pacedReadSeeker struct {
 	p    pacer
 }
 
+// newPacedRequestBody paces the reading of the body.
+// If the rate is not capped (i.e. --cap-mbps is zero or omitted), the body is not paced, and is only wrapped so that
+// the bytes read from it, which are the bytes actually sent, are counted as traffic.
 func newPacedRequestBody(ctx context.Context, requestBody io.ReadSeeker, p pacer) io.ReadSeeker {
 	if p == nil {
 		panic("p must not be nil")
 	}
+	if np, ok := p.(*nullAutoPacer); ok {
+		return &countedBody{body: requestBody, p: np}
+	}
 	return &pacedReadSeeker{ctx: ctx, body: requestBody, p: p}
 }
 
+// newPacedResponseBody paces the reading of the body.
+// Like newPacedRequestBody, it only counts what is read from the body if the rate is not capped.
 func newPacedResponseBody(ctx context.Context, responseBody io.ReadCloser, p pacer) io.ReadCloser {
 	if p == nil {
 		panic("p must not be nil")
 	}
+	if np, ok := p.(*nullAutoPacer); ok {
+		return &countedBody{body: responseBody, p: np}
+	}
 	return &pacedReadSeeker{ctx: ctx, body: responseBody, p: p}
 }
 
+// countedBody records the bytes read from its body as traffic, without pacing them.
+// A body might never be read in full (e.g. if its request fails), so nothing is counted until it has been read.
+type countedBody struct {
+	body io.Reader
+	p    *nullAutoPacer
+}
+
+func (cb *countedBody) Read(p []byte) (int, error) {
+	n, err := cb.body.Read(p)
+	cb.p.recordTraffic(int64(n))
+	return n, err
+}
+
+func (cb *countedBody) Seek(offset int64, whence int) (int64, error) {
+	return cb.body.(io.ReadSeeker).Seek(offset, whence)
+}
+
+func (cb *countedBody) Close() error {
+	if c, ok := cb.body.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
 func (prs *pacedReadSeeker) Read(p []byte) (int, error) {
 	requestedCount := len(p)
 
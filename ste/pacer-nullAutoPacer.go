@@ -48,6 +48,11 @@ func (a *nullAutoPacer) RequestTrafficAllocation(ctx context.Context, byteCount
 	return nil
 }
 
+// recordTraffic counts bytes which were not requested through RequestTrafficAllocation, as they are not paced
+func (a *nullAutoPacer) recordTraffic(byteCount int64) {
+	atomic.AddInt64(&a.atomicGrandTotal, byteCount)
+}
+
 func (a *nullAutoPacer) UndoRequest(byteCount int64) {
 	atomic.AddInt64(&a.atomicGrandTotal, -byteCount)
 }
@@ -0,0 +1,101 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// How often the scheduled pacer checks whether a new window of its schedule has started
+const scheduleCheckInterval = 15 * time.Second
+
+// scheduledPacer is a token bucket pacer whose target rate follows a time-of-day schedule, as given by --cap-mbps.
+// While the active window has no cap, traffic bypasses the bucket entirely, and is only counted.
+type scheduledPacer struct {
+	*tokenBucketPacer
+	schedule     common.CapMbpsSchedule
+	now          func() time.Time
+	atomicCapped int32
+	scheduleDone chan struct{}
+}
+
+func newScheduledPacer(schedule common.CapMbpsSchedule, now func() time.Time) *scheduledPacer {
+	unusedExpectedCoarseRequestByteCount := int64(0)
+	p := &scheduledPacer{
+		tokenBucketPacer: newTokenBucketPacer(mbpsToBytesPerSecond(schedule.CapAt(now())), unusedExpectedCoarseRequestByteCount),
+		schedule:         schedule,
+		now:              now,
+		scheduleDone:     make(chan struct{}),
+	}
+	p.applySchedule()
+
+	go p.scheduleBody()
+
+	return p
+}
+
+// use the "networking mega" (based on powers of 10, not powers of 2, since that's what mega means in networking context)
+func mbpsToBytesPerSecond(mbps float64) int64 {
+	return int64(mbps * 1000 * 1000 / 8)
+}
+
+// applySchedule sets the target rate to that of the window that is active now
+func (p *scheduledPacer) applySchedule() {
+	targetBytesPerSecond := mbpsToBytesPerSecond(p.schedule.CapAt(p.now()))
+	if targetBytesPerSecond > 0 {
+		p.setTargetBytesPerSecond(targetBytesPerSecond)
+		atomic.StoreInt32(&p.atomicCapped, 1)
+	} else {
+		atomic.StoreInt32(&p.atomicCapped, 0)
+	}
+}
+
+func (p *scheduledPacer) isCapped() bool {
+	return atomic.LoadInt32(&p.atomicCapped) == 1
+}
+
+func (p *scheduledPacer) scheduleBody() {
+	for {
+		select {
+		case <-p.scheduleDone:
+			return
+		case <-time.After(scheduleCheckInterval):
+			p.applySchedule()
+		}
+	}
+}
+
+func (p *scheduledPacer) RequestTrafficAllocation(ctx context.Context, byteCount int64) error {
+	if !p.isCapped() {
+		atomic.AddInt64(&p.atomicGrandTotal, byteCount) // just record it, like the bucket would have done
+		return nil
+	}
+	return p.tokenBucketPacer.RequestTrafficAllocation(ctx, byteCount)
+}
+
+func (p *scheduledPacer) Close() error {
+	close(p.scheduleDone)
+	return p.tokenBucketPacer.Close()
+}
@@ -111,6 +111,13 @@ func (s *appendBlobSenderBase) RemoteFileExists() (bool, time.Time, error) {
 	return remoteObjectExists(s.destAppendBlobURL.GetProperties(s.jptm.Context(), azblob.BlobAccessConditions{}, s.cpkToApply))
 }
 
+func (s *appendBlobSenderBase) SnapshotDestination() (string, error) {
+	return snapshotBlob(s.jptm.Context(), s.destAppendBlobURL.BlobURL, s.cpkToApply)
+}
+
+// SendsChunksInOrder marks append blob senders as orderedSenders, since each block is appended after the previous one
+func (s *appendBlobSenderBase) SendsChunksInOrder() {}
+
 // Returns a chunk-func for sending append blob to remote
 func (s *appendBlobSenderBase) generateAppendBlockToRemoteFunc(id common.ChunkID, appendBlock appendBlockFunc) chunkFunc {
 	// Copy must be totally sequential for append blobs
@@ -152,7 +159,10 @@ func (s *appendBlobSenderBase) Prologue(ps common.PrologueState) (destinationMod
 	if separateSetTagsRequired || len(blobTags) == 0 {
 		blobTags = nil
 	}
-	if _, err := s.destAppendBlobURL.Create(s.jptm.Context(), s.headersToApply, s.metadataToApply, azblob.BlobAccessConditions{}, blobTags, s.cpkToApply); err != nil {
+	if err := overwriteDespiteSnapshots(s.jptm, s.destAppendBlobURL.BlobURL, func() error {
+		_, err := s.destAppendBlobURL.Create(s.jptm.Context(), s.headersToApply, s.metadataToApply, azblob.BlobAccessConditions{}, blobTags, s.cpkToApply)
+		return err
+	}); err != nil {
 		s.jptm.FailActiveSend("Creating blob", err)
 		return
 	}
@@ -335,7 +335,9 @@ func (u *azureFileSenderBase) Epilogue() {
 	//      This is not trivial but the Files Team has explicitly told us to perform this extra set call.
 	//   2. The service started updating the last-write-time in March 2021 when the file is modified.
 	//      So when we uploaded the ranges, we've unintentionally changed the last-write-time.
-	if u.jptm.IsLive() && u.jptm.Info().PreserveSMBInfo {
+	//   3. The permission descriptor from the source must be set again with the update syntax, even when no other SMB info is preserved.
+	info := u.jptm.Info()
+	if u.jptm.IsLive() && (info.PreserveSMBInfo || info.PreserveSMBPermissions.IsTruthy()) {
 		//This is an extra round trip, but we can live with that for these relatively rare cases
 		_, err := u.fileURL().SetHTTPHeaders(u.ctx, u.headersToApply)
 		if err != nil {
@@ -22,10 +22,13 @@ package ste
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -58,14 +61,36 @@ type blockBlobSenderBase struct {
 	blobTagsToApply azblob.BlobTagsMap
 	cpkToApply      azblob.ClientProvidedKeyOptions
 
+	// the Content-MD5 of the destination, as read back by GetDestinationLength
+	destContentMD5 []byte
+
+	// blockNamePrefix makes our block IDs a deterministic function of the transfer, so that a
+	// resumed transfer re-generates exactly the same IDs as the run that was interrupted
+	blockNamePrefix string
+	// stagedBlocks holds the indices of blocks that an interrupted run of this same transfer already staged.
+	// It is only written in the Prologue, before any chunk funcs run.
+	stagedBlocks map[int32]bool
+
 	atomicChunksWritten    int32
 	atomicPutListIndicator int32
 	muBlockIDs             *sync.Mutex
 }
 
+// length of the (un-encoded) index suffix of our block IDs. Enough for common.MaxNumberOfBlocksPerBlob
+const blockIndexWidth = 5
+
+// how many bytes of the source digest go into each block ID. Together with the job ID and the index,
+// this keeps the un-encoded ID within the service's limit of 64 bytes
+const blockSourceDigestLength = 8
+
 func getVerifiedChunkParams(transferInfo TransferInfo, memLimit int64) (chunkSize int64, numChunks uint32, err error) {
 	chunkSize = transferInfo.BlockSize
 	srcSize := transferInfo.SourceSize
+	// a source up to the Put Blob size is sent whole, as a single chunk, however big the blocks of larger sources are
+	isPutBlob := srcSize > 0 && srcSize <= transferInfo.PutBlobSize
+	if isPutBlob {
+		chunkSize = srcSize
+	}
 	numChunks = getNumChunks(srcSize, chunkSize)
 
 	toGiB := func(bytes int64) float64 {
@@ -89,6 +114,14 @@ func getVerifiedChunkParams(transferInfo TransferInfo, memLimit int64) (chunkSiz
 		return
 	}
 
+	// the chunk has to fit under the strict limit of the cache, or reading it would wait forever for room
+	if strictLimit := common.NewCacheLimiter(memLimit).StrictLimit(); chunkSize > strictLimit {
+		err = fmt.Errorf("Cannot read a chunk of %.2fGiB for file %s. AzCopy can only use %.2fGiB of memory for a single chunk",
+			toGiB(chunkSize), transferInfo.Source, toGiB(strictLimit))
+		return
+	}
+
+	// a whole-file chunk is no exception, since the slice pool has nothing bigger to give
 	if chunkSize > common.MaxBlockBlobBlockSize {
 		// mercy, please
 		err = fmt.Errorf("block size of %.2fGiB for file %s of size %.2fGiB exceeds maxmimum allowed block size for a BlockBlob",
@@ -151,9 +184,24 @@ func newBlockBlobSenderBase(jptm IJobPartTransferMgr, destination string, p pipe
 		blobTagsToApply:  props.SrcBlobTags.ToAzBlobTagsMap(),
 		destBlobTier:     destBlobTier,
 		cpkToApply:       cpkToApply,
+		blockNamePrefix:  getBlockNamePrefix(jptm.Info()),
 		muBlockIDs:       &sync.Mutex{}}, nil
 }
 
+// getBlockNamePrefix returns a fixed-width prefix that identifies the given transfer within its job.
+// It is made from the job ID and a digest of the source, so it is stable across any number of resumes of the job.
+// All IDs for one blob must be the same length, so every component is fixed-width.
+func getBlockNamePrefix(info TransferInfo) string {
+	source := info.Source
+	// the SAS (or other query) of a remote source may legitimately change between runs, so leave it out
+	if u, err := url.Parse(source); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		u.RawQuery = ""
+		source = u.String()
+	}
+	sourceDigest := sha256.Sum256([]byte(source))
+	return fmt.Sprintf("%s%x", info.JobID.String(), sourceDigest[:blockSourceDigestLength])
+}
+
 func (s *blockBlobSenderBase) SendableEntityType() common.EntityType {
 	return common.EEntityType.File()
 }
@@ -170,6 +218,19 @@ func (s *blockBlobSenderBase) RemoteFileExists() (bool, time.Time, error) {
 	return remoteObjectExists(s.destBlockBlobURL.GetProperties(s.jptm.Context(), azblob.BlobAccessConditions{}, s.cpkToApply))
 }
 
+func (s *blockBlobSenderBase) SnapshotDestination() (string, error) {
+	return snapshotBlob(s.jptm.Context(), s.destBlockBlobURL.BlobURL, s.cpkToApply)
+}
+
+// snapshotBlob takes a snapshot of an existing blob, of any type, and returns the snapshot's timestamp
+func snapshotBlob(ctx context.Context, blobURL azblob.BlobURL, cpk azblob.ClientProvidedKeyOptions) (string, error) {
+	resp, err := blobURL.CreateSnapshot(ctx, azblob.Metadata{}, azblob.BlobAccessConditions{}, cpk)
+	if err != nil {
+		return "", err
+	}
+	return resp.Snapshot(), nil
+}
+
 func (s *blockBlobSenderBase) Prologue(ps common.PrologueState) (destinationModified bool) {
 	if s.jptm.ShouldInferContentType() {
 		s.headersToApply.ContentType = ps.GetInferredContentType(s.jptm)
@@ -177,6 +238,14 @@ func (s *blockBlobSenderBase) Prologue(ps common.PrologueState) (destinationModi
 	return false
 }
 
+// blockListCommitter is implemented by the senders whose epilogue commits a block list.
+// When there's more than one block, that epilogue is run by the commit pool.
+type blockListCommitter interface {
+	commitsBlockList()
+}
+
+func (s *blockBlobSenderBase) commitsBlockList() {}
+
 func (s *blockBlobSenderBase) Epilogue() {
 	jptm := s.jptm
 
@@ -211,10 +280,18 @@ func (s *blockBlobSenderBase) Epilogue() {
 			destBlobTier = azblob.AccessTierNone
 		}
 
-		if _, err := s.destBlockBlobURL.CommitBlockList(jptm.Context(), blockIDs, s.headersToApply, s.metadataToApply, azblob.BlobAccessConditions{}, destBlobTier, blobTags, s.cpkToApply); err != nil {
-			jptm.FailActiveSend("Committing block list", err)
+		var resp *azblob.BlockBlobCommitBlockListResponse
+		err := commitDespiteSnapshots(jptm, s.destBlockBlobURL.BlobURL, func() (err error) {
+			resp, err = s.destBlockBlobURL.CommitBlockList(jptm.Context(), blockIDs, s.headersToApply, s.metadataToApply, s.destAccessConditions(), destBlobTier, blobTags, s.cpkToApply)
+			return err
+		})
+		if err != nil {
+			if !skipIfDestinationCreatedMeanwhile(jptm, err) {
+				jptm.FailActiveSend("Committing block list", err)
+			}
 			return
 		}
+		jptm.SetETag(string(resp.ETag()))
 
 		if separateSetTagsRequired {
 			if _, err := s.destBlockBlobURL.SetTags(jptm.Context(), nil, nil, nil, s.blobTagsToApply); err != nil {
@@ -253,7 +330,10 @@ func (s *blockBlobSenderBase) Cleanup() {
 		// Delete the uncommitted blobs
 		deletionContext, cancelFn := context.WithTimeout(context.WithValue(context.Background(), ServiceAPIVersionOverride, DefaultServiceApiVersion), 30*time.Second)
 		defer cancelFn()
-		if jptm.WasCanceled() {
+		if jptm.WasPaused() {
+			// Keep whatever we've staged, so that the resumed transfer doesn't have to send those blocks again
+			jptm.LogAtLevelForCurrentTransfer(pipeline.LogDebug, "Keeping uncommitted blocks, since the job was paused")
+		} else if jptm.WasCanceled() {
 			// If we cancelled, and the only blocks that exist are uncommitted, then clean them up.
 			// This prevents customer paying for their storage for a week until they get garbage collected, and it
 			// also prevents any issues with "too many uncommitted blocks" if user tries to upload the blob again in future.
@@ -265,6 +345,9 @@ func (s *blockBlobSenderBase) Cleanup() {
 				// Delete can delete uncommitted blobs.
 				_, _ = s.destBlockBlobURL.Delete(deletionContext, azblob.DeleteSnapshotsOptionNone, azblob.BlobAccessConditions{})
 			}
+		} else if jptm.TransferStatusIgnoringCancellation() == common.ETransferStatus.SkippedEntityAlreadyExists() {
+			// The blob was created by someone else while we were staging our blocks. It's theirs, so leave it alone
+			jptm.LogAtLevelForCurrentTransfer(pipeline.LogDebug, "Keeping destination blob, since it was not written by this transfer")
 		} else {
 			// TODO: review (one last time) should we really do this?  Or should we just give better error messages on "too many uncommitted blocks" errors
 			jptm.LogAtLevelForCurrentTransfer(pipeline.LogDebug, "Deleting destination blob due to failure")
@@ -273,6 +356,53 @@ func (s *blockBlobSenderBase) Cleanup() {
 	}
 }
 
+// destAccessConditions are the conditions for writing the destination blob.
+// With --if-none-match, the service only writes the blob if it doesn't exist yet. Unlike checking for the blob
+// before the transfer, as --overwrite=false does, that can't race with another process creating the same blob.
+func (s *blockBlobSenderBase) destAccessConditions() azblob.BlobAccessConditions {
+	if s.jptm.ShouldUploadIfNoneMatch() {
+		return azblob.BlobAccessConditions{ModifiedAccessConditions: azblob.ModifiedAccessConditions{IfNoneMatch: azblob.ETagAny}}
+	}
+	return azblob.BlobAccessConditions{}
+}
+
+// skipIfDestinationCreatedMeanwhile checks whether err is the service refusing a write made with --if-none-match,
+// because the blob exists. If so, the transfer is marked as skipped, rather than failed, and true is returned.
+func skipIfDestinationCreatedMeanwhile(jptm IJobPartTransferMgr, err error) bool {
+	if !jptm.ShouldUploadIfNoneMatch() {
+		return false
+	}
+	if stgErr, ok := err.(azblob.StorageError); !ok || stgErr.Response() == nil || stgErr.Response().StatusCode != http.StatusPreconditionFailed {
+		return false
+	}
+
+	// logging as Warning, like other skips of existing files
+	jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning, "Blob already exists, so will be skipped")
+	jptm.SetStatus(common.ETransferStatus.SkippedEntityAlreadyExists())
+	return true
+}
+
+// md5MetadataKey is the metadata entry that --put-md5 also saves the hash in, for tools that read it from metadata
+// rather than from the Content-MD5 property
+const md5MetadataKey = "md5"
+
+// setContentMD5 applies the hash of the uploaded data to the blob's headers and, if MD5s are to be put, to its metadata.
+// The computed hash takes precedence over any md5 entry in the metadata given for the transfer.
+func (s *blockBlobSenderBase) setContentMD5(md5Hash []byte) {
+	s.headersToApply.ContentMD5 = md5Hash
+	if !s.jptm.ShouldPutMd5() || len(md5Hash) == 0 {
+		return
+	}
+
+	// copy the metadata, since it may be shared with the transfer's properties
+	metadata := azblob.Metadata{}
+	for k, v := range s.metadataToApply {
+		metadata[k] = v
+	}
+	metadata[md5MetadataKey] = base64.StdEncoding.EncodeToString(md5Hash)
+	s.metadataToApply = metadata
+}
+
 func (s *blockBlobSenderBase) setBlockID(index int32, value string) {
 	s.muBlockIDs.Lock()
 	defer s.muBlockIDs.Unlock()
@@ -282,7 +412,55 @@ func (s *blockBlobSenderBase) setBlockID(index int32, value string) {
 	s.blockIDs[index] = value
 }
 
-func (s *blockBlobSenderBase) generateEncodedBlockID() string {
-	blockID := common.NewUUID().String()
+func (s *blockBlobSenderBase) generateEncodedBlockID(index int32) string {
+	blockID := fmt.Sprintf("%s%0*d", s.blockNamePrefix, blockIndexWidth, index)
 	return base64.StdEncoding.EncodeToString([]byte(blockID))
 }
+
+// findStagedBlocks looks for blocks that were staged, but not committed, by an earlier run of this same
+// transfer (i.e. a run that was interrupted before the job was resumed). Those blocks don't need to be sent again.
+func (s *blockBlobSenderBase) findStagedBlocks() {
+	blockList, err := s.destBlockBlobURL.GetBlockList(s.jptm.Context(), azblob.BlockListUncommitted, azblob.LeaseAccessConditions{})
+	if err != nil {
+		// most commonly, the blob does not exist yet. In any case, just upload the whole thing
+		return
+	}
+
+	s.stagedBlocks = s.parseStagedBlocks(blockList.UncommittedBlocks, s.jptm.Info().SourceSize)
+	if len(s.stagedBlocks) > 0 {
+		s.jptm.LogAtLevelForCurrentTransfer(pipeline.LogInfo, fmt.Sprintf("Found %d blocks staged by an earlier attempt. They will not be uploaded again", len(s.stagedBlocks)))
+	}
+}
+
+// parseStagedBlocks returns the indices of the given uncommitted blocks. If there are any blocks that
+// we did not stage, or that were staged with a different chunk size, none of them can be trusted and nil is returned.
+func (s *blockBlobSenderBase) parseStagedBlocks(uncommittedBlocks []azblob.Block, sourceSize int64) map[int32]bool {
+	staged := make(map[int32]bool)
+	for _, block := range uncommittedBlocks {
+		decoded, err := base64.StdEncoding.DecodeString(block.Name)
+		if err != nil || len(decoded) != len(s.blockNamePrefix)+blockIndexWidth || !strings.HasPrefix(string(decoded), s.blockNamePrefix) {
+			return nil
+		}
+
+		index, err := strconv.ParseInt(string(decoded[len(s.blockNamePrefix):]), 10, 32)
+		if err != nil || index < 0 || index >= int64(s.numChunks) {
+			return nil
+		}
+
+		// only the last block is allowed to be shorter than the chunk size
+		expectedSize := s.chunkSize
+		if index == int64(s.numChunks)-1 {
+			expectedSize = sourceSize - index*s.chunkSize
+		}
+		if block.Size != expectedSize {
+			return nil
+		}
+
+		staged[int32(index)] = true
+	}
+	return staged
+}
+
+func (s *blockBlobSenderBase) isBlockStaged(index int32) bool {
+	return s.stagedBlocks[index]
+}
@@ -0,0 +1,214 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sync/atomic"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// blockBlobCompressingUploader gzips local files as it uploads them.
+// Since the compressed size isn't known until the whole file has been read, the file can't be cut up into
+// chunks by offset like we normally do. Instead the whole file is handled by a single chunk func, which stages
+// blocks one after another as the compressor emits them. So this doesn't get the parallelism (or the memory-mapped
+// file reads) of the normal upload path for a single file; it relies on there being many files in the job.
+type blockBlobCompressingUploader struct {
+	blockBlobSenderBase
+
+	md5Channel      chan []byte
+	compressedBytes int64
+}
+
+func newBlockBlobCompressingUploader(jptm IJobPartTransferMgr, destination string, p pipeline.Pipeline, pacer pacer, sip ISourceInfoProvider) (sender, error) {
+	senderBase, err := newBlockBlobSenderBase(jptm, destination, p, pacer, sip, azblob.AccessTierNone)
+	if err != nil {
+		return nil, err
+	}
+	senderBase.headersToApply.ContentEncoding = "gzip"
+
+	// we only ever schedule one chunk func for the whole file
+	senderBase.numChunks = 1
+	senderBase.blockIDs = nil
+
+	return &blockBlobCompressingUploader{blockBlobSenderBase: *senderBase, md5Channel: newMd5Channel()}, nil
+}
+
+func (u *blockBlobCompressingUploader) Md5Channel() chan<- []byte {
+	// The MD5 that matters is the one of the compressed data, which we compute ourselves as we stage it
+	return u.md5Channel
+}
+
+func (u *blockBlobCompressingUploader) GenerateUploadFunc(id common.ChunkID, blockIndex int32, reader common.SingleChunkReader, chunkIsWholeFile bool) chunkFunc {
+	panic("compressed uploads must be scheduled with GenerateStreamingUploadFunc")
+}
+
+// GenerateStreamingUploadFunc returns a chunk func that compresses the whole source file and stages the result as blocks
+func (u *blockBlobCompressingUploader) GenerateStreamingUploadFunc(id common.ChunkID, sourceFileFactory common.ChunkReaderSourceFactory, srcSize int64) chunkFunc {
+	setPutListNeed(&u.atomicPutListIndicator, putListNeeded)
+
+	return createSendToRemoteChunkFunc(u.jptm, id, func() {
+		jptm := u.jptm
+		srcFile, err := sourceFileFactory()
+		if err != nil {
+			jptm.FailActiveUpload("Opening source", err)
+			return
+		}
+		defer srcFile.Close()
+
+		jptm.LogChunkStatus(id, common.EWaitReason.Body())
+
+		var md5Hasher hash.Hash
+		if jptm.ShouldPutMd5() {
+			md5Hasher = md5.New()
+		} else {
+			md5Hasher = common.NewNullHasher()
+		}
+
+		blockIDs := make([]string, 0)
+		compressedBytes, err := stageCompressedBlocks(io.NewSectionReader(srcFile, 0, srcSize), u.chunkSize, jptm.SlicePool(), func(blockIndex int32, block []byte) error {
+			if blockIndex >= common.MaxNumberOfBlocksPerBlob {
+				return fmt.Errorf("compressed data needs more than %d blocks of size %d", common.MaxNumberOfBlocksPerBlob, u.chunkSize)
+			}
+			encodedBlockID := u.generateEncodedBlockID(blockIndex)
+			var blockMD5 []byte
+			if jptm.ShouldValidateBlocks() {
+				sum := md5.Sum(block)
+				blockMD5 = sum[:]
+			}
+			body := newPacedRequestBody(jptm.Context(), bytes.NewReader(block), u.pacer)
+			if _, err := u.destBlockBlobURL.StageBlock(jptm.Context(), encodedBlockID, body, azblob.LeaseAccessConditions{}, blockMD5, u.cpkToApply); err != nil {
+				return err
+			}
+			md5Hasher.Write(block)
+			blockIDs = append(blockIDs, encodedBlockID)
+			atomic.AddInt32(&u.atomicChunksWritten, 1)
+			return nil
+		})
+		if err != nil {
+			jptm.FailActiveUpload("Compressing and staging blocks", err)
+			return
+		}
+
+		u.muBlockIDs.Lock()
+		u.blockIDs = blockIDs
+		u.muBlockIDs.Unlock()
+		atomic.StoreInt64(&u.compressedBytes, compressedBytes)
+		u.md5Channel <- md5Hasher.Sum(nil)
+	})
+}
+
+func (u *blockBlobCompressingUploader) Prologue(ps common.PrologueState) (destinationModified bool) {
+	// Blocks from an interrupted run can't be reused, since the compressed stream doesn't line up with the source,
+	// so (unlike blockBlobUploader) we don't look for staged blocks here
+	return u.blockBlobSenderBase.Prologue(ps)
+}
+
+func (u *blockBlobCompressingUploader) Epilogue() {
+	jptm := u.jptm
+
+	if jptm.IsLive() {
+		select {
+		case md5Hash := <-u.md5Channel:
+			if jptm.ShouldPutMd5() {
+				u.setContentMD5(md5Hash)
+			}
+		default:
+			jptm.FailActiveSend("Getting hash", errNoHash)
+			return
+		}
+	}
+
+	u.blockBlobSenderBase.Epilogue()
+}
+
+// CompressedLength is the number of bytes actually stored at the destination
+func (u *blockBlobCompressingUploader) CompressedLength() int64 {
+	return atomic.LoadInt64(&u.compressedBytes)
+}
+
+func (u *blockBlobCompressingUploader) GetDestinationLength() (int64, error) {
+	prop, err := u.destBlockBlobURL.GetProperties(u.jptm.Context(), azblob.BlobAccessConditions{}, u.cpkToApply)
+
+	if err != nil {
+		return -1, err
+	}
+
+	u.destContentMD5 = prop.ContentMD5()
+	return prop.ContentLength(), nil
+}
+
+func (u *blockBlobCompressingUploader) VerifyDestinationMD5() error {
+	return verifyContentMD5(u.headersToApply.ContentMD5, u.destContentMD5)
+}
+
+// stageCompressedBlocks gzips src, and hands the compressed stream to stage in blocks of (at most) blockSize bytes.
+// It returns the total compressed length. The block buffer is rented from slicePool, and always returned to it,
+// so stage must not keep hold of the block after it returns.
+func stageCompressedBlocks(src io.Reader, blockSize int64, slicePool common.ByteSlicePooler, stage func(blockIndex int32, block []byte) error) (int64, error) {
+	if blockSize <= 0 {
+		return 0, errors.New("block size must be positive")
+	}
+
+	compressed, pipeWriter := io.Pipe()
+	go func() {
+		gzipWriter := gzip.NewWriter(pipeWriter)
+		_, err := io.Copy(gzipWriter, src)
+		if closeErr := gzipWriter.Close(); err == nil {
+			err = closeErr
+		}
+		_ = pipeWriter.CloseWithError(err) // a nil err closes the pipe normally, so the reader sees EOF
+	}()
+	// if we bail out early, make sure the compressing goroutine doesn't block forever on the pipe
+	defer compressed.Close()
+
+	buffer := slicePool.RentSlice(blockSize)
+	defer slicePool.ReturnSlice(buffer)
+
+	totalBytes := int64(0)
+	for blockIndex := int32(0); ; blockIndex++ {
+		n, err := io.ReadFull(compressed, buffer)
+		if err == io.EOF {
+			return totalBytes, nil
+		} else if err != nil && err != io.ErrUnexpectedEOF {
+			return totalBytes, err
+		}
+
+		if stageErr := stage(blockIndex, buffer[:n]); stageErr != nil {
+			return totalBytes, stageErr
+		}
+		totalBytes += int64(n)
+
+		if err == io.ErrUnexpectedEOF {
+			return totalBytes, nil // that was the last, partial, block
+		}
+	}
+}
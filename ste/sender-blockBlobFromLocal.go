@@ -22,6 +22,9 @@ package ste
 
 import (
 	"bytes"
+	"crypto/md5"
+	"fmt"
+	"io"
 	"sync/atomic"
 
 	"github.com/Azure/azure-pipeline-go/pipeline"
@@ -49,9 +52,19 @@ func (u *blockBlobUploader) Md5Channel() chan<- []byte {
 	return u.md5Channel
 }
 
+func (u *blockBlobUploader) Prologue(ps common.PrologueState) (destinationModified bool) {
+	// only a resumed job can find blocks of its own already staged, so a fresh upload doesn't spend a request looking
+	if u.numChunks > 1 && u.jptm.JobWasResumed() {
+		u.findStagedBlocks()
+	}
+	return u.blockBlobSenderBase.Prologue(ps)
+}
+
 // Returns a chunk-func for blob uploads
 func (u *blockBlobUploader) GenerateUploadFunc(id common.ChunkID, blockIndex int32, reader common.SingleChunkReader, chunkIsWholeFile bool) chunkFunc {
-	if chunkIsWholeFile {
+	// a file over the Put Blob size is sent as a block, even when one block is enough to hold it
+	info := u.jptm.Info()
+	if chunkIsWholeFile && (info.PutBlobSize == 0 || info.SourceSize <= info.PutBlobSize) {
 		if blockIndex > 0 {
 			panic("chunk cannot be whole file where there is more than one chunk")
 		}
@@ -67,15 +80,33 @@ func (u *blockBlobUploader) GenerateUploadFunc(id common.ChunkID, blockIndex int
 func (u *blockBlobUploader) generatePutBlock(id common.ChunkID, blockIndex int32, reader common.SingleChunkReader) chunkFunc {
 	return createSendToRemoteChunkFunc(u.jptm, id, func() {
 		// step 1: generate block ID
-		encodedBlockID := u.generateEncodedBlockID()
+		encodedBlockID := u.generateEncodedBlockID(blockIndex)
 
 		// step 2: save the block ID into the list of block IDs
 		u.setBlockID(blockIndex, encodedBlockID)
 
-		// step 3: put block to remote
+		// skip blocks that are already there, from an earlier attempt at this transfer
+		if u.isBlockStaged(blockIndex) {
+			u.jptm.LogAtLevelForCurrentTransfer(pipeline.LogDebug, fmt.Sprintf("Block %d was already staged", blockIndex))
+			atomic.AddInt32(&u.atomicChunksWritten, 1)
+			return
+		}
+
+		// step 3: hash the block, if the service is to check that it arrived intact
+		var blockMD5 []byte
+		if u.jptm.ShouldValidateBlocks() {
+			md5Hasher := md5.New()
+			reader.WriteBufferTo(md5Hasher)
+			blockMD5 = md5Hasher.Sum(nil)
+		}
+
+		// step 4: put block to remote
 		u.jptm.LogChunkStatus(id, common.EWaitReason.Body())
-		body := newPacedRequestBody(u.jptm.Context(), reader, u.pacer)
-		_, err := u.destBlockBlobURL.StageBlock(u.jptm.Context(), encodedBlockID, body, azblob.LeaseAccessConditions{}, nil, u.cpkToApply)
+		err := retryChunk(u.jptm, id, reader, func(chunkData io.ReadSeeker) error {
+			body := newPacedRequestBody(u.jptm.Context(), chunkData, u.pacer)
+			_, err := u.destBlockBlobURL.StageBlock(u.jptm.Context(), encodedBlockID, body, azblob.LeaseAccessConditions{}, blockMD5, u.cpkToApply)
+			return err
+		})
 		if err != nil {
 			u.jptm.FailActiveUpload("Staging block", err)
 			return
@@ -85,6 +116,49 @@ func (u *blockBlobUploader) generatePutBlock(id common.ChunkID, blockIndex int32
 	})
 }
 
+// retryChunk sends a chunk, and sends it again (up to UploadChunkRetries times) if that fails with an error that
+// the retry policy treats as transient.
+// The pipeline has already retried each request by then, but a failure can still be a one-off, and it's much cheaper
+// to re-send the one chunk than to fail the transfer and have the whole file sent again.
+// Anything else, e.g. an auth failure or a bad request, would only fail again, so it's returned right away.
+// The pipeline closes request bodies once it's done with them, so send is given a view of the reader that
+// it can't close, and the reader itself is closed here when there are no more tries to make.
+func retryChunk(jptm IJobPartTransferMgr, id common.ChunkID, reader common.SingleChunkReader, send func(chunkData io.ReadSeeker) error) error {
+	defer reader.Close()
+	chunkData := &nonClosingReadSeeker{reader}
+
+	err := send(chunkData)
+	for retry := 1; err != nil && retry <= UploadChunkRetries; retry++ {
+		if jptm.Context().Err() != nil {
+			return err // cancelled, so there's no point trying again
+		}
+		if !isTransientChunkError(err) {
+			return err
+		}
+		if _, seekErr := reader.Seek(0, io.SeekStart); seekErr != nil {
+			return err
+		}
+		jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning,
+			fmt.Sprintf("Chunk at offset %d failed, so sending it again (retry %d of %d). Error was: %v", id.OffsetInFile(), retry, UploadChunkRetries, err))
+		err = send(chunkData)
+	}
+	return err
+}
+
+// isTransientChunkError is true for the errors that sending the chunk again might get past. As well as those the
+// retry policy treats as transient, that includes the service rejecting the chunk as corrupted in transit
+func isTransientChunkError(err error) bool {
+	if stErr, ok := err.(azblob.StorageError); ok && stErr.ServiceCode() == azblob.ServiceCodeMd5Mismatch {
+		return true
+	}
+	return isTransientBlobError(err)
+}
+
+// nonClosingReadSeeker hides the Close method of the reader it wraps
+type nonClosingReadSeeker struct {
+	io.ReadSeeker
+}
+
 // generates PUT Blob (for a blob that fits in a single put request)
 func (u *blockBlobUploader) generatePutWholeBlob(id common.ChunkID, blockIndex int32, reader common.SingleChunkReader) chunkFunc {
 
@@ -110,30 +184,38 @@ func (u *blockBlobUploader) generatePutWholeBlob(id common.ChunkID, blockIndex i
 			destBlobTier = azblob.AccessTierNone
 		}
 
-		if jptm.Info().SourceSize == 0 {
-			_, err = u.destBlockBlobURL.Upload(jptm.Context(), bytes.NewReader(nil), u.headersToApply, u.metadataToApply, azblob.BlobAccessConditions{}, destBlobTier, blobTags, u.cpkToApply)
-		} else {
-			// File with content
-
-			// Get the MD5 that was computed as we read the file
-			md5Hash, ok := <-u.md5Channel
-			if !ok {
-				jptm.FailActiveUpload("Getting hash", errNoHash)
-				return
-			}
-			u.headersToApply.ContentMD5 = md5Hash
+		// Get the MD5 that was computed as we read the file. Empty files get one too (the hash of no data),
+		// so that their blobs can be checked on download just like any other
+		md5Hash, ok := <-u.md5Channel
+		if !ok {
+			jptm.FailActiveUpload("Getting hash", errNoHash)
+			return
+		}
+		u.setContentMD5(md5Hash)
 
-			// Upload the file
-			body := newPacedRequestBody(jptm.Context(), reader, u.pacer)
-			_, err = u.destBlockBlobURL.Upload(jptm.Context(), body, u.headersToApply, u.metadataToApply,
-				azblob.BlobAccessConditions{}, u.destBlobTier, blobTags, u.cpkToApply)
+		// Upload the file. An empty file has nothing to read, so it has nothing to pace either
+		var body io.ReadSeeker = bytes.NewReader(nil)
+		if jptm.Info().SourceSize > 0 {
+			body = newPacedRequestBody(jptm.Context(), reader, u.pacer)
 		}
+		var resp *azblob.BlockBlobUploadResponse
+		err = overwriteDespiteSnapshots(jptm, u.destBlockBlobURL.BlobURL, func() (err error) {
+			if _, err = body.Seek(0, io.SeekStart); err != nil { // in case this is the second attempt
+				return err
+			}
+			resp, err = u.destBlockBlobURL.Upload(jptm.Context(), body, u.headersToApply, u.metadataToApply,
+				u.destAccessConditions(), destBlobTier, blobTags, u.cpkToApply)
+			return err
+		})
 
 		// if the put blob is a failure, update the transfer status to failed
 		if err != nil {
-			jptm.FailActiveUpload("Uploading blob", err)
+			if !skipIfDestinationCreatedMeanwhile(jptm, err) {
+				jptm.FailActiveUpload("Uploading blob", err)
+			}
 			return
 		}
+		jptm.SetETag(string(resp.ETag()))
 
 		atomic.AddInt32(&u.atomicChunksWritten, 1)
 
@@ -154,7 +236,7 @@ func (u *blockBlobUploader) Epilogue() {
 
 		md5Hash, ok := <-u.md5Channel
 		if ok {
-			u.headersToApply.ContentMD5 = md5Hash
+			u.setContentMD5(md5Hash)
 		} else {
 			jptm.FailActiveSend("Getting hash", errNoHash)
 			return
@@ -171,5 +253,10 @@ func (u *blockBlobUploader) GetDestinationLength() (int64, error) {
 		return -1, err
 	}
 
+	u.destContentMD5 = prop.ContentMD5()
 	return prop.ContentLength(), nil
 }
+
+func (u *blockBlobUploader) VerifyDestinationMD5() error {
+	return verifyContentMD5(u.headersToApply.ContentMD5, u.destContentMD5)
+}
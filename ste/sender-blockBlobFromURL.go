@@ -107,10 +107,18 @@ func (c *urlToBlockBlobCopier) generateCreateEmptyBlob(id common.ChunkID) chunkF
 			destBlobTier = azblob.AccessTierNone
 		}
 
-		if _, err := c.destBlockBlobURL.Upload(c.jptm.Context(), bytes.NewReader(nil), c.headersToApply, c.metadataToApply, azblob.BlobAccessConditions{}, destBlobTier, blobTags, c.cpkToApply); err != nil {
-			jptm.FailActiveSend("Creating empty blob", err)
+		var resp *azblob.BlockBlobUploadResponse
+		err := overwriteDespiteSnapshots(jptm, c.destBlockBlobURL.BlobURL, func() (err error) {
+			resp, err = c.destBlockBlobURL.Upload(c.jptm.Context(), bytes.NewReader(nil), c.headersToApply, c.metadataToApply, c.destAccessConditions(), destBlobTier, blobTags, c.cpkToApply)
+			return err
+		})
+		if err != nil {
+			if !skipIfDestinationCreatedMeanwhile(jptm, err) {
+				jptm.FailActiveSend("Creating empty blob", err)
+			}
 			return
 		}
+		jptm.SetETag(string(resp.ETag()))
 
 		atomic.AddInt32(&c.atomicChunksWritten, 1)
 
@@ -126,7 +134,7 @@ func (c *urlToBlockBlobCopier) generateCreateEmptyBlob(id common.ChunkID) chunkF
 func (c *urlToBlockBlobCopier) generatePutBlockFromURL(id common.ChunkID, blockIndex int32, adjustedChunkSize int64) chunkFunc {
 	return createSendToRemoteChunkFunc(c.jptm, id, func() {
 		// step 1: generate block ID
-		encodedBlockID := c.generateEncodedBlockID()
+		encodedBlockID := c.generateEncodedBlockID(blockIndex)
 
 		// step 2: save the block ID into the list of block IDs
 		c.setBlockID(blockIndex, encodedBlockID)
@@ -174,12 +182,17 @@ func (c *urlToBlockBlobCopier) generateStartPutBlobFromURL(id common.ChunkID, bl
 			c.jptm.FailActiveUpload("Pacing block", err)
 		}
 
-		_, err := c.destBlockBlobURL.PutBlobFromURL(c.jptm.Context(), c.headersToApply, c.srcURL, c.metadataToApply,
-			azblob.ModifiedAccessConditions{}, azblob.BlobAccessConditions{}, nil, nil, destBlobTier, blobTags,
-			c.cpkToApply)
+		err := overwriteDespiteSnapshots(c.jptm, c.destBlockBlobURL.BlobURL, func() error {
+			_, err := c.destBlockBlobURL.PutBlobFromURL(c.jptm.Context(), c.headersToApply, c.srcURL, c.metadataToApply,
+				azblob.ModifiedAccessConditions{}, c.destAccessConditions(), nil, nil, destBlobTier, blobTags,
+				c.cpkToApply)
+			return err
+		})
 
 		if err != nil {
-			c.jptm.FailActiveSend("Put Blob from URL", err)
+			if !skipIfDestinationCreatedMeanwhile(c.jptm, err) {
+				c.jptm.FailActiveSend("Put Blob from URL", err)
+			}
 			return
 		}
 
@@ -172,6 +172,10 @@ func (s *pageBlobSenderBase) RemoteFileExists() (bool, time.Time, error) {
 	return remoteObjectExists(s.destPageBlobURL.GetProperties(s.jptm.Context(), azblob.BlobAccessConditions{}, s.cpkToApply))
 }
 
+func (s *pageBlobSenderBase) SnapshotDestination() (string, error) {
+	return snapshotBlob(s.jptm.Context(), s.destPageBlobURL.BlobURL, s.cpkToApply)
+}
+
 var premiumPageBlobTierRegex = regexp.MustCompile(`P\d+`)
 
 func (s *pageBlobSenderBase) Prologue(ps common.PrologueState) (destinationModified bool) {
@@ -241,16 +245,19 @@ func (s *pageBlobSenderBase) Prologue(ps common.PrologueState) (destinationModif
 		destBlobTier = azblob.PremiumPageBlobAccessTierNone
 	}
 
-	if _, err := s.destPageBlobURL.Create(s.jptm.Context(),
-		s.srcSize,
-		0,
-		s.headersToApply,
-		s.metadataToApply,
-		azblob.BlobAccessConditions{},
-		destBlobTier,
-		blobTags,
-		s.cpkToApply,
-	); err != nil {
+	if err := overwriteDespiteSnapshots(s.jptm, s.destPageBlobURL.BlobURL, func() error {
+		_, err := s.destPageBlobURL.Create(s.jptm.Context(),
+			s.srcSize,
+			0,
+			s.headersToApply,
+			s.metadataToApply,
+			azblob.BlobAccessConditions{},
+			destBlobTier,
+			blobTags,
+			s.cpkToApply,
+		)
+		return err
+	}); err != nil {
 		s.jptm.FailActiveSend("Creating blob", err)
 		return
 	}
@@ -22,6 +22,7 @@ package ste
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/Azure/azure-pipeline-go/pipeline"
@@ -66,6 +67,24 @@ type sender interface {
 	GetDestinationLength() (int64, error)
 }
 
+// destinationMD5Verifier is implemented by senders that can check the MD5 hash stored at the destination against
+// the one they sent. It is called after GetDestinationLength, which is expected to have read the stored hash
+type destinationMD5Verifier interface {
+	VerifyDestinationMD5() error
+}
+
+// destinationSnapshotter is implemented by senders whose destination can be snapshotted, so that its current content
+// is kept when the transfer overwrites it. SnapshotDestination returns the snapshot's timestamp
+type destinationSnapshotter interface {
+	SnapshotDestination() (string, error)
+}
+
+// orderedSender is implemented by senders that must send their chunks strictly in order. Their chunks go straight into
+// the chunk channel, rather than taking turns with the chunks of other transfers
+type orderedSender interface {
+	SendsChunksInOrder()
+}
+
 /////////////////////////////////////////////////////////////////////////////////////////////////
 // folderSender is a sender that also knows how to send folder property information
 /////////////////////////////////////////////////////////////////////////////////////////////////
@@ -108,6 +127,18 @@ type uploader interface {
 	Md5Channel() chan<- []byte
 }
 
+// streamingUploader is an uploader whose output can't be split into chunks by offset ahead of time
+// (e.g. because it compresses the data as it goes). It reads the whole source in one chunk func.
+type streamingUploader interface {
+	uploader
+
+	// GenerateStreamingUploadFunc returns a func() that will open the source, and upload the first srcSize bytes of it
+	GenerateStreamingUploadFunc(chunkID common.ChunkID, sourceFileFactory common.ChunkReaderSourceFactory, srcSize int64) chunkFunc
+
+	// CompressedLength returns how many bytes were actually sent, once the upload func has run
+	CompressedLength() int64
+}
+
 func newMd5Channel() chan []byte {
 	return make(chan []byte, 1) // must be buffered, so as not to hold up the goroutine running anyToRemote (which needs to start on the NEXT file after finishing its current one)
 }
@@ -169,6 +200,9 @@ func createChunkFunc(setDoneStatusOnExit bool, jptm IJobPartTransferMgr, id comm
 				defer jptm.LogChunkStatus(id, common.EWaitReason.ChunkDone())
 			}
 		}
+		if jptm.ShouldLog(pipeline.LogDebug) {
+			defer jptm.Log(pipeline.LogDebug, common.ChunkLogPrefix(id, workerId)+fmt.Sprintf("Processed %d bytes at offset %d", id.Length(), id.OffsetInFile()))
+		}
 
 		// tell the jptm that the destination should be assumed to have been modified
 		// (this is necessary for those cases where the prologue does not modify the dest, so the flag will not have been set at prologue time)
@@ -195,12 +229,18 @@ func newBlobUploader(jptm IJobPartTransferMgr, destination string, p pipeline.Pi
 
 	switch intendedType {
 	case azblob.BlobBlockBlob:
+		if jptm.ShouldCompress() {
+			return newBlockBlobCompressingUploader(jptm, destination, p, pacer, sip)
+		}
 		return newBlockBlobUploader(jptm, destination, p, pacer, sip)
 	case azblob.BlobPageBlob:
 		return newPageBlobUploader(jptm, destination, p, pacer, sip)
 	case azblob.BlobAppendBlob:
 		return newAppendBlobUploader(jptm, destination, p, pacer, sip)
 	default:
+		if jptm.ShouldCompress() {
+			return newBlockBlobCompressingUploader(jptm, destination, p, pacer, sip)
+		}
 		return newBlockBlobUploader(jptm, destination, p, pacer, sip) // If no blob type was inferred, assume block blob.
 	}
 }
@@ -21,8 +21,21 @@
 package ste
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-blob-go/azblob"
 	chk "gopkg.in/check.v1"
 )
 
@@ -58,3 +71,357 @@ func (s *blockBlobSuite) TestGetVerifiedChunkParams(c *chk.C) {
 	c.Assert(err.Error(), chk.Equals, expectedErr)
 
 }
+
+func (s *blockBlobSuite) TestGetVerifiedChunkParamsForLargeBlocks(c *chk.C) {
+	const mib = int64(1024 * 1024)
+	transferInfo := TransferInfo{
+		BlockSize:  256 * mib,
+		Source:     "tmpSrc",
+		SourceSize: 5*1024*mib + 1, // just over 5GiB, so the last block is 1 byte
+	}
+
+	chunkSize, numChunks, err := getVerifiedChunkParams(transferInfo, 4*1024*mib)
+	c.Assert(err, chk.IsNil)
+	c.Assert(chunkSize, chk.Equals, 256*mib)
+	c.Assert(numChunks, chk.Equals, uint32(21))
+
+	// the largest block the service accepts
+	transferInfo.BlockSize = common.MaxBlockBlobBlockSize
+	chunkSize, numChunks, err = getVerifiedChunkParams(transferInfo, 16*1024*mib)
+	c.Assert(err, chk.IsNil)
+	c.Assert(chunkSize, chk.Equals, int64(common.MaxBlockBlobBlockSize))
+	c.Assert(numChunks, chk.Equals, uint32(2))
+
+	// the largest blob that can be made of such blocks is not limited by the block count
+	transferInfo.SourceSize = common.MaxNumberOfBlocksPerBlob * common.MaxBlockBlobBlockSize
+	_, numChunks, err = getVerifiedChunkParams(transferInfo, 16*1024*mib)
+	c.Assert(err, chk.IsNil)
+	c.Assert(numChunks, chk.Equals, uint32(common.MaxNumberOfBlocksPerBlob))
+
+	transferInfo.SourceSize++
+	_, _, err = getVerifiedChunkParams(transferInfo, 16*1024*mib)
+	c.Assert(err, chk.NotNil)
+}
+
+func (s *blockBlobSuite) TestGetVerifiedChunkParamsWithPutBlobSize(c *chk.C) {
+	const mib = int64(1024 * 1024)
+	transferInfo := TransferInfo{
+		BlockSize:   16 * mib,
+		PutBlobSize: 256 * mib,
+		Source:      "tmpSrc",
+		SourceSize:  256 * mib,
+	}
+
+	// a file up to the threshold is sent whole, though it's many blocks long
+	chunkSize, numChunks, err := getVerifiedChunkParams(transferInfo, 4*1024*mib)
+	c.Assert(err, chk.IsNil)
+	c.Assert(chunkSize, chk.Equals, 256*mib)
+	c.Assert(numChunks, chk.Equals, uint32(1))
+
+	// and one byte more is sent in blocks
+	transferInfo.SourceSize++
+	chunkSize, numChunks, err = getVerifiedChunkParams(transferInfo, 4*1024*mib)
+	c.Assert(err, chk.IsNil)
+	c.Assert(chunkSize, chk.Equals, 16*mib)
+	c.Assert(numChunks, chk.Equals, uint32(17))
+
+	// a single Put Blob is held in memory whole, so it can be no bigger than the biggest block
+	transferInfo.PutBlobSize = common.MaxPutBlobSize
+	transferInfo.SourceSize = common.MaxBlockBlobBlockSize + 1
+	_, _, err = getVerifiedChunkParams(transferInfo, 16*1024*mib)
+	c.Assert(err, chk.NotNil)
+
+	// and must fit under the strict limit of the chunk cache
+	transferInfo.SourceSize = 900 * mib
+	_, _, err = getVerifiedChunkParams(transferInfo, 1024*mib)
+	c.Assert(err, chk.NotNil)
+	c.Assert(strings.Contains(err.Error(), "for a single chunk"), chk.Equals, true, chk.Commentf("error was %v", err))
+}
+
+func (s *blockBlobSuite) TestPutBlobSizeBelowBlockSize(c *chk.C) {
+	var mu sync.Mutex
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		requests = append(requests, req.Method+" "+req.URL.Query().Get("comp"))
+		mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	send := func(size, putBlobSize int64) []string {
+		requests = nil
+		srcPath := filepath.Join(c.MkDir(), "file.bin")
+		c.Assert(ioutil.WriteFile(srcPath, make([]byte, size), 0644), chk.IsNil)
+		jptm := &testTransferMgr{info: TransferInfo{Source: srcPath, SourceSize: size, PutBlobSize: putBlobSize}}
+		uploader, err := newTestBlockBlobUploader(jptm, server.URL+"/container/file.bin")
+		c.Assert(err, chk.IsNil)
+		uploader.blockIDs = make([]string, 1)
+
+		srcFile, err := os.Open(srcPath)
+		c.Assert(err, chk.IsNil)
+		defer srcFile.Close()
+		factory := func() (common.CloseableReaderAt, error) { return os.Open(srcPath) }
+		scheduleSendChunks(jptm, srcPath, srcFile, size, uploader, factory, localSourceInfoProvider{})
+		c.Assert(jptm.chunks, chk.HasLen, 1) // both fit in one block
+		jptm.chunks[0](0)
+		c.Assert(jptm.failure, chk.IsNil)
+		return requests
+	}
+
+	// a file at the threshold is sent with Put Blob, and one over it as a block, even with a bigger block size
+	c.Assert(send(1024, 1024), chk.DeepEquals, []string{"PUT "})
+	c.Assert(send(1025, 1024), chk.DeepEquals, []string{"PUT block"})
+}
+
+func (s *blockBlobSuite) TestParseStagedBlocks(c *chk.C) {
+	info := TransferInfo{JobID: common.NewJobID(), Source: "/data/a.bin"}
+	sender := &blockBlobSenderBase{chunkSize: 100, numChunks: 3, blockNamePrefix: getBlockNamePrefix(info)}
+	sourceSize := int64(250)
+	block := func(index int32, size int64) azblob.Block {
+		return azblob.Block{Name: sender.generateEncodedBlockID(index), Size: size}
+	}
+
+	// blocks staged by an interrupted run of the same transfer are recognized, including a short final block
+	staged := sender.parseStagedBlocks([]azblob.Block{block(0, 100), block(2, 50)}, sourceSize)
+	c.Assert(staged, chk.DeepEquals, map[int32]bool{0: true, 2: true})
+
+	// a block that was staged by some other transfer means we can't trust any of them
+	otherInfo := info
+	otherInfo.Source = "/data/b.bin"
+	other := &blockBlobSenderBase{chunkSize: 100, numChunks: 3, blockNamePrefix: getBlockNamePrefix(otherInfo)}
+	foreign := azblob.Block{Name: other.generateEncodedBlockID(1), Size: 100}
+	c.Assert(sender.parseStagedBlocks([]azblob.Block{block(0, 100), foreign}, sourceSize), chk.IsNil)
+
+	// as does a block that was staged with a different chunk size
+	c.Assert(sender.parseStagedBlocks([]azblob.Block{block(0, 100), block(1, 80)}, sourceSize), chk.IsNil)
+
+	// or a block index that can't be part of this transfer
+	c.Assert(sender.parseStagedBlocks([]azblob.Block{block(3, 100)}, sourceSize), chk.IsNil)
+}
+
+func (s *blockBlobSuite) TestBlockIDsAreDeterministic(c *chk.C) {
+	jobID := common.NewJobID()
+	newSender := func(source string) *blockBlobSenderBase {
+		return &blockBlobSenderBase{blockNamePrefix: getBlockNamePrefix(TransferInfo{JobID: jobID, Source: source})}
+	}
+
+	// two runs of the same transfer produce the same IDs, even if the source SAS was refreshed in between
+	firstRun := newSender("https://account.blob.core.windows.net/c/blob?sig=first")
+	secondRun := newSender("https://account.blob.core.windows.net/c/blob?sig=second")
+	for i := int32(0); i < 3; i++ {
+		c.Assert(secondRun.generateEncodedBlockID(i), chk.Equals, firstRun.generateEncodedBlockID(i))
+	}
+
+	// within one transfer, IDs are unique per chunk and all the same length, as the service requires
+	seen := make(map[string]bool)
+	for _, i := range []int32{0, 1, 9, 10, 99999} {
+		id := firstRun.generateEncodedBlockID(i)
+		c.Assert(seen[id], chk.Equals, false)
+		c.Assert(len(id), chk.Equals, len(firstRun.generateEncodedBlockID(0)))
+		seen[id] = true
+
+		decoded, err := base64.StdEncoding.DecodeString(id)
+		c.Assert(err, chk.IsNil)
+		c.Assert(len(decoded) <= 64, chk.Equals, true)
+	}
+
+	// different sources, or different jobs, don't share IDs
+	c.Assert(newSender("/data/other").generateEncodedBlockID(0), chk.Not(chk.Equals), firstRun.generateEncodedBlockID(0))
+	otherJob := &blockBlobSenderBase{blockNamePrefix: getBlockNamePrefix(TransferInfo{JobID: common.NewJobID(), Source: "https://account.blob.core.windows.net/c/blob"})}
+	c.Assert(otherJob.generateEncodedBlockID(0), chk.Not(chk.Equals), firstRun.generateEncodedBlockID(0))
+}
+
+// newStagingBlockServer keeps the blocks it is sent uncommitted, and lists them when asked
+func newStagingBlockServer() *testBlobServer {
+	var server *testBlobServer
+	server = newTestBlobServer(func(w http.ResponseWriter, r testRequest) {
+		if r.method != http.MethodGet || r.query.Get("comp") != "blocklist" {
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		list := &strings.Builder{}
+		list.WriteString(`<?xml version="1.0" encoding="utf-8"?><BlockList><CommittedBlocks /><UncommittedBlocks>`)
+		for _, staged := range stagedBlockRequests(server) {
+			fmt.Fprintf(list, "<Block><Name>%s</Name><Size>%d</Size></Block>", staged.query.Get("blockid"), len(staged.body))
+		}
+		list.WriteString("</UncommittedBlocks></BlockList>")
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(list.String()))
+	})
+	return server
+}
+
+// stagedBlockRequests are the Put Block requests that a server has received
+func stagedBlockRequests(server *testBlobServer) []testRequest {
+	staged := []testRequest{}
+	for _, r := range server.received() {
+		if r.method == http.MethodPut && r.query.Get("comp") == "block" {
+			staged = append(staged, r)
+		}
+	}
+	return staged
+}
+
+// newThreeBlockTransfer is the upload of a local file that is a little under three 1 KiB blocks long
+func newThreeBlockTransfer(c *chk.C) TransferInfo {
+	content := make([]byte, 3*1024-100)
+	srcPath := filepath.Join(c.MkDir(), "file.bin")
+	c.Assert(ioutil.WriteFile(srcPath, content, 0644), chk.IsNil)
+	return TransferInfo{JobID: common.NewJobID(), Source: srcPath, SourceSize: int64(len(content))}
+}
+
+// scheduleThreeBlockUpload schedules the chunks of a newThreeBlockTransfer, leaving the test to run them
+func scheduleThreeBlockUpload(c *chk.C, server *testBlobServer, jptm *testTransferMgr) *blockBlobUploader {
+	uploader, err := newTestBlockBlobUploader(jptm, server.URL+"/container/file.bin")
+	c.Assert(err, chk.IsNil)
+	uploader.blockNamePrefix = getBlockNamePrefix(jptm.info)
+	uploader.chunkSize = 1024
+	uploader.numChunks = 3
+	uploader.blockIDs = make([]string, 3)
+
+	srcPath := jptm.info.Source
+	srcFile, err := os.Open(srcPath)
+	c.Assert(err, chk.IsNil)
+	defer srcFile.Close()
+	factory := func() (common.CloseableReaderAt, error) { return os.Open(srcPath) }
+	scheduleSendChunks(jptm, srcPath, srcFile, jptm.info.SourceSize, uploader, factory, localSourceInfoProvider{})
+	c.Assert(jptm.chunks, chk.HasLen, 3)
+	return uploader
+}
+
+func (s *blockBlobSuite) TestResumedUploadSkipsStagedBlocks(c *chk.C) {
+	server := newStagingBlockServer()
+	defer server.Close()
+	info := newThreeBlockTransfer(c)
+
+	// the first run is interrupted after staging two blocks. Being a fresh upload, it doesn't look for earlier blocks
+	jptm := &testTransferMgr{info: info}
+	scheduleThreeBlockUpload(c, server, jptm)
+	jptm.chunks[0](0)
+	jptm.chunks[1](0)
+	c.Assert(jptm.failure, chk.IsNil)
+	c.Assert(server.methods(), chk.DeepEquals, []string{http.MethodPut, http.MethodPut})
+
+	// the resumed run finds them, and sends only the block that is missing
+	jptm = &testTransferMgr{info: info, resumed: true}
+	scheduleThreeBlockUpload(c, server, jptm)
+	jptm.runChunks()
+	c.Assert(jptm.failure, chk.IsNil)
+	c.Assert(server.methods(), chk.DeepEquals, []string{http.MethodPut, http.MethodPut, http.MethodGet, http.MethodPut})
+	staged := stagedBlockRequests(server)
+	c.Assert(staged, chk.HasLen, 3)
+	c.Assert(staged[2].body, chk.HasLen, 1024-100)
+}
+
+// countingSlicePool tracks how many slices are rented and not yet returned
+type countingSlicePool struct {
+	common.ByteSlicePooler
+	outstanding int
+}
+
+func (p *countingSlicePool) RentSlice(desiredLength int64) []byte {
+	p.outstanding++
+	return p.ByteSlicePooler.RentSlice(desiredLength)
+}
+
+func (p *countingSlicePool) ReturnSlice(slice []byte) {
+	p.outstanding--
+	p.ByteSlicePooler.ReturnSlice(slice)
+}
+
+// nonPoolingSlicePool allocates a new slice every time, like a plain make() would
+type nonPoolingSlicePool struct{}
+
+func (nonPoolingSlicePool) RentSlice(desiredLength int64) []byte { return make([]byte, desiredLength) }
+func (nonPoolingSlicePool) ReturnSlice([]byte)                   {}
+func (nonPoolingSlicePool) Prune()                               {}
+
+func compressibleTestData() []byte {
+	text := &strings.Builder{}
+	for i := 0; i < 10000; i++ {
+		fmt.Fprintf(text, "line %d of some compressible text\n", i*7919%10007)
+	}
+	return []byte(text.String())
+}
+
+func (s *blockBlobSuite) TestStageCompressedBlocks(c *chk.C) {
+	original := compressibleTestData()
+	blockSize := int64(1024)
+	pool := &countingSlicePool{ByteSlicePooler: common.NewMultiSizeSlicePool(blockSize)}
+
+	staged := &bytes.Buffer{}
+	expectedIndex := int32(0)
+	compressedLength, err := stageCompressedBlocks(bytes.NewReader(original), blockSize, pool, func(blockIndex int32, block []byte) error {
+		c.Assert(blockIndex, chk.Equals, expectedIndex)
+		c.Assert(int64(len(block)) <= blockSize, chk.Equals, true)
+		expectedIndex++
+		staged.Write(block)
+		return nil
+	})
+	c.Assert(err, chk.IsNil)
+	c.Assert(pool.outstanding, chk.Equals, 0)
+	c.Assert(compressedLength, chk.Equals, int64(staged.Len()))
+	c.Assert(compressedLength < int64(len(original)), chk.Equals, true)
+	c.Assert(expectedIndex > 1, chk.Equals, true)
+
+	// what was staged must decompress back to the original
+	gzipReader, err := gzip.NewReader(staged)
+	c.Assert(err, chk.IsNil)
+	decompressed, err := ioutil.ReadAll(gzipReader)
+	c.Assert(err, chk.IsNil)
+	c.Assert(bytes.Equal(decompressed, original), chk.Equals, true)
+
+	// a failure to stage stops the upload
+	stageErr := errors.New("staging failed")
+	_, err = stageCompressedBlocks(bytes.NewReader(original), blockSize, pool, func(int32, []byte) error { return stageErr })
+	c.Assert(err, chk.Equals, stageErr)
+	c.Assert(pool.outstanding, chk.Equals, 0) // the buffer goes back to the pool on failure too
+}
+
+func (s *blockBlobSuite) benchmarkStageCompressedBlocks(c *chk.C, pool common.ByteSlicePooler) {
+	original := compressibleTestData()
+	blockSize := int64(64 * 1024)
+	c.ResetTimer()
+	for i := 0; i < c.N; i++ {
+		_, err := stageCompressedBlocks(bytes.NewReader(original), blockSize, pool, func(int32, []byte) error { return nil })
+		if err != nil {
+			c.Fatal(err)
+		}
+	}
+}
+
+// Run with -check.b -check.bmem to compare allocations with and without the pool
+func (s *blockBlobSuite) BenchmarkStageCompressedBlocksPooled(c *chk.C) {
+	s.benchmarkStageCompressedBlocks(c, common.NewMultiSizeSlicePool(64*1024))
+}
+
+func (s *blockBlobSuite) BenchmarkStageCompressedBlocksUnpooled(c *chk.C) {
+	s.benchmarkStageCompressedBlocks(c, nonPoolingSlicePool{})
+}
+
+func (s *blockBlobSuite) TestCheckDestinationContentDetectsTruncation(c *chk.C) {
+	sentMD5 := []byte("0123456789abcdef")
+	u := &blockBlobUploader{blockBlobSenderBase: blockBlobSenderBase{headersToApply: azblob.BlobHTTPHeaders{ContentMD5: sentMD5}}}
+	u.destContentMD5 = sentMD5
+
+	c.Assert(checkDestinationContent(u, 1024, 1024), chk.IsNil)
+
+	// a truncated upload is caught by the length check
+	err := checkDestinationContent(u, 512, 1024)
+	c.Assert(err, chk.NotNil)
+	c.Assert(strings.Contains(err.Error(), "does not match source length"), chk.Equals, true)
+	c.Assert(strings.Contains(err.Error(), "512"), chk.Equals, true)
+
+	// and an upload of the right length, but with the wrong content, by the MD5 check
+	u.destContentMD5 = []byte("fedcba9876543210")
+	err = checkDestinationContent(u, 1024, 1024)
+	c.Assert(err, chk.NotNil)
+	c.Assert(strings.Contains(err.Error(), "Content-MD5"), chk.Equals, true)
+
+	// when no MD5 was sent, there's nothing to compare it with
+	u.headersToApply.ContentMD5 = nil
+	u.destContentMD5 = nil
+	c.Assert(checkDestinationContent(u, 1024, 1024), chk.IsNil)
+}
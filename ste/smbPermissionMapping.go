@@ -0,0 +1,109 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Azure/azure-storage-file-go/azfile"
+)
+
+// POSIX has no SIDs, so owners and groups are given the SIDs that Samba gives to Unix users and groups it can't map to
+// Windows accounts. The rest of the world is Everyone.
+const (
+	unixUserSIDPrefix  = "S-1-22-1-"
+	unixGroupSIDPrefix = "S-1-22-2-"
+	everyoneSID        = "S-1-1-0"
+)
+
+// smbPermissionsFromPOSIX gives the SDDL of the SMB security descriptor closest to the POSIX permissions of a file or
+// folder with the given mode, owner and group. Each of the owner, group and other permission classes becomes an entry
+// granting the matching rights, which the children of a folder inherit. inexact says why, if at all, the descriptor
+// can't mean even roughly what the mode does.
+func smbPermissionsFromPOSIX(mode os.FileMode, uid, gid uint32) (sddl string, inexact []string) {
+	owner, group, other := uint32(mode.Perm()>>6)&7, uint32(mode.Perm()>>3)&7, uint32(mode.Perm())&7
+	ownerSID := fmt.Sprintf("%s%d", unixUserSIDPrefix, uid)
+	groupSID := fmt.Sprintf("%s%d", unixGroupSIDPrefix, gid)
+
+	// on a folder, the entries are inherited by the files and folders in it, as POSIX permissions would be by default
+	aceFlags := ""
+	if mode.IsDir() {
+		aceFlags = "OICI"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("O:" + ownerSID + "G:" + groupSID + "D:P")
+	for _, ace := range []struct {
+		bits uint32
+		sid  string
+	}{{owner, ownerSID}, {group, groupSID}, {other, everyoneSID}} {
+		if ace.bits != 0 {
+			sb.WriteString("(A;" + aceFlags + ";" + smbRightsFromPOSIX(ace.bits) + ";;;" + ace.sid + ")")
+		}
+	}
+
+	// in POSIX, only the most specific class that applies counts, but SMB grants the sum of all the entries that apply
+	if (group|other)&^owner != 0 {
+		inexact = append(inexact, fmt.Sprintf("the owner is denied rights (%s) that the group or others have, "+
+			"but SMB permissions can't deny the owner what they grant to everyone", mode.Perm()))
+	}
+	if other&^group != 0 {
+		inexact = append(inexact, fmt.Sprintf("the group is denied rights (%s) that others have, "+
+			"but SMB permissions can't deny the group what they grant to everyone", mode.Perm()))
+	}
+	if special := mode & (os.ModeSetuid | os.ModeSetgid | os.ModeSticky); special != 0 {
+		inexact = append(inexact, "the setuid, setgid and sticky bits have no SMB equivalent, so they are dropped")
+	}
+
+	return sb.String(), inexact
+}
+
+// smbRightsFromPOSIX gives the SDDL rights string for one class of rwx permission bits. Even rwx is only read, write
+// and execute: full control (FA) would also let them change the permissions and the owner, which POSIX doesn't
+func smbRightsFromPOSIX(bits uint32) string {
+	rights := ""
+	if bits&4 != 0 {
+		rights += "FR"
+	}
+	if bits&2 != 0 {
+		rights += "FW"
+	}
+	if bits&1 != 0 {
+		rights += "FX"
+	}
+	return rights
+}
+
+// smbAttributesFromPOSIX gives the SMB attributes closest to the mode and name of a file or folder.
+// A file its owner can't write to is read-only, and dot files and folders are hidden, as they are in a Unix listing.
+func smbAttributesFromPOSIX(mode os.FileMode, name string) azfile.FileAttributeFlags {
+	attribs := azfile.FileAttributeNone
+	// on a Windows folder, the read-only attribute doesn't stop anything being written, so it isn't used for folders
+	if !mode.IsDir() && mode.Perm()&0200 == 0 {
+		attribs = attribs.Add(azfile.FileAttributeReadonly)
+	}
+	if strings.HasPrefix(name, ".") && name != "." && name != ".." {
+		attribs = attribs.Add(azfile.FileAttributeHidden)
+	}
+	return attribs
+}
@@ -42,6 +42,10 @@ func (f localFileSourceInfoProvider) Properties() (*SrcProperties, error) {
 	// this file
 
 	headers, metadata, blobTags, _ := f.jptm.ResourceDstData(nil) // we don't have a known MIME type yet, so pass nil for the sniffed content of thefile
+	if f.jptm.LastModifiedInMetadata() {
+		// Blob Storage keeps no modification time of its own for the file, so carry it in the metadata
+		metadata = metadata.WithLastModifiedTime(f.jptm.LastModifiedTime())
+	}
 
 	return &SrcProperties{
 		SrcHTTPHeaders: common.ResourceHTTPHeaders{
@@ -66,7 +70,13 @@ func (f localFileSourceInfoProvider) OpenSourceFile() (common.CloseableReaderAt,
 	if custom, ok := interface{}(f).(ICustomLocalOpener); ok {
 		return custom.Open(path)
 	}
-	return os.Open(path)
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	// uploads read each file once, in order, so the kernel can read ahead more than it otherwise would
+	common.AdviseSequentialRead(file)
+	return file, nil
 }
 
 func (f localFileSourceInfoProvider) GetFreshFileLastModifiedTime() (time.Time, error) {
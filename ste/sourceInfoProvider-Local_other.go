@@ -0,0 +1,81 @@
+//go:build !windows
+// +build !windows
+
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-file-go/azfile"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// This file os-triggers the ISMBPropertyBearingSourceInfoProvider interface on a local SIP,
+// by mapping POSIX permissions to the closest SMB permissions and attributes.
+
+func (f localFileSourceInfoProvider) GetSDDL() (string, error) {
+	info, err := common.OSStat(f.jptm.Info().Source)
+	if err != nil {
+		return "", err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", errors.New("the owner and group of " + f.jptm.Info().Source + " are unknown")
+	}
+
+	sddlString, inexact := smbPermissionsFromPOSIX(info.Mode(), stat.Uid, stat.Gid)
+	f.jptm.LogAtLevelForCurrentTransfer(pipeline.LogInfo, fmt.Sprintf("SMB permissions %s are an approximation of the POSIX permissions %s", sddlString, info.Mode().Perm()))
+	for _, reason := range inexact {
+		f.jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning, "SMB permissions don't exactly match the POSIX permissions: "+reason)
+	}
+	return sddlString, nil
+}
+
+func (f localFileSourceInfoProvider) GetSMBProperties() (TypedSMBPropertyHolder, error) {
+	info, err := common.OSStat(f.jptm.Info().Source)
+
+	return posixSMBProperties{info}, err
+}
+
+type posixSMBProperties struct {
+	os.FileInfo
+}
+
+// FileCreationTime is the last modification time, because POSIX doesn't keep a creation time
+func (p posixSMBProperties) FileCreationTime() time.Time {
+	return p.ModTime()
+}
+
+func (p posixSMBProperties) FileLastWriteTime() time.Time {
+	return p.ModTime()
+}
+
+func (p posixSMBProperties) FileAttributes() azfile.FileAttributeFlags {
+	return smbAttributesFromPOSIX(p.Mode(), p.Name())
+}
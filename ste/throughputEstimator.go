@@ -0,0 +1,87 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"sync"
+	"time"
+)
+
+// throughputEstimator tracks a smoothed (exponentially weighted) rate of bytes transferred.
+// We use that, rather than the all-time average, for ETAs, so that they respond reasonably quickly
+// when the speed changes, without jumping around too much from one sample to the next.
+type throughputEstimator struct {
+	mu         sync.Mutex
+	lastBytes  uint64
+	lastSample time.Time
+	smoothed   float64 // bytes per second
+	hasRate    bool
+}
+
+// how much weight each new sample carries, for a sample taken one second after the last
+const throughputSmoothingPerSecond = 0.2
+
+// update records the (cumulative) number of bytes transferred so far, and returns the smoothed rate in bytes per second
+func (t *throughputEstimator) update(bytesTransferred uint64, now time.Time) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.lastSample.IsZero() || bytesTransferred < t.lastBytes {
+		// first sample, or the count was reset (e.g. by a resume), so there's nothing to compare with yet
+		t.lastBytes = bytesTransferred
+		t.lastSample = now
+		return t.smoothed
+	}
+
+	elapsed := now.Sub(t.lastSample).Seconds()
+	if elapsed <= 0 {
+		return t.smoothed
+	}
+	rate := float64(bytesTransferred-t.lastBytes) / elapsed
+
+	if !t.hasRate {
+		t.smoothed = rate
+		t.hasRate = true
+	} else {
+		// weight the new sample by how long it covers, so that polling more often doesn't make the estimate jumpier
+		weight := throughputSmoothingPerSecond * elapsed
+		if weight > 1 {
+			weight = 1
+		}
+		t.smoothed = weight*rate + (1-weight)*t.smoothed
+	}
+
+	t.lastBytes = bytesTransferred
+	t.lastSample = now
+	return t.smoothed
+}
+
+// estimateSecondsRemaining returns how long the remaining bytes will take at the given rate,
+// or -1 if that can't be known because nothing is moving
+func estimateSecondsRemaining(bytesRemaining uint64, bytesPerSecond float64) float64 {
+	if bytesRemaining == 0 {
+		return 0
+	}
+	if bytesPerSecond <= 0 {
+		return -1
+	}
+	return float64(bytesRemaining) / bytesPerSecond
+}
@@ -21,8 +21,10 @@
 package ste
 
 import (
+	"bytes"
 	"context"
 	"crypto/md5"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"github.com/Azure/azure-storage-blob-go/azblob"
@@ -183,6 +185,29 @@ func anyToRemote(jptm IJobPartTransferMgr, p pipeline.Pipeline, pacer pacer, sen
 	}
 }
 
+// snapshotDestination snapshots the existing destination of a transfer that is about to overwrite it. If that fails,
+// the transfer is failed, since going ahead would lose the content the user asked us to keep
+func snapshotDestination(jptm IJobPartTransferMgr, info TransferInfo, s sender) (ok bool) {
+	snapshotter, isSnapshotter := s.(destinationSnapshotter)
+	if !isSnapshotter {
+		jptm.LogSendError(info.Source, info.Destination, "The destination cannot be snapshotted, so will not be overwritten", 0)
+		jptm.SetStatus(common.ETransferStatus.Failed())
+		jptm.ReportTransferDone()
+		return false
+	}
+
+	snapshot, err := snapshotter.SnapshotDestination()
+	if err != nil {
+		jptm.LogSendError(info.Source, info.Destination, "Could not snapshot the destination before overwriting it. "+err.Error(), 0)
+		jptm.SetStatus(common.ETransferStatus.Failed())
+		jptm.ReportTransferDone()
+		return false
+	}
+
+	jptm.LogAtLevelForCurrentTransfer(pipeline.LogInfo, fmt.Sprintf("Created snapshot %s of the destination before overwriting it", snapshot))
+	return true
+}
+
 // anyToRemote_file handles all kinds of sender operations for files - both uploads from local files, and S2S copies
 func anyToRemote_file(jptm IJobPartTransferMgr, info TransferInfo, p pipeline.Pipeline, pacer pacer, senderFactory senderFactory, sipf sourceInfoProviderFactory) {
 
@@ -230,10 +255,10 @@ func anyToRemote_file(jptm IJobPartTransferMgr, info TransferInfo, p pipeline.Pi
 	}
 
 	// step 3: check overwrite option
-	// if the force Write flags is set to false or prompt
+	// if the force Write flags is set to false or prompt, or the destination must be snapshotted before it's overwritten,
 	// then check the file exists at the remote location
 	// if it does, react accordingly
-	if jptm.GetOverwriteOption() != common.EOverwriteOption.True() {
+	if jptm.GetOverwriteOption() != common.EOverwriteOption.True() || jptm.ShouldSnapshotBeforeOverwrite() {
 		exists, dstLmt, existenceErr := s.RemoteFileExists()
 		if existenceErr != nil {
 			jptm.LogSendError(info.Source, info.Destination, "Could not check destination file existence. "+existenceErr.Error(), 0)
@@ -245,7 +270,9 @@ func anyToRemote_file(jptm IJobPartTransferMgr, info TransferInfo, p pipeline.Pi
 			shouldOverwrite := false
 
 			// if necessary, prompt to confirm user's intent
-			if jptm.GetOverwriteOption() == common.EOverwriteOption.Prompt() {
+			if jptm.GetOverwriteOption() == common.EOverwriteOption.True() {
+				shouldOverwrite = true
+			} else if jptm.GetOverwriteOption() == common.EOverwriteOption.Prompt() {
 				// remove the SAS before prompting the user
 				parsed, _ := url.Parse(info.Destination)
 				parsed.RawQuery = ""
@@ -264,17 +291,25 @@ func anyToRemote_file(jptm IJobPartTransferMgr, info TransferInfo, p pipeline.Pi
 				jptm.ReportTransferDone()
 				return
 			}
+
+			if jptm.ShouldSnapshotBeforeOverwrite() && !snapshotDestination(jptm, info, s) {
+				return
+			}
 		}
 	}
 
 	// step 4: Open the local Source File (if any)
+	// Small files are opened later, by the small-file pool, so that this routine can move on to the next file straight away
 	common.GetLifecycleMgr().E2EAwaitAllowOpenFiles()
 	jptm.LogChunkStatus(pseudoId, common.EWaitReason.OpenLocalSource())
+	isSmallFile := isSmallFileUpload(s, srcInfoProvider, srcSize, jptm.SmallFileThreshold())
 	var sourceFileFactory func() (common.CloseableReaderAt, error)
 	srcFile := (common.CloseableReaderAt)(nil)
 	if srcInfoProvider.IsLocal() {
 		sourceFileFactory = srcInfoProvider.(ILocalSourceInfoProvider).OpenSourceFile // all local providers must implement this interface
-		srcFile, err = sourceFileFactory()
+	}
+	if srcInfoProvider.IsLocal() && !isSmallFile {
+		srcFile, err = openCountedSourceFile(jptm, sourceFileFactory)
 		if err != nil {
 			suffix := ""
 			if strings.Contains(err.Error(), "Access is denied") && runtime.GOOS == "windows" {
@@ -336,17 +371,149 @@ func anyToRemote_file(jptm IJobPartTransferMgr, info TransferInfo, p pipeline.Pi
 
 	// step 5b: tell jptm what to expect, and how to clean up at the end
 	jptm.SetNumberOfChunks(numChunks)
-	jptm.SetActionAfterLastChunk(func() { epilogueWithCleanupSendToRemote(jptm, s, srcInfoProvider) })
+	jptm.SetActionAfterLastChunk(actionAfterLastChunk(jptm, s, numChunks, func() { epilogueWithCleanupSendToRemote(jptm, s, srcInfoProvider) }))
 
 	// stop tracking pseudo id (since real chunk id's will be tracked from here on)
 	jptm.LogChunkStatus(pseudoId, common.EWaitReason.ChunkDone())
 
 	// Step 6: Go through the file and schedule chunk messages to send each chunk
-	scheduleSendChunks(jptm, info.Source, srcFile, srcSize, s, sourceFileFactory, srcInfoProvider)
+	if isSmallFile {
+		scheduleSmallFileSend(jptm, info.Source, srcSize, s.(uploader), sourceFileFactory)
+	} else if su, ok := s.(streamingUploader); ok && srcInfoProvider.IsLocal() {
+		scheduleStreamingSend(jptm, info.Source, srcFile, srcSize, su, sourceFileFactory)
+	} else {
+		scheduleSendChunks(jptm, info.Source, srcFile, srcSize, s, sourceFileFactory, srcInfoProvider)
+	}
+}
+
+// actionAfterLastChunk says how the epilogue should be run, once the last chunk is done.
+// Committing a block list is a round trip of its own, so the worker that sent the last block hands that over to the commit pool
+// rather than waiting for it. Single-chunk blobs have nothing to commit, so their (quick) epilogue is still run straight away.
+func actionAfterLastChunk(jptm IJobPartTransferMgr, s sender, numChunks uint32, epilogue func()) func() {
+	if _, commitsBlockList := s.(blockListCommitter); commitsBlockList && numChunks > 1 {
+		return func() { jptm.ScheduleCommit(epilogue) }
+	}
+	return epilogue
+}
+
+// scheduleStreamingSend schedules the single chunk func that a streamingUploader uses to send the whole file.
+// Unlike scheduleSendChunks, nothing is prefetched here; the chunk func opens and reads the file itself, as it sends.
+func scheduleStreamingSend(jptm IJobPartTransferMgr, srcPath string, srcFile common.CloseableReaderAt, srcSize int64, s streamingUploader, sourceFileFactory common.ChunkReaderSourceFactory) {
+	// read just enough of the file to infer its content type
+	leadingBytes := make([]byte, common.Iffint64(srcSize < 512, srcSize, 512))
+	n, _ := srcFile.ReadAt(leadingBytes, 0)
+	if s.Prologue(common.PrologueState{LeadingBytes: leadingBytes[:n]}) {
+		jptm.SetDestinationIsModified()
+	}
+
+	id := common.NewChunkID(srcPath, 0, srcSize)
+	jptm.LogChunkStatus(id, common.EWaitReason.WorkerGR())
+	jptm.ScheduleChunks(s.GenerateStreamingUploadFunc(id, sourceFileFactory, srcSize))
+}
+
+// isSmallFileUpload says whether a file is small enough to be read and sent in one go by the small-file pool.
+// That's only done for local files that are sent as a single chunk, by an uploader that can send from a prefetched buffer.
+func isSmallFileUpload(s sender, sip ISourceInfoProvider, srcSize int64, threshold int64) bool {
+	if _, isUploader := s.(uploader); !isUploader {
+		return false
+	}
+	if _, isStreaming := s.(streamingUploader); isStreaming {
+		return false
+	}
+	return sip.IsLocal() && s.NumChunks() == 1 && threshold > 0 && srcSize <= threshold
+}
+
+// scheduleSmallFileSend hands a small local file over to the small-file pool. Nothing is read here; the pool opens the file,
+// reads all of it into a pooled buffer, and sends it, so that transfer initiation is never held up by file IO for small files.
+func scheduleSmallFileSend(jptm IJobPartTransferMgr, srcPath string, srcSize int64, u uploader, sourceFileFactory common.ChunkReaderSourceFactory) {
+	id := common.NewChunkID(srcPath, 0, srcSize)
+	jptm.LogChunkStatus(id, common.EWaitReason.WorkerGR())
+	jptm.ScheduleSmallFileSend(func(workerId int) {
+		sendSmallFile(jptm, id, srcSize, u, sourceFileFactory, workerId)
+	})
+}
+
+// sendSmallFile does, for a single-chunk file, everything that scheduleSendChunks and the chunk func it schedules would do.
+// As there, the prologue always runs, and the chunk is always reported done, even if the file could not be read.
+func sendSmallFile(jptm IJobPartTransferMgr, id common.ChunkID, srcSize int64, u uploader, sourceFileFactory common.ChunkReaderSourceFactory, workerId int) {
+	md5Channel := u.Md5Channel()
+	defer close(md5Channel)
+
+	chunkReader, readErr := readSmallFile(jptm, id, srcSize, sourceFileFactory)
+	ps := common.PrologueState{}
+	if readErr == nil {
+		ps = chunkReader.GetPrologueState()
+	}
+	if u.Prologue(ps) {
+		jptm.SetDestinationIsModified()
+	}
+	if readErr != nil {
+		createSendToRemoteChunkFunc(jptm, id, func() { jptm.FailActiveSend("chunk data read", readErr) })(workerId)
+		return
+	}
+
+	// the whole file is in the buffer, so its hash is known before the send starts
+	var md5Hasher hash.Hash = common.NewNullHasher()
+	if jptm.ShouldPutMd5() {
+		md5Hasher = md5.New()
+	}
+	chunkReader.WriteBufferTo(md5Hasher)
+	md5Hash := md5Hasher.Sum(nil)
+	jptm.SetContentMD5(md5Hash)
+	md5Channel <- md5Hash
+
+	u.GenerateUploadFunc(id, 0, chunkReader, true)(workerId)
+}
+
+// readSmallFile opens a small file, and reads all of it into a buffer from the slice pool
+func readSmallFile(jptm IJobPartTransferMgr, id common.ChunkID, srcSize int64, sourceFileFactory common.ChunkReaderSourceFactory) (common.SingleChunkReader, error) {
+	if jptm.WasCanceled() {
+		return nil, jobCancelledLocalPrefetchErr
+	}
+	srcFile, err := openCountedSourceFile(jptm, sourceFileFactory)
+	if err != nil {
+		return nil, err
+	}
+	defer srcFile.Close()
+
+	chunkReader := createPopulatedChunkReader(jptm, sourceFileFactory, id, srcSize, srcFile)
+	if err := chunkReader.BlockingPrefetch(srcFile, false); err != nil {
+		_ = chunkReader.Close()
+		return nil, err
+	}
+	return chunkReader, nil
 }
 
 var jobCancelledLocalPrefetchErr = errors.New("job was cancelled; Pre-fetching stopped")
 
+// openCountedSourceFile opens a local source file once there's room for it within the limit on open files.
+// The room is given back when the file is closed.
+// Re-reads for retries use the factory directly, so that they are never held up by files opened for other transfers
+func openCountedSourceFile(jptm IJobPartTransferMgr, sourceFileFactory common.ChunkReaderSourceFactory) (common.CloseableReaderAt, error) {
+	limiter := jptm.FileCountLimiter()
+	if err := limiter.WaitUntilAdd(jptm.Context(), 1, func() bool { return true }); err != nil {
+		return nil, err
+	}
+	f, err := sourceFileFactory()
+	if err != nil {
+		limiter.Remove(1)
+		return nil, err
+	}
+	return &countedSourceFile{CloseableReaderAt: f, limiter: limiter}, nil
+}
+
+// countedSourceFile is an open source file that counts against the limit on open files until it is closed
+type countedSourceFile struct {
+	common.CloseableReaderAt
+	limiter   common.CacheLimiter
+	closeOnce sync.Once
+}
+
+func (f *countedSourceFile) Close() error {
+	f.closeOnce.Do(func() { f.limiter.Remove(1) })
+	return f.CloseableReaderAt.Close()
+}
+
 // Schedule all the send chunks.
 // For upload, we force preload of each chunk to memory, and we wait (block)
 // here if the amount of preloaded data gets excessive. That's OK to do,
@@ -379,6 +546,11 @@ func scheduleSendChunks(jptm IJobPartTransferMgr, srcPath string, srcFile common
 		defer close(md5Channel)
 	}
 
+	scheduleChunk := jptm.ScheduleChunks
+	if _, inOrder := s.(orderedSender); inOrder {
+		scheduleChunk = jptm.ScheduleChunksInOrder
+	}
+
 	chunkIDCount := int32(0)
 	for startIndex := int64(0); startIndex < srcSize || isDummyChunkInEmptyFile(startIndex, srcSize); startIndex += int64(chunkSize) {
 
@@ -450,7 +622,7 @@ func scheduleSendChunks(jptm IJobPartTransferMgr, srcPath string, srcFile common
 		} else {
 			cf = s.(s2sCopier).GenerateCopyFunc(id, chunkIDCount, adjustedChunkSize, isWholeFile)
 		}
-		jptm.ScheduleChunks(cf)
+		scheduleChunk(cf)
 
 		chunkIDCount++
 	}
@@ -461,7 +633,9 @@ func scheduleSendChunks(jptm IJobPartTransferMgr, srcPath string, srcFile common
 	}
 
 	if srcInfoProvider.IsLocal() && safeToUseHash {
-		md5Channel <- md5Hasher.Sum(nil)
+		md5Hash := md5Hasher.Sum(nil)
+		jptm.SetContentMD5(md5Hash)
+		md5Channel <- md5Hash
 	}
 }
 
@@ -545,8 +719,8 @@ func epilogueWithCleanupSendToRemote(jptm IJobPartTransferMgr, s sender, sip ISo
 			if err != nil {
 				wrapped := fmt.Errorf("Could not read destination length. %w", err)
 				jptm.FailActiveSend(common.IffString(isS2SCopier, "S2S ", "Upload ")+"Length check: Get destination length", wrapped)
-			} else if destLength != jptm.Info().SourceSize {
-				jptm.FailActiveSend(common.IffString(isS2SCopier, "S2S ", "Upload ")+"Length check", errors.New("destination length does not match source length"))
+			} else if err := checkDestinationContent(s, destLength, expectedDestinationLength(s, jptm.Info().SourceSize)); err != nil {
+				jptm.FailActiveSend(common.IffString(isS2SCopier, "S2S ", "Upload ")+"Length check", err)
 			}
 		}
 	}
@@ -558,6 +732,38 @@ func epilogueWithCleanupSendToRemote(jptm IJobPartTransferMgr, s sender, sip ISo
 	commonSenderCompletion(jptm, s, info)
 }
 
+// checkDestinationContent compares what was read back from the destination with what was sent. As well as the length,
+// it checks the MD5 hash, if the sender sent one and knows how to check it
+func checkDestinationContent(s sender, destLength int64, expectedLength int64) error {
+	if destLength != expectedLength {
+		return fmt.Errorf("destination length does not match source length. Destination has %d bytes, but %d were expected", destLength, expectedLength)
+	}
+	if v, ok := s.(destinationMD5Verifier); ok {
+		return v.VerifyDestinationMD5()
+	}
+	return nil
+}
+
+// verifyContentMD5 checks the hash stored at the destination, if we sent one
+func verifyContentMD5(sent, stored []byte) error {
+	if len(sent) == 0 {
+		return nil
+	}
+	if !bytes.Equal(sent, stored) {
+		return fmt.Errorf("destination Content-MD5 %s does not match the MD5 of the uploaded data %s",
+			base64.StdEncoding.EncodeToString(stored), base64.StdEncoding.EncodeToString(sent))
+	}
+	return nil
+}
+
+// expectedDestinationLength is the source size, unless the sender changed the data (e.g. compressed it) as it went
+func expectedDestinationLength(s sender, sourceSize int64) int64 {
+	if su, ok := s.(streamingUploader); ok {
+		return su.CompressedLength()
+	}
+	return sourceSize
+}
+
 // commonSenderCompletion is used for both files and folders
 func commonSenderCompletion(jptm IJobPartTransferMgr, s sender, info TransferInfo) {
 
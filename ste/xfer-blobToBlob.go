@@ -0,0 +1,262 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package ste
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// syncServerSideCopyMaxSize is the largest blob that Copy Blob From URL accepts. Larger blobs are copied with the
+// asynchronous Copy Blob operation instead, and we poll the destination until the service has finished the copy
+const syncServerSideCopyMaxSize = 256 * 1024 * 1024
+
+// serverSideCopyPollInterval is how often the destination is checked while an asynchronous copy is pending
+var serverSideCopyPollInterval = 2 * time.Second
+
+// BlobToBlob copies a blob by asking the destination's service to copy it, so the data never passes through AzCopy.
+// Folder properties are sent the usual way.
+func BlobToBlob(jptm IJobPartTransferMgr, p pipeline.Pipeline, pacer pacer) {
+	if jptm.Info().IsFolderPropertiesTransfer() {
+		anyToRemote(jptm, p, pacer, newURLToBlobCopier, newBlobSourceInfoProvider)
+		return
+	}
+
+	if jptm.WasCanceled() {
+		jptm.SetStatus(common.ETransferStatus.Cancelled())
+		jptm.ReportTransferDone()
+		return
+	}
+
+	// schedule the work as a chunk, so it will run on the main goroutine pool, instead of the
+	// smaller "transfer initiation pool", where this code runs.
+	id := common.NewChunkID(jptm.Info().Source, 0, jptm.Info().SourceSize)
+	cf := createChunkFunc(true, jptm, id, func() { doBlobToBlob(jptm, p) })
+	jptm.ScheduleChunks(cf)
+}
+
+func doBlobToBlob(jptm IJobPartTransferMgr, p pipeline.Pipeline) {
+	info := jptm.Info()
+	srcURL, err := url.Parse(info.Source)
+	common.PanicIfErr(err)
+	dstURL, err := url.Parse(info.Destination)
+	common.PanicIfErr(err)
+	destBlobURL := azblob.NewBlobURL(*dstURL, p)
+
+	transferDone := func(status common.TransferStatus, err error) {
+		if status == common.ETransferStatus.Failed() {
+			jptm.LogS2SCopyError(info.Source, info.Destination, err.Error(), 0)
+		} else if status == common.ETransferStatus.Success() {
+			jptm.Log(pipeline.LogInfo, fmt.Sprintf("COPY SUCCESSFUL (server-side): %s", strings.Split(info.Destination, "?")[0]))
+		}
+		jptm.SetStatus(status)
+		jptm.ReportTransferDone()
+	}
+
+	// The service reads the source with the source URL's own credentials, i.e. its SAS. Without one, only the
+	// asynchronous copy, within a single account, can authorize the read, using the credential for the destination.
+	srcHasSAS := srcURL.Query().Get("sig") != ""
+	if !srcHasSAS && !strings.EqualFold(srcURL.Host, dstURL.Host) {
+		transferDone(common.ETransferStatus.Failed(), fmt.Errorf("a server-side copy between accounts needs a SAS token on the source URL"))
+		return
+	}
+
+	if jptm.GetOverwriteOption() == common.EOverwriteOption.False() {
+		exists, _, err := remoteObjectExists(destBlobURL.GetProperties(jptm.Context(), azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{}))
+		if err != nil {
+			transferDone(common.ETransferStatus.Failed(), fmt.Errorf("could not check destination blob existence. %w", err))
+			return
+		}
+		if exists {
+			// logging as Warning, like other skips of existing files
+			jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning, "Blob already exists, so will be skipped")
+			transferDone(common.ETransferStatus.SkippedEntityAlreadyExists(), nil)
+			return
+		}
+	}
+	dstAccessConditions := azblob.BlobAccessConditions{}
+	if jptm.ShouldUploadIfNoneMatch() {
+		dstAccessConditions.ModifiedAccessConditions.IfNoneMatch = azblob.ETagAny
+	}
+
+	// By default the service gives the copy the source's headers and metadata. Metadata can be replaced as part of
+	// the copy, but headers can only be set once it's done.
+	var metadata azblob.Metadata
+	var headers *azblob.BlobHTTPHeaders
+	if jptm.ShouldReplaceProperties() {
+		metadata, headers, err = replacementProperties(jptm, azblob.NewBlobURL(*srcURL, jptm.SourceProviderPipeline()))
+		if err != nil {
+			transferDone(common.ETransferStatus.Failed(), err)
+			return
+		}
+	}
+	copied := func() {
+		if headers != nil {
+			if _, err := destBlobURL.SetHTTPHeaders(jptm.Context(), *headers, azblob.BlobAccessConditions{}); err != nil {
+				transferDone(common.ETransferStatus.Failed(), fmt.Errorf("the blob was copied, but its headers could not be replaced. %w", err))
+				return
+			}
+		}
+		transferDone(common.ETransferStatus.Success(), nil)
+	}
+
+	// Copy Blob From URL only writes block blobs, and needs the source to be readable by URL
+	if srcHasSAS && info.SrcBlobType == azblob.BlobBlockBlob && info.SourceSize <= syncServerSideCopyMaxSize {
+		_, err = destBlobURL.ToBlockBlobURL().CopyFromURL(jptm.Context(), *srcURL, metadata, azblob.ModifiedAccessConditions{}, dstAccessConditions, nil, azblob.AccessTierNone, nil)
+		if skipIfDestinationCreatedMeanwhile(jptm, err) {
+			jptm.ReportTransferDone()
+		} else if err != nil {
+			transferDone(common.ETransferStatus.Failed(), err)
+		} else {
+			copied()
+		}
+		return
+	}
+
+	startResp, err := destBlobURL.StartCopyFromURL(jptm.Context(), *srcURL, metadata, azblob.ModifiedAccessConditions{}, dstAccessConditions, azblob.AccessTierNone, nil)
+	if skipIfDestinationCreatedMeanwhile(jptm, err) {
+		jptm.ReportTransferDone()
+		return
+	} else if err != nil {
+		transferDone(common.ETransferStatus.Failed(), err)
+		return
+	}
+
+	awaitServerSideCopy(jptm, destBlobURL, startResp.CopyID(), startResp.CopyStatus(), func(status common.TransferStatus, err error) {
+		if jptm.WasCanceled() {
+			// best effort, so that the service doesn't carry on with a copy that the user has cancelled
+			abortContext, cancelFn := context.WithTimeout(context.WithValue(context.Background(), ServiceAPIVersionOverride, DefaultServiceApiVersion), 30*time.Second)
+			defer cancelFn()
+			_, _ = destBlobURL.AbortCopyFromURL(abortContext, startResp.CopyID(), azblob.LeaseAccessConditions{})
+			transferDone(common.ETransferStatus.Cancelled(), nil)
+			return
+		}
+		if err != nil {
+			transferDone(common.ETransferStatus.Failed(), err)
+			return
+		}
+		if status != common.ETransferStatus.Success() {
+			transferDone(status, nil)
+			return
+		}
+		copied()
+	})
+}
+
+// replacementProperties works out the properties that a server-side copy should end up with, when the user has
+// asked for them to be replaced. The metadata the user gave replaces the source's. Each header the user gave
+// replaces the source's, but since headers can only be set all at once, the source is read for the others
+func replacementProperties(jptm IJobPartTransferMgr, srcBlobURL azblob.BlobURL) (azblob.Metadata, *azblob.BlobHTTPHeaders, error) {
+	userHeaders, userMetadata, _, _ := jptm.ResourceDstData(nil)
+
+	srcProps, err := srcBlobURL.GetProperties(jptm.Context(), azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read the source's properties, to replace them. %w", err)
+	}
+	headers := srcProps.NewHTTPHeaders()
+	for _, h := range []struct {
+		userValue string
+		header    *string
+	}{
+		{userHeaders.ContentType, &headers.ContentType},
+		{userHeaders.ContentEncoding, &headers.ContentEncoding},
+		{userHeaders.ContentLanguage, &headers.ContentLanguage},
+		{userHeaders.ContentDisposition, &headers.ContentDisposition},
+		{userHeaders.CacheControl, &headers.CacheControl},
+	} {
+		if h.userValue != "" {
+			*h.header = h.userValue
+		}
+	}
+
+	var metadata azblob.Metadata
+	if len(userMetadata) > 0 {
+		metadata = userMetadata.ToAzBlobMetadata()
+	}
+	return metadata, &headers, nil
+}
+
+// awaitServerSideCopy checks the destination of an asynchronous copy until the service says the copy is no longer
+// pending, or the transfer is cancelled. Then it calls done with the transfer status that matches how the copy ended.
+// Each check is scheduled as a chunk of its own, once the poll interval has passed, so that no worker is tied up
+// while the service copies the data.
+func awaitServerSideCopy(jptm IJobPartTransferMgr, destBlobURL azblob.BlobURL, copyID string, copyStatus azblob.CopyStatusType,
+	done func(status common.TransferStatus, err error)) {
+	if copyStatus != azblob.CopyStatusPending {
+		done(common.ETransferStatus.Success(), nil)
+		return
+	}
+
+	var check chunkFunc
+	scheduleCheck := func() {
+		go func() {
+			select {
+			case <-jptm.Context().Done():
+				done(common.ETransferStatus.Cancelled(), nil)
+			case <-time.After(serverSideCopyPollInterval):
+				jptm.ScheduleChunksInOrder(check)
+			}
+		}()
+	}
+	check = func(int) {
+		jptm.OccupyAConnection()
+		pending, status, err := checkServerSideCopy(jptm, destBlobURL, copyID)
+		jptm.ReleaseAConnection()
+		if pending {
+			scheduleCheck()
+			return
+		}
+		done(status, err)
+	}
+	scheduleCheck()
+}
+
+// checkServerSideCopy checks once on the progress of an asynchronous copy. If the copy is no longer pending, it
+// returns the transfer status that matches how the copy ended.
+func checkServerSideCopy(jptm IJobPartTransferMgr, destBlobURL azblob.BlobURL, copyID string) (pending bool, status common.TransferStatus, err error) {
+	props, err := destBlobURL.GetProperties(jptm.Context(), azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		if jptm.WasCanceled() {
+			return false, common.ETransferStatus.Cancelled(), nil
+		}
+		return false, common.ETransferStatus.Failed(), fmt.Errorf("could not check the progress of the server-side copy. %w", err)
+	}
+	if props.CopyID() != copyID {
+		return false, common.ETransferStatus.Failed(), fmt.Errorf("the server-side copy was replaced by another copy to the same blob")
+	}
+	copyStatus := props.CopyStatus()
+	jptm.Log(pipeline.LogDebug, fmt.Sprintf("Server-side copy %s is %s, %s bytes copied", copyID, copyStatus, props.CopyProgress()))
+	switch copyStatus {
+	case azblob.CopyStatusPending:
+		return true, common.ETransferStatus.Started(), nil
+	case azblob.CopyStatusFailed, azblob.CopyStatusAborted:
+		return false, common.ETransferStatus.Failed(), fmt.Errorf("the server-side copy %s: %s", copyStatus, props.CopyStatusDescription())
+	default:
+		return false, common.ETransferStatus.Success(), nil
+	}
+}
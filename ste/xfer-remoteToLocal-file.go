@@ -21,9 +21,11 @@
 package ste
 
 import (
+	"crypto/md5"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -159,6 +161,7 @@ func remoteToLocal_file(jptm IJobPartTransferMgr, p pipeline.Pipeline, pacer pac
 	}
 
 	var dstFile io.WriteCloser
+	alreadySaved := int64(0)
 	if strings.EqualFold(info.Destination, common.Dev_Null) {
 		// the user wants to discard the downloaded data
 		dstFile = devNullWriter{}
@@ -171,7 +174,14 @@ func remoteToLocal_file(jptm IJobPartTransferMgr, p pipeline.Pipeline, pacer pac
 		// to correct name.
 		pseudoId := common.NewPseudoChunkIDForWholeFile(info.Source)
 		jptm.LogChunkStatus(pseudoId, common.EWaitReason.CreateLocalFile())
-		dstFile, err = createDestinationFile(jptm, info.getTempDownloadPath(), fileSize, writeThrough)
+		if rd, ok := dl.(resumableDownloader); ok && !jptm.ShouldDecompress() {
+			alreadySaved = resumePoint(jptm, rd, p, fileSize, downloadChunkSize)
+		}
+		if alreadySaved > 0 {
+			dstFile, err = reopenPartialDownload(info.getTempDownloadPath(), alreadySaved)
+		} else {
+			dstFile, err = createDestinationFile(jptm, info.getTempDownloadPath(), fileSize, writeThrough)
+		}
 		jptm.LogChunkStatus(pseudoId, common.EWaitReason.ChunkDone()) // normal setting to done doesn't apply to these pseudo ids
 		if err != nil {
 			failFileCreation(err)
@@ -196,17 +206,19 @@ func remoteToLocal_file(jptm IJobPartTransferMgr, p pipeline.Pipeline, pacer pac
 			return
 		}*/
 
-	// step 5a: compute num chunks
+	// step 5a: compute num chunks (only counting those that still need downloading, if we are resuming)
 	numChunks := uint32(0)
-	if rem := fileSize % downloadChunkSize; rem == 0 {
-		numChunks = uint32(fileSize / downloadChunkSize)
+	if rem := (fileSize - alreadySaved) % downloadChunkSize; rem == 0 {
+		numChunks = uint32((fileSize - alreadySaved) / downloadChunkSize)
 	} else {
-		numChunks = uint32(fileSize/downloadChunkSize + 1)
+		numChunks = uint32((fileSize-alreadySaved)/downloadChunkSize + 1)
 	}
 
 	// step 5b: create destination writer
 	chunkLogger := jptm.ChunkStatusLogger()
 	sourceMd5Exists := len(info.SrcHTTPHeaders.ContentMD5) > 0
+	// the hash of the file as written is needed to check it against the source's, and to write it to the checksum file
+	computeMd5 := (sourceMd5Exists && jptm.MD5ValidationOption() != common.EHashValidationOption.NoCheck()) || jptm.ShouldWriteChecksum()
 	dstWriter := common.NewChunkedFileWriter(
 		jptm.Context(),
 		jptm.SlicePool(),
@@ -215,8 +227,8 @@ func remoteToLocal_file(jptm IJobPartTransferMgr, p pipeline.Pipeline, pacer pac
 		dstFile,
 		numChunks,
 		MaxRetryPerDownloadBody,
-		jptm.MD5ValidationOption(),
-		sourceMd5Exists)
+		computeMd5,
+		alreadySaved)
 
 	// step 5c: run prologue in downloader (here it can, for example, create things that will require cleanup in the epilogue)
 	common.GetLifecycleMgr().E2EAwaitAllowOpenFiles()
@@ -236,7 +248,7 @@ func remoteToLocal_file(jptm IJobPartTransferMgr, p pipeline.Pipeline, pacer pac
 	// eventually reach numChunks, since we have no better short-term alternative.
 
 	chunkCount := uint32(0)
-	for startIndex := int64(0); startIndex < fileSize; startIndex += downloadChunkSize {
+	for startIndex := alreadySaved; startIndex < fileSize; startIndex += downloadChunkSize {
 		adjustedChunkSize := downloadChunkSize
 
 		// compute exact size of the chunk
@@ -269,6 +281,82 @@ func remoteToLocal_file(jptm IJobPartTransferMgr, p pipeline.Pipeline, pacer pac
 
 }
 
+// resumePoint says how much of a download that was kept when its job was paused can be used, as a whole number of chunks.
+// That's nothing if no partial download was kept, or if the source has changed since then
+func resumePoint(jptm IJobPartTransferMgr, rd resumableDownloader, p pipeline.Pipeline, fileSize int64, chunkSize int64) int64 {
+	info := jptm.Info()
+	etagPath := info.getResumeETagPath()
+	keptETag, err := ioutil.ReadFile(etagPath)
+	if err != nil {
+		return 0 // nothing was kept (which is the usual case)
+	}
+	_ = os.Remove(etagPath) // it describes the partial file as it was kept, and we are about to change that
+	fi, err := common.OSStat(info.getTempDownloadPath())
+	if err != nil {
+		return 0
+	}
+
+	currentETag, err := rd.CurrentSourceETag(jptm, p)
+	if err != nil || currentETag != string(keptETag) {
+		jptm.LogAtLevelForCurrentTransfer(pipeline.LogInfo, "Source may have changed since the download was paused, so it will be restarted from the beginning")
+		return 0
+	}
+
+	saved := fi.Size() - fi.Size()%chunkSize
+	if saved >= fileSize {
+		// always download the last chunk again, since it's the completion of the last chunk that finishes the transfer
+		saved = (fileSize - 1) / chunkSize * chunkSize
+	}
+	if saved > 0 {
+		jptm.LogAtLevelForCurrentTransfer(pipeline.LogInfo, fmt.Sprintf("Resuming download after the %d bytes saved before the job was paused", saved))
+	}
+	return saved
+}
+
+// reopenPartialDownload opens a kept partial download, positioned to write after the part that we are keeping
+func reopenPartialDownload(path string, alreadySaved int64) (io.WriteCloser, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, common.DEFAULT_FILE_PERM)
+	if err != nil {
+		return nil, err
+	}
+	if err = f.Truncate(alreadySaved); err == nil {
+		_, err = f.Seek(alreadySaved, io.SeekStart)
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// keepPartialDownload trims the temp file of a paused download to the length that was fully written, and records the ETag
+// of the data in it, so that the download can be continued when the job is resumed. Returns false if it can't be kept
+func keepPartialDownload(jptm IJobPartTransferMgr, dl downloader, savedLength int64) bool {
+	rd, ok := dl.(resumableDownloader)
+	if !ok || savedLength == 0 || rd.SourceETag() == "" {
+		return false
+	}
+	info := jptm.Info()
+	if err := os.Truncate(info.getTempDownloadPath(), savedLength); err != nil {
+		return false
+	}
+	if err := ioutil.WriteFile(info.getResumeETagPath(), []byte(rd.SourceETag()), common.DEFAULT_FILE_PERM); err != nil {
+		return false
+	}
+	jptm.LogAtLevelForCurrentTransfer(pipeline.LogInfo, fmt.Sprintf("Keeping the %d bytes downloaded so far, so that the download can continue when the job is resumed", savedLength))
+	return true
+}
+
+// savedLength returns how much of the file has been written, for files that are written sequentially and know their position
+func savedLength(file io.WriteCloser) int64 {
+	if s, ok := file.(io.Seeker); ok {
+		if pos, err := s.Seek(0, io.SeekCurrent); err == nil {
+			return pos
+		}
+	}
+	return 0
+}
+
 func createDestinationFile(jptm IJobPartTransferMgr, destination string, size int64, writeThrough bool) (file io.WriteCloser, err error) {
 	ct := common.ECompressionType.None()
 	if jptm.ShouldDecompress() {
@@ -314,12 +402,21 @@ func epilogueWithCleanupDownload(jptm IJobPartTransferMgr, dl downloader, active
 		jptm.SetStatus(common.ETransferStatus.Cancelled())
 	}
 
+	keptForResume := false
+	md5OfFile := md5.New().Sum(nil) // the hash of an empty file, for when there was no content to write
 	haveNonEmptyFile := activeDstFile != nil
 	if haveNonEmptyFile {
 
 		// wait until all received chunks are flushed out
 		md5OfFileAsWritten, flushError := cw.Flush(jptm.Context())
+		pausedAt := int64(0)
+		if jptm.WasPaused() {
+			pausedAt = savedLength(activeDstFile) // everything before this has been written, even if a write is still finishing
+		}
 		closeErr := activeDstFile.Close() // always try to close if, even if flush failed
+		if pausedAt > 0 {
+			keptForResume = keepPartialDownload(jptm, dl, pausedAt)
+		}
 		if flushError != nil {
 			jptm.FailActiveDownload("Flushing file", flushError)
 		}
@@ -339,6 +436,8 @@ func epilogueWithCleanupDownload(jptm IJobPartTransferMgr, dl downloader, active
 			if err != nil {
 				jptm.FailActiveDownload("Checking MD5 hash", err)
 			}
+			jptm.SetContentMD5(md5OfFileAsWritten)
+			md5OfFile = md5OfFileAsWritten
 
 			// check length if enabled (except for dev null and decompression case, where that's impossible)
 			if info.DestLengthValidation && info.Destination != common.Dev_Null && !jptm.ShouldDecompress() {
@@ -387,10 +486,16 @@ func epilogueWithCleanupDownload(jptm IJobPartTransferMgr, dl downloader, active
 		}
 	}
 
-	commonDownloaderCompletion(jptm, info, common.EEntityType.File())
+	// the file is known to be complete and correct, so its hash can go in the checksum file
+	if jptm.IsLive() && jptm.ShouldWriteChecksum() && !strings.EqualFold(info.Destination, common.Dev_Null) {
+		jptm.WriteChecksum(md5OfFile)
+	}
+
+	commonDownloaderCompletion(jptm, info, common.EEntityType.File(), keptForResume)
 }
 
-func commonDownloaderCompletion(jptm IJobPartTransferMgr, info TransferInfo, entityType common.EntityType) {
+// keepPartialFile is true for paused file downloads whose incomplete file will be used when the job is resumed
+func commonDownloaderCompletion(jptm IJobPartTransferMgr, info TransferInfo, entityType common.EntityType, keepPartialFile bool) {
 	// note that we do not really know whether the context was canceled because of an error, or because the user asked for it
 	// if was an intentional cancel, the status is still "in progress", so we are still counting it as pending
 	// we leave these transfer status alone
@@ -404,10 +509,14 @@ func commonDownloaderCompletion(jptm IJobPartTransferMgr, info TransferInfo, ent
 		}
 		// for files only, cleanup local file if applicable
 		if entityType == entityType.File() && jptm.IsDeadInflight() && jptm.HoldsDestinationLock() {
-			jptm.LogAtLevelForCurrentTransfer(pipeline.LogInfo, "Deleting incomplete destination file")
+			if keepPartialFile {
+				jptm.LogAtLevelForCurrentTransfer(pipeline.LogInfo, "Keeping incomplete destination file, since the job was paused")
+			} else {
+				jptm.LogAtLevelForCurrentTransfer(pipeline.LogInfo, "Deleting incomplete destination file")
 
-			// the file created locally should be deleted
-			tryDeleteFile(info, jptm)
+				// the file created locally should be deleted
+				tryDeleteFile(info, jptm)
+			}
 		}
 	} else {
 		if !jptm.IsLive() {
@@ -476,6 +585,12 @@ func (info *TransferInfo) getTempDownloadPath() string {
 	return filepath.Join(parent, fileName)
 }
 
+// Returns the path of the file that records the ETag of the source data in a partial download that was kept for
+// resuming. It sits beside the temp file, as /actual/parent/path/.azDownload-<jobID>-<actualFileName>.etag
+func (info *TransferInfo) getResumeETagPath() string {
+	return info.getTempDownloadPath() + ".etag"
+}
+
 // conforms to io.Writer and io.Closer
 // does absolutely nothing to discard the given data
 type devNullWriter struct{}
@@ -68,6 +68,6 @@ func remoteToLocal_folder(jptm IJobPartTransferMgr, p pipeline.Pipeline, pacer p
 			jptm.FailActiveDownload("setting folder properties", err)
 		}
 	}
-	commonDownloaderCompletion(jptm, info, common.EEntityType.Folder()) // for consistency, always run the standard epilogue
+	commonDownloaderCompletion(jptm, info, common.EEntityType.Folder(), false) // for consistency, always run the standard epilogue
 
 }
@@ -37,6 +37,8 @@ const UploadMaxTries = 20
 const UploadRetryDelay = time.Second * 1
 const UploadMaxRetryDelay = time.Second * 60
 var UploadTryTimeout = time.Minute * 15
+var UploadRetryJitter = DefaultRetryJitter
+var UploadChunkRetries = 3 // times a block is staged again, after its request has used up the pipeline's retries, before the transfer fails
 var ADLSFlushThreshold uint32 = 7500 // The # of blocks to flush at a time-- Implemented only for CI.
 
 // download related
@@ -78,7 +80,7 @@ func parameterizeSend(targetFunction newJobXferWithSenderFactory, sf senderFacto
 }
 
 // the xfer factory is generated based on the type of source and destination
-func computeJobXfer(fromTo common.FromTo, blobType common.BlobType) newJobXfer {
+func computeJobXfer(fromTo common.FromTo, blobType common.BlobType, serverSideCopy bool) newJobXfer {
 
 	const blobFSNotS2S = "blobFS not supported as S2S source"
 
@@ -154,6 +156,8 @@ func computeJobXfer(fromTo common.FromTo, blobType common.BlobType) newJobXfer {
 		return DeleteBlob
 	case fromTo == common.EFromTo.FileTrash():
 		return DeleteFile
+	case fromTo == common.EFromTo.BlobBlob() && serverSideCopy:
+		return BlobToBlob
 	default:
 		if fromTo.IsDownload() {
 			return parameterizeDownload(remoteToLocal, getDownloader(fromTo.From()))
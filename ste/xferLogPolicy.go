@@ -113,8 +113,17 @@ func NewRequestLogPolicyFactory(o RequestLogOptions) pipeline.Factory {
 				logLevel, forceLog = pipeline.LogError, !o.SyslogDisabled
 			}
 
+			// Routine successes are sampled, if the transfer asked for that, so that its log isn't one line per chunk
+			shouldLog := po.ShouldLog(logLevel)
+			sampledCount := int64(0)
+			if shouldLog && logLevel == pipeline.LogInfo && err == nil && !httpError && try == 1 && !po.ShouldLog(pipeline.LogDebug) {
+				if throttle, ok := requestLogThrottleFrom(ctx); ok {
+					shouldLog, sampledCount = throttle.shouldLog(request.Request)
+				}
+			}
+
 			logBody := false
-			if shouldLog := po.ShouldLog(logLevel); forceLog || shouldLog {
+			if forceLog || shouldLog {
 				// We're going to log this; build the string to log
 				b := &bytes.Buffer{}
 				slow := ""
@@ -129,7 +138,11 @@ func NewRequestLogPolicyFactory(o RequestLogOptions) pipeline.Factory {
 						fmt.Fprint(b, "RESPONSE STATUS CODE ERROR\n")
 						logBody = true
 					} else {
-						fmt.Fprint(b, "RESPONSE SUCCESSFULLY RECEIVED\n")
+						fmt.Fprint(b, "RESPONSE SUCCESSFULLY RECEIVED")
+						if sampledCount > 2 {
+							fmt.Fprintf(b, " (request %d like this for the transfer; only some are logged)", sampledCount)
+						}
+						fmt.Fprint(b, "\n")
 					}
 				}
 
@@ -0,0 +1,66 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// requestLogThrottle samples the routine log lines that the request log policy writes for one transfer.
+// A big file takes one request per chunk, all of the same kind, and logging thousands of identical
+// "RESPONSE SUCCESSFULLY RECEIVED" lines buries anything interesting in the log. So, for each kind of request,
+// only the 1st, 2nd, 4th, 8th... successful response is logged. Failures, retries and slow responses are
+// always logged, and nothing is sampled when logging at debug level.
+type requestLogThrottle struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func newRequestLogThrottle() *requestLogThrottle {
+	return &requestLogThrottle{counts: make(map[string]int64)}
+}
+
+var requestLogThrottleContextKey = contextKey{"requestLogThrottle"}
+
+// withRequestLogThrottle returns a context whose routine request log lines are sampled by the given throttle
+func withRequestLogThrottle(ctx context.Context, t *requestLogThrottle) context.Context {
+	return context.WithValue(ctx, requestLogThrottleContextKey, t)
+}
+
+func requestLogThrottleFrom(ctx context.Context) (*requestLogThrottle, bool) {
+	t, ok := ctx.Value(requestLogThrottleContextKey).(*requestLogThrottle)
+	return t, ok
+}
+
+// shouldLog counts a routine response to the given request, and says whether it is one to log.
+// It also returns the count, so that the logged line can say how many like it there have been.
+func (t *requestLogThrottle) shouldLog(r *http.Request) (bool, int64) {
+	// e.g. all the Put Blocks of a transfer are one kind of request, and its Put Block List is another
+	kind := r.Method + " " + r.URL.Query().Get("comp")
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := t.counts[kind] + 1
+	t.counts[kind] = n
+	return n&(n-1) == 0, n // i.e. n is a power of two
+}
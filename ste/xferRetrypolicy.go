@@ -61,8 +61,16 @@ type XferRetryOptions struct {
 	// NOTE: Before setting this field, make sure you understand the issues around reading stale & potentially-inconsistent
 	// data at this webpage: https://docs.microsoft.com/en-us/azure/storage/common/storage-designing-ha-apps-with-ragrs
 	RetryReadsFromSecondaryHost string // Comment this our for non-Blob SDKs
+
+	// RetryJitter specifies how much the delay before each retry is randomized, as a fraction of the delay (0=default).
+	// E.g. 0.5 means the delay is picked from between 75% and 125% of the computed delay, subject to RetryDelay and MaxRetryDelay.
+	// Values above 1 are treated as 1. A negative value turns jitter off.
+	RetryJitter float32
 }
 
+// DefaultRetryJitter is the RetryJitter used when none is specified
+const DefaultRetryJitter float32 = 0.5
+
 func (o XferRetryOptions) retryReadsFromSecondaryHost() string {
 	return o.RetryReadsFromSecondaryHost // This is for the Blob SDK only
 	//return "" // This is for non-blob SDKs
@@ -95,6 +103,9 @@ func (o XferRetryOptions) defaults() XferRetryOptions {
 	if o.MaxTries == 0 {
 		o.MaxTries = 4
 	}
+	if o.RetryJitter == 0 {
+		o.RetryJitter = DefaultRetryJitter
+	}
 	switch o.Policy {
 	case RetryPolicyExponential:
 		IfDefault(&o.TryTimeout, 1*time.Minute)
@@ -129,12 +140,35 @@ func (o XferRetryOptions) calcDelay(try int32) time.Duration { // try is >=1; ne
 		}
 	}
 
-	// Introduce some jitter:  [0.0, 1.0) / 2 = [0.0, 0.5) + 0.8 = [0.8, 1.3)
-	// For casts and rounding - be careful, as per https://github.com/golang/go/issues/20757
-	delay = time.Duration(float32(delay) * (rand.Float32()/2 + 0.8)) // NOTE: We want math/rand; not crypto/rand
+	if delay == 0 {
+		return 0
+	}
 	if delay > o.MaxRetryDelay {
 		delay = o.MaxRetryDelay
 	}
+
+	// Introduce some jitter, so that chunks throttled at the same moment don't all retry together. The delay is picked
+	// uniformly from a window of width RetryJitter around the computed delay, with the window kept within
+	// [RetryDelay, MaxRetryDelay]. (Jittering only before capping would let every long-running retry land on exactly MaxRetryDelay)
+	jitter := float64(o.RetryJitter)
+	if jitter < 0 {
+		jitter = 0
+	} else if jitter > 1 {
+		jitter = 1
+	}
+	lo := time.Duration(float64(delay) * (1 - jitter/2))
+	hi := time.Duration(float64(delay) * (1 + jitter/2))
+	if lo < o.RetryDelay {
+		lo = o.RetryDelay
+	}
+	if hi > o.MaxRetryDelay {
+		hi = o.MaxRetryDelay
+	}
+	if hi > lo {
+		delay = lo + time.Duration(rand.Int63n(int64(hi-lo))) // NOTE: We want math/rand; not crypto/rand
+	} else {
+		delay = lo
+	}
 	return delay
 }
 
@@ -286,6 +320,9 @@ func NewBFSXferRetryPolicyFactory(o XferRetryOptions) pipeline.Factory {
 					io.Copy(ioutil.Discard, response.Response().Body)
 					response.Response().Body.Close()
 				}
+				if try < o.MaxTries {
+					transferMetrics.retry()
+				}
 				// If retrying, cancel the current per-try timeout context
 				tryCancel()
 			}
@@ -410,24 +447,7 @@ func NewBlobXferRetryPolicyFactory(o XferRetryOptions) pipeline.Factory {
 					// some errors like 'connection reset by peer' or 'transport connection broken' does not implement the Temporary interface
 					// but they should be retried. So redefined the retry policy for azcopy to retry for such errors as well.
 
-					// TODO make sure Storage error can be cast to different package's error object
-					// TODO: Discuss the error handling of Go Blob SDK.
-					if stErr, ok := err.(azblob.StorageError); ok {
-						// retry only in case of temporary storage errors.
-						if stErr.Temporary() {
-							action = "Retry: StorageError with error service code and Temporary()"
-						} else if stErr.Response() != nil && isSuccessStatusCode(stErr.Response()) { // This is a temporarily work around.
-							action = "Retry: StorageError with success status code"
-						} else {
-							action = "NoRetry: StorageError not Temporary() and without retriable status code"
-						}
-					} else if _, ok := err.(net.Error); ok {
-						action = "Retry: net.Error"
-					} else if err == io.ErrUnexpectedEOF {
-						action = "Retry: io.UnexpectedEOF"
-					} else {
-						action = "NoRetry: unrecognized error"
-					}
+					action = blobErrorRetryAction(err)
 
 				default:
 					action = "NoRetry: successful HTTP request" // no error
@@ -458,6 +478,9 @@ func NewBlobXferRetryPolicyFactory(o XferRetryOptions) pipeline.Factory {
 					io.Copy(ioutil.Discard, response.Response().Body)
 					response.Response().Body.Close()
 				}
+				if try < maxTries {
+					transferMetrics.retry()
+				}
 				// If retrying, cancel the current per-try timeout context
 				tryCancel()
 			}
@@ -466,6 +489,32 @@ func NewBlobXferRetryPolicyFactory(o XferRetryOptions) pipeline.Factory {
 	})
 }
 
+// blobErrorRetryAction says whether a failed blob request should be tried again, and why.
+// Like the other actions of the retry policy, the result starts with 'R' if, and only if, it's a retry
+func blobErrorRetryAction(err error) string {
+	// TODO make sure Storage error can be cast to different package's error object
+	// TODO: Discuss the error handling of Go Blob SDK.
+	if stErr, ok := err.(azblob.StorageError); ok {
+		// retry only in case of temporary storage errors.
+		if stErr.Temporary() {
+			return "Retry: StorageError with error service code and Temporary()"
+		} else if stErr.Response() != nil && isSuccessStatusCode(stErr.Response()) { // This is a temporarily work around.
+			return "Retry: StorageError with success status code"
+		}
+		return "NoRetry: StorageError not Temporary() and without retriable status code"
+	} else if _, ok := err.(net.Error); ok {
+		return "Retry: net.Error"
+	} else if err == io.ErrUnexpectedEOF {
+		return "Retry: io.UnexpectedEOF"
+	}
+	return "NoRetry: unrecognized error"
+}
+
+// isTransientBlobError is true for the errors that the blob retry policy would try again
+func isTransientBlobError(err error) bool {
+	return blobErrorRetryAction(err)[0] == 'R'
+}
+
 var successStatusCodes = []int{http.StatusOK, http.StatusCreated, http.StatusAccepted, http.StatusNoContent, http.StatusPartialContent}
 
 func isSuccessStatusCode(resp *http.Response) bool {
@@ -0,0 +1,64 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+type autoBlockSizeSuite struct{}
+
+var _ = chk.Suite(&autoBlockSizeSuite{})
+
+func (s *autoBlockSizeSuite) TestAutoBlockSize(c *chk.C) {
+	const (
+		KiB = int64(1024)
+		MiB = 1024 * KiB
+		GiB = 1024 * MiB
+		TiB = 1024 * GiB
+	)
+
+	cases := []struct {
+		name              string
+		sourceSize        int64
+		expectedBlockSize int64
+		expectedNumChunks uint32
+	}{
+		{"empty file", 0, 8 * MiB, 1},
+		{"1 KB", 1 * KiB, 8 * MiB, 1},
+		{"exactly one default block", 8 * MiB, 8 * MiB, 1},
+		{"100 MB", 100 * MiB, 8 * MiB, 13},
+		{"exactly the block limit at the default size", common.MaxNumberOfBlocksPerBlob * 8 * MiB, 8 * MiB, common.MaxNumberOfBlocksPerBlob},
+		{"one byte past the block limit at the default size", common.MaxNumberOfBlocksPerBlob*8*MiB + 1, 16 * MiB, 25001},
+		{"500 GB", 500 * GiB, 16 * MiB, 32000},
+		{"20 TB", 20 * TiB, 512 * MiB, 40960},
+		{"30 TB, beyond the doubling threshold", 30 * TiB, 659706977, common.MaxNumberOfBlocksPerBlob},
+	}
+
+	for _, tc := range cases {
+		blockSize := autoBlockSize(tc.sourceSize)
+		c.Assert(blockSize, chk.Equals, tc.expectedBlockSize, chk.Commentf(tc.name))
+		c.Assert(blockSize >= common.DefaultBlockBlobBlockSize, chk.Equals, true, chk.Commentf(tc.name))
+		c.Assert(getNumChunks(tc.sourceSize, blockSize), chk.Equals, tc.expectedNumChunks, chk.Commentf(tc.name))
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-file-go/azfile"
+	chk "gopkg.in/check.v1"
+)
+
+type azureFileParentDirCreatorSuite struct{}
+
+var _ = chk.Suite(&azureFileParentDirCreatorSuite{})
+
+type recordingFolderTracker struct {
+	created []string
+}
+
+func (t *recordingFolderTracker) RecordCreation(folder string) {
+	t.created = append(t.created, folder)
+}
+
+func (t *recordingFolderTracker) ShouldSetProperties(string, common.OverwriteOption, common.Prompter) bool {
+	return true
+}
+
+func (t *recordingFolderTracker) StopTracking(string) {}
+
+// fakeFileShare answers just enough of the File service API for directory creation.
+// Directories in existing are already there; any others are created on request
+func fakeFileShare(existing ...string) (*httptest.Server, *[]string) {
+	var mu sync.Mutex
+	dirs := map[string]bool{}
+	for _, d := range existing {
+		dirs[d] = true
+	}
+	createRequests := &[]string{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		dir := strings.TrimPrefix(r.URL.Path, "/share/")
+
+		switch r.Method {
+		case http.MethodHead, http.MethodGet:
+			if !dirs[dir] {
+				w.Header().Set("x-ms-error-code", "ResourceNotFound")
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		case http.MethodPut:
+			*createRequests = append(*createRequests, dir)
+			if dirs[dir] {
+				w.Header().Set("x-ms-error-code", "ResourceAlreadyExists")
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			dirs[dir] = true
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	return server, createRequests
+}
+
+func (s *azureFileParentDirCreatorSuite) TestCreateParentDirToRootIsIdempotent(c *chk.C) {
+	server, createRequests := fakeFileShare("a")
+	defer server.Close()
+
+	// use a host name, since with an IP address the first path segment would be taken as the account name
+	u, err := url.Parse(strings.Replace(server.URL, "127.0.0.1", "localhost", 1) + "/share/a/b/file.txt")
+	c.Assert(err, chk.IsNil)
+	p := azfile.NewPipeline(azfile.NewAnonymousCredential(), azfile.PipelineOptions{Retry: azfile.RetryOptions{MaxTries: 1}})
+	fileURL := azfile.NewFileURL(*u, p)
+	tracker := &recordingFolderTracker{}
+
+	// "a" exists already, so only "a/b" is new
+	err = AzureFileParentDirCreator{}.CreateParentDirToRoot(context.Background(), fileURL, p, tracker)
+	c.Assert(err, chk.IsNil)
+	c.Assert(*createRequests, chk.DeepEquals, []string{"a", "a/b"})
+	c.Assert(tracker.created, chk.HasLen, 1)
+	c.Assert(strings.HasSuffix(tracker.created[0], "/share/a/b"), chk.Equals, true)
+
+	// now that the parent is there, doing it again (e.g. for another file in the same folder) creates nothing
+	err = AzureFileParentDirCreator{}.CreateParentDirToRoot(context.Background(), fileURL, p, tracker)
+	c.Assert(err, chk.IsNil)
+	c.Assert(*createRequests, chk.HasLen, 2)
+	c.Assert(tracker.created, chk.HasLen, 1)
+}
@@ -0,0 +1,142 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// Static websites depend on --cache-control and --content-disposition reaching the blob, whether it is
+// written with a single Put Blob or staged in blocks and committed with Put Block List.
+type blobHttpHeadersSuite struct{}
+
+var _ = chk.Suite(&blobHttpHeadersSuite{})
+
+// headersTransferMgr adds what the block blob sender's constructor and epilogue need to a testTransferMgr
+type headersTransferMgr struct {
+	testTransferMgr
+	headers common.ResourceHTTPHeaders
+}
+
+func (t *headersTransferMgr) BlobTiers() (common.BlockBlobTier, common.PageBlobTier) {
+	return common.EBlockBlobTier.None(), common.EPageBlobTier.None()
+}
+func (t *headersTransferMgr) CpkInfo() common.CpkInfo                                { return common.CpkInfo{} }
+func (t *headersTransferMgr) CpkScopeInfo() common.CpkScopeInfo                      { return common.CpkScopeInfo{} }
+func (t *headersTransferMgr) LastModifiedInMetadata() bool                           { return false }
+func (t *headersTransferMgr) FromTo() common.FromTo                                  { return common.EFromTo.LocalBlob() }
+func (t *headersTransferMgr) LogAtLevelForCurrentTransfer(pipeline.LogLevel, string) {}
+func (t *headersTransferMgr) ResourceDstData(_ []byte) (common.ResourceHTTPHeaders, common.Metadata, common.BlobTags, common.CpkOptions) {
+	return t.headers, common.Metadata{}, nil, common.CpkOptions{}
+}
+
+// blobWriteRecorder accepts every request, and remembers the headers of the ones that create the blob
+type blobWriteRecorder struct {
+	*httptest.Server
+	mu     sync.Mutex
+	writes map[string]http.Header // keyed by the operation: "blob", "block" or "blocklist"
+}
+
+func newBlobWriteRecorder() *blobWriteRecorder {
+	r := &blobWriteRecorder{writes: map[string]http.Header{}}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = ioutil.ReadAll(req.Body)
+		if req.Method != http.MethodPut {
+			w.WriteHeader(http.StatusNotFound) // e.g. looking for blocks staged by an earlier attempt
+			return
+		}
+		op := req.URL.Query().Get("comp")
+		if op == "" {
+			op = "blob"
+		}
+		r.mu.Lock()
+		r.writes[op] = req.Header
+		r.mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	return r
+}
+
+func (s *blobHttpHeadersSuite) upload(c *chk.C, content string, blockSize int64) *blobWriteRecorder {
+	srcPath := filepath.Join(c.MkDir(), "index.html")
+	c.Assert(ioutil.WriteFile(srcPath, []byte(content), 0644), chk.IsNil)
+
+	jptm := &headersTransferMgr{
+		testTransferMgr: testTransferMgr{info: TransferInfo{Source: srcPath, SourceSize: int64(len(content)), BlockSize: blockSize}},
+		headers:         common.ResourceHTTPHeaders{ContentType: "text/html", CacheControl: "public, max-age=3600", ContentDisposition: "inline"},
+	}
+	server := newBlobWriteRecorder()
+	p := azblob.NewPipeline(azblob.NewAnonymousCredential(), azblob.PipelineOptions{Retry: azblob.RetryOptions{MaxTries: 1}})
+	sip, err := newLocalSourceInfoProvider(jptm)
+	c.Assert(err, chk.IsNil)
+	uploader, err := newBlockBlobUploader(jptm, server.URL+"/container/index.html", p, newNullAutoPacer(), sip)
+	c.Assert(err, chk.IsNil)
+
+	srcFile, err := os.Open(srcPath)
+	c.Assert(err, chk.IsNil)
+	defer srcFile.Close()
+	factory := func() (common.CloseableReaderAt, error) { return os.Open(srcPath) }
+
+	scheduleSendChunks(jptm, srcPath, srcFile, int64(len(content)), uploader, factory, localSourceInfoProvider{})
+	for _, cf := range jptm.chunks {
+		cf(0)
+	}
+	uploader.Epilogue()
+	c.Assert(jptm.failure, chk.IsNil)
+	return server
+}
+
+func (s *blobHttpHeadersSuite) assertHeaders(c *chk.C, h http.Header) {
+	c.Assert(h, chk.NotNil)
+	c.Assert(h.Get("x-ms-blob-cache-control"), chk.Equals, "public, max-age=3600")
+	c.Assert(h.Get("x-ms-blob-content-disposition"), chk.Equals, "inline")
+	c.Assert(h.Get("x-ms-blob-content-type"), chk.Equals, "text/html")
+}
+
+func (s *blobHttpHeadersSuite) TestHeadersOnSmallFile(c *chk.C) {
+	server := s.upload(c, "<html></html>", common.DefaultBlockBlobBlockSize)
+	defer server.Close()
+
+	s.assertHeaders(c, server.writes["blob"])
+	c.Assert(server.writes, chk.HasLen, 1)
+}
+
+func (s *blobHttpHeadersSuite) TestHeadersOnLargeFile(c *chk.C) {
+	// small blocks, so that this file needs several of them
+	server := s.upload(c, "<html>"+strings.Repeat("x", 100)+"</html>", 16)
+	defer server.Close()
+
+	s.assertHeaders(c, server.writes["blocklist"])
+	c.Assert(server.writes["block"], chk.NotNil)
+	c.Assert(server.writes["blob"], chk.IsNil)
+}
@@ -0,0 +1,106 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package ste
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	chk "gopkg.in/check.v1"
+)
+
+type blobTagsUploadSuite struct{}
+
+var _ = chk.Suite(&blobTagsUploadSuite{})
+
+// tagRecorder remembers the tags sent with each Put Blob, and by any separate Set Blob Tags
+type tagRecorder struct {
+	*httptest.Server
+	mu         sync.Mutex
+	putTags    string
+	setTagsXML string
+}
+
+func newTagRecorder() *tagRecorder {
+	r := &tagRecorder{}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if req.URL.Query().Get("comp") == "tags" {
+			r.setTagsXML = string(body)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		r.putTags = req.Header.Get("x-ms-tags")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	return r
+}
+
+func (s *blobTagsUploadSuite) upload(c *chk.C, tags azblob.BlobTagsMap) *tagRecorder {
+	content := []byte("tagged")
+	srcPath := filepath.Join(c.MkDir(), "tagged.txt")
+	c.Assert(ioutil.WriteFile(srcPath, content, 0644), chk.IsNil)
+
+	server := newTagRecorder()
+	jptm := &smallFileTransferMgr{testTransferMgr: testTransferMgr{info: TransferInfo{Source: srcPath, SourceSize: int64(len(content))}}}
+	uploader, err := newTestBlockBlobUploader(jptm, server.URL+"/container/tagged.txt")
+	c.Assert(err, chk.IsNil)
+	uploader.blobTagsToApply = tags
+
+	factory := func() (common.CloseableReaderAt, error) { return os.Open(srcPath) }
+	scheduleSmallFileSend(jptm, srcPath, int64(len(content)), uploader, factory)
+	jptm.smallFileSends[0](0)
+	c.Assert(jptm.failure, chk.IsNil)
+	return server
+}
+
+func (s *blobTagsUploadSuite) TestTagsAreSentWithTheBlob(c *chk.C) {
+	server := s.upload(c, azblob.BlobTagsMap{"project": "alpha", "stage": "raw data"})
+	defer server.Close()
+
+	sent, err := url.ParseQuery(server.putTags)
+	c.Assert(err, chk.IsNil)
+	c.Assert(sent.Get("project"), chk.Equals, "alpha")
+	c.Assert(sent.Get("stage"), chk.Equals, "raw data")
+	c.Assert(server.setTagsXML, chk.Equals, "") // no separate request was needed
+}
+
+func (s *blobTagsUploadSuite) TestTagsTooLongForTheHeaderAreSetAfterwards(c *chk.C) {
+	long := strings.Repeat("v", 256)
+	tags := azblob.BlobTagsMap{}
+	for _, key := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"} {
+		tags[key] = long
+	}
+	server := s.upload(c, tags)
+	defer server.Close()
+
+	c.Assert(server.putTags, chk.Equals, "")
+	c.Assert(strings.Count(server.setTagsXML, "<Tag>"), chk.Equals, 10)
+}
@@ -0,0 +1,437 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package ste
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+type blobToBlobSuite struct{}
+
+var _ = chk.Suite(&blobToBlobSuite{})
+
+func (s *blobToBlobSuite) SetUpTest(c *chk.C) {
+	serverSideCopyPollInterval = time.Millisecond
+}
+
+func (s *blobToBlobSuite) TearDownTest(c *chk.C) {
+	serverSideCopyPollInterval = 2 * time.Second
+}
+
+// serverSideCopyTransferMgr is a testTransferMgr for a server-side copy, which records how the transfer ended
+type serverSideCopyTransferMgr struct {
+	testTransferMgr
+	ctx       context.Context
+	overwrite common.OverwriteOption
+	status    common.TransferStatus
+	done      bool
+	finished  chan struct{}
+	copyError string
+
+	// heldChecks keeps the checks on an asynchronous copy, instead of running them, when holdChecks is set
+	checksLock sync.Mutex
+	holdChecks bool
+	heldChecks []chunkFunc
+
+	replaceProperties bool
+	userHeaders       common.ResourceHTTPHeaders
+	userMetadata      common.Metadata
+}
+
+func newServerSideCopyTransferMgr(source, destination string, size int64) *serverSideCopyTransferMgr {
+	return &serverSideCopyTransferMgr{
+		testTransferMgr: testTransferMgr{info: TransferInfo{
+			Source:      source,
+			Destination: destination,
+			SourceSize:  size,
+			SrcBlobType: azblob.BlobBlockBlob,
+		}},
+		ctx:       context.Background(),
+		overwrite: common.EOverwriteOption.True(),
+		finished:  make(chan struct{}),
+	}
+}
+
+func (t *serverSideCopyTransferMgr) Context() context.Context                   { return t.ctx }
+func (t *serverSideCopyTransferMgr) WasCanceled() bool                          { return t.ctx.Err() != nil }
+func (t *serverSideCopyTransferMgr) GetOverwriteOption() common.OverwriteOption { return t.overwrite }
+func (t *serverSideCopyTransferMgr) SetStatus(status common.TransferStatus)     { t.status = status }
+func (t *serverSideCopyTransferMgr) ReportTransferDone() uint32 {
+	t.done = true
+	close(t.finished)
+	return 0
+}
+func (t *serverSideCopyTransferMgr) ScheduleChunksInOrder(cf chunkFunc) {
+	t.checksLock.Lock()
+	defer t.checksLock.Unlock()
+	if t.holdChecks {
+		t.heldChecks = append(t.heldChecks, cf)
+		return
+	}
+	go cf(0)
+}
+func (t *serverSideCopyTransferMgr) LogAtLevelForCurrentTransfer(pipeline.LogLevel, string) {}
+func (t *serverSideCopyTransferMgr) ShouldReplaceProperties() bool                          { return t.replaceProperties }
+func (t *serverSideCopyTransferMgr) SourceProviderPipeline() pipeline.Pipeline {
+	return azblob.NewPipeline(azblob.NewAnonymousCredential(), azblob.PipelineOptions{Retry: azblob.RetryOptions{MaxTries: 1}})
+}
+func (t *serverSideCopyTransferMgr) ResourceDstData([]byte) (common.ResourceHTTPHeaders, common.Metadata, common.BlobTags, common.CpkOptions) {
+	return t.userHeaders, t.userMetadata, nil, common.CpkOptions{}
+}
+func (t *serverSideCopyTransferMgr) LogS2SCopyError(_, _, errorMsg string, _ int) {
+	t.copyError = errorMsg
+}
+
+// copyBlobService fakes the destination of server-side copies. An asynchronous copy stays pending for
+// pendingPolls checks of the destination's properties, then ends with finalStatus.
+// Blobs whose name is "source" are sources, which only have their properties read.
+type copyBlobService struct {
+	mu           sync.Mutex
+	source       fakeBlobProperties
+	dest         fakeBlobProperties
+	destExists   bool
+	pendingPolls int
+	finalStatus  azblob.CopyStatusType
+	syncCopies   int
+	asyncCopies  int
+	polls        int
+	aborts       int
+	copySources  []string
+	onPoll       func()
+}
+
+type fakeBlobProperties struct {
+	headers  http.Header // the content headers, such as Content-Type
+	metadata map[string]string
+}
+
+var fakeBlobHeaderNames = map[string]string{
+	"Content-Type":        "x-ms-blob-content-type",
+	"Content-Encoding":    "x-ms-blob-content-encoding",
+	"Content-Language":    "x-ms-blob-content-language",
+	"Content-Disposition": "x-ms-blob-content-disposition",
+	"Cache-Control":       "x-ms-blob-cache-control",
+}
+
+func (p fakeBlobProperties) write(w http.ResponseWriter) {
+	for name := range fakeBlobHeaderNames {
+		if v := p.headers.Get(name); v != "" {
+			w.Header().Set(name, v)
+		}
+	}
+	for k, v := range p.metadata {
+		w.Header().Set("x-ms-meta-"+k, v)
+	}
+}
+
+func requestMetadata(r *http.Request) map[string]string {
+	metadata := map[string]string{}
+	for k := range r.Header {
+		if strings.HasPrefix(strings.ToLower(k), "x-ms-meta-") {
+			metadata[strings.ToLower(strings.TrimPrefix(strings.ToLower(k), "x-ms-meta-"))] = r.Header.Get(k)
+		}
+	}
+	return metadata
+}
+
+func (f *copyBlobService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	const copyID = "copy-1"
+
+	switch {
+	case r.Method == http.MethodHead && strings.HasSuffix(r.URL.Path, "/source"):
+		f.source.write(w)
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPut && r.URL.Query().Get("comp") == "properties":
+		f.dest.headers = http.Header{}
+		for name, requestHeader := range fakeBlobHeaderNames {
+			if v := r.Header.Get(requestHeader); v != "" {
+				f.dest.headers.Set(name, v)
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPut && r.URL.Query().Get("comp") == "copy":
+		f.aborts++
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		f.copySources = append(f.copySources, r.Header.Get("x-ms-copy-source"))
+		f.dest.headers = f.source.headers.Clone()
+		f.dest.metadata = requestMetadata(r)
+		if len(f.dest.metadata) == 0 {
+			f.dest.metadata = f.source.metadata
+		}
+		w.Header().Set("x-ms-copy-id", copyID)
+		if r.Header.Get("x-ms-requires-sync") == "true" {
+			f.syncCopies++
+			w.Header().Set("x-ms-copy-status", string(azblob.CopyStatusSuccess))
+		} else {
+			f.asyncCopies++
+			w.Header().Set("x-ms-copy-status", string(azblob.CopyStatusPending))
+		}
+		f.destExists = true
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodHead:
+		if !f.destExists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if f.asyncCopies > 0 {
+			f.polls++
+			if f.onPoll != nil {
+				f.onPoll()
+			}
+			w.Header().Set("x-ms-copy-id", copyID)
+			if f.polls <= f.pendingPolls {
+				w.Header().Set("x-ms-copy-status", string(azblob.CopyStatusPending))
+				w.Header().Set("x-ms-copy-progress", "512/1024")
+			} else {
+				w.Header().Set("x-ms-copy-status", string(f.finalStatus))
+				w.Header().Set("x-ms-copy-status-description", "500 InternalError")
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (s *blobToBlobSuite) copy(jptm *serverSideCopyTransferMgr) {
+	p := azblob.NewPipeline(azblob.NewAnonymousCredential(), azblob.PipelineOptions{Retry: azblob.RetryOptions{MaxTries: 1}})
+	doBlobToBlob(jptm, p)
+	select {
+	case <-jptm.finished:
+	case <-time.After(10 * time.Second):
+		panic("the transfer was not reported done")
+	}
+}
+
+func (s *blobToBlobSuite) TestPendingCopyDoesNotHoldTheWorker(c *chk.C) {
+	service := &copyBlobService{pendingPolls: 1, finalStatus: azblob.CopyStatusSuccess}
+	server := httptest.NewServer(service)
+	defer server.Close()
+
+	jptm := newServerSideCopyTransferMgr("https://source.blob.core.windows.net/container/blob?sig=secret", server.URL+"/container/blob", syncServerSideCopyMaxSize+1)
+	jptm.holdChecks = true
+	p := azblob.NewPipeline(azblob.NewAnonymousCredential(), azblob.PipelineOptions{Retry: azblob.RetryOptions{MaxTries: 1}})
+	doBlobToBlob(jptm, p)
+
+	// the copy has been started, and the worker is free while it's pending
+	c.Assert(jptm.done, chk.Equals, false)
+	c.Assert(service.asyncCopies, chk.Equals, 1)
+
+	// each check is a chunk of its own, which schedules the next one if the copy is still pending
+	for polls := 1; polls <= 2; polls++ {
+		c.Assert(waitForChunksToFinish(func() int64 {
+			jptm.checksLock.Lock()
+			defer jptm.checksLock.Unlock()
+			return int64(1 - len(jptm.heldChecks))
+		}, 5*time.Second), chk.Equals, true)
+		jptm.checksLock.Lock()
+		check := jptm.heldChecks[0]
+		jptm.heldChecks = nil
+		jptm.checksLock.Unlock()
+
+		check(0)
+		c.Assert(service.polls, chk.Equals, polls)
+	}
+	<-jptm.finished
+	c.Assert(jptm.status, chk.Equals, common.ETransferStatus.Success())
+}
+
+func (s *blobToBlobSuite) TestSmallBlobIsCopiedSynchronously(c *chk.C) {
+	service := &copyBlobService{}
+	server := httptest.NewServer(service)
+	defer server.Close()
+
+	source := "https://source.blob.core.windows.net/container/blob?sig=secret"
+	jptm := newServerSideCopyTransferMgr(source, server.URL+"/container/blob", 1024)
+	s.copy(jptm)
+
+	c.Assert(jptm.done, chk.Equals, true)
+	c.Assert(jptm.status, chk.Equals, common.ETransferStatus.Success())
+	c.Assert(service.syncCopies, chk.Equals, 1)
+	c.Assert(service.asyncCopies, chk.Equals, 0)
+	c.Assert(service.polls, chk.Equals, 0)
+	c.Assert(service.copySources, chk.DeepEquals, []string{source}) // the SAS goes to the service, so that it can read the source
+}
+
+func (s *blobToBlobSuite) TestLargeBlobIsCopiedAsynchronouslyAndPolled(c *chk.C) {
+	service := &copyBlobService{pendingPolls: 2, finalStatus: azblob.CopyStatusSuccess}
+	server := httptest.NewServer(service)
+	defer server.Close()
+
+	jptm := newServerSideCopyTransferMgr("https://source.blob.core.windows.net/container/blob?sig=secret", server.URL+"/container/blob", syncServerSideCopyMaxSize+1)
+	s.copy(jptm)
+
+	c.Assert(jptm.status, chk.Equals, common.ETransferStatus.Success())
+	c.Assert(service.syncCopies, chk.Equals, 0)
+	c.Assert(service.asyncCopies, chk.Equals, 1)
+	c.Assert(service.polls, chk.Equals, 3) // two while pending, then one that sees the copy has finished
+}
+
+func (s *blobToBlobSuite) TestPageBlobIsCopiedAsynchronously(c *chk.C) {
+	service := &copyBlobService{finalStatus: azblob.CopyStatusSuccess}
+	server := httptest.NewServer(service)
+	defer server.Close()
+
+	jptm := newServerSideCopyTransferMgr("https://source.blob.core.windows.net/container/disk.vhd?sig=secret", server.URL+"/container/disk.vhd", 512)
+	jptm.info.SrcBlobType = azblob.BlobPageBlob
+	s.copy(jptm)
+
+	c.Assert(jptm.status, chk.Equals, common.ETransferStatus.Success())
+	c.Assert(service.asyncCopies, chk.Equals, 1)
+}
+
+func (s *blobToBlobSuite) TestFailedAsyncCopyFailsTheTransfer(c *chk.C) {
+	service := &copyBlobService{pendingPolls: 1, finalStatus: azblob.CopyStatusFailed}
+	server := httptest.NewServer(service)
+	defer server.Close()
+
+	jptm := newServerSideCopyTransferMgr("https://source.blob.core.windows.net/container/blob?sig=secret", server.URL+"/container/blob", syncServerSideCopyMaxSize+1)
+	s.copy(jptm)
+
+	c.Assert(jptm.done, chk.Equals, true)
+	c.Assert(jptm.status, chk.Equals, common.ETransferStatus.Failed())
+	c.Assert(jptm.copyError, chk.Equals, "the server-side copy failed: 500 InternalError")
+}
+
+func (s *blobToBlobSuite) TestSourceWithoutSAS(c *chk.C) {
+	// within one account, the service can read the source with the destination's credential, but only asynchronously
+	service := &copyBlobService{finalStatus: azblob.CopyStatusSuccess}
+	server := httptest.NewServer(service)
+	defer server.Close()
+
+	jptm := newServerSideCopyTransferMgr(server.URL+"/container/source", server.URL+"/container/blob", 1024)
+	s.copy(jptm)
+	c.Assert(jptm.status, chk.Equals, common.ETransferStatus.Success())
+	c.Assert(service.syncCopies, chk.Equals, 0)
+	c.Assert(service.asyncCopies, chk.Equals, 1)
+
+	// from another account, nothing can authorize the read
+	service = &copyBlobService{}
+	jptm = newServerSideCopyTransferMgr("https://source.blob.core.windows.net/container/blob", server.URL+"/container/blob", 1024)
+	s.copy(jptm)
+	c.Assert(jptm.status, chk.Equals, common.ETransferStatus.Failed())
+	c.Assert(jptm.copyError, chk.Matches, ".*needs a SAS token on the source URL")
+	c.Assert(service.copySources, chk.HasLen, 0)
+}
+
+func (s *blobToBlobSuite) TestExistingBlobIsSkippedWithoutOverwrite(c *chk.C) {
+	service := &copyBlobService{destExists: true}
+	server := httptest.NewServer(service)
+	defer server.Close()
+
+	jptm := newServerSideCopyTransferMgr("https://source.blob.core.windows.net/container/blob?sig=secret", server.URL+"/container/blob", 1024)
+	jptm.overwrite = common.EOverwriteOption.False()
+	s.copy(jptm)
+
+	c.Assert(jptm.done, chk.Equals, true)
+	c.Assert(jptm.status, chk.Equals, common.ETransferStatus.SkippedEntityAlreadyExists())
+	c.Assert(service.copySources, chk.HasLen, 0)
+}
+
+func (s *blobToBlobSuite) TestCancellationAbortsThePendingCopy(c *chk.C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	service := &copyBlobService{pendingPolls: 1000, onPoll: cancel}
+	server := httptest.NewServer(service)
+	defer server.Close()
+
+	jptm := newServerSideCopyTransferMgr("https://source.blob.core.windows.net/container/blob?sig=secret", server.URL+"/container/blob", syncServerSideCopyMaxSize+1)
+	jptm.ctx = ctx
+	s.copy(jptm)
+
+	c.Assert(jptm.done, chk.Equals, true)
+	c.Assert(jptm.status, chk.Equals, common.ETransferStatus.Cancelled())
+	c.Assert(service.aborts, chk.Equals, 1)
+}
+
+func (s *blobToBlobSuite) newServiceWithSource() *copyBlobService {
+	return &copyBlobService{source: fakeBlobProperties{
+		headers: http.Header{
+			"Content-Type":     []string{"text/csv"},
+			"Content-Language": []string{"en"},
+			"Cache-Control":    []string{"max-age=60"},
+		},
+		metadata: map[string]string{"origin": "export"},
+	}}
+}
+
+func (s *blobToBlobSuite) TestCopyPreservesSourceProperties(c *chk.C) {
+	service := s.newServiceWithSource()
+	server := httptest.NewServer(service)
+	defer server.Close()
+
+	jptm := newServerSideCopyTransferMgr(server.URL+"/container/source?sig=secret", server.URL+"/container/blob", 1024)
+	s.copy(jptm)
+
+	c.Assert(jptm.status, chk.Equals, common.ETransferStatus.Success())
+	c.Assert(service.dest.headers, chk.DeepEquals, service.source.headers)
+	c.Assert(service.dest.metadata, chk.DeepEquals, map[string]string{"origin": "export"})
+}
+
+func (s *blobToBlobSuite) TestCopyReplacesGivenProperties(c *chk.C) {
+	for _, size := range []int64{1024, syncServerSideCopyMaxSize + 1} { // both the sync and the async copy
+		service := s.newServiceWithSource()
+		service.finalStatus = azblob.CopyStatusSuccess
+		server := httptest.NewServer(service)
+
+		jptm := newServerSideCopyTransferMgr(server.URL+"/container/source?sig=secret", server.URL+"/container/blob", size)
+		jptm.replaceProperties = true
+		jptm.userHeaders = common.ResourceHTTPHeaders{ContentType: "application/json", CacheControl: "no-cache"}
+		jptm.userMetadata = common.Metadata{"team": "data"}
+		s.copy(jptm)
+		server.Close()
+
+		c.Assert(jptm.status, chk.Equals, common.ETransferStatus.Success(), chk.Commentf("size %d", size))
+		c.Assert(service.dest.headers, chk.DeepEquals, http.Header{
+			"Content-Type":     []string{"application/json"},
+			"Cache-Control":    []string{"no-cache"},
+			"Content-Language": []string{"en"}, // not given, so kept from the source
+		})
+		c.Assert(service.dest.metadata, chk.DeepEquals, map[string]string{"team": "data"})
+	}
+}
+
+func (s *blobToBlobSuite) TestReplacingWithOnlyHeadersKeepsSourceMetadata(c *chk.C) {
+	service := s.newServiceWithSource()
+	server := httptest.NewServer(service)
+	defer server.Close()
+
+	jptm := newServerSideCopyTransferMgr(server.URL+"/container/source?sig=secret", server.URL+"/container/blob", 1024)
+	jptm.replaceProperties = true
+	jptm.userHeaders = common.ResourceHTTPHeaders{ContentType: "application/json"}
+	s.copy(jptm)
+
+	c.Assert(jptm.status, chk.Equals, common.ETransferStatus.Success())
+	c.Assert(service.dest.headers.Get("Content-Type"), chk.Equals, "application/json")
+	c.Assert(service.dest.metadata, chk.DeepEquals, map[string]string{"origin": "export"})
+}
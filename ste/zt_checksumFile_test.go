@@ -0,0 +1,184 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	chk "gopkg.in/check.v1"
+)
+
+type checksumFileSuite struct{}
+
+var _ = chk.Suite(&checksumFileSuite{})
+
+var md5sumLinePattern = regexp.MustCompile(`^(\\?)([0-9a-f]{32})  (.+)$`)
+
+// verifyChecksumFile checks every line of the checksum file the way md5sum -c does, against the files in dir,
+// and returns the names of the files that were checked
+func verifyChecksumFile(c *chk.C, checksumFile, dir string) []string {
+	f, err := os.Open(checksumFile)
+	c.Assert(err, chk.IsNil)
+	defer f.Close()
+
+	var names []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		m := md5sumLinePattern.FindStringSubmatch(scanner.Text())
+		c.Assert(m, chk.NotNil, chk.Commentf("line %q isn't in the format of md5sum", scanner.Text()))
+		name := m[3]
+		if m[1] == `\` {
+			name = strings.NewReplacer(`\\`, `\`, `\n`, "\n").Replace(name)
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		c.Assert(err, chk.IsNil)
+		hash := md5.Sum(content)
+		c.Assert(m[2], chk.Equals, hex.EncodeToString(hash[:]), chk.Commentf("hash of %s", name))
+		names = append(names, name)
+	}
+	c.Assert(scanner.Err(), chk.IsNil)
+	return names
+}
+
+func (s *checksumFileSuite) TestConcurrentLinesAreWrittenWhole(c *chk.C) {
+	dir := c.MkDir()
+	checksumFile := filepath.Join(c.MkDir(), "checksums.md5")
+	c.Assert(ioutil.WriteFile(checksumFile, []byte("d41d8cd98f00b204e9800998ecf8427e  from-an-earlier-job\n"), 0644), chk.IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "from-an-earlier-job"), nil, 0644), chk.IsNil)
+	w := newChecksumFileWriter(checksumFile)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("folder%d/file%d.txt", i%5, i)
+			content := []byte(strings.Repeat(name, i))
+			c.Check(os.MkdirAll(filepath.Join(dir, fmt.Sprintf("folder%d", i%5)), 0755), chk.IsNil)
+			c.Check(ioutil.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), content, 0644), chk.IsNil)
+			hash := md5.Sum(content)
+			w.Add(hash[:], name)
+		}(i)
+	}
+	wg.Wait()
+	c.Assert(w.Close(), chk.IsNil)
+
+	names := verifyChecksumFile(c, checksumFile, dir)
+	c.Assert(names, chk.HasLen, 201) // the file is appended to
+	c.Assert(names[0], chk.Equals, "from-an-earlier-job")
+
+	// the real md5sum must agree, where there is one
+	if md5sum, err := exec.LookPath("md5sum"); err == nil {
+		cmd := exec.Command(md5sum, "-c", "--quiet", checksumFile)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		c.Assert(err, chk.IsNil, chk.Commentf("%s", out))
+	}
+}
+
+func (s *checksumFileSuite) TestChecksumLine(c *chk.C) {
+	hash := md5.Sum([]byte("data"))
+	hexHash := hex.EncodeToString(hash[:])
+
+	c.Assert(checksumLine(hash[:], "a/b c.txt"), chk.Equals, hexHash+"  a/b c.txt\n")
+	// escaped the way md5sum does it, so that each file is still one line
+	c.Assert(checksumLine(hash[:], "odd\\name\nhere"), chk.Equals, `\`+hexHash+`  odd\\name\nhere`+"\n")
+}
+
+func (s *checksumFileSuite) TestChecksumRelativePath(c *chk.C) {
+	root := filepath.Join(c.MkDir(), "dest")
+	c.Assert(checksumRelativePath(root, filepath.Join(root, "container", "dir", "file.txt")), chk.Equals, "container/dir/file.txt")
+	// a single file downloaded to the destination itself
+	c.Assert(checksumRelativePath(filepath.Join(root, "file.txt"), filepath.Join(root, "file.txt")), chk.Equals, "file.txt")
+}
+
+func (s *checksumFileSuite) TestWriteErrorIsReportedOnClose(c *chk.C) {
+	w := newChecksumFileWriter(filepath.Join(c.MkDir(), "no-such-folder", "checksums.md5"))
+	hash := md5.Sum(nil)
+	w.Add(hash[:], "file")
+	c.Assert(w.Close(), chk.NotNil)
+}
+
+func (s *checksumFileSuite) TestJobClosesItsChecksumFile(c *chk.C) {
+	dir := c.MkDir()
+	checksumFile := filepath.Join(c.MkDir(), "checksums.md5")
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "first"), []byte("first"), 0644), chk.IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "second"), []byte("second"), 0644), chk.IsNil)
+	jm := &jobMgr{}
+
+	hash := md5.Sum([]byte("first"))
+	jm.checksumFileWriter(checksumFile).Add(hash[:], "first")
+	jm.closeChecksumFile()
+	c.Assert(jm.checksumFile, chk.IsNil)
+	c.Assert(verifyChecksumFile(c, checksumFile, dir), chk.DeepEquals, []string{"first"})
+
+	// when the job is resumed, the file is opened again, and added to
+	hash = md5.Sum([]byte("second"))
+	jm.checksumFileWriter(checksumFile).Add(hash[:], "second")
+	jm.closeChecksumFile()
+	c.Assert(verifyChecksumFile(c, checksumFile, dir), chk.DeepEquals, []string{"first", "second"})
+
+	// closing it again does nothing
+	jm.closeChecksumFile()
+}
+
+func (s *checksumFileSuite) TestDownloadWritesItsChecksum(c *chk.C) {
+	content := []byte("0123456789abcdefghij")
+	blob := newTestBlobWithContent(content, `"etag1"`)
+	defer blob.Close()
+	dir := c.MkDir()
+	checksumFile := filepath.Join(c.MkDir(), "checksums.md5")
+
+	// the source has no MD5 to check against, so the hash is only computed for the checksum file
+	jptm := &testTransferMgr{
+		ctx:       context.Background(),
+		status:    common.ETransferStatus.Started(),
+		checksums: newChecksumFileWriter(checksumFile),
+		info: TransferInfo{
+			Source:      blob.URL + "/container/file",
+			Destination: filepath.Join(dir, "file"),
+			SourceSize:  int64(len(content)),
+			BlockSize:   4,
+			SrcBlobType: azblob.BlobBlockBlob,
+		},
+	}
+	startTestDownload(jptm)
+	jptm.runChunks()
+	c.Assert(jptm.finished, chk.Equals, true)
+	c.Assert(jptm.failure, chk.IsNil)
+
+	c.Assert(jptm.checksums.Close(), chk.IsNil)
+	c.Assert(verifyChecksumFile(c, checksumFile, dir), chk.DeepEquals, []string{"file"})
+}
@@ -0,0 +1,124 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	chk "gopkg.in/check.v1"
+)
+
+type chunkInterleaverSuite struct{}
+
+var _ = chk.Suite(&chunkInterleaverSuite{})
+
+// newGatedChunkChannel returns a send func that waits for the gate to open before putting each chunk in the channel.
+// That lets a test queue up chunks before the interleaver can pass any of them on.
+func newGatedChunkChannel() (send func(chunkFunc), chunkCh chan chunkFunc, gate chan struct{}) {
+	chunkCh = make(chan chunkFunc, 1000)
+	gate = make(chan struct{})
+	send = func(cf chunkFunc) {
+		<-gate
+		chunkCh <- cf
+	}
+	return
+}
+
+// runChunks takes count chunks from the channel, in order, and runs each of them
+func runChunks(c *chk.C, chunkCh chan chunkFunc, count int) {
+	for i := 0; i < count; i++ {
+		select {
+		case cf := <-chunkCh:
+			cf(0)
+		case <-time.After(5 * time.Second):
+			c.Fatalf("only %d of %d chunks reached the channel", i, count)
+		}
+	}
+}
+
+func (s *chunkInterleaverSuite) TestChunksOfTwoLargeFilesAreInterleaved(c *chk.C) {
+	send, chunkCh, gate := newGatedChunkChannel()
+	ci := newChunkInterleaver(send)
+
+	ran := make([]string, 0)
+	namedChunk := func(name string) chunkFunc {
+		return func(int) { ran = append(ran, name) }
+	}
+
+	// the big file schedules all its chunks before the other file gets going, as happens when a huge file
+	// is the first to be picked up
+	const chunksPerFile = 10
+	for i := 0; i < chunksPerFile; i++ {
+		ci.Schedule("bigFile", namedChunk(fmt.Sprintf("big%d", i)))
+	}
+	for i := 0; i < chunksPerFile; i++ {
+		ci.Schedule("otherFile", namedChunk(fmt.Sprintf("other%d", i)))
+	}
+	close(gate)
+	runChunks(c, chunkCh, 2*chunksPerFile)
+
+	expected := make([]string, 0)
+	for i := 0; i < chunksPerFile; i++ {
+		expected = append(expected, fmt.Sprintf("big%d", i), fmt.Sprintf("other%d", i))
+	}
+	c.Assert(ran, chk.DeepEquals, expected)
+}
+
+func (s *chunkInterleaverSuite) TestSchedulingBlocksWhenTransferHasTooManyChunksWaiting(c *chk.C) {
+	send, chunkCh, gate := newGatedChunkChannel()
+	ci := newChunkInterleaver(send)
+	noop := func(int) {}
+
+	// one more than the limit, since the interleaver may already be holding the first one, waiting to send it
+	for i := 0; i < maxInterleavedChunksPerTransfer+1; i++ {
+		ci.Schedule("file", noop)
+	}
+
+	scheduled := make(chan struct{})
+	go func() {
+		ci.Schedule("file", noop)
+		ci.Schedule("file", noop)
+		close(scheduled)
+	}()
+	select {
+	case <-scheduled:
+		c.Fatal("scheduling didn't wait for the queued chunks to be sent")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	close(gate)
+	select {
+	case <-scheduled:
+	case <-time.After(5 * time.Second):
+		c.Fatal("scheduling didn't resume once the queued chunks were sent")
+	}
+	runChunks(c, chunkCh, maxInterleavedChunksPerTransfer+3)
+}
+
+func (s *chunkInterleaverSuite) TestChunksGoStraightInWithoutInterleaving(c *chk.C) {
+	ja := &jobsAdmin{xferChannels: XferChannels{normalChunckCh: make(chan chunkFunc, 1), lowChunkCh: make(chan chunkFunc, 1)}}
+
+	ja.ScheduleTransferChunk(common.EJobPriority.Normal(), nil, func(int) {})
+
+	c.Assert(len(ja.xferChannels.normalChunckCh), chk.Equals, 1)
+}
@@ -0,0 +1,147 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package ste
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	chk "gopkg.in/check.v1"
+)
+
+type chunkRetrySuite struct{}
+
+var _ = chk.Suite(&chunkRetrySuite{})
+
+// retryLoggingTransferMgr counts the chunk retries that get logged
+type retryLoggingTransferMgr struct {
+	testTransferMgr
+	retriesLogged int
+}
+
+func (t *retryLoggingTransferMgr) LogAtLevelForCurrentTransfer(level pipeline.LogLevel, _ string) {
+	if level == pipeline.LogWarning {
+		t.retriesLogged++
+	}
+}
+
+// flakyBlockServer fails the first few attempts to stage each block with the given status, and remembers the blocks that got through
+type flakyBlockServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	attempts map[string]int
+	staged   map[string]int
+}
+
+func newFlakyBlockServer(failuresPerBlock, failureStatus int) *flakyBlockServer {
+	s := &flakyBlockServer{attempts: map[string]int{}, staged: map[string]int{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		if req.URL.Query().Get("comp") != "block" {
+			w.WriteHeader(http.StatusNotFound) // there's no earlier attempt to find
+			return
+		}
+		blockID := req.URL.Query().Get("blockid")
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.attempts[blockID]++
+		if s.attempts[blockID] <= failuresPerBlock {
+			w.WriteHeader(failureStatus)
+			return
+		}
+		s.staged[blockID] = len(body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	return s
+}
+
+// stageBlocks runs a two-block upload's chunk funcs against the server
+func (s *chunkRetrySuite) stageBlocks(c *chk.C, server *flakyBlockServer, retries int) *retryLoggingTransferMgr {
+	defer func(old int) { UploadChunkRetries = old }(UploadChunkRetries)
+	UploadChunkRetries = retries
+
+	content := make([]byte, 2*1024)
+	srcPath := filepath.Join(c.MkDir(), "blocks.bin")
+	c.Assert(ioutil.WriteFile(srcPath, content, 0644), chk.IsNil)
+
+	jptm := &retryLoggingTransferMgr{testTransferMgr: testTransferMgr{info: TransferInfo{Source: srcPath, SourceSize: int64(len(content))}}}
+	uploader, err := newTestBlockBlobUploader(jptm, server.URL+"/container/blocks.bin")
+	c.Assert(err, chk.IsNil)
+	uploader.chunkSize = 1024
+	uploader.numChunks = 2
+	uploader.blockIDs = make([]string, 2)
+
+	srcFile, err := os.Open(srcPath)
+	c.Assert(err, chk.IsNil)
+	defer srcFile.Close()
+	factory := func() (common.CloseableReaderAt, error) { return os.Open(srcPath) }
+
+	scheduleSendChunks(jptm, srcPath, srcFile, int64(len(content)), uploader, factory, localSourceInfoProvider{})
+	c.Assert(jptm.chunks, chk.HasLen, 2)
+	for _, cf := range jptm.chunks {
+		cf(0)
+	}
+	return jptm
+}
+
+func (s *chunkRetrySuite) TestFlakyBlockIsStagedAgain(c *chk.C) {
+	server := newFlakyBlockServer(2, http.StatusInternalServerError)
+	defer server.Close()
+
+	jptm := s.stageBlocks(c, server, 3)
+	c.Assert(jptm.failure, chk.IsNil)
+	c.Assert(jptm.retriesLogged, chk.Equals, 4) // two for each block
+	c.Assert(server.staged, chk.HasLen, 2)
+	for blockID, length := range server.staged {
+		c.Assert(server.attempts[blockID], chk.Equals, 3)
+		c.Assert(length, chk.Equals, 1024) // the whole block was sent again, not just what was left of it
+	}
+}
+
+func (s *chunkRetrySuite) TestTransferFailsWhenRetriesRunOut(c *chk.C) {
+	server := newFlakyBlockServer(2, http.StatusInternalServerError)
+	defer server.Close()
+
+	jptm := s.stageBlocks(c, server, 1)
+	c.Assert(jptm.failure, chk.NotNil)
+	c.Assert(server.staged, chk.HasLen, 0)
+	for _, attempts := range server.attempts {
+		c.Assert(attempts, chk.Equals, 2)
+	}
+}
+
+func (s *chunkRetrySuite) TestPermanentFailureIsNotStagedAgain(c *chk.C) {
+	server := newFlakyBlockServer(1, http.StatusBadRequest)
+	defer server.Close()
+
+	jptm := s.stageBlocks(c, server, 3)
+	c.Assert(jptm.failure, chk.NotNil)
+	c.Assert(jptm.retriesLogged, chk.Equals, 0)
+	c.Assert(server.staged, chk.HasLen, 0)
+	for _, attempts := range server.attempts {
+		c.Assert(attempts, chk.Equals, 1)
+	}
+}
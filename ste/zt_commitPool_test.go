@@ -0,0 +1,251 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+type commitPoolSuite struct{}
+
+var _ = chk.Suite(&commitPoolSuite{})
+
+// commitRecordingTransferMgr remembers the epilogues that are handed to the commit pool, instead of running them
+type commitRecordingTransferMgr struct {
+	testTransferMgr
+	commits []func()
+}
+
+func (t *commitRecordingTransferMgr) ScheduleCommit(commitFunc func()) {
+	t.commits = append(t.commits, commitFunc)
+}
+
+// pageBlobLikeSender is a sender that has no block list to commit
+type pageBlobLikeSender struct {
+	sender
+}
+
+func (s *commitPoolSuite) TestMultiBlockEpilogueGoesToCommitPool(c *chk.C) {
+	jptm := &commitRecordingTransferMgr{}
+	uploader, err := newTestBlockBlobUploader(jptm, "https://account.blob.core.windows.net/container/blob")
+	c.Assert(err, chk.IsNil)
+
+	epilogueRuns := 0
+	action := actionAfterLastChunk(jptm, uploader, 3, func() { epilogueRuns++ })
+	action()
+	c.Assert(epilogueRuns, chk.Equals, 0) // the worker that sent the last block doesn't wait for the commit
+	c.Assert(jptm.commits, chk.HasLen, 1)
+
+	jptm.commits[0]()
+	c.Assert(epilogueRuns, chk.Equals, 1)
+}
+
+func (s *commitPoolSuite) TestEpilogueRunsInlineWhenThereIsNoBlockListToCommit(c *chk.C) {
+	jptm := &commitRecordingTransferMgr{}
+	uploader, err := newTestBlockBlobUploader(jptm, "https://account.blob.core.windows.net/container/blob")
+	c.Assert(err, chk.IsNil)
+
+	cases := []struct {
+		s         sender
+		numChunks uint32
+	}{
+		{uploader, 1},             // sent with Put Blob, so there's no block list
+		{pageBlobLikeSender{}, 3}, // some other kind of blob
+	}
+	for _, x := range cases {
+		epilogueRuns := 0
+		actionAfterLastChunk(jptm, x.s, x.numChunks, func() { epilogueRuns++ })()
+		c.Assert(epilogueRuns, chk.Equals, 1)
+	}
+	c.Assert(jptm.commits, chk.HasLen, 0)
+}
+
+func (s *commitPoolSuite) TestScheduleCommitRunsInlineWhenPoolIsOff(c *chk.C) {
+	ja := &jobsAdmin{
+		concurrency:  ConcurrencySettings{CommitPoolSize: &ConfiguredInt{Value: 0}},
+		xferChannels: XferChannels{commitCh: make(chan func(), 1)},
+	}
+	ran := false
+	ja.ScheduleCommit(func() { ran = true })
+	c.Assert(ran, chk.Equals, true)
+	c.Assert(ja.xferChannels.commitCh, chk.HasLen, 0)
+
+	ja.concurrency.CommitPoolSize.Value = 1
+	ran = false
+	ja.ScheduleCommit(func() { ran = true })
+	c.Assert(ran, chk.Equals, false) // queued, for the commit pool to run
+	(<-ja.xferChannels.commitCh)()
+	c.Assert(ran, chk.Equals, true)
+}
+
+// The benchmark uploads a set of files, each a few blocks long, with the block lists committed by the main pool
+// (as they used to be) and by the commit pool. Run it with:
+//
+//	go test ./ste -run XXX -bench MultiBlockCommit -benchtime 1x
+const commitBenchmarkFileCount = 5000
+const commitBenchmarkBlocksPerFile = 4
+const commitBenchmarkBlockSize = 4 * 1024
+
+// commitBenchmarkLatency stands in for the round trip to the service, for each Put Block and Put Block List
+const commitBenchmarkLatency = 5 * time.Millisecond
+
+// commitBenchmarkTransferMgr counts its chunks, runs its epilogue after the last one, and hands it to the commit pool when asked
+type commitBenchmarkTransferMgr struct {
+	pooledTransferMgr
+	commitCh         chan func()
+	atomicChunksDone uint32
+	afterLastChunk   func()
+}
+
+func (t *commitBenchmarkTransferMgr) FromTo() common.FromTo                                      { return common.EFromTo.LocalBlob() }
+func (t *commitBenchmarkTransferMgr) ScheduleCommit(commitFunc func())                           { t.commitCh <- commitFunc }
+func (t *commitBenchmarkTransferMgr) LogAtLevelForCurrentTransfer(_ pipeline.LogLevel, _ string) {}
+func (t *commitBenchmarkTransferMgr) ReportChunkDone(common.ChunkID) (bool, uint32) {
+	done := atomic.AddUint32(&t.atomicChunksDone, 1)
+	if done == commitBenchmarkBlocksPerFile {
+		t.afterLastChunk()
+	}
+	return done == commitBenchmarkBlocksPerFile, done
+}
+
+func BenchmarkMultiBlockCommit(b *testing.B) {
+	dir := b.TempDir()
+	content := make([]byte, commitBenchmarkBlocksPerFile*commitBenchmarkBlockSize)
+	files := make([]string, commitBenchmarkFileCount)
+	for i := range files {
+		files[i] = filepath.Join(dir, fmt.Sprintf("file%05d", i))
+		if err := ioutil.WriteFile(files[i], content, 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = ioutil.ReadAll(req.Body)
+		time.Sleep(commitBenchmarkLatency)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	b.Run("mainPool", func(b *testing.B) { benchmarkMultiBlockCommit(b, server.URL, files, false) })
+	b.Run("commitPool", func(b *testing.B) { benchmarkMultiBlockCommit(b, server.URL, files, true) })
+}
+
+func benchmarkMultiBlockCommit(b *testing.B, serverURL string, files []string, useCommitPool bool) {
+	mainPoolSize, _, _ := getMainPoolSize(runtime.NumCPU(), false)
+
+	for n := 0; n < b.N; n++ {
+		pools := &benchmarkPools{
+			mainCh:       make(chan chunkFunc, len(files)*commitBenchmarkBlocksPerFile),
+			slicePool:    common.NewMultiSizeSlicePool(common.MaxBlockBlobBlockSize),
+			cacheLimiter: common.NewCacheLimiter(getMaxRamForChunks()),
+		}
+		commitCh := make(chan func(), len(files))
+		pools.filesDone.Add(len(files))
+		for i := 0; i < mainPoolSize; i++ {
+			go func(workerID int) {
+				for cf := range pools.mainCh {
+					cf(workerID)
+				}
+			}(i)
+		}
+		for i := 0; i < defaultCommitPoolSize; i++ {
+			go func() {
+				for commitFunc := range commitCh {
+					commitFunc()
+				}
+			}()
+		}
+
+		start := time.Now()
+		toInitiate := make(chan string, len(files))
+		for _, f := range files {
+			toInitiate <- f
+		}
+		close(toInitiate)
+		for i := 0; i < defaultTransferInitiationPoolSize; i++ {
+			go func() {
+				for srcPath := range toInitiate {
+					initiateMultiBlockUpload(pools, commitCh, serverURL, srcPath, useCommitPool)
+				}
+			}()
+		}
+
+		pools.filesDone.Wait()
+		elapsed := time.Since(start)
+		close(pools.mainCh)
+		close(commitCh)
+		if pools.firstErr != nil {
+			b.Fatal(pools.firstErr)
+		}
+		b.ReportMetric(float64(len(files))/elapsed.Seconds(), "files/s")
+	}
+}
+
+// initiateMultiBlockUpload does what anyToRemote_file does for a local file that is sent in several blocks
+func initiateMultiBlockUpload(pools *benchmarkPools, commitCh chan func(), serverURL string, srcPath string, useCommitPool bool) {
+	size := int64(commitBenchmarkBlocksPerFile * commitBenchmarkBlockSize)
+	jptm := &commitBenchmarkTransferMgr{
+		pooledTransferMgr: pooledTransferMgr{
+			testTransferMgr: testTransferMgr{info: TransferInfo{Source: srcPath, SourceSize: size}},
+			pools:           pools,
+		},
+		commitCh: commitCh,
+	}
+	uploader, err := newTestBlockBlobUploader(jptm, serverURL+"/container/"+filepath.Base(srcPath))
+	if err != nil {
+		panic(err)
+	}
+	uploader.chunkSize = commitBenchmarkBlockSize
+	uploader.numChunks = commitBenchmarkBlocksPerFile
+	uploader.blockIDs = make([]string, commitBenchmarkBlocksPerFile)
+
+	epilogue := func() {
+		uploader.Epilogue()
+		pools.filesDone.Done()
+	}
+	if useCommitPool {
+		jptm.afterLastChunk = actionAfterLastChunk(jptm, uploader, commitBenchmarkBlocksPerFile, epilogue)
+	} else {
+		jptm.afterLastChunk = epilogue
+	}
+
+	srcFile, err := os.Open(srcPath)
+	if err != nil {
+		panic(err)
+	}
+	defer srcFile.Close()
+	factory := func() (common.CloseableReaderAt, error) { return os.Open(srcPath) }
+	scheduleSendChunks(jptm, srcPath, srcFile, size, uploader, factory, localSourceInfoProvider{})
+}
@@ -0,0 +1,110 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package ste
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	chk "gopkg.in/check.v1"
+)
+
+type decompressDownloadSuite struct{}
+
+var _ = chk.Suite(&decompressDownloadSuite{})
+
+// gzipBlobTransferMgr downloads a blob stored with Content-Encoding: gzip, decompressing it if told to
+type gzipBlobTransferMgr struct {
+	testTransferMgr
+	decompress bool
+}
+
+func (t *gzipBlobTransferMgr) ShouldDecompress() bool { return t.decompress }
+func (t *gzipBlobTransferMgr) GetSourceCompressionType() (common.CompressionType, error) {
+	return common.ECompressionType.GZip(), nil
+}
+
+// downloadGzipBlob downloads gzipped content, in ranges much smaller than it, and returns what was written to disk
+func (s *decompressDownloadSuite) downloadGzipBlob(c *chk.C, gzipped []byte, decompress bool) []byte {
+	blob := newTestBlobWithContent(gzipped, `"0x1"`)
+	defer blob.Close()
+
+	md5Sum := md5.Sum(gzipped) // a stored hash is always of the data as it's stored, i.e. compressed
+	jptm := &gzipBlobTransferMgr{
+		decompress: decompress,
+		testTransferMgr: testTransferMgr{
+			ctx:    context.Background(),
+			status: common.ETransferStatus.Started(),
+			info: TransferInfo{
+				Source:      blob.URL + "/container/file",
+				Destination: filepath.Join(c.MkDir(), "file"),
+				SourceSize:  int64(len(gzipped)),
+				BlockSize:   64,
+				SrcBlobType: azblob.BlobBlockBlob,
+				SrcProperties: SrcProperties{SrcHTTPHeaders: common.ResourceHTTPHeaders{
+					ContentMD5:      md5Sum[:],
+					ContentEncoding: "gzip",
+				}},
+			},
+		},
+	}
+	startTestDownload(jptm)
+
+	// the compressed data is still fetched in ranges, and written (so decompressed) in order
+	c.Assert(len(jptm.chunks) > 1, chk.Equals, true)
+	jptm.runChunks()
+	c.Assert(jptm.finished, chk.Equals, true)
+	c.Assert(jptm.failure, chk.IsNil)
+	c.Assert(jptm.status, chk.Equals, common.ETransferStatus.Success())
+
+	written, err := ioutil.ReadFile(jptm.info.Destination)
+	c.Assert(err, chk.IsNil)
+	return written
+}
+
+func gzipOf(c *chk.C, data []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write(data)
+	c.Assert(err, chk.IsNil)
+	c.Assert(w.Close(), chk.IsNil)
+	return buf.Bytes()
+}
+
+func (s *decompressDownloadSuite) TestGzipBlobIsDecompressedWhenAskedTo(c *chk.C) {
+	original := []byte(strings.Repeat("gzip-encoded blobs can be decompressed on the way to disk. ", 100))
+	gzipped := gzipOf(c, original)
+
+	c.Assert(s.downloadGzipBlob(c, gzipped, true), chk.DeepEquals, original)
+}
+
+func (s *decompressDownloadSuite) TestGzipBlobIsKeptCompressedByDefault(c *chk.C) {
+	original := []byte(strings.Repeat("without --decompress the bytes are stored as they are. ", 100))
+	gzipped := gzipOf(c, original)
+
+	c.Assert(s.downloadGzipBlob(c, gzipped, false), chk.DeepEquals, gzipped)
+}
@@ -0,0 +1,141 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+type destinationSnapshotsSuite struct{}
+
+var _ = chk.Suite(&destinationSnapshotsSuite{})
+
+// newBlobWithSnapshotsServer refuses to replace its blob until the blob's snapshots have been deleted
+func newBlobWithSnapshotsServer() *testBlobServer {
+	var server *testBlobServer
+	server = newTestBlobServer(func(w http.ResponseWriter, r testRequest) {
+		_, snapshotsDeleted := server.lastRequest(http.MethodDelete, r.path)
+		switch {
+		case r.method == http.MethodDelete:
+			w.WriteHeader(http.StatusAccepted)
+		case r.method == http.MethodPut && !snapshotsDeleted:
+			writeStorageError(w, http.StatusConflict, string(azblob.ServiceCodeSnapshotsPresent), "This operation is not permitted because the blob has snapshots.")
+		default:
+			w.WriteHeader(http.StatusCreated)
+		}
+	})
+	return server
+}
+
+// deleteSnapshotsHeader is the x-ms-delete-snapshots header of the delete request
+func deleteSnapshotsHeader(server *testBlobServer) string {
+	r, _ := server.lastRequest(http.MethodDelete, "/container/blob")
+	return r.header.Get("x-ms-delete-snapshots")
+}
+
+// overwrite replaces the blob with an append blob, as a blob of a different type
+func (s *destinationSnapshotsSuite) overwrite(c *chk.C, deleteSnapshots common.DeleteSnapshotsOption) (*testBlobServer, *testTransferMgr, error) {
+	server := newBlobWithSnapshotsServer()
+	dest := server.blobURL(c, "blob").ToAppendBlobURL()
+
+	jptm := &testTransferMgr{deleteSnapshots: deleteSnapshots}
+	err := overwriteDespiteSnapshots(jptm, dest.BlobURL, func() error {
+		_, err := dest.Create(context.Background(), azblob.BlobHTTPHeaders{}, azblob.Metadata{}, azblob.BlobAccessConditions{}, nil, azblob.ClientProvidedKeyOptions{})
+		return err
+	})
+	return server, jptm, err
+}
+
+func (s *destinationSnapshotsSuite) TestIncludeDeletesBlobAndSnapshotsThenOverwrites(c *chk.C) {
+	server, _, err := s.overwrite(c, common.EDeleteSnapshotsOption.Include())
+	defer server.Close()
+
+	c.Assert(err, chk.IsNil)
+	c.Assert(server.methods(), chk.DeepEquals, []string{http.MethodPut, http.MethodDelete, http.MethodPut})
+	c.Assert(deleteSnapshotsHeader(server), chk.Equals, "include")
+}
+
+func (s *destinationSnapshotsSuite) TestOnlyDeletesSnapshotsThenOverwrites(c *chk.C) {
+	server, _, err := s.overwrite(c, common.EDeleteSnapshotsOption.Only())
+	defer server.Close()
+
+	c.Assert(err, chk.IsNil)
+	c.Assert(server.methods(), chk.DeepEquals, []string{http.MethodPut, http.MethodDelete, http.MethodPut})
+	c.Assert(deleteSnapshotsHeader(server), chk.Equals, "only")
+}
+
+func (s *destinationSnapshotsSuite) TestByDefaultTheFailureNamesTheFlag(c *chk.C) {
+	server, jptm, err := s.overwrite(c, common.EDeleteSnapshotsOption.None())
+	defer server.Close()
+
+	// nothing is deleted
+	c.Assert(isSnapshotsPresent(err), chk.Equals, true)
+	c.Assert(server.methods(), chk.DeepEquals, []string{http.MethodPut})
+	c.Assert(jptm.logs, chk.HasLen, 1)
+	c.Assert(jptm.logs[0], chk.Matches, `OVERWRITE FAILED\(blob has snapshots, see --delete-snapshots\): http://.*/container/blob`)
+}
+
+// newStagedBlobWithSnapshotsServer has blocks staged on its blob, which has snapshots. Like the service, it refuses to
+// commit the blocks until the snapshots are deleted, and forgets them if the blob itself is deleted
+func newStagedBlobWithSnapshotsServer() *testBlobServer {
+	var server *testBlobServer
+	server = newTestBlobServer(func(w http.ResponseWriter, r testRequest) {
+		deleted, snapshotsDeleted := server.lastRequest(http.MethodDelete, r.path)
+		switch {
+		case r.method == http.MethodDelete:
+			w.WriteHeader(http.StatusAccepted)
+		case r.method == http.MethodPut && !snapshotsDeleted:
+			writeStorageError(w, http.StatusConflict, string(azblob.ServiceCodeSnapshotsPresent), "This operation is not permitted because the blob has snapshots.")
+		case r.method == http.MethodPut && deleted.header.Get("x-ms-delete-snapshots") == "include":
+			writeStorageError(w, http.StatusBadRequest, string(azblob.ServiceCodeInvalidBlockList), "The specified block list is invalid.")
+		default:
+			w.WriteHeader(http.StatusCreated)
+		}
+	})
+	return server
+}
+
+func (s *destinationSnapshotsSuite) TestCommitKeepsTheStagedBlocks(c *chk.C) {
+	server := newStagedBlobWithSnapshotsServer()
+	defer server.Close()
+
+	jptm := &testTransferMgr{status: common.ETransferStatus.Started(), deleteSnapshots: common.EDeleteSnapshotsOption.Include()}
+	sender := &blockBlobSenderBase{
+		jptm:                   jptm,
+		destBlockBlobURL:       server.blobURL(c, "blob").ToBlockBlobURL(),
+		muBlockIDs:             &sync.Mutex{},
+		blockIDs:               []string{common.NewUUID().String()},
+		atomicPutListIndicator: putListNeeded,
+	}
+	sender.Epilogue()
+
+	// with --delete-snapshots=include, the blob isn't deleted, since its staged blocks would go with it
+	c.Assert(jptm.failure, chk.IsNil)
+	c.Assert(server.methods(), chk.DeepEquals, []string{http.MethodPut, http.MethodDelete, http.MethodPut})
+	c.Assert(deleteSnapshotsHeader(server), chk.Equals, "only")
+}
@@ -0,0 +1,93 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// Directory uploads commonly include zero-length files (lock files, .gitkeep, placeholders).
+// These tests upload a mix of empty and non-empty local files through the real chunk scheduling
+// and make sure every one of them becomes a blob with the right content, hash and metadata.
+type emptyFileUploadSuite struct{}
+
+var _ = chk.Suite(&emptyFileUploadSuite{})
+
+// uploadFile runs a local file through scheduleSendChunks and the block blob uploader, as a directory upload would
+func (s *emptyFileUploadSuite) uploadFile(c *chk.C, server *testBlobServer, srcPath string) *testTransferMgr {
+	fi, err := os.Stat(srcPath)
+	c.Assert(err, chk.IsNil)
+	jptm := &testTransferMgr{info: TransferInfo{Source: srcPath, SourceSize: fi.Size()}}
+
+	uploader, err := newTestBlockBlobUploader(jptm, server.URL+"/container/"+filepath.Base(srcPath))
+	c.Assert(err, chk.IsNil)
+
+	srcFile, err := os.Open(srcPath)
+	c.Assert(err, chk.IsNil)
+	defer srcFile.Close()
+	factory := func() (common.CloseableReaderAt, error) { return os.Open(srcPath) }
+
+	scheduleSendChunks(jptm, srcPath, srcFile, fi.Size(), uploader, factory, localSourceInfoProvider{})
+	c.Assert(jptm.chunks, chk.HasLen, 1)
+	jptm.runChunks()
+	return jptm
+}
+
+func (s *emptyFileUploadSuite) TestDirectoryWithEmptyFiles(c *chk.C) {
+	dir := c.MkDir()
+	files := map[string]string{
+		".gitkeep":    "",
+		"a.lock":      "",
+		"readme.txt":  "hello",
+		"placeholder": "",
+		"data.csv":    "1,2,3\n4,5,6\n",
+	}
+	for name, content := range files {
+		c.Assert(ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644), chk.IsNil)
+	}
+
+	server := newTestBlobServer(nil)
+	defer server.Close()
+
+	for name, content := range files {
+		jptm := s.uploadFile(c, server, filepath.Join(dir, name))
+		c.Assert(jptm.failure, chk.IsNil, chk.Commentf(name))
+
+		expectedMD5 := md5.Sum([]byte(content))
+		c.Assert(jptm.contentMD5, chk.DeepEquals, expectedMD5[:], chk.Commentf(name))
+
+		blob, ok := server.lastRequest(http.MethodPut, "/container/"+name)
+		c.Assert(ok, chk.Equals, true, chk.Commentf("%s was never uploaded", name))
+		c.Assert(blob.body, chk.HasLen, len(content), chk.Commentf(name))
+		c.Assert(blob.header.Get("x-ms-blob-content-md5"), chk.Equals, base64.StdEncoding.EncodeToString(expectedMD5[:]), chk.Commentf(name))
+		c.Assert(blob.header.Get("x-ms-meta-origin"), chk.Equals, "local", chk.Commentf(name))
+	}
+	c.Assert(server.received(), chk.HasLen, len(files))
+}
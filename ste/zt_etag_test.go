@@ -0,0 +1,105 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// After a blob is written, the ETag the service gave it is recorded against the transfer, so that it can be listed later
+type etagSuite struct{}
+
+var _ = chk.Suite(&etagSuite{})
+
+const putBlobETag = `"0x8D9A0000000PUT"`
+const putBlockListETag = `"0x8D9A000000LIST"`
+
+// etagTransferMgr is a testTransferMgr that can also run the block blob epilogue
+type etagTransferMgr struct {
+	testTransferMgr
+}
+
+func (t *etagTransferMgr) FromTo() common.FromTo { return common.EFromTo.LocalBlob() }
+
+// newETagServer accepts blocks, block lists and whole blobs, and gives each blob it creates an ETag
+func newETagServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = ioutil.ReadAll(req.Body)
+		switch req.URL.Query().Get("comp") {
+		case "block":
+		case "blocklist":
+			w.Header().Set("ETag", putBlockListETag)
+		case "":
+			w.Header().Set("ETag", putBlobETag)
+		default:
+			w.WriteHeader(http.StatusNotFound) // e.g. there are no uncommitted blocks to list
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+}
+
+func (s *etagSuite) upload(c *chk.C, size int, numChunks uint32) *etagTransferMgr {
+	server := newETagServer()
+	defer server.Close()
+
+	srcPath := filepath.Join(c.MkDir(), "file.bin")
+	c.Assert(ioutil.WriteFile(srcPath, make([]byte, size), 0644), chk.IsNil)
+
+	jptm := &etagTransferMgr{testTransferMgr{info: TransferInfo{Source: srcPath, SourceSize: int64(size)}}}
+	uploader, err := newTestBlockBlobUploader(jptm, server.URL+"/container/file.bin")
+	c.Assert(err, chk.IsNil)
+	uploader.numChunks = numChunks
+	uploader.chunkSize = int64(size) / int64(numChunks)
+	uploader.blockIDs = make([]string, numChunks)
+
+	srcFile, err := os.Open(srcPath)
+	c.Assert(err, chk.IsNil)
+	defer srcFile.Close()
+	factory := func() (common.CloseableReaderAt, error) { return os.Open(srcPath) }
+
+	scheduleSendChunks(jptm, srcPath, srcFile, int64(size), uploader, factory, localSourceInfoProvider{})
+	c.Assert(jptm.chunks, chk.HasLen, int(numChunks))
+	for _, cf := range jptm.chunks {
+		cf(0)
+	}
+	uploader.Epilogue()
+	c.Assert(jptm.failure, chk.IsNil)
+	return jptm
+}
+
+func (s *etagSuite) TestETagOfPutBlobIsRecorded(c *chk.C) {
+	jptm := s.upload(c, 1024, 1)
+	c.Assert(jptm.etag, chk.Equals, putBlobETag)
+}
+
+func (s *etagSuite) TestETagOfPutBlockListIsRecorded(c *chk.C) {
+	jptm := s.upload(c, 2048, 2)
+	c.Assert(jptm.etag, chk.Equals, putBlockListETag)
+}
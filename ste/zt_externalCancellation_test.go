@@ -0,0 +1,137 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	chk "gopkg.in/check.v1"
+)
+
+type externalCancellationSuite struct{}
+
+var _ = chk.Suite(&externalCancellationSuite{})
+
+// planOnlyJobPartMgr is a part manager that only knows its plan, which is all the cancellation watcher looks at
+type planOnlyJobPartMgr struct {
+	IJobPartMgr
+	plan *JobPartPlanHeader
+}
+
+func (p *planOnlyJobPartMgr) Plan() *JobPartPlanHeader {
+	return p.plan
+}
+
+func (s *externalCancellationSuite) TestCheckForExternalCancellation(c *chk.C) {
+	jm := &jobMgr{
+		jobID:       common.NewJobID(),
+		jobPartMgrs: newJobPartToJobPartMgr(),
+		logger:      common.NewJobLogger(common.NewJobID(), common.ELogLevel.None(), "", ""),
+	}
+	cancelled := false
+	cancel := func() { cancelled = true }
+
+	// nothing to look at until part 0 exists
+	c.Assert(jm.checkForExternalCancellation(cancel), chk.Equals, true)
+
+	plan := &JobPartPlanHeader{}
+	jm.jobPartMgrs.Set(0, &planOnlyJobPartMgr{plan: plan})
+
+	plan.SetJobStatus(common.EJobStatus.InProgress())
+	c.Assert(jm.checkForExternalCancellation(cancel), chk.Equals, true)
+	c.Assert(cancelled, chk.Equals, false)
+
+	// another process cancels the job
+	plan.SetJobStatus(common.EJobStatus.Cancelling())
+	c.Assert(jm.checkForExternalCancellation(cancel), chk.Equals, false)
+	c.Assert(cancelled, chk.Equals, true)
+
+	// or pauses it
+	cancelled = false
+	plan.SetJobStatus(common.EJobStatus.Paused())
+	c.Assert(jm.checkForExternalCancellation(cancel), chk.Equals, false)
+	c.Assert(cancelled, chk.Equals, true)
+
+	// once the job is done, there's nothing left to cancel
+	cancelled = false
+	plan.SetJobStatus(common.EJobStatus.Completed())
+	c.Assert(jm.checkForExternalCancellation(cancel), chk.Equals, false)
+	c.Assert(cancelled, chk.Equals, false)
+}
+
+func (s *externalCancellationSuite) TestCancelStopsInflightUpload(c *chk.C) {
+	// the service accepts the upload's first block, but never answers
+	received, release := make(chan struct{}, 1), make(chan struct{})
+	server := newTestBlobServer(func(w http.ResponseWriter, r testRequest) {
+		received <- struct{}{}
+		<-release
+	})
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	jptm := &testTransferMgr{info: newThreeBlockTransfer(c), ctx: ctx, status: common.ETransferStatus.Started()}
+	uploader := scheduleThreeBlockUpload(c, server, jptm)
+	stopped := make(chan struct{})
+	go func() {
+		jptm.chunks[0](0)
+		close(stopped)
+	}()
+	<-received
+
+	// the job is cancelled while the block is in flight, so its request is abandoned
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		c.Fatal("the in-flight block was not abandoned when the job was cancelled")
+	}
+
+	// and the chunks that hadn't started never send anything
+	jptm.chunks[1](0)
+	jptm.chunks[2](0)
+	epilogueWithCleanupSendToRemote(jptm, uploader, localSourceInfoProvider{})
+	c.Assert(server.methods(), chk.DeepEquals, []string{http.MethodPut})
+	c.Assert(jptm.finished, chk.Equals, true)
+	c.Assert(jptm.status, chk.Equals, common.ETransferStatus.Cancelled())
+}
+
+func (s *externalCancellationSuite) TestResetStopsThePreviousWatcher(c *chk.C) {
+	jm := &jobMgr{jobID: common.NewJobID(), jobPartMgrs: newJobPartToJobPartMgr()}
+	before := runtime.NumGoroutine()
+
+	// each reset gives the job a new context, and starts watching that instead
+	for i := 0; i < 20; i++ {
+		jm.ctx, jm.cancel = context.WithCancel(context.Background())
+		jm.startWatchingForExternalCancellation()
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before+1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Assert(runtime.NumGoroutine() <= before+1, chk.Equals, true, chk.Commentf("%d goroutines, up from %d", runtime.NumGoroutine(), before))
+	jm.stopWatchingForExternalCancellation()
+}
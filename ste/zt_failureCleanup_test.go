@@ -0,0 +1,78 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"net/http"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// Senders delete a half-written destination when a transfer fails, so that nobody sees a partial blob.
+// These tests pin down when that cleanup does, and does not, happen.
+type failureCleanupSuite struct{}
+
+var _ = chk.Suite(&failureCleanupSuite{})
+
+// failedTransfer is a transfer that has failed, after touching its destination if it was inflight
+func failedTransfer(inflight bool) *testTransferMgr {
+	return &testTransferMgr{status: common.ETransferStatus.Failed(), destTouched: inflight}
+}
+
+func (s *failureCleanupSuite) TestBlockBlobDeletedAfterFailureWithStagedBlocks(c *chk.C) {
+	server := newTestBlobServer(nil)
+	defer server.Close()
+
+	sender := &blockBlobSenderBase{jptm: failedTransfer(true), destBlockBlobURL: server.blobURL(c, "blob").ToBlockBlobURL(), atomicChunksWritten: 3}
+	sender.Cleanup()
+	c.Assert(server.methods(), chk.DeepEquals, []string{http.MethodDelete})
+}
+
+func (s *failureCleanupSuite) TestBlockBlobLeftAloneWhenNothingWasStaged(c *chk.C) {
+	server := newTestBlobServer(nil)
+	defer server.Close()
+
+	sender := &blockBlobSenderBase{jptm: failedTransfer(true), destBlockBlobURL: server.blobURL(c, "blob").ToBlockBlobURL()}
+	sender.Cleanup()
+	c.Assert(server.methods(), chk.HasLen, 0)
+}
+
+func (s *failureCleanupSuite) TestAppendBlobDeletedAfterFailure(c *chk.C) {
+	server := newTestBlobServer(nil)
+	defer server.Close()
+
+	sender := &appendBlobSenderBase{jptm: failedTransfer(true), destAppendBlobURL: server.blobURL(c, "blob").ToAppendBlobURL()}
+	sender.Cleanup()
+	c.Assert(server.methods(), chk.DeepEquals, []string{http.MethodDelete})
+}
+
+func (s *failureCleanupSuite) TestNothingDeletedWhenTransferDiedBeforeWritingAnything(c *chk.C) {
+	// e.g. --overwrite=false found the destination already there, so the transfer never touched it
+	server := newTestBlobServer(nil)
+	defer server.Close()
+
+	jptm := failedTransfer(false)
+	(&appendBlobSenderBase{jptm: jptm, destAppendBlobURL: server.blobURL(c, "blob").ToAppendBlobURL()}).Cleanup()
+	(&blockBlobSenderBase{jptm: jptm, destBlockBlobURL: server.blobURL(c, "blob").ToBlockBlobURL(), atomicChunksWritten: 3}).Cleanup()
+	c.Assert(server.methods(), chk.HasLen, 0)
+}
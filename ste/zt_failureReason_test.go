@@ -0,0 +1,101 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	chk "gopkg.in/check.v1"
+)
+
+type failureReasonSuite struct{}
+
+var _ = chk.Suite(&failureReasonSuite{})
+
+// forbiddenBlobError gets a real azblob.StorageError, by talking to a server that refuses everything
+func forbiddenBlobError(c *chk.C) error {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-ms-error-code", "AuthorizationPermissionMismatch")
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`<?xml version="1.0" encoding="utf-8"?><Error><Code>AuthorizationPermissionMismatch</Code>` +
+			`<Message>This request is not authorized to perform this operation using this permission.</Message></Error>`))
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL + "/container/blob")
+	c.Assert(err, chk.IsNil)
+	p := azblob.NewPipeline(azblob.NewAnonymousCredential(), azblob.PipelineOptions{Retry: azblob.RetryOptions{MaxTries: 1}})
+	_, err = azblob.NewBlockBlobURL(*u, p).Upload(context.Background(), strings.NewReader("data"),
+		azblob.BlobHTTPHeaders{}, azblob.Metadata{}, azblob.BlobAccessConditions{}, azblob.AccessTierNone, nil, azblob.ClientProvidedKeyOptions{})
+	c.Assert(err, chk.NotNil)
+	return err
+}
+
+func (s *failureReasonSuite) TestTransferFailureReason(c *chk.C) {
+	reason := transferFailureReason(forbiddenBlobError(c))
+	c.Assert(strings.HasPrefix(reason, "403 "), chk.Equals, true, chk.Commentf("reason was %q", reason))
+	c.Assert(strings.HasSuffix(reason, "(AuthorizationPermissionMismatch)"), chk.Equals, true, chk.Commentf("reason was %q", reason))
+
+	// other errors are summarized by their first line
+	c.Assert(transferFailureReason(errors.New("disk full\nmore detail")), chk.Equals, "disk full")
+
+	c.Assert(failureReasonFromErrorCode(0), chk.Equals, "")
+	c.Assert(failureReasonFromErrorCode(http.StatusForbidden), chk.Equals, "403 Forbidden")
+}
+
+func (s *failureReasonSuite) TestFailureReasonReachesJobSummary(c *chk.C) {
+	jptm := &jobPartTransferMgr{jobPartPlanTransfer: &JobPartPlanTransfer{}}
+	c.Assert(jptm.failureReason(), chk.Equals, "")
+
+	err := forbiddenBlobError(c)
+	jptm.atomicFailureReason.Store(transferFailureReason(err))
+	jptm.SetErrorCode(http.StatusForbidden)
+
+	// run the status manager the way the job manager does, and send it the failure
+	jm := &jobMgr{jobID: common.NewJobID(), jstm: newJobStatusManager()}
+	go jm.handleStatusUpdateMessage()
+
+	jm.SendXferDoneMsg(xferDoneMsg{
+		Src:            "/src/file",
+		Dst:            "https://account.blob.core.windows.net/container/file",
+		TransferStatus: common.ETransferStatus.Failed(),
+		ErrorCode:      jptm.ErrorCode(),
+		FailureReason:  jptm.failureReason(),
+	})
+
+	// the status manager may answer the list request before it has consumed the message
+	var summary common.ListJobSummaryResponse
+	for i := 0; i < 100 && len(summary.FailedTransfers) == 0; i++ {
+		summary = jm.ListJobSummary()
+	}
+
+	c.Assert(summary.FailedTransfers, chk.HasLen, 1)
+	c.Assert(summary.FailedTransfers[0].ErrorCode, chk.Equals, int32(http.StatusForbidden))
+	c.Assert(summary.FailedTransfers[0].FailureReason, chk.Equals, transferFailureReason(err))
+}
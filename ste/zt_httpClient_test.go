@@ -0,0 +1,134 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+
+	chk "gopkg.in/check.v1"
+)
+
+type httpClientSuite struct{}
+
+var _ = chk.Suite(&httpClientSuite{})
+
+// connectionCountingServer counts the connections made to it. Each request waits until a whole burst has arrived,
+// so that every request of a burst needs a connection of its own (at least, over HTTP/1.1).
+type connectionCountingServer struct {
+	*httptest.Server
+	newConnections int32
+	burst          *sync.WaitGroup
+}
+
+func newConnectionCountingServer(useTLS bool) *connectionCountingServer {
+	s := &connectionCountingServer{}
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.burst.Done()
+		s.burst.Wait()
+		w.WriteHeader(http.StatusOK)
+	}))
+	s.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&s.newConnections, 1)
+		}
+	}
+	if useTLS {
+		s.EnableHTTP2 = true
+		s.StartTLS()
+	} else {
+		s.Start()
+	}
+	return s
+}
+
+// sendBursts sends the given number of bursts of concurrent requests, one burst after another,
+// and returns the number of new connections they needed and the protocol that was used
+func (s *connectionCountingServer) sendBursts(c *chk.C, client *http.Client, bursts int, burstSize int) (int, string) {
+	var proto atomic.Value
+	atomic.StoreInt32(&s.newConnections, 0)
+	for b := 0; b < bursts; b++ {
+		s.burst = &sync.WaitGroup{}
+		s.burst.Add(burstSize)
+		var requests sync.WaitGroup
+		for i := 0; i < burstSize; i++ {
+			requests.Add(1)
+			go func() {
+				defer requests.Done()
+				resp, err := client.Get(s.URL)
+				c.Check(err, chk.IsNil)
+				if err == nil {
+					proto.Store(resp.Proto)
+					_, _ = ioutil.ReadAll(resp.Body)
+					resp.Body.Close()
+				}
+			}()
+		}
+		requests.Wait()
+	}
+	return int(atomic.LoadInt32(&s.newConnections)), proto.Load().(string)
+}
+
+func (s *httpClientSuite) TestIdleConnectionsAreReusedWhenPoolIsBigEnough(c *chk.C) {
+	const bursts, burstSize = 4, 16
+	server := newConnectionCountingServer(false)
+	defer server.Close()
+
+	// a pool as big as the concurrency keeps every connection for the next burst
+	tuned := newAzcopyHTTPClient(burstSize, false)
+	newConnections, _ := server.sendBursts(c, tuned, bursts, burstSize)
+	c.Assert(newConnections, chk.Equals, burstSize)
+
+	// whereas Go's default pool keeps only 2 of them, so each burst makes most of its connections again
+	// (and, for real services, pays for another TLS handshake on each)
+	untuned := newAzcopyHTTPClient(http.DefaultMaxIdleConnsPerHost, false)
+	newConnections, _ = server.sendBursts(c, untuned, bursts, burstSize)
+	c.Assert(newConnections, chk.Equals, burstSize+(bursts-1)*(burstSize-http.DefaultMaxIdleConnsPerHost))
+}
+
+func (s *httpClientSuite) TestHTTP2OnlyWhenForced(c *chk.C) {
+	const burstSize = 8
+	server := newConnectionCountingServer(true)
+	defer server.Close()
+	trustServer := func(client *http.Client) *http.Client {
+		client.Transport.(*http.Transport).TLSClientConfig = &tls.Config{RootCAs: server.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs}
+		return client
+	}
+
+	// each client makes one connection first, so that it knows what protocol the server speaks
+	http1 := trustServer(newAzcopyHTTPClient(burstSize, false))
+	server.sendBursts(c, http1, 1, 1)
+	newConnections, proto := server.sendBursts(c, http1, 1, burstSize)
+	c.Assert(proto, chk.Equals, "HTTP/1.1")
+	c.Assert(newConnections, chk.Equals, burstSize-1)
+
+	// with HTTP/2, the concurrent requests all share that first connection
+	http2 := trustServer(newAzcopyHTTPClient(burstSize, true))
+	server.sendBursts(c, http2, 1, 1)
+	newConnections, proto = server.sendBursts(c, http2, 1, burstSize)
+	c.Assert(proto, chk.Equals, "HTTP/2.0")
+	c.Assert(newConnections, chk.Equals, 0)
+}
@@ -0,0 +1,84 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+type ifNoneMatchSuite struct{}
+
+var _ = chk.Suite(&ifNoneMatchSuite{})
+
+// newExistingBlobServer pretends to hold the blob already, so refuses any write made with If-None-Match: *
+func newExistingBlobServer() *testBlobServer {
+	return newTestBlobServer(func(w http.ResponseWriter, r testRequest) {
+		if r.method == http.MethodPut && r.header.Get("If-None-Match") == "*" {
+			writeStorageError(w, http.StatusPreconditionFailed, "ConditionNotMet", "The condition specified using HTTP conditional header(s) is not met.")
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+}
+
+func (s *ifNoneMatchSuite) commitToExistingBlob(c *chk.C, jptm *testTransferMgr) (*testBlobServer, *blockBlobSenderBase, error) {
+	server := newExistingBlobServer()
+	jptm.SetDestinationIsModified() // a block has been staged
+	sender := &blockBlobSenderBase{jptm: jptm, destBlockBlobURL: server.blobURL(c, "blob").ToBlockBlobURL(), atomicChunksWritten: 1}
+	_, err := sender.destBlockBlobURL.CommitBlockList(context.Background(), []string{"AAAA"}, azblob.BlobHTTPHeaders{}, azblob.Metadata{},
+		sender.destAccessConditions(), azblob.AccessTierNone, nil, azblob.ClientProvidedKeyOptions{})
+	return server, sender, err
+}
+
+func (s *ifNoneMatchSuite) TestConditionalUploadToExistingBlobIsSkipped(c *chk.C) {
+	jptm := &testTransferMgr{ifNoneMatch: true, status: common.ETransferStatus.Started()}
+	server, sender, err := s.commitToExistingBlob(c, jptm)
+	defer server.Close()
+
+	c.Assert(err, chk.NotNil)
+	c.Assert(skipIfDestinationCreatedMeanwhile(jptm, err), chk.Equals, true)
+	c.Assert(jptm.status, chk.Equals, common.ETransferStatus.SkippedEntityAlreadyExists())
+
+	// the existing blob is not ours, so cleaning up after the skip must not delete it
+	sender.Cleanup()
+	c.Assert(server.methods(), chk.DeepEquals, []string{http.MethodPut})
+}
+
+func (s *ifNoneMatchSuite) TestUnconditionalUploadOverwrites(c *chk.C) {
+	jptm := &testTransferMgr{status: common.ETransferStatus.Started()}
+	server, _, err := s.commitToExistingBlob(c, jptm)
+	defer server.Close()
+
+	c.Assert(err, chk.IsNil)
+	c.Assert(server.received()[0].header.Get("If-None-Match"), chk.Equals, "")
+
+	// and any other failure is not mistaken for a skip
+	c.Assert(skipIfDestinationCreatedMeanwhile(jptm, forbiddenBlobError(c)), chk.Equals, false)
+	jptm.ifNoneMatch = true
+	c.Assert(skipIfDestinationCreatedMeanwhile(jptm, forbiddenBlobError(c)), chk.Equals, false)
+	c.Assert(jptm.status, chk.Equals, common.ETransferStatus.Started())
+}
@@ -0,0 +1,72 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"crypto/md5"
+	"strings"
+
+	chk "gopkg.in/check.v1"
+)
+
+type jobPartPlanTransferSuite struct{}
+
+var _ = chk.Suite(&jobPartPlanTransferSuite{})
+
+func (s *jobPartPlanTransferSuite) TestContentMD5RoundTrip(c *chk.C) {
+	transfer := JobPartPlanTransfer{}
+	c.Assert(transfer.ContentMD5(), chk.IsNil) // nothing computed yet
+
+	hash := md5.Sum([]byte("hello world"))
+	transfer.SetContentMD5(hash[:])
+	c.Assert(transfer.ContentMD5(), chk.DeepEquals, hash[:])
+
+	// the returned slice is a copy, so callers cannot change what is stored in the plan file
+	transfer.ContentMD5()[0] ^= 0xff
+	c.Assert(transfer.ContentMD5(), chk.DeepEquals, hash[:])
+}
+
+func (s *jobPartPlanTransferSuite) TestContentMD5IgnoresHashesOfWrongLength(c *chk.C) {
+	transfer := JobPartPlanTransfer{}
+	transfer.SetContentMD5(nil)
+	transfer.SetContentMD5([]byte{1, 2, 3})
+	c.Assert(transfer.ContentMD5(), chk.IsNil)
+}
+
+func (s *jobPartPlanTransferSuite) TestETagRoundTrip(c *chk.C) {
+	transfer := JobPartPlanTransfer{}
+	c.Assert(transfer.ETag(), chk.Equals, "") // nothing written yet
+
+	transfer.SetETag(`"0x8D9A1B2C3D4E5F60"`)
+	c.Assert(transfer.ETag(), chk.Equals, `"0x8D9A1B2C3D4E5F60"`)
+
+	// a later attempt at the transfer leaves nothing of the earlier, longer, ETag behind
+	transfer.SetETag(`"0x1"`)
+	c.Assert(transfer.ETag(), chk.Equals, `"0x1"`)
+
+	// one that's too long to store is ignored, rather than being cut short
+	transfer.SetETag(strings.Repeat("x", ETagMaxBytes+1))
+	c.Assert(transfer.ETag(), chk.Equals, `"0x1"`)
+
+	// the longest that fits
+	transfer.SetETag(strings.Repeat("y", ETagMaxBytes))
+	c.Assert(transfer.ETag(), chk.Equals, strings.Repeat("y", ETagMaxBytes))
+}
@@ -0,0 +1,125 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+type jobPlanResumeSuite struct{}
+
+var _ = chk.Suite(&jobPlanResumeSuite{})
+
+// withPlanDir points JobsAdmin at a plan folder of its own for the duration of a test, and returns how to undo that
+func withPlanDir(dir string) (restore func()) {
+	previous := JobsAdmin
+	JobsAdmin = &jobsAdmin{planDir: dir}
+	return func() { JobsAdmin = previous }
+}
+
+func (s *jobPlanResumeSuite) TestPlanSurvivesRestart(c *chk.C) {
+	defer withPlanDir(c.MkDir())()
+
+	jobID := common.NewJobID()
+	order := common.CopyJobPartOrderRequest{
+		JobID:           jobID,
+		PartNum:         0,
+		FromTo:          common.EFromTo.LocalBlob(),
+		IsFinalPart:     true,
+		CommandString:   "copy /data https://account.blob.core.windows.net/container --recursive",
+		SourceRoot:      common.ResourceString{Value: "/data"},
+		DestinationRoot: common.ResourceString{Value: "https://account.blob.core.windows.net/container"},
+		Transfers: common.Transfers{List: []common.CopyTransfer{
+			{Source: "/a.txt", Destination: "/a.txt", SourceSize: 1},
+			{Source: "/b.txt", Destination: "/b.txt", SourceSize: 2},
+			{Source: "/c.txt", Destination: "/c.txt", SourceSize: 3},
+		}},
+	}
+	planFile := JobsAdmin.NewJobPartPlanFileName(jobID, 0)
+	planFile.Create(order)
+
+	// the job gets part of the way through: one transfer is done and one is in flight...
+	mmf := planFile.Map()
+	mmf.Plan().Transfer(0).SetTransferStatus(common.ETransferStatus.Success(), false)
+	mmf.Plan().Transfer(1).SetTransferStatus(common.ETransferStatus.Started(), false)
+	// ...when the process is killed. Nothing is flushed or saved on the way out
+	mmf.Unmap()
+
+	// after the restart, everything the STE needs to carry on comes from the plan file alone
+	parsedJobID, partNum, err := planFile.Parse()
+	c.Assert(err, chk.IsNil)
+	c.Assert(parsedJobID, chk.Equals, jobID)
+	c.Assert(partNum, chk.Equals, common.PartNumber(0))
+
+	mmf = planFile.Map()
+	defer mmf.Unmap()
+	plan := mmf.Plan()
+	c.Assert(plan.Version, chk.Equals, DataSchemaVersion)
+	c.Assert(plan.JobStatus(), chk.Equals, common.EJobStatus.InProgress())
+	c.Assert(plan.CommandString(), chk.Equals, order.CommandString)
+	c.Assert(plan.NumTransfers, chk.Equals, uint32(3))
+
+	// ScheduleTransfers only re-enqueues transfers that have not succeeded
+	incomplete := []string{}
+	for t := uint32(0); t < plan.NumTransfers; t++ {
+		if plan.Transfer(t).TransferStatus() != common.ETransferStatus.Success() {
+			src, _ := plan.TransferSrcDstRelatives(t)
+			incomplete = append(incomplete, src)
+		}
+	}
+	c.Assert(incomplete, chk.DeepEquals, []string{"/b.txt", "/c.txt"})
+	c.Assert(plan.Transfer(1).TransferStatus(), chk.Equals, common.ETransferStatus.Started())
+}
+
+func (s *jobPlanResumeSuite) TestPlanFileVersionsOfJob(c *chk.C) {
+	dir := c.MkDir()
+	jobID := common.NewJobID()
+	names := []string{
+		fmt.Sprintf("%v--00000.steV%d", jobID, DataSchemaVersion-1),
+		fmt.Sprintf("%v--00001.steV%d", jobID, DataSchemaVersion-1),
+		fmt.Sprintf("%v--00000.steV%d", common.NewJobID(), DataSchemaVersion), // another job
+		jobID.String() + ".log", // not a plan file
+	}
+	for _, name := range names {
+		c.Assert(ioutil.WriteFile(filepath.Join(dir, name), nil, 0644), chk.IsNil)
+	}
+
+	c.Assert(planFileVersionsOfJob(dir, jobID), chk.DeepEquals, []common.Version{DataSchemaVersion - 1, DataSchemaVersion - 1})
+	c.Assert(planFileVersionsOfJob(dir, common.NewJobID()), chk.HasLen, 0)
+}
+
+func (s *jobPlanResumeSuite) TestJobNotResurrectedMessage(c *chk.C) {
+	jobID := common.NewJobID()
+
+	c.Assert(jobNotResurrectedMessage(jobID, nil), chk.Equals, fmt.Sprintf("no job with JobId %v exists", jobID))
+	c.Assert(jobNotResurrectedMessage(jobID, []common.Version{DataSchemaVersion}), chk.Equals, fmt.Sprintf("no job with JobId %v exists", jobID))
+
+	msg := jobNotResurrectedMessage(jobID, []common.Version{DataSchemaVersion + 1})
+	c.Assert(strings.Contains(msg, "different version of AzCopy"), chk.Equals, true)
+	c.Assert(strings.Contains(msg, fmt.Sprintf("format %d", DataSchemaVersion+1)), chk.Equals, true)
+}
@@ -0,0 +1,159 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"bufio"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"unsafe"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+type jobProgressSuite struct{}
+
+var _ = chk.Suite(&jobProgressSuite{})
+
+func (s *jobProgressSuite) TestSingleHugeFileReportsIntermediatePercentages(c *chk.C) {
+	const size = int64(100 * 1024 * 1024 * 1024)
+	transfer := JobPartPlanTransfer{SourceSize: size}
+	c.Assert(percentComplete(transfer.InFlightBytes(), uint64(size)), chk.Equals, float32(0)) // not started yet
+
+	transfer.SetNumChunks(400)
+	expected := map[int]float32{1: 0.25, 100: 25, 200: 50, 399: 99.75, 400: 100}
+	for chunksDone := 1; chunksDone <= 400; chunksDone++ {
+		transfer.ReportChunkDone()
+		if pct, ok := expected[chunksDone]; ok {
+			c.Assert(percentComplete(transfer.InFlightBytes(), uint64(size)), chk.Equals, pct, chk.Commentf("after %d chunks", chunksDone))
+		}
+	}
+
+	// starting the transfer again (e.g. on resume) starts its progress again too
+	transfer.SetNumChunks(400)
+	c.Assert(transfer.InFlightBytes(), chk.Equals, uint64(0))
+}
+
+func (s *jobProgressSuite) TestPercentBlendsCompletedAndInFlightTransfers(c *chk.C) {
+	const MiB = uint64(1024 * 1024)
+	// two finished 1 MiB files, and a 2 MiB file that is half done
+	inFlight := JobPartPlanTransfer{SourceSize: int64(2 * MiB)}
+	inFlight.SetNumChunks(8)
+	for i := 0; i < 4; i++ {
+		inFlight.ReportChunkDone()
+	}
+
+	c.Assert(percentComplete(2*MiB+inFlight.InFlightBytes(), 4*MiB), chk.Equals, float32(75))
+	c.Assert(percentComplete(0, 0), chk.Equals, float32(100)) // nothing to transfer means nothing left to do
+}
+
+func (s *jobProgressSuite) TestInFlightBytesOfTheLargestBlobs(c *chk.C) {
+	// the largest block blob: 50000 blocks of 4000 MiB, where size times chunk count would overflow 64 bits
+	const blockSize = int64(4000 * 1024 * 1024)
+	transfer := JobPartPlanTransfer{SourceSize: 50000 * blockSize}
+	transfer.SetNumChunks(50000)
+	for i := 0; i < 25000; i++ {
+		transfer.ReportChunkDone()
+	}
+	c.Assert(transfer.InFlightBytes(), chk.Equals, uint64(25000*blockSize))
+}
+
+// BenchmarkChunkProgressPersistence compares recording chunk progress the way the STE does, in the memory-mapped plan
+// file, with writing it to the file as each chunk completes. The mapped pages are written back by the OS in its own
+// time, however many chunks complete in between, so no write calls are made per chunk (see the writeSyscalls/op metric,
+// which is taken from /proc/self/io where that exists).
+func BenchmarkChunkProgressPersistence(b *testing.B) {
+	planFile, err := os.Create(filepath.Join(b.TempDir(), "plan"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer planFile.Close()
+	transferSize := int64(unsafe.Sizeof(JobPartPlanTransfer{}))
+	if err = planFile.Truncate(transferSize); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("mappedPlanFile", func(b *testing.B) {
+		mmf, err := common.NewMMF(planFile, true, 0, transferSize)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer mmf.Unmap()
+		transfer := (*JobPartPlanTransfer)(unsafe.Pointer(&mmf.Slice()[0]))
+		transfer.SetNumChunks(uint32(b.N))
+
+		measureWriteSyscalls(b, func() {
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					transfer.ReportChunkDone()
+				}
+			})
+		})
+	})
+
+	b.Run("writePerChunk", func(b *testing.B) {
+		var chunksDone uint32
+		measureWriteSyscalls(b, func() {
+			for n := 0; n < b.N; n++ {
+				chunksDone++
+				buf := make([]byte, 4)
+				binary.LittleEndian.PutUint32(buf, chunksDone)
+				if _, err := planFile.WriteAt(buf, 0); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+}
+
+// measureWriteSyscalls runs f, and reports the write calls the process made meanwhile, per op
+func measureWriteSyscalls(b *testing.B, f func()) {
+	before, ok := writeSyscalls()
+	b.ResetTimer()
+	f()
+	b.StopTimer()
+	if after, ok2 := writeSyscalls(); ok && ok2 {
+		b.ReportMetric(float64(after-before)/float64(b.N), "writeSyscalls/op")
+	}
+}
+
+// writeSyscalls gives the number of write calls this process has made, if the OS says
+func writeSyscalls() (uint64, bool) {
+	f, err := os.Open("/proc/self/io")
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if value := strings.TrimPrefix(scanner.Text(), "syscw: "); value != scanner.Text() {
+			count, err := strconv.ParseUint(value, 10, 64)
+			return count, err == nil
+		}
+	}
+	return 0, false
+}
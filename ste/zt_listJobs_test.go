@@ -0,0 +1,66 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+type listJobsSuite struct{}
+
+var _ = chk.Suite(&listJobsSuite{})
+
+func (s *listJobsSuite) TestFilterJobsByStatus(c *chk.C) {
+	completed := common.JobIDDetails{JobId: common.NewJobID(), JobStatus: common.EJobStatus.Completed()}
+	failed := common.JobIDDetails{JobId: common.NewJobID(), JobStatus: common.EJobStatus.Failed()}
+	inProgress := common.JobIDDetails{JobId: common.NewJobID(), JobStatus: common.EJobStatus.InProgress()}
+	jobs := []common.JobIDDetails{completed, failed, inProgress}
+
+	c.Assert(filterJobsByStatus(jobs, common.EJobStatus.All()), chk.DeepEquals, jobs)
+	c.Assert(filterJobsByStatus(jobs, common.EJobStatus.Completed()), chk.DeepEquals, []common.JobIDDetails{completed})
+	c.Assert(filterJobsByStatus(jobs, common.EJobStatus.Failed()), chk.DeepEquals, []common.JobIDDetails{failed})
+	c.Assert(filterJobsByStatus(jobs, common.EJobStatus.InProgress()), chk.DeepEquals, []common.JobIDDetails{inProgress})
+
+	// no match gives an empty list rather than nil, so that the JSON output is an empty array
+	filtered := filterJobsByStatus(jobs, common.EJobStatus.Cancelled())
+	c.Assert(filtered, chk.NotNil)
+	c.Assert(filtered, chk.HasLen, 0)
+}
+
+func (s *listJobsSuite) TestTransferMatchesGrep(c *chk.C) {
+	t := common.TransferDetail{
+		Src:           "/data/reports/q1.csv",
+		Dst:           "https://account.blob.core.windows.net/archive/q1.csv",
+		FailureReason: failureReasonFromErrorCode(403),
+	}
+
+	c.Assert(transferMatchesGrep(t, ""), chk.Equals, true)
+	c.Assert(transferMatchesGrep(t, "/data/reports"), chk.Equals, true) // source
+	c.Assert(transferMatchesGrep(t, "archive/"), chk.Equals, true)      // destination
+	c.Assert(transferMatchesGrep(t, "403"), chk.Equals, true)           // failure reason
+	c.Assert(transferMatchesGrep(t, "Forbidden"), chk.Equals, true)
+
+	c.Assert(transferMatchesGrep(t, "404"), chk.Equals, false)
+	c.Assert(transferMatchesGrep(t, "forbidden"), chk.Equals, false) // the match is case sensitive
+	c.Assert(transferMatchesGrep(common.TransferDetail{Src: "/a", Dst: "/b"}, "403"), chk.Equals, false)
+}
@@ -0,0 +1,103 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"crypto/md5"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	chk "gopkg.in/check.v1"
+)
+
+type md5ComparerSuite struct{}
+
+var _ = chk.Suite(&md5ComparerSuite{})
+
+type recordingTransferLogger struct {
+	warnings []string
+}
+
+func (l *recordingTransferLogger) LogAtLevelForCurrentTransfer(level pipeline.LogLevel, msg string) {
+	if level == pipeline.LogWarning {
+		l.warnings = append(l.warnings, msg)
+	}
+}
+
+func (s *md5ComparerSuite) compare(expected, actual []byte, option common.HashValidationOption) (*recordingTransferLogger, error) {
+	logger := &recordingTransferLogger{}
+	comparer := md5Comparer{expected: expected, actualAsSaved: actual, validationOption: option, logger: logger}
+	return logger, comparer.Check()
+}
+
+func (s *md5ComparerSuite) TestMatch(c *chk.C) {
+	hash := md5.Sum([]byte("downloaded data"))
+	for _, option := range []common.HashValidationOption{
+		common.EHashValidationOption.FailIfDifferent(),
+		common.EHashValidationOption.FailIfDifferentOrMissing(),
+		common.EHashValidationOption.LogOnly(),
+		common.EHashValidationOption.NoCheck(),
+	} {
+		logger, err := s.compare(hash[:], hash[:], option)
+		c.Assert(err, chk.IsNil, chk.Commentf("option %v", option))
+		c.Assert(logger.warnings, chk.HasLen, 0)
+	}
+}
+
+func (s *md5ComparerSuite) TestMismatch(c *chk.C) {
+	expected := md5.Sum([]byte("stored data"))
+	actual := md5.Sum([]byte("downloaded data"))
+
+	for _, option := range []common.HashValidationOption{common.EHashValidationOption.FailIfDifferent(), common.EHashValidationOption.FailIfDifferentOrMissing()} {
+		_, err := s.compare(expected[:], actual[:], option)
+		c.Assert(err, chk.Equals, errMd5Mismatch, chk.Commentf("option %v", option))
+	}
+
+	logger, err := s.compare(expected[:], actual[:], common.EHashValidationOption.LogOnly())
+	c.Assert(err, chk.IsNil)
+	c.Assert(logger.warnings, chk.DeepEquals, []string{errMd5Mismatch.Error()})
+
+	logger, err = s.compare(expected[:], actual[:], common.EHashValidationOption.NoCheck())
+	c.Assert(err, chk.IsNil)
+	c.Assert(logger.warnings, chk.HasLen, 0)
+}
+
+func (s *md5ComparerSuite) TestMissingStoredMD5(c *chk.C) {
+	actual := md5.Sum([]byte("downloaded data"))
+
+	// only logged, unless the user asked for missing hashes to fail (and that's caught before the download starts)
+	for _, option := range []common.HashValidationOption{common.EHashValidationOption.FailIfDifferent(), common.EHashValidationOption.LogOnly()} {
+		logger, err := s.compare(nil, actual[:], option)
+		c.Assert(err, chk.IsNil, chk.Commentf("option %v", option))
+		c.Assert(logger.warnings, chk.DeepEquals, []string{noMD5Stored})
+	}
+	c.Assert(func() { _, _ = s.compare(nil, actual[:], common.EHashValidationOption.FailIfDifferentOrMissing()) }, chk.PanicMatches, ".*pre-emptively failed.*")
+
+	logger, err := s.compare(nil, actual[:], common.EHashValidationOption.NoCheck())
+	c.Assert(err, chk.IsNil)
+	c.Assert(logger.warnings, chk.HasLen, 0)
+}
+
+func (s *md5ComparerSuite) TestMissingComputedMD5(c *chk.C) {
+	expected := md5.Sum([]byte("stored data"))
+	_, err := s.compare(expected[:], nil, common.EHashValidationOption.LogOnly())
+	c.Assert(err, chk.Equals, errActualMd5NotComputed)
+}
@@ -0,0 +1,159 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package ste
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+type metricsSuite struct{}
+
+var _ = chk.Suite(&metricsSuite{})
+
+// metricsJobPartMgr is just enough of a job part for transfers to start and report that they are done
+type metricsJobPartMgr struct {
+	IJobPartMgr
+}
+
+func (metricsJobPartMgr) Plan() *JobPartPlanHeader                        { return &JobPartPlanHeader{} }
+func (metricsJobPartMgr) StartJobXfer(IJobPartTransferMgr)                {}
+func (metricsJobPartMgr) SendXferDoneMsg(xferDoneMsg)                     {}
+func (metricsJobPartMgr) ReportTransferDone(common.TransferStatus) uint32 { return 0 }
+
+func newMetricsTestTransfer(numChunks uint32) *jobPartTransferMgr {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &jobPartTransferMgr{
+		jobPartMgr:          metricsJobPartMgr{},
+		jobPartPlanTransfer: &JobPartPlanTransfer{},
+		transferInfo:        &TransferInfo{},
+		ctx:                 ctx,
+		cancel:              cancel,
+		numChunks:           numChunks,
+	}
+}
+
+// scrapeMetrics reads every sample from the metrics endpoint
+func scrapeMetrics(c *chk.C, serverURL string) map[string]float64 {
+	resp, err := http.Get(serverURL + "/metrics")
+	c.Assert(err, chk.IsNil)
+	defer resp.Body.Close()
+	c.Assert(resp.Header.Get("Content-Type"), chk.Matches, "text/plain.*")
+
+	samples := map[string]float64{}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		c.Assert(fields, chk.HasLen, 2)
+		value, err := strconv.ParseFloat(fields[1], 64)
+		c.Assert(err, chk.IsNil)
+		samples[fields[0]] = value
+	}
+	return samples
+}
+
+func (s *metricsSuite) TestCountersIncrementAfterTransfers(c *chk.C) {
+	defer withPlanDir(c.MkDir())() // reporting chunks done updates the JobsAdmin's count of bytes in active files
+	bytesOverWire := int64(0)
+	server := httptest.NewServer(newMetricsHandler(transferMetrics, func() int64 { return bytesOverWire }))
+	defer server.Close()
+	before := scrapeMetrics(c, server.URL)
+
+	// one transfer of two chunks succeeds, and another fails after its first chunk
+	succeeding, failing := newMetricsTestTransfer(2), newMetricsTestTransfer(2)
+	succeeding.StartJobXfer()
+	failing.StartJobXfer()
+	succeeding.ReportChunkDone(common.NewChunkID("a", 0, 4))
+	failing.SetStatus(common.ETransferStatus.Failed())
+	failing.ReportChunkDone(common.NewChunkID("b", 0, 4))
+	bytesOverWire += 8
+
+	during := scrapeMetrics(c, server.URL)
+	c.Assert(during["azcopy_active_transfers"]-before["azcopy_active_transfers"], chk.Equals, 2.0)
+	c.Assert(during["azcopy_transfers_started_total"]-before["azcopy_transfers_started_total"], chk.Equals, 2.0)
+	c.Assert(during["azcopy_bytes_transferred_total"], chk.Equals, 8.0)
+	c.Assert(during["azcopy_throughput_bytes_per_second"] > 0, chk.Equals, true)
+
+	succeeding.ReportChunkDone(common.NewChunkID("a", 4, 4))
+	succeeding.SetStatus(common.ETransferStatus.Success())
+	succeeding.ReportTransferDone()
+	failing.ReportTransferDone()
+
+	after := scrapeMetrics(c, server.URL)
+	c.Assert(after["azcopy_chunks_succeeded_total"]-before["azcopy_chunks_succeeded_total"], chk.Equals, 2.0)
+	c.Assert(after["azcopy_chunks_failed_total"]-before["azcopy_chunks_failed_total"], chk.Equals, 1.0)
+	c.Assert(after["azcopy_active_transfers"], chk.Equals, before["azcopy_active_transfers"])
+	c.Assert(after["azcopy_throughput_bytes_per_second"], chk.Equals, 0.0) // nothing more was sent since the last scrape
+}
+
+func (s *metricsSuite) TestTransferCancelledBeforeStartIsNeverActive(c *chk.C) {
+	before := atomic.LoadInt64(&transferMetrics.atomicActiveTransfers)
+	jptm := newMetricsTestTransfer(1)
+	jptm.SetStatus(common.ETransferStatus.Cancelled())
+	jptm.ReportTransferDone()
+	c.Assert(atomic.LoadInt64(&transferMetrics.atomicActiveTransfers), chk.Equals, before)
+}
+
+func (s *metricsSuite) TestRetriesAreCounted(c *chk.C) {
+	requests := 0
+	blobServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer blobServer.Close()
+	metricsServer := httptest.NewServer(newMetricsHandler(transferMetrics, func() int64 { return 0 }))
+	defer metricsServer.Close()
+	before := scrapeMetrics(c, metricsServer.URL)
+
+	u, _ := url.Parse(blobServer.URL + "/container/blob")
+	p := pipeline.NewPipeline([]pipeline.Factory{NewBlobXferRetryPolicyFactory(XferRetryOptions{
+		MaxTries:      3,
+		TryTimeout:    time.Minute,
+		RetryDelay:    time.Millisecond,
+		MaxRetryDelay: time.Millisecond,
+	}), pipeline.MethodFactoryMarker()}, pipeline.Options{})
+	_, err := azblob.NewBlobURL(*u, p).GetProperties(context.Background(), azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(requests, chk.Equals, 2)
+
+	after := scrapeMetrics(c, metricsServer.URL)
+	c.Assert(after["azcopy_retries_total"]-before["azcopy_retries_total"], chk.Equals, 1.0)
+}
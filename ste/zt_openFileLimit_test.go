@@ -0,0 +1,89 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package ste
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	chk "gopkg.in/check.v1"
+)
+
+type openFileLimitSuite struct{}
+
+var _ = chk.Suite(&openFileLimitSuite{})
+
+// trackedFile records how many files are open at once
+type trackedFile struct {
+	open *int32
+}
+
+func (f *trackedFile) ReadAt(p []byte, off int64) (int, error) { return 0, nil }
+func (f *trackedFile) Close() error {
+	atomic.AddInt32(f.open, -1)
+	return nil
+}
+
+func (s *openFileLimitSuite) TestOpenSourceFilesStayWithinLimit(c *chk.C) {
+	const limit = 2
+	jptm := &testTransferMgr{openFiles: common.NewCacheLimiter(limit)}
+
+	var open, maxOpen int32
+	factory := func() (common.CloseableReaderAt, error) {
+		n := atomic.AddInt32(&open, 1)
+		for {
+			m := atomic.LoadInt32(&maxOpen)
+			if n <= m || atomic.CompareAndSwapInt32(&maxOpen, m, n) {
+				break
+			}
+		}
+		return &trackedFile{open: &open}, nil
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f, err := openCountedSourceFile(jptm, factory)
+			c.Check(err, chk.IsNil)
+			time.Sleep(50 * time.Millisecond)
+			c.Check(f.Close(), chk.IsNil)
+			c.Check(f.Close(), chk.IsNil) // a second close must not give the room back twice
+		}()
+	}
+	wg.Wait()
+
+	c.Assert(atomic.LoadInt32(&maxOpen) <= limit, chk.Equals, true)
+	c.Assert(jptm.openFiles.TryAdd(limit, true), chk.Equals, true) // everything was handed back
+}
+
+func (s *openFileLimitSuite) TestFailedOpenGivesBackItsRoom(c *chk.C) {
+	jptm := &testTransferMgr{openFiles: common.NewCacheLimiter(1)}
+
+	_, err := openCountedSourceFile(jptm, func() (common.CloseableReaderAt, error) {
+		return nil, errors.New("access denied")
+	})
+	c.Assert(err, chk.ErrorMatches, "access denied")
+	c.Assert(jptm.openFiles.TryAdd(1, true), chk.Equals, true)
+}
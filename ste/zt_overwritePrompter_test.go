@@ -0,0 +1,79 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"time"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+type overwritePrompterSuite struct{}
+
+var _ = chk.Suite(&overwritePrompterSuite{})
+
+// newScriptedOverwritePrompter gives the answers in order, and counts the questions asked and the cancellations
+func newScriptedOverwritePrompter(answers ...common.ResponseOption) (o *overwritePrompter, asked *int, cancelled chan struct{}) {
+	asked = new(int)
+	cancelled = make(chan struct{}, 1)
+	o = newOverwritePrompter(func() { cancelled <- struct{}{} })
+	o.prompt = func(message string, details common.PromptDetails) common.ResponseOption {
+		answer := answers[*asked]
+		*asked++
+		return answer
+	}
+	return
+}
+
+func (s *overwritePrompterSuite) TestYesAndNoAnswerOnlyTheQuestionAsked(c *chk.C) {
+	o, asked, _ := newScriptedOverwritePrompter(common.EResponseOption.Yes(), common.EResponseOption.No())
+
+	c.Assert(o.ShouldOverwrite("a.txt", common.EEntityType.File()), chk.Equals, true)
+	c.Assert(o.ShouldOverwrite("b.txt", common.EEntityType.File()), chk.Equals, false)
+	c.Assert(*asked, chk.Equals, 2)
+}
+
+func (s *overwritePrompterSuite) TestYesForAllStopsPrompting(c *chk.C) {
+	o, asked, _ := newScriptedOverwritePrompter(common.EResponseOption.YesForAll())
+
+	c.Assert(o.ShouldOverwrite("a.txt", common.EEntityType.File()), chk.Equals, true)
+	c.Assert(o.ShouldOverwrite("b.txt", common.EEntityType.File()), chk.Equals, true)
+	c.Assert(o.ShouldOverwrite("c.txt", common.EEntityType.File()), chk.Equals, true)
+	c.Assert(*asked, chk.Equals, 1)
+}
+
+func (s *overwritePrompterSuite) TestQuitCancelsTheJobAndOverwritesNothing(c *chk.C) {
+	o, asked, cancelled := newScriptedOverwritePrompter(common.EResponseOption.Quit())
+
+	c.Assert(o.ShouldOverwrite("a.txt", common.EEntityType.File()), chk.Equals, false)
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		c.Fatal("the job was not cancelled")
+	}
+
+	// transfers still in flight are neither asked about nor overwritten, whatever their type
+	c.Assert(o.ShouldOverwrite("b.txt", common.EEntityType.File()), chk.Equals, false)
+	c.Assert(o.ShouldOverwrite("dir", common.EEntityType.Folder()), chk.Equals, false)
+	c.Assert(*asked, chk.Equals, 1)
+}
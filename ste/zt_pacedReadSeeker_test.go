@@ -0,0 +1,110 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+
+	chk "gopkg.in/check.v1"
+)
+
+type pacedReadSeekerSuite struct{}
+
+var _ = chk.Suite(&pacedReadSeekerSuite{})
+
+func (s *pacedReadSeekerSuite) TestUncappedRateCountsOnlyWhatIsRead(c *chk.C) {
+	p := newNullAutoPacer()
+	reader := bytes.NewReader(make([]byte, 1000))
+	_, err := reader.Seek(200, io.SeekStart)
+	c.Assert(err, chk.IsNil)
+
+	// nothing is counted for a body that's never sent
+	body := newPacedRequestBody(context.Background(), reader, p)
+	_, isPaced := body.(*pacedReadSeeker)
+	c.Assert(isPaced, chk.Equals, false)
+	c.Assert(p.GetTotalTraffic(), chk.Equals, int64(0))
+
+	// and only what is left to send is counted once it's sent
+	data, err := ioutil.ReadAll(body)
+	c.Assert(err, chk.IsNil)
+	c.Assert(data, chk.HasLen, 800)
+	c.Assert(p.GetTotalTraffic(), chk.Equals, int64(800))
+
+	// sending it again (e.g. on a retry) sends the bytes over the wire again
+	_, err = body.Seek(900, io.SeekStart)
+	c.Assert(err, chk.IsNil)
+	_, err = ioutil.ReadAll(body)
+	c.Assert(err, chk.IsNil)
+	c.Assert(p.GetTotalTraffic(), chk.Equals, int64(900))
+
+	// a response that fails part way through only counts what arrived
+	response := newPacedResponseBody(context.Background(), ioutil.NopCloser(bytes.NewReader(make([]byte, 300))), p)
+	_, err = response.Read(make([]byte, 100))
+	c.Assert(err, chk.IsNil)
+	c.Assert(response.Close(), chk.IsNil)
+	c.Assert(p.GetTotalTraffic(), chk.Equals, int64(1000))
+}
+
+func (s *pacedReadSeekerSuite) TestCappedRateWrapsBody(c *chk.C) {
+	p := newTokenBucketPacer(1000*1000*1000, 0)
+	defer p.Close()
+
+	body := newPacedRequestBody(context.Background(), bytes.NewReader(make([]byte, 1000)), p)
+	_, isPaced := body.(*pacedReadSeeker)
+	c.Assert(isPaced, chk.Equals, true)
+
+	data, err := ioutil.ReadAll(body)
+	c.Assert(err, chk.IsNil)
+	c.Assert(data, chk.HasLen, 1000)
+	c.Assert(p.GetTotalTraffic(), chk.Equals, int64(1000))
+}
+
+func (s *pacedReadSeekerSuite) benchmarkReads(c *chk.C, p pacer) {
+	data := make([]byte, 1024*1024)
+	buf := make([]byte, 256) // small reads, so that the cost per read dominates
+	c.SetBytes(int64(len(data)))
+	c.ResetTimer()
+	for i := 0; i < c.N; i++ {
+		body := newPacedRequestBody(context.Background(), bytes.NewReader(data), p)
+		for {
+			_, err := body.Read(buf)
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				c.Fatal(err)
+			}
+		}
+	}
+}
+
+// reading an uncapped body should cost about the same as reading the raw reader, with no per-read pacing
+func (s *pacedReadSeekerSuite) BenchmarkUncappedReads(c *chk.C) {
+	s.benchmarkReads(c, newNullAutoPacer())
+}
+
+func (s *pacedReadSeekerSuite) BenchmarkCappedReads(c *chk.C) {
+	p := newTokenBucketPacer(1000*1000*1000*1000, 0) // high enough never to block, so that we only measure the cost of pacing
+	defer p.Close()
+	s.benchmarkReads(c, p)
+}
@@ -0,0 +1,191 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	chk "gopkg.in/check.v1"
+)
+
+type pauseDrainSuite struct{}
+
+var _ = chk.Suite(&pauseDrainSuite{})
+
+func (s *pauseDrainSuite) TestWaitForChunksToFinish(c *chk.C) {
+	var active int64 = 2
+	activeChunks := func() int64 { return atomic.LoadInt64(&active) }
+	go func() {
+		time.Sleep(150 * time.Millisecond)
+		atomic.AddInt64(&active, -1)
+		time.Sleep(150 * time.Millisecond)
+		atomic.AddInt64(&active, -1)
+	}()
+
+	c.Assert(waitForChunksToFinish(activeChunks, 5*time.Second), chk.Equals, true)
+}
+
+func (s *pauseDrainSuite) TestWaitForChunksToFinishTimesOut(c *chk.C) {
+	activeChunks := func() int64 { return 1 }
+
+	start := time.Now()
+	c.Assert(waitForChunksToFinish(activeChunks, 300*time.Millisecond), chk.Equals, false)
+	c.Assert(time.Since(start) >= 300*time.Millisecond, chk.Equals, true)
+}
+
+// runChunkQueue schedules chunks into a queue that a single worker runs, like one of the chunk processors.
+// Once stopped, chunks that come back to be scheduled again are dropped
+func runChunkQueue() (schedule func(chunkFunc), stop func()) {
+	queue := make(chan chunkFunc, 10)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case cf := <-queue:
+				cf(0)
+			case <-done:
+				return
+			}
+		}
+	}()
+	schedule = func(cf chunkFunc) {
+		select {
+		case queue <- cf:
+		case <-done:
+		}
+	}
+	return schedule, func() { close(done) }
+}
+
+func (s *pauseDrainSuite) TestHeldChunksDoNotStartUntilReleased(c *chk.C) {
+	schedule, stop := runChunkQueue()
+	defer stop()
+
+	jm := &jobMgr{}
+	jm.holdChunkProcessing()
+	ran := make(chan struct{}, 1)
+	schedule(jm.holdableChunk(schedule, func(int) { ran <- struct{}{} }))
+
+	select {
+	case <-ran:
+		c.Fatal("chunk ran while processing was held")
+	case <-time.After(400 * time.Millisecond):
+	}
+
+	jm.releaseChunkProcessing()
+	select {
+	case <-ran:
+	case <-time.After(5 * time.Second):
+		c.Fatal("chunk did not run after processing was released")
+	}
+}
+
+func (s *pauseDrainSuite) TestHeldJobDoesNotHoldOtherJobs(c *chk.C) {
+	schedule, stop := runChunkQueue()
+	defer stop()
+
+	paused, running := &jobMgr{}, &jobMgr{}
+	paused.holdChunkProcessing()
+	defer paused.releaseChunkProcessing()
+	ran := make(chan struct{}, 1)
+	schedule(paused.holdableChunk(schedule, func(int) { c.Error("the paused job's chunk ran") }))
+	schedule(running.holdableChunk(schedule, func(int) { ran <- struct{}{} }))
+
+	select {
+	case <-ran:
+	case <-time.After(5 * time.Second):
+		c.Fatal("the other job's chunk did not run")
+	}
+}
+
+func (s *pauseDrainSuite) TestPausedUploadStagesNoMoreBlocks(c *chk.C) {
+	server := newStagingBlockServer()
+	defer server.Close()
+	info := newThreeBlockTransfer(c)
+
+	// one block is staged before the job is paused
+	ctx, cancel := context.WithCancel(context.Background())
+	jptm := &testTransferMgr{info: info, ctx: ctx, paused: true}
+	uploader := scheduleThreeBlockUpload(c, server, jptm)
+	jptm.chunks[0](0)
+	cancel()
+
+	// the chunks that hadn't started yet send nothing, and what was staged is kept for the resume
+	jptm.chunks[1](0)
+	jptm.chunks[2](0)
+	uploader.Cleanup()
+	c.Assert(jptm.failure, chk.IsNil)
+	c.Assert(server.methods(), chk.DeepEquals, []string{http.MethodPut})
+
+	// until the job is resumed, and sends the rest
+	jptm = &testTransferMgr{info: info, resumed: true}
+	scheduleThreeBlockUpload(c, server, jptm)
+	jptm.runChunks()
+	c.Assert(jptm.failure, chk.IsNil)
+	c.Assert(stagedBlockRequests(server), chk.HasLen, 3)
+}
+
+func (s *pauseDrainSuite) TestPauseByAnotherProcessIsVisible(c *chk.C) {
+	jm := &jobMgr{}
+
+	// another process is running the job, so nothing here will ever complete the pause
+	c.Assert(jm.isPauseVisible(), chk.Equals, true)
+
+	// this process is running the job, so the pause shows once its transfers have stopped
+	atomic.StoreInt32(&jm.atomicRunningInThisProcess, 1)
+	c.Assert(jm.isPauseVisible(), chk.Equals, false)
+	atomic.StoreInt32(&jm.atomicPauseCompleteIndicator, 1)
+	c.Assert(jm.isPauseVisible(), chk.Equals, true)
+}
+
+func (s *pauseDrainSuite) TestPauseDoesNotWaitForRunningChunks(c *chk.C) {
+	jm := &jobMgr{}
+	atomic.StoreInt32(&jm.atomicRunningInThisProcess, 1)
+	atomic.StoreInt64(&jm.atomicCurrentConcurrentConnections, 1)
+	stopped := make(chan struct{})
+
+	// the pause is accepted straight away, while a chunk is still running
+	c.Assert(jm.drainInFlightChunksThen(5*time.Second, func() { close(stopped) }), chk.Equals, true)
+	c.Assert(jm.isChunkProcessingHeld(), chk.Equals, true)
+	c.Assert(jm.drainInFlightChunksThen(5*time.Second, func() { c.Error("the job was stopped twice") }), chk.Equals, false)
+	select {
+	case <-stopped:
+		c.Fatal("the job was stopped before its running chunk finished")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	// and the job is stopped once the chunk finishes
+	atomic.StoreInt64(&jm.atomicCurrentConcurrentConnections, 0)
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		c.Fatal("the job was not stopped after its running chunk finished")
+	}
+	c.Assert(waitForChunksToFinish(func() int64 {
+		if jm.isChunkProcessingHeld() {
+			return 1
+		}
+		return 0
+	}, 5*time.Second), chk.Equals, true)
+}
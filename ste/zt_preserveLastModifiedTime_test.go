@@ -0,0 +1,101 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+type preserveLastModifiedTimeSuite struct{}
+
+var _ = chk.Suite(&preserveLastModifiedTimeSuite{})
+
+func (s *preserveLastModifiedTimeSuite) uploadedMetadata(c *chk.C, mtime time.Time, inMetadata bool) common.Metadata {
+	return s.uploadedMetadataWith(c, &testTransferMgr{lastModifiedTime: mtime, lastModifiedInMetadata: inMetadata, metadata: common.Metadata{"owner": "alice"}})
+}
+
+func (s *preserveLastModifiedTimeSuite) uploadedMetadataWith(c *chk.C, jptm *testTransferMgr) common.Metadata {
+	provider, err := newLocalSourceInfoProvider(jptm)
+	c.Assert(err, chk.IsNil)
+	props, err := provider.Properties()
+	c.Assert(err, chk.IsNil)
+	return props.SrcMetadata
+}
+
+func (s *preserveLastModifiedTimeSuite) TestLastModifiedTimeSurvivesUploadAndDownload(c *chk.C) {
+	// the file was last changed in a different time zone, long before it was uploaded
+	mtime := time.Date(2020, 7, 1, 9, 30, 15, 250000000, time.FixedZone("UTC-7", -7*60*60))
+	metadata := s.uploadedMetadata(c, mtime, true)
+	c.Assert(metadata["owner"], chk.Equals, "alice") // the user's own metadata is kept
+
+	// the blob itself was written just now, but the download should restore the file's time
+	blobLastModified := time.Now()
+	restored := lastModifiedTimeToPreserve(blobLastModified, metadata)
+
+	localPath := filepath.Join(c.MkDir(), "downloaded.txt")
+	c.Assert(ioutil.WriteFile(localPath, []byte("hello"), 0644), chk.IsNil)
+	c.Assert(os.Chtimes(localPath, restored, restored), chk.IsNil)
+	info, err := os.Stat(localPath)
+	c.Assert(err, chk.IsNil)
+
+	diff := info.ModTime().Sub(mtime)
+	c.Assert(diff < time.Second && diff > -time.Second, chk.Equals, true, chk.Commentf("restored %v, expected %v", info.ModTime(), mtime))
+}
+
+func (s *preserveLastModifiedTimeSuite) TestNothingRecordedUnlessRequested(c *chk.C) {
+	metadata := s.uploadedMetadata(c, time.Now(), false)
+	_, recorded := metadata[common.LastModifiedTimeMetadataKey]
+	c.Assert(recorded, chk.Equals, false)
+}
+
+func (s *preserveLastModifiedTimeSuite) TestDownloadFallsBackToBlobTime(c *chk.C) {
+	blobLastModified := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	c.Assert(lastModifiedTimeToPreserve(blobLastModified, common.Metadata{"owner": "alice"}), chk.Equals, blobLastModified)
+	c.Assert(lastModifiedTimeToPreserve(blobLastModified, nil), chk.Equals, blobLastModified)
+	c.Assert(lastModifiedTimeToPreserve(blobLastModified, common.Metadata{common.LastModifiedTimeMetadataKey: "garbage"}), chk.Equals, blobLastModified)
+}
+
+func (s *preserveLastModifiedTimeSuite) TestRecordedTimeWinsOverUserMetadata(c *chk.C) {
+	userMetadata, err := common.ParseMetadata("env=prod;team=data;AzCopy_Last_Modified_Time=whenever")
+	c.Assert(err, chk.IsNil)
+	mtime := time.Date(2020, 7, 1, 9, 30, 15, 0, time.UTC)
+
+	metadata := s.uploadedMetadataWith(c, &testTransferMgr{lastModifiedTime: mtime, lastModifiedInMetadata: true, metadata: userMetadata})
+	recorded, ok := metadata.LastModifiedTime()
+	c.Assert(ok, chk.Equals, true)
+	c.Assert(recorded.Equal(mtime), chk.Equals, true)
+	c.Assert(metadata, chk.DeepEquals, common.Metadata{
+		"env":                              "prod",
+		"team":                             "data",
+		common.LastModifiedTimeMetadataKey: mtime.Format(time.RFC3339Nano),
+	})
+
+	// without the flag, the user's own value goes through untouched
+	metadata = s.uploadedMetadataWith(c, &testTransferMgr{metadata: userMetadata})
+	c.Assert(metadata, chk.DeepEquals, userMetadata)
+}
@@ -0,0 +1,82 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package ste
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	chk "gopkg.in/check.v1"
+)
+
+type putMd5MetadataSuite struct{}
+
+var _ = chk.Suite(&putMd5MetadataSuite{})
+
+// noMd5TransferMgr is an upload that wasn't asked to put MD5s
+type noMd5TransferMgr struct {
+	testTransferMgr
+}
+
+func (t *noMd5TransferMgr) ShouldPutMd5() bool { return false }
+
+func (s *putMd5MetadataSuite) TestHashIsInHeaderAndMetadata(c *chk.C) {
+	content := []byte("downstream tools read the md5 metadata")
+	srcPath := filepath.Join(c.MkDir(), "hashed.txt")
+	c.Assert(ioutil.WriteFile(srcPath, content, 0644), chk.IsNil)
+
+	server := newTestBlobServer(nil)
+	defer server.Close()
+	jptm := &smallFileTransferMgr{testTransferMgr: testTransferMgr{info: TransferInfo{Source: srcPath, SourceSize: int64(len(content))}}}
+	uploader, err := newTestBlockBlobUploader(jptm, server.URL+"/container/hashed.txt")
+	c.Assert(err, chk.IsNil)
+	sourceMetadata := uploader.metadataToApply
+	uploader.metadataToApply["md5"] = "given with --metadata"
+
+	factory := func() (common.CloseableReaderAt, error) { return os.Open(srcPath) }
+	scheduleSmallFileSend(jptm, srcPath, int64(len(content)), uploader, factory)
+	jptm.smallFileSends[0](0)
+	c.Assert(jptm.failure, chk.IsNil)
+
+	hash := md5.Sum(content)
+	expected := base64.StdEncoding.EncodeToString(hash[:])
+	blob, _ := server.lastRequest(http.MethodPut, "/container/hashed.txt")
+	c.Assert(blob.header.Get("x-ms-blob-content-md5"), chk.Equals, expected)
+	c.Assert(blob.header.Get("x-ms-meta-md5"), chk.Equals, expected) // the computed hash wins over the given one
+	c.Assert(blob.header.Get("x-ms-meta-origin"), chk.Equals, "local")
+
+	// the transfer's own metadata is left as it was
+	c.Assert(sourceMetadata["md5"], chk.Equals, "given with --metadata")
+}
+
+func (s *putMd5MetadataSuite) TestNoMetadataUnlessAskedToPutMd5(c *chk.C) {
+	hash := md5.Sum([]byte("data"))
+	base := blockBlobSenderBase{jptm: &noMd5TransferMgr{}, metadataToApply: azblob.Metadata{"origin": "local"}}
+
+	base.setContentMD5(hash[:])
+	c.Assert(base.headersToApply.ContentMD5, chk.DeepEquals, hash[:])
+	c.Assert(base.metadataToApply, chk.DeepEquals, azblob.Metadata{"origin": "local"})
+}
@@ -0,0 +1,228 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// loadedJobPartMgr stands in for a job part whose plan file is loaded, and records whether it was closed
+type loadedJobPartMgr struct {
+	IJobPartMgr
+	plan   JobPartPlanHeader
+	closed bool
+}
+
+func (p *loadedJobPartMgr) Plan() *JobPartPlanHeader { return &p.plan }
+func (p *loadedJobPartMgr) Close()                   { p.closed = true }
+
+type closeRecordingLogger struct {
+	common.ILoggerResetable
+	common.ChunkStatusLoggerCloser
+	closed bool
+}
+
+func (l *closeRecordingLogger) CloseLog() { l.closed = true }
+func (l *closeRecordingLogger) FlushLog() {}
+
+// loadJob adds a job to JobsAdmin as though its plan files had been loaded, with part 0 in the given status
+func loadJob(status common.JobStatus) (common.JobID, *jobMgr, *loadedJobPartMgr, *closeRecordingLogger) {
+	jobID := common.NewJobID()
+	logger := &closeRecordingLogger{}
+	jm := &jobMgr{jobID: jobID, jobPartMgrs: newJobPartToJobPartMgr(), logger: logger, chunkStatusLogger: logger}
+	part := &loadedJobPartMgr{}
+	part.plan.SetJobStatus(status)
+	jm.jobPartMgrs.Set(0, part)
+	JobsAdmin.(*jobsAdmin).jobIDToJobMgr.Set(jobID, jm)
+	return jobID, jm, part, logger
+}
+
+type removeJobFilesSuite struct{}
+
+var _ = chk.Suite(&removeJobFilesSuite{})
+
+func (s *removeJobFilesSuite) jobs(now time.Time) []jobFilesInfo {
+	return []jobFilesInfo{
+		{jobID: common.NewJobID(), status: common.EJobStatus.Completed(), startTime: now.Add(-48 * time.Hour)},
+		{jobID: common.NewJobID(), status: common.EJobStatus.Failed(), startTime: now.Add(-48 * time.Hour)},
+		{jobID: common.NewJobID(), status: common.EJobStatus.Completed(), startTime: now.Add(-time.Hour)},
+		{jobID: common.NewJobID(), status: common.EJobStatus.InProgress(), startTime: now.Add(-48 * time.Hour)},
+		{jobID: common.NewJobID(), status: common.EJobStatus.Cancelling(), startTime: now.Add(-time.Hour)},
+	}
+}
+
+func (s *removeJobFilesSuite) TestSelectJobsFiltersByAge(c *chk.C) {
+	now := time.Now()
+	jobs := s.jobs(now)
+
+	toRemove, running := selectJobsToRemove(jobs, common.RemoveJobFilesRequest{WithStatus: common.EJobStatus.All(), OlderThan: 24 * time.Hour}, now)
+	c.Assert(toRemove, chk.DeepEquals, []common.JobID{jobs[0].jobID, jobs[1].jobID})
+	c.Assert(running, chk.DeepEquals, []common.JobID{jobs[3].jobID})
+
+	// no age given means jobs of any age
+	toRemove, _ = selectJobsToRemove(jobs, common.RemoveJobFilesRequest{WithStatus: common.EJobStatus.Completed()}, now)
+	c.Assert(toRemove, chk.DeepEquals, []common.JobID{jobs[0].jobID, jobs[2].jobID})
+}
+
+func (s *removeJobFilesSuite) TestSelectJobsNeverRemovesRunningJobs(c *chk.C) {
+	now := time.Now()
+	jobs := s.jobs(now)
+
+	toRemove, running := selectJobsToRemove(jobs, common.RemoveJobFilesRequest{WithStatus: common.EJobStatus.All()}, now)
+	c.Assert(toRemove, chk.DeepEquals, []common.JobID{jobs[0].jobID, jobs[1].jobID, jobs[2].jobID})
+	c.Assert(running, chk.DeepEquals, []common.JobID{jobs[3].jobID, jobs[4].jobID})
+
+	// asking for a running job by its ID doesn't remove it either
+	toRemove, running = selectJobsToRemove(jobs, common.RemoveJobFilesRequest{JobID: jobs[3].jobID, WithStatus: common.EJobStatus.All()}, now)
+	c.Assert(toRemove, chk.HasLen, 0)
+	c.Assert(running, chk.DeepEquals, []common.JobID{jobs[3].jobID})
+}
+
+func (s *removeJobFilesSuite) TestForceRemovesJobsNoProcessIsRunning(c *chk.C) {
+	now := time.Now()
+	jobs := s.jobs(now)
+	jobs[4].runningInThisProcess = true
+
+	// the in-progress job was left behind by a killed process, but the cancelling one is still being run by this one
+	toRemove, running := selectJobsToRemove(jobs, common.RemoveJobFilesRequest{WithStatus: common.EJobStatus.All(), Force: true}, now)
+	c.Assert(toRemove, chk.DeepEquals, []common.JobID{jobs[0].jobID, jobs[1].jobID, jobs[2].jobID, jobs[3].jobID})
+	c.Assert(running, chk.DeepEquals, []common.JobID{jobs[4].jobID})
+
+	toRemove, running = selectJobsToRemove(jobs, common.RemoveJobFilesRequest{JobID: jobs[3].jobID, WithStatus: common.EJobStatus.All(), Force: true}, now)
+	c.Assert(toRemove, chk.DeepEquals, []common.JobID{jobs[3].jobID})
+	c.Assert(running, chk.HasLen, 0)
+}
+
+func (s *removeJobFilesSuite) TestRemoveFilesWithPredicateCountsBytes(c *chk.C) {
+	dir := c.MkDir()
+	jobID := common.NewJobID().String()
+	files := map[string]int{
+		jobID + "--00000.steV18": 100,
+		jobID + "--00001.steV18": 50,
+		"other--00000.steV18":    10,
+	}
+	for name, size := range files {
+		c.Assert(ioutil.WriteFile(filepath.Join(dir, name), make([]byte, size), 0644), chk.IsNil)
+	}
+
+	count, bytes, err := removeFilesWithPredicate(dir, func(name string) bool { return strings.HasPrefix(name, jobID) })
+	c.Assert(err, chk.IsNil)
+	c.Assert(count, chk.Equals, 2)
+	c.Assert(bytes, chk.Equals, int64(150))
+
+	remaining, err := ioutil.ReadDir(dir)
+	c.Assert(err, chk.IsNil)
+	c.Assert(remaining, chk.HasLen, 1)
+	c.Assert(remaining[0].Name(), chk.Equals, "other--00000.steV18")
+}
+
+func (s *removeJobFilesSuite) TestUnloadingLeavesBusyJobsAlone(c *chk.C) {
+	defer withPlanDir(c.MkDir())()
+	JobsAdmin.(*jobsAdmin).jobIDToJobMgr = newJobIDToJobMgr()
+
+	finished, _, finishedPart, finishedLog := loadJob(common.EJobStatus.Completed())
+	ranHere, ranHereJm, ranHerePart, _ := loadJob(common.EJobStatus.Completed())
+	atomic.StoreInt32(&ranHereJm.atomicRunningInThisProcess, 1)
+	busy, busyJm, busyPart, busyLog := loadJob(common.EJobStatus.InProgress())
+	atomic.StoreInt32(&busyJm.atomicRunningInThisProcess, 1)
+	notAsked, _, notAskedPart, _ := loadJob(common.EJobStatus.Completed())
+
+	JobsAdmin.(*jobsAdmin).unloadJobs([]common.JobID{finished, ranHere, busy})
+
+	// the jobs that aren't being used are closed and forgotten, so they are loaded afresh when next needed
+	c.Assert(finishedPart.closed, chk.Equals, true)
+	c.Assert(finishedLog.closed, chk.Equals, true)
+	c.Assert(ranHerePart.closed, chk.Equals, true)
+	_, found := JobsAdmin.JobMgr(finished)
+	c.Assert(found, chk.Equals, false)
+	_, found = JobsAdmin.JobMgr(ranHere)
+	c.Assert(found, chk.Equals, false)
+
+	// but a job this process is still running keeps its plan files and log
+	c.Assert(busyPart.closed, chk.Equals, false)
+	c.Assert(busyLog.closed, chk.Equals, false)
+	_, found = JobsAdmin.JobMgr(busy)
+	c.Assert(found, chk.Equals, true)
+
+	// and so does a job that wasn't asked for
+	c.Assert(notAskedPart.closed, chk.Equals, false)
+	_, found = JobsAdmin.JobMgr(notAsked)
+	c.Assert(found, chk.Equals, true)
+}
+
+func (s *removeJobFilesSuite) TestResurrectingLeavesLoadedPartsAlone(c *chk.C) {
+	defer withPlanDir(c.MkDir())()
+	JobsAdmin.(*jobsAdmin).jobIDToJobMgr = newJobIDToJobMgr()
+
+	jobID, jm, part, _ := loadJob(common.EJobStatus.InProgress())
+	atomic.StoreInt32(&jm.atomicRunningInThisProcess, 1)
+	JobsAdmin.NewJobPartPlanFileName(jobID, 0).Create(common.CopyJobPartOrderRequest{
+		JobID:       jobID,
+		FromTo:      common.EFromTo.LocalBlob(),
+		IsFinalPart: true,
+		Transfers:   common.Transfers{List: []common.CopyTransfer{{Source: "/a.txt", Destination: "/a.txt", SourceSize: 1}}},
+	})
+
+	JobsAdmin.ResurrectJobParts()
+
+	// the running job's transfers still see the part they were using, not a fresh copy of its plan
+	loaded, found := jm.JobPartMgr(0)
+	c.Assert(found, chk.Equals, true)
+	c.Assert(loaded, chk.Equals, IJobPartMgr(part))
+}
+
+func (s *removeJobFilesSuite) TestOnlyRemovedJobsAreClosed(c *chk.C) {
+	dir := c.MkDir()
+	defer withPlanDir(dir)()
+	JobsAdmin.(*jobsAdmin).logDir = dir
+	JobsAdmin.(*jobsAdmin).jobIDToJobMgr = newJobIDToJobMgr()
+
+	removed, _, removedPart, removedLog := loadJob(common.EJobStatus.Completed())
+	kept, _, keptPart, keptLog := loadJob(common.EJobStatus.Completed())
+	for _, jobID := range []common.JobID{removed, kept} {
+		c.Assert(ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("%v--00000.steV%d", jobID, DataSchemaVersion)), nil, 0644), chk.IsNil)
+		c.Assert(ioutil.WriteFile(filepath.Join(dir, jobID.String()+".log"), nil, 0644), chk.IsNil)
+	}
+
+	resp := RemoveJobFiles(common.RemoveJobFilesRequest{JobID: removed, WithStatus: common.EJobStatus.All()})
+	c.Assert(resp.ErrorMsg, chk.Equals, "")
+	c.Assert(resp.RemovedJobs, chk.DeepEquals, []common.JobID{removed})
+	c.Assert(resp.FilesRemoved, chk.Equals, 2)
+	c.Assert(removedPart.closed, chk.Equals, true)
+	c.Assert(removedLog.closed, chk.Equals, true)
+	_, found := JobsAdmin.JobMgr(removed)
+	c.Assert(found, chk.Equals, false)
+
+	// the job the request didn't select is still loaded, and still usable
+	c.Assert(keptPart.closed, chk.Equals, false)
+	c.Assert(keptLog.closed, chk.Equals, false)
+	_, found = JobsAdmin.JobMgr(kept)
+	c.Assert(found, chk.Equals, true)
+}
@@ -0,0 +1,132 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"bytes"
+	"crypto/md5"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+type resumeDownloadSuite struct{}
+
+var _ = chk.Suite(&resumeDownloadSuite{})
+
+// download runs the blob, which holds content, through remoteToLocal_file, with the blob downloader, into dir/file
+func (s *resumeDownloadSuite) download(c *chk.C, blob *testBlobServer, content []byte, dir string) *testTransferMgr {
+	md5Sum := md5.Sum(content)
+	jptm := &testTransferMgr{
+		status: common.ETransferStatus.Started(),
+		info: TransferInfo{
+			Source:        blob.URL + "/container/file",
+			Destination:   filepath.Join(dir, "file"),
+			SourceSize:    int64(len(content)),
+			BlockSize:     4,
+			SrcBlobType:   azblob.BlobBlockBlob,
+			SrcProperties: SrcProperties{SrcHTTPHeaders: common.ResourceHTTPHeaders{ContentMD5: md5Sum[:]}},
+		},
+	}
+	startTestDownload(jptm)
+	jptm.runChunks()
+	c.Assert(jptm.finished, chk.Equals, true)
+	return jptm
+}
+
+// keepHalfDownloaded leaves dir as a paused download of the first half of content would have
+func keepHalfDownloaded(c *chk.C, info TransferInfo, content []byte, etag string) {
+	c.Assert(ioutil.WriteFile(info.getTempDownloadPath(), content[:len(content)/2], 0666), chk.IsNil)
+	c.Assert(ioutil.WriteFile(info.getResumeETagPath(), []byte(etag), 0666), chk.IsNil)
+}
+
+func (s *resumeDownloadSuite) TestResumeFetchesOnlyMissingTail(c *chk.C) {
+	content := []byte("0123456789abcdefghij") // five chunks of four bytes
+	blob := newTestBlobWithContent(content, `"etag1"`)
+	defer blob.Close()
+	dir := c.MkDir()
+	keepHalfDownloaded(c, TransferInfo{Destination: filepath.Join(dir, "file")}, content, `"etag1"`)
+
+	jptm := s.download(c, blob, content, dir)
+	c.Assert(jptm.failure, chk.IsNil)
+	// 10 bytes were kept, so the first two chunks are reused, and the half-chunk after them is downloaded again
+	c.Assert(blob.ranges(), chk.DeepEquals, []string{"bytes=8-11", "bytes=12-15", "bytes=16-19"})
+
+	downloaded, err := ioutil.ReadFile(filepath.Join(dir, "file"))
+	c.Assert(err, chk.IsNil)
+	c.Assert(downloaded, chk.DeepEquals, content)
+	md5Sum := md5.Sum(content)
+	c.Assert(jptm.contentMD5, chk.DeepEquals, md5Sum[:]) // the hash covers the part that was kept too
+	_, err = os.Stat(jptm.info.getResumeETagPath())
+	c.Assert(os.IsNotExist(err), chk.Equals, true)
+}
+
+func (s *resumeDownloadSuite) TestChangedSourceRestartsFromBeginning(c *chk.C) {
+	content := []byte("0123456789abcdefghij")
+	blob := newTestBlobWithContent(content, `"etag2"`)
+	defer blob.Close()
+	dir := c.MkDir()
+	keepHalfDownloaded(c, TransferInfo{Destination: filepath.Join(dir, "file")}, bytes.Repeat([]byte("x"), len(content)), `"etag1"`)
+
+	jptm := s.download(c, blob, content, dir)
+	c.Assert(jptm.failure, chk.IsNil)
+	c.Assert(blob.ranges(), chk.HasLen, 5)
+	downloaded, err := ioutil.ReadFile(filepath.Join(dir, "file"))
+	c.Assert(err, chk.IsNil)
+	c.Assert(downloaded, chk.DeepEquals, content)
+}
+
+func (s *resumeDownloadSuite) TestPausedDownloadIsKept(c *chk.C) {
+	dir := c.MkDir()
+	info := TransferInfo{Destination: filepath.Join(dir, "file")}
+	c.Assert(ioutil.WriteFile(info.getTempDownloadPath(), make([]byte, 20), 0666), chk.IsNil) // pre-sized, as new downloads are
+	jptm := &testTransferMgr{info: info}
+	bd := newBlobDownloader().(*blobDownloader)
+
+	// nothing to keep until some data has arrived, since until then we don't know which version of the blob it is
+	c.Assert(keepPartialDownload(jptm, bd, 8), chk.Equals, false)
+
+	bd.sourceETag.Store(`"etag1"`)
+	c.Assert(keepPartialDownload(jptm, bd, 8), chk.Equals, true)
+	fi, err := os.Stat(info.getTempDownloadPath())
+	c.Assert(err, chk.IsNil)
+	c.Assert(fi.Size(), chk.Equals, int64(8))
+	etag, err := ioutil.ReadFile(info.getResumeETagPath())
+	c.Assert(err, chk.IsNil)
+	c.Assert(string(etag), chk.Equals, `"etag1"`)
+}
+
+func (s *resumeDownloadSuite) TestFailedDownloadIsDeleted(c *chk.C) {
+	content := []byte("0123456789abcdefghij")
+	blob := newTestBlobWithContent(content, `"etag1"`)
+	blob.Close() // every request fails
+	dir := c.MkDir()
+
+	jptm := s.download(c, blob, content, dir)
+	c.Assert(jptm.failure, chk.NotNil)
+	_, err := os.Stat(jptm.info.getTempDownloadPath())
+	c.Assert(os.IsNotExist(err), chk.Equals, true)
+}
@@ -0,0 +1,82 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"time"
+
+	chk "gopkg.in/check.v1"
+)
+
+type retryJitterSuite struct{}
+
+var _ = chk.Suite(&retryJitterSuite{})
+
+func (s *retryJitterSuite) uploadRetryOptions(jitter float32) XferRetryOptions {
+	return XferRetryOptions{
+		MaxTries:      UploadMaxTries,
+		TryTimeout:    UploadTryTimeout,
+		RetryDelay:    UploadRetryDelay,
+		MaxRetryDelay: UploadMaxRetryDelay,
+		RetryJitter:   jitter,
+	}.defaults()
+}
+
+func (s *retryJitterSuite) TestRetryDelaysAreSpreadOut(c *chk.C) {
+	o := s.uploadRetryOptions(0) // default jitter
+	c.Assert(o.RetryJitter, chk.Equals, DefaultRetryJitter)
+
+	// try 10 is well past the point where the exponential delay reaches MaxRetryDelay,
+	// which is where synchronized retries would otherwise all land on the same value
+	for _, try := range []int32{2, 4, 10} {
+		seen := map[time.Duration]bool{}
+		min, max := time.Duration(1<<62), time.Duration(0)
+		for i := 0; i < 200; i++ {
+			d := o.calcDelay(try)
+			c.Assert(d >= o.RetryDelay && d <= o.MaxRetryDelay, chk.Equals, true, chk.Commentf("try %d delay %v", try, d))
+			seen[d] = true
+			if d < min {
+				min = d
+			}
+			if d > max {
+				max = d
+			}
+		}
+		c.Assert(len(seen) > 100, chk.Equals, true, chk.Commentf("try %d only had %d distinct delays", try, len(seen)))
+		c.Assert(max-min > o.RetryDelay/10, chk.Equals, true, chk.Commentf("try %d delays only ranged from %v to %v", try, min, max))
+	}
+
+	// the capped delays are spread over the top quarter of the allowed range
+	for i := 0; i < 200; i++ {
+		d := o.calcDelay(10)
+		c.Assert(d >= o.MaxRetryDelay*3/4, chk.Equals, true)
+	}
+
+	// first try never waits
+	c.Assert(o.calcDelay(1), chk.Equals, time.Duration(0))
+}
+
+func (s *retryJitterSuite) TestRetryJitterCanBeTurnedOff(c *chk.C) {
+	o := s.uploadRetryOptions(-1)
+	c.Assert(o.calcDelay(2), chk.Equals, o.RetryDelay)
+	c.Assert(o.calcDelay(3), chk.Equals, 3*o.RetryDelay)
+	c.Assert(o.calcDelay(10), chk.Equals, o.MaxRetryDelay)
+}
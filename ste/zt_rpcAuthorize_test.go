@@ -0,0 +1,89 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+type rpcAuthorizeSuite struct{}
+
+var _ = chk.Suite(&rpcAuthorizeSuite{})
+
+// echoHandler answers 202 with the body it was given, to show that the body survives being checked
+func echoHandler(writer http.ResponseWriter, request *http.Request) {
+	body, _ := ioutil.ReadAll(request.Body)
+	writer.WriteHeader(http.StatusAccepted)
+	_, _ = writer.Write(body)
+}
+
+func (s *rpcAuthorizeSuite) serve(auth *common.RpcAuthenticator, authorization string, payload []byte) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, common.ERpcCmd.ListJobSummary().Pattern(), bytes.NewReader(payload))
+	if authorization != "" {
+		request.Header.Set("Authorization", authorization)
+	}
+	recorder := httptest.NewRecorder()
+	authorizeRpc(auth, common.ERpcCmd.ListJobSummary(), echoHandler)(recorder, request)
+	return recorder
+}
+
+func (s *rpcAuthorizeSuite) TestSignedRequestIsHandled(c *chk.C) {
+	auth, err := common.NewRpcAuthenticatorFromFile(filepath.Join(c.MkDir(), "secret"))
+	c.Assert(err, chk.IsNil)
+	payload := []byte(`{"JobID":"abc"}`)
+
+	recorder := s.serve(auth, auth.AuthorizationHeader(common.ERpcCmd.ListJobSummary(), payload), payload)
+	c.Assert(recorder.Code, chk.Equals, http.StatusAccepted)
+	c.Assert(recorder.Body.String(), chk.Equals, string(payload))
+}
+
+func (s *rpcAuthorizeSuite) TestMissingOrBadTokenIsRejected(c *chk.C) {
+	dir := c.MkDir()
+	auth, err := common.NewRpcAuthenticatorFromFile(filepath.Join(dir, "secret"))
+	c.Assert(err, chk.IsNil)
+	stranger, err := common.NewRpcAuthenticatorFromFile(filepath.Join(dir, "otherSecret"))
+	c.Assert(err, chk.IsNil)
+	payload := []byte(`{"JobID":"abc"}`)
+
+	for _, authorization := range []string{
+		"",             // missing
+		"AzCopyRPC !!", // malformed
+		stranger.AuthorizationHeader(common.ERpcCmd.ListJobSummary(), payload), // signed with another secret
+		auth.AuthorizationHeader(common.ERpcCmd.CancelJob(), payload),          // signed for another command
+	} {
+		recorder := s.serve(auth, authorization, payload)
+		c.Assert(recorder.Code, chk.Equals, http.StatusUnauthorized, chk.Commentf("Authorization: %q", authorization))
+		c.Assert(recorder.Body.Len(), chk.Equals, 0)
+	}
+}
+
+func (s *rpcAuthorizeSuite) TestNoAuthenticatorMeansNoCheck(c *chk.C) {
+	recorder := s.serve(nil, "", []byte(`{}`))
+	c.Assert(recorder.Code, chk.Equals, http.StatusAccepted)
+}
@@ -0,0 +1,83 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+type rpcServeSuite struct{}
+
+var _ = chk.Suite(&rpcServeSuite{})
+
+// serveRpcForTest serves the RPC handlers on a free local port, and returns the URL to send commands to
+func serveRpcForTest(c *chk.C, auth *common.RpcAuthenticator) (url string, stop func()) {
+	previousHandler, previousAdmin := rpcHandler, JobsAdmin
+	rpcHandler = newRpcHandler(auth)
+	JobsAdmin = &jobsAdmin{}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, chk.IsNil)
+	go ServeRPC(listener)
+
+	return "http://" + listener.Addr().String(), func() {
+		listener.Close()
+		rpcHandler, JobsAdmin = previousHandler, previousAdmin
+	}
+}
+
+func (s *rpcServeSuite) TestServedCommandIsAnswered(c *chk.C) {
+	url, stop := serveRpcForTest(c, nil)
+	defer stop()
+
+	response, err := http.Post(url+common.ERpcCmd.Ping().Pattern(), "application/json", strings.NewReader("null"))
+	c.Assert(err, chk.IsNil)
+	defer response.Body.Close()
+	c.Assert(response.StatusCode, chk.Equals, http.StatusAccepted)
+
+	body, err := ioutil.ReadAll(response.Body)
+	c.Assert(err, chk.IsNil)
+	var ping common.PingResponse
+	c.Assert(json.Unmarshal(body, &ping), chk.IsNil)
+	c.Assert(ping.Version, chk.Equals, common.AzcopyVersion)
+	c.Assert(ping.ActiveJobs, chk.Equals, 0)
+}
+
+func (s *rpcServeSuite) TestServedCommandsAreAuthorized(c *chk.C) {
+	auth, err := common.NewRpcAuthenticatorFromFile(filepath.Join(c.MkDir(), "secret"))
+	c.Assert(err, chk.IsNil)
+	url, stop := serveRpcForTest(c, auth)
+	defer stop()
+
+	response, err := http.Post(url+common.ERpcCmd.Ping().Pattern(), "application/json", strings.NewReader("null"))
+	c.Assert(err, chk.IsNil)
+	response.Body.Close()
+	c.Assert(response.StatusCode, chk.Equals, http.StatusUnauthorized)
+}
@@ -0,0 +1,76 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	chk "gopkg.in/check.v1"
+)
+
+type scheduledPacerSuite struct{}
+
+var _ = chk.Suite(&scheduledPacerSuite{})
+
+func (s *scheduledPacerSuite) TestCapChangesAtWindowBoundary(c *chk.C) {
+	schedule, err := common.ParseCapMbpsSchedule("08:00-18:00=50,18:00-08:00=0")
+	c.Assert(err, chk.IsNil)
+
+	now := time.Date(2022, 3, 14, 7, 59, 59, 0, time.Local)
+	p := newScheduledPacer(schedule, func() time.Time { return now })
+	defer p.Close()
+
+	// overnight, there's no cap, so even a huge request is allowed straight away, and is still counted
+	c.Assert(p.isCapped(), chk.Equals, false)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	c.Assert(p.RequestTrafficAllocation(ctx, 1024*1024*1024), chk.IsNil)
+	c.Assert(p.GetTotalTraffic(), chk.Equals, int64(1024*1024*1024))
+
+	// once the working day starts, the cap applies
+	now = time.Date(2022, 3, 14, 8, 0, 0, 0, time.Local)
+	p.applySchedule()
+	c.Assert(p.isCapped(), chk.Equals, true)
+	c.Assert(p.targetBytesPerSecond(), chk.Equals, int64(50*1000*1000/8))
+
+	// a request that would take far longer than our deadline now has to wait for the bucket
+	err = p.RequestTrafficAllocation(ctx, 1024*1024*1024)
+	c.Assert(err, chk.Equals, context.DeadlineExceeded)
+
+	// and it's lifted again in the evening
+	now = time.Date(2022, 3, 14, 18, 0, 0, 0, time.Local)
+	p.applySchedule()
+	c.Assert(p.isCapped(), chk.Equals, false)
+}
+
+func (s *scheduledPacerSuite) TestStartsInCappedWindow(c *chk.C) {
+	schedule, err := common.ParseCapMbpsSchedule("22:00-06:00=8")
+	c.Assert(err, chk.IsNil)
+
+	now := time.Date(2022, 3, 14, 1, 30, 0, 0, time.Local) // inside the window that wraps midnight
+	p := newScheduledPacer(schedule, func() time.Time { return now })
+	defer p.Close()
+
+	c.Assert(p.isCapped(), chk.Equals, true)
+	c.Assert(p.targetBytesPerSecond(), chk.Equals, int64(1000*1000))
+}
@@ -0,0 +1,281 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+type smallFileUploadSuite struct{}
+
+var _ = chk.Suite(&smallFileUploadSuite{})
+
+// smallFileTransferMgr is a testTransferMgr that also records what is handed to the small-file pool
+type smallFileTransferMgr struct {
+	testTransferMgr
+	smallFileSends []chunkFunc
+	chunksDone     int
+}
+
+func (t *smallFileTransferMgr) ScheduleSmallFileSend(cf chunkFunc) {
+	t.smallFileSends = append(t.smallFileSends, cf)
+}
+func (t *smallFileTransferMgr) ReportChunkDone(common.ChunkID) (bool, uint32) {
+	t.chunksDone++
+	return true, uint32(t.chunksDone)
+}
+
+type notAnUploader struct {
+	sender
+}
+
+type remoteSourceInfoProvider struct {
+	ISourceInfoProvider
+}
+
+func (remoteSourceInfoProvider) IsLocal() bool { return false }
+
+func (s *smallFileUploadSuite) TestIsSmallFileUpload(c *chk.C) {
+	const threshold = 10 * 1024
+	oneChunk := &blockBlobUploader{blockBlobSenderBase: blockBlobSenderBase{numChunks: 1}}
+	twoChunks := &blockBlobUploader{blockBlobSenderBase: blockBlobSenderBase{numChunks: 2}}
+	compressing := &blockBlobCompressingUploader{blockBlobSenderBase: blockBlobSenderBase{numChunks: 1}}
+
+	c.Assert(isSmallFileUpload(oneChunk, localSourceInfoProvider{}, threshold, threshold), chk.Equals, true)
+	c.Assert(isSmallFileUpload(oneChunk, localSourceInfoProvider{}, 0, threshold), chk.Equals, true)
+
+	c.Assert(isSmallFileUpload(oneChunk, localSourceInfoProvider{}, threshold+1, threshold), chk.Equals, false)
+	c.Assert(isSmallFileUpload(oneChunk, localSourceInfoProvider{}, 10, 0), chk.Equals, false)          // turned off
+	c.Assert(isSmallFileUpload(twoChunks, localSourceInfoProvider{}, 10, threshold), chk.Equals, false) // tiny blocks
+	c.Assert(isSmallFileUpload(compressing, localSourceInfoProvider{}, 10, threshold), chk.Equals, false)
+	c.Assert(isSmallFileUpload(oneChunk, remoteSourceInfoProvider{}, 10, threshold), chk.Equals, false)
+	c.Assert(isSmallFileUpload(notAnUploader{}, localSourceInfoProvider{}, 10, threshold), chk.Equals, false)
+}
+
+func (s *smallFileUploadSuite) TestSmallFileIsReadAndSentByThePool(c *chk.C) {
+	content := make([]byte, 10*1024)
+	for i := range content {
+		content[i] = byte(i)
+	}
+	srcPath := filepath.Join(c.MkDir(), "small.bin")
+	c.Assert(ioutil.WriteFile(srcPath, content, 0644), chk.IsNil)
+
+	server := newTestBlobServer(nil)
+	defer server.Close()
+	jptm := &smallFileTransferMgr{testTransferMgr: testTransferMgr{info: TransferInfo{Source: srcPath, SourceSize: int64(len(content))}}}
+	uploader, err := newTestBlockBlobUploader(jptm, server.URL+"/container/small.bin")
+	c.Assert(err, chk.IsNil)
+	factory := func() (common.CloseableReaderAt, error) { return os.Open(srcPath) }
+
+	// nothing is read or sent by transfer initiation; it's all left to the pool
+	scheduleSmallFileSend(jptm, srcPath, int64(len(content)), uploader, factory)
+	c.Assert(jptm.chunks, chk.HasLen, 0)
+	c.Assert(jptm.smallFileSends, chk.HasLen, 1)
+	c.Assert(server.received(), chk.HasLen, 0)
+
+	jptm.smallFileSends[0](0)
+	c.Assert(jptm.failure, chk.IsNil)
+	c.Assert(jptm.chunksDone, chk.Equals, 1)
+
+	expectedMD5 := md5.Sum(content)
+	c.Assert(jptm.contentMD5, chk.DeepEquals, expectedMD5[:])
+	blob, ok := server.lastRequest(http.MethodPut, "/container/small.bin")
+	c.Assert(ok, chk.Equals, true)
+	c.Assert(blob.body, chk.HasLen, len(content))
+	c.Assert(blob.header.Get("x-ms-blob-content-md5"), chk.Equals, base64.StdEncoding.EncodeToString(expectedMD5[:]))
+	c.Assert(blob.header.Get("x-ms-meta-origin"), chk.Equals, "local")
+}
+
+func (s *smallFileUploadSuite) TestUnreadableSmallFileFailsTheTransfer(c *chk.C) {
+	srcPath := filepath.Join(c.MkDir(), "gone.txt") // never created
+
+	server := newTestBlobServer(nil)
+	defer server.Close()
+	jptm := &smallFileTransferMgr{testTransferMgr: testTransferMgr{info: TransferInfo{Source: srcPath, SourceSize: 5}}}
+	uploader, err := newTestBlockBlobUploader(jptm, server.URL+"/container/gone.txt")
+	c.Assert(err, chk.IsNil)
+	factory := func() (common.CloseableReaderAt, error) { return os.Open(srcPath) }
+
+	scheduleSmallFileSend(jptm, srcPath, 5, uploader, factory)
+	c.Assert(jptm.smallFileSends, chk.HasLen, 1)
+	jptm.smallFileSends[0](0)
+
+	c.Assert(jptm.failure, chk.NotNil)
+	c.Assert(os.IsNotExist(jptm.failure), chk.Equals, true)
+	c.Assert(jptm.chunksDone, chk.Equals, 1) // the transfer can still finish
+	c.Assert(server.received(), chk.HasLen, 0)
+}
+
+// The benchmark uploads the same set of small files the old way (prefetched by transfer initiation, sent by the main pool)
+// and through the small-file pool. Run it with:
+//
+//	go test ./ste -run XXX -bench SmallFileUpload -benchtime 1x
+const smallFileBenchmarkCount = 100000
+const smallFileBenchmarkSize = 10 * 1024
+
+// smallFileBenchmarkLatency stands in for the round trip to the service, which is what really limits small file uploads
+const smallFileBenchmarkLatency = 5 * time.Millisecond
+
+// pooledTransferMgr feeds its chunk funcs to goroutine pools, as the real jobsAdmin does, and says when it's done
+type pooledTransferMgr struct {
+	testTransferMgr
+	pools *benchmarkPools
+}
+
+func (t *pooledTransferMgr) ScheduleChunks(cf chunkFunc)          { t.pools.mainCh <- cf }
+func (t *pooledTransferMgr) ScheduleChunksInOrder(cf chunkFunc)   { t.pools.mainCh <- cf }
+func (t *pooledTransferMgr) ScheduleSmallFileSend(cf chunkFunc)   { t.pools.smallFileCh <- cf }
+func (t *pooledTransferMgr) SlicePool() common.ByteSlicePooler    { return t.pools.slicePool }
+func (t *pooledTransferMgr) CacheLimiter() common.CacheLimiter    { return t.pools.cacheLimiter }
+func (t *pooledTransferMgr) FailActiveSend(_ string, err error)   { t.pools.fail(err) }
+func (t *pooledTransferMgr) FailActiveUpload(_ string, err error) { t.pools.fail(err) }
+func (t *pooledTransferMgr) SetContentMD5([]byte)                 {}
+func (t *pooledTransferMgr) ReportChunkDone(common.ChunkID) (bool, uint32) {
+	t.pools.filesDone.Done()
+	return true, 1
+}
+
+type benchmarkPools struct {
+	mainCh       chan chunkFunc
+	smallFileCh  chan chunkFunc
+	slicePool    common.ByteSlicePooler
+	cacheLimiter common.CacheLimiter
+	filesDone    sync.WaitGroup
+	mu           sync.Mutex
+	firstErr     error
+}
+
+func (p *benchmarkPools) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.firstErr == nil {
+		p.firstErr = err
+	}
+}
+
+func BenchmarkSmallFileUpload(b *testing.B) {
+	dir := b.TempDir()
+	content := make([]byte, smallFileBenchmarkSize)
+	files := make([]string, smallFileBenchmarkCount)
+	for i := range files {
+		files[i] = filepath.Join(dir, fmt.Sprintf("file%06d", i))
+		if err := ioutil.WriteFile(files[i], content, 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = ioutil.ReadAll(req.Body)
+		time.Sleep(smallFileBenchmarkLatency)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	b.Run("mainPool", func(b *testing.B) { benchmarkSmallFileUpload(b, server.URL, files, false) })
+	b.Run("smallFilePool", func(b *testing.B) { benchmarkSmallFileUpload(b, server.URL, files, true) })
+}
+
+func benchmarkSmallFileUpload(b *testing.B, serverURL string, files []string, useSmallFilePool bool) {
+	mainPoolSize, _, _ := getMainPoolSize(runtime.NumCPU(), false)
+
+	for n := 0; n < b.N; n++ {
+		pools := &benchmarkPools{
+			mainCh:       make(chan chunkFunc, len(files)),
+			smallFileCh:  make(chan chunkFunc, len(files)),
+			slicePool:    common.NewMultiSizeSlicePool(common.MaxBlockBlobBlockSize),
+			cacheLimiter: common.NewCacheLimiter(getMaxRamForChunks()),
+		}
+		pools.filesDone.Add(len(files))
+		runPool := func(size int, ch chan chunkFunc) {
+			for i := 0; i < size; i++ {
+				go func(workerID int) {
+					for cf := range ch {
+						cf(workerID)
+					}
+				}(i)
+			}
+		}
+		runPool(mainPoolSize, pools.mainCh)
+		runPool(defaultSmallFilePoolSize, pools.smallFileCh)
+
+		start := time.Now()
+		toInitiate := make(chan string, len(files))
+		for _, f := range files {
+			toInitiate <- f
+		}
+		close(toInitiate)
+		for i := 0; i < defaultTransferInitiationPoolSize; i++ {
+			go func() {
+				for srcPath := range toInitiate {
+					initiateSmallFileUpload(pools, serverURL, srcPath, useSmallFilePool)
+				}
+			}()
+		}
+
+		pools.filesDone.Wait()
+		elapsed := time.Since(start)
+		close(pools.mainCh)
+		close(pools.smallFileCh)
+		if pools.firstErr != nil {
+			b.Fatal(pools.firstErr)
+		}
+		b.ReportMetric(float64(len(files))/elapsed.Seconds(), "files/s")
+	}
+}
+
+// initiateSmallFileUpload does what anyToRemote_file does for a small local file, by one route or the other
+func initiateSmallFileUpload(pools *benchmarkPools, serverURL string, srcPath string, useSmallFilePool bool) {
+	jptm := &pooledTransferMgr{
+		testTransferMgr: testTransferMgr{info: TransferInfo{Source: srcPath, SourceSize: smallFileBenchmarkSize}},
+		pools:           pools,
+	}
+	uploader, err := newTestBlockBlobUploader(jptm, serverURL+"/container/"+filepath.Base(srcPath))
+	if err != nil {
+		panic(err)
+	}
+	factory := func() (common.CloseableReaderAt, error) { return os.Open(srcPath) }
+
+	if useSmallFilePool {
+		scheduleSmallFileSend(jptm, srcPath, smallFileBenchmarkSize, uploader, factory)
+		return
+	}
+	srcFile, err := factory()
+	if err != nil {
+		panic(err)
+	}
+	defer srcFile.Close()
+	scheduleSendChunks(jptm, srcPath, srcFile, smallFileBenchmarkSize, uploader, factory, localSourceInfoProvider{})
+}
@@ -0,0 +1,75 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"os"
+
+	"github.com/Azure/azure-storage-file-go/azfile"
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/sddl"
+)
+
+type smbPermissionMappingSuite struct{}
+
+var _ = chk.Suite(&smbPermissionMappingSuite{})
+
+func (s *smbPermissionMappingSuite) TestPermissionsFromPOSIX(c *chk.C) {
+	for _, t := range []struct {
+		mode    os.FileMode
+		sddl    string
+		inexact int
+	}{
+		{0644, "O:S-1-22-1-1000G:S-1-22-2-100D:P(A;;FRFW;;;S-1-22-1-1000)(A;;FR;;;S-1-22-2-100)(A;;FR;;;S-1-1-0)", 0},
+		// rwx isn't full control, and children inherit the permissions of folders
+		{os.ModeDir | 0755, "O:S-1-22-1-1000G:S-1-22-2-100D:P(A;OICI;FRFWFX;;;S-1-22-1-1000)(A;OICI;FRFX;;;S-1-22-2-100)(A;OICI;FRFX;;;S-1-1-0)", 0},
+		{0700, "O:S-1-22-1-1000G:S-1-22-2-100D:P(A;;FRFWFX;;;S-1-22-1-1000)", 0},
+		{0600, "O:S-1-22-1-1000G:S-1-22-2-100D:P(A;;FRFW;;;S-1-22-1-1000)", 0},
+		{0000, "O:S-1-22-1-1000G:S-1-22-2-100D:P", 0},
+		// the owner can't be denied what the group gets
+		{0470, "O:S-1-22-1-1000G:S-1-22-2-100D:P(A;;FR;;;S-1-22-1-1000)(A;;FRFWFX;;;S-1-22-2-100)", 1},
+		// nor the owner and the group what everyone gets
+		{0604, "O:S-1-22-1-1000G:S-1-22-2-100D:P(A;;FRFW;;;S-1-22-1-1000)(A;;FR;;;S-1-1-0)", 1},
+		{os.ModeSetuid | os.ModeSticky | 0755, "O:S-1-22-1-1000G:S-1-22-2-100D:P(A;;FRFWFX;;;S-1-22-1-1000)(A;;FRFX;;;S-1-22-2-100)(A;;FRFX;;;S-1-1-0)", 1},
+	} {
+		sddlString, inexact := smbPermissionsFromPOSIX(t.mode, 1000, 100)
+		c.Check(sddlString, chk.Equals, t.sddl, chk.Commentf("mode %s", t.mode))
+		c.Check(inexact, chk.HasLen, t.inexact, chk.Commentf("mode %s", t.mode))
+
+		// what we send must be an SDDL the service can parse
+		parsed, err := sddl.ParseSDDL(sddlString)
+		c.Assert(err, chk.IsNil, chk.Commentf("mode %s", t.mode))
+		c.Check(parsed.PortableString(), chk.Equals, sddlString)
+	}
+}
+
+func (s *smbPermissionMappingSuite) TestAttributesFromPOSIX(c *chk.C) {
+	c.Assert(smbAttributesFromPOSIX(0644, "a.txt"), chk.Equals, azfile.FileAttributeNone)
+	c.Assert(smbAttributesFromPOSIX(0444, "a.txt"), chk.Equals, azfile.FileAttributeReadonly)
+	c.Assert(smbAttributesFromPOSIX(0600, ".profile"), chk.Equals, azfile.FileAttributeHidden)
+	c.Assert(smbAttributesFromPOSIX(0400, ".netrc"), chk.Equals, azfile.FileAttributeReadonly|azfile.FileAttributeHidden)
+
+	// folders are never read-only, and the current folder isn't hidden
+	c.Assert(smbAttributesFromPOSIX(os.ModeDir|0555, "dir"), chk.Equals, azfile.FileAttributeNone)
+	c.Assert(smbAttributesFromPOSIX(os.ModeDir|0755, ".git"), chk.Equals, azfile.FileAttributeHidden)
+	c.Assert(smbAttributesFromPOSIX(os.ModeDir|0755, "."), chk.Equals, azfile.FileAttributeNone)
+}
@@ -0,0 +1,110 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package ste
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+type snapshotBeforeOverwriteSuite struct{}
+
+var _ = chk.Suite(&snapshotBeforeOverwriteSuite{})
+
+// snapshottingTransferMgr is a testTransferMgr that records how its transfer ended
+type snapshottingTransferMgr struct {
+	testTransferMgr
+	status    common.TransferStatus
+	done      bool
+	sendError string
+	logged    []string
+}
+
+func (t *snapshottingTransferMgr) SetStatus(status common.TransferStatus) { t.status = status }
+func (t *snapshottingTransferMgr) ReportTransferDone() uint32             { t.done = true; return 0 }
+func (t *snapshottingTransferMgr) LogSendError(_, _, errorMessage string, _ int) {
+	t.sendError = errorMessage
+}
+func (t *snapshottingTransferMgr) LogAtLevelForCurrentTransfer(_ pipeline.LogLevel, msg string) {
+	t.logged = append(t.logged, msg)
+}
+
+// newSnapshotServer fakes the Snapshot Blob operation, answering with the given status code
+func newSnapshotServer(c *chk.C, statusCode int) (server *httptest.Server, snapshotsTaken *int) {
+	snapshotsTaken = new(int)
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, chk.Equals, http.MethodPut)
+		c.Check(r.URL.Query().Get("comp"), chk.Equals, "snapshot")
+		*snapshotsTaken++
+		if statusCode == http.StatusCreated {
+			w.Header().Set("x-ms-snapshot", "2026-10-15T00:00:00.0000000Z")
+		}
+		w.WriteHeader(statusCode)
+	}))
+	return server, snapshotsTaken
+}
+
+func (s *snapshotBeforeOverwriteSuite) TestBlobSendersCanSnapshotTheirDestination(c *chk.C) {
+	var _ destinationSnapshotter = &blockBlobSenderBase{}
+	var _ destinationSnapshotter = &pageBlobSenderBase{}
+	var _ destinationSnapshotter = &appendBlobSenderBase{}
+}
+
+func (s *snapshotBeforeOverwriteSuite) TestDestinationIsSnapshotted(c *chk.C) {
+	server, snapshotsTaken := newSnapshotServer(c, http.StatusCreated)
+	defer server.Close()
+
+	jptm := &snapshottingTransferMgr{}
+	uploader, err := newTestBlockBlobUploader(jptm, server.URL+"/container/blob")
+	c.Assert(err, chk.IsNil)
+
+	c.Assert(snapshotDestination(jptm, TransferInfo{}, uploader), chk.Equals, true)
+	c.Assert(*snapshotsTaken, chk.Equals, 1)
+	c.Assert(jptm.done, chk.Equals, false)
+	c.Assert(jptm.logged, chk.DeepEquals, []string{"Created snapshot 2026-10-15T00:00:00.0000000Z of the destination before overwriting it"})
+}
+
+func (s *snapshotBeforeOverwriteSuite) TestFailedSnapshotFailsTheTransfer(c *chk.C) {
+	server, snapshotsTaken := newSnapshotServer(c, http.StatusForbidden)
+	defer server.Close()
+
+	jptm := &snapshottingTransferMgr{}
+	uploader, err := newTestBlockBlobUploader(jptm, server.URL+"/container/blob")
+	c.Assert(err, chk.IsNil)
+
+	c.Assert(snapshotDestination(jptm, TransferInfo{}, uploader), chk.Equals, false)
+	c.Assert(*snapshotsTaken, chk.Equals, 1)
+	c.Assert(jptm.done, chk.Equals, true)
+	c.Assert(jptm.status, chk.Equals, common.ETransferStatus.Failed())
+	c.Assert(jptm.sendError, chk.Matches, "(?s)Could not snapshot the destination before overwriting it.*")
+}
+
+func (s *snapshotBeforeOverwriteSuite) TestDestinationThatCannotBeSnapshottedIsNotOverwritten(c *chk.C) {
+	jptm := &snapshottingTransferMgr{}
+
+	c.Assert(snapshotDestination(jptm, TransferInfo{}, &notAnUploader{}), chk.Equals, false)
+	c.Assert(jptm.done, chk.Equals, true)
+	c.Assert(jptm.status, chk.Equals, common.ETransferStatus.Failed())
+}
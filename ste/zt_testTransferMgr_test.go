@@ -0,0 +1,357 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// testTransferMgr is the IJobPartTransferMgr that the tests in this package run senders and downloaders against.
+// It behaves like a real transfer that is never logged, and collects the chunks it is given, for the test to run.
+// Tests that need something else embed it and override just the methods that differ.
+// Anything it doesn't implement panics, so a test that strays into new territory finds out straight away
+type testTransferMgr struct {
+	IJobPartTransferMgr
+	info    TransferInfo
+	fromTo  common.FromTo
+	ctx     context.Context // if nil, the transfer is never cancelled
+	paused  bool            // whether cancellation means the job was paused
+	resumed bool            // whether the job was resumed
+	status  common.TransferStatus
+	failure error // the first failure reported through FailActive*
+
+	mu          sync.Mutex
+	chunks      []chunkFunc
+	numChunks   uint32
+	chunksDone  uint32
+	afterLast   func()
+	finished    bool
+	destTouched bool
+	destLocked  bool
+
+	contentMD5 []byte
+	etag       string
+	checksums  *checksumFileWriter
+	openFiles  common.CacheLimiter
+	logs       []string
+
+	lastModifiedTime       time.Time
+	lastModifiedInMetadata bool
+	metadata               common.Metadata
+	ifNoneMatch            bool
+	deleteSnapshots        common.DeleteSnapshotsOption
+}
+
+func (t *testTransferMgr) Info() TransferInfo    { return t.info }
+func (t *testTransferMgr) FromTo() common.FromTo { return t.fromTo }
+func (t *testTransferMgr) Context() context.Context {
+	if t.ctx == nil {
+		return context.Background()
+	}
+	return t.ctx
+}
+func (t *testTransferMgr) WasCanceled() bool   { return t.Context().Err() != nil }
+func (t *testTransferMgr) WasPaused() bool     { return t.paused && t.WasCanceled() }
+func (t *testTransferMgr) JobWasResumed() bool { return t.resumed }
+
+// isDead, and the methods built on it, follow jobPartTransferMgr
+func (t *testTransferMgr) isDead() bool              { return t.status < 0 || t.WasCanceled() }
+func (t *testTransferMgr) IsLive() bool              { return !t.isDead() }
+func (t *testTransferMgr) IsDeadInflight() bool      { return t.isDead() && t.destTouched }
+func (t *testTransferMgr) IsDeadBeforeStart() bool   { return t.isDead() && !t.destTouched }
+func (t *testTransferMgr) SetDestinationIsModified() { t.destTouched = true }
+
+func (t *testTransferMgr) SetStatus(status common.TransferStatus) { t.status = status }
+func (t *testTransferMgr) TransferStatusIgnoringCancellation() common.TransferStatus {
+	return t.status
+}
+
+// fail records the first failure of the transfer, as every FailActive* method does
+func (t *testTransferMgr) fail(where string, err error) {
+	if err == nil {
+		err = errors.New(where)
+	}
+	if t.failure == nil {
+		t.failure = err
+	}
+	t.status = common.ETransferStatus.Failed()
+}
+func (t *testTransferMgr) FailActiveUpload(where string, err error)   { t.fail(where, err) }
+func (t *testTransferMgr) FailActiveDownload(where string, err error) { t.fail(where, err) }
+func (t *testTransferMgr) FailActiveS2SCopy(where string, err error)  { t.fail(where, err) }
+func (t *testTransferMgr) FailActiveSend(where string, err error)     { t.fail(where, err) }
+func (t *testTransferMgr) LogDownloadError(_, _, msg string, _ int)   { t.fail(msg, nil) }
+
+func (t *testTransferMgr) ScheduleChunks(cf chunkFunc)        { t.chunks = append(t.chunks, cf) }
+func (t *testTransferMgr) ScheduleChunksInOrder(cf chunkFunc) { t.chunks = append(t.chunks, cf) }
+func (t *testTransferMgr) SetNumberOfChunks(n uint32)         { t.numChunks = n }
+func (t *testTransferMgr) SetActionAfterLastChunk(f func())   { t.afterLast = f }
+func (t *testTransferMgr) ReportTransferDone() uint32         { t.finished = true; return 0 }
+func (t *testTransferMgr) ReportChunkDone(common.ChunkID) (bool, uint32) {
+	t.mu.Lock()
+	t.chunksDone++
+	last, done := t.chunksDone == t.numChunks, t.chunksDone
+	t.mu.Unlock()
+	if last && t.afterLast != nil {
+		t.afterLast()
+	}
+	return last, done
+}
+
+// runChunks runs, in order, every chunk that has been scheduled
+func (t *testTransferMgr) runChunks() {
+	for _, cf := range t.chunks {
+		cf(0)
+	}
+}
+
+func (t *testTransferMgr) WaitUntilLockDestination(context.Context) error {
+	t.destLocked = true
+	return nil
+}
+func (t *testTransferMgr) HoldsDestinationLock() bool { return t.destLocked }
+func (t *testTransferMgr) EnsureDestinationUnlocked() { t.destLocked = false }
+
+func (t *testTransferMgr) SlicePool() common.ByteSlicePooler {
+	return common.NewMultiSizeSlicePool(common.MaxBlockBlobBlockSize)
+}
+func (t *testTransferMgr) CacheLimiter() common.CacheLimiter {
+	return common.NewCacheLimiter(common.MaxBlockBlobBlockSize)
+}
+func (t *testTransferMgr) FileCountLimiter() common.CacheLimiter {
+	if t.openFiles == nil {
+		t.openFiles = common.NewCacheLimiter(concurrentFilesFloor)
+	}
+	return t.openFiles
+}
+func (t *testTransferMgr) OccupyAConnection()  {}
+func (t *testTransferMgr) ReleaseAConnection() {}
+
+func (t *testTransferMgr) ResourceDstData(_ []byte) (common.ResourceHTTPHeaders, common.Metadata, common.BlobTags, common.CpkOptions) {
+	return common.ResourceHTTPHeaders{}, t.metadata, nil, common.CpkOptions{}
+}
+func (t *testTransferMgr) LastModifiedTime() time.Time                 { return t.lastModifiedTime }
+func (t *testTransferMgr) LastModifiedInMetadata() bool                { return t.lastModifiedInMetadata }
+func (t *testTransferMgr) PreserveLastModifiedTime() (time.Time, bool) { return time.Time{}, false }
+func (t *testTransferMgr) ShouldPutMd5() bool                          { return true }
+func (t *testTransferMgr) SetContentMD5(hash []byte)                   { t.contentMD5 = hash }
+func (t *testTransferMgr) SetETag(etag string)                         { t.etag = etag }
+func (t *testTransferMgr) ShouldInferContentType() bool                { return false }
+func (t *testTransferMgr) ShouldUploadIfNoneMatch() bool               { return t.ifNoneMatch }
+func (t *testTransferMgr) ShouldValidateBlocks() bool                  { return false }
+func (t *testTransferMgr) DeleteSnapshotsOption() common.DeleteSnapshotsOption {
+	return t.deleteSnapshots
+}
+func (t *testTransferMgr) GetOverwriteOption() common.OverwriteOption {
+	return common.EOverwriteOption.True()
+}
+func (t *testTransferMgr) GetForceIfReadOnly() bool { return false }
+func (t *testTransferMgr) MD5ValidationOption() common.HashValidationOption {
+	return common.EHashValidationOption.FailIfDifferent()
+}
+func (t *testTransferMgr) ShouldDecompress() bool  { return false }
+func (t *testTransferMgr) IsSourceEncrypted() bool { return false }
+func (t *testTransferMgr) GetFolderCreationTracker() FolderCreationTracker {
+	return NewFolderCreationTracker(common.EFolderPropertiesOption.NoFolders(), nil)
+}
+func (t *testTransferMgr) ShouldWriteChecksum() bool { return t.checksums != nil }
+func (t *testTransferMgr) WriteChecksum(md5Hash []byte) {
+	t.checksums.Add(md5Hash, checksumRelativePath(filepath.Dir(t.info.Destination), t.info.Destination))
+}
+
+// logs are kept, for tests that check what was said, but nothing is logged at a level that has to be asked for
+func (t *testTransferMgr) ShouldLog(pipeline.LogLevel) bool { return false }
+func (t *testTransferMgr) Log(_ pipeline.LogLevel, msg string) {
+	t.mu.Lock()
+	t.logs = append(t.logs, msg)
+	t.mu.Unlock()
+}
+func (t *testTransferMgr) LogAtLevelForCurrentTransfer(pipeline.LogLevel, string) {}
+func (t *testTransferMgr) LogError(string, string, error)                         {}
+func (t *testTransferMgr) LogChunkStatus(common.ChunkID, common.WaitReason)       {}
+func (t *testTransferMgr) IsWaitingOnFinalBodyReads() bool                        { return false }
+func (t *testTransferMgr) ChunkStatusLogger() common.ChunkStatusLogger            { return t }
+
+// localSourceInfoProvider is the source of an upload from local disk
+type localSourceInfoProvider struct {
+	ISourceInfoProvider
+}
+
+func (localSourceInfoProvider) IsLocal() bool { return true }
+
+// testPipeline talks to a testBlobServer, and gives up after the first failure
+func testPipeline() pipeline.Pipeline {
+	return azblob.NewPipeline(azblob.NewAnonymousCredential(), azblob.PipelineOptions{Retry: azblob.RetryOptions{MaxTries: 1}})
+}
+
+// newTestBlockBlobUploader makes an uploader that sends a local file, in one chunk, to the given blob URL
+func newTestBlockBlobUploader(jptm IJobPartTransferMgr, blobURL string) (*blockBlobUploader, error) {
+	u, err := url.Parse(blobURL)
+	if err != nil {
+		return nil, err
+	}
+	return &blockBlobUploader{
+		blockBlobSenderBase: blockBlobSenderBase{
+			jptm:             jptm,
+			destBlockBlobURL: azblob.NewBlockBlobURL(*u, testPipeline()),
+			chunkSize:        common.DefaultBlockBlobBlockSize,
+			numChunks:        1,
+			pacer:            newNullAutoPacer(),
+			metadataToApply:  azblob.Metadata{"origin": "local"},
+			muBlockIDs:       &sync.Mutex{},
+		},
+		md5Channel: newMd5Channel(),
+	}, nil
+}
+
+// downloads wait for permission to open files (for end-to-end testing) unless it has been turned off, which can only happen once
+var disableAwaitOpenFilesOnce sync.Once
+
+// startTestDownload schedules the chunks of a blob download, which the test then runs
+func startTestDownload(jptm IJobPartTransferMgr) {
+	disableAwaitOpenFilesOnce.Do(func() { common.GetLifecycleMgr().E2EEnableAwaitAllowOpenFiles(false) })
+	bd := newBlobDownloader().(*blobDownloader)
+	remoteToLocal_file(jptm, testPipeline(), newNullAutoPacer(), func() downloader { return bd })
+}
+
+// testRequest is one request that a testBlobServer received
+type testRequest struct {
+	method string
+	path   string
+	query  url.Values
+	header http.Header
+	body   []byte
+}
+
+// testBlobServer stands in for the Blob service. It records every request it receives, and answers it
+// with its responder, or, if there is none, with the status code of a successful blob operation
+type testBlobServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []testRequest
+}
+
+func newTestBlobServer(respond func(w http.ResponseWriter, r testRequest)) *testBlobServer {
+	s := &testBlobServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		r := testRequest{method: req.Method, path: req.URL.Path, query: req.URL.Query(), header: req.Header, body: body}
+		s.mu.Lock()
+		s.requests = append(s.requests, r)
+		s.mu.Unlock()
+
+		switch {
+		case respond != nil:
+			respond(w, r)
+		case r.method == http.MethodDelete:
+			w.WriteHeader(http.StatusAccepted)
+		case r.method == http.MethodPut:
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	return s
+}
+
+// newTestBlobWithContent serves the properties and ranges of a single block blob
+func newTestBlobWithContent(content []byte, etag string) *testBlobServer {
+	return newTestBlobServer(func(w http.ResponseWriter, r testRequest) {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("x-ms-blob-type", "BlockBlob")
+		if r.method == http.MethodHead {
+			w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		var start, end int
+		_, _ = fmt.Sscanf(r.header.Get("x-ms-range"), "bytes=%d-%d", &start, &end)
+		w.Header().Set("Content-Length", fmt.Sprint(end-start+1))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write(content[start : end+1])
+	})
+}
+
+// writeStorageError answers as the service does when it refuses an operation
+func writeStorageError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("x-ms-error-code", code)
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(`<?xml version="1.0" encoding="utf-8"?><Error><Code>` + code + `</Code><Message>` + message + `</Message></Error>`))
+}
+
+func (s *testBlobServer) received() []testRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]testRequest{}, s.requests...)
+}
+
+func (s *testBlobServer) methods() []string {
+	methods := []string{}
+	for _, r := range s.received() {
+		methods = append(methods, r.method)
+	}
+	return methods
+}
+
+// lastRequest finds the latest request with the given method to the given path
+func (s *testBlobServer) lastRequest(method, path string) (testRequest, bool) {
+	requests := s.received()
+	for i := len(requests) - 1; i >= 0; i-- {
+		if requests[i].method == method && requests[i].path == path {
+			return requests[i], true
+		}
+	}
+	return testRequest{}, false
+}
+
+// ranges lists the ranges that were downloaded, in the order they were asked for
+func (s *testBlobServer) ranges() []string {
+	ranges := []string{}
+	for _, r := range s.received() {
+		if r.method == http.MethodGet {
+			ranges = append(ranges, r.header.Get("x-ms-range"))
+		}
+	}
+	return ranges
+}
+
+// blobURL is the URL of the named blob in this server's container
+func (s *testBlobServer) blobURL(c *chk.C, name string) azblob.BlobURL {
+	u, err := url.Parse(s.URL + "/container/" + name)
+	c.Assert(err, chk.IsNil)
+	return azblob.NewBlobURL(*u, testPipeline())
+}
@@ -0,0 +1,60 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"time"
+
+	chk "gopkg.in/check.v1"
+)
+
+type throughputEstimatorSuite struct{}
+
+var _ = chk.Suite(&throughputEstimatorSuite{})
+
+func (s *throughputEstimatorSuite) TestThroughputEstimatorSmoothing(c *chk.C) {
+	t := &throughputEstimator{}
+	start := time.Now()
+
+	// nothing to compare with on the first sample
+	c.Assert(t.update(0, start), chk.Equals, float64(0))
+
+	// steady 1000 bytes/sec
+	c.Assert(t.update(2000, start.Add(2*time.Second)), chk.Equals, float64(1000))
+
+	// a sudden burst moves the estimate towards the new rate, but doesn't jump all the way there
+	rate := t.update(12000, start.Add(3*time.Second))
+	c.Assert(rate > 1000, chk.Equals, true)
+	c.Assert(rate < 10000, chk.Equals, true)
+
+	// and a stall decays it towards zero
+	stalled := t.update(12000, start.Add(4*time.Second))
+	c.Assert(stalled < rate, chk.Equals, true)
+	c.Assert(t.update(12000, start.Add(30*time.Second)), chk.Equals, float64(0))
+}
+
+func (s *throughputEstimatorSuite) TestEstimateSecondsRemaining(c *chk.C) {
+	c.Assert(estimateSecondsRemaining(1000, 100), chk.Equals, float64(10))
+	c.Assert(estimateSecondsRemaining(0, 0), chk.Equals, float64(0))
+
+	// no division by zero when stalled
+	c.Assert(estimateSecondsRemaining(1000, 0), chk.Equals, float64(-1))
+}
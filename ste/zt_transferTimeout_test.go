@@ -0,0 +1,150 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+type transferTimeoutSuite struct{}
+
+var _ = chk.Suite(&transferTimeoutSuite{})
+
+// timeoutJobPartMgr runs each transfer as a single chunk that GETs url, and says when the transfer is done
+type timeoutJobPartMgr struct {
+	IJobPartMgr
+	plan *JobPartPlanHeader
+	url  string
+	done chan common.TransferStatus
+
+	mu   sync.Mutex
+	logs []string
+}
+
+func (m *timeoutJobPartMgr) Plan() *JobPartPlanHeader         { return m.plan }
+func (m *timeoutJobPartMgr) SendXferDoneMsg(xferDoneMsg)      {}
+func (m *timeoutJobPartMgr) ShouldLog(pipeline.LogLevel) bool { return false }
+func (m *timeoutJobPartMgr) Log(_ pipeline.LogLevel, msg string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.logs = append(m.logs, msg)
+}
+func (m *timeoutJobPartMgr) ReportTransferDone(s common.TransferStatus) uint32 {
+	m.done <- s
+	return 0
+}
+
+func (m *timeoutJobPartMgr) StartJobXfer(t IJobPartTransferMgr) {
+	jptm := t.(*jobPartTransferMgr)
+	go func() {
+		req, _ := http.NewRequest(http.MethodGet, m.url, nil)
+		resp, err := http.DefaultClient.Do(req.WithContext(jptm.Context()))
+		if err != nil {
+			jptm.failActive("reading from the backend", err)
+		} else {
+			resp.Body.Close()
+			jptm.SetStatus(common.ETransferStatus.Success())
+		}
+		jptm.ReportChunkDone(common.NewChunkID("src", 0, 1))
+		jptm.ReportTransferDone()
+	}()
+}
+
+// runTimedTransfer runs one transfer against a backend that takes delay to respond, with the given transfer timeout
+func runTimedTransfer(c *chk.C, fromTo common.FromTo, delay, timeout time.Duration) (common.TransferStatus, *jobPartTransferMgr) {
+	defer withPlanDir(c.MkDir())() // reporting chunks done updates the JobsAdmin's count of bytes in active files
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+		}
+	}))
+	defer backend.Close()
+
+	jpm := &timeoutJobPartMgr{
+		plan: &JobPartPlanHeader{FromTo: fromTo, TransferTimeout: timeout},
+		url:  backend.URL,
+		done: make(chan common.TransferStatus, 1),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	jptm := &jobPartTransferMgr{
+		jobPartMgr:          jpm,
+		jobPartPlanTransfer: &JobPartPlanTransfer{},
+		transferInfo:        &TransferInfo{Source: "src", Destination: "dst"},
+		ctx:                 ctx,
+		cancel:              cancel,
+		numChunks:           1,
+	}
+	jptm.SetStatus(common.ETransferStatus.Started())
+	jptm.StartJobXfer()
+
+	select {
+	case status := <-jpm.done:
+		return status, jptm
+	case <-time.After(10 * time.Second):
+		c.Fatal("transfer never finished")
+	}
+	panic("unreachable")
+}
+
+func (s *transferTimeoutSuite) TestStalledTransferTimesOut(c *chk.C) {
+	status, jptm := runTimedTransfer(c, common.EFromTo.LocalBlob(), time.Minute, 50*time.Millisecond)
+	c.Assert(status, chk.Equals, common.ETransferStatus.Failed())
+	c.Assert(strings.Contains(jptm.failureReason(), "transfer timed out"), chk.Equals, true, chk.Commentf("reason was %q", jptm.failureReason()))
+}
+
+func (s *transferTimeoutSuite) TestTimeoutClearedOnCompletion(c *chk.C) {
+	status, jptm := runTimedTransfer(c, common.EFromTo.LocalBlob(), 0, 100*time.Millisecond)
+	c.Assert(status, chk.Equals, common.ETransferStatus.Success())
+
+	// the timer must not fire after the transfer is done
+	time.Sleep(200 * time.Millisecond)
+	c.Assert(jptm.TransferStatusIgnoringCancellation(), chk.Equals, common.ETransferStatus.Success())
+	c.Assert(jptm.failureReason(), chk.Equals, "")
+}
+
+func (s *transferTimeoutSuite) TestTimedOutTransferFailsForItsDirection(c *chk.C) {
+	for fromTo, errorCode := range map[common.FromTo]transferErrorCode{
+		common.EFromTo.LocalBlob(): transferErrorCodeUploadFailed,
+		common.EFromTo.BlobLocal(): transferErrorCodeDownloadFailed,
+		common.EFromTo.BlobBlob():  transferErrorCodeCopyFailed,
+	} {
+		status, jptm := runTimedTransfer(c, fromTo, time.Minute, 50*time.Millisecond)
+		c.Assert(status, chk.Equals, common.ETransferStatus.Failed())
+
+		jpm := jptm.jobPartMgr.(*timeoutJobPartMgr)
+		jpm.mu.Lock()
+		logs := strings.Join(jpm.logs, "\n")
+		jpm.mu.Unlock()
+		c.Assert(strings.Contains(logs, string(errorCode)), chk.Equals, true, chk.Commentf("%v logged %q", fromTo, logs))
+		c.Assert(strings.Contains(logs, "check operation type"), chk.Equals, false, chk.Commentf("%v logged %q", fromTo, logs))
+	}
+}
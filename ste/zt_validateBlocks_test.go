@@ -0,0 +1,158 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+type validateBlocksSuite struct{}
+
+var _ = chk.Suite(&validateBlocksSuite{})
+
+type validateBlocksTransferMgr struct {
+	testTransferMgr
+	validateBlocks bool
+}
+
+func (t *validateBlocksTransferMgr) ShouldValidateBlocks() bool                             { return t.validateBlocks }
+func (t *validateBlocksTransferMgr) LogAtLevelForCurrentTransfer(pipeline.LogLevel, string) {}
+
+// corruptingBlockServer flips a bit in the first attempt at each block, as a faulty network might, and then does what
+// the service does: rejects a block whose Content-MD5 doesn't match what arrived, and otherwise stages it
+type corruptingBlockServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	attempts map[string]int
+	staged   map[string][]byte
+}
+
+func newCorruptingBlockServer() *corruptingBlockServer {
+	s := &corruptingBlockServer{attempts: map[string]int{}, staged: map[string][]byte{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		if req.URL.Query().Get("comp") != "block" {
+			w.WriteHeader(http.StatusNotFound) // there's no earlier attempt to find
+			return
+		}
+		blockID := req.URL.Query().Get("blockid")
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.attempts[blockID]++
+		if s.attempts[blockID] == 1 {
+			body[0] ^= 1
+		}
+		if contentMD5 := req.Header.Get("Content-MD5"); contentMD5 != "" {
+			sum := md5.Sum(body)
+			if contentMD5 != base64.StdEncoding.EncodeToString(sum[:]) {
+				w.Header().Set("x-ms-error-code", "Md5Mismatch")
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+		s.staged[blockID] = body
+		w.WriteHeader(http.StatusCreated)
+	}))
+	return s
+}
+
+// stageBlocks runs a two-block upload's chunk funcs against the server, and gives back the content of the file
+func (s *validateBlocksSuite) stageBlocks(c *chk.C, server *corruptingBlockServer, jptm *validateBlocksTransferMgr) []byte {
+	content := make([]byte, 2*1024)
+	for i := range content {
+		content[i] = byte(i)
+	}
+	srcPath := filepath.Join(c.MkDir(), "blocks.bin")
+	c.Assert(ioutil.WriteFile(srcPath, content, 0644), chk.IsNil)
+
+	jptm.info = TransferInfo{Source: srcPath, SourceSize: int64(len(content))}
+	uploader, err := newTestBlockBlobUploader(jptm, server.URL+"/container/blocks.bin")
+	c.Assert(err, chk.IsNil)
+	uploader.chunkSize = 1024
+	uploader.numChunks = 2
+	uploader.blockIDs = make([]string, 2)
+
+	srcFile, err := os.Open(srcPath)
+	c.Assert(err, chk.IsNil)
+	defer srcFile.Close()
+	factory := func() (common.CloseableReaderAt, error) { return os.Open(srcPath) }
+
+	scheduleSendChunks(jptm, srcPath, srcFile, int64(len(content)), uploader, factory, localSourceInfoProvider{})
+	c.Assert(jptm.chunks, chk.HasLen, 2)
+	for _, cf := range jptm.chunks {
+		cf(0)
+	}
+	return content
+}
+
+func (s *validateBlocksSuite) TestCorruptedBlockIsRejectedAndSentAgain(c *chk.C) {
+	server := newCorruptingBlockServer()
+	defer server.Close()
+
+	jptm := &validateBlocksTransferMgr{validateBlocks: true}
+	content := s.stageBlocks(c, server, jptm)
+	c.Assert(jptm.failure, chk.IsNil)
+	c.Assert(server.staged, chk.HasLen, 2)
+	for blockID, block := range server.staged {
+		c.Assert(server.attempts[blockID], chk.Equals, 2)
+		c.Assert(bytes.Contains(content, block), chk.Equals, true, chk.Commentf("block %s was staged corrupted", blockID))
+	}
+}
+
+func (s *validateBlocksSuite) TestCorruptedBlockIsStagedWithoutValidation(c *chk.C) {
+	server := newCorruptingBlockServer()
+	defer server.Close()
+
+	jptm := &validateBlocksTransferMgr{}
+	content := s.stageBlocks(c, server, jptm)
+	c.Assert(jptm.failure, chk.IsNil)
+	c.Assert(server.staged, chk.HasLen, 2)
+	for blockID, block := range server.staged {
+		c.Assert(server.attempts[blockID], chk.Equals, 1)
+		c.Assert(bytes.Contains(content, block), chk.Equals, false) // nothing noticed, until the whole blob is checked
+	}
+}
+
+func (s *validateBlocksSuite) TestTransferFailsWhenEveryAttemptIsCorrupted(c *chk.C) {
+	defer func(old int) { UploadChunkRetries = old }(UploadChunkRetries)
+	UploadChunkRetries = 0
+
+	server := newCorruptingBlockServer()
+	defer server.Close()
+
+	jptm := &validateBlocksTransferMgr{validateBlocks: true}
+	s.stageBlocks(c, server, jptm)
+	c.Assert(jptm.failure, chk.NotNil)
+	c.Assert(server.staged, chk.HasLen, 0)
+}
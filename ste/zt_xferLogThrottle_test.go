@@ -0,0 +1,152 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	chk "gopkg.in/check.v1"
+)
+
+type xferLogThrottleSuite struct{}
+
+var _ = chk.Suite(&xferLogThrottleSuite{})
+
+// logLineRecorder keeps the lines logged by a pipeline, at or above a given level
+type logLineRecorder struct {
+	level pipeline.LogLevel
+	mu    sync.Mutex
+	lines []string
+}
+
+func (r *logLineRecorder) options() pipeline.LogOptions {
+	return pipeline.LogOptions{
+		ShouldLog: func(level pipeline.LogLevel) bool { return level <= r.level },
+		Log: func(_ pipeline.LogLevel, msg string) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.lines = append(r.lines, msg)
+		},
+	}
+}
+
+func (r *logLineRecorder) count(substring string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, l := range r.lines {
+		if strings.Contains(l, substring) {
+			n++
+		}
+	}
+	return n
+}
+
+// sendRequests makes the given number of requests through a pipeline that logs the way transfers do
+func (s *xferLogThrottleSuite) sendRequests(c *chk.C, ctx context.Context, logger *logLineRecorder, serverURL string, method string, comp string, count int) {
+	p := pipeline.NewPipeline([]pipeline.Factory{NewRequestLogPolicyFactory(RequestLogOptions{SyslogDisabled: true})},
+		pipeline.Options{Log: logger.options()})
+	u, err := url.Parse(serverURL + "/container/blob?comp=" + comp)
+	c.Assert(err, chk.IsNil)
+
+	for i := 0; i < count; i++ {
+		req, err := pipeline.NewRequest(method, *u, nil)
+		c.Assert(err, chk.IsNil)
+		resp, err := p.Do(ctx, nil, req)
+		c.Assert(err, chk.IsNil)
+		resp.Response().Body.Close()
+	}
+}
+
+func (s *xferLogThrottleSuite) TestManyChunksLogABoundedNumberOfLines(c *chk.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("comp") == "fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+	logger := &logLineRecorder{level: pipeline.LogInfo}
+	ctx := withRequestLogThrottle(context.Background(), newRequestLogThrottle())
+
+	// one Put Block for each of 3000 chunks gives requests 1, 2, 4, ... 2048: 12 lines
+	s.sendRequests(c, ctx, logger, server.URL, http.MethodPut, "block", 3000)
+	c.Assert(logger.count("comp=block"), chk.Equals, 12)
+	c.Assert(logger.count("request 2048 like this"), chk.Equals, 1)
+
+	// the commit is a different kind of request, so it is always logged
+	s.sendRequests(c, ctx, logger, server.URL, http.MethodPut, "blocklist", 1)
+	c.Assert(logger.count("comp=blocklist"), chk.Equals, 1)
+
+	// and failures are never sampled
+	s.sendRequests(c, ctx, logger, server.URL, http.MethodPut, "fail", 5)
+	c.Assert(logger.count("RESPONSE STATUS CODE ERROR"), chk.Equals, 5)
+}
+
+func (s *xferLogThrottleSuite) TestNothingSampledWithoutThrottleOrAtDebugLevel(c *chk.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	logger := &logLineRecorder{level: pipeline.LogInfo}
+	s.sendRequests(c, context.Background(), logger, server.URL, http.MethodPut, "block", 100)
+	c.Assert(logger.count("RESPONSE SUCCESSFULLY RECEIVED"), chk.Equals, 100)
+
+	logger = &logLineRecorder{level: pipeline.LogDebug}
+	ctx := withRequestLogThrottle(context.Background(), newRequestLogThrottle())
+	s.sendRequests(c, ctx, logger, server.URL, http.MethodPut, "block", 100)
+	c.Assert(logger.count("RESPONSE SUCCESSFULLY RECEIVED"), chk.Equals, 100)
+}
+
+func (s *xferLogThrottleSuite) TestThrottleIsSafeAcrossWorkers(c *chk.C) {
+	t := newRequestLogThrottle()
+	req, err := http.NewRequest(http.MethodPut, "https://account.blob.core.windows.net/container/blob?comp=block", nil)
+	c.Assert(err, chk.IsNil)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	logged := 0
+	for w := 0; w < 16; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 256; i++ {
+				if ok, _ := t.shouldLog(req); ok {
+					mu.Lock()
+					logged++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 4096 requests in all, so exactly 1, 2, 4, ... 4096 were chosen, whichever workers made them
+	c.Assert(logged, chk.Equals, 13)
+}